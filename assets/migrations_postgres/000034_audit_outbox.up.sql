@@ -0,0 +1,16 @@
+-- One row per (sink, record) awaiting delivery to an external audit sink. Rows
+-- are written in the same transaction as the audit record and deleted once the
+-- sink acknowledges them.
+CREATE TABLE audit_outbox (
+    id              TEXT        NOT NULL PRIMARY KEY,
+    sink_id         TEXT        NOT NULL,
+    record_id       TEXT        NOT NULL REFERENCES audit_records(id) ON DELETE CASCADE,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL,
+    UNIQUE (sink_id, record_id)
+);
+
+CREATE INDEX idx_audit_outbox_due
+    ON audit_outbox(sink_id, next_attempt_at, id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS service_accounts;
+ALTER TABLE accounts
+    DROP COLUMN kind;
@@ -0,0 +1,4 @@
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS sso_issuer;
+DROP TABLE IF EXISTS sso_group_mappings;
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS account_identities;
@@ -0,0 +1,38 @@
+-- Links an external OpenID Connect identity (issuer + subject) to an account.
+CREATE TABLE account_identities (
+    id            TEXT        NOT NULL PRIMARY KEY,
+    account_id    BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    issuer        TEXT        NOT NULL,
+    subject       TEXT        NOT NULL,
+    email         TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_account_identities_account
+    ON account_identities(account_id);
+
+-- Pending authorization requests, keyed by the SHA-256 hash of the state
+-- parameter. Each row is consumed by the first callback that presents it.
+CREATE TABLE oidc_login_states (
+    id            TEXT        NOT NULL PRIMARY KEY,
+    org_id        BIGINT      REFERENCES organizations(id) ON DELETE CASCADE,
+    nonce         TEXT        NOT NULL,
+    code_verifier TEXT        NOT NULL,
+    return_to     TEXT,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Maps an ID token group claim value to a team in the organization.
+CREATE TABLE sso_group_mappings (
+    org_id     BIGINT      NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    group_name TEXT        NOT NULL,
+    team_id    BIGINT      NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_id, group_name, team_id)
+);
+
+ALTER TABLE auth_sessions
+    ADD COLUMN sso_issuer TEXT;
@@ -0,0 +1,6 @@
+ALTER TABLE instance_settings DROP COLUMN mfa_required;
+ALTER TABLE organizations DROP COLUMN mfa_required;
+ALTER TABLE auth_sessions DROP COLUMN mfa_method;
+DROP TABLE IF EXISTS mfa_login_challenges;
+DROP TABLE IF EXISTS account_mfa_recovery_codes;
+DROP TABLE IF EXISTS account_mfa;
@@ -0,0 +1,37 @@
+-- TOTP enrollment per account. The secret is encrypted with the keyring.
+-- enabled_at stays NULL until the first code is confirmed; totp_last_step is
+-- the last accepted time step, so a code cannot be replayed.
+CREATE TABLE account_mfa (
+    account_id            BIGINT      NOT NULL PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    totp_secret_encrypted TEXT        NOT NULL,
+    totp_last_step        BIGINT      NOT NULL DEFAULT 0,
+    enabled_at            TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Single-use recovery codes, stored as SHA-256 hashes.
+CREATE TABLE account_mfa_recovery_codes (
+    id         TEXT        NOT NULL PRIMARY KEY,
+    account_id BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    code_hash  TEXT        NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_account_mfa_recovery_codes_account
+    ON account_mfa_recovery_codes(account_id);
+
+-- Password sign-ins waiting for their second factor, keyed by the SHA-256
+-- hash of the challenge token.
+CREATE TABLE mfa_login_challenges (
+    id         TEXT        NOT NULL PRIMARY KEY,
+    account_id BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    attempts   INTEGER     NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE auth_sessions ADD COLUMN mfa_method TEXT;
+ALTER TABLE organizations ADD COLUMN mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE instance_settings ADD COLUMN mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE instance_settings DROP COLUMN webauthn_user_verification;
+DROP TABLE IF EXISTS webauthn_ceremonies;
+DROP TABLE IF EXISTS webauthn_credentials;
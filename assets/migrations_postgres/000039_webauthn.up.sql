@@ -0,0 +1,35 @@
+-- WebAuthn credentials (passkeys and security keys). credential_id and
+-- public_key are base64url; public_key is the COSE key from registration.
+-- sign_count is the last counter the authenticator reported.
+CREATE TABLE webauthn_credentials (
+    id              TEXT        NOT NULL PRIMARY KEY,
+    account_id      BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    credential_id   TEXT        NOT NULL UNIQUE,
+    public_key      TEXT        NOT NULL,
+    algorithm       BIGINT      NOT NULL,
+    sign_count      BIGINT      NOT NULL DEFAULT 0,
+    aaguid          TEXT        NOT NULL DEFAULT '',
+    transports      TEXT        NOT NULL,
+    name            TEXT        NOT NULL,
+    backup_eligible BOOLEAN     NOT NULL DEFAULT FALSE,
+    last_used_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webauthn_credentials_account
+    ON webauthn_credentials(account_id);
+
+-- Pending registration and authentication ceremonies, keyed by the SHA-256
+-- hash of the challenge. Passwordless sign-in ceremonies have no account yet;
+-- second-factor ceremonies belong to an MFA login challenge.
+CREATE TABLE webauthn_ceremonies (
+    id                TEXT        NOT NULL PRIMARY KEY,
+    purpose           TEXT        NOT NULL,
+    account_id        BIGINT      REFERENCES accounts(id) ON DELETE CASCADE,
+    mfa_challenge_id  TEXT        REFERENCES mfa_login_challenges(id) ON DELETE CASCADE,
+    user_verification TEXT        NOT NULL,
+    expires_at        TIMESTAMPTZ NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE instance_settings ADD COLUMN webauthn_user_verification TEXT NOT NULL DEFAULT 'preferred';
@@ -0,0 +1,15 @@
+-- Failed sign-in attempts, per account email and per client IP. The row is
+-- reset after a successful sign-in (email only), when the last failure is
+-- older than the lockout window, or when an instance admin unlocks it.
+CREATE TABLE login_failures (
+    scope          TEXT        NOT NULL,
+    subject        TEXT        NOT NULL,
+    failures       INTEGER     NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until   TIMESTAMPTZ,
+    PRIMARY KEY (scope, subject)
+);
+
+ALTER TABLE instance_settings ADD COLUMN login_lockout_threshold INTEGER NOT NULL DEFAULT 10;
+ALTER TABLE instance_settings ADD COLUMN login_ip_lockout_threshold INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE instance_settings ADD COLUMN login_lockout_seconds BIGINT NOT NULL DEFAULT 900;
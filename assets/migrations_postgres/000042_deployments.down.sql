@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS deployment_targets;
+DROP TABLE IF EXISTS deployments;
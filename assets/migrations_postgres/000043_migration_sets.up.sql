@@ -0,0 +1,16 @@
+-- A migration set registers a shared workspace folder of numbered SQL files
+-- as migrations for target databases. Applied state is kept on each target in
+-- the set's tracking table, not here.
+CREATE TABLE migration_sets (
+    id           BIGSERIAL   PRIMARY KEY,
+    org_id       BIGINT      NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workspace_id BIGINT      NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    folder_id    BIGINT      NOT NULL REFERENCES workspace_files(id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    table_name   TEXT        NOT NULL,
+    created_by   BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, name),
+    UNIQUE (workspace_id, folder_id)
+);
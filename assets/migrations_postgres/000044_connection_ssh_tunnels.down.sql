@@ -0,0 +1,2 @@
+ALTER TABLE connections DROP COLUMN ssh_tunnel_enabled;
+ALTER TABLE connections DROP COLUMN ssh_tunnel_encrypted;
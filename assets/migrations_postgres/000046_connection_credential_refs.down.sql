@@ -0,0 +1 @@
+ALTER TABLE connections DROP COLUMN credential_ref;
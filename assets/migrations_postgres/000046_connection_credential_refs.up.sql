@@ -0,0 +1,6 @@
+-- A secret reference, such as "vault:database/creds/readonly", resolved each
+-- time the connection is opened. Its credentials replace the user and password
+-- of the structured config, so the stored DSN holds no password. Empty means
+-- the stored DSN is used as is.
+ALTER TABLE connections
+    ADD COLUMN credential_ref TEXT NOT NULL DEFAULT '';
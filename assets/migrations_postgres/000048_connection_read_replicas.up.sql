@@ -0,0 +1,12 @@
+-- Read replicas of a connection. A replica shares the connection's
+-- credentials, TLS settings, and SSH tunnel and differs only in host and
+-- port; a port of 0 keeps the connection's own. Position keeps the order
+-- reads are routed in.
+CREATE TABLE connection_read_replicas (
+    connection_id BIGINT  NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    name          TEXT    NOT NULL,
+    host          TEXT    NOT NULL,
+    port          INTEGER NOT NULL DEFAULT 0,
+    position      INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (connection_id, name)
+);
@@ -0,0 +1,2 @@
+DROP TABLE connection_health_checks;
+ALTER TABLE connections DROP COLUMN health_check_enabled;
@@ -0,0 +1,4 @@
+ALTER TABLE connections DROP COLUMN query_max_execution_seconds;
+ALTER TABLE environments DROP COLUMN query_max_execution_seconds;
+ALTER TABLE organization_runtime_settings DROP COLUMN query_max_execution_seconds;
+ALTER TABLE instance_settings DROP COLUMN query_max_execution_seconds;
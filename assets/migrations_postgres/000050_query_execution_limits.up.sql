@@ -0,0 +1,6 @@
+-- Maximum statement run time in seconds. 0 on the instance means unlimited;
+-- organization, environment, and connection values may only tighten it.
+ALTER TABLE instance_settings ADD COLUMN query_max_execution_seconds BIGINT NOT NULL DEFAULT 0 CHECK (query_max_execution_seconds >= 0);
+ALTER TABLE organization_runtime_settings ADD COLUMN query_max_execution_seconds BIGINT CHECK (query_max_execution_seconds > 0);
+ALTER TABLE environments ADD COLUMN query_max_execution_seconds BIGINT CHECK (query_max_execution_seconds > 0);
+ALTER TABLE connections ADD COLUMN query_max_execution_seconds BIGINT CHECK (query_max_execution_seconds > 0);
@@ -0,0 +1,2 @@
+ALTER TABLE connections DROP COLUMN session_init;
+ALTER TABLE environments DROP COLUMN session_init;
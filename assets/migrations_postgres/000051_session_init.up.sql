@@ -0,0 +1,4 @@
+-- Session variables and statements run on every new interactive session, as
+-- JSON. A connection's own list takes precedence over its environment's.
+ALTER TABLE environments ADD COLUMN session_init TEXT;
+ALTER TABLE connections ADD COLUMN session_init TEXT;
@@ -0,0 +1,22 @@
+-- Connector agents run inside private networks and dial out to the server, so
+-- connections bound to one reach their database through the agent. The
+-- registration token is stored as a SHA-256 hash; the plaintext is shown once.
+CREATE TABLE connector_agents (
+    id                    BIGSERIAL   PRIMARY KEY,
+    org_id                BIGINT      NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name                  TEXT        NOT NULL,
+    token_prefix          TEXT        NOT NULL,
+    token_hash            TEXT        NOT NULL UNIQUE,
+    version               TEXT        NOT NULL DEFAULT '',
+    remote_addr           TEXT        NOT NULL DEFAULT '',
+    last_heartbeat_at     TIMESTAMPTZ,
+    created_by_account_id BIGINT      REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, name)
+);
+
+ALTER TABLE connections ADD COLUMN agent_id BIGINT REFERENCES connector_agents(id);
+
+CREATE INDEX idx_connections_agent
+    ON connections(agent_id);
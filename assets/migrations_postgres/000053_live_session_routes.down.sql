@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_live_session_routes_replica;
+DROP INDEX IF EXISTS idx_live_session_routes_session;
+DROP TABLE IF EXISTS live_session_routes;
+DROP TABLE IF EXISTS server_replicas;
@@ -0,0 +1,2 @@
+ALTER TABLE environments DROP COLUMN ddl_windows;
+ALTER TABLE environments DROP COLUMN protection_level;
@@ -0,0 +1,13 @@
+-- A SCIM client that matches an existing account by email only proposes a
+-- link. Until the account holder confirms it, the identity provider cannot
+-- add the account to the organization, change its profile or sign it out;
+-- requested_active holds the state the client last asked for meanwhile.
+-- Existing links stay confirmed only where the organization was already the
+-- account's sole claim.
+ALTER TABLE scim_users ADD COLUMN confirmed_at TIMESTAMPTZ;
+ALTER TABLE scim_users ADD COLUMN requested_active BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE scim_users SET confirmed_at = created_at
+WHERE NOT EXISTS (SELECT 1 FROM instance_admins AS ia WHERE ia.account_id = scim_users.account_id)
+  AND NOT EXISTS (SELECT 1 FROM org_members AS om WHERE om.account_id = scim_users.account_id AND om.org_id <> scim_users.org_id)
+  AND NOT EXISTS (SELECT 1 FROM scim_users AS other WHERE other.account_id = scim_users.account_id AND other.org_id <> scim_users.org_id);
@@ -0,0 +1,4 @@
+-- A personal access token satisfies an MFA requirement only when the session
+-- that created it had presented a second factor. Tokens created before this
+-- was recorded do not qualify and must be recreated.
+ALTER TABLE api_tokens ADD COLUMN mfa_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,33 @@
+-- Audit records are hash-chained per chain: one chain per organization plus an
+-- instance chain. They intentionally carry no foreign keys so history outlives
+-- the organizations and accounts it describes.
+CREATE TABLE audit_records (
+    id               TEXT     NOT NULL PRIMARY KEY,
+    chain            TEXT     NOT NULL,
+    seq              INTEGER  NOT NULL,
+    org_id           INTEGER,
+    actor_account_id INTEGER,
+    action           TEXT     NOT NULL,
+    resource_type    TEXT,
+    resource_id      TEXT,
+    details_json     TEXT,
+    occurred_at      DATETIME NOT NULL,
+    prev_hash        TEXT     NOT NULL,
+    hash             TEXT     NOT NULL,
+    key_id           TEXT     NOT NULL,
+    UNIQUE (chain, seq)
+);
+
+CREATE INDEX idx_audit_records_org
+    ON audit_records(org_id, occurred_at);
+
+CREATE TABLE audit_checkpoints (
+    id          TEXT     NOT NULL PRIMARY KEY,
+    chain       TEXT     NOT NULL,
+    seq         INTEGER  NOT NULL,
+    record_hash TEXT     NOT NULL,
+    key_id      TEXT     NOT NULL,
+    signature   TEXT     NOT NULL,
+    created_at  DATETIME NOT NULL,
+    UNIQUE (chain, seq)
+);
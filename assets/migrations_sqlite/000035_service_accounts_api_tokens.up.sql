@@ -0,0 +1,34 @@
+ALTER TABLE accounts ADD COLUMN kind TEXT NOT NULL DEFAULT 'user';
+
+-- A service account is a non-interactive account owned by one organization.
+-- The backing accounts row makes it an ordinary RBAC principal.
+CREATE TABLE service_accounts (
+    account_id            INTEGER     NOT NULL PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    org_id                INTEGER     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    slug                  TEXT        NOT NULL,
+    description           TEXT,
+    created_by_account_id INTEGER     REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at            DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, slug)
+);
+
+-- API tokens are stored as SHA-256 hashes; the plaintext is shown once.
+CREATE TABLE api_tokens (
+    id                    TEXT        NOT NULL PRIMARY KEY,
+    account_id            INTEGER     NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    org_id                INTEGER     REFERENCES organizations(id) ON DELETE CASCADE,
+    name                  TEXT        NOT NULL,
+    token_prefix          TEXT        NOT NULL,
+    token_hash            TEXT        NOT NULL UNIQUE,
+    scopes                TEXT        NOT NULL,
+    expires_at            DATETIME,
+    last_used_at          DATETIME,
+    last_used_ip          TEXT,
+    revoked_at            DATETIME,
+    created_by_account_id INTEGER     REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at            DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_tokens_account
+    ON api_tokens(account_id, revoked_at);
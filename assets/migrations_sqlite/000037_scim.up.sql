@@ -0,0 +1,39 @@
+-- SCIM bearer tokens are org-scoped and stored as SHA-256 hashes; the
+-- plaintext is shown once.
+CREATE TABLE scim_tokens (
+    id                    TEXT        NOT NULL PRIMARY KEY,
+    org_id                INTEGER     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name                  TEXT        NOT NULL,
+    token_prefix          TEXT        NOT NULL,
+    token_hash            TEXT        NOT NULL UNIQUE,
+    last_used_at          DATETIME,
+    revoked_at            DATETIME,
+    created_by_account_id INTEGER     REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at            DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scim_tokens_org
+    ON scim_tokens(org_id, revoked_at);
+
+-- Accounts known to an organization's SCIM client. A row outlives the org
+-- membership so a deactivated user can still be read and reactivated.
+CREATE TABLE scim_users (
+    org_id      INTEGER     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    account_id  INTEGER     NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    external_id TEXT,
+    created_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_id, account_id)
+);
+
+-- The identity provider's id for a team it manages.
+CREATE TABLE scim_groups (
+    team_id     INTEGER     NOT NULL PRIMARY KEY REFERENCES teams(id) ON DELETE CASCADE,
+    org_id      INTEGER     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    external_id TEXT,
+    created_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scim_groups_org
+    ON scim_groups(org_id);
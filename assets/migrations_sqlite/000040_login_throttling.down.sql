@@ -0,0 +1,4 @@
+ALTER TABLE instance_settings DROP COLUMN login_lockout_seconds;
+ALTER TABLE instance_settings DROP COLUMN login_ip_lockout_threshold;
+ALTER TABLE instance_settings DROP COLUMN login_lockout_threshold;
+DROP TABLE IF EXISTS login_failures;
@@ -0,0 +1,13 @@
+-- Authorization cache invalidations published by each replica. Replicas poll
+-- for rows above the highest id they have applied; rows older than the cache
+-- fallback TTL are pruned. Postgres replicas use LISTEN/NOTIFY instead, so
+-- this migration has no Postgres counterpart.
+CREATE TABLE authorization_invalidations (
+    id            INTEGER  PRIMARY KEY AUTOINCREMENT,
+    kind          TEXT     NOT NULL,
+    org_id        INTEGER  NOT NULL DEFAULT 0,
+    account_id    INTEGER  NOT NULL DEFAULT 0,
+    resource_type TEXT     NOT NULL DEFAULT '',
+    resource_id   INTEGER  NOT NULL DEFAULT 0,
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,41 @@
+-- Deployments run one revision of a shared workspace SQL file against an
+-- ordered list of environment connections. The SQL text is copied from the
+-- file revision when the deployment is created, so later edits and revision
+-- pruning do not change what runs. Targets run one at a time; a failed or
+-- cancelled target skips the rest.
+CREATE TABLE deployments (
+    id              INTEGER  PRIMARY KEY AUTOINCREMENT,
+    org_id          INTEGER  NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workspace_id    INTEGER  NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    file_id         INTEGER  REFERENCES workspace_files(id) ON DELETE SET NULL,
+    file_name       TEXT     NOT NULL,
+    content_id      INTEGER  NOT NULL,
+    content_version INTEGER  NOT NULL,
+    content_hash    TEXT     NOT NULL,
+    sql_text        TEXT     NOT NULL,
+    status          TEXT     NOT NULL CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+    created_by      INTEGER  NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at     DATETIME
+);
+
+CREATE INDEX deployments_workspace_idx ON deployments (workspace_id, created_at DESC);
+
+CREATE TABLE deployment_targets (
+    id              INTEGER  PRIMARY KEY AUTOINCREMENT,
+    deployment_id   INTEGER  NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    position        INTEGER  NOT NULL,
+    environment_id  INTEGER  NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    connection_id   INTEGER  REFERENCES connections(id) ON DELETE SET NULL,
+    connection_name TEXT     NOT NULL,
+    job_id          TEXT,
+    status          TEXT     NOT NULL CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'cancelled', 'skipped')),
+    error_code      TEXT,
+    error_message   TEXT,
+    started_at      DATETIME,
+    finished_at     DATETIME,
+    duration_ms     INTEGER,
+    UNIQUE (deployment_id, position)
+);
+
+CREATE INDEX deployment_targets_environment_idx ON deployment_targets (environment_id, id DESC);
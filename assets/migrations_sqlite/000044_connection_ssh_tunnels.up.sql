@@ -0,0 +1,7 @@
+-- Optional SSH jump host per connection. The host, user, key or password and
+-- pinned host key fingerprint are stored together, encrypted with the keyring;
+-- ssh_tunnel_enabled exposes whether one is set without decrypting it.
+ALTER TABLE connections
+    ADD COLUMN ssh_tunnel_encrypted TEXT NOT NULL DEFAULT '';
+ALTER TABLE connections
+    ADD COLUMN ssh_tunnel_enabled BOOLEAN NOT NULL DEFAULT FALSE;
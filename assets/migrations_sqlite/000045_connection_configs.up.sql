@@ -0,0 +1,6 @@
+-- Structured connection settings (host, port, user, password, TLS material,
+-- extra parameters), encrypted with the keyring. dsn_encrypted still holds the
+-- DSN assembled from them, so connecting does not depend on this column; an
+-- empty value means the connection only has a raw DSN.
+ALTER TABLE connections
+    ADD COLUMN config_encrypted TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE connection_user_credentials;
+ALTER TABLE connections DROP COLUMN credential_mode;
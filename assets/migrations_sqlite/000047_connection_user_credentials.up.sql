@@ -0,0 +1,22 @@
+-- "shared" connects everyone with the connection's own credentials;
+-- "per_user" connects each account with the credentials it stored in
+-- connection_user_credentials, merged into the structured config. The
+-- connection's own credentials remain in use for background work such as
+-- schema sync, migrations, and deployments.
+ALTER TABLE connections
+    ADD COLUMN credential_mode TEXT NOT NULL DEFAULT 'shared';
+
+-- An account's own credentials for a per_user connection: either a
+-- keyring-encrypted {"user", "password"} document or a secret reference.
+CREATE TABLE connection_user_credentials (
+    connection_id        INTEGER     NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    account_id           INTEGER     NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    credential_encrypted TEXT        NOT NULL DEFAULT '',
+    credential_ref       TEXT        NOT NULL DEFAULT '',
+    created_at           DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at           DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (connection_id, account_id)
+);
+
+CREATE INDEX idx_connection_user_credentials_account
+    ON connection_user_credentials(account_id);
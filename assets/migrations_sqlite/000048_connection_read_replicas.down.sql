@@ -0,0 +1 @@
+DROP TABLE connection_read_replicas;
@@ -0,0 +1,21 @@
+-- Connections opted into health monitoring are pinged on a schedule with
+-- their own credentials, like schema sync.
+ALTER TABLE connections
+    ADD COLUMN health_check_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- One row per scheduled check. status is "reachable" or "unreachable"; a
+-- failed check records the stage and error category a connection test would
+-- report.
+CREATE TABLE connection_health_checks (
+    id             INTEGER     PRIMARY KEY AUTOINCREMENT,
+    connection_id  INTEGER     NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    status         TEXT        NOT NULL,
+    latency_ms     INTEGER     NOT NULL DEFAULT 0,
+    stage          TEXT        NOT NULL DEFAULT '',
+    error_category TEXT        NOT NULL DEFAULT '',
+    error          TEXT        NOT NULL DEFAULT '',
+    checked_at     DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_connection_health_checks_connection
+    ON connection_health_checks(connection_id, checked_at);
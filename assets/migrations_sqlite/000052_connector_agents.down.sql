@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_connections_agent;
+ALTER TABLE connections DROP COLUMN agent_id;
+DROP TABLE IF EXISTS connector_agents;
@@ -0,0 +1,26 @@
+-- Live sessions and query cursors stay in the memory of the replica that
+-- opened them. Replicas register here and record where each session and
+-- cursor lives, so any replica can route a request to the owner.
+CREATE TABLE server_replicas (
+    id            TEXT        PRIMARY KEY,
+    advertise_url TEXT        NOT NULL,
+    draining      BOOLEAN     NOT NULL DEFAULT FALSE,
+    started_at    DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE live_session_routes (
+    id            TEXT        PRIMARY KEY,
+    kind          TEXT        NOT NULL,
+    session_id    TEXT        NOT NULL,
+    replica_id    TEXT        NOT NULL REFERENCES server_replicas(id) ON DELETE CASCADE,
+    account_id    INTEGER     NOT NULL,
+    connection_id INTEGER     NOT NULL,
+    created_at    DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_live_session_routes_session
+    ON live_session_routes(session_id);
+
+CREATE INDEX idx_live_session_routes_replica
+    ON live_session_routes(replica_id);
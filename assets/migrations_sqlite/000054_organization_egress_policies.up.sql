@@ -0,0 +1,10 @@
+-- Organization egress policies narrow the instance policy for the target
+-- databases connections in the organization may reach. The target lists are
+-- JSON arrays of host names, IP addresses, and CIDR ranges.
+CREATE TABLE organization_egress_policies (
+    org_id          INTEGER     PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    allowed_targets TEXT        NOT NULL,
+    denied_targets  TEXT        NOT NULL,
+    created_at      DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
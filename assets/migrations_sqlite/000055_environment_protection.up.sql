@@ -0,0 +1,4 @@
+-- Protection level drives query guardrails. Production environments allow DDL
+-- only during the weekly windows in ddl_windows, stored as JSON.
+ALTER TABLE environments ADD COLUMN protection_level TEXT NOT NULL DEFAULT 'development';
+ALTER TABLE environments ADD COLUMN ddl_windows TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE scim_users DROP COLUMN requested_active;
+ALTER TABLE scim_users DROP COLUMN confirmed_at;
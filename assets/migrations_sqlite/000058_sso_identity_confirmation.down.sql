@@ -0,0 +1,2 @@
+ALTER TABLE account_identities DROP COLUMN confirmed_at;
+ALTER TABLE account_identities DROP COLUMN org_id;
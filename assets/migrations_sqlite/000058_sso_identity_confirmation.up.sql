@@ -0,0 +1,13 @@
+-- An organization's identity provider that matches an existing account by
+-- email only proposes a link. Until the account holder confirms it, the
+-- identity cannot sign in; org_id records the organization whose provider
+-- asked. Existing links stay confirmed for accounts without a password, and
+-- for accounts that are not instance admins and belong to at most one
+-- organization.
+ALTER TABLE account_identities ADD COLUMN org_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE account_identities ADD COLUMN confirmed_at DATETIME;
+
+UPDATE account_identities SET confirmed_at = created_at
+WHERE EXISTS (SELECT 1 FROM accounts AS a WHERE a.id = account_identities.account_id AND a.password IS NULL)
+   OR (NOT EXISTS (SELECT 1 FROM instance_admins AS ia WHERE ia.account_id = account_identities.account_id)
+       AND (SELECT COUNT(*) FROM org_members AS om WHERE om.account_id = account_identities.account_id) <= 1);
@@ -0,0 +1,9 @@
+CREATE TABLE authorization_invalidations (
+    id            INTEGER  PRIMARY KEY AUTOINCREMENT,
+    kind          TEXT     NOT NULL,
+    org_id        INTEGER  NOT NULL DEFAULT 0,
+    account_id    INTEGER  NOT NULL DEFAULT 0,
+    resource_type TEXT     NOT NULL DEFAULT '',
+    resource_id   INTEGER  NOT NULL DEFAULT 0,
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
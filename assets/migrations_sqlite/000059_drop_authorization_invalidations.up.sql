@@ -0,0 +1,4 @@
+-- SQLite cannot be shared by several replicas, so authorization cache
+-- invalidations no longer leave the process. This migration has no Postgres
+-- counterpart.
+DROP TABLE IF EXISTS authorization_invalidations;
@@ -2,34 +2,52 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strings"
 	"syscall"
 
+	"github.com/spf13/pflag"
+	"github.com/sqlwarden/internal/agent"
 	"github.com/sqlwarden/internal/version"
 	"github.com/sqlwarden/internal/web"
+	"go.yaml.in/yaml/v3"
 )
 
 func main() {
 	err := run(os.Args[1:])
 	if err != nil {
 		trace := string(debug.Stack())
-		bootstrapLogger().Error(err.Error(), "trace", trace)
+		bootstrapLogger(os.Stderr).Error(err.Error(), "trace", trace)
 		os.Exit(1)
 	}
 }
 
-func bootstrapLogger() *slog.Logger {
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+func bootstrapLogger(out io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelInfo}))
 }
 
 func run(args []string) error {
 	if len(args) > 0 && args[0] == "rotate-keys" {
 		return runRotateKeys(args[1:])
 	}
+	if len(args) > 0 && args[0] == "verify-audit" {
+		return runVerifyAudit(args[1:])
+	}
+	if len(args) > 0 && args[0] == "policy-export" {
+		return runPolicyExport(args[1:])
+	}
+	if len(args) > 0 && (args[0] == "policy-plan" || args[0] == "policy-apply") {
+		return runPolicyImport(args[1:], args[0] == "policy-apply")
+	}
+	if len(args) > 0 && args[0] == "agent" {
+		return runAgent(args[1:])
+	}
 
 	cfg, showVersion, err := web.LoadConfig(args)
 	if err != nil {
@@ -96,3 +114,158 @@ func runRotateKeys(args []string) error {
 	)
 	return nil
 }
+
+// runVerifyAudit walks every tamper-evident audit chain and prints one line
+// per chain, naming the first broken link in each. It exits non-zero when any
+// chain fails verification, so it can gate compliance evidence collection. It
+// is the CLI equivalent of the instance-admin HTTP verify endpoint and, like
+// rotate-keys, runs at infrastructure trust level.
+func runVerifyAudit(args []string) error {
+	app, err := newCommandApp(args)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	report, err := app.VerifyAuditChains(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	for _, chain := range report.Chains {
+		if chain.FirstBreak == nil {
+			fmt.Printf("verified %s records=%d checkpoints=%d head_seq=%d\n", chain.Chain, chain.Records, chain.Checkpoints, chain.HeadSeq)
+			continue
+		}
+		fmt.Printf("broken %s records_verified=%d seq=%d record=%s reason=%s: %s\n",
+			chain.Chain, chain.Records, chain.FirstBreak.Seq, chain.FirstBreak.RecordID, chain.FirstBreak.Reason, chain.FirstBreak.Message)
+	}
+	if !report.Valid {
+		return errors.New("audit chain verification failed")
+	}
+	return nil
+}
+
+// runPolicyExport writes an organization's policy document as YAML to
+// stdout. Usage: policy-export <org-slug> [config flags].
+func runPolicyExport(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: policy-export <org-slug> [flags]")
+	}
+	orgSlug := args[0]
+
+	app, err := newCommandApp(args[1:])
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	doc, err := app.ExportOrgPolicy(context.Background(), orgSlug)
+	if err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// runPolicyImport plans, and with apply also applies, a policy document read
+// from a YAML or JSON file, printing one line per change. Usage:
+// policy-plan|policy-apply <org-slug> <file> [config flags].
+//
+// Like rotate-keys it runs at infrastructure trust level, so the
+// privileged-grant guard that limits who may grant org deletion or ownership
+// transfer does not apply. Builtin roles cannot be changed and the last
+// Owner binding cannot be removed.
+func runPolicyImport(args []string, apply bool) error {
+	if len(args) < 2 {
+		return errors.New("usage: policy-plan|policy-apply <org-slug> <file> [flags]")
+	}
+	orgSlug, path := args[0], args[1]
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	doc, err := web.ParsePolicyDocument(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	app, err := newCommandApp(args[2:])
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	var plan web.PolicyPlan
+	if apply {
+		plan, err = app.ApplyOrgPolicy(context.Background(), orgSlug, doc)
+	} else {
+		plan, err = app.PlanOrgPolicy(context.Background(), orgSlug, doc)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, change := range plan.Changes {
+		fmt.Printf("%s %s %s\n", change.Action, change.Kind, change.Target)
+	}
+	fmt.Printf("%d changes\n", len(plan.Changes))
+	return nil
+}
+
+// runAgent runs a connector agent inside a private network. It dials out to
+// the server and opens connections to databases there on the server's behalf,
+// so it needs no database or configuration file of its own. Flags fall back
+// to AGENT_SERVER_URL, AGENT_TOKEN and AGENT_ALLOWED_TARGETS.
+func runAgent(args []string) error {
+	flagSet := pflag.NewFlagSet("sqlwarden agent", pflag.ContinueOnError)
+	serverURL := flagSet.String("server-url", os.Getenv("AGENT_SERVER_URL"), "Base URL of the SQLWarden server")
+	agentToken := flagSet.String("token", os.Getenv("AGENT_TOKEN"), "Registration token issued when the agent was created")
+	var allowed []string
+	if env := os.Getenv("AGENT_ALLOWED_TARGETS"); env != "" {
+		allowed = strings.Split(env, ",")
+	}
+	allow := flagSet.StringSlice("allow", allowed, "Comma-separated host names, IP addresses and CIDR ranges the agent may connect to; empty allows any")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *serverURL == "" || *agentToken == "" {
+		return errors.New("usage: agent --server-url <url> --token <token> [--allow <targets>]")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger := bootstrapLogger(os.Stdout)
+	logger.Info("starting connector agent", "server", *serverURL, "version", version.Get(), "allowed_targets", *allow)
+	return agent.Run(ctx, agent.Config{
+		ServerURL:      *serverURL,
+		Token:          *agentToken,
+		AllowedTargets: *allow,
+		Version:        version.Get(),
+		Logger:         logger,
+	})
+}
+
+// newCommandApp builds the application for a subcommand whose stdout is its
+// output, so logs go to stderr.
+func newCommandApp(args []string) (*web.App, error) {
+	cfg, _, err := web.LoadConfig(args)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := web.NewLogger(cfg, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	return web.New(cfg, logger)
+}
@@ -55,6 +55,7 @@ type AuthorizationCache interface {
 
 // MemoryAuthorizationCache is an in-process RBAC cache with TTL-based expiry.
 type MemoryAuthorizationCache struct {
+	ttl         time.Duration
 	mu          sync.RWMutex
 	orgPolicies map[int64]*cachedOrgPolicy
 	principals  map[principalKey]*cachedPrincipals
@@ -90,7 +91,12 @@ type cachedAncestry struct {
 
 // NewMemoryAuthorizationCache returns an empty in-process RBAC cache.
 func NewMemoryAuthorizationCache() *MemoryAuthorizationCache {
+	return newMemoryAuthorizationCache(authorizationCacheTTL)
+}
+
+func newMemoryAuthorizationCache(ttl time.Duration) *MemoryAuthorizationCache {
 	return &MemoryAuthorizationCache{
+		ttl:         ttl,
 		orgPolicies: make(map[int64]*cachedOrgPolicy),
 		principals:  make(map[principalKey]*cachedPrincipals),
 		ancestries:  make(map[resourceKey]*cachedAncestry),
@@ -110,7 +116,7 @@ func (c *MemoryAuthorizationCache) GetOrgPolicy(orgID int64) (*OrgPolicy, bool)
 func (c *MemoryAuthorizationCache) SetOrgPolicy(orgID int64, policy *OrgPolicy) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.orgPolicies[orgID] = &cachedOrgPolicy{policy: policy, expiresAt: time.Now().Add(authorizationCacheTTL)}
+	c.orgPolicies[orgID] = &cachedOrgPolicy{policy: policy, expiresAt: time.Now().Add(c.ttl)}
 }
 
 func (c *MemoryAuthorizationCache) InvalidateOrgPolicy(orgID int64) {
@@ -132,7 +138,7 @@ func (c *MemoryAuthorizationCache) GetPrincipals(orgID, accountID int64) (Princi
 func (c *MemoryAuthorizationCache) SetPrincipals(orgID, accountID int64, principals Principals) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.principals[principalKey{orgID, accountID}] = &cachedPrincipals{principals: principals, expiresAt: time.Now().Add(authorizationCacheTTL)}
+	c.principals[principalKey{orgID, accountID}] = &cachedPrincipals{principals: principals, expiresAt: time.Now().Add(c.ttl)}
 }
 
 func (c *MemoryAuthorizationCache) InvalidatePrincipals(orgID, accountID int64) {
@@ -154,7 +160,7 @@ func (c *MemoryAuthorizationCache) GetAncestry(resourceType string, resourceID i
 func (c *MemoryAuthorizationCache) SetAncestry(resourceType string, resourceID int64, ancestry []AncestorLevel) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.ancestries[resourceKey{resourceType, resourceID}] = &cachedAncestry{levels: ancestry, expiresAt: time.Now().Add(authorizationCacheTTL)}
+	c.ancestries[resourceKey{resourceType, resourceID}] = &cachedAncestry{levels: ancestry, expiresAt: time.Now().Add(c.ttl)}
 }
 
 func (c *MemoryAuthorizationCache) InvalidateAncestry(resourceType string, resourceID int64) {
@@ -162,3 +168,12 @@ func (c *MemoryAuthorizationCache) InvalidateAncestry(resourceType string, resou
 	defer c.mu.Unlock()
 	delete(c.ancestries, resourceKey{resourceType, resourceID})
 }
+
+// Clear drops every cached entry.
+func (c *MemoryAuthorizationCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.orgPolicies = make(map[int64]*cachedOrgPolicy)
+	c.principals = make(map[principalKey]*cachedPrincipals)
+	c.ancestries = make(map[resourceKey]*cachedAncestry)
+}
@@ -19,7 +19,14 @@ type Enforcer struct {
 
 // New creates an Enforcer backed by the given database.
 func New(db *bun.DB) (*Enforcer, error) {
-	return &Enforcer{db: db, cache: NewMemoryAuthorizationCache()}, nil
+	return NewWithCache(db, NewMemoryAuthorizationCache())
+}
+
+// NewWithCache creates an Enforcer that caches policy, principals and ancestry
+// in cache. Use a DistributedAuthorizationCache when several replicas share
+// the database.
+func NewWithCache(db *bun.DB, cache AuthorizationCache) (*Enforcer, error) {
+	return &Enforcer{db: db, cache: cache}, nil
 }
 
 // Can returns true if accountID holds permission on the given resource within orgID.
@@ -451,23 +458,11 @@ func (e *Enforcer) SeedWorkspaceWithExecutor(ctx context.Context, exec bun.IDB,
 // CreateRole creates a custom (non-builtin) role. Pass workspaceID=nil for an org-level role or
 // a non-nil pointer for a workspace-scoped custom role. Returns the new role ID.
 func (e *Enforcer) CreateRole(ctx context.Context, orgID int64, workspaceID *int64, name, description, scopeType string, permissions []string) (int64, error) {
-	for _, p := range permissions {
-		if !ValidForScope(p, scopeType) {
-			return 0, fmt.Errorf("%w: permission %q is not valid for scope %q", ErrInvalidScopePermission, p, scopeType)
-		}
-	}
-
 	var roleID int64
 	err := e.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		var err error
-		roleID, err = e.insertRoleWithExecutor(ctx, tx, orgID, workspaceID, name, description, scopeType, false)
-		if err != nil {
-			return err
-		}
-		if err = e.insertRolePermissionsWithExecutor(ctx, tx, roleID, permissions, false); err != nil {
-			return fmt.Errorf("insert permissions: %w", err)
-		}
-		return nil
+		roleID, err = e.CreateRoleWithExecutor(ctx, tx, orgID, workspaceID, name, description, scopeType, permissions)
+		return err
 	})
 	if err != nil {
 		return 0, err
@@ -477,6 +472,25 @@ func (e *Enforcer) CreateRole(ctx context.Context, orgID int64, workspaceID *int
 	return roleID, nil
 }
 
+// CreateRoleWithExecutor creates a custom role using exec.
+// Callers that pass a transaction are responsible for cache invalidation after commit.
+func (e *Enforcer) CreateRoleWithExecutor(ctx context.Context, exec bun.IDB, orgID int64, workspaceID *int64, name, description, scopeType string, permissions []string) (int64, error) {
+	for _, p := range permissions {
+		if !ValidForScope(p, scopeType) {
+			return 0, fmt.Errorf("%w: permission %q is not valid for scope %q", ErrInvalidScopePermission, p, scopeType)
+		}
+	}
+
+	roleID, err := e.insertRoleWithExecutor(ctx, exec, orgID, workspaceID, name, description, scopeType, false)
+	if err != nil {
+		return 0, err
+	}
+	if err = e.insertRolePermissionsWithExecutor(ctx, exec, roleID, permissions, false); err != nil {
+		return 0, fmt.Errorf("insert permissions: %w", err)
+	}
+	return roleID, nil
+}
+
 type rolePermissionInsert struct {
 	bun.BaseModel `bun:"table:role_permissions"`
 	RoleID        int64  `bun:"role_id"`
@@ -508,55 +522,61 @@ func (e *Enforcer) insertRolePermissionsWithExecutor(ctx context.Context, exec b
 // changed here, so permissions are validated against the role's existing scope.
 func (e *Enforcer) UpdateRole(ctx context.Context, roleID, orgID int64, name, description string, permissions []string) error {
 	err := e.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		var role struct {
-			ScopeType string `bun:"scope_type"`
-			IsBuiltin bool   `bun:"is_builtin"`
-		}
-		err := tx.NewSelect().
-			TableExpr("roles").
-			ColumnExpr("scope_type, is_builtin").
-			Where("id = ? AND org_id = ?", roleID, orgID).
-			Scan(ctx, &role)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return ErrRoleNotFound
-			}
-			return err
-		}
-		if role.IsBuiltin {
-			return ErrBuiltinRole
-		}
+		return e.UpdateRoleWithExecutor(ctx, tx, roleID, orgID, name, description, permissions)
+	})
+	if err != nil {
+		return err
+	}
 
-		for _, p := range permissions {
-			if !ValidForScope(p, role.ScopeType) {
-				return fmt.Errorf("%w: permission %q is not valid for scope %q", ErrInvalidScopePermission, p, role.ScopeType)
-			}
-		}
+	e.cache.InvalidateOrgPolicy(orgID)
+	return nil
+}
 
-		_, err = tx.NewUpdate().
-			TableExpr("roles").
-			Set("name = ?", name).
-			Set("description = ?", description).
-			Set("updated_at = ?", time.Now()).
-			Where("id = ?", roleID).
-			Exec(ctx)
-		if err != nil {
-			return err
+// UpdateRoleWithExecutor updates a custom role using exec.
+// Callers that pass a transaction are responsible for cache invalidation after commit.
+func (e *Enforcer) UpdateRoleWithExecutor(ctx context.Context, exec bun.IDB, roleID, orgID int64, name, description string, permissions []string) error {
+	var role struct {
+		ScopeType string `bun:"scope_type"`
+		IsBuiltin bool   `bun:"is_builtin"`
+	}
+	err := exec.NewSelect().
+		TableExpr("roles").
+		ColumnExpr("scope_type, is_builtin").
+		Where("id = ? AND org_id = ?", roleID, orgID).
+		Scan(ctx, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRoleNotFound
 		}
+		return err
+	}
+	if role.IsBuiltin {
+		return ErrBuiltinRole
+	}
 
-		if _, err = tx.NewDelete().TableExpr("role_permissions").Where("role_id = ?", roleID).Exec(ctx); err != nil {
-			return fmt.Errorf("clear permissions: %w", err)
-		}
-		if err = e.insertRolePermissionsWithExecutor(ctx, tx, roleID, permissions, false); err != nil {
-			return fmt.Errorf("insert permissions: %w", err)
+	for _, p := range permissions {
+		if !ValidForScope(p, role.ScopeType) {
+			return fmt.Errorf("%w: permission %q is not valid for scope %q", ErrInvalidScopePermission, p, role.ScopeType)
 		}
-		return nil
-	})
+	}
+
+	_, err = exec.NewUpdate().
+		TableExpr("roles").
+		Set("name = ?", name).
+		Set("description = ?", description).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", roleID).
+		Exec(ctx)
 	if err != nil {
 		return err
 	}
 
-	e.cache.InvalidateOrgPolicy(orgID)
+	if _, err = exec.NewDelete().TableExpr("role_permissions").Where("role_id = ?", roleID).Exec(ctx); err != nil {
+		return fmt.Errorf("clear permissions: %w", err)
+	}
+	if err = e.insertRolePermissionsWithExecutor(ctx, exec, roleID, permissions, false); err != nil {
+		return fmt.Errorf("insert permissions: %w", err)
+	}
 	return nil
 }
 
@@ -564,37 +584,7 @@ func (e *Enforcer) UpdateRole(ctx context.Context, roleID, orgID int64, name, de
 // revoked first so deleting a role cannot silently revoke access assignments.
 func (e *Enforcer) DeleteRole(ctx context.Context, roleID, orgID int64) error {
 	err := e.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		var isBuiltin bool
-		err := tx.NewSelect().
-			TableExpr("roles").
-			ColumnExpr("is_builtin").
-			Where("id = ? AND org_id = ?", roleID, orgID).
-			Scan(ctx, &isBuiltin)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return ErrRoleNotFound
-			}
-			return err
-		}
-		if isBuiltin {
-			return ErrBuiltinRole
-		}
-
-		var bindingCount int
-		err = tx.NewSelect().
-			TableExpr("role_bindings").
-			ColumnExpr("COUNT(*)").
-			Where("role_id = ? AND org_id = ?", roleID, orgID).
-			Scan(ctx, &bindingCount)
-		if err != nil {
-			return err
-		}
-		if bindingCount > 0 {
-			return RoleInUseError{BindingCount: bindingCount}
-		}
-
-		_, err = tx.NewDelete().TableExpr("roles").Where("id = ?", roleID).Exec(ctx)
-		return err
+		return e.DeleteRoleWithExecutor(ctx, tx, roleID, orgID)
 	})
 	if err != nil {
 		return err
@@ -604,9 +594,45 @@ func (e *Enforcer) DeleteRole(ctx context.Context, roleID, orgID int64) error {
 	return nil
 }
 
+// DeleteRoleWithExecutor deletes an unbound custom role using exec.
+// Callers that pass a transaction are responsible for cache invalidation after commit.
+func (e *Enforcer) DeleteRoleWithExecutor(ctx context.Context, exec bun.IDB, roleID, orgID int64) error {
+	var isBuiltin bool
+	err := exec.NewSelect().
+		TableExpr("roles").
+		ColumnExpr("is_builtin").
+		Where("id = ? AND org_id = ?", roleID, orgID).
+		Scan(ctx, &isBuiltin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+	if isBuiltin {
+		return ErrBuiltinRole
+	}
+
+	var bindingCount int
+	err = exec.NewSelect().
+		TableExpr("role_bindings").
+		ColumnExpr("COUNT(*)").
+		Where("role_id = ? AND org_id = ?", roleID, orgID).
+		Scan(ctx, &bindingCount)
+	if err != nil {
+		return err
+	}
+	if bindingCount > 0 {
+		return RoleInUseError{BindingCount: bindingCount}
+	}
+
+	_, err = exec.NewDelete().TableExpr("roles").Where("id = ?", roleID).Exec(ctx)
+	return err
+}
+
 // BindRole assigns a role to a subject at a specific resource.
 func (e *Enforcer) BindRole(ctx context.Context, orgID, roleID int64, subjectType string, subjectID int64, resourceType string, resourceID int64, grantedBy int64) error {
-	err := e.bindRoleByID(ctx, orgID, roleID, subjectType, subjectID, resourceType, resourceID, grantedBy)
+	err := e.BindRoleWithExecutor(ctx, e.db, orgID, roleID, subjectType, subjectID, resourceType, resourceID, grantedBy)
 	if err != nil {
 		return err
 	}
@@ -614,9 +640,15 @@ func (e *Enforcer) BindRole(ctx context.Context, orgID, roleID int64, subjectTyp
 	return nil
 }
 
+// BindRoleWithExecutor assigns a role using exec.
+// Callers that pass a transaction are responsible for cache invalidation after commit.
+func (e *Enforcer) BindRoleWithExecutor(ctx context.Context, exec bun.IDB, orgID, roleID int64, subjectType string, subjectID int64, resourceType string, resourceID int64, grantedBy int64) error {
+	return e.bindRoleByIDWithExecutor(ctx, exec, orgID, roleID, subjectType, subjectID, resourceType, resourceID, grantedBy)
+}
+
 // UnbindRole removes a role binding by binding ID.
 func (e *Enforcer) UnbindRole(ctx context.Context, bindingID, orgID int64) error {
-	_, err := e.db.NewDelete().TableExpr("role_bindings").Where("id = ? AND org_id = ?", bindingID, orgID).Exec(ctx)
+	err := e.UnbindRoleWithExecutor(ctx, e.db, bindingID, orgID)
 	if err != nil {
 		return err
 	}
@@ -624,6 +656,13 @@ func (e *Enforcer) UnbindRole(ctx context.Context, bindingID, orgID int64) error
 	return nil
 }
 
+// UnbindRoleWithExecutor removes a role binding using exec.
+// Callers that pass a transaction are responsible for cache invalidation after commit.
+func (e *Enforcer) UnbindRoleWithExecutor(ctx context.Context, exec bun.IDB, bindingID, orgID int64) error {
+	_, err := exec.NewDelete().TableExpr("role_bindings").Where("id = ? AND org_id = ?", bindingID, orgID).Exec(ctx)
+	return err
+}
+
 // insertRole inserts a role row and returns its ID. workspaceID=nil creates an org-level role;
 // non-nil creates a workspace-scoped role. Idempotent: returns existing ID on conflict.
 func (e *Enforcer) insertRole(ctx context.Context, orgID int64, workspaceID *int64, name, description, scopeType string, isBuiltin bool) (int64, error) {
@@ -675,12 +714,13 @@ func (e *Enforcer) insertRoleWithExecutor(ctx context.Context, exec bun.IDB, org
 	return id, nil
 }
 
-// bindRoleByID inserts a role_bindings row.
-func (e *Enforcer) bindRoleByID(ctx context.Context, orgID, roleID int64, subjectType string, subjectID int64, resourceType string, resourceID int64, grantedBy int64) error {
-	return e.bindRoleByIDWithExecutor(ctx, e.db, orgID, roleID, subjectType, subjectID, resourceType, resourceID, grantedBy)
-}
-
+// bindRoleByIDWithExecutor inserts a role_bindings row. grantedBy=0 records no
+// grantor, for bindings made at infrastructure trust level.
 func (e *Enforcer) bindRoleByIDWithExecutor(ctx context.Context, exec bun.IDB, orgID, roleID int64, subjectType string, subjectID int64, resourceType string, resourceID int64, grantedBy int64) error {
+	var createdBy *int64
+	if grantedBy != 0 {
+		createdBy = &grantedBy
+	}
 	rbm := map[string]interface{}{
 		"org_id":        orgID,
 		"role_id":       roleID,
@@ -688,7 +728,7 @@ func (e *Enforcer) bindRoleByIDWithExecutor(ctx context.Context, exec bun.IDB, o
 		"subject_id":    subjectID,
 		"resource_type": resourceType,
 		"resource_id":   resourceID,
-		"created_by":    grantedBy,
+		"created_by":    createdBy,
 	}
 	_, err := exec.NewInsert().
 		TableExpr("role_bindings").
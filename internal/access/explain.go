@@ -0,0 +1,290 @@
+package access
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Reasons a role binding on the target's resource path does not grant the
+// explained permission.
+const (
+	ExplainReasonPrincipalNotMatched   = "principal_not_matched"
+	ExplainReasonResourceNotInPath     = "resource_not_in_path"
+	ExplainReasonRoleLacksPermission   = "role_lacks_permission"
+	ExplainReasonScopeNotApplicable    = "scope_not_applicable"
+	ExplainReasonResourceNotApplicable = "resource_not_applicable"
+)
+
+// ProposedBinding is a role binding that Explain evaluates without saving it.
+type ProposedBinding struct {
+	RoleID       int64
+	SubjectType  string
+	SubjectID    int64
+	ResourceType string
+	ResourceID   int64
+}
+
+// Subject is one principal an account matches.
+type Subject struct {
+	Type string
+	ID   int64
+}
+
+// BindingExplanation describes how one role binding was evaluated. Reason is
+// empty when the binding grants the permission.
+type BindingExplanation struct {
+	BindingID     int64
+	RoleID        int64
+	RoleName      string
+	RoleScopeType string
+	SubjectType   string
+	SubjectID     int64
+	ResourceType  string
+	ResourceID    int64
+	Reason        string
+}
+
+// Explanation lists every binding that grants an account a permission on a
+// resource, and every other binding on the resource path with the reason it
+// does not, including bindings for principals the account does not match.
+// Grants and Ignored are ordered from the resource up to the org.
+type Explanation struct {
+	Allowed    bool
+	Principals []Subject
+	Ancestry   []AncestorLevel
+	Grants     []BindingExplanation
+	Ignored    []BindingExplanation
+
+	// WhatIf is set when a proposed binding was evaluated.
+	WhatIf *WhatIfExplanation
+}
+
+// WhatIfExplanation is the outcome of adding a proposed binding.
+type WhatIfExplanation struct {
+	Allowed bool
+	Binding BindingExplanation
+}
+
+// Explain reports why accountID does or does not hold permission on the
+// target resource. A binding grants the permission when it matches one of the
+// account's principals, is bound on the resource or one of its ancestors, and
+// its role includes the permission for both the role's scope and the target
+// resource type, as in EffectivePermissions. When proposed is non-nil it is
+// evaluated as if it had been saved.
+func (e *Enforcer) Explain(ctx context.Context,
+	accountID, orgID int64,
+	resourceType string, resourceID int64,
+	permission string,
+	proposed *ProposedBinding,
+) (Explanation, error) {
+	principals, err := e.principalsFor(ctx, orgID, accountID)
+	if err != nil {
+		return Explanation{}, err
+	}
+	ancestors, err := e.ancestryFor(ctx, "org", resourceType, resourceID, orgID)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	bindings, err := e.explainBindings(ctx, orgID, ancestors)
+	if err != nil {
+		return Explanation{}, err
+	}
+	var proposedBinding BindingExplanation
+	roleIDs := make([]int64, 0, len(bindings)+1)
+	for _, b := range bindings {
+		roleIDs = append(roleIDs, b.RoleID)
+	}
+	if proposed != nil {
+		proposedBinding, err = e.explainProposedBinding(ctx, orgID, *proposed)
+		if err != nil {
+			return Explanation{}, err
+		}
+		roleIDs = append(roleIDs, proposed.RoleID)
+	}
+	rolePermissions, err := e.explainRolePermissions(ctx, roleIDs)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	explanation := Explanation{
+		Principals: principalSubjects(accountID, principals),
+		Ancestry:   ancestors,
+	}
+	for _, b := range bindings {
+		if !matchesPrincipal(b.SubjectType, b.SubjectID, accountID, principals) {
+			b.Reason = ExplainReasonPrincipalNotMatched
+		} else {
+			b.Reason = explainPermission(rolePermissions[b.RoleID], b.RoleScopeType, resourceType, permission)
+		}
+		if b.Reason == "" {
+			explanation.Grants = append(explanation.Grants, b)
+		} else {
+			explanation.Ignored = append(explanation.Ignored, b)
+		}
+	}
+	explanation.Allowed = len(explanation.Grants) > 0
+
+	if proposed != nil {
+		switch {
+		case !matchesPrincipal(proposedBinding.SubjectType, proposedBinding.SubjectID, accountID, principals):
+			proposedBinding.Reason = ExplainReasonPrincipalNotMatched
+		case !inAncestry(ancestors, proposedBinding.ResourceType, proposedBinding.ResourceID):
+			proposedBinding.Reason = ExplainReasonResourceNotInPath
+		default:
+			proposedBinding.Reason = explainPermission(rolePermissions[proposedBinding.RoleID], proposedBinding.RoleScopeType, resourceType, permission)
+		}
+		explanation.WhatIf = &WhatIfExplanation{
+			Allowed: explanation.Allowed || proposedBinding.Reason == "",
+			Binding: proposedBinding,
+		}
+	}
+	return explanation, nil
+}
+
+// explainPermission returns why a role with permissions does not grant
+// permission on targetResourceType, or "" when it does.
+func explainPermission(permissions map[string]bool, roleScopeType, targetResourceType, permission string) string {
+	switch {
+	case !permissions[permission]:
+		return ExplainReasonRoleLacksPermission
+	case !ValidForScope(permission, roleScopeType):
+		return ExplainReasonScopeNotApplicable
+	case !ValidForResource(permission, targetResourceType):
+		return ExplainReasonResourceNotApplicable
+	default:
+		return ""
+	}
+}
+
+func principalSubjects(accountID int64, principals Principals) []Subject {
+	subjects := []Subject{{Type: SubjectTypeAccount, ID: accountID}}
+	for _, teamID := range principals.TeamIDs {
+		subjects = append(subjects, Subject{Type: SubjectTypeTeam, ID: teamID})
+	}
+	if principals.OrgMember {
+		subjects = append(subjects, Subject{Type: SubjectTypeOrgMembers, ID: principals.OrgID})
+	}
+	for _, workspaceID := range principals.WorkspaceMemberIDs {
+		subjects = append(subjects, Subject{Type: SubjectTypeWorkspaceMembers, ID: workspaceID})
+	}
+	return subjects
+}
+
+func inAncestry(ancestors []AncestorLevel, resourceType string, resourceID int64) bool {
+	for _, level := range ancestors {
+		if level.ResourceType == resourceType && level.ResourceID == resourceID {
+			return true
+		}
+	}
+	return false
+}
+
+// explainBindings loads the bindings on every ancestor level, read from the
+// database rather than the policy cache so each can be identified.
+func (e *Enforcer) explainBindings(ctx context.Context, orgID int64, ancestors []AncestorLevel) ([]BindingExplanation, error) {
+	var rows []struct {
+		ID           int64
+		RoleID       int64
+		RoleName     string
+		ScopeType    string
+		SubjectType  string
+		SubjectID    int64
+		ResourceType string
+		ResourceID   int64
+	}
+	err := e.db.NewSelect().
+		TableExpr("role_bindings rb").
+		ColumnExpr("rb.id, rb.role_id, r.name AS role_name, r.scope_type, rb.subject_type, rb.subject_id, rb.resource_type, rb.resource_id").
+		Join("JOIN roles r ON r.id = rb.role_id").
+		Where("rb.org_id = ?", orgID).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, level := range ancestors {
+				q = q.WhereOr("rb.resource_type = ? AND rb.resource_id = ?", level.ResourceType, level.ResourceID)
+			}
+			return q
+		}).
+		OrderExpr("rb.id ASC").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("load role bindings: %w", err)
+	}
+
+	bindings := make([]BindingExplanation, 0, len(rows))
+	for _, level := range ancestors {
+		for _, row := range rows {
+			if row.ResourceType != level.ResourceType || row.ResourceID != level.ResourceID {
+				continue
+			}
+			bindings = append(bindings, BindingExplanation{
+				BindingID:     row.ID,
+				RoleID:        row.RoleID,
+				RoleName:      row.RoleName,
+				RoleScopeType: row.ScopeType,
+				SubjectType:   row.SubjectType,
+				SubjectID:     row.SubjectID,
+				ResourceType:  row.ResourceType,
+				ResourceID:    row.ResourceID,
+			})
+		}
+	}
+	return bindings, nil
+}
+
+func (e *Enforcer) explainProposedBinding(ctx context.Context, orgID int64, proposed ProposedBinding) (BindingExplanation, error) {
+	var role struct {
+		Name      string
+		ScopeType string
+	}
+	err := e.db.NewSelect().
+		TableExpr("roles").
+		ColumnExpr("name, scope_type").
+		Where("id = ? AND org_id = ?", proposed.RoleID, orgID).
+		Limit(1).
+		Scan(ctx, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BindingExplanation{}, ErrRoleNotFound
+		}
+		return BindingExplanation{}, err
+	}
+	return BindingExplanation{
+		RoleID:        proposed.RoleID,
+		RoleName:      role.Name,
+		RoleScopeType: role.ScopeType,
+		SubjectType:   proposed.SubjectType,
+		SubjectID:     proposed.SubjectID,
+		ResourceType:  proposed.ResourceType,
+		ResourceID:    proposed.ResourceID,
+	}, nil
+}
+
+func (e *Enforcer) explainRolePermissions(ctx context.Context, roleIDs []int64) (map[int64]map[string]bool, error) {
+	permissions := make(map[int64]map[string]bool)
+	if len(roleIDs) == 0 {
+		return permissions, nil
+	}
+	var rows []struct {
+		RoleID     int64
+		Permission string
+	}
+	err := e.db.NewSelect().
+		TableExpr("role_permissions").
+		ColumnExpr("role_id, permission").
+		Where("role_id IN (?)", bun.In(roleIDs)).
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("load role permissions: %w", err)
+	}
+	for _, row := range rows {
+		if permissions[row.RoleID] == nil {
+			permissions[row.RoleID] = make(map[string]bool)
+		}
+		permissions[row.RoleID][row.Permission] = true
+	}
+	return permissions, nil
+}
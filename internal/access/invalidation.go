@@ -0,0 +1,226 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// distributedCacheFallbackTTL bounds how long a replica may serve a cached
+// entry after an invalidation from another replica was lost.
+const distributedCacheFallbackTTL = 2 * time.Minute
+
+const (
+	invalidationChannel        = "sqlwarden_authorization_invalidations"
+	invalidationPublishTimeout = 5 * time.Second
+	invalidationReceiveTimeout = 30 * time.Second
+	invalidationRetryInterval  = time.Second
+	invalidationQueueSize      = 1024
+)
+
+// Invalidation kinds. Each names the AuthorizationCache entry it drops.
+const (
+	InvalidationOrgPolicy  = "org_policy"
+	InvalidationPrincipals = "principals"
+	InvalidationAncestry   = "ancestry"
+)
+
+// Invalidation identifies one cache entry dropped on every replica.
+type Invalidation struct {
+	Kind         string `json:"kind"`
+	OrgID        int64  `json:"org_id,omitempty"`
+	AccountID    int64  `json:"account_id,omitempty"`
+	ResourceType string `json:"resource_type,omitempty"`
+	ResourceID   int64  `json:"resource_id,omitempty"`
+}
+
+// InvalidationTransport carries invalidations between replicas sharing an
+// application database.
+type InvalidationTransport interface {
+	// Publish sends inv to every subscribed replica, including this one.
+	Publish(ctx context.Context, inv Invalidation) error
+	// Subscribe calls apply for each published invalidation until ctx is
+	// done. reset is called whenever invalidations may have been missed, so
+	// the subscriber can drop everything it has cached.
+	Subscribe(ctx context.Context, apply func(Invalidation), reset func()) error
+}
+
+// NewInvalidationTransport returns the LISTEN/NOTIFY transport on Postgres. It
+// returns nil on SQLite, which cannot be shared by several replicas, so a
+// process-local cache is all that is needed there.
+func NewInvalidationTransport(db *bun.DB, logger *slog.Logger) InvalidationTransport {
+	if db.Dialect().Name() != dialect.PG {
+		return nil
+	}
+	return &notifyTransport{db: db, logger: logger}
+}
+
+// DistributedAuthorizationCache is an in-process RBAC cache whose
+// invalidations are published to every replica. Entries expire after
+// distributedCacheFallbackTTL, which bounds staleness when a replica misses
+// an invalidation.
+type DistributedAuthorizationCache struct {
+	*MemoryAuthorizationCache
+	transport InvalidationTransport
+	logger    *slog.Logger
+	pending   chan Invalidation
+}
+
+// NewDistributedAuthorizationCache returns an empty cache that publishes
+// invalidations through transport. Run must be called to send invalidations
+// and to receive those published by other replicas.
+func NewDistributedAuthorizationCache(transport InvalidationTransport, logger *slog.Logger) *DistributedAuthorizationCache {
+	return &DistributedAuthorizationCache{
+		MemoryAuthorizationCache: newMemoryAuthorizationCache(distributedCacheFallbackTTL),
+		transport:                transport,
+		logger:                   logger,
+		pending:                  make(chan Invalidation, invalidationQueueSize),
+	}
+}
+
+// Run publishes queued invalidations and applies those published by any
+// replica until ctx is done or the subscription fails.
+func (c *DistributedAuthorizationCache) Run(ctx context.Context) error {
+	publishCtx, cancel := context.WithCancel(ctx)
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		c.publishPending(publishCtx)
+	}()
+	err := c.transport.Subscribe(ctx, c.apply, c.Clear)
+	cancel()
+	<-published
+	return err
+}
+
+func (c *DistributedAuthorizationCache) InvalidateOrgPolicy(orgID int64) {
+	c.MemoryAuthorizationCache.InvalidateOrgPolicy(orgID)
+	c.publish(Invalidation{Kind: InvalidationOrgPolicy, OrgID: orgID})
+}
+
+func (c *DistributedAuthorizationCache) InvalidatePrincipals(orgID, accountID int64) {
+	c.MemoryAuthorizationCache.InvalidatePrincipals(orgID, accountID)
+	c.publish(Invalidation{Kind: InvalidationPrincipals, OrgID: orgID, AccountID: accountID})
+}
+
+func (c *DistributedAuthorizationCache) InvalidateAncestry(resourceType string, resourceID int64) {
+	c.MemoryAuthorizationCache.InvalidateAncestry(resourceType, resourceID)
+	c.publish(Invalidation{Kind: InvalidationAncestry, ResourceType: resourceType, ResourceID: resourceID})
+}
+
+// publish queues inv for Run and never blocks the caller. Publishing is best
+// effort: if inv is dropped or fails to send, other replicas fall back to the
+// TTL.
+func (c *DistributedAuthorizationCache) publish(inv Invalidation) {
+	select {
+	case c.pending <- inv:
+	default:
+		c.logger.Warn("authorization cache invalidation dropped, queue full", "kind", inv.Kind)
+	}
+}
+
+func (c *DistributedAuthorizationCache) publishPending(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case inv := <-c.pending:
+			publishCtx, cancel := context.WithTimeout(ctx, invalidationPublishTimeout)
+			err := c.transport.Publish(publishCtx, inv)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				c.logger.Error("authorization cache invalidation publish failed", "kind", inv.Kind, "error", err)
+			}
+		}
+	}
+}
+
+func (c *DistributedAuthorizationCache) apply(inv Invalidation) {
+	switch inv.Kind {
+	case InvalidationOrgPolicy:
+		c.MemoryAuthorizationCache.InvalidateOrgPolicy(inv.OrgID)
+	case InvalidationPrincipals:
+		c.MemoryAuthorizationCache.InvalidatePrincipals(inv.OrgID, inv.AccountID)
+	case InvalidationAncestry:
+		c.MemoryAuthorizationCache.InvalidateAncestry(inv.ResourceType, inv.ResourceID)
+	default:
+		// Unknown kinds come from newer replicas; drop everything to be safe.
+		c.Clear()
+	}
+}
+
+// notifyTransport publishes with pg_notify and receives on a dedicated
+// LISTEN connection.
+type notifyTransport struct {
+	db     *bun.DB
+	logger *slog.Logger
+}
+
+func (t *notifyTransport) Publish(ctx context.Context, inv Invalidation) error {
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	_, err = t.db.ExecContext(ctx, "SELECT pg_notify(?, ?)", invalidationChannel, string(payload))
+	return err
+}
+
+func (t *notifyTransport) Subscribe(ctx context.Context, apply func(Invalidation), reset func()) error {
+	ln := pgdriver.NewListener(t.db)
+	if err := ln.Listen(ctx, invalidationChannel); err != nil {
+		_ = ln.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer func() {
+		if stop() {
+			_ = ln.Close()
+		}
+	}()
+	// Entries cached before LISTEN took effect may have missed invalidations.
+	reset()
+
+	disconnected := false
+	for {
+		_, payload, err := ln.ReceiveTimeout(ctx, invalidationReceiveTimeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var netErr net.Error
+		if err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			// The listener reconnects and listens again on its own, but
+			// notifications sent while it was disconnected are lost.
+			if !disconnected {
+				t.logger.Warn("authorization cache invalidation listener disconnected", "error", err)
+			}
+			disconnected = true
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(invalidationRetryInterval):
+			}
+			continue
+		}
+		if disconnected {
+			t.logger.Info("authorization cache invalidation listener reconnected")
+			disconnected = false
+			reset()
+		}
+		if err != nil {
+			continue
+		}
+		var inv Invalidation
+		if err := json.Unmarshal([]byte(payload), &inv); err != nil {
+			t.logger.Warn("authorization cache invalidation payload invalid", "error", err)
+			continue
+		}
+		apply(inv)
+	}
+}
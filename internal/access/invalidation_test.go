@@ -0,0 +1,141 @@
+package access_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/database"
+)
+
+// subscribeCache returns a replica cache applying invalidations from db until
+// the test ends.
+func subscribeCache(t *testing.T, db *database.DB) *access.DistributedAuthorizationCache {
+	t.Helper()
+	cache := access.NewDistributedAuthorizationCache(access.NewInvalidationTransport(db.DB, nilLogger()), nilLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cache.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return cache
+}
+
+func waitForInvalidation(t *testing.T, cached func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for cached() {
+		if time.Now().After(deadline) {
+			t.Fatal("invalidation did not reach the other replica")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func testInvalidationAcrossReplicas(t *testing.T, db *database.DB) {
+	t.Helper()
+	publisher := subscribeCache(t, db)
+	replica := subscribeCache(t, db)
+	// Let the subscription start so the entries below are not cleared by
+	// its initial reset.
+	time.Sleep(500 * time.Millisecond)
+
+	replica.SetOrgPolicy(1, &access.OrgPolicy{})
+	replica.SetPrincipals(1, 2, access.Principals{OrgID: 1, OrgMember: true})
+	replica.SetAncestry("connection", 3, []access.AncestorLevel{{ResourceType: "workspace", ResourceID: 4}})
+	replica.SetPrincipals(1, 5, access.Principals{OrgID: 1, OrgMember: true})
+
+	publisher.InvalidateOrgPolicy(1)
+	waitForInvalidation(t, func() bool { _, ok := replica.GetOrgPolicy(1); return ok })
+	publisher.InvalidatePrincipals(1, 2)
+	waitForInvalidation(t, func() bool { _, ok := replica.GetPrincipals(1, 2); return ok })
+	publisher.InvalidateAncestry("connection", 3)
+	waitForInvalidation(t, func() bool { _, ok := replica.GetAncestry("connection", 3); return ok })
+
+	if _, ok := replica.GetPrincipals(1, 5); !ok {
+		t.Fatal("unrelated principals must stay cached")
+	}
+}
+
+func TestInvalidationAcrossReplicasPostgres(t *testing.T) {
+	db := newTestDB(t)
+	testInvalidationAcrossReplicas(t, db)
+}
+
+func TestNoInvalidationTransportOnSQLite(t *testing.T) {
+	db, err := database.New("sqlite", filepath.Join(t.TempDir(), "sqlwarden.db"), nilLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if transport := access.NewInvalidationTransport(db.DB, nilLogger()); transport != nil {
+		t.Fatalf("transport = %T, want nil", transport)
+	}
+}
+
+// stalledTransport blocks every Publish until release is closed, then fails.
+type stalledTransport struct {
+	release   chan struct{}
+	published chan access.Invalidation
+}
+
+func (t *stalledTransport) Publish(ctx context.Context, inv access.Invalidation) error {
+	select {
+	case <-t.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	t.published <- inv
+	return errors.New("notify failed")
+}
+
+func (t *stalledTransport) Subscribe(ctx context.Context, apply func(access.Invalidation), reset func()) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestInvalidationPublishingDoesNotBlockTheCaller(t *testing.T) {
+	transport := &stalledTransport{release: make(chan struct{}), published: make(chan access.Invalidation, 1)}
+	cache := access.NewDistributedAuthorizationCache(transport, nilLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cache.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	cache.SetOrgPolicy(1, &access.OrgPolicy{})
+	invalidated := make(chan struct{})
+	go func() {
+		cache.InvalidateOrgPolicy(1)
+		close(invalidated)
+	}()
+	select {
+	case <-invalidated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("InvalidateOrgPolicy waited for the transport")
+	}
+	if _, ok := cache.GetOrgPolicy(1); ok {
+		t.Fatal("invalidation must apply locally before it is published")
+	}
+
+	close(transport.release)
+	select {
+	case inv := <-transport.published:
+		if inv.Kind != access.InvalidationOrgPolicy || inv.OrgID != 1 {
+			t.Fatalf("published %+v", inv)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued invalidation was not published")
+	}
+}
@@ -0,0 +1,288 @@
+package agent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// dialTimeout bounds how long the agent spends reaching a target database.
+const dialTimeout = 10 * time.Second
+
+// Reconnect backoff bounds. The delay doubles after each failed attempt and
+// resets once a link comes up.
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ErrUnauthorized reports that the server rejected the agent's token. The
+// agent stops instead of retrying with a token that will not start working.
+var ErrUnauthorized = errors.New("server rejected the agent token")
+
+// Config configures an agent.
+type Config struct {
+	// ServerURL is the sqlwarden server's base URL, for example
+	// https://warden.example.com.
+	ServerURL string
+	// Token is the agent's registration token.
+	Token string
+	// AllowedTargets limits the databases the server may reach through the
+	// agent to these host names, IP addresses and CIDR ranges. Host names
+	// only match targets given by that name; IP and CIDR entries also match
+	// host names that resolve into them. Empty allows every target.
+	AllowedTargets []string
+	// Version is reported to the server on every heartbeat.
+	Version string
+	// TLSConfig, when set, is used for https server URLs.
+	TLSConfig *tls.Config
+	Logger    *slog.Logger
+}
+
+// targetPolicy decides which targets an agent dials.
+type targetPolicy struct {
+	hosts    map[string]bool
+	prefixes []netip.Prefix
+}
+
+func newTargetPolicy(entries []string) (targetPolicy, error) {
+	policy := targetPolicy{hosts: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return targetPolicy{}, fmt.Errorf("allowed target %q: %w", entry, err)
+			}
+			policy.prefixes = append(policy.prefixes, prefix.Masked())
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				policy.prefixes = append(policy.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			policy.hosts[strings.ToLower(entry)] = true
+		}
+	}
+	return policy, nil
+}
+
+func (p targetPolicy) open() bool {
+	return len(p.hosts) == 0 && len(p.prefixes) == 0
+}
+
+func (p targetPolicy) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the address to dial for host and port, or false when the
+// policy does not allow the target. A host name matched through a CIDR entry
+// is dialed by the address that matched, so a later DNS answer cannot steer
+// the connection elsewhere.
+func (p targetPolicy) resolve(ctx context.Context, host string, port int) (string, bool) {
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	if p.open() || p.hosts[strings.ToLower(host)] {
+		return target, true
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return target, p.contains(addr)
+	}
+	if len(p.prefixes) == 0 {
+		return "", false
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return "", false
+	}
+	for _, addr := range addrs {
+		if p.contains(addr) {
+			return net.JoinHostPort(addr.Unmap().String(), strconv.Itoa(port)), true
+		}
+	}
+	return "", false
+}
+
+// Run keeps the agent connected to the server until ctx ends, reconnecting
+// with backoff when the link drops. It returns ErrUnauthorized if the server
+// rejects the token.
+func Run(ctx context.Context, cfg Config) error {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	policy, err := newTargetPolicy(cfg.AllowedTargets)
+	if err != nil {
+		return err
+	}
+	linkURL, err := connectURL(cfg.ServerURL)
+	if err != nil {
+		return err
+	}
+	// The host key only has to outlive a link: the server learns it from
+	// the authenticated upgrade request each time.
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		return err
+	}
+
+	a := &runner{cfg: cfg, logger: logger, policy: policy, url: linkURL, signer: signer}
+	delay := minReconnectDelay
+	for {
+		connected, err := a.connect(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var handshakeErr *HandshakeError
+		if errors.As(err, &handshakeErr) && handshakeErr.StatusCode == http.StatusUnauthorized {
+			return ErrUnauthorized
+		}
+		if connected {
+			delay = minReconnectDelay
+		}
+		logger.Warn("agent link lost", "error", err, "retry_in_ms", delay.Milliseconds())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		if !connected {
+			delay = min(delay*2, maxReconnectDelay)
+		}
+	}
+}
+
+// connectURL turns the server's base URL into the agent link's URL.
+func connectURL(serverURL string) (string, error) {
+	serverURL = strings.TrimRight(strings.TrimSpace(serverURL), "/")
+	switch {
+	case strings.HasPrefix(serverURL, "https://"), strings.HasPrefix(serverURL, "http://"),
+		strings.HasPrefix(serverURL, "wss://"), strings.HasPrefix(serverURL, "ws://"):
+		return serverURL + ConnectPath, nil
+	default:
+		return "", fmt.Errorf("server URL %q must start with https:// or http://", serverURL)
+	}
+}
+
+type runner struct {
+	cfg    Config
+	logger *slog.Logger
+	policy targetPolicy
+	url    string
+	signer ssh.Signer
+}
+
+// connect runs one link until it drops. connected reports whether the link
+// came up at all.
+func (a *runner) connect(ctx context.Context) (connected bool, err error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+a.cfg.Token)
+	header.Set(HeaderHostKey, ssh.FingerprintSHA256(a.signer.PublicKey()))
+	header.Set(HeaderVersion, a.cfg.Version)
+
+	dialCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	conn, err := Dial(dialCtx, a.url, header, a.cfg.TLSConfig)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(a.signer)
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return false, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	a.logger.Info("agent connected", "server", a.cfg.ServerURL)
+
+	stop := context.AfterFunc(ctx, func() { serverConn.Close() })
+	defer stop()
+	go a.answerRequests(reqs)
+	for newChannel := range chans {
+		go a.forward(ctx, newChannel)
+	}
+	return true, serverConn.Wait()
+}
+
+func (a *runner) answerRequests(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type != heartbeatRequest {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		payload, _ := json.Marshal(heartbeat{Version: a.cfg.Version})
+		_ = req.Reply(true, payload)
+	}
+}
+
+// forward connects a direct-tcpip channel opened by the server to its target.
+func (a *runner) forward(ctx context.Context, newChannel ssh.NewChannel) {
+	if newChannel.ChannelType() != "direct-tcpip" {
+		_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		return
+	}
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		_ = newChannel.Reject(ssh.Prohibited, "malformed target")
+		return
+	}
+	addr, ok := a.policy.resolve(ctx, target.Host, int(target.Port))
+	if !ok {
+		a.logger.Warn("agent refused target", "host", target.Host, "port", target.Port)
+		_ = newChannel.Reject(ssh.Prohibited, "target is not allowed by this agent")
+		return
+	}
+	dialer := net.Dialer{Timeout: dialTimeout}
+	upstream, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		a.logger.Warn("agent could not reach target", "host", target.Host, "port", target.Port, "error", err)
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	a.logger.Debug("agent stream opened", "host", target.Host, "port", target.Port)
+
+	go func() {
+		_, _ = io.Copy(upstream, channel)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(channel, upstream)
+	channel.Close()
+}
@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebSocketRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, http.Header{"X-Test": {"yes"}})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain GET: status %d, want 400", res.StatusCode)
+	}
+
+	conn, err := Dial(context.Background(), "ws"+server.URL[len("http"):], nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The largest payload exceeds the default WebSocket message read limit.
+	for _, size := range []int{5, 300, 70000} {
+		payload := bytes.Repeat([]byte{byte(size)}, size)
+		if _, err := conn.Write(payload); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, size)
+		if _, err := io.ReadFull(conn, got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("echo of %d bytes came back different", size)
+		}
+	}
+}
+
+func TestTargetPolicy(t *testing.T) {
+	policy, err := newTargetPolicy([]string{"DB.internal", "10.0.0.0/8", "192.168.1.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"db.internal", true},
+		{"10.20.30.40", true},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"other.internal.invalid", false},
+	}
+	for _, tt := range tests {
+		if _, ok := policy.resolve(context.Background(), tt.host, 5432); ok != tt.want {
+			t.Errorf("resolve(%q) allowed=%v, want %v", tt.host, ok, tt.want)
+		}
+	}
+
+	if _, err := newTargetPolicy([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected an invalid CIDR to be rejected")
+	}
+	open, _ := newTargetPolicy(nil)
+	if addr, ok := open.resolve(context.Background(), "anything.example", 3306); !ok || addr != "anything.example:3306" {
+		t.Errorf("open policy resolved %q, %v", addr, ok)
+	}
+}
+
+// testServer accepts agent links for agent 7 authenticated with "secret".
+func testServer(t *testing.T, hub *Hub, versions chan<- string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = hub.Serve(r.Context(), 7, conn, r.Header.Get(HeaderHostKey), func(version string) {
+			select {
+			case versions <- version:
+			default:
+			}
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestHubDialsThroughAgent(t *testing.T) {
+	hub := NewHub(time.Hour)
+	defer hub.Close()
+	versions := make(chan string, 1)
+	server := testServer(t, hub, versions)
+	echo := startEchoServer(t)
+
+	if _, err := hub.Dialer(7)(context.Background(), "tcp", echo); !errors.Is(err, ErrAgentOffline) {
+		t.Fatalf("dial before the agent connected: %v, want ErrAgentOffline", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = Run(ctx, Config{ServerURL: server.URL, Token: "secret", Version: "1.2.3", AllowedTargets: []string{"127.0.0.1"}})
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	select {
+	case version := <-versions:
+		if version != "1.2.3" {
+			t.Fatalf("heartbeat version %q, want 1.2.3", version)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not connect")
+	}
+	if !hub.Connected(7) {
+		t.Fatal("expected the agent to be connected")
+	}
+
+	// Two streams share the link.
+	dial := hub.Dialer(7)
+	var conns []net.Conn
+	for range 2 {
+		conn, err := dial(context.Background(), "tcp", echo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		msg := []byte{'p', 'i', 'n', 'g', byte('0' + i)}
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(msg))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("stream %d echoed %q", i, got)
+		}
+	}
+
+	// The agent only reaches the targets it allows.
+	_, port, _ := net.SplitHostPort(echo)
+	if _, err := dial(context.Background(), "tcp", net.JoinHostPort("127.0.0.2", port)); err == nil {
+		t.Fatal("expected the agent to refuse a target outside its allow list")
+	}
+
+	hub.Disconnect(7)
+	if hub.Connected(7) {
+		t.Fatal("expected the agent to be disconnected")
+	}
+	if _, err := conns[0].Write([]byte("x")); err == nil {
+		if _, err := conns[0].Read(make([]byte, 1)); err == nil {
+			t.Fatal("expected streams to close with the link")
+		}
+	}
+}
+
+func TestRunStopsOnUnauthorized(t *testing.T) {
+	hub := NewHub(time.Hour)
+	defer hub.Close()
+	server := testServer(t, hub, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := Run(ctx, Config{ServerURL: server.URL, Token: "wrong"})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Run returned %v, want ErrUnauthorized", err)
+	}
+}
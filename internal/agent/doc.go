@@ -0,0 +1,11 @@
+// Package agent connects sqlwarden to databases it cannot reach directly.
+//
+// A connector agent runs inside the private network and dials out to the
+// server over an authenticated WebSocket, so no inbound port has to be opened.
+// The server then runs an SSH client over that single connection and the
+// agent acts as the SSH server: every database connection the server opens
+// through the agent is a "direct-tcpip" channel multiplexed on the link, and
+// heartbeats are SSH global requests.
+//
+// Hub is the server side; Run is the agent side.
+package agent
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+// Headers an agent sends with its upgrade request.
+const (
+	// HeaderHostKey carries the SHA256 fingerprint of the agent's SSH host
+	// key, which the server pins for the link.
+	HeaderHostKey = "X-Warden-Agent-Host-Key"
+	HeaderVersion = "X-Warden-Agent-Version"
+)
+
+// ConnectPath is where agents open their link on the server.
+const ConnectPath = "/agent/v1/connect"
+
+// heartbeatRequest is the SSH global request the server sends to check that
+// an agent is alive. The agent replies with a heartbeat.
+const heartbeatRequest = "heartbeat@sqlwarden"
+
+// DefaultHeartbeatInterval is how often the server checks on each agent.
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// handshakeTimeout bounds the SSH handshake on a new link.
+const handshakeTimeout = 15 * time.Second
+
+// heartbeatTimeout is how long an agent has to answer a heartbeat before its
+// link is dropped.
+const heartbeatTimeout = 10 * time.Second
+
+var (
+	ErrAgentOffline    = errors.New("connector agent is not connected")
+	ErrHostKeyMismatch = errors.New("agent host key does not match its fingerprint")
+)
+
+// heartbeat is an agent's reply to heartbeatRequest.
+type heartbeat struct {
+	Version string `json:"version"`
+}
+
+// Hub holds the links of the agents connected to this server process. Links
+// live in memory, so a connection bound to an agent can only be opened by the
+// process the agent is connected to.
+type Hub struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	links  map[int64]*link
+	closed bool
+}
+
+type link struct {
+	client *ssh.Client
+}
+
+// NewHub returns a hub that sends each agent a heartbeat every interval.
+func NewHub(interval time.Duration) *Hub {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	return &Hub{interval: interval, links: make(map[int64]*link)}
+}
+
+// Serve runs the link for agentID over conn until the agent disconnects, a
+// heartbeat goes unanswered, ctx ends, or the link is replaced or dropped.
+// hostKeyFingerprint pins the agent's SSH host key. onHeartbeat is called
+// with the version the agent reports, once when the link is up and then on
+// every heartbeat. A new link for the same agent replaces the old one.
+func (h *Hub) Serve(ctx context.Context, agentID int64, conn net.Conn, hostKeyFingerprint string, onHeartbeat func(version string)) error {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, "agent", &ssh.ClientConfig{
+		User: "sqlwarden",
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != hostKeyFingerprint {
+				return ErrHostKeyMismatch
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("agent handshake: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	l := &link{client: ssh.NewClient(clientConn, chans, reqs)}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		l.client.Close()
+		return net.ErrClosed
+	}
+	previous := h.links[agentID]
+	h.links[agentID] = l
+	h.mu.Unlock()
+	if previous != nil {
+		previous.client.Close()
+	}
+	defer func() {
+		h.mu.Lock()
+		if h.links[agentID] == l {
+			delete(h.links, agentID)
+		}
+		h.mu.Unlock()
+		l.client.Close()
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- l.client.Wait() }()
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		status, err := l.heartbeat(min(h.interval, heartbeatTimeout))
+		if err != nil {
+			return err
+		}
+		if onHeartbeat != nil {
+			onHeartbeat(status.Version)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// heartbeat asks the agent for its status, giving up after timeout.
+func (l *link) heartbeat(timeout time.Duration) (heartbeat, error) {
+	type reply struct {
+		ok      bool
+		payload []byte
+		err     error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		ok, payload, err := l.client.SendRequest(heartbeatRequest, true, nil)
+		replies <- reply{ok, payload, err}
+	}()
+	select {
+	case r := <-replies:
+		if r.err != nil {
+			return heartbeat{}, r.err
+		}
+		var status heartbeat
+		if !r.ok || json.Unmarshal(r.payload, &status) != nil {
+			return heartbeat{}, errors.New("agent sent an invalid heartbeat")
+		}
+		return status, nil
+	case <-time.After(timeout):
+		return heartbeat{}, errors.New("agent heartbeat timed out")
+	}
+}
+
+// Connected reports whether agentID has a link to this process.
+func (h *Hub) Connected(agentID int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.links[agentID] != nil
+}
+
+// Dialer returns the dial function for engine.ConnectionConfig.Dial that
+// opens connections from inside agentID's network. The link is looked up on
+// every dial, so the function keeps working across agent reconnects and
+// fails with ErrAgentOffline while the agent is away.
+func (h *Hub) Dialer(agentID int64) engine.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		h.mu.Lock()
+		l := h.links[agentID]
+		h.mu.Unlock()
+		if l == nil {
+			return nil, ErrAgentOffline
+		}
+		conn, err := l.client.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("connector agent: %w", err)
+		}
+		return conn, nil
+	}
+}
+
+// Disconnect drops agentID's link along with every connection through it.
+func (h *Hub) Disconnect(agentID int64) {
+	h.mu.Lock()
+	l := h.links[agentID]
+	delete(h.links, agentID)
+	h.mu.Unlock()
+	if l != nil {
+		l.client.Close()
+	}
+}
+
+// Close drops every link and refuses new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	links := h.links
+	h.links = make(map[int64]*link)
+	h.closed = true
+	h.mu.Unlock()
+	for _, l := range links {
+		l.client.Close()
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/coder/websocket"
+)
+
+var ErrNotWebSocket = errors.New("request is not a websocket upgrade")
+
+// HandshakeError reports a server that refused the WebSocket upgrade.
+type HandshakeError struct {
+	StatusCode int
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("websocket handshake: server responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade completes the server side of a WebSocket handshake and returns the
+// link as a byte stream carried in binary messages. header is added to the
+// 101 response. It returns ErrNotWebSocket, having written nothing, when r is
+// not an upgrade request. The stream lives until it is closed or r's context
+// ends, so the handler must not return while the link is in use.
+func Upgrade(w http.ResponseWriter, r *http.Request, header http.Header) (net.Conn, error) {
+	if r.Method != http.MethodGet || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, ErrNotWebSocket
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return websocket.NetConn(r.Context(), c, websocket.MessageBinary), nil
+}
+
+// Dial opens a WebSocket to rawURL, which may use the ws, wss, http or https
+// scheme, and returns it as a byte stream carried in binary messages.
+// tlsConfig, when set, is used for wss and https. ctx bounds the handshake
+// only. A server that does not switch protocols yields a *HandshakeError.
+func Dial(ctx context.Context, rawURL string, header http.Header, tlsConfig *tls.Config) (net.Conn, error) {
+	opts := &websocket.DialOptions{HTTPHeader: header}
+	if tlsConfig != nil {
+		opts.HTTPClient = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}}
+	}
+	c, res, err := websocket.Dial(ctx, rawURL, opts)
+	if err != nil {
+		if res != nil && res.StatusCode != http.StatusSwitchingProtocols {
+			return nil, &HandshakeError{StatusCode: res.StatusCode}
+		}
+		return nil, err
+	}
+	return websocket.NetConn(context.WithoutCancel(ctx), c, websocket.MessageBinary), nil
+}
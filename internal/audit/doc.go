@@ -0,0 +1,10 @@
+// Package audit records tamper-evident audit history. Records are hash-chained
+// per organization (plus one instance chain): each record carries the hash of
+// its predecessor and is HMAC-signed with a key from the encryption keyring.
+// Periodic signed checkpoints pin chain heads so truncation is detectable, and
+// Verify walks a chain and reports the first broken link.
+//
+// Records can also be forwarded to external sinks such as a SIEM. Forwarding
+// goes through a durable outbox written in the record's transaction, so request
+// handling never waits on a sink.
+package audit
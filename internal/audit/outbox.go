@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/uptrace/bun"
+)
+
+// OutboxEntry is one record awaiting delivery to one external sink. Entries
+// are written with the record and deleted once the sink acknowledges them, so
+// a sink outage delays forwarding rather than losing committed records.
+type OutboxEntry struct {
+	bun.BaseModel `bun:"table:audit_outbox"`
+	ID            string    `bun:",pk" json:"id"`
+	SinkID        string    `bun:",notnull" json:"sink_id"`
+	RecordID      string    `bun:",notnull" json:"record_id"`
+	Attempts      int       `bun:",notnull" json:"attempts"`
+	NextAttemptAt time.Time `bun:",notnull" json:"next_attempt_at"`
+	LastError     string    `bun:",nullzero" json:"last_error,omitempty"`
+	CreatedAt     time.Time `bun:",notnull" json:"created_at"`
+}
+
+// Delivery pairs a due outbox entry with the record it forwards.
+type Delivery struct {
+	Entry  OutboxEntry
+	Record Record
+}
+
+func enqueueOutbox(ctx context.Context, tx bun.Tx, sinkIDs []string, record Record) error {
+	if len(sinkIDs) == 0 {
+		return nil
+	}
+	entries := make([]OutboxEntry, 0, len(sinkIDs))
+	for _, sinkID := range sinkIDs {
+		entries = append(entries, OutboxEntry{
+			ID:            database.NewID(),
+			SinkID:        sinkID,
+			RecordID:      record.ID,
+			NextAttemptAt: record.OccurredAt,
+			CreatedAt:     record.OccurredAt,
+		})
+	}
+	_, err := tx.NewInsert().Model(&entries).Exec(ctx)
+	return err
+}
+
+// DueSinks lists the sinks that have at least one entry due at now.
+func (s *Store) DueSinks(ctx context.Context, now time.Time) ([]string, error) {
+	var sinkIDs []string
+	err := s.db.NewSelect().Model((*OutboxEntry)(nil)).
+		Distinct().
+		Column("sink_id").
+		Where("next_attempt_at <= ?", now.UTC()).
+		OrderExpr("sink_id ASC").
+		Scan(ctx, &sinkIDs)
+	return sinkIDs, err
+}
+
+// DueDeliveries returns up to limit entries for sinkID that are due at now,
+// oldest first, together with their records. Entry IDs are ULIDs, so ID order
+// is append order.
+func (s *Store) DueDeliveries(ctx context.Context, sinkID string, now time.Time, limit int) ([]Delivery, error) {
+	var entries []OutboxEntry
+	if err := s.db.NewSelect().Model(&entries).
+		Where("sink_id = ? AND next_attempt_at <= ?", sinkID, now.UTC()).
+		OrderExpr("id ASC").
+		Limit(limit).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	recordIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		recordIDs = append(recordIDs, entry.RecordID)
+	}
+	var records []Record
+	if err := s.db.NewSelect().Model(&records).
+		Where("id IN (?)", bun.List(recordIDs)).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]Record, len(records))
+	for _, record := range records {
+		populateDetails(&record)
+		byID[record.ID] = record
+	}
+	deliveries := make([]Delivery, 0, len(entries))
+	for _, entry := range entries {
+		record, ok := byID[entry.RecordID]
+		if !ok {
+			continue
+		}
+		deliveries = append(deliveries, Delivery{Entry: entry, Record: record})
+	}
+	return deliveries, nil
+}
+
+// Acknowledge deletes entries their sink has accepted.
+func (s *Store) Acknowledge(ctx context.Context, entryIDs []string) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	_, err := s.db.NewDelete().Model((*OutboxEntry)(nil)).
+		Where("id IN (?)", bun.List(entryIDs)).
+		Exec(ctx)
+	return err
+}
+
+// Defer reschedules entries after a failed delivery attempt.
+func (s *Store) Defer(ctx context.Context, entryIDs []string, next time.Time, reason string) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	_, err := s.db.NewUpdate().Model((*OutboxEntry)(nil)).
+		Set("attempts = attempts + 1").
+		Set("next_attempt_at = ?", next.UTC()).
+		Set("last_error = ?", reason).
+		Where("id IN (?)", bun.List(entryIDs)).
+		Exec(ctx)
+	return err
+}
+
+// OutboxDepth returns how many entries are waiting for sinkID.
+func (s *Store) OutboxDepth(ctx context.Context, sinkID string) (int, error) {
+	return s.db.NewSelect().Model((*OutboxEntry)(nil)).
+		Where("sink_id = ?", sinkID).
+		Count(ctx)
+}
+
+// TrimOutbox bounds the backlog of a sink that cannot keep up. When more than
+// maxPending entries are waiting, the oldest are dropped and the number dropped
+// is returned. Dropped records remain in the audit chain; only their
+// forwarding is abandoned.
+func (s *Store) TrimOutbox(ctx context.Context, sinkID string, maxPending int) (int, error) {
+	depth, err := s.OutboxDepth(ctx, sinkID)
+	if err != nil {
+		return 0, err
+	}
+	excess := depth - maxPending
+	if maxPending <= 0 || excess <= 0 {
+		return 0, nil
+	}
+	var entryIDs []string
+	if err := s.db.NewSelect().Model((*OutboxEntry)(nil)).
+		Column("id").
+		Where("sink_id = ?", sinkID).
+		OrderExpr("id ASC").
+		Limit(excess).
+		Scan(ctx, &entryIDs); err != nil {
+		return 0, err
+	}
+	if err := s.Acknowledge(ctx, entryIDs); err != nil {
+		return 0, err
+	}
+	return len(entryIDs), nil
+}
+
+// PruneOutbox deletes entries for sinks that are no longer configured and
+// returns how many were removed.
+func (s *Store) PruneOutbox(ctx context.Context, keepSinkIDs []string) (int, error) {
+	query := s.db.NewDelete().Model((*OutboxEntry)(nil))
+	if len(keepSinkIDs) == 0 {
+		query = query.Where("1 = 1")
+	} else {
+		query = query.Where("sink_id NOT IN (?)", bun.List(keepSinkIDs))
+	}
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAppendEnqueuesOutboxEntriesPerSink(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	store.ForwardTo([]string{"siem", "archive"})
+	orgID := int64(4)
+	records := appendTestRecords(t, store, &orgID, 3)
+
+	for _, sinkID := range []string{"siem", "archive"} {
+		deliveries, err := store.DueDeliveries(ctx, sinkID, time.Now(), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 3 {
+			t.Fatalf("%s deliveries = %d, want 3", sinkID, len(deliveries))
+		}
+		for i, delivery := range deliveries {
+			if delivery.Record.ID != records[i].ID || delivery.Record.Details == nil {
+				t.Fatalf("%s delivery %d = %+v, want record %s with details", sinkID, i, delivery.Record, records[i].ID)
+			}
+		}
+	}
+
+	deliveries, err := store.DueDeliveries(ctx, "siem", time.Now(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Acknowledge(ctx, []string{deliveries[0].Entry.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Defer(ctx, []string{deliveries[1].Entry.ID}, time.Now().Add(time.Hour), "unavailable"); err != nil {
+		t.Fatal(err)
+	}
+	due, err := store.DueDeliveries(ctx, "siem", time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].Record.ID != records[2].ID {
+		t.Fatalf("due after ack/defer = %+v, want only the third record", due)
+	}
+	depth, err := store.OutboxDepth(ctx, "siem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth != 2 {
+		t.Fatalf("depth = %d, want 2", depth)
+	}
+}
+
+func TestTrimAndPruneOutbox(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	store.ForwardTo([]string{"siem", "retired"})
+	records := appendTestRecords(t, store, nil, 5)
+
+	dropped, err := store.TrimOutbox(ctx, "siem", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 3 {
+		t.Fatalf("dropped = %d, want 3", dropped)
+	}
+	due, err := store.DueDeliveries(ctx, "siem", time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Record.ID != records[3].ID {
+		t.Fatalf("remaining = %+v, want the two newest records", due)
+	}
+
+	pruned, err := store.PruneOutbox(ctx, []string{"siem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 5 {
+		t.Fatalf("pruned = %d, want 5 entries for the retired sink", pruned)
+	}
+	sinks, err := store.DueSinks(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 1 || sinks[0] != "siem" {
+		t.Fatalf("due sinks = %v, want [siem]", sinks)
+	}
+}
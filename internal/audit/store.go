@@ -0,0 +1,441 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type Store struct {
+	db     *database.DB
+	signer Signer
+	sinks  []string
+}
+
+func NewStore(db *database.DB, signer Signer) *Store {
+	return &Store{db: db, signer: signer}
+}
+
+// ForwardTo makes every appended record also enqueue one outbox entry per sink
+// ID, in the same transaction as the record itself.
+func (s *Store) ForwardTo(sinkIDs []string) *Store {
+	s.sinks = sinkIDs
+	return s
+}
+
+// Append adds one record to the end of the event's chain. Appends to the same
+// chain are serialized: on Postgres by a transaction-scoped advisory lock on
+// the chain, and on SQLite by its single writer connection. The (chain, seq)
+// unique constraint stays as a backstop.
+func (s *Store) Append(ctx context.Context, event Event) (Record, error) {
+	record, err := newRecord(event)
+	if err != nil {
+		return Record{}, err
+	}
+	err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockChain(ctx, tx, record.Chain); err != nil {
+			return err
+		}
+		head, found, err := chainHead(ctx, tx, record.Chain)
+		if err != nil {
+			return err
+		}
+		record.Seq = 1
+		record.PrevHash = ""
+		if found {
+			record.Seq = head.Seq + 1
+			record.PrevHash = head.Hash
+		}
+		record.KeyID, record.Hash = s.signer.Sign(recordPayload(record))
+		if _, err := tx.NewInsert().Model(&record).Exec(ctx); err != nil {
+			return err
+		}
+		return enqueueOutbox(ctx, tx, s.sinks, record)
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	populateDetails(&record)
+	return record, nil
+}
+
+// Checkpoint signs the current head of every chain that has advanced since its
+// last checkpoint and returns how many checkpoints were written.
+func (s *Store) Checkpoint(ctx context.Context) (int, error) {
+	var heads []struct {
+		Chain string `bun:"chain"`
+		Seq   int64  `bun:"seq"`
+	}
+	if err := s.db.NewSelect().Model((*Record)(nil)).
+		ColumnExpr("chain, MAX(seq) AS seq").
+		GroupExpr("chain").
+		Scan(ctx, &heads); err != nil {
+		return 0, err
+	}
+	written := 0
+	for _, head := range heads {
+		var last Checkpoint
+		err := s.db.NewSelect().Model(&last).
+			Where("chain = ?", head.Chain).
+			OrderExpr("seq DESC").
+			Limit(1).
+			Scan(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return written, err
+		}
+		if err == nil && last.Seq >= head.Seq {
+			continue
+		}
+		var record Record
+		if err := s.db.NewSelect().Model(&record).
+			Where("chain = ? AND seq = ?", head.Chain, head.Seq).
+			Scan(ctx); err != nil {
+			return written, err
+		}
+		checkpoint := Checkpoint{
+			ID:         database.NewID(),
+			Chain:      record.Chain,
+			Seq:        record.Seq,
+			RecordHash: record.Hash,
+			CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		}
+		checkpoint.KeyID, checkpoint.Signature = s.signer.Sign(checkpointPayload(checkpoint))
+		if _, err := s.db.NewInsert().Model(&checkpoint).Exec(ctx); err != nil {
+			if isUniqueConstraintError(err) {
+				continue
+			}
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// Chains lists every chain that has records or checkpoints, instance chain
+// first and organizations in id order.
+func (s *Store) Chains(ctx context.Context) ([]string, error) {
+	var recordChains, checkpointChains []string
+	if err := s.db.NewSelect().Model((*Record)(nil)).Distinct().Column("chain").Scan(ctx, &recordChains); err != nil {
+		return nil, err
+	}
+	if err := s.db.NewSelect().Model((*Checkpoint)(nil)).Distinct().Column("chain").Scan(ctx, &checkpointChains); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var chains []string
+	for _, chain := range append(recordChains, checkpointChains...) {
+		if !seen[chain] {
+			seen[chain] = true
+			chains = append(chains, chain)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		a, b := chainOrgID(chains[i]), chainOrgID(chains[j])
+		switch {
+		case a == nil && b == nil:
+			return chains[i] < chains[j]
+		case a == nil:
+			return true
+		case b == nil:
+			return false
+		default:
+			return *a < *b
+		}
+	})
+	return chains, nil
+}
+
+// VerifyAll verifies every chain.
+func (s *Store) VerifyAll(ctx context.Context) (Report, error) {
+	chains, err := s.Chains(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.verifyChains(ctx, chains)
+}
+
+// VerifyOrg verifies the chain of a single organization.
+func (s *Store) VerifyOrg(ctx context.Context, orgID int64) (Report, error) {
+	return s.verifyChains(ctx, []string{OrgChain(orgID)})
+}
+
+func (s *Store) verifyChains(ctx context.Context, chains []string) (Report, error) {
+	report := Report{Valid: true, Chains: []ChainReport{}, CheckedAt: time.Now()}
+	for _, chain := range chains {
+		chainReport, err := s.VerifyChain(ctx, chain)
+		if err != nil {
+			return Report{}, err
+		}
+		report.Valid = report.Valid && chainReport.Valid
+		report.Chains = append(report.Chains, chainReport)
+	}
+	return report, nil
+}
+
+// VerifyChain walks a chain in sequence order and reports the first broken
+// link: a missing sequence number, a record whose prev_hash does not match its
+// predecessor, a record or checkpoint whose signature does not verify, a
+// checkpoint that disagrees with the record it pins, or a checkpoint beyond the
+// chain head.
+func (s *Store) VerifyChain(ctx context.Context, chain string) (ChainReport, error) {
+	report := ChainReport{Chain: chain, OrgID: chainOrgID(chain), Valid: true}
+
+	var checkpoints []Checkpoint
+	if err := s.db.NewSelect().Model(&checkpoints).
+		Where("chain = ?", chain).
+		OrderExpr("seq ASC").
+		Scan(ctx); err != nil {
+		return ChainReport{}, err
+	}
+	report.Checkpoints = len(checkpoints)
+	pinned := make(map[int64]Checkpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		pinned[checkpoint.Seq] = checkpoint
+	}
+
+	fail := func(brk Break) (ChainReport, error) {
+		report.Valid = false
+		report.FirstBreak = &brk
+		return report, nil
+	}
+
+	prevHash := ""
+	expected := int64(1)
+	for {
+		var batch []Record
+		if err := s.db.NewSelect().Model(&batch).
+			Where("chain = ? AND seq >= ?", chain, expected).
+			OrderExpr("seq ASC").
+			Limit(verifyBatchSize).
+			Scan(ctx); err != nil {
+			return ChainReport{}, err
+		}
+		for _, record := range batch {
+			if record.Seq != expected {
+				return fail(Break{
+					Seq:     expected,
+					Reason:  BreakSequenceGap,
+					Message: fmt.Sprintf("record %d is missing; next record has sequence %d", expected, record.Seq),
+				})
+			}
+			if record.PrevHash != prevHash {
+				return fail(Break{
+					Seq:      record.Seq,
+					RecordID: record.ID,
+					Reason:   BreakPrevHashMismatch,
+					Message:  "prev_hash does not match the hash of the preceding record",
+				})
+			}
+			ok, err := s.signer.Verify(record.KeyID, recordPayload(record), record.Hash)
+			if err != nil {
+				return fail(Break{
+					Seq:      record.Seq,
+					RecordID: record.ID,
+					Reason:   BreakUnknownKey,
+					Message:  fmt.Sprintf("record was signed with key %q, which is not in the keyring", record.KeyID),
+				})
+			}
+			if !ok {
+				return fail(Break{
+					Seq:      record.Seq,
+					RecordID: record.ID,
+					Reason:   BreakSignatureInvalid,
+					Message:  "record hash does not match its contents",
+				})
+			}
+			if checkpoint, ok := pinned[record.Seq]; ok {
+				if brk := s.verifyCheckpoint(checkpoint); brk != nil {
+					brk.RecordID = record.ID
+					return fail(*brk)
+				}
+				if checkpoint.RecordHash != record.Hash {
+					return fail(Break{
+						Seq:      record.Seq,
+						RecordID: record.ID,
+						Reason:   BreakCheckpointMismatch,
+						Message:  fmt.Sprintf("checkpoint %s pinned a different hash for this record", checkpoint.ID),
+					})
+				}
+			}
+			prevHash = record.Hash
+			report.Records++
+			report.HeadSeq = record.Seq
+			report.HeadHash = record.Hash
+			expected++
+		}
+		if len(batch) < verifyBatchSize {
+			break
+		}
+	}
+
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Seq <= report.HeadSeq {
+			continue
+		}
+		if brk := s.verifyCheckpoint(checkpoint); brk != nil {
+			return fail(*brk)
+		}
+		return fail(Break{
+			Seq:     report.HeadSeq + 1,
+			Reason:  BreakCheckpointTruncated,
+			Message: fmt.Sprintf("checkpoint %s pins sequence %d but the chain ends at %d", checkpoint.ID, checkpoint.Seq, report.HeadSeq),
+		})
+	}
+	return report, nil
+}
+
+func (s *Store) verifyCheckpoint(checkpoint Checkpoint) *Break {
+	ok, err := s.signer.Verify(checkpoint.KeyID, checkpointPayload(checkpoint), checkpoint.Signature)
+	if err != nil {
+		return &Break{
+			Seq:     checkpoint.Seq,
+			Reason:  BreakUnknownKey,
+			Message: fmt.Sprintf("checkpoint %s was signed with key %q, which is not in the keyring", checkpoint.ID, checkpoint.KeyID),
+		}
+	}
+	if !ok {
+		return &Break{
+			Seq:     checkpoint.Seq,
+			Reason:  BreakCheckpointInvalid,
+			Message: fmt.Sprintf("checkpoint %s signature does not match its contents", checkpoint.ID),
+		}
+	}
+	return nil
+}
+
+// lockChain blocks until tx holds the append lock for chain. The lock is
+// released when tx commits or rolls back. SQLite needs none: the application
+// database has a single connection, so transactions already run one at a time.
+func lockChain(ctx context.Context, tx bun.Tx, chain string) error {
+	if tx.Dialect().Name() != dialect.PG {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?, hashtext(?))", chainLockNamespace, chain)
+	return err
+}
+
+func chainHead(ctx context.Context, tx bun.Tx, chain string) (Record, bool, error) {
+	var head Record
+	err := tx.NewSelect().Model(&head).
+		Column("seq", "hash").
+		Where("chain = ?", chain).
+		OrderExpr("seq DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	return head, err == nil, err
+}
+
+func newRecord(event Event) (Record, error) {
+	event.Action = strings.TrimSpace(event.Action)
+	if event.Action == "" || len(event.Action) > maxActionLength {
+		return Record{}, ErrInvalidEvent
+	}
+	details := ""
+	if event.Details != nil {
+		b, err := json.Marshal(event.Details)
+		if err != nil {
+			return Record{}, err
+		}
+		details = string(b)
+	}
+	if len(details) > maxDetailsBytes {
+		return Record{}, ErrInvalidEvent
+	}
+	return Record{
+		ID:             database.NewID(),
+		Chain:          ChainForOrg(event.OrgID),
+		OrgID:          event.OrgID,
+		ActorAccountID: event.ActorAccountID,
+		Action:         event.Action,
+		ResourceType:   strings.TrimSpace(event.ResourceType),
+		ResourceID:     strings.TrimSpace(event.ResourceID),
+		DetailsJSON:    details,
+		// Both supported databases keep microsecond precision; truncating
+		// before signing keeps the signed payload identical after a round trip.
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+	}, nil
+}
+
+// recordPayload is the canonical byte encoding a record hash signs. Field
+// order is fixed by the struct, so the encoding is stable across processes.
+func recordPayload(record Record) []byte {
+	payload, _ := json.Marshal(struct {
+		Version        string `json:"v"`
+		ID             string `json:"id"`
+		Chain          string `json:"chain"`
+		Seq            int64  `json:"seq"`
+		OrgID          *int64 `json:"org_id"`
+		ActorAccountID *int64 `json:"actor_account_id"`
+		Action         string `json:"action"`
+		ResourceType   string `json:"resource_type"`
+		ResourceID     string `json:"resource_id"`
+		Details        string `json:"details"`
+		OccurredAt     string `json:"occurred_at"`
+		PrevHash       string `json:"prev_hash"`
+	}{
+		Version:        payloadFormatVersion,
+		ID:             record.ID,
+		Chain:          record.Chain,
+		Seq:            record.Seq,
+		OrgID:          record.OrgID,
+		ActorAccountID: record.ActorAccountID,
+		Action:         record.Action,
+		ResourceType:   record.ResourceType,
+		ResourceID:     record.ResourceID,
+		Details:        record.DetailsJSON,
+		OccurredAt:     record.OccurredAt.UTC().Format(time.RFC3339Nano),
+		PrevHash:       record.PrevHash,
+	})
+	return payload
+}
+
+func checkpointPayload(checkpoint Checkpoint) []byte {
+	payload, _ := json.Marshal(struct {
+		Version    string `json:"v"`
+		ID         string `json:"id"`
+		Chain      string `json:"chain"`
+		Seq        int64  `json:"seq"`
+		RecordHash string `json:"record_hash"`
+		CreatedAt  string `json:"created_at"`
+	}{
+		Version:    payloadFormatVersion,
+		ID:         checkpoint.ID,
+		Chain:      checkpoint.Chain,
+		Seq:        checkpoint.Seq,
+		RecordHash: checkpoint.RecordHash,
+		CreatedAt:  checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	return payload
+}
+
+func populateDetails(record *Record) {
+	if record == nil || record.DetailsJSON == "" {
+		return
+	}
+	var details any
+	if err := json.Unmarshal([]byte(record.DetailsJSON), &details); err == nil {
+		record.Details = details
+	}
+}
+
+func isUniqueConstraintError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unique constraint") ||
+		strings.Contains(msg, "duplicate key value") ||
+		strings.Contains(msg, "constraint failed")
+}
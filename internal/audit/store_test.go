@@ -0,0 +1,278 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/encrypt"
+)
+
+func newTestStore(t *testing.T) (*Store, *database.DB) {
+	t.Helper()
+	db, err := database.New("sqlite", filepath.Join(t.TempDir(), "audit.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := encrypt.NewKeyring("audit-test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(db, keyring), db
+}
+
+func appendTestRecords(t *testing.T, store *Store, orgID *int64, n int) []Record {
+	t.Helper()
+	records := make([]Record, 0, n)
+	for range n {
+		record, err := store.Append(context.Background(), Event{
+			OrgID:        orgID,
+			Action:       "workspace.create",
+			ResourceType: "workspace",
+			ResourceID:   "1",
+			Details:      map[string]any{"name": "Analytics"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAppendLinksRecordsPerChain(t *testing.T) {
+	store, _ := newTestStore(t)
+	orgA, orgB := int64(1), int64(2)
+
+	a := appendTestRecords(t, store, &orgA, 3)
+	b := appendTestRecords(t, store, &orgB, 2)
+	instance := appendTestRecords(t, store, nil, 1)
+
+	if a[0].Seq != 1 || a[0].PrevHash != "" {
+		t.Fatalf("first record seq=%d prev=%q, want genesis", a[0].Seq, a[0].PrevHash)
+	}
+	for i := 1; i < len(a); i++ {
+		if a[i].Seq != int64(i+1) || a[i].PrevHash != a[i-1].Hash {
+			t.Fatalf("record %d seq=%d prev=%q, want seq %d prev %q", i, a[i].Seq, a[i].PrevHash, i+1, a[i-1].Hash)
+		}
+	}
+	if b[0].Seq != 1 || b[0].Chain != OrgChain(orgB) {
+		t.Fatalf("org B record = %+v, want first record on its own chain", b[0])
+	}
+	if instance[0].Chain != InstanceChain || instance[0].OrgID != nil {
+		t.Fatalf("instance record = %+v, want instance chain", instance[0])
+	}
+
+	report, err := store.VerifyAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || len(report.Chains) != 3 {
+		t.Fatalf("report = %+v, want three valid chains", report)
+	}
+	if report.Chains[0].Chain != InstanceChain || report.Chains[1].Chain != OrgChain(orgA) {
+		t.Fatalf("chain order = %+v, want instance first then organizations", report.Chains)
+	}
+	if report.Chains[1].Records != 3 || report.Chains[1].HeadHash != a[2].Hash {
+		t.Fatalf("org A chain report = %+v", report.Chains[1])
+	}
+}
+
+func TestConcurrentAppendsAllLandOnTheChain(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	const appends = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, appends)
+	for range appends {
+		wg.Go(func() {
+			_, err := store.Append(context.Background(), Event{Action: "account.login"})
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := store.VerifyChain(context.Background(), InstanceChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Records != appends {
+		t.Fatalf("report = %+v, want %d valid records", report, appends)
+	}
+}
+
+func TestAppendRejectsInvalidEvent(t *testing.T) {
+	store, _ := newTestStore(t)
+	if _, err := store.Append(context.Background(), Event{Action: "  "}); err != ErrInvalidEvent {
+		t.Fatalf("err = %v, want ErrInvalidEvent", err)
+	}
+}
+
+func TestVerifyChainReportsFirstBrokenLink(t *testing.T) {
+	ctx := context.Background()
+	orgID := int64(7)
+
+	t.Run("edited record", func(t *testing.T) {
+		store, db := newTestStore(t)
+		records := appendTestRecords(t, store, &orgID, 4)
+		if _, err := db.NewUpdate().Model((*Record)(nil)).
+			Set("action = ?", "workspace.delete").
+			Where("id = ?", records[1].ID).
+			Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertBreak(t, store, OrgChain(orgID), 2, BreakSignatureInvalid)
+	})
+
+	t.Run("re-linked record", func(t *testing.T) {
+		store, db := newTestStore(t)
+		records := appendTestRecords(t, store, &orgID, 4)
+		if _, err := db.NewUpdate().Model((*Record)(nil)).
+			Set("prev_hash = ?", records[0].Hash).
+			Where("id = ?", records[2].ID).
+			Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertBreak(t, store, OrgChain(orgID), 3, BreakPrevHashMismatch)
+	})
+
+	t.Run("deleted record", func(t *testing.T) {
+		store, db := newTestStore(t)
+		records := appendTestRecords(t, store, &orgID, 4)
+		if _, err := db.NewDelete().Model((*Record)(nil)).Where("id = ?", records[1].ID).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertBreak(t, store, OrgChain(orgID), 2, BreakSequenceGap)
+	})
+
+	t.Run("truncated tail", func(t *testing.T) {
+		store, db := newTestStore(t)
+		records := appendTestRecords(t, store, &orgID, 4)
+		if _, err := store.Checkpoint(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.NewDelete().Model((*Record)(nil)).Where("id = ?", records[3].ID).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertBreak(t, store, OrgChain(orgID), 4, BreakCheckpointTruncated)
+	})
+
+	t.Run("forged checkpoint", func(t *testing.T) {
+		store, db := newTestStore(t)
+		appendTestRecords(t, store, &orgID, 2)
+		if _, err := store.Checkpoint(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.NewUpdate().Model((*Checkpoint)(nil)).
+			Set("record_hash = ?", "forged").
+			Where("chain = ?", OrgChain(orgID)).
+			Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertBreak(t, store, OrgChain(orgID), 2, BreakCheckpointInvalid)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		store, _ := newTestStore(t)
+		appendTestRecords(t, store, &orgID, 2)
+		other, err := encrypt.NewKeyring("a-different-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.signer = other
+		assertBreak(t, store, OrgChain(orgID), 1, BreakUnknownKey)
+	})
+}
+
+func TestVerifyChainAcceptsRetiredKeys(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	orgID := int64(3)
+	appendTestRecords(t, store, &orgID, 2)
+	if _, err := store.Checkpoint(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := encrypt.NewKeyring("audit-rotated-key", "audit-test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.signer = rotated
+	appendTestRecords(t, store, &orgID, 2)
+
+	report, err := store.VerifyOrg(ctx, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Chains[0].Records != 4 {
+		t.Fatalf("report = %+v, want four valid records across a key rotation", report)
+	}
+}
+
+func TestCheckpointOnlySignsAdvancedChains(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	orgID := int64(5)
+
+	appendTestRecords(t, store, &orgID, 2)
+	written, err := store.Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 {
+		t.Fatalf("written = %d, want 1", written)
+	}
+	written, err = store.Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 {
+		t.Fatalf("written = %d, want 0 for an unchanged chain", written)
+	}
+
+	appendTestRecords(t, store, &orgID, 1)
+	appendTestRecords(t, store, nil, 1)
+	written, err = store.Checkpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 2 {
+		t.Fatalf("written = %d, want 2", written)
+	}
+
+	report, err := store.VerifyOrg(ctx, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Chains[0].Checkpoints != 2 {
+		t.Fatalf("report = %+v, want valid chain with two checkpoints", report)
+	}
+}
+
+func assertBreak(t *testing.T, store *Store, chain string, seq int64, reason string) {
+	t.Helper()
+	report, err := store.VerifyChain(context.Background(), chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.FirstBreak == nil {
+		t.Fatalf("report = %+v, want broken chain", report)
+	}
+	if report.FirstBreak.Seq != seq || report.FirstBreak.Reason != reason {
+		t.Fatalf("first break = %+v, want seq %d reason %q", *report.FirstBreak, seq, reason)
+	}
+}
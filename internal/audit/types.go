@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	// InstanceChain holds records that are not scoped to an organization, such
+	// as instance administration and sign-in events.
+	InstanceChain = "instance"
+
+	orgChainPrefix = "org:"
+
+	BreakSequenceGap         = "sequence_gap"
+	BreakPrevHashMismatch    = "prev_hash_mismatch"
+	BreakSignatureInvalid    = "signature_invalid"
+	BreakUnknownKey          = "unknown_key"
+	BreakCheckpointInvalid   = "checkpoint_signature_invalid"
+	BreakCheckpointMismatch  = "checkpoint_mismatch"
+	BreakCheckpointTruncated = "truncated"
+
+	maxActionLength      = 128
+	maxDetailsBytes      = 16 * 1024
+	verifyBatchSize      = 500
+	payloadFormatVersion = "v1"
+
+	// chainLockNamespace is the first key of the Postgres advisory lock that
+	// serializes appends to a chain, keeping it apart from other advisory
+	// locks on the application database.
+	chainLockNamespace = 0x61756474
+)
+
+var (
+	ErrInvalidEvent = errors.New("audit event is invalid")
+)
+
+// Signer signs and verifies chain hashes. *encrypt.Keyring satisfies it.
+type Signer interface {
+	Sign(message []byte) (keyID, mac string)
+	Verify(keyID string, message []byte, mac string) (bool, error)
+}
+
+// Record mirrors a persisted audit row. Hash is the HMAC over the record's
+// canonical payload, which includes PrevHash, so editing, reordering, or
+// removing any record breaks every later link.
+type Record struct {
+	bun.BaseModel  `bun:"table:audit_records"`
+	ID             string    `bun:",pk" json:"id"`
+	Chain          string    `bun:",notnull" json:"chain"`
+	Seq            int64     `bun:",notnull" json:"seq"`
+	OrgID          *int64    `bun:",nullzero" json:"org_id,omitempty"`
+	ActorAccountID *int64    `bun:",nullzero" json:"actor_account_id,omitempty"`
+	Action         string    `bun:",notnull" json:"action"`
+	ResourceType   string    `bun:",nullzero" json:"resource_type,omitempty"`
+	ResourceID     string    `bun:",nullzero" json:"resource_id,omitempty"`
+	DetailsJSON    string    `bun:",nullzero" json:"-"`
+	Details        any       `bun:"-" json:"details,omitempty"`
+	OccurredAt     time.Time `bun:",notnull" json:"occurred_at"`
+	PrevHash       string    `bun:",notnull" json:"prev_hash"`
+	Hash           string    `bun:",notnull" json:"hash"`
+	KeyID          string    `bun:",notnull" json:"key_id"`
+}
+
+// Checkpoint is a signed statement that a chain's record at Seq had
+// RecordHash. A checkpoint beyond the current chain head proves truncation.
+type Checkpoint struct {
+	bun.BaseModel `bun:"table:audit_checkpoints"`
+	ID            string    `bun:",pk" json:"id"`
+	Chain         string    `bun:",notnull" json:"chain"`
+	Seq           int64     `bun:",notnull" json:"seq"`
+	RecordHash    string    `bun:",notnull" json:"record_hash"`
+	KeyID         string    `bun:",notnull" json:"key_id"`
+	Signature     string    `bun:",notnull" json:"signature"`
+	CreatedAt     time.Time `bun:",notnull" json:"created_at"`
+}
+
+// Event describes an audit record to append. Details must be safe to retain
+// indefinitely and must not contain SQL text, credentials, bind values, row
+// values, or secrets.
+type Event struct {
+	OrgID          *int64
+	ActorAccountID *int64
+	Action         string
+	ResourceType   string
+	ResourceID     string
+	Details        any
+}
+
+// Break describes the first broken link found in a chain.
+type Break struct {
+	Seq      int64  `json:"seq"`
+	RecordID string `json:"record_id,omitempty"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+}
+
+// ChainReport summarizes the verification of one chain.
+type ChainReport struct {
+	Chain       string `json:"chain"`
+	OrgID       *int64 `json:"org_id,omitempty"`
+	Records     int64  `json:"records"`
+	Checkpoints int    `json:"checkpoints"`
+	HeadSeq     int64  `json:"head_seq"`
+	HeadHash    string `json:"head_hash,omitempty"`
+	Valid       bool   `json:"valid"`
+	FirstBreak  *Break `json:"first_break,omitempty"`
+}
+
+// Report summarizes the verification of one or more chains.
+type Report struct {
+	Valid     bool          `json:"valid"`
+	Chains    []ChainReport `json:"chains"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// OrgChain returns the chain key for an organization.
+func OrgChain(orgID int64) string {
+	return orgChainPrefix + strconv.FormatInt(orgID, 10)
+}
+
+// ChainForOrg returns the chain an event scoped to orgID belongs to.
+func ChainForOrg(orgID *int64) string {
+	if orgID == nil {
+		return InstanceChain
+	}
+	return OrgChain(*orgID)
+}
+
+func chainOrgID(chain string) *int64 {
+	rest, ok := strings.CutPrefix(chain, orgChainPrefix)
+	if !ok {
+		return nil
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,4 @@
+// Package auditsink delivers audit records to external systems such as a SIEM:
+// RFC 5424 syslog over UDP, TCP, or TLS; HMAC-signed HTTP webhooks; and
+// newline-delimited JSON files with size-based rotation.
+package auditsink
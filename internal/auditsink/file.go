@@ -0,0 +1,147 @@
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+const defaultFileMaxSizeBytes = 100 << 20
+
+type FileConfig struct {
+	Path string
+	// MaxSizeBytes rotates the file before a write would grow it past this
+	// size.
+	MaxSizeBytes int64
+	// MaxBackups is how many rotated files (path.1 … path.N) are kept.
+	MaxBackups int
+}
+
+// File appends one JSON object per line. Rotation renames path to path.1,
+// shifting older backups up and deleting the oldest. File is not safe for
+// concurrent use; callers serialize deliveries per sink.
+type File struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+}
+
+func NewFile(cfg FileConfig) (*File, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("file sink path is required")
+	}
+	path, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve file sink path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("create file sink directory: %w", err)
+	}
+	if cfg.MaxSizeBytes <= 0 {
+		cfg.MaxSizeBytes = defaultFileMaxSizeBytes
+	}
+	if cfg.MaxBackups < 0 {
+		return nil, fmt.Errorf("file sink max backups must not be negative")
+	}
+	return &File{path: path, maxSize: cfg.MaxSizeBytes, maxBackups: cfg.MaxBackups}, nil
+}
+
+func (f *File) Deliver(ctx context.Context, records []audit.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	file, size, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if size > 0 && size+int64(len(line)) > f.maxSize {
+			if err := file.Close(); err != nil {
+				return err
+			}
+			file = nil
+			if err := f.rotate(); err != nil {
+				return err
+			}
+			if file, size, err = f.open(); err != nil {
+				return err
+			}
+		}
+		n, err := file.Write(line)
+		size += int64(n)
+		if err != nil {
+			return fmt.Errorf("write file sink: %w", err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync file sink: %w", err)
+	}
+	err = file.Close()
+	file = nil
+	return err
+}
+
+func (f *File) open() (*os.File, int64, error) {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open file sink: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("stat file sink: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+func (f *File) rotate() error {
+	if f.maxBackups == 0 {
+		return removeIfExists(f.path)
+	}
+	if err := removeIfExists(f.backupPath(f.maxBackups)); err != nil {
+		return err
+	}
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		if err := renameIfExists(f.backupPath(i), f.backupPath(i+1)); err != nil {
+			return err
+		}
+	}
+	return renameIfExists(f.path, f.backupPath(1))
+}
+
+func (f *File) backupPath(n int) string {
+	return f.path + "." + strconv.Itoa(n)
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate file sink: %w", err)
+	}
+	return nil
+}
+
+func renameIfExists(from, to string) error {
+	if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate file sink: %w", err)
+	}
+	return nil
+}
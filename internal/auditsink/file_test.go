@@ -0,0 +1,87 @@
+package auditsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+func TestFileAppendsNewlineDelimitedJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.ndjson")
+	sink, err := NewFile(FileConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := sink.Deliver(ctx, testRecords("auth.login")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Deliver(ctx, testRecords("auth.logout")); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := readFileSinkActions(t, path)
+	if len(actions) != 2 || actions[0] != "auth.login" || actions[1] != "auth.logout" {
+		t.Fatalf("actions = %q", actions)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestFileRotatesAndKeepsMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	line, err := json.Marshal(testRecords("auth.login")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two records fit per file, so five deliveries produce three files.
+	sink, err := NewFile(FileConfig{Path: path, MaxSizeBytes: int64(2 * (len(line) + 1)), MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Deliver(context.Background(), testRecords("a.1", "a.2", "a.3", "a.4", "a.5")); err != nil {
+		t.Fatal(err)
+	}
+
+	if actions := readFileSinkActions(t, path); len(actions) != 1 || actions[0] != "a.5" {
+		t.Fatalf("current file actions = %q", actions)
+	}
+	if actions := readFileSinkActions(t, path+".1"); len(actions) != 2 || actions[0] != "a.3" {
+		t.Fatalf("backup actions = %q", actions)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("expected only one backup, stat .2 err = %v", err)
+	}
+}
+
+func readFileSinkActions(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var actions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record audit.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, record.Action)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return actions
+}
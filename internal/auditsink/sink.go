@@ -0,0 +1,23 @@
+package auditsink
+
+import (
+	"context"
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+const (
+	TypeSyslog  = "syslog"
+	TypeWebhook = "webhook"
+	TypeFile    = "file"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Sink delivers audit records, oldest first. Deliver returns nil only once the
+// sink has accepted every record; on error the caller retries the whole batch,
+// so receivers should de-duplicate on the record ID.
+type Sink interface {
+	Deliver(ctx context.Context, records []audit.Record) error
+}
@@ -0,0 +1,26 @@
+package auditsink
+
+import (
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+func testRecords(actions ...string) []audit.Record {
+	orgID := int64(1)
+	records := make([]audit.Record, 0, len(actions))
+	for i, action := range actions {
+		records = append(records, audit.Record{
+			ID:         "rec" + string(rune('a'+i)),
+			Chain:      audit.OrgChain(orgID),
+			Seq:        int64(i + 1),
+			OrgID:      &orgID,
+			Action:     action,
+			Details:    map[string]any{"line": "one\ntwo"},
+			OccurredAt: time.Date(2026, 3, 4, 5, 6, 7, 123456000, time.UTC),
+			Hash:       "hash",
+			KeyID:      "key",
+		})
+	}
+	return records
+}
@@ -0,0 +1,157 @@
+package auditsink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+
+	// FacilityLogAudit is the RFC 5424 "log audit" facility.
+	FacilityLogAudit = 13
+
+	syslogSeverityNotice = 5
+	syslogVersion        = 1
+	syslogAppName        = "sqlwarden"
+	syslogTimestamp      = "2006-01-02T15:04:05.000000Z07:00"
+	maxSyslogHostname    = 255
+	maxSyslogAppName     = 48
+	maxSyslogMsgID       = 32
+)
+
+// utf8BOM marks the MSG part as UTF-8, as RFC 5424 section 6.4 requires.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+type SyslogConfig struct {
+	Network  string
+	Address  string
+	Hostname string
+	AppName  string
+	Facility int
+	// TLS is used when Network is NetworkTLS. A nil config verifies the
+	// server against the system roots.
+	TLS     *tls.Config
+	Timeout time.Duration
+}
+
+// Syslog writes one RFC 5424 message per record. UDP sends one datagram per
+// message; TCP and TLS use octet-counting framing (RFC 6587, RFC 5425) so
+// messages may safely contain newlines.
+type Syslog struct {
+	cfg SyslogConfig
+}
+
+func NewSyslog(cfg SyslogConfig) (*Syslog, error) {
+	switch cfg.Network {
+	case NetworkUDP, NetworkTCP, NetworkTLS:
+	default:
+		return nil, fmt.Errorf("syslog network must be %q, %q, or %q", NetworkUDP, NetworkTCP, NetworkTLS)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return nil, fmt.Errorf("syslog address must be host:port: %w", err)
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = syslogAppName
+	}
+	if cfg.Facility < 0 || cfg.Facility > 23 {
+		return nil, fmt.Errorf("syslog facility must be between 0 and 23")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &Syslog{cfg: cfg}, nil
+}
+
+func (s *Syslog) Deliver(ctx context.Context, records []audit.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("dial syslog %s: %w", s.cfg.Address, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		msg, err := s.format(record)
+		if err != nil {
+			return err
+		}
+		if s.cfg.Network != NetworkUDP {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := conn.Write(msg); err != nil {
+			return fmt.Errorf("write syslog message: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Syslog) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	if s.cfg.Network == NetworkTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.cfg.TLS}
+		return tlsDialer.DialContext(ctx, "tcp", s.cfg.Address)
+	}
+	return dialer.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+}
+
+// format renders a record as an RFC 5424 message. The audit action becomes the
+// MSGID so collectors can route on it; the record itself is the JSON MSG.
+func (s *Syslog) format(record audit.Record) ([]byte, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>%d %s %s %s - %s - ",
+		s.cfg.Facility*8+syslogSeverityNotice,
+		syslogVersion,
+		record.OccurredAt.UTC().Format(syslogTimestamp),
+		syslogHeaderField(s.cfg.Hostname, maxSyslogHostname),
+		syslogHeaderField(s.cfg.AppName, maxSyslogAppName),
+		syslogHeaderField(record.Action, maxSyslogMsgID),
+	)
+	buf.Write(utf8BOM)
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// syslogHeaderField restricts a header field to printable US-ASCII without
+// spaces and to the field's maximum length, using the nil value "-" when
+// nothing remains.
+func syslogHeaderField(value string, maxLen int) string {
+	out := make([]byte, 0, min(len(value), maxLen))
+	for i := 0; i < len(value) && len(out) < maxLen; i++ {
+		if c := value[i]; c >= 33 && c <= 126 {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return "-"
+	}
+	return string(out)
+}
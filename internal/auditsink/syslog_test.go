@@ -0,0 +1,130 @@
+package auditsink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+func TestSyslogUDPSendsOneRFC5424DatagramPerRecord(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewSyslog(SyslogConfig{Network: NetworkUDP, Address: conn.LocalAddr().String(), Hostname: "db host", Facility: FacilityLogAudit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Deliver(context.Background(), testRecords("org.team.create", "org.team.delete")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64<<10)
+	for _, action := range []string{"org.team.create", "org.team.delete"} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, body, ok := bytes.Cut(buf[:n], utf8BOM)
+		if !ok {
+			t.Fatalf("message %q has no UTF-8 BOM", buf[:n])
+		}
+		want := "<109>1 2026-03-04T05:06:07.123456Z dbhost sqlwarden - " + action + " - "
+		if string(header) != want {
+			t.Fatalf("header = %q, want %q", header, want)
+		}
+		var record audit.Record
+		if err := json.Unmarshal(body, &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Action != action {
+			t.Fatalf("record action = %q, want %q", record.Action, action)
+		}
+	}
+}
+
+func TestSyslogTCPUsesOctetCountingFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var messages []string
+		for {
+			prefix, err := reader.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(prefix))
+			if err != nil {
+				break
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				break
+			}
+			messages = append(messages, string(msg))
+		}
+		received <- messages
+	}()
+
+	sink, err := NewSyslog(SyslogConfig{Network: NetworkTCP, Address: listener.Addr().String(), Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Deliver(context.Background(), testRecords("auth.login", "auth.logout")); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := <-received
+	if len(messages) != 2 {
+		t.Fatalf("received %d framed messages, want 2: %q", len(messages), messages)
+	}
+	if !strings.HasPrefix(messages[1], "<5>1 ") || !strings.Contains(messages[1], " auth.logout - ") {
+		t.Fatalf("second message = %q", messages[1])
+	}
+}
+
+func TestNewSyslogValidatesConfig(t *testing.T) {
+	for _, cfg := range []SyslogConfig{
+		{Network: "http", Address: "127.0.0.1:514"},
+		{Network: NetworkUDP, Address: "127.0.0.1"},
+		{Network: NetworkTCP, Address: "127.0.0.1:514", Facility: 24},
+	} {
+		if _, err := NewSyslog(cfg); err == nil {
+			t.Fatalf("NewSyslog(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	if got := syslogHeaderField("a b\tc", 32); got != "abc" {
+		t.Fatalf("got %q, want %q", got, "abc")
+	}
+	if got := syslogHeaderField("   ", 32); got != "-" {
+		t.Fatalf("got %q, want nil value", got)
+	}
+	if got := syslogHeaderField(strings.Repeat("x", 40), 32); len(got) != 32 {
+		t.Fatalf("got length %d, want 32", len(got))
+	}
+}
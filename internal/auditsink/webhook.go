@@ -0,0 +1,107 @@
+package auditsink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+const (
+	HeaderWebhookTimestamp = "X-Sqlwarden-Timestamp"
+	HeaderWebhookSignature = "X-Sqlwarden-Signature"
+
+	webhookSignaturePrefix = "sha256="
+	maxWebhookErrorBody    = 512
+)
+
+type WebhookConfig struct {
+	URL string
+	// Secret keys the HMAC-SHA256 signature sent with every request.
+	Secret  string
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// Webhook POSTs batches of records as {"records": [...]}. Each request is
+// signed over "<timestamp>.<body>" so receivers can authenticate it and reject
+// replays; see WebhookSignature.
+type Webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("webhook url must be an absolute http or https URL")
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("webhook secret is required")
+	}
+	client := cfg.Client
+	if client == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	return &Webhook{url: cfg.URL, secret: []byte(cfg.Secret), client: client, now: time.Now}, nil
+}
+
+func (w *Webhook) Deliver(ctx context.Context, records []audit.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(struct {
+		Records []audit.Record `json:"records"`
+	}{Records: records})
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(w.now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, webhookSignaturePrefix+WebhookSignature(w.secret, timestamp, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post audit webhook: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(res.Body, maxWebhookErrorBody))
+		return fmt.Errorf("audit webhook returned %d: %s", res.StatusCode, bytes.TrimSpace(snippet))
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// WebhookSignature returns the hex HMAC-SHA256 of "<timestamp>.<body>" under
+// secret. Receivers compare it, in constant time, with the value after
+// "sha256=" in the X-Sqlwarden-Signature header.
+func WebhookSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package auditsink
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+)
+
+func TestWebhookSignsBatches(t *testing.T) {
+	secret := "webhook-secret"
+	var got struct {
+		Records []audit.Record `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(HeaderWebhookTimestamp)
+		signature, _ := strings.CutPrefix(r.Header.Get(HeaderWebhookSignature), "sha256=")
+		want := WebhookSignature([]byte(secret), timestamp, body)
+		if !hmac.Equal([]byte(signature), []byte(want)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhook(WebhookConfig{URL: server.URL, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.now = func() time.Time { return time.Unix(1700000000, 0) }
+	if err := sink.Deliver(context.Background(), testRecords("auth.login", "auth.logout")); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Records) != 2 || got.Records[1].Action != "auth.logout" {
+		t.Fatalf("received records = %+v", got.Records)
+	}
+}
+
+func TestWebhookReturnsErrorForNonSuccessStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "siem overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhook(WebhookConfig{URL: server.URL, Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Deliver(context.Background(), testRecords("auth.login"))
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "siem overloaded") {
+		t.Fatalf("err = %v, want 503 with response snippet", err)
+	}
+}
+
+func TestNewWebhookValidatesConfig(t *testing.T) {
+	for _, cfg := range []WebhookConfig{
+		{URL: "ftp://siem.example.com", Secret: "s"},
+		{URL: "/relative", Secret: "s"},
+		{URL: "https://siem.example.com/hook"},
+	} {
+		if _, err := NewWebhook(cfg); err == nil {
+			t.Fatalf("NewWebhook(%+v) succeeded, want error", cfg)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package connection
 import (
 	"context"
 	"errors"
-	"fmt"
+	"io"
 	"math/rand"
 	"sync"
 	"time"
@@ -17,6 +17,10 @@ import (
 
 var ErrQueryCursorsUnsupported = errors.New("driver does not support query cursors")
 
+// ErrSessionClosed is returned by GetOrCreate when the session was removed,
+// for example because access was revoked, while it was still opening.
+var ErrSessionClosed = errors.New("live session was closed while it was opening")
+
 // entropySource is a package-level entropy source for ULID generation.
 var (
 	entropyMu     sync.Mutex
@@ -40,13 +44,25 @@ type Session struct {
 	Conn         engine.Driver // open connection
 	mu           sync.Mutex    // serializes Query/Execute on this session
 	cursors      map[string]*QueryCursorHandle
+	tunnel       *Tunnel   // nil for direct connections
+	lease        io.Closer // nil unless the credentials are leased
+	init         []string  // run on every connection the session opens
 	lastUsed     time.Time
+
+	replicas     []*replica // read replicas, in configured order
+	nextReplica  int        // round-robin position, guarded by mu
+	statusMu     sync.Mutex // guards inTx, lastEndpoint and replica health
+	inTx         bool
+	lastEndpoint string
 }
 
 type QueryCursorHandle struct {
 	ID     string
 	Cursor cursor.QueryCursor
-	mu     sync.Mutex
+	// Endpoint is the endpoint the cursor runs on; empty unless the session
+	// has replicas.
+	Endpoint string
+	mu       sync.Mutex
 }
 
 type SessionMetadata struct {
@@ -54,22 +70,24 @@ type SessionMetadata struct {
 	WorkspaceID string
 }
 
-// Query executes a query on the session, serialized via the session mutex.
+// Query executes a query on the session's primary, serialized via the
+// session mutex. Use ReadWithOptions to let replicas serve reads.
 func (s *Session) Query(ctx context.Context, sql string, args ...any) (*result.ResultSet, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lastUsed = time.Now()
-	return s.Conn.Query(ctx, sql, args...)
+	return s.onPrimary(sql, func() (*result.ResultSet, error) {
+		return s.Conn.Query(ctx, sql, args...)
+	})
 }
 
 func (s *Session) QueryWithOptions(ctx context.Context, sql string, opts cursor.ScanOptions, args ...any) (*result.ResultSet, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lastUsed = time.Now()
-	if driver, ok := s.Conn.(cursor.ResultLimitDriver); ok {
-		return driver.QueryWithOptions(ctx, sql, opts, args...)
-	}
-	return s.Conn.Query(ctx, sql, args...)
+	return s.onPrimary(sql, func() (*result.ResultSet, error) {
+		return queryWithOptions(ctx, s.Conn, sql, opts, args...)
+	})
 }
 
 // Execute executes a statement on the session, serialized via the session mutex.
@@ -77,17 +95,33 @@ func (s *Session) Execute(ctx context.Context, sql string, args ...any) (*result
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lastUsed = time.Now()
-	return s.Conn.Execute(ctx, sql, args...)
+	return s.onPrimary(sql, func() (*result.ResultSet, error) {
+		return s.Conn.Execute(ctx, sql, args...)
+	})
 }
 
 func (s *Session) ExecuteWithOptions(ctx context.Context, sql string, opts cursor.ScanOptions, args ...any) (*result.ResultSet, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lastUsed = time.Now()
-	if driver, ok := s.Conn.(cursor.ResultLimitDriver); ok {
-		return driver.ExecuteWithOptions(ctx, sql, opts, args...)
+	return s.onPrimary(sql, func() (*result.ResultSet, error) {
+		if driver, ok := s.Conn.(cursor.ResultLimitDriver); ok {
+			return driver.ExecuteWithOptions(ctx, sql, opts, args...)
+		}
+		return s.Conn.Execute(ctx, sql, args...)
+	})
+}
+
+// onPrimary runs a statement on the primary and records its effect on the
+// session's transaction state and last endpoint. The caller holds mu.
+func (s *Session) onPrimary(sql string, run func() (*result.ResultSet, error)) (*result.ResultSet, error) {
+	rs, err := run()
+	if err != nil {
+		return nil, err
 	}
-	return s.Conn.Execute(ctx, sql, args...)
+	s.trackTransaction(sql)
+	s.served(PrimaryEndpoint, rs)
+	return rs, nil
 }
 
 // ApplyDDL applies a structured DDL operation while holding the same
@@ -113,8 +147,14 @@ func (s *Session) StartQueryCursor(ctx context.Context, sql string, args ...any)
 	if err != nil {
 		return nil, err
 	}
+	return s.addCursor(cursor, PrimaryEndpoint), nil
+}
 
-	handle := &QueryCursorHandle{ID: newULID(), Cursor: cursor}
+func (s *Session) addCursor(qc cursor.QueryCursor, endpoint string) *QueryCursorHandle {
+	handle := &QueryCursorHandle{ID: newULID(), Cursor: qc}
+	if s.HasReplicas() {
+		handle.Endpoint = endpoint
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -124,7 +164,7 @@ func (s *Session) StartQueryCursor(ctx context.Context, sql string, args ...any)
 	s.cursors[handle.ID] = handle
 	s.lastUsed = time.Now()
 
-	return handle, nil
+	return handle
 }
 
 func (s *Session) CloseCursor(cursorID string) error {
@@ -163,7 +203,11 @@ func (h *QueryCursorHandle) Columns() []result.Column {
 func (h *QueryCursorHandle) Fetch(ctx context.Context, opts cursor.ScanOptions) (*result.ResultSet, cursor.QueryCursorState, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	return h.Cursor.Fetch(ctx, opts)
+	rs, state, err := h.Cursor.Fetch(ctx, opts)
+	if rs != nil && h.Endpoint != "" {
+		rs.Endpoint = h.Endpoint
+	}
+	return rs, state, err
 }
 
 func (h *QueryCursorHandle) Close() error {
@@ -175,13 +219,15 @@ func (h *QueryCursorHandle) Close() error {
 // Manager maintains in-memory live sessions with TTL reaping.
 type Manager struct {
 	mu                sync.RWMutex
-	byKey             map[string]*Session // key: "accountID:connID"
-	byID              map[string]*Session // key: session ULID
+	byKey             map[string]*Session        // key: "accountID:connID"
+	byID              map[string]*Session        // key: session ULID
+	opening           map[string]*openingSession // key: "accountID:connID"
 	idleTimeout       time.Duration
 	stop              chan struct{}
 	stopped           chan struct{}
 	closeOnce         sync.Once
 	onConnectionEmpty func(string)
+	onSessionsRemoved func([]string)
 }
 
 // SetOnConnectionEmpty registers a lifecycle hook invoked after the final live
@@ -192,11 +238,21 @@ func (m *Manager) SetOnConnectionEmpty(hook func(connectionID string)) {
 	m.mu.Unlock()
 }
 
+// SetOnSessionsRemoved registers a hook invoked with the IDs of sessions after
+// they are closed and removed, however that happened. The hook always runs
+// without m.mu held.
+func (m *Manager) SetOnSessionsRemoved(hook func(sessionIDs []string)) {
+	m.mu.Lock()
+	m.onSessionsRemoved = hook
+	m.mu.Unlock()
+}
+
 // New creates a new Manager with the given idle timeout and starts the background reaper.
 func New(idleTimeout time.Duration) *Manager {
 	m := &Manager{
 		byKey:       make(map[string]*Session),
 		byID:        make(map[string]*Session),
+		opening:     make(map[string]*openingSession),
 		idleTimeout: idleTimeout,
 		stop:        make(chan struct{}),
 		stopped:     make(chan struct{}),
@@ -205,49 +261,168 @@ func New(idleTimeout time.Duration) *Manager {
 	return m
 }
 
-// GetOrCreate returns the existing session for (accountID, connID) or creates one using open().
-// Returns: (session, created, error) where created=true means a new session was opened.
-func (m *Manager) GetOrCreate(accountID, connID string, open func() (engine.Driver, error)) (*Session, bool, error) {
-	return m.GetOrCreateWithMetadata(accountID, connID, SessionMetadata{}, open)
+// SessionKey identifies the live session an account holds on a connection.
+type SessionKey struct {
+	AccountID    string
+	ConnectionID string
 }
 
-// GetOrCreateWithMetadata returns an existing session or creates one with
-// resource metadata used for workspace-scoped admin visibility and revocation.
-func (m *Manager) GetOrCreateWithMetadata(accountID, connID string, metadata SessionMetadata, open func() (engine.Driver, error)) (*Session, bool, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	key := fmt.Sprintf("%s:%s", accountID, connID)
-	if sess, ok := m.byKey[key]; ok {
-		sess.lastUsed = time.Now()
-		if metadata.OrgID != "" {
-			sess.OrgID = metadata.OrgID
+func (k SessionKey) String() string {
+	return k.AccountID + ":" + k.ConnectionID
+}
+
+// SessionOptions describes how GetOrCreate opens a new session. An existing
+// session keeps the tunnel, replicas and init it was created with.
+type SessionOptions struct {
+	// Metadata scopes the session for workspace-scoped admin visibility and
+	// revocation. Non-empty fields are also applied to an existing session.
+	Metadata SessionMetadata
+	// Tunnel, when set, makes a new session first open its own SSH tunnel.
+	// Open receives the tunnel's dialer, and the tunnel is closed with the
+	// session. Without a tunnel Open receives a nil dialer.
+	Tunnel *TunnelConfig
+	// Replicas are the connection's read replicas. A new session only opens
+	// the primary; each replica connects through the session's tunnel the
+	// first time a read is routed to it.
+	Replicas []ReplicaSpec
+	// Init is session initialization SQL. It runs on the primary right after
+	// it connects, and on each replica whenever that replica connects or
+	// reconnects; if a statement fails, the connection is closed and a
+	// *SessionInitError returned.
+	Init []string
+	// Open connects the primary. It also returns the credential lease, which
+	// may be nil; the lease is kept for the session's lifetime and closed
+	// after the driver when the session ends. Open closes the lease itself
+	// when it fails.
+	Open func(dial engine.DialFunc) (engine.Driver, io.Closer, error)
+}
+
+// openingSession is a session being opened outside m.mu. Concurrent callers
+// for the same key wait on done instead of opening a second session. The
+// placeholder carries the session's identity so removals that match it can
+// discard it once it opens.
+type openingSession struct {
+	placeholder Session
+	done        chan struct{}
+	discarded   bool
+	err         error
+}
+
+// GetOrCreate returns the existing session for key or opens one as opts
+// describe. created reports whether a new session was opened. Opening runs
+// without m.mu held, so a slow tunnel or database only holds up callers
+// waiting on the same key.
+func (m *Manager) GetOrCreate(ctx context.Context, key SessionKey, opts SessionOptions) (sess *Session, created bool, err error) {
+	var opening *openingSession
+	for opening == nil {
+		m.mu.Lock()
+		if sess, ok := m.byKey[key.String()]; ok {
+			sess.lastUsed = time.Now()
+			if opts.Metadata.OrgID != "" {
+				sess.OrgID = opts.Metadata.OrgID
+			}
+			if opts.Metadata.WorkspaceID != "" {
+				sess.WorkspaceID = opts.Metadata.WorkspaceID
+			}
+			m.mu.Unlock()
+			return sess, false, nil
+		}
+		if pending, ok := m.opening[key.String()]; ok {
+			m.mu.Unlock()
+			select {
+			case <-pending.done:
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+			if pending.err != nil {
+				return nil, false, pending.err
+			}
+			continue
 		}
-		if metadata.WorkspaceID != "" {
-			sess.WorkspaceID = metadata.WorkspaceID
+		opening = &openingSession{
+			placeholder: Session{
+				AccountID:    key.AccountID,
+				ConnectionID: key.ConnectionID,
+				OrgID:        opts.Metadata.OrgID,
+				WorkspaceID:  opts.Metadata.WorkspaceID,
+			},
+			done: make(chan struct{}),
 		}
-		return sess, false, nil
+		m.opening[key.String()] = opening
+		m.mu.Unlock()
 	}
 
-	d, err := open()
+	sess, err = openSession(ctx, key, opts)
+
+	m.mu.Lock()
+	delete(m.opening, key.String())
+	if err == nil && opening.discarded {
+		sess.close()
+		sess, err = nil, ErrSessionClosed
+	}
+	if err == nil {
+		m.byKey[key.String()] = sess
+		m.byID[sess.ID] = sess
+	}
+	opening.err = err
+	close(opening.done)
+	m.mu.Unlock()
 	if err != nil {
 		return nil, false, err
 	}
+	return sess, true, nil
+}
+
+func openSession(ctx context.Context, key SessionKey, opts SessionOptions) (*Session, error) {
+	var t *Tunnel
+	if opts.Tunnel != nil {
+		var err error
+		t, err = OpenTunnel(ctx, *opts.Tunnel)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d, lease, err := opts.Open(t.Dialer())
+	if err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+	if err := runSessionInit(ctx, d, opts.Init); err != nil {
+		_ = d.Close()
+		if lease != nil {
+			_ = lease.Close()
+		}
+		_ = t.Close()
+		return nil, err
+	}
 
 	sess := &Session{
 		ID:           newULID(),
-		AccountID:    accountID,
-		ConnectionID: connID,
-		OrgID:        metadata.OrgID,
-		WorkspaceID:  metadata.WorkspaceID,
+		AccountID:    key.AccountID,
+		ConnectionID: key.ConnectionID,
+		OrgID:        opts.Metadata.OrgID,
+		WorkspaceID:  opts.Metadata.WorkspaceID,
 		Conn:         d,
+		tunnel:       t,
+		lease:        lease,
+		init:         opts.Init,
 		lastUsed:     time.Now(),
 	}
+	for _, spec := range opts.Replicas {
+		sess.replicas = append(sess.replicas, &replica{spec: spec})
+	}
+	return sess, nil
+}
 
-	m.byKey[key] = sess
-	m.byID[sess.ID] = sess
-
-	return sess, true, nil
+// discardOpeningLocked marks sessions still being opened that match so they
+// are closed instead of registered, the same as a removal would have done
+// had they already opened. m.mu must be held.
+func (m *Manager) discardOpeningLocked(match func(*Session) bool) {
+	for _, opening := range m.opening {
+		if match(&opening.placeholder) {
+			opening.discarded = true
+		}
+	}
 }
 
 // SessionRef is a lightweight summary of an active session returned by AllForAccount.
@@ -258,6 +433,7 @@ type SessionRef struct {
 	OrgID        string
 	WorkspaceID  string
 	LastUsedAt   time.Time
+	LastEndpoint string // empty unless the session has read replicas
 }
 
 // AllForAccount returns a SessionRef for every active session owned by accountID.
@@ -275,6 +451,7 @@ func (m *Manager) AllForAccount(accountID string) []SessionRef {
 				OrgID:        sess.OrgID,
 				WorkspaceID:  sess.WorkspaceID,
 				LastUsedAt:   sess.lastUsed,
+				LastEndpoint: sess.LastEndpoint(),
 			})
 		}
 	}
@@ -295,6 +472,7 @@ func (m *Manager) AllForWorkspace(workspaceID string) []SessionRef {
 				OrgID:        sess.OrgID,
 				WorkspaceID:  sess.WorkspaceID,
 				LastUsedAt:   sess.lastUsed,
+				LastEndpoint: sess.LastEndpoint(),
 			})
 		}
 	}
@@ -329,9 +507,23 @@ func (m *Manager) Remove(sessionID string) {
 	delete(m.byID, sessionID)
 	connectionID := sess.ConnectionID
 	m.mu.Unlock()
+	m.notifySessionsRemoved([]string{sessionID})
 	m.notifyConnectionEmpty(connectionID)
 }
 
+// RemoveForAccountConnection closes and removes the session accountID holds
+// on connID, if any. It reports whether there was one.
+func (m *Manager) RemoveForAccountConnection(accountID, connID string) bool {
+	m.mu.RLock()
+	sess, ok := m.byKey[accountID+":"+connID]
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	m.Remove(sess.ID)
+	return true
+}
+
 // CountForConnection returns the number of live sessions for the given connection ID.
 func (m *Manager) CountForConnection(connID string) int {
 	m.mu.RLock()
@@ -346,11 +538,19 @@ func (m *Manager) CountForConnection(connID string) int {
 	return count
 }
 
+// Count returns the number of live sessions.
+func (m *Manager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.byID)
+}
+
 // RemoveForConnection closes and removes all live sessions for the given connection ID.
 // Returns the number of removed sessions.
 func (m *Manager) RemoveForConnection(connID string) int {
 	m.mu.Lock()
-	removed := 0
+	m.discardOpeningLocked(func(sess *Session) bool { return sess.ConnectionID == connID })
+	var removed []string
 	for id, sess := range m.byID {
 		if sess.ConnectionID != connID {
 			continue
@@ -359,20 +559,22 @@ func (m *Manager) RemoveForConnection(connID string) int {
 		key := sess.AccountID + ":" + sess.ConnectionID
 		delete(m.byKey, key)
 		delete(m.byID, id)
-		removed++
+		removed = append(removed, id)
 	}
 	m.mu.Unlock()
-	if removed > 0 {
+	if len(removed) > 0 {
+		m.notifySessionsRemoved(removed)
 		m.notifyConnectionEmpty(connID)
 	}
-	return removed
+	return len(removed)
 }
 
 // RemoveForAccount closes and removes all live sessions owned by accountID.
 func (m *Manager) RemoveForAccount(accountID string) int {
 	m.mu.Lock()
+	m.discardOpeningLocked(func(sess *Session) bool { return sess.AccountID == accountID })
 	connectionIDs := make(map[string]struct{})
-	removed := 0
+	var removed []string
 	for id, sess := range m.byID {
 		if sess.AccountID != accountID {
 			continue
@@ -381,20 +583,22 @@ func (m *Manager) RemoveForAccount(accountID string) int {
 		key := sess.AccountID + ":" + sess.ConnectionID
 		delete(m.byKey, key)
 		delete(m.byID, id)
-		removed++
+		removed = append(removed, id)
 		connectionIDs[sess.ConnectionID] = struct{}{}
 	}
 	m.mu.Unlock()
+	m.notifySessionsRemoved(removed)
 	m.notifyConnectionsEmpty(connectionIDs)
-	return removed
+	return len(removed)
 }
 
 // RemoveForWorkspaceAccount closes and removes all live sessions for an account
 // inside one workspace.
 func (m *Manager) RemoveForWorkspaceAccount(workspaceID, accountID string) int {
 	m.mu.Lock()
+	m.discardOpeningLocked(func(sess *Session) bool { return sess.WorkspaceID == workspaceID && sess.AccountID == accountID })
 	connectionIDs := make(map[string]struct{})
-	removed := 0
+	var removed []string
 	for id, sess := range m.byID {
 		if sess.WorkspaceID != workspaceID || sess.AccountID != accountID {
 			continue
@@ -403,20 +607,22 @@ func (m *Manager) RemoveForWorkspaceAccount(workspaceID, accountID string) int {
 		key := sess.AccountID + ":" + sess.ConnectionID
 		delete(m.byKey, key)
 		delete(m.byID, id)
-		removed++
+		removed = append(removed, id)
 		connectionIDs[sess.ConnectionID] = struct{}{}
 	}
 	m.mu.Unlock()
+	m.notifySessionsRemoved(removed)
 	m.notifyConnectionsEmpty(connectionIDs)
-	return removed
+	return len(removed)
 }
 
 // RemoveForOrgAccount closes and removes all live sessions for an account in
 // an organization.
 func (m *Manager) RemoveForOrgAccount(orgID, accountID string) int {
 	m.mu.Lock()
+	m.discardOpeningLocked(func(sess *Session) bool { return sess.OrgID == orgID && sess.AccountID == accountID })
 	connectionIDs := make(map[string]struct{})
-	removed := 0
+	var removed []string
 	for id, sess := range m.byID {
 		if sess.OrgID != orgID || sess.AccountID != accountID {
 			continue
@@ -425,12 +631,13 @@ func (m *Manager) RemoveForOrgAccount(orgID, accountID string) int {
 		key := sess.AccountID + ":" + sess.ConnectionID
 		delete(m.byKey, key)
 		delete(m.byID, id)
-		removed++
+		removed = append(removed, id)
 		connectionIDs[sess.ConnectionID] = struct{}{}
 	}
 	m.mu.Unlock()
+	m.notifySessionsRemoved(removed)
 	m.notifyConnectionsEmpty(connectionIDs)
-	return removed
+	return len(removed)
 }
 
 // Close closes all sessions and stops the reaper goroutine. Safe to call multiple times.
@@ -441,15 +648,19 @@ func (m *Manager) Close() {
 	<-m.stopped
 
 	m.mu.Lock()
+	m.discardOpeningLocked(func(*Session) bool { return true })
 	connectionIDs := make(map[string]struct{})
+	var removed []string
 	for id, sess := range m.byID {
 		sess.close()
 		key := sess.AccountID + ":" + sess.ConnectionID
 		delete(m.byKey, key)
 		delete(m.byID, id)
+		removed = append(removed, id)
 		connectionIDs[sess.ConnectionID] = struct{}{}
 	}
 	m.mu.Unlock()
+	m.notifySessionsRemoved(removed)
 	m.notifyConnectionsEmpty(connectionIDs)
 }
 
@@ -470,6 +681,7 @@ func (m *Manager) reap() {
 func (m *Manager) reapIdle() {
 	m.mu.Lock()
 	connectionIDs := make(map[string]struct{})
+	var removed []string
 	now := time.Now()
 	for id, sess := range m.byID {
 		if now.Sub(sess.lastUsed) > m.idleTimeout {
@@ -477,13 +689,27 @@ func (m *Manager) reapIdle() {
 			key := sess.AccountID + ":" + sess.ConnectionID
 			delete(m.byKey, key)
 			delete(m.byID, id)
+			removed = append(removed, id)
 			connectionIDs[sess.ConnectionID] = struct{}{}
 		}
 	}
 	m.mu.Unlock()
+	m.notifySessionsRemoved(removed)
 	m.notifyConnectionsEmpty(connectionIDs)
 }
 
+func (m *Manager) notifySessionsRemoved(sessionIDs []string) {
+	if len(sessionIDs) == 0 {
+		return
+	}
+	m.mu.RLock()
+	hook := m.onSessionsRemoved
+	m.mu.RUnlock()
+	if hook != nil {
+		hook(sessionIDs)
+	}
+}
+
 func (m *Manager) notifyConnectionsEmpty(connectionIDs map[string]struct{}) {
 	for connectionID := range connectionIDs {
 		m.notifyConnectionEmpty(connectionID)
@@ -508,4 +734,11 @@ func (m *Manager) notifyConnectionEmpty(connectionID string) {
 func (s *Session) close() {
 	s.CloseAllCursors()
 	_ = s.Conn.Close()
+	s.closeReplicas()
+	_ = s.tunnel.Close()
+	if s.lease != nil {
+		// Revoking a lease is a network call and sessions close under the
+		// manager lock. A lease that is never revoked still expires.
+		go func() { _ = s.lease.Close() }()
+	}
 }
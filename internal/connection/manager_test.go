@@ -2,6 +2,8 @@ package connection
 
 import (
 	"context"
+	"errors"
+	"io"
 	"sync"
 	"testing"
 	"time"
@@ -47,6 +49,14 @@ func (d *mockDriver) Execute(ctx context.Context, sql string, args ...any) (*res
 }
 func (d *mockDriver) Dialect() engine.Dialect { return engine.DialectSQLite }
 
+// openDriver adapts open for test sessions without a tunnel or lease.
+func openDriver(open func() (engine.Driver, error)) func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+	return func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+		d, err := open()
+		return d, nil, err
+	}
+}
+
 // TestReuse verifies that calling GetOrCreate twice for the same account+conn returns the same session.
 func TestReuse(t *testing.T) {
 	m := New(5 * time.Minute)
@@ -59,7 +69,7 @@ func TestReuse(t *testing.T) {
 		return d, nil
 	}
 
-	sess1, created1, err := m.GetOrCreate("alice", "conn1", open)
+	sess1, created1, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -67,7 +77,7 @@ func TestReuse(t *testing.T) {
 		t.Fatal("expected created=true on first call")
 	}
 
-	sess2, created2, err := m.GetOrCreate("alice", "conn1", open)
+	sess2, created2, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -93,12 +103,12 @@ func TestIsolation(t *testing.T) {
 		return &mockDriver{}, nil
 	}
 
-	sessAlice, _, err := m.GetOrCreate("alice", "conn1", open)
+	sessAlice, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	sessBob, _, err := m.GetOrCreate("bob", "conn1", open)
+	sessBob, _, err := m.GetOrCreate(context.Background(), SessionKey{"bob", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -117,7 +127,7 @@ func TestGetByID(t *testing.T) {
 		return &mockDriver{}, nil
 	}
 
-	sess, _, err := m.GetOrCreate("alice", "conn1", open)
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -155,7 +165,7 @@ func TestReapIdle(t *testing.T) {
 		return md, nil
 	}
 
-	sess, _, err := m.GetOrCreate("alice", "conn1", open)
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -190,7 +200,7 @@ func TestClose(t *testing.T) {
 		return md, nil
 	}
 
-	sess, _, err := m.GetOrCreate("alice", "conn1", open)
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -222,7 +232,7 @@ func TestRemove(t *testing.T) {
 		return md, nil
 	}
 
-	sess, _, err := m.GetOrCreate("alice", "conn1", open)
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -244,6 +254,45 @@ func TestRemove(t *testing.T) {
 	}
 }
 
+// closerFunc adapts a function to io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestRemoveClosesSessionLease(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+
+	released := make(chan struct{})
+	lease := closerFunc(func() error {
+		close(released)
+		return nil
+	})
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+		return &mockDriver{}, lease, nil
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Reusing the session keeps the lease.
+	if _, created, _ := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(func() (engine.Driver, error) { return &mockDriver{}, nil })}); created {
+		t.Fatal("expected the leased session to be reused")
+	}
+	select {
+	case <-released:
+		t.Fatal("lease released while the session is open")
+	default:
+	}
+
+	m.Remove(sess.ID)
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the lease to be released after Remove")
+	}
+}
+
 func TestCountAndRemoveForConnection(t *testing.T) {
 	m := New(5 * time.Minute)
 	defer m.Close()
@@ -252,15 +301,15 @@ func TestCountAndRemoveForConnection(t *testing.T) {
 		return &mockDriver{}, nil
 	}
 
-	sessAlice, _, err := m.GetOrCreate("alice", "conn1", open)
+	sessAlice, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	sessBob, _, err := m.GetOrCreate("bob", "conn1", open)
+	sessBob, _, err := m.GetOrCreate(context.Background(), SessionKey{"bob", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	_, _, err = m.GetOrCreate("charlie", "conn2", open)
+	_, _, err = m.GetOrCreate(context.Background(), SessionKey{"charlie", "conn2"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -291,6 +340,37 @@ func TestCountAndRemoveForConnection(t *testing.T) {
 	}
 }
 
+func TestRemoveForAccountConnection(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+
+	open := func() (engine.Driver, error) {
+		return &mockDriver{}, nil
+	}
+
+	sessAlice, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sessBob, _, err := m.GetOrCreate(context.Background(), SessionKey{"bob", "conn1"}, SessionOptions{Open: openDriver(open)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !m.RemoveForAccountConnection("alice", "conn1") {
+		t.Fatal("expected alice conn1 session to be removed")
+	}
+	if m.RemoveForAccountConnection("alice", "conn1") {
+		t.Fatal("expected no alice conn1 session left to remove")
+	}
+	if _, ok := m.Get(sessAlice.ID); ok {
+		t.Fatal("expected alice conn1 session to be gone")
+	}
+	if _, ok := m.Get(sessBob.ID); !ok {
+		t.Fatal("expected bob conn1 session to remain")
+	}
+}
+
 func TestSessionQueryAndExecuteUpdateLastUsed(t *testing.T) {
 	m := New(5 * time.Minute)
 	defer m.Close()
@@ -300,7 +380,7 @@ func TestSessionQueryAndExecuteUpdateLastUsed(t *testing.T) {
 		return md, nil
 	}
 
-	sess, _, err := m.GetOrCreate("alice", "conn1", open)
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -387,9 +467,9 @@ func TestRemoveClosesActiveCursorsBeforeDriver(t *testing.T) {
 	defer m.Close()
 
 	md := &mockCursorDriver{}
-	sess, _, err := m.GetOrCreate("alice", "conn1", func() (engine.Driver, error) {
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(func() (engine.Driver, error) {
 		return md, nil
-	})
+	})})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -407,20 +487,97 @@ func TestRemoveClosesActiveCursorsBeforeDriver(t *testing.T) {
 	}
 }
 
+func TestGetOrCreateOpensOutsideTheLock(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+
+	release := make(chan struct{})
+	opening := make(chan struct{})
+	var opens sync.WaitGroup
+	var mu sync.Mutex
+	openCount := 0
+	slow := openDriver(func() (engine.Driver, error) {
+		mu.Lock()
+		openCount++
+		mu.Unlock()
+		close(opening)
+		<-release
+		return &mockDriver{}, nil
+	})
+	sessions := make([]*Session, 3)
+	for i := range sessions {
+		opens.Go(func() {
+			sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "slow"}, SessionOptions{Open: slow})
+			if err != nil {
+				t.Error(err)
+			}
+			sessions[i] = sess
+		})
+	}
+	<-opening
+
+	// Another key opens while the slow one is still connecting.
+	if _, created, err := m.GetOrCreate(context.Background(), SessionKey{"bob", "fast"}, SessionOptions{Open: openDriver(func() (engine.Driver, error) {
+		return &mockDriver{}, nil
+	})}); err != nil || !created {
+		t.Fatalf("GetOrCreate for another key = %v, %v", created, err)
+	}
+	if m.Count() != 1 {
+		t.Fatalf("Count = %d while a session is opening, want 1", m.Count())
+	}
+
+	close(release)
+	opens.Wait()
+	if openCount != 1 {
+		t.Fatalf("opened %d times, want callers for one key to share one open", openCount)
+	}
+	if sessions[0] == nil || sessions[0] != sessions[1] || sessions[1] != sessions[2] {
+		t.Fatal("expected every caller to get the same session")
+	}
+}
+
+func TestRemovalDiscardsSessionStillOpening(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+
+	md := &mockDriver{}
+	release := make(chan struct{})
+	opening := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(func() (engine.Driver, error) {
+			close(opening)
+			<-release
+			return md, nil
+		})})
+		done <- err
+	}()
+	<-opening
+	m.RemoveForAccount("alice")
+	close(release)
+
+	if err := <-done; !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("err = %v, want ErrSessionClosed", err)
+	}
+	if !md.closed || m.Count() != 0 {
+		t.Fatalf("closed=%v count=%d, want the discarded session closed and unregistered", md.closed, m.Count())
+	}
+}
+
 func TestConnectionEmptyHookRunsOnlyAfterLastSession(t *testing.T) {
 	m := New(5 * time.Minute)
 	defer m.Close()
 	var notified []string
 	m.SetOnConnectionEmpty(func(connectionID string) { notified = append(notified, connectionID) })
-	first, _, err := m.GetOrCreate("alice", "conn1", func() (engine.Driver, error) {
+	first, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(func() (engine.Driver, error) {
 		return &mockDriver{}, nil
-	})
+	})})
 	if err != nil {
 		t.Fatal(err)
 	}
-	second, _, err := m.GetOrCreate("bob", "conn1", func() (engine.Driver, error) {
+	second, _, err := m.GetOrCreate(context.Background(), SessionKey{"bob", "conn1"}, SessionOptions{Open: openDriver(func() (engine.Driver, error) {
 		return &mockDriver{}, nil
-	})
+	})})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -434,6 +591,29 @@ func TestConnectionEmptyHookRunsOnlyAfterLastSession(t *testing.T) {
 	}
 }
 
+func TestSessionsRemovedHookReportsEveryRemoval(t *testing.T) {
+	m := New(5 * time.Minute)
+	var removed []string
+	m.SetOnSessionsRemoved(func(sessionIDs []string) { removed = append(removed, sessionIDs...) })
+	open := func() (engine.Driver, error) { return &mockDriver{}, nil }
+	first, _, _ := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Open: openDriver(open)})
+	second, _, _ := m.GetOrCreate(context.Background(), SessionKey{"bob", "conn1"}, SessionOptions{Open: openDriver(open)})
+	third, _, _ := m.GetOrCreate(context.Background(), SessionKey{"carol", "conn2"}, SessionOptions{Open: openDriver(open)})
+	if m.Count() != 3 {
+		t.Fatalf("Count = %d, want 3", m.Count())
+	}
+
+	m.Remove(first.ID)
+	m.RemoveForConnection("conn1")
+	if len(removed) != 2 || removed[0] != first.ID || removed[1] != second.ID {
+		t.Fatalf("removed = %v", removed)
+	}
+	m.Close()
+	if len(removed) != 3 || removed[2] != third.ID || m.Count() != 0 {
+		t.Fatalf("removed after Close = %v", removed)
+	}
+}
+
 func TestQueryCursorManagerRemoveClosesSessionCursor(t *testing.T) {
 	md := &mockCursorDriver{}
 	sess := &Session{Conn: md}
@@ -0,0 +1,338 @@
+package connection
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/cursor"
+	"github.com/sqlwarden/pkg/result"
+)
+
+// PrimaryEndpoint names the connection's own server in results and session
+// state; replicas use the names they were configured with.
+const PrimaryEndpoint = "primary"
+
+const (
+	// replicaCheckInterval is how long a replica may sit unused before it is
+	// pinged again ahead of the next read routed to it.
+	replicaCheckInterval = 30 * time.Second
+	replicaMinBackoff    = 5 * time.Second
+	replicaMaxBackoff    = 5 * time.Minute
+)
+
+// ReplicaSpec describes a read replica a session can route reads to. Open
+// connects to it, dialing through dial when the session has an SSH tunnel.
+type ReplicaSpec struct {
+	Name string
+	Open func(ctx context.Context, dial engine.DialFunc) (engine.Driver, error)
+}
+
+// EndpointStatus is the health of one endpoint of a session as last observed.
+type EndpointStatus struct {
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Healthy   bool      `json:"healthy"`
+	Connected bool      `json:"connected"`
+	DownUntil time.Time `json:"down_until,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// replica is one read replica of a session. It connects on first use; a
+// replica that fails to connect or answer a ping is skipped with exponential
+// backoff and retried afterwards. All fields are guarded by the session's
+// statusMu; conn is only used while the session's mu is held.
+type replica struct {
+	spec      ReplicaSpec
+	conn      engine.Driver
+	checkedAt time.Time
+	failures  int
+	downUntil time.Time
+	lastError string
+}
+
+// HasReplicas reports whether the session can route reads to replicas.
+func (s *Session) HasReplicas() bool {
+	return len(s.replicas) > 0
+}
+
+// InTransaction reports whether an explicit transaction is open on the
+// primary, as far as the statements run on it tell.
+func (s *Session) InTransaction() bool {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.inTx
+}
+
+// LastEndpoint returns the endpoint that served the session's most recent
+// statement. It is empty before the first one and for sessions without
+// replicas.
+func (s *Session) LastEndpoint() string {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.lastEndpoint
+}
+
+// Endpoints reports the primary and every replica of the session.
+func (s *Session) Endpoints() []EndpointStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	statuses := []EndpointStatus{{Name: PrimaryEndpoint, Role: "primary", Healthy: true, Connected: true}}
+	now := time.Now()
+	for _, r := range s.replicas {
+		status := EndpointStatus{
+			Name:      r.spec.Name,
+			Role:      "replica",
+			Healthy:   !now.Before(r.downUntil),
+			Connected: r.conn != nil,
+			LastError: r.lastError,
+		}
+		if !status.Healthy {
+			status.DownUntil = r.downUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ReadWithOptions runs a read-only query on the next healthy replica, round
+// robin, and falls back to the primary when none is available or an explicit
+// transaction is open there. A replica that fails a query and then its ping
+// is marked down and the query moves on; a query error from a healthy replica
+// is returned as is.
+func (s *Session) ReadWithOptions(ctx context.Context, sql string, opts cursor.ScanOptions, args ...any) (*result.ResultSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+
+	if !s.InTransaction() {
+		for range s.replicas {
+			r := s.pickReplica(ctx)
+			if r == nil {
+				break
+			}
+			rs, err := queryWithOptions(ctx, r.conn, sql, opts, args...)
+			if err == nil {
+				s.served(r.spec.Name, rs)
+				return rs, nil
+			}
+			if ctx.Err() != nil || r.conn.Ping(ctx) == nil {
+				return nil, err
+			}
+			s.markReplicaDown(r, err)
+		}
+	}
+	return s.onPrimary(sql, func() (*result.ResultSet, error) {
+		return queryWithOptions(ctx, s.Conn, sql, opts, args...)
+	})
+}
+
+// StartReadCursor starts a query cursor for a read-only query, on a replica
+// when one is healthy and no explicit transaction is open, like
+// ReadWithOptions.
+func (s *Session) StartReadCursor(ctx context.Context, sql string, args ...any) (*QueryCursorHandle, error) {
+	if _, ok := s.Conn.(cursor.QueryCursorDriver); !ok {
+		return nil, ErrQueryCursorsUnsupported
+	}
+	if s.HasReplicas() && !s.InTransaction() {
+		s.mu.Lock()
+		for range s.replicas {
+			r := s.pickReplica(ctx)
+			if r == nil {
+				break
+			}
+			qc, err := r.conn.(cursor.QueryCursorDriver).StartQuery(ctx, cursor.QueryRequest{SQL: sql, Args: args})
+			if err == nil {
+				s.mu.Unlock()
+				return s.addCursor(qc, r.spec.Name), nil
+			}
+			if ctx.Err() != nil || r.conn.Ping(ctx) == nil {
+				s.mu.Unlock()
+				return nil, err
+			}
+			s.markReplicaDown(r, err)
+		}
+		s.mu.Unlock()
+	}
+	return s.StartQueryCursor(ctx, sql, args...)
+}
+
+// pickReplica returns the next healthy replica, connecting or pinging it
+// first when needed, or nil when every replica is down. The caller holds mu.
+func (s *Session) pickReplica(ctx context.Context) *replica {
+	for i := range s.replicas {
+		index := (s.nextReplica + i) % len(s.replicas)
+		r := s.replicas[index]
+
+		s.statusMu.Lock()
+		down := time.Now().Before(r.downUntil)
+		due := r.conn == nil || time.Since(r.checkedAt) > replicaCheckInterval
+		s.statusMu.Unlock()
+		if down {
+			continue
+		}
+		if due {
+			if err := s.checkReplica(ctx, r); err != nil {
+				s.markReplicaDown(r, err)
+				continue
+			}
+		}
+		s.nextReplica = (index + 1) % len(s.replicas)
+		return r
+	}
+	return nil
+}
+
+// checkReplica connects r when it has no connection yet and pings it
+// otherwise. The caller holds mu.
+func (s *Session) checkReplica(ctx context.Context, r *replica) error {
+	if r.conn == nil {
+		conn, err := r.spec.Open(ctx, s.tunnel.Dialer())
+		if err != nil {
+			return err
+		}
+		if err := runSessionInit(ctx, conn, s.init); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		s.statusMu.Lock()
+		r.conn = conn
+		s.statusMu.Unlock()
+	} else if err := r.conn.Ping(ctx); err != nil {
+		return err
+	}
+	s.statusMu.Lock()
+	r.checkedAt = time.Now()
+	r.failures = 0
+	r.lastError = ""
+	s.statusMu.Unlock()
+	return nil
+}
+
+// markReplicaDown closes r and skips it until its backoff has passed. The
+// caller holds mu.
+func (s *Session) markReplicaDown(r *replica, err error) {
+	s.statusMu.Lock()
+	conn := r.conn
+	r.conn = nil
+	r.failures++
+	backoff := min(replicaMinBackoff<<min(r.failures-1, 10), replicaMaxBackoff)
+	r.downUntil = time.Now().Add(backoff)
+	r.lastError = err.Error()
+	s.statusMu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+func (s *Session) closeReplicas() {
+	for _, r := range s.replicas {
+		s.statusMu.Lock()
+		conn := r.conn
+		r.conn = nil
+		s.statusMu.Unlock()
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+// served records endpoint as the one that answered the latest statement and
+// stamps rs with it, when the session has replicas to tell apart.
+func (s *Session) served(endpoint string, rs *result.ResultSet) {
+	if !s.HasReplicas() {
+		return
+	}
+	s.statusMu.Lock()
+	s.lastEndpoint = endpoint
+	s.statusMu.Unlock()
+	if rs != nil {
+		rs.Endpoint = endpoint
+	}
+}
+
+// trackTransaction follows explicit transactions opened and closed on the
+// primary so reads inside one stay there.
+func (s *Session) trackTransaction(sql string) {
+	switch transactionEffect(sql) {
+	case txOpened:
+		s.statusMu.Lock()
+		s.inTx = true
+		s.statusMu.Unlock()
+	case txClosed:
+		s.statusMu.Lock()
+		s.inTx = false
+		s.statusMu.Unlock()
+	}
+}
+
+func queryWithOptions(ctx context.Context, conn engine.Driver, sql string, opts cursor.ScanOptions, args ...any) (*result.ResultSet, error) {
+	if driver, ok := conn.(cursor.ResultLimitDriver); ok {
+		return driver.QueryWithOptions(ctx, sql, opts, args...)
+	}
+	return conn.Query(ctx, sql, args...)
+}
+
+type txEffect int
+
+const (
+	txUnchanged txEffect = iota
+	txOpened
+	txClosed
+)
+
+// transactionEffect reports whether sql leaves an explicit transaction open
+// or closed, judged by the leading keywords of its statements; the last
+// statement that begins or ends one wins. Statements are split on semicolons
+// without parsing, so a keyword inside a literal may be misread; a misread
+// open transaction only keeps reads on the primary.
+func transactionEffect(sql string) txEffect {
+	effect := txUnchanged
+	for statement := range strings.SplitSeq(sql, ";") {
+		words := strings.Fields(strings.ToUpper(stripLeadingComments(statement)))
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "BEGIN":
+			effect = txOpened
+		case "START":
+			if len(words) > 1 && words[1] == "TRANSACTION" {
+				effect = txOpened
+			}
+		case "COMMIT", "END", "ABORT":
+			if len(words) == 1 || words[1] != "PREPARED" {
+				effect = txClosed
+			}
+		case "ROLLBACK":
+			// ROLLBACK TO SAVEPOINT keeps the transaction open.
+			if len(words) == 1 || (words[1] != "TO" && words[1] != "PREPARED" && !(len(words) > 2 && words[2] == "TO")) {
+				effect = txClosed
+			}
+		}
+	}
+	return effect
+}
+
+func stripLeadingComments(statement string) string {
+	for {
+		statement = strings.TrimSpace(statement)
+		switch {
+		case strings.HasPrefix(statement, "--"):
+			end := strings.IndexByte(statement, '\n')
+			if end < 0 {
+				return ""
+			}
+			statement = statement[end+1:]
+		case strings.HasPrefix(statement, "/*"):
+			end := strings.Index(statement, "*/")
+			if end < 0 {
+				return ""
+			}
+			statement = statement[end+2:]
+		default:
+			return statement
+		}
+	}
+}
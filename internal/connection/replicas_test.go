@@ -0,0 +1,238 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/cursor"
+	"github.com/sqlwarden/pkg/result"
+)
+
+// downDriver fails every query and ping, like a replica that went away after
+// it was connected.
+type downDriver struct {
+	mockDriver
+}
+
+func (d *downDriver) Ping(context.Context) error { return errors.New("connection reset") }
+func (d *downDriver) Query(context.Context, string, ...any) (*result.ResultSet, error) {
+	return nil, errors.New("connection reset")
+}
+
+func replicaSpec(name string, driver engine.Driver, openErr error) ReplicaSpec {
+	return ReplicaSpec{Name: name, Open: func(context.Context, engine.DialFunc) (engine.Driver, error) {
+		if openErr != nil {
+			return nil, openErr
+		}
+		return driver, nil
+	}}
+}
+
+func newReplicaSession(t *testing.T, primary engine.Driver, replicas ...ReplicaSpec) *Session {
+	t.Helper()
+	m := New(5 * time.Minute)
+	t.Cleanup(m.Close)
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{
+		Replicas: replicas,
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+			return primary, nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
+
+func read(t *testing.T, sess *Session) string {
+	t.Helper()
+	rs, err := sess.ReadWithOptions(context.Background(), "SELECT 1", cursor.ScanOptions{})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return rs.Endpoint
+}
+
+func TestReadsRoundRobinAcrossReplicas(t *testing.T) {
+	primary, west, east := &mockDriver{}, &mockDriver{}, &mockDriver{}
+	sess := newReplicaSession(t, primary, replicaSpec("west", west, nil), replicaSpec("east", east, nil))
+
+	var got []string
+	for range 4 {
+		got = append(got, read(t, sess))
+	}
+	want := []string{"west", "east", "west", "east"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("endpoints = %v, want %v", got, want)
+		}
+	}
+	if primary.querys != 0 || west.querys != 2 || east.querys != 2 {
+		t.Fatalf("queries primary=%d west=%d east=%d", primary.querys, west.querys, east.querys)
+	}
+
+	rs, err := sess.Execute(context.Background(), "UPDATE t SET a = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Endpoint != PrimaryEndpoint || sess.LastEndpoint() != PrimaryEndpoint {
+		t.Fatalf("execute endpoint = %q, last = %q", rs.Endpoint, sess.LastEndpoint())
+	}
+}
+
+func TestReadsFailOverToHealthyEndpoints(t *testing.T) {
+	primary, east := &mockDriver{}, &mockDriver{}
+	sess := newReplicaSession(t, primary,
+		replicaSpec("west", nil, errors.New("connection refused")),
+		replicaSpec("east", east, nil),
+	)
+
+	if got := read(t, sess); got != "east" {
+		t.Fatalf("endpoint = %q, want east", got)
+	}
+	if got := read(t, sess); got != "east" {
+		t.Fatalf("endpoint = %q, want east while west backs off", got)
+	}
+	statuses := sess.Endpoints()
+	if len(statuses) != 3 || statuses[1].Name != "west" || statuses[1].Healthy || statuses[1].DownUntil.IsZero() {
+		t.Fatalf("endpoints = %+v, want west down", statuses)
+	}
+
+	// A replica that stops answering mid-session is dropped for the primary.
+	sess.replicas[1].conn = &downDriver{}
+	if got := read(t, sess); got != PrimaryEndpoint {
+		t.Fatalf("endpoint = %q, want primary", got)
+	}
+	if sess.replicas[1].conn != nil || sess.Endpoints()[2].Healthy {
+		t.Fatal("expected east to be marked down")
+	}
+}
+
+func TestReplicaQueryErrorIsReturnedWhenReplicaIsHealthy(t *testing.T) {
+	primary := &mockDriver{}
+	replica := &failingQueryDriver{}
+	sess := newReplicaSession(t, primary, replicaSpec("west", replica, nil))
+
+	_, err := sess.ReadWithOptions(context.Background(), "SELECT missing", cursor.ScanOptions{})
+	if err == nil || err.Error() != "column does not exist" {
+		t.Fatalf("err = %v, want the replica's query error", err)
+	}
+	if primary.querys != 0 || !sess.Endpoints()[1].Healthy {
+		t.Fatal("a healthy replica's query error must not fail over")
+	}
+}
+
+type failingQueryDriver struct {
+	mockDriver
+}
+
+func (d *failingQueryDriver) Query(context.Context, string, ...any) (*result.ResultSet, error) {
+	return nil, errors.New("column does not exist")
+}
+
+func TestReadsStayOnPrimaryInsideTransaction(t *testing.T) {
+	primary, west := &mockDriver{}, &mockDriver{}
+	sess := newReplicaSession(t, primary, replicaSpec("west", west, nil))
+
+	if _, err := sess.Execute(context.Background(), "BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if !sess.InTransaction() {
+		t.Fatal("expected an open transaction after BEGIN")
+	}
+	if got := read(t, sess); got != PrimaryEndpoint {
+		t.Fatalf("endpoint = %q, want primary inside a transaction", got)
+	}
+	if _, err := sess.Execute(context.Background(), "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, sess); got != "west" {
+		t.Fatalf("endpoint = %q, want west after COMMIT", got)
+	}
+}
+
+func TestStartReadCursorUsesReplica(t *testing.T) {
+	primary, west := &mockCursorDriver{}, &mockCursorDriver{}
+	sess := newReplicaSession(t, primary, replicaSpec("west", west, nil))
+
+	handle, err := sess.StartReadCursor(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handle.Endpoint != "west" || len(west.cursors) != 1 || len(primary.cursors) != 0 {
+		t.Fatalf("cursor endpoint = %q, replica cursors = %d", handle.Endpoint, len(west.cursors))
+	}
+	rs, _, err := handle.Fetch(context.Background(), cursor.ScanOptions{MaxRows: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Endpoint != "west" {
+		t.Fatalf("page endpoint = %q, want west", rs.Endpoint)
+	}
+
+	primaryHandle, err := sess.StartQueryCursor(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primaryHandle.Endpoint != PrimaryEndpoint {
+		t.Fatalf("primary cursor endpoint = %q", primaryHandle.Endpoint)
+	}
+}
+
+func TestSessionCloseClosesReplicas(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+	west := &mockDriver{}
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{
+		Replicas: []ReplicaSpec{replicaSpec("west", west, nil)},
+		Open:     func(engine.DialFunc) (engine.Driver, io.Closer, error) { return &mockDriver{}, nil, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read(t, sess)
+	m.Remove(sess.ID)
+	if !west.closed {
+		t.Fatal("expected the replica connection to be closed with the session")
+	}
+}
+
+func TestSessionWithoutReplicasReportsNoEndpoint(t *testing.T) {
+	sess := newReplicaSession(t, &mockDriver{})
+	if got := read(t, sess); got != "" {
+		t.Fatalf("endpoint = %q, want none without replicas", got)
+	}
+	if sess.LastEndpoint() != "" {
+		t.Fatalf("last endpoint = %q", sess.LastEndpoint())
+	}
+}
+
+func TestTransactionEffect(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want txEffect
+	}{
+		{"SELECT 1", txUnchanged},
+		{"BEGIN", txOpened},
+		{"begin transaction isolation level serializable", txOpened},
+		{"START TRANSACTION READ ONLY", txOpened},
+		{"-- open\nBEGIN;", txOpened},
+		{"/* tx */ COMMIT", txClosed},
+		{"END", txClosed},
+		{"ROLLBACK", txClosed},
+		{"ROLLBACK TO SAVEPOINT s1", txUnchanged},
+		{"ROLLBACK WORK TO s1", txUnchanged},
+		{"COMMIT PREPARED 'gx'", txUnchanged},
+		{"BEGIN; UPDATE t SET a = 1; COMMIT", txClosed},
+		{"UPDATE t SET a = 1; BEGIN", txOpened},
+	}
+	for _, tt := range tests {
+		if got := transactionEffect(tt.sql); got != tt.want {
+			t.Errorf("transactionEffect(%q) = %d, want %d", tt.sql, got, tt.want)
+		}
+	}
+}
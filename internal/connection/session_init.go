@@ -0,0 +1,34 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+// SessionInitError reports the session initialization statement that failed
+// on a new connection.
+type SessionInitError struct {
+	// Index is the position of the statement in the session's init list.
+	Index     int
+	Statement string
+	Err       error
+}
+
+func (e *SessionInitError) Error() string {
+	return fmt.Sprintf("session init statement %d: %v", e.Index+1, e.Err)
+}
+
+func (e *SessionInitError) Unwrap() error { return e.Err }
+
+// runSessionInit executes init on conn in order and stops at the first
+// statement that fails.
+func runSessionInit(ctx context.Context, conn engine.Driver, init []string) error {
+	for i, statement := range init {
+		if _, err := conn.Execute(ctx, statement); err != nil {
+			return &SessionInitError{Index: i, Statement: statement, Err: err}
+		}
+	}
+	return nil
+}
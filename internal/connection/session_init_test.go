@@ -0,0 +1,105 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/pkg/result"
+)
+
+// initDriver records the statements executed on it and fails the one that
+// matches failOn.
+type initDriver struct {
+	mockDriver
+	executed []string
+	failOn   string
+}
+
+func (d *initDriver) Execute(_ context.Context, sql string, _ ...any) (*result.ResultSet, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if sql == d.failOn {
+		return nil, errors.New("permission denied to set role")
+	}
+	d.executed = append(d.executed, sql)
+	return &result.ResultSet{}, nil
+}
+
+func TestSessionInitRunsOnPrimaryAndReplicas(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+
+	init := []string{"SET ROLE reporting", "SET search_path = reporting"}
+	primary := &initDriver{}
+	replica := &initDriver{}
+	opens := 0
+	open := func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+		opens++
+		return primary, nil, nil
+	}
+	sess, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{
+		Replicas: []ReplicaSpec{replicaSpec("replica-1", replica, nil)},
+		Init:     init,
+		Open:     open,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(primary.executed, init) {
+		t.Fatalf("primary ran %q, want %q", primary.executed, init)
+	}
+	if len(replica.executed) != 0 {
+		t.Fatal("replica initialized before it connected")
+	}
+
+	if got := read(t, sess); got != "replica-1" {
+		t.Fatalf("read served by %q, want replica-1", got)
+	}
+	if !slices.Equal(replica.executed, init) {
+		t.Fatalf("replica ran %q, want %q", replica.executed, init)
+	}
+
+	// A reused session does not run init again.
+	if _, created, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{Init: init, Open: open}); err != nil || created {
+		t.Fatalf("reuse: created=%v err=%v", created, err)
+	}
+	if opens != 1 || len(primary.executed) != len(init) {
+		t.Fatalf("reuse reopened or re-initialized the session: opens=%d executed=%q", opens, primary.executed)
+	}
+}
+
+func TestSessionInitFailureClosesConnection(t *testing.T) {
+	m := New(5 * time.Minute)
+	defer m.Close()
+
+	primary := &initDriver{failOn: "SET ROLE reporting"}
+	released := false
+	lease := closerFunc(func() error {
+		released = true
+		return nil
+	})
+	_, _, err := m.GetOrCreate(context.Background(), SessionKey{"alice", "conn1"}, SessionOptions{
+		Init: []string{"SET search_path = reporting", "SET ROLE reporting"},
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+			return primary, lease, nil
+		},
+	})
+	var initErr *SessionInitError
+	if !errors.As(err, &initErr) {
+		t.Fatalf("expected SessionInitError, got %v", err)
+	}
+	if initErr.Index != 1 || initErr.Statement != "SET ROLE reporting" {
+		t.Fatalf("unexpected failed statement: %d %q", initErr.Index, initErr.Statement)
+	}
+	if !primary.closed || !released {
+		t.Fatalf("expected the driver and lease closed, got closed=%v released=%v", primary.closed, released)
+	}
+	if m.CountForConnection("conn1") != 0 {
+		t.Fatal("expected no session after a failed init")
+	}
+}
@@ -0,0 +1,210 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+// DefaultTunnelPort is the SSH port used when a tunnel does not set one.
+const DefaultTunnelPort = 22
+
+// tunnelHandshakeTimeout bounds the SSH handshake when the caller's context
+// has no deadline.
+const tunnelHandshakeTimeout = 15 * time.Second
+
+// Tunnel failure stages reported by TunnelError.
+const (
+	TunnelStageConfig    = "config"    // the stored key or settings are unusable
+	TunnelStageDial      = "dial"      // the jump host could not be reached
+	TunnelStageHostKey   = "host_key"  // the jump host presented an unexpected key
+	TunnelStageAuth      = "auth"      // the jump host rejected the credentials
+	TunnelStageHandshake = "handshake" // any other SSH protocol failure
+	TunnelStageForward   = "forward"   // the jump host could not reach the database
+)
+
+var ErrHostKeyMismatch = errors.New("host key fingerprint does not match")
+
+// TunnelConfig describes an SSH jump host used to reach a database. Exactly one
+// of PrivateKey and Password authenticates User. HostKeyFingerprint pins the
+// jump host's key in the "SHA256:..." form printed by ssh-keygen -lf.
+type TunnelConfig struct {
+	Host               string `json:"host"`
+	Port               int    `json:"port,omitempty"`
+	User               string `json:"user"`
+	PrivateKey         string `json:"private_key,omitempty"`
+	Passphrase         string `json:"passphrase,omitempty"`
+	Password           string `json:"password,omitempty"`
+	HostKeyFingerprint string `json:"host_key_fingerprint"`
+	// Dial, when set, replaces the default dialer for reaching the jump host.
+	Dial engine.DialFunc `json:"-"`
+}
+
+// Address returns the host:port of the jump host.
+func (c TunnelConfig) Address() string {
+	port := c.Port
+	if port == 0 {
+		port = DefaultTunnelPort
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// Equal reports whether c and other describe the same jump host and
+// credentials. Dial is not compared.
+func (c TunnelConfig) Equal(other TunnelConfig) bool {
+	return c.Host == other.Host &&
+		c.Port == other.Port &&
+		c.User == other.User &&
+		c.PrivateKey == other.PrivateKey &&
+		c.Passphrase == other.Passphrase &&
+		c.Password == other.Password &&
+		c.HostKeyFingerprint == other.HostKeyFingerprint
+}
+
+// HasSecret reports whether the config carries a key or password.
+func (c TunnelConfig) HasSecret() bool {
+	return c.PrivateKey != "" || c.Password != ""
+}
+
+// TunnelError reports which stage of opening or using a tunnel failed.
+type TunnelError struct {
+	Stage string
+	Err   error
+}
+
+func (e *TunnelError) Error() string {
+	return fmt.Sprintf("ssh tunnel: %s: %v", e.Stage, e.Err)
+}
+
+func (e *TunnelError) Unwrap() error {
+	return e.Err
+}
+
+// ValidFingerprint reports whether fingerprint is a SHA256 host key
+// fingerprint.
+func ValidFingerprint(fingerprint string) bool {
+	digest, ok := strings.CutPrefix(strings.TrimSpace(fingerprint), "SHA256:")
+	digest = strings.TrimRight(digest, "=")
+	return ok && len(digest) == 43
+}
+
+// ValidatePrivateKey reports whether key parses, decrypting it with passphrase
+// when one is given.
+func ValidatePrivateKey(key, passphrase string) error {
+	_, err := parsePrivateKey(key, passphrase)
+	return err
+}
+
+func parsePrivateKey(key, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey([]byte(key))
+}
+
+// Tunnel is an open SSH client connection to a jump host. A nil *Tunnel is a
+// direct connection: Dialer returns nil and Close does nothing.
+type Tunnel struct {
+	client    *ssh.Client
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// OpenTunnel connects and authenticates to the jump host described by cfg,
+// verifying its host key against cfg.HostKeyFingerprint.
+func OpenTunnel(ctx context.Context, cfg TunnelConfig) (*Tunnel, error) {
+	var auth []ssh.AuthMethod
+	switch {
+	case cfg.PrivateKey != "":
+		signer, err := parsePrivateKey(cfg.PrivateKey, cfg.Passphrase)
+		if err != nil {
+			return nil, &TunnelError{Stage: TunnelStageConfig, Err: err}
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	case cfg.Password != "":
+		auth = append(auth, ssh.Password(cfg.Password))
+	default:
+		return nil, &TunnelError{Stage: TunnelStageConfig, Err: errors.New("no private key or password")}
+	}
+	if !ValidFingerprint(cfg.HostKeyFingerprint) {
+		return nil, &TunnelError{Stage: TunnelStageConfig, Err: errors.New("invalid host key fingerprint")}
+	}
+	want := strings.TrimRight(strings.TrimSpace(cfg.HostKeyFingerprint), "=")
+
+	addr := cfg.Address()
+	dial := cfg.Dial
+	if dial == nil {
+		var dialer net.Dialer
+		dial = dialer.DialContext
+	}
+	raw, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, &TunnelError{Stage: TunnelStageDial, Err: err}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(tunnelHandshakeTimeout)
+	}
+	_ = raw.SetDeadline(deadline)
+
+	clientConn, chans, reqs, err := ssh.NewClientConn(raw, addr, &ssh.ClientConfig{
+		User: cfg.User,
+		Auth: auth,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != want {
+				return ErrHostKeyMismatch
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		raw.Close()
+		stage := TunnelStageHandshake
+		switch {
+		case errors.Is(err, ErrHostKeyMismatch):
+			stage = TunnelStageHostKey
+		case strings.Contains(err.Error(), "unable to authenticate"):
+			stage = TunnelStageAuth
+		}
+		return nil, &TunnelError{Stage: stage, Err: err}
+	}
+	_ = raw.SetDeadline(time.Time{})
+	return &Tunnel{client: ssh.NewClient(clientConn, chans, reqs)}, nil
+}
+
+// DialContext opens a connection to addr from the jump host.
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := t.client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &TunnelError{Stage: TunnelStageForward, Err: err}
+	}
+	return conn, nil
+}
+
+// Dialer returns the dial function for engine.ConnectionConfig.Dial.
+func (t *Tunnel) Dialer() engine.DialFunc {
+	if t == nil {
+		return nil
+	}
+	return t.DialContext
+}
+
+// Close closes the SSH connection and every connection forwarded through it.
+func (t *Tunnel) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.closeOnce.Do(func() {
+		t.closeErr = t.client.Close()
+	})
+	return t.closeErr
+}
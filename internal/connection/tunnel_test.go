@@ -0,0 +1,303 @@
+package connection
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+// testJumpHost is an in-process SSH server that forwards direct-tcpip
+// channels, standing in for a bastion.
+type testJumpHost struct {
+	addr        string
+	fingerprint string
+	privateKey  string
+}
+
+func startTestJumpHost(t *testing.T) testJumpHost {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPublic, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "bastion" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "bastion" && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestJumpHost(conn, config)
+		}
+	}()
+
+	return testJumpHost{
+		addr:        listener.Addr().String(),
+		fingerprint: ssh.FingerprintSHA256(hostSigner.PublicKey()),
+		privateKey:  string(pem.EncodeToMemory(block)),
+	}
+}
+
+func serveTestJumpHost(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.Prohibited, "bad request")
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			defer channel.Close()
+			defer upstream.Close()
+			go io.Copy(upstream, channel)
+			io.Copy(channel, upstream)
+		}()
+	}
+}
+
+func (h testJumpHost) config() TunnelConfig {
+	host, port, _ := net.SplitHostPort(h.addr)
+	portNumber, _ := strconv.Atoi(port)
+	return TunnelConfig{Host: host, Port: portNumber, User: "bastion", PrivateKey: h.privateKey, HostKeyFingerprint: h.fingerprint}
+}
+
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func assertEcho(t *testing.T, dial engine.DialFunc, addr string) {
+	t.Helper()
+
+	conn, err := dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("echo = %q, want ping", buf)
+	}
+}
+
+func TestTunnelForwardsThroughJumpHost(t *testing.T) {
+	jump := startTestJumpHost(t)
+	echo := startEchoServer(t)
+
+	for name, cfg := range map[string]TunnelConfig{
+		"private key": jump.config(),
+		"password":    {Host: jump.config().Host, Port: jump.config().Port, User: "bastion", Password: "secret", HostKeyFingerprint: jump.fingerprint},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tunnel, err := OpenTunnel(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tunnel.Close()
+			assertEcho(t, tunnel.Dialer(), echo)
+		})
+	}
+
+	var direct *Tunnel
+	if direct.Dialer() != nil || direct.Close() != nil {
+		t.Fatal("nil tunnel should dial directly and close cleanly")
+	}
+}
+
+func TestOpenTunnelReportsFailureStage(t *testing.T) {
+	jump := startTestJumpHost(t)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedHost, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+	closedPortNumber, _ := strconv.Atoi(closedPort)
+
+	wrongKey := jump.config()
+	wrongKey.HostKeyFingerprint = "SHA256:" + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	wrongPassword := jump.config()
+	wrongPassword.PrivateKey = ""
+	wrongPassword.Password = "wrong"
+	unreachable := jump.config()
+	unreachable.Host, unreachable.Port = closedHost, closedPortNumber
+	badKey := jump.config()
+	badKey.PrivateKey = "not a key"
+	refused := jump.config()
+	refused.Dial = func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("refused by dialer")
+	}
+
+	tests := []struct {
+		name  string
+		cfg   TunnelConfig
+		stage string
+	}{
+		{name: "host key mismatch", cfg: wrongKey, stage: TunnelStageHostKey},
+		{name: "bad password", cfg: wrongPassword, stage: TunnelStageAuth},
+		{name: "unreachable", cfg: unreachable, stage: TunnelStageDial},
+		{name: "custom dialer", cfg: refused, stage: TunnelStageDial},
+		{name: "unparseable key", cfg: badKey, stage: TunnelStageConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := OpenTunnel(ctx, tt.cfg)
+			var tunnelErr *TunnelError
+			if !errors.As(err, &tunnelErr) || tunnelErr.Stage != tt.stage {
+				t.Fatalf("error = %v, want tunnel %s failure", err, tt.stage)
+			}
+		})
+	}
+	if !errors.Is(func() error { _, err := OpenTunnel(context.Background(), wrongKey); return err }(), ErrHostKeyMismatch) {
+		t.Fatal("host key mismatch should wrap ErrHostKeyMismatch")
+	}
+}
+
+func TestManagerClosesSessionTunnelOnIdleReap(t *testing.T) {
+	jump := startTestJumpHost(t)
+	echo := startEchoServer(t)
+	m := New(time.Millisecond)
+	defer m.Close()
+
+	cfg := jump.config()
+	var dial engine.DialFunc
+	sess, created, err := m.GetOrCreate(context.Background(), SessionKey{"1", "2"}, SessionOptions{
+		Tunnel: &cfg,
+		Open: func(d engine.DialFunc) (engine.Driver, io.Closer, error) {
+			dial = d
+			return &mockDriver{}, nil, nil
+		},
+	})
+	if err != nil || !created {
+		t.Fatalf("GetOrCreate = %v, %v; want a new session", created, err)
+	}
+	assertEcho(t, dial, echo)
+
+	time.Sleep(5 * time.Millisecond)
+	m.reapIdle()
+	if _, ok := m.Get(sess.ID); ok {
+		t.Fatal("idle session should be reaped")
+	}
+	if !sess.Conn.(*mockDriver).closed {
+		t.Fatal("reaped session driver should be closed")
+	}
+	if _, err := dial(context.Background(), "tcp", echo); err == nil {
+		t.Fatal("reaped session tunnel should be closed")
+	}
+
+	failing := jump.config()
+	failing.Password, failing.PrivateKey = "wrong", ""
+	opened := false
+	_, _, err = m.GetOrCreate(context.Background(), SessionKey{"1", "3"}, SessionOptions{
+		Tunnel: &failing,
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) {
+			opened = true
+			return &mockDriver{}, nil, nil
+		},
+	})
+	var tunnelErr *TunnelError
+	if !errors.As(err, &tunnelErr) || opened {
+		t.Fatalf("failed tunnel = %v, opened %v; want tunnel error before opening the driver", err, opened)
+	}
+}
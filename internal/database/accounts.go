@@ -12,16 +12,29 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// Account kinds. Service accounts are owned by an organization and can only
+// authenticate with API tokens.
+const (
+	AccountKindUser    = "user"
+	AccountKindService = "service"
+)
+
 type Account struct {
 	ID        int64     `bun:",pk,autoincrement"      json:"id"`
 	Email     string    `bun:",notnull,unique"        json:"email"`
 	Name      string    `bun:",notnull"               json:"name"`
 	Password  *string   `bun:",nullzero"              json:"-"`
+	Kind      string    `bun:",notnull,default:'user'" json:"kind"`
 	IsActive  bool      `bun:",notnull,default:true"  json:"is_active"`
 	CreatedAt time.Time `bun:",notnull"               json:"created_at"`
 	UpdatedAt time.Time `bun:",notnull"               json:"updated_at"`
 }
 
+// IsService reports whether the account is a service account.
+func (a Account) IsService() bool {
+	return a.Kind == AccountKindService
+}
+
 type ListAccountsParams struct {
 	ExcludeOrgID int64
 	Search       string
@@ -44,6 +57,7 @@ func (db *DB) InsertAccountWithExecutor(ctx context.Context, exec bun.IDB, email
 		Email:     email,
 		Name:      name,
 		Password:  password,
+		Kind:      AccountKindUser,
 		IsActive:  true,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -174,6 +188,14 @@ func (db *DB) DeactivateAccount(ctx context.Context, id int64) error {
 			Set("revoked_at = ?", now).
 			Where("account_id = ? AND revoked_at IS NULL", id).
 			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().
+			Model((*APIToken)(nil)).
+			Set("revoked_at = ?", now).
+			Where("account_id = ? AND revoked_at IS NULL", id).
+			Exec(ctx)
 		return err
 	})
 }
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sqlwarden/internal/response"
+	"github.com/uptrace/bun"
+)
+
+const apiTokenLastUsedUpdateInterval = time.Minute
+
+// APIToken is a long-lived bearer credential for an account. Personal access
+// tokens belong to human accounts; service account tokens are additionally
+// pinned to the owning organization through OrgID.
+type APIToken struct {
+	bun.BaseModel `bun:"table:api_tokens"`
+
+	ID                 string     `bun:",pk"             json:"id"`
+	AccountID          int64      `bun:",notnull"        json:"account_id"`
+	OrgID              *int64     `bun:",nullzero"       json:"org_id,omitempty"`
+	Name               string     `bun:",notnull"        json:"name"`
+	TokenPrefix        string     `bun:",notnull"        json:"token_prefix"`
+	TokenHash          string     `bun:",notnull,unique" json:"-"`
+	Scopes             []string   `bun:",notnull"        json:"scopes"`
+	ExpiresAt          *time.Time `bun:",nullzero"       json:"expires_at,omitempty"`
+	LastUsedAt         *time.Time `bun:",nullzero"       json:"last_used_at,omitempty"`
+	LastUsedIP         string     `bun:",nullzero"       json:"last_used_ip,omitempty"`
+	RevokedAt          *time.Time `bun:",nullzero"       json:"revoked_at,omitempty"`
+	CreatedByAccountID *int64     `bun:",nullzero"       json:"created_by_account_id,omitempty"`
+	CreatedAt          time.Time  `bun:",notnull"        json:"created_at"`
+	// MFAVerified records that the token was created from a session that had
+	// presented a second factor.
+	MFAVerified bool `bun:",notnull" json:"mfa_verified"`
+}
+
+// IsExpired reports whether the token has an expiry at or before now.
+func (t APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// UsedRecently reports whether the token's recorded use is within
+// apiTokenLastUsedUpdateInterval of now, in which case TouchAPIToken would
+// not change it.
+func (t APIToken) UsedRecently(now time.Time) bool {
+	return t.LastUsedAt != nil && now.Sub(*t.LastUsedAt) < apiTokenLastUsedUpdateInterval
+}
+
+type InsertAPITokenParams struct {
+	AccountID          int64
+	OrgID              *int64
+	Name               string
+	TokenPrefix        string
+	TokenHash          string
+	Scopes             []string
+	ExpiresAt          *time.Time
+	CreatedByAccountID *int64
+	MFAVerified        bool
+}
+
+type ListAPITokensParams struct {
+	AccountID int64
+	Page      int
+	PageSize  int
+}
+
+func (db *DB) InsertAPIToken(ctx context.Context, params InsertAPITokenParams) (APIToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	token := APIToken{
+		ID:                 newID(),
+		AccountID:          params.AccountID,
+		OrgID:              params.OrgID,
+		Name:               params.Name,
+		TokenPrefix:        params.TokenPrefix,
+		TokenHash:          params.TokenHash,
+		Scopes:             params.Scopes,
+		ExpiresAt:          params.ExpiresAt,
+		CreatedByAccountID: params.CreatedByAccountID,
+		CreatedAt:          time.Now(),
+		MFAVerified:        params.MFAVerified,
+	}
+	_, err := db.NewInsert().Model(&token).Exec(ctx)
+	if err != nil {
+		return APIToken{}, err
+	}
+	return token, nil
+}
+
+func (db *DB) GetAPITokenByHash(ctx context.Context, hash string) (APIToken, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var token APIToken
+	err := db.NewSelect().Model(&token).Where("token_hash = ?", hash).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, false, nil
+	}
+	if err != nil {
+		return APIToken{}, false, err
+	}
+	return token, true, nil
+}
+
+func (db *DB) GetAPIToken(ctx context.Context, id string, accountID int64) (APIToken, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var token APIToken
+	err := db.NewSelect().
+		Model(&token).
+		Where("id = ? AND account_id = ?", id, accountID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, false, nil
+	}
+	if err != nil {
+		return APIToken{}, false, err
+	}
+	return token, true, nil
+}
+
+// ListAPITokensPage lists an account's tokens that have not been revoked,
+// newest first. Expired tokens stay listed so their owner can see and remove
+// them.
+func (db *DB) ListAPITokensPage(ctx context.Context, params ListAPITokensParams) (response.Paginated[APIToken], error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 25
+	}
+
+	var tokens []APIToken
+	err := db.NewSelect().
+		Model(&tokens).
+		Where("account_id = ? AND revoked_at IS NULL", params.AccountID).
+		OrderExpr("created_at DESC, id DESC").
+		Scan(ctx)
+	if err != nil {
+		return response.Paginated[APIToken]{}, err
+	}
+	return response.PaginateItems(tokens, params.Page, params.PageSize), nil
+}
+
+// TouchAPIToken records token use, writing at most once per
+// apiTokenLastUsedUpdateInterval so busy CI jobs do not update the row on
+// every request.
+func (db *DB) TouchAPIToken(ctx context.Context, id, ipAddress string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	_, err := db.NewUpdate().
+		Model((*APIToken)(nil)).
+		Set("last_used_at = ?", now).
+		Set("last_used_ip = ?", ipAddress).
+		Where("id = ?", id).
+		Where("(last_used_at IS NULL OR last_used_at < ?)", now.Add(-apiTokenLastUsedUpdateInterval)).
+		Exec(ctx)
+	return err
+}
+
+func (db *DB) RevokeAPIToken(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*APIToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Exec(ctx)
+	return err
+}
+
+// RevokeAPITokensForAccountWithExecutor revokes every active token of an
+// account using exec for transaction composition.
+func (db *DB) RevokeAPITokensForAccountWithExecutor(ctx context.Context, exec bun.IDB, accountID int64) error {
+	_, err := exec.NewUpdate().
+		Model((*APIToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("account_id = ? AND revoked_at IS NULL", accountID).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestAPITokenLifecycle(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			account, err := db.InsertAccount(ctx, "pat@example.com", "PAT User", nil)
+			assert.Nil(t, err)
+
+			expires := time.Now().Add(24 * time.Hour)
+			token, err := db.InsertAPIToken(ctx, InsertAPITokenParams{
+				AccountID:   account.ID,
+				Name:        "ci",
+				TokenPrefix: "sqlw_abcdefgh",
+				TokenHash:   "hash-pat",
+				Scopes:      []string{"read", "write"},
+				ExpiresAt:   &expires,
+			})
+			assert.Nil(t, err)
+
+			fetched, found, err := db.GetAPITokenByHash(ctx, "hash-pat")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, fetched.ID, token.ID)
+			assert.Equal(t, len(fetched.Scopes), 2)
+			assert.False(t, fetched.IsExpired(time.Now()))
+			assert.True(t, fetched.IsExpired(expires))
+
+			assert.Nil(t, db.TouchAPIToken(ctx, token.ID, "10.0.0.1"))
+			fetched, _, err = db.GetAPIToken(ctx, token.ID, account.ID)
+			assert.Nil(t, err)
+			assert.NotNil(t, fetched.LastUsedAt)
+			assert.Equal(t, fetched.LastUsedIP, "10.0.0.1")
+			assert.True(t, fetched.UsedRecently(time.Now()))
+			assert.False(t, fetched.UsedRecently(fetched.LastUsedAt.Add(time.Minute)))
+
+			page, err := db.ListAPITokensPage(ctx, ListAPITokensParams{AccountID: account.ID})
+			assert.Nil(t, err)
+			assert.Equal(t, page.Total, 1)
+
+			assert.Nil(t, db.RevokeAPIToken(ctx, token.ID))
+			page, err = db.ListAPITokensPage(ctx, ListAPITokensParams{AccountID: account.ID})
+			assert.Nil(t, err)
+			assert.Equal(t, page.Total, 0)
+
+			revoked, found, err := db.GetAPITokenByHash(ctx, "hash-pat")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.NotNil(t, revoked.RevokedAt)
+		})
+	}
+}
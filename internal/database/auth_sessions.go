@@ -23,6 +23,14 @@ type AuthSession struct {
 	RevokedAt          *time.Time `bun:",nullzero" json:"revoked_at,omitempty"`
 	RevokedByAccountID *int64     `bun:",nullzero" json:"revoked_by_account_id,omitempty"`
 	RevocationReason   string     `bun:",nullzero" json:"revocation_reason,omitempty"`
+	// SSOIssuer is the OpenID Connect issuer the session was signed in
+	// through; it is empty for password sign-ins.
+	SSOIssuer string `bun:"sso_issuer,nullzero" json:"sso_issuer,omitempty"`
+	// MFAMethod is the second factor presented at sign-in ("totp",
+	// "recovery_code", "webauthn" or "sso"), or the factor confirmed during
+	// the session when the account enrolled. It is empty for sessions without
+	// one.
+	MFAMethod string `bun:"mfa_method,nullzero" json:"mfa_method,omitempty"`
 }
 
 type OrgAccessSession struct {
@@ -132,25 +140,29 @@ func (db *DB) RevokeAuthSessionsForAccount(ctx context.Context, accountID int64,
 	defer cancel()
 
 	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		now := time.Now()
-		_, err := tx.NewUpdate().
-			Model((*AuthSession)(nil)).
-			Set("revoked_at = ?", now).
-			Set("revoked_by_account_id = ?", revokedBy).
-			Set("revocation_reason = ?", reason).
-			Where("account_id = ? AND revoked_at IS NULL", accountID).
-			Exec(ctx)
-		if err != nil {
-			return err
-		}
+		return revokeAuthSessionsForAccountWithExecutor(ctx, tx, accountID, revokedBy, reason)
+	})
+}
 
-		_, err = tx.NewUpdate().
-			Model((*RefreshToken)(nil)).
-			Set("revoked_at = ?", now).
-			Where("account_id = ? AND revoked_at IS NULL", accountID).
-			Exec(ctx)
+func revokeAuthSessionsForAccountWithExecutor(ctx context.Context, exec bun.IDB, accountID int64, revokedBy *int64, reason string) error {
+	now := time.Now()
+	_, err := exec.NewUpdate().
+		Model((*AuthSession)(nil)).
+		Set("revoked_at = ?", now).
+		Set("revoked_by_account_id = ?", revokedBy).
+		Set("revocation_reason = ?", reason).
+		Where("account_id = ? AND revoked_at IS NULL", accountID).
+		Exec(ctx)
+	if err != nil {
 		return err
-	})
+	}
+
+	_, err = exec.NewUpdate().
+		Model((*RefreshToken)(nil)).
+		Set("revoked_at = ?", now).
+		Where("account_id = ? AND revoked_at IS NULL", accountID).
+		Exec(ctx)
+	return err
 }
 
 func (db *DB) ListAuthSessionsPage(ctx context.Context, params ListAuthSessionsParams) (response.Paginated[AuthSession], error) {
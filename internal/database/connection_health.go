@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Connection health check statuses.
+const (
+	ConnectionHealthReachable   = "reachable"
+	ConnectionHealthUnreachable = "unreachable"
+)
+
+// ConnectionHealthCheck is the outcome of one scheduled ping of a connection.
+// Stage and ErrorCategory use the values a connection test reports.
+type ConnectionHealthCheck struct {
+	bun.BaseModel `bun:"table:connection_health_checks,alias:chc"`
+
+	ID            int64     `bun:",pk,autoincrement"    json:"id"`
+	ConnectionID  int64     `bun:",notnull"             json:"connection_id"`
+	Status        string    `bun:",notnull"             json:"status"`
+	LatencyMs     int64     `bun:",notnull"             json:"latency_ms"`
+	Stage         string    `bun:",notnull,default:''"  json:"stage,omitempty"`
+	ErrorCategory string    `bun:",notnull,default:''"  json:"error_category,omitempty"`
+	Error         string    `bun:",notnull,default:''"  json:"error,omitempty"`
+	CheckedAt     time.Time `bun:",notnull"             json:"checked_at"`
+}
+
+// UpdateConnectionHealthCheck opts a connection in or out of scheduled health
+// checks. Its history is kept when it opts out.
+func (db *DB) UpdateConnectionHealthCheck(ctx context.Context, id int64, enabled bool) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("health_check_enabled = ?", enabled).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// ListHealthCheckedConnections returns every connection opted into scheduled
+// health checks.
+func (db *DB) ListHealthCheckedConnections(ctx context.Context) ([]Connection, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var conns []Connection
+	err := db.NewSelect().Model(&conns).Where("health_check_enabled = ?", true).OrderExpr("id ASC").Scan(ctx)
+	return conns, err
+}
+
+func (db *DB) InsertConnectionHealthCheck(ctx context.Context, check ConnectionHealthCheck) (ConnectionHealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if check.CheckedAt.IsZero() {
+		check.CheckedAt = time.Now()
+	}
+	_, err := db.NewInsert().Model(&check).Returning("id").Exec(ctx)
+	return check, err
+}
+
+// GetLatestConnectionHealthCheck returns the connection's most recent check.
+func (db *DB) GetLatestConnectionHealthCheck(ctx context.Context, connectionID int64) (ConnectionHealthCheck, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var check ConnectionHealthCheck
+	err := db.NewSelect().
+		Model(&check).
+		Where("connection_id = ?", connectionID).
+		OrderExpr("checked_at DESC, id DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ConnectionHealthCheck{}, false, nil
+	}
+	if err != nil {
+		return ConnectionHealthCheck{}, false, err
+	}
+	return check, true, nil
+}
+
+// ListConnectionHealthChecks returns the connection's checks since since,
+// oldest first, keeping the most recent limit of them.
+func (db *DB) ListConnectionHealthChecks(ctx context.Context, connectionID int64, since time.Time, limit int) ([]ConnectionHealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var checks []ConnectionHealthCheck
+	err := db.NewSelect().
+		Model(&checks).
+		Where("connection_id = ?", connectionID).
+		Where("checked_at >= ?", since).
+		OrderExpr("checked_at DESC, id DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(checks)-1; i < j; i, j = i+1, j-1 {
+		checks[i], checks[j] = checks[j], checks[i]
+	}
+	return checks, nil
+}
+
+// DeleteConnectionHealthChecksBefore removes checks older than before and
+// returns how many it removed.
+func (db *DB) DeleteConnectionHealthChecksBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().
+		Model((*ConnectionHealthCheck)(nil)).
+		Where("checked_at < ?", before).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestConnectionHealthChecks(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "health-org", "Health Org")
+			assert.Nil(t, err)
+			ws, err := db.InsertWorkspace(ctx, &org.ID, "org", org.ID, "Main", "")
+			assert.Nil(t, err)
+			conn, err := db.InsertConnectionWithConfig(ctx, ws.ID, nil, "warehouse", "postgres", "dsn", "config", "", CredentialModeShared, "", "open", "")
+			assert.Nil(t, err)
+
+			conns, err := db.ListHealthCheckedConnections(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, len(conns), 0)
+			assert.Nil(t, db.UpdateConnectionHealthCheck(ctx, conn.ID, true))
+			conns, err = db.ListHealthCheckedConnections(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, len(conns), 1)
+			assert.True(t, conns[0].HealthCheckEnabled)
+
+			_, found, err := db.GetLatestConnectionHealthCheck(ctx, conn.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			now := time.Now().UTC().Truncate(time.Second)
+			_, err = db.InsertConnectionHealthCheck(ctx, ConnectionHealthCheck{
+				ConnectionID: conn.ID, Status: ConnectionHealthReachable, LatencyMs: 12, CheckedAt: now.Add(-2 * time.Hour),
+			})
+			assert.Nil(t, err)
+			_, err = db.InsertConnectionHealthCheck(ctx, ConnectionHealthCheck{
+				ConnectionID: conn.ID, Status: ConnectionHealthReachable, LatencyMs: 9, CheckedAt: now.Add(-time.Hour),
+			})
+			assert.Nil(t, err)
+			_, err = db.InsertConnectionHealthCheck(ctx, ConnectionHealthCheck{
+				ConnectionID: conn.ID, Status: ConnectionHealthUnreachable, LatencyMs: 10003,
+				Stage: "connect", ErrorCategory: "timeout", Error: "dial tcp: i/o timeout", CheckedAt: now,
+			})
+			assert.Nil(t, err)
+
+			latest, found, err := db.GetLatestConnectionHealthCheck(ctx, conn.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, latest.Status, ConnectionHealthUnreachable)
+			assert.Equal(t, latest.ErrorCategory, "timeout")
+
+			checks, err := db.ListConnectionHealthChecks(ctx, conn.ID, now.Add(-3*time.Hour), 2)
+			assert.Nil(t, err)
+			assert.Equal(t, len(checks), 2)
+			assert.Equal(t, checks[0].LatencyMs, int64(9))
+			assert.Equal(t, checks[1].Status, ConnectionHealthUnreachable)
+
+			removed, err := db.DeleteConnectionHealthChecksBefore(ctx, now.Add(-90*time.Minute))
+			assert.Nil(t, err)
+			assert.Equal(t, removed, int64(1))
+			checks, err = db.ListConnectionHealthChecks(ctx, conn.ID, now.Add(-3*time.Hour), 10)
+			assert.Nil(t, err)
+			assert.Equal(t, len(checks), 2)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// ConnectionReadReplica is a read replica of a connection. It connects with
+// the connection's own settings except for Host and Port; a zero Port keeps
+// the connection's port.
+type ConnectionReadReplica struct {
+	bun.BaseModel `bun:"table:connection_read_replicas,alias:crr"`
+
+	ConnectionID int64  `bun:",pk"      json:"-"`
+	Name         string `bun:",pk"      json:"name"`
+	Host         string `bun:",notnull" json:"host"`
+	Port         int    `bun:",notnull" json:"port,omitempty"`
+	Position     int    `bun:",notnull" json:"-"`
+}
+
+// ListConnectionReadReplicas returns the connection's replicas in the order
+// reads are routed to them.
+func (db *DB) ListConnectionReadReplicas(ctx context.Context, connectionID int64) ([]ConnectionReadReplica, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var replicas []ConnectionReadReplica
+	err := db.NewSelect().
+		Model(&replicas).
+		Where("connection_id = ?", connectionID).
+		OrderExpr("position ASC, name ASC").
+		Scan(ctx)
+	return replicas, err
+}
+
+// ReplaceConnectionReadReplicas swaps the connection's replicas for replicas,
+// kept in the given order. Live sessions keep the replicas they opened with.
+func (db *DB) ReplaceConnectionReadReplicas(ctx context.Context, connectionID int64, replicas []ConnectionReadReplica) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*ConnectionReadReplica)(nil)).
+			Where("connection_id = ?", connectionID).
+			Exec(ctx)
+		if err != nil || len(replicas) == 0 {
+			return err
+		}
+		rows := make([]ConnectionReadReplica, len(replicas))
+		for i, replica := range replicas {
+			rows[i] = ConnectionReadReplica{ConnectionID: connectionID, Name: replica.Name, Host: replica.Host, Port: replica.Port, Position: i}
+		}
+		_, err = tx.NewInsert().Model(&rows).Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestConnectionReadReplicas(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "replica-org", "Replica Org")
+			assert.Nil(t, err)
+			ws, err := db.InsertWorkspace(ctx, &org.ID, "org", org.ID, "Main", "")
+			assert.Nil(t, err)
+			conn, err := db.InsertConnectionWithConfig(ctx, ws.ID, nil, "warehouse", "postgres", "dsn", "config", "", CredentialModeShared, "", "open", "")
+			assert.Nil(t, err)
+
+			err = db.ReplaceConnectionReadReplicas(ctx, conn.ID, []ConnectionReadReplica{
+				{Name: "west", Host: "west.db.internal"},
+				{Name: "east", Host: "east.db.internal", Port: 6432},
+			})
+			assert.Nil(t, err)
+			replicas, err := db.ListConnectionReadReplicas(ctx, conn.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(replicas), 2)
+			assert.Equal(t, replicas[0].Name, "west")
+			assert.Equal(t, replicas[1].Host, "east.db.internal")
+			assert.Equal(t, replicas[1].Port, 6432)
+
+			err = db.ReplaceConnectionReadReplicas(ctx, conn.ID, []ConnectionReadReplica{{Name: "east", Host: "east2.db.internal"}})
+			assert.Nil(t, err)
+			replicas, err = db.ListConnectionReadReplicas(ctx, conn.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(replicas), 1)
+			assert.Equal(t, replicas[0].Host, "east2.db.internal")
+
+			assert.Nil(t, db.DeleteConnection(ctx, conn.ID))
+			replicas, err = db.ListConnectionReadReplicas(ctx, conn.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(replicas), 0)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ConnectionUserCredential is the credential one account connects with on a
+// per-user connection. Exactly one of CredentialEncrypted, a keyring-encrypted
+// {"user", "password"} document, and CredentialRef, a secret reference, is set.
+type ConnectionUserCredential struct {
+	bun.BaseModel `bun:"table:connection_user_credentials,alias:cuc"`
+
+	ConnectionID        int64     `bun:",pk"                  json:"connection_id"`
+	AccountID           int64     `bun:",pk"                  json:"account_id"`
+	CredentialEncrypted string    `bun:",notnull,default:''"  json:"-"`
+	CredentialRef       string    `bun:",notnull,default:''"  json:"credential_ref,omitempty"`
+	AccountEmail        string    `bun:",scanonly"            json:"account_email,omitempty"`
+	AccountName         string    `bun:",scanonly"            json:"account_name,omitempty"`
+	CreatedAt           time.Time `bun:",notnull"             json:"created_at"`
+	UpdatedAt           time.Time `bun:",notnull"             json:"updated_at"`
+}
+
+// UpsertConnectionUserCredential stores cred, replacing the account's earlier
+// credential for the connection.
+func (db *DB) UpsertConnectionUserCredential(ctx context.Context, cred ConnectionUserCredential) (ConnectionUserCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	cred.CreatedAt, cred.UpdatedAt = now, now
+	_, err := db.NewInsert().
+		Model(&cred).
+		On("CONFLICT (connection_id, account_id) DO UPDATE").
+		Set("credential_encrypted = EXCLUDED.credential_encrypted").
+		Set("credential_ref = EXCLUDED.credential_ref").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return ConnectionUserCredential{}, err
+	}
+	return cred, nil
+}
+
+func (db *DB) GetConnectionUserCredential(ctx context.Context, connectionID, accountID int64) (ConnectionUserCredential, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var cred ConnectionUserCredential
+	err := db.NewSelect().
+		Model(&cred).
+		Where("connection_id = ? AND account_id = ?", connectionID, accountID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ConnectionUserCredential{}, false, nil
+	}
+	if err != nil {
+		return ConnectionUserCredential{}, false, err
+	}
+	return cred, true, nil
+}
+
+// ListConnectionUserCredentials returns who has stored a credential for the
+// connection, with each account's email and name. Callers must not expose
+// CredentialEncrypted.
+func (db *DB) ListConnectionUserCredentials(ctx context.Context, connectionID int64) ([]ConnectionUserCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var creds []ConnectionUserCredential
+	err := db.NewSelect().
+		Model(&creds).
+		ColumnExpr("cuc.*").
+		ColumnExpr("a.email AS account_email").
+		ColumnExpr("a.name AS account_name").
+		Join("JOIN accounts AS a ON a.id = cuc.account_id").
+		Where("cuc.connection_id = ?", connectionID).
+		OrderExpr("a.email ASC").
+		Scan(ctx)
+	return creds, err
+}
+
+// ListAllConnectionUserCredentials returns every stored per-user credential,
+// for key rotation.
+func (db *DB) ListAllConnectionUserCredentials(ctx context.Context) ([]ConnectionUserCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var creds []ConnectionUserCredential
+	err := db.NewSelect().Model(&creds).OrderExpr("connection_id ASC, account_id ASC").Scan(ctx)
+	return creds, err
+}
+
+// UpdateConnectionUserCredentialSecret replaces only the encrypted credential
+// and, like UpdateConnectionDSN, leaves updated_at untouched for key rotation.
+func (db *DB) UpdateConnectionUserCredentialSecret(ctx context.Context, connectionID, accountID int64, credentialEncrypted string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*ConnectionUserCredential)(nil)).
+		Set("credential_encrypted = ?", credentialEncrypted).
+		Where("connection_id = ? AND account_id = ?", connectionID, accountID).
+		Exec(ctx)
+	return err
+}
+
+// DeleteConnectionUserCredential removes the account's credential for the
+// connection. found is false when there was none.
+func (db *DB) DeleteConnectionUserCredential(ctx context.Context, connectionID, accountID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().
+		Model((*ConnectionUserCredential)(nil)).
+		Where("connection_id = ? AND account_id = ?", connectionID, accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestConnectionUserCredentialLifecycle(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "per-user-org", "Per User Org")
+			assert.Nil(t, err)
+			ws, err := db.InsertWorkspace(ctx, &org.ID, "org", org.ID, "Main", "")
+			assert.Nil(t, err)
+			conn, err := db.InsertConnectionWithConfig(ctx, ws.ID, nil, "warehouse", "postgres", "dsn", "config", "", CredentialModePerUser, "", "open", "")
+			assert.Nil(t, err)
+			assert.Equal(t, conn.CredentialMode, CredentialModePerUser)
+			alice, err := db.InsertAccount(ctx, "alice@example.com", "Alice", nil)
+			assert.Nil(t, err)
+			bob, err := db.InsertAccount(ctx, "bob@example.com", "Bob", nil)
+			assert.Nil(t, err)
+
+			_, err = db.UpsertConnectionUserCredential(ctx, ConnectionUserCredential{ConnectionID: conn.ID, AccountID: bob.ID, CredentialRef: "vault:database/creds/bob"})
+			assert.Nil(t, err)
+			_, err = db.UpsertConnectionUserCredential(ctx, ConnectionUserCredential{ConnectionID: conn.ID, AccountID: alice.ID, CredentialEncrypted: "first"})
+			assert.Nil(t, err)
+			_, err = db.UpsertConnectionUserCredential(ctx, ConnectionUserCredential{ConnectionID: conn.ID, AccountID: alice.ID, CredentialEncrypted: "second"})
+			assert.Nil(t, err)
+
+			cred, found, err := db.GetConnectionUserCredential(ctx, conn.ID, alice.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, cred.CredentialEncrypted, "second")
+			assert.Equal(t, cred.CredentialRef, "")
+
+			creds, err := db.ListConnectionUserCredentials(ctx, conn.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(creds), 2)
+			assert.Equal(t, creds[0].AccountEmail, "alice@example.com")
+			assert.Equal(t, creds[1].AccountName, "Bob")
+			assert.Equal(t, creds[1].CredentialRef, "vault:database/creds/bob")
+
+			found, err = db.DeleteConnectionUserCredential(ctx, conn.ID, alice.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			found, err = db.DeleteConnectionUserCredential(ctx, conn.ID, alice.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			assert.Nil(t, db.DeleteConnection(ctx, conn.ID))
+			_, found, err = db.GetConnectionUserCredential(ctx, conn.ID, bob.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/sqlwarden/internal/response"
 	"github.com/uptrace/bun"
 
+	"github.com/sqlwarden/internal/engine/connconfig"
 	"github.com/sqlwarden/internal/engine/metadata"
 )
 
@@ -24,8 +25,28 @@ type Connection struct {
 	AccessMode           string             `bun:",notnull,default:'open'" json:"access_mode"`
 	SchemaSnapshotPolicy string             `bun:",notnull,default:'inherit'" json:"schema_snapshot_policy"`
 	DefaultScope         metadata.ScopePath `bun:",notnull,default:''" json:"default_scope,omitempty"`
-	CreatedAt            time.Time          `bun:",notnull"          json:"created_at"`
-	UpdatedAt            time.Time          `bun:",notnull"          json:"updated_at"`
+	SSHTunnelEncrypted   string             `bun:",notnull,default:''" json:"-"`
+	SSHTunnelEnabled     bool               `bun:",notnull,default:false" json:"ssh_tunnel_enabled"`
+	ConfigEncrypted      string             `bun:",notnull,default:''" json:"-"`
+	CredentialRef        string             `bun:",notnull,default:''" json:"credential_ref,omitempty"`
+	CredentialMode       string             `bun:",notnull,default:'shared'" json:"credential_mode"`
+	HealthCheckEnabled   bool               `bun:",notnull,default:false" json:"health_check_enabled"`
+	// QueryMaxExecutionSeconds tightens the environment's statement time
+	// limit for this connection. Nil inherits it.
+	QueryMaxExecutionSeconds *int64 `json:"query_max_execution_seconds,omitempty"`
+	// SessionInit runs on every new interactive session. Nil inherits the
+	// environment's.
+	SessionInit *SessionInit `json:"session_init,omitempty"`
+	// AgentID binds the connection to a connector agent, which dials the
+	// database from inside its network. Nil connects directly.
+	AgentID   *int64    `json:"agent_id,omitempty"`
+	CreatedAt time.Time `bun:",notnull"          json:"created_at"`
+	UpdatedAt time.Time `bun:",notnull"          json:"updated_at"`
+	// Config is the masked structured configuration. Handlers fill it in; it
+	// is never read from or written to the connections table.
+	Config *connconfig.Config `bun:"-" json:"config,omitempty"`
+	// ReadReplicas is filled in by handlers from connection_read_replicas.
+	ReadReplicas []ConnectionReadReplica `bun:"-" json:"read_replicas,omitempty"`
 }
 
 const (
@@ -33,6 +54,28 @@ const (
 	SchemaSnapshotPolicyDisabled = "disabled"
 )
 
+// SessionInit is the SQL a connection runs on each new interactive session
+// right after it connects: the variables in order, then the statements.
+type SessionInit struct {
+	Variables  []SessionVariable `json:"variables,omitempty"`
+	Statements []string          `json:"statements,omitempty"`
+}
+
+// SessionVariable is a session variable set by SessionInit. The engine
+// renders the statement that sets it.
+type SessionVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Connection credential modes. A per-user connection opens interactive
+// sessions with the credentials each account stores for it; background work
+// keeps using the connection's own credentials.
+const (
+	CredentialModeShared  = "shared"
+	CredentialModePerUser = "per_user"
+)
+
 type ListConnectionsParams struct {
 	WorkspaceID   int64
 	Search        string
@@ -50,6 +93,19 @@ func (db *DB) InsertConnection(ctx context.Context, workspaceID int64, envID *in
 }
 
 func (db *DB) InsertConnectionWithScope(ctx context.Context, workspaceID int64, envID *int64, name, driver, dsnEncrypted, accessMode string, defaultScope metadata.ScopePath) (Connection, error) {
+	return db.InsertConnectionWithTunnel(ctx, workspaceID, envID, name, driver, dsnEncrypted, "", accessMode, defaultScope)
+}
+
+// InsertConnectionWithTunnel inserts a connection together with its encrypted
+// SSH tunnel settings. An empty sshTunnelEncrypted connects directly.
+func (db *DB) InsertConnectionWithTunnel(ctx context.Context, workspaceID int64, envID *int64, name, driver, dsnEncrypted, sshTunnelEncrypted, accessMode string, defaultScope metadata.ScopePath) (Connection, error) {
+	return db.InsertConnectionWithConfig(ctx, workspaceID, envID, name, driver, dsnEncrypted, "", "", CredentialModeShared, sshTunnelEncrypted, accessMode, defaultScope)
+}
+
+// InsertConnectionWithConfig inserts a connection together with its encrypted
+// structured configuration, secret reference for its credentials, credential
+// mode, and SSH tunnel settings. Any of them but the mode may be empty.
+func (db *DB) InsertConnectionWithConfig(ctx context.Context, workspaceID int64, envID *int64, name, driver, dsnEncrypted, configEncrypted, credentialRef, credentialMode, sshTunnelEncrypted, accessMode string, defaultScope metadata.ScopePath) (Connection, error) {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
@@ -57,6 +113,18 @@ func (db *DB) InsertConnectionWithScope(ctx context.Context, workspaceID int64,
 	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		var err error
 		conn, err = db.InsertConnectionWithScopeAndExecutor(ctx, tx, workspaceID, envID, name, driver, dsnEncrypted, accessMode, defaultScope)
+		if err != nil || (configEncrypted == "" && credentialRef == "" && credentialMode == CredentialModeShared && sshTunnelEncrypted == "") {
+			return err
+		}
+		conn.ConfigEncrypted = configEncrypted
+		conn.CredentialRef = credentialRef
+		conn.CredentialMode = credentialMode
+		conn.SSHTunnelEncrypted = sshTunnelEncrypted
+		conn.SSHTunnelEnabled = sshTunnelEncrypted != ""
+		_, err = tx.NewUpdate().Model(&conn).
+			Column("config_encrypted", "credential_ref", "credential_mode", "ssh_tunnel_encrypted", "ssh_tunnel_enabled").
+			WherePK().
+			Exec(ctx)
 		return err
 	})
 	if err != nil {
@@ -91,6 +159,7 @@ func (db *DB) InsertConnectionWithScopeAndExecutor(ctx context.Context, exec bun
 		DSNEncrypted:         dsnEncrypted,
 		AccessMode:           accessMode,
 		SchemaSnapshotPolicy: SchemaSnapshotPolicyInherit,
+		CredentialMode:       CredentialModeShared,
 		DefaultScope:         defaultScope,
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
@@ -224,6 +293,91 @@ func (db *DB) ListAllConnections(ctx context.Context) ([]Connection, error) {
 	return conns, err
 }
 
+// UpdateConnectionSSHTunnel replaces the encrypted SSH tunnel settings for a
+// connection; an empty sshTunnelEncrypted removes the tunnel. Like
+// UpdateConnectionDSN it leaves updated_at untouched so key rotation can use
+// it too.
+func (db *DB) UpdateConnectionSSHTunnel(ctx context.Context, id int64, sshTunnelEncrypted string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("ssh_tunnel_encrypted = ?", sshTunnelEncrypted).
+		Set("ssh_tunnel_enabled = ?", sshTunnelEncrypted != "").
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateConnectionConfig replaces the encrypted structured configuration for a
+// connection; an empty configEncrypted leaves it with a raw DSN only. Callers
+// store the matching DSN with UpdateConnectionDSN or UpdateConnection. Like
+// UpdateConnectionDSN it leaves updated_at untouched.
+func (db *DB) UpdateConnectionConfig(ctx context.Context, id int64, configEncrypted string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("config_encrypted = ?", configEncrypted).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateConnectionCredentialRef replaces the secret reference a connection's
+// credentials are resolved from; an empty credentialRef connects with the
+// stored DSN as is. Callers store the matching DSN and config alongside it.
+func (db *DB) UpdateConnectionCredentialRef(ctx context.Context, id int64, credentialRef string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("credential_ref = ?", credentialRef).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateConnectionCredentialMode switches a connection between shared and
+// per-user credentials. Stored per-user credentials are kept when switching
+// back to shared so switching again restores them.
+func (db *DB) UpdateConnectionCredentialMode(ctx context.Context, id int64, credentialMode string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("credential_mode = ?", credentialMode).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateConnectionQueryMaxExecution sets or, with nil, clears the
+// connection's statement time limit.
+func (db *DB) UpdateConnectionQueryMaxExecution(ctx context.Context, id int64, seconds *int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("query_max_execution_seconds = ?", seconds).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateConnectionSessionInit sets or, with nil, clears the connection's own
+// session initialization.
+func (db *DB) UpdateConnectionSessionInit(ctx context.Context, id int64, init *SessionInit) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("session_init = ?", init).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
 // UpdateConnectionDSN replaces only the encrypted DSN for a connection. It is
 // used by encryption-key rotation and deliberately leaves all other fields,
 // including updated_at, untouched so rotation is invisible to consumers.
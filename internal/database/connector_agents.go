@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ConnectorAgent is an organization's agent running inside a private network.
+// It authenticates with its registration token and dials target databases on
+// behalf of the connections bound to it.
+type ConnectorAgent struct {
+	bun.BaseModel `bun:"table:connector_agents"`
+
+	ID                 int64      `bun:",pk,autoincrement" json:"id"`
+	OrgID              int64      `bun:",notnull"          json:"org_id"`
+	Name               string     `bun:",notnull"          json:"name"`
+	TokenPrefix        string     `bun:",notnull"          json:"token_prefix"`
+	TokenHash          string     `bun:",notnull,unique"   json:"-"`
+	Version            string     `bun:",notnull"          json:"version,omitempty"`
+	RemoteAddr         string     `bun:",notnull"          json:"remote_addr,omitempty"`
+	LastHeartbeatAt    *time.Time `bun:",nullzero"         json:"last_heartbeat_at,omitempty"`
+	CreatedByAccountID *int64     `bun:",nullzero"         json:"created_by_account_id,omitempty"`
+	CreatedAt          time.Time  `bun:",notnull"          json:"created_at"`
+	UpdatedAt          time.Time  `bun:",notnull"          json:"updated_at"`
+	// Connected is filled in by handlers from the live agent links; it is
+	// never read from or written to the connector_agents table.
+	Connected bool `bun:"-" json:"connected"`
+}
+
+type InsertConnectorAgentParams struct {
+	OrgID              int64
+	Name               string
+	TokenPrefix        string
+	TokenHash          string
+	CreatedByAccountID *int64
+}
+
+func (db *DB) InsertConnectorAgent(ctx context.Context, params InsertConnectorAgentParams) (ConnectorAgent, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	agent := ConnectorAgent{
+		OrgID:              params.OrgID,
+		Name:               params.Name,
+		TokenPrefix:        params.TokenPrefix,
+		TokenHash:          params.TokenHash,
+		CreatedByAccountID: params.CreatedByAccountID,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	_, err := db.NewInsert().Model(&agent).Returning("id").Exec(ctx)
+	if err != nil {
+		return ConnectorAgent{}, err
+	}
+	return agent, nil
+}
+
+// GetConnectorAgent returns the agent with id in the organization.
+func (db *DB) GetConnectorAgent(ctx context.Context, id, orgID int64) (ConnectorAgent, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var agent ConnectorAgent
+	err := db.NewSelect().Model(&agent).Where("id = ? AND org_id = ?", id, orgID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ConnectorAgent{}, false, nil
+	}
+	if err != nil {
+		return ConnectorAgent{}, false, err
+	}
+	return agent, true, nil
+}
+
+func (db *DB) GetConnectorAgentByTokenHash(ctx context.Context, hash string) (ConnectorAgent, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var agent ConnectorAgent
+	err := db.NewSelect().Model(&agent).Where("token_hash = ?", hash).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ConnectorAgent{}, false, nil
+	}
+	if err != nil {
+		return ConnectorAgent{}, false, err
+	}
+	return agent, true, nil
+}
+
+// ListConnectorAgents lists the organization's agents by name.
+func (db *DB) ListConnectorAgents(ctx context.Context, orgID int64) ([]ConnectorAgent, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	agents := []ConnectorAgent{}
+	err := db.NewSelect().
+		Model(&agents).
+		Where("org_id = ?", orgID).
+		OrderExpr("name ASC, id ASC").
+		Scan(ctx)
+	return agents, err
+}
+
+// RecordConnectorAgentHeartbeat stores what the agent last reported about
+// itself and where it connected from.
+func (db *DB) RecordConnectorAgentHeartbeat(ctx context.Context, id int64, version, remoteAddr string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*ConnectorAgent)(nil)).
+		Set("version = ?", version).
+		Set("remote_addr = ?", remoteAddr).
+		Set("last_heartbeat_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// RotateConnectorAgentToken replaces the agent's registration token. The old
+// token stops authenticating immediately.
+func (db *DB) RotateConnectorAgentToken(ctx context.Context, id, orgID int64, tokenPrefix, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*ConnectorAgent)(nil)).
+		Set("token_prefix = ?", tokenPrefix).
+		Set("token_hash = ?", tokenHash).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ? AND org_id = ?", id, orgID).
+		Exec(ctx)
+	return err
+}
+
+func (db *DB) DeleteConnectorAgent(ctx context.Context, id, orgID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewDelete().
+		Model((*ConnectorAgent)(nil)).
+		Where("id = ? AND org_id = ?", id, orgID).
+		Exec(ctx)
+	return err
+}
+
+// CountConnectorAgentConnections counts the connections bound to the agent.
+func (db *DB) CountConnectorAgentConnections(ctx context.Context, id int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.NewSelect().Model((*Connection)(nil)).Where("agent_id = ?", id).Count(ctx)
+}
+
+// UpdateConnectionAgent binds the connection to an agent or, with nil, makes
+// it connect directly.
+func (db *DB) UpdateConnectionAgent(ctx context.Context, id int64, agentID *int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Connection)(nil)).
+		Set("agent_id = ?", agentID).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,292 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/sqlwarden/internal/response"
+)
+
+const (
+	DeploymentStatusQueued    = "queued"
+	DeploymentStatusRunning   = "running"
+	DeploymentStatusSucceeded = "succeeded"
+	DeploymentStatusFailed    = "failed"
+	DeploymentStatusCancelled = "cancelled"
+
+	DeploymentTargetStatusPending   = "pending"
+	DeploymentTargetStatusRunning   = "running"
+	DeploymentTargetStatusSucceeded = "succeeded"
+	DeploymentTargetStatusFailed    = "failed"
+	DeploymentTargetStatusCancelled = "cancelled"
+	DeploymentTargetStatusSkipped   = "skipped"
+)
+
+// Deployment is one run of a pinned workspace file revision against an
+// ordered list of environment connections. SQL holds the revision's text as
+// it was when the deployment was created.
+type Deployment struct {
+	bun.BaseModel `bun:"table:deployments"`
+
+	ID             int64              `bun:",pk,autoincrement" json:"id"`
+	OrgID          int64              `bun:",notnull" json:"org_id"`
+	WorkspaceID    int64              `bun:",notnull" json:"workspace_id"`
+	FileID         *int64             `bun:",nullzero" json:"file_id"`
+	FileName       string             `bun:",notnull" json:"file_name"`
+	ContentID      int64              `bun:",notnull" json:"content_id"`
+	ContentVersion int                `bun:",notnull" json:"content_version"`
+	ContentHash    string             `bun:",notnull" json:"content_hash"`
+	SQL            string             `bun:"sql_text,notnull" json:"-"`
+	Status         string             `bun:",notnull" json:"status"`
+	CreatedBy      int64              `bun:",notnull" json:"created_by"`
+	CreatedAt      time.Time          `bun:",notnull" json:"created_at"`
+	FinishedAt     *time.Time         `bun:",nullzero" json:"finished_at"`
+	Targets        []DeploymentTarget `bun:"-" json:"targets"`
+}
+
+// DeploymentTarget is one connection a deployment runs on, in position
+// order. JobID names the background job that ran it once it was queued.
+type DeploymentTarget struct {
+	bun.BaseModel `bun:"table:deployment_targets"`
+
+	ID             int64      `bun:",pk,autoincrement" json:"id"`
+	DeploymentID   int64      `bun:",notnull" json:"deployment_id"`
+	Position       int        `bun:",notnull" json:"position"`
+	EnvironmentID  int64      `bun:",notnull" json:"environment_id"`
+	ConnectionID   *int64     `bun:",nullzero" json:"connection_id"`
+	ConnectionName string     `bun:",notnull" json:"connection_name"`
+	JobID          string     `bun:",nullzero" json:"job_id,omitempty"`
+	Status         string     `bun:",notnull" json:"status"`
+	ErrorCode      string     `bun:",nullzero" json:"error_code,omitempty"`
+	ErrorMessage   string     `bun:",nullzero" json:"error_message,omitempty"`
+	StartedAt      *time.Time `bun:",nullzero" json:"started_at"`
+	FinishedAt     *time.Time `bun:",nullzero" json:"finished_at"`
+	DurationMS     *int64     `bun:"duration_ms,nullzero" json:"duration_ms"`
+}
+
+// InsertDeployment records a queued deployment and its pending targets in
+// one transaction. Targets are numbered in the order given.
+func (db *DB) InsertDeployment(ctx context.Context, deployment *Deployment, targets []DeploymentTarget) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	deployment.Status = DeploymentStatusQueued
+	deployment.CreatedAt = time.Now()
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(deployment).Returning("id").Exec(ctx); err != nil {
+			return err
+		}
+		for i := range targets {
+			targets[i].DeploymentID = deployment.ID
+			targets[i].Position = i + 1
+			targets[i].Status = DeploymentTargetStatusPending
+		}
+		if len(targets) > 0 {
+			if _, err := tx.NewInsert().Model(&targets).Returning("id").Exec(ctx); err != nil {
+				return err
+			}
+		}
+		deployment.Targets = targets
+		return nil
+	})
+}
+
+// GetDeployment returns a deployment with all of its targets.
+func (db *DB) GetDeployment(ctx context.Context, id int64) (Deployment, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var deployment Deployment
+	err := db.NewSelect().Model(&deployment).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Deployment{}, false, nil
+	}
+	if err != nil {
+		return Deployment{}, false, err
+	}
+	err = db.NewSelect().Model(&deployment.Targets).
+		Where("deployment_id = ?", id).
+		OrderExpr("position ASC").
+		Scan(ctx)
+	if err != nil {
+		return Deployment{}, false, err
+	}
+	return deployment, true, nil
+}
+
+// GetDeploymentTarget returns one deployment target by ID.
+func (db *DB) GetDeploymentTarget(ctx context.Context, id int64) (DeploymentTarget, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var target DeploymentTarget
+	err := db.NewSelect().Model(&target).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DeploymentTarget{}, false, nil
+	}
+	return target, err == nil, err
+}
+
+// SetDeploymentTargetJob records the job queued to run a target.
+func (db *DB) SetDeploymentTargetJob(ctx context.Context, targetID int64, jobID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*DeploymentTarget)(nil)).
+		Set("job_id = ?", jobID).
+		Where("id = ?", targetID).
+		Exec(ctx)
+	return err
+}
+
+// StartDeploymentTarget moves a pending target, and its deployment if still
+// queued, to running. It reports false when the target was no longer pending.
+func (db *DB) StartDeploymentTarget(ctx context.Context, target DeploymentTarget, startedAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	started := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().Model((*DeploymentTarget)(nil)).
+			Set("status = ?", DeploymentTargetStatusRunning).
+			Set("started_at = ?", startedAt).
+			Where("id = ? AND status = ?", target.ID, DeploymentTargetStatusPending).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+		started = true
+		_, err = tx.NewUpdate().Model((*Deployment)(nil)).
+			Set("status = ?", DeploymentStatusRunning).
+			Where("id = ? AND status = ?", target.DeploymentID, DeploymentStatusQueued).
+			Exec(ctx)
+		return err
+	})
+	return started, err
+}
+
+// FinishDeploymentTarget records a target's outcome. A running target also
+// records how long it ran.
+func (db *DB) FinishDeploymentTarget(ctx context.Context, target DeploymentTarget, status, errorCode, errorMessage string, finishedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	q := db.NewUpdate().Model((*DeploymentTarget)(nil)).
+		Set("status = ?", status).
+		Set("error_code = ?", nullableText(errorCode)).
+		Set("error_message = ?", nullableText(errorMessage)).
+		Set("finished_at = ?", finishedAt)
+	if target.StartedAt != nil {
+		q = q.Set("duration_ms = ?", finishedAt.Sub(*target.StartedAt).Milliseconds())
+	}
+	_, err := q.Where("id = ?", target.ID).Exec(ctx)
+	return err
+}
+
+// FinishDeployment records a deployment's outcome and skips any targets that
+// had not started.
+func (db *DB) FinishDeployment(ctx context.Context, deploymentID int64, status string, finishedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().Model((*DeploymentTarget)(nil)).
+			Set("status = ?", DeploymentTargetStatusSkipped).
+			Where("deployment_id = ? AND status = ?", deploymentID, DeploymentTargetStatusPending).
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewUpdate().Model((*Deployment)(nil)).
+			Set("status = ?", status).
+			Set("finished_at = ?", finishedAt).
+			Where("id = ? AND finished_at IS NULL", deploymentID).
+			Exec(ctx)
+		return err
+	})
+}
+
+// NextDeploymentTarget returns the pending target after position, if any.
+func (db *DB) NextDeploymentTarget(ctx context.Context, deploymentID int64, position int) (DeploymentTarget, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var target DeploymentTarget
+	err := db.NewSelect().Model(&target).
+		Where("deployment_id = ? AND position > ? AND status = ?", deploymentID, position, DeploymentTargetStatusPending).
+		OrderExpr("position ASC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DeploymentTarget{}, false, nil
+	}
+	return target, err == nil, err
+}
+
+// ListEnvironmentDeployments lists deployments that targeted an environment,
+// newest first. Each deployment carries only its targets in that environment.
+func (db *DB) ListEnvironmentDeployments(ctx context.Context, environmentID int64, page, pageSize int) (response.Paginated[Deployment], error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var deployments []Deployment
+	q := db.NewSelect().Model(&deployments).
+		Where("id IN (SELECT deployment_id FROM deployment_targets WHERE environment_id = ?)", environmentID)
+	total, err := q.Count(ctx)
+	if err != nil {
+		return response.Paginated[Deployment]{}, err
+	}
+	err = q.OrderExpr("created_at DESC, id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Scan(ctx)
+	if err != nil {
+		return response.Paginated[Deployment]{}, err
+	}
+	if deployments == nil {
+		deployments = []Deployment{}
+	}
+
+	if len(deployments) > 0 {
+		ids := make([]int64, len(deployments))
+		index := make(map[int64]int, len(deployments))
+		for i, deployment := range deployments {
+			ids[i] = deployment.ID
+			index[deployment.ID] = i
+		}
+		var targets []DeploymentTarget
+		err = db.NewSelect().Model(&targets).
+			Where("deployment_id IN (?) AND environment_id = ?", bun.In(ids), environmentID).
+			OrderExpr("position ASC").
+			Scan(ctx)
+		if err != nil {
+			return response.Paginated[Deployment]{}, err
+		}
+		for _, target := range targets {
+			i := index[target.DeploymentID]
+			deployments[i].Targets = append(deployments[i].Targets, target)
+		}
+	}
+
+	return response.Paginated[Deployment]{
+		Items:    deployments,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}, nil
+}
+
+// nullableText stores an empty string as NULL.
+func nullableText(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// OrganizationEgressPolicy narrows the instance egress policy for target
+// connections in one organization. Entries are host names, IP addresses, or
+// CIDR ranges.
+type OrganizationEgressPolicy struct {
+	bun.BaseModel `bun:"table:organization_egress_policies"`
+
+	OrgID          int64     `bun:",pk"      json:"-"`
+	AllowedTargets []string  `bun:",notnull" json:"allowed_targets"`
+	DeniedTargets  []string  `bun:",notnull" json:"denied_targets"`
+	CreatedAt      time.Time `bun:",notnull" json:"created_at"`
+	UpdatedAt      time.Time `bun:",notnull" json:"updated_at"`
+}
+
+func (db *DB) GetOrganizationEgressPolicy(ctx context.Context, orgID int64) (OrganizationEgressPolicy, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var policy OrganizationEgressPolicy
+	err := db.NewSelect().Model(&policy).Where("org_id = ?", orgID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrganizationEgressPolicy{}, false, nil
+	}
+	if err != nil {
+		return OrganizationEgressPolicy{}, false, err
+	}
+	return policy, true, nil
+}
+
+// UpsertOrganizationEgressPolicy creates or replaces the organization's
+// policy.
+func (db *DB) UpsertOrganizationEgressPolicy(ctx context.Context, orgID int64, allowed, denied []string) (OrganizationEgressPolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if allowed == nil {
+		allowed = []string{}
+	}
+	if denied == nil {
+		denied = []string{}
+	}
+	now := time.Now()
+	policy := OrganizationEgressPolicy{
+		OrgID:          orgID,
+		AllowedTargets: allowed,
+		DeniedTargets:  denied,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	err := db.NewInsert().
+		Model(&policy).
+		On("CONFLICT (org_id) DO UPDATE").
+		Set("allowed_targets = EXCLUDED.allowed_targets").
+		Set("denied_targets = EXCLUDED.denied_targets").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Scan(ctx)
+	return policy, err
+}
+
+// DeleteOrganizationEgressPolicy removes the organization's policy, leaving
+// only the instance policy. It reports whether there was one.
+func (db *DB) DeleteOrganizationEgressPolicy(ctx context.Context, orgID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().Model((*OrganizationEgressPolicy)(nil)).Where("org_id = ?", orgID).Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
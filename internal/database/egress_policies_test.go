@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestOrganizationEgressPolicy(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "egress-org", "Egress Org")
+			assert.Nil(t, err)
+			_, found, err := db.GetOrganizationEgressPolicy(ctx, org.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			_, err = db.UpsertOrganizationEgressPolicy(ctx, org.ID, []string{"10.0.0.0/8"}, nil)
+			assert.Nil(t, err)
+			policy, err := db.UpsertOrganizationEgressPolicy(ctx, org.ID, []string{"*.db.example.com"}, []string{"10.9.0.0/16"})
+			assert.Nil(t, err)
+			assert.Equal(t, policy.AllowedTargets, []string{"*.db.example.com"})
+
+			policy, found, err = db.GetOrganizationEgressPolicy(ctx, org.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, policy.AllowedTargets, []string{"*.db.example.com"})
+			assert.Equal(t, policy.DeniedTargets, []string{"10.9.0.0/16"})
+
+			deleted, err := db.DeleteOrganizationEgressPolicy(ctx, org.ID)
+			assert.Nil(t, err)
+			assert.True(t, deleted)
+			deleted, err = db.DeleteOrganizationEgressPolicy(ctx, org.ID)
+			assert.Nil(t, err)
+			assert.False(t, deleted)
+		})
+	}
+}
@@ -13,12 +13,42 @@ import (
 )
 
 type Environment struct {
-	ID          int64     `bun:",pk,autoincrement" json:"id"`
-	WorkspaceID int64     `bun:",notnull"          json:"workspace_id"`
-	Name        string    `bun:",notnull"          json:"name"`
-	Description string    `bun:",nullzero"         json:"description,omitempty"`
-	CreatedAt   time.Time `bun:",notnull"          json:"created_at"`
-	UpdatedAt   time.Time `bun:",notnull"          json:"updated_at"`
+	ID          int64  `bun:",pk,autoincrement" json:"id"`
+	WorkspaceID int64  `bun:",notnull"          json:"workspace_id"`
+	Name        string `bun:",notnull"          json:"name"`
+	Description string `bun:",nullzero"         json:"description,omitempty"`
+	// QueryMaxExecutionSeconds tightens the organization's statement time
+	// limit for connections in this environment. Nil inherits it.
+	QueryMaxExecutionSeconds *int64 `json:"query_max_execution_seconds,omitempty"`
+	// SessionInit is the default session initialization for connections in
+	// this environment that have none of their own.
+	SessionInit *SessionInit `json:"session_init,omitempty"`
+	// ProtectionLevel is one of the EnvironmentProtection levels. Production
+	// environments get query guardrails.
+	ProtectionLevel string `bun:",notnull" json:"protection_level"`
+	// DDLWindows are the weekly periods in which DDL may run in a production
+	// environment.
+	DDLWindows []DDLWindow `bun:",nullzero" json:"ddl_windows,omitempty"`
+	CreatedAt  time.Time   `bun:",notnull"  json:"created_at"`
+	UpdatedAt  time.Time   `bun:",notnull"  json:"updated_at"`
+}
+
+// Environment protection levels. Development and staging only label the
+// environment; production also requires confirming writes by name, refuses
+// statements that would affect every row, and confines DDL to DDLWindows.
+const (
+	EnvironmentProtectionDevelopment = "development"
+	EnvironmentProtectionStaging     = "staging"
+	EnvironmentProtectionProduction  = "production"
+)
+
+// DDLWindow is a weekly period in a time zone. It opens at Start on each of
+// Days and closes at End, on the next day when End is not after Start.
+type DDLWindow struct {
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	TimeZone string   `json:"time_zone,omitempty"`
 }
 
 type ListEnvironmentsParams struct {
@@ -49,11 +79,12 @@ func (db *DB) InsertEnvironment(ctx context.Context, workspaceID int64, name, de
 
 func (db *DB) insertEnvironmentWithExecutor(ctx context.Context, exec bun.IDB, workspaceID int64, name, description string) (Environment, error) {
 	env := Environment{
-		WorkspaceID: workspaceID,
-		Name:        name,
-		Description: description,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		WorkspaceID:     workspaceID,
+		Name:            name,
+		Description:     description,
+		ProtectionLevel: EnvironmentProtectionDevelopment,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 	_, err := exec.NewInsert().Model(&env).Returning("id").Exec(ctx)
 	if err != nil {
@@ -267,6 +298,49 @@ func (db *DB) UpdateEnvironment(ctx context.Context, id int64, name, description
 	return err
 }
 
+// UpdateEnvironmentQueryMaxExecution sets or, with nil, clears the
+// environment's statement time limit.
+func (db *DB) UpdateEnvironmentQueryMaxExecution(ctx context.Context, id int64, seconds *int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Environment)(nil)).
+		Set("query_max_execution_seconds = ?", seconds).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateEnvironmentSessionInit sets or, with nil, clears the environment's
+// default session initialization.
+func (db *DB) UpdateEnvironmentSessionInit(ctx context.Context, id int64, init *SessionInit) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().Model((*Environment)(nil)).
+		Set("session_init = ?", init).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// UpdateEnvironmentProtection sets the environment's protection level and
+// DDL windows.
+func (db *DB) UpdateEnvironmentProtection(ctx context.Context, id int64, level string, windows []DDLWindow) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if len(windows) == 0 {
+		windows = nil
+	}
+	_, err := db.NewUpdate().Model((*Environment)(nil)).
+		Set("protection_level = ?", level).
+		Set("ddl_windows = ?", windows).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
 func (db *DB) DeleteEnvironment(ctx context.Context, id int64) error {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
@@ -56,6 +56,33 @@ func TestEnvironmentCRUD(t *testing.T) {
 	if found.Name != "production" || found.Description != "Updated env" {
 		t.Fatalf("unexpected updated environment: %+v", found)
 	}
+	if found.ProtectionLevel != EnvironmentProtectionDevelopment || found.DDLWindows != nil {
+		t.Fatalf("unexpected default protection: %+v", found)
+	}
+
+	windows := []DDLWindow{{Days: []string{"sat"}, Start: "02:00", End: "04:00", TimeZone: "UTC"}}
+	err = db.UpdateEnvironmentProtection(context.Background(), env.ID, EnvironmentProtectionProduction, windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _, err = db.GetEnvironment(context.Background(), env.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ProtectionLevel != EnvironmentProtectionProduction || len(found.DDLWindows) != 1 || found.DDLWindows[0].Start != "02:00" {
+		t.Fatalf("unexpected protection: %+v", found)
+	}
+	err = db.UpdateEnvironmentProtection(context.Background(), env.ID, EnvironmentProtectionStaging, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _, err = db.GetEnvironment(context.Background(), env.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ProtectionLevel != EnvironmentProtectionStaging || found.DDLWindows != nil {
+		t.Fatalf("unexpected protection after clearing windows: %+v", found)
+	}
 
 	err = db.DeleteEnvironment(context.Background(), env.ID)
 	if err != nil {
@@ -16,6 +16,7 @@ const (
 	DefaultQueryMaxResultBytes            int64 = 26214400
 	DefaultExportsSyncMaxBytes            int64 = 104857600
 	DefaultExportsBackgroundMaxBytes      int64 = 0
+	DefaultQueryMaxExecutionSeconds       int64 = 0
 	DefaultSchemaSnapshotFreshnessSeconds int64 = 86400
 	DefaultFileRevisionsKeepLatest              = 50
 	DefaultJobsWorkerCount                      = 16
@@ -25,6 +26,9 @@ const (
 	DefaultSMTPPort                             = 25
 	DefaultQueryHistoryRetentionCount           = 500
 	DefaultQueryHistoryRetentionCountMax        = 5000
+	DefaultLoginLockoutThreshold                = 10
+	DefaultLoginIPLockoutThreshold              = 100
+	DefaultLoginLockoutSeconds            int64 = 900
 )
 
 type InstanceSettings struct {
@@ -39,6 +43,7 @@ type InstanceSettings struct {
 	QueryMaxResultRows             int       `bun:",notnull" json:"query_max_result_rows"`
 	QueryCursorPageSize            int       `bun:",notnull" json:"query_cursor_page_size"`
 	QueryMaxResultBytes            int64     `bun:",notnull" json:"query_max_result_bytes"`
+	QueryMaxExecutionSeconds       int64     `bun:",notnull" json:"query_max_execution_seconds"`
 	ExportsSyncMaxBytes            int64     `bun:",notnull" json:"exports_sync_max_bytes"`
 	ExportsBackgroundMaxBytes      int64     `bun:",notnull" json:"exports_background_max_bytes"`
 	SchemaSnapshotFreshnessSeconds int64     `bun:",notnull" json:"schema_snapshot_freshness_seconds"`
@@ -62,6 +67,11 @@ type InstanceSettings struct {
 	QueryHistoryRetentionCount     int       `bun:",notnull" json:"query_history_retention_count"`
 	QueryHistoryRetentionCountMax  int       `bun:",notnull" json:"query_history_retention_count_max"`
 	QueryFavoritesMode             string    `bun:",notnull" json:"query_favorites_mode"`
+	MFARequired                    bool      `bun:"mfa_required,notnull" json:"mfa_required"`
+	WebAuthnUserVerification       string    `bun:"webauthn_user_verification,notnull" json:"webauthn_user_verification"`
+	LoginLockoutThreshold          int       `bun:",notnull" json:"login_lockout_threshold"`
+	LoginIPLockoutThreshold        int       `bun:"login_ip_lockout_threshold,notnull" json:"login_ip_lockout_threshold"`
+	LoginLockoutSeconds            int64     `bun:",notnull" json:"login_lockout_seconds"`
 	CreatedAt                      time.Time `bun:",notnull" json:"created_at"`
 	UpdatedAt                      time.Time `bun:",notnull" json:"updated_at"`
 }
@@ -72,6 +82,7 @@ type OrganizationRuntimeSettings struct {
 	OrgID                          int64     `bun:",pk" json:"-"`
 	QueryMaxResultRows             *int      `json:"query_max_result_rows"`
 	QueryMaxResultBytes            *int64    `json:"query_max_result_bytes"`
+	QueryMaxExecutionSeconds       *int64    `json:"query_max_execution_seconds"`
 	ExportsSyncMaxBytes            *int64    `json:"exports_sync_max_bytes"`
 	ExportsBackgroundMaxBytes      *int64    `json:"exports_background_max_bytes"`
 	SchemaSnapshotFreshnessSeconds *int64    `json:"schema_snapshot_freshness_seconds"`
@@ -94,6 +105,7 @@ func DefaultInstanceSettings() InstanceSettings {
 		QueryMaxResultRows:             DefaultQueryMaxResultRows,
 		QueryCursorPageSize:            DefaultQueryCursorPageSize,
 		QueryMaxResultBytes:            DefaultQueryMaxResultBytes,
+		QueryMaxExecutionSeconds:       DefaultQueryMaxExecutionSeconds,
 		ExportsSyncMaxBytes:            DefaultExportsSyncMaxBytes,
 		ExportsBackgroundMaxBytes:      DefaultExportsBackgroundMaxBytes,
 		SchemaSnapshotFreshnessSeconds: DefaultSchemaSnapshotFreshnessSeconds,
@@ -109,6 +121,10 @@ func DefaultInstanceSettings() InstanceSettings {
 		QueryHistoryRetentionCount:     DefaultQueryHistoryRetentionCount,
 		QueryHistoryRetentionCountMax:  DefaultQueryHistoryRetentionCountMax,
 		QueryFavoritesMode:             "backend",
+		WebAuthnUserVerification:       "preferred",
+		LoginLockoutThreshold:          DefaultLoginLockoutThreshold,
+		LoginIPLockoutThreshold:        DefaultLoginIPLockoutThreshold,
+		LoginLockoutSeconds:            DefaultLoginLockoutSeconds,
 	}
 }
 
@@ -149,6 +165,7 @@ func (db *DB) UpsertInstanceSettings(ctx context.Context, settings InstanceSetti
 		Set("query_max_result_rows = EXCLUDED.query_max_result_rows").
 		Set("query_cursor_page_size = EXCLUDED.query_cursor_page_size").
 		Set("query_max_result_bytes = EXCLUDED.query_max_result_bytes").
+		Set("query_max_execution_seconds = EXCLUDED.query_max_execution_seconds").
 		Set("exports_sync_max_bytes = EXCLUDED.exports_sync_max_bytes").
 		Set("exports_background_max_bytes = EXCLUDED.exports_background_max_bytes").
 		Set("schema_snapshot_freshness_seconds = EXCLUDED.schema_snapshot_freshness_seconds").
@@ -172,6 +189,11 @@ func (db *DB) UpsertInstanceSettings(ctx context.Context, settings InstanceSetti
 		Set("query_history_retention_count = EXCLUDED.query_history_retention_count").
 		Set("query_history_retention_count_max = EXCLUDED.query_history_retention_count_max").
 		Set("query_favorites_mode = EXCLUDED.query_favorites_mode").
+		Set("mfa_required = EXCLUDED.mfa_required").
+		Set("webauthn_user_verification = EXCLUDED.webauthn_user_verification").
+		Set("login_lockout_threshold = EXCLUDED.login_lockout_threshold").
+		Set("login_ip_lockout_threshold = EXCLUDED.login_ip_lockout_threshold").
+		Set("login_lockout_seconds = EXCLUDED.login_lockout_seconds").
 		Set("updated_at = EXCLUDED.updated_at").
 		Exec(ctx)
 	if err != nil {
@@ -238,6 +260,7 @@ func (db *DB) UpsertOrganizationRuntimeSettings(ctx context.Context, settings Or
 		On("CONFLICT (org_id) DO UPDATE").
 		Set("query_max_result_rows = EXCLUDED.query_max_result_rows").
 		Set("query_max_result_bytes = EXCLUDED.query_max_result_bytes").
+		Set("query_max_execution_seconds = EXCLUDED.query_max_execution_seconds").
 		Set("exports_sync_max_bytes = EXCLUDED.exports_sync_max_bytes").
 		Set("exports_background_max_bytes = EXCLUDED.exports_background_max_bytes").
 		Set("schema_snapshot_freshness_seconds = EXCLUDED.schema_snapshot_freshness_seconds").
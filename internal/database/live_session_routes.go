@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Kinds of live session route.
+const (
+	LiveRouteSession     = "session"
+	LiveRouteQueryCursor = "query_cursor"
+)
+
+// ServerReplica is one running server process. Replicas refresh LastSeenAt
+// while they run; one that stops doing so is considered gone.
+type ServerReplica struct {
+	bun.BaseModel `bun:"table:server_replicas,alias:sr"`
+
+	ID           string    `bun:",pk"      json:"id"`
+	AdvertiseURL string    `bun:",notnull" json:"advertise_url"`
+	Draining     bool      `bun:",notnull" json:"draining"`
+	StartedAt    time.Time `bun:",notnull" json:"started_at"`
+	LastSeenAt   time.Time `bun:",notnull" json:"last_seen_at"`
+}
+
+// LiveSessionRoute records which replica holds a live session or query
+// cursor. A query cursor's SessionID is its parent session; a session's is its
+// own ID. Routes are removed with their replica.
+type LiveSessionRoute struct {
+	bun.BaseModel `bun:"table:live_session_routes,alias:lsr"`
+
+	ID           string    `bun:",pk"      json:"id"`
+	Kind         string    `bun:",notnull" json:"kind"`
+	SessionID    string    `bun:",notnull" json:"session_id"`
+	ReplicaID    string    `bun:",notnull" json:"replica_id"`
+	AccountID    int64     `bun:",notnull" json:"account_id"`
+	ConnectionID int64     `bun:",notnull" json:"connection_id"`
+	CreatedAt    time.Time `bun:",notnull" json:"created_at"`
+
+	// Replica columns, filled by GetLiveSessionRoute.
+	ReplicaURL        string    `bun:",scanonly" json:"-"`
+	ReplicaDraining   bool      `bun:",scanonly" json:"-"`
+	ReplicaLastSeenAt time.Time `bun:",scanonly" json:"-"`
+}
+
+// UpsertServerReplica registers the replica or refreshes its registration,
+// marking it seen now.
+func (db *DB) UpsertServerReplica(ctx context.Context, id, advertiseURL string, draining bool) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	replica := ServerReplica{ID: id, AdvertiseURL: advertiseURL, Draining: draining, StartedAt: now, LastSeenAt: now}
+	_, err := db.NewInsert().
+		Model(&replica).
+		On("CONFLICT (id) DO UPDATE").
+		Set("advertise_url = EXCLUDED.advertise_url").
+		Set("draining = EXCLUDED.draining").
+		Set("last_seen_at = EXCLUDED.last_seen_at").
+		Exec(ctx)
+	return err
+}
+
+// DeleteServerReplica unregisters the replica along with its routes.
+func (db *DB) DeleteServerReplica(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewDelete().Model((*ServerReplica)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+// DeleteStaleServerReplicas unregisters replicas last seen before cutoff,
+// which stopped without unregistering, along with their routes.
+func (db *DB) DeleteStaleServerReplicas(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().Model((*ServerReplica)(nil)).Where("last_seen_at < ?", cutoff).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (db *DB) InsertLiveSessionRoute(ctx context.Context, route LiveSessionRoute) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if route.CreatedAt.IsZero() {
+		route.CreatedAt = time.Now()
+	}
+	_, err := db.NewInsert().Model(&route).Exec(ctx)
+	return err
+}
+
+// GetLiveSessionRoute returns the route for a session or query cursor ID,
+// with its replica's address and status.
+func (db *DB) GetLiveSessionRoute(ctx context.Context, id string) (LiveSessionRoute, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var route LiveSessionRoute
+	err := db.NewSelect().
+		Model(&route).
+		ColumnExpr("lsr.*").
+		ColumnExpr("sr.advertise_url AS replica_url").
+		ColumnExpr("sr.draining AS replica_draining").
+		ColumnExpr("sr.last_seen_at AS replica_last_seen_at").
+		Join("JOIN server_replicas AS sr ON sr.id = lsr.replica_id").
+		Where("lsr.id = ?", id).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LiveSessionRoute{}, false, nil
+	}
+	if err != nil {
+		return LiveSessionRoute{}, false, err
+	}
+	return route, true, nil
+}
+
+// DeleteLiveSessionRoute removes the route for one session or query cursor.
+func (db *DB) DeleteLiveSessionRoute(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewDelete().Model((*LiveSessionRoute)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+// DeleteSessionRoutes removes the routes of the given sessions and of their
+// query cursors.
+func (db *DB) DeleteSessionRoutes(ctx context.Context, sessionIDs []string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewDelete().Model((*LiveSessionRoute)(nil)).Where("session_id IN (?)", bun.In(sessionIDs)).Exec(ctx)
+	return err
+}
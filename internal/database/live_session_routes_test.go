@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestLiveSessionRoutes(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			assert.Nil(t, db.UpsertServerReplica(ctx, "replica-a", "http://10.0.0.1:6020", false))
+			assert.Nil(t, db.UpsertServerReplica(ctx, "replica-b", "http://10.0.0.2:6020", false))
+			assert.Nil(t, db.InsertLiveSessionRoute(ctx, LiveSessionRoute{
+				ID: "sess-1", Kind: LiveRouteSession, SessionID: "sess-1", ReplicaID: "replica-a", AccountID: 1, ConnectionID: 2,
+			}))
+			assert.Nil(t, db.InsertLiveSessionRoute(ctx, LiveSessionRoute{
+				ID: "cursor-1", Kind: LiveRouteQueryCursor, SessionID: "sess-1", ReplicaID: "replica-a", AccountID: 1, ConnectionID: 2,
+			}))
+			assert.Nil(t, db.InsertLiveSessionRoute(ctx, LiveSessionRoute{
+				ID: "sess-2", Kind: LiveRouteSession, SessionID: "sess-2", ReplicaID: "replica-b", AccountID: 1, ConnectionID: 3,
+			}))
+
+			assert.Nil(t, db.UpsertServerReplica(ctx, "replica-a", "http://10.0.0.9:6020", true))
+			route, found, err := db.GetLiveSessionRoute(ctx, "cursor-1")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, route.SessionID, "sess-1")
+			assert.Equal(t, route.ReplicaURL, "http://10.0.0.9:6020")
+			assert.True(t, route.ReplicaDraining)
+
+			assert.Nil(t, db.DeleteSessionRoutes(ctx, []string{"sess-1"}))
+			_, found, err = db.GetLiveSessionRoute(ctx, "cursor-1")
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			removed, err := db.DeleteStaleServerReplicas(ctx, time.Now().Add(time.Minute))
+			assert.Nil(t, err)
+			assert.Equal(t, removed, int64(2))
+			_, found, err = db.GetLiveSessionRoute(ctx, "sess-2")
+			assert.Nil(t, err)
+			assert.False(t, found)
+		})
+	}
+}
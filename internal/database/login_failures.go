@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Login failure scopes. Account failures are keyed by the normalized email so
+// that unknown addresses are throttled exactly like real accounts.
+const (
+	LoginFailureScopeAccount = "account"
+	LoginFailureScopeIP      = "ip"
+)
+
+// LoginFailure counts recent failed sign-in attempts for one subject.
+type LoginFailure struct {
+	bun.BaseModel `bun:"table:login_failures"`
+
+	Scope        string     `bun:",pk"`
+	Subject      string     `bun:",pk"`
+	Failures     int        `bun:",notnull"`
+	LastFailedAt time.Time  `bun:",notnull"`
+	LockedUntil  *time.Time `bun:",nullzero"`
+}
+
+// Locked reports whether the subject is locked out at now.
+func (f LoginFailure) Locked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
+
+func (db *DB) GetLoginFailure(ctx context.Context, scope, subject string) (LoginFailure, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var failure LoginFailure
+	err := db.NewSelect().
+		Model(&failure).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LoginFailure{}, false, nil
+	}
+	if err != nil {
+		return LoginFailure{}, false, err
+	}
+	return failure, true, nil
+}
+
+// RecordLoginFailure counts a failed attempt for subject. Failures older than
+// window, and failures from before an expired lockout, are forgotten first.
+// Once threshold failures are counted the subject is locked for window; the
+// second result is true only for the attempt that caused the lockout. A
+// threshold of 0 never locks.
+func (db *DB) RecordLoginFailure(ctx context.Context, scope, subject string, threshold int, window time.Duration) (LoginFailure, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var failure LoginFailure
+	locked := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		_, err := tx.NewDelete().
+			Model((*LoginFailure)(nil)).
+			Where("last_failed_at < ?", now.Add(-window)).
+			Where("locked_until IS NULL OR locked_until < ?", now).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().
+			Model(&LoginFailure{Scope: scope, Subject: subject, LastFailedAt: now}).
+			On("CONFLICT (scope, subject) DO NOTHING").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		// The increment is a single statement so concurrent failures are
+		// all counted.
+		_, err = tx.NewUpdate().
+			Model(&failure).
+			Set("failures = CASE WHEN last_failed_at < ? OR locked_until < ? THEN 1 ELSE failures + 1 END", now.Add(-window), now).
+			Set("locked_until = CASE WHEN locked_until < ? THEN NULL ELSE locked_until END", now).
+			Set("last_failed_at = ?", now).
+			Where("scope = ? AND subject = ?", scope, subject).
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if threshold <= 0 || failure.Failures < threshold || failure.LockedUntil != nil {
+			return nil
+		}
+		until := now.Add(window)
+		res, err := tx.NewUpdate().
+			Model((*LoginFailure)(nil)).
+			Set("locked_until = ?", until).
+			Where("scope = ? AND subject = ? AND locked_until IS NULL", scope, subject).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 1 {
+			failure.LockedUntil = &until
+			locked = true
+		}
+		return nil
+	})
+	if err != nil {
+		return LoginFailure{}, false, err
+	}
+	return failure, locked, nil
+}
+
+// ClearLoginFailures forgets failed attempts and any lockout for subject. It
+// reports whether the subject was locked.
+func (db *DB) ClearLoginFailures(ctx context.Context, scope, subject string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var cleared []LoginFailure
+	err := db.NewDelete().
+		Model(&cleared).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, failure := range cleared {
+		if failure.Locked(time.Now()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestLoginFailuresLockAndClear(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			for i := 1; i <= 2; i++ {
+				failure, locked, err := db.RecordLoginFailure(ctx, LoginFailureScopeAccount, "a@example.com", 3, time.Minute)
+				assert.Nil(t, err)
+				assert.False(t, locked)
+				assert.Equal(t, failure.Failures, i)
+			}
+			failure, locked, err := db.RecordLoginFailure(ctx, LoginFailureScopeAccount, "a@example.com", 3, time.Minute)
+			assert.Nil(t, err)
+			assert.True(t, locked)
+			assert.True(t, failure.Locked(time.Now()))
+
+			// Further failures keep the lockout without reporting it again.
+			failure, locked, err = db.RecordLoginFailure(ctx, LoginFailureScopeAccount, "a@example.com", 3, time.Minute)
+			assert.Nil(t, err)
+			assert.False(t, locked)
+			assert.Equal(t, failure.Failures, 4)
+
+			other, found, err := db.GetLoginFailure(ctx, LoginFailureScopeIP, "a@example.com")
+			assert.Nil(t, err)
+			assert.False(t, found)
+			assert.Equal(t, other.Failures, 0)
+
+			cleared, err := db.ClearLoginFailures(ctx, LoginFailureScopeAccount, "a@example.com")
+			assert.Nil(t, err)
+			assert.True(t, cleared)
+			_, found, err = db.GetLoginFailure(ctx, LoginFailureScopeAccount, "a@example.com")
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			// Failures older than the window are forgotten.
+			_, _, err = db.RecordLoginFailure(ctx, LoginFailureScopeIP, "192.0.2.1", 0, time.Millisecond)
+			assert.Nil(t, err)
+			time.Sleep(5 * time.Millisecond)
+			failure, locked, err = db.RecordLoginFailure(ctx, LoginFailureScopeIP, "192.0.2.1", 0, time.Millisecond)
+			assert.Nil(t, err)
+			assert.False(t, locked)
+			assert.Equal(t, failure.Failures, 1)
+		})
+	}
+}
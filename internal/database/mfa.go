@@ -0,0 +1,380 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// MFA methods recorded on auth sessions.
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+	MFAMethodWebAuthn     = "webauthn"
+	// MFAMethodSSO marks an SSO sign-in where the identity provider reported
+	// a second factor, or is trusted to enforce one.
+	MFAMethodSSO = "sso"
+)
+
+// AccountMFA is an account's TOTP enrollment. EnabledAt is nil while the
+// enrollment waits for its first code.
+type AccountMFA struct {
+	bun.BaseModel `bun:"table:account_mfa"`
+
+	AccountID           int64      `bun:",pk"       json:"account_id"`
+	TOTPSecretEncrypted string     `bun:"totp_secret_encrypted,notnull" json:"-"`
+	TOTPLastStep        int64      `bun:"totp_last_step,notnull"        json:"-"`
+	EnabledAt           *time.Time `bun:",nullzero" json:"enabled_at"`
+	CreatedAt           time.Time  `bun:",notnull"  json:"created_at"`
+	UpdatedAt           time.Time  `bun:",notnull"  json:"updated_at"`
+}
+
+// Enabled reports whether the enrollment has been confirmed.
+func (m AccountMFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code.
+type MFARecoveryCode struct {
+	bun.BaseModel `bun:"table:account_mfa_recovery_codes"`
+
+	ID        string     `bun:",pk"`
+	AccountID int64      `bun:",notnull"`
+	CodeHash  string     `bun:",notnull"`
+	UsedAt    *time.Time `bun:",nullzero"`
+	CreatedAt time.Time  `bun:",notnull"`
+}
+
+// MFALoginChallenge is a password sign-in waiting for its second factor. ID is
+// the hash of the challenge token handed to the client.
+type MFALoginChallenge struct {
+	bun.BaseModel `bun:"table:mfa_login_challenges"`
+
+	ID        string    `bun:",pk"`
+	AccountID int64     `bun:",notnull"`
+	Attempts  int       `bun:",notnull"`
+	ExpiresAt time.Time `bun:",notnull"`
+	CreatedAt time.Time `bun:",notnull"`
+}
+
+func (db *DB) GetAccountMFA(ctx context.Context, accountID int64) (AccountMFA, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var mfa AccountMFA
+	err := db.NewSelect().Model(&mfa).Where("account_id = ?", accountID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AccountMFA{}, false, nil
+	}
+	if err != nil {
+		return AccountMFA{}, false, err
+	}
+	return mfa, true, nil
+}
+
+// ListAccountMFA returns every enrollment, for key rotation.
+func (db *DB) ListAccountMFA(ctx context.Context) ([]AccountMFA, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var enrollments []AccountMFA
+	err := db.NewSelect().Model(&enrollments).OrderExpr("account_id ASC").Scan(ctx)
+	return enrollments, err
+}
+
+// StartMFAEnrollment stores a pending TOTP secret, replacing an earlier
+// pending one. A confirmed enrollment is left untouched; ok is false then.
+func (db *DB) StartMFAEnrollment(ctx context.Context, accountID int64, secretEncrypted string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	mfa := AccountMFA{AccountID: accountID, TOTPSecretEncrypted: secretEncrypted, CreatedAt: now, UpdatedAt: now}
+	res, err := db.NewInsert().
+		Model(&mfa).
+		On("CONFLICT (account_id) DO UPDATE").
+		Set("totp_secret_encrypted = EXCLUDED.totp_secret_encrypted").
+		Set("totp_last_step = 0").
+		Set("created_at = EXCLUDED.created_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Where("account_mfa.enabled_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// EnableAccountMFA confirms a pending enrollment at the TOTP step its first
+// code matched, issues recovery codes, and marks authSessionID, the session
+// that confirmed it, as having presented a second factor.
+func (db *DB) EnableAccountMFA(ctx context.Context, accountID, step int64, recoveryCodeHashes []string, authSessionID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		_, err := tx.NewUpdate().
+			Model((*AccountMFA)(nil)).
+			Set("enabled_at = ?", now).
+			Set("totp_last_step = ?", step).
+			Set("updated_at = ?", now).
+			Where("account_id = ? AND enabled_at IS NULL", accountID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if err := replaceMFARecoveryCodesWithExecutor(ctx, tx, accountID, recoveryCodeHashes); err != nil {
+			return err
+		}
+		if authSessionID == "" {
+			return nil
+		}
+		_, err = tx.NewUpdate().
+			Model((*AuthSession)(nil)).
+			Set("mfa_method = ?", MFAMethodTOTP).
+			Where("id = ? AND account_id = ?", authSessionID, accountID).
+			Exec(ctx)
+		return err
+	})
+}
+
+// ReplaceMFARecoveryCodes discards the account's recovery codes and stores
+// new ones.
+func (db *DB) ReplaceMFARecoveryCodes(ctx context.Context, accountID int64, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return replaceMFARecoveryCodesWithExecutor(ctx, tx, accountID, codeHashes)
+	})
+}
+
+func replaceMFARecoveryCodesWithExecutor(ctx context.Context, exec bun.IDB, accountID int64, codeHashes []string) error {
+	_, err := exec.NewDelete().
+		Model((*MFARecoveryCode)(nil)).
+		Where("account_id = ?", accountID).
+		Exec(ctx)
+	if err != nil || len(codeHashes) == 0 {
+		return err
+	}
+	now := time.Now()
+	codes := make([]MFARecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = MFARecoveryCode{ID: newID(), AccountID: accountID, CodeHash: hash, CreatedAt: now}
+	}
+	_, err = exec.NewInsert().Model(&codes).Exec(ctx)
+	return err
+}
+
+// CountUnusedMFARecoveryCodes returns how many recovery codes the account
+// has left.
+func (db *DB) CountUnusedMFARecoveryCodes(ctx context.Context, accountID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.NewSelect().
+		Model((*MFARecoveryCode)(nil)).
+		Where("account_id = ? AND used_at IS NULL", accountID).
+		Count(ctx)
+}
+
+// UseTOTPStep records step as the last accepted TOTP step. It reports false
+// when that step, or a later one, was already used, so two requests racing
+// with the same code cannot both succeed.
+func (db *DB) UseTOTPStep(ctx context.Context, accountID, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewUpdate().
+		Model((*AccountMFA)(nil)).
+		Set("totp_last_step = ?", step).
+		Set("updated_at = ?", time.Now()).
+		Where("account_id = ? AND totp_last_step < ?", accountID, step).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// UseMFARecoveryCode marks the account's unused recovery code with codeHash as
+// used. It reports false when there is no such code.
+func (db *DB) UseMFARecoveryCode(ctx context.Context, accountID int64, codeHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewUpdate().
+		Model((*MFARecoveryCode)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("account_id = ? AND code_hash = ? AND used_at IS NULL", accountID, codeHash).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// UpdateAccountMFASecret stores a re-encrypted TOTP secret.
+func (db *DB) UpdateAccountMFASecret(ctx context.Context, accountID int64, secretEncrypted string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*AccountMFA)(nil)).
+		Set("totp_secret_encrypted = ?", secretEncrypted).
+		Where("account_id = ?", accountID).
+		Exec(ctx)
+	return err
+}
+
+// DeleteAccountMFA removes the account's enrollment, recovery codes and
+// pending sign-in challenges. It reports whether there was an enrollment.
+func (db *DB) DeleteAccountMFA(ctx context.Context, accountID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	deleted := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		deleted, err = deleteAccountMFAWithExecutor(ctx, tx, accountID)
+		return err
+	})
+	return deleted, err
+}
+
+// ResetAccountMFA removes every second factor the account has: its TOTP
+// enrollment, recovery codes and WebAuthn credentials. It reports whether
+// there was anything to remove.
+func (db *DB) ResetAccountMFA(ctx context.Context, accountID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	reset := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		deleted, err := deleteAccountMFAWithExecutor(ctx, tx, accountID)
+		if err != nil {
+			return err
+		}
+		res, err := tx.NewDelete().
+			Model((*WebAuthnCredential)(nil)).
+			Where("account_id = ?", accountID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		reset = deleted || affected > 0
+		return err
+	})
+	return reset, err
+}
+
+func deleteAccountMFAWithExecutor(ctx context.Context, exec bun.IDB, accountID int64) (bool, error) {
+	if err := replaceMFARecoveryCodesWithExecutor(ctx, exec, accountID, nil); err != nil {
+		return false, err
+	}
+	_, err := exec.NewDelete().
+		Model((*MFALoginChallenge)(nil)).
+		Where("account_id = ?", accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := exec.NewDelete().
+		Model((*AccountMFA)(nil)).
+		Where("account_id = ?", accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// InsertMFALoginChallenge stores a pending second step and prunes challenges
+// that expired unanswered.
+func (db *DB) InsertMFALoginChallenge(ctx context.Context, challenge MFALoginChallenge) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*MFALoginChallenge)(nil)).
+			Where("expires_at < ?", time.Now()).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		challenge.CreatedAt = time.Now()
+		_, err = tx.NewInsert().Model(&challenge).Exec(ctx)
+		return err
+	})
+}
+
+// GetMFALoginChallenge returns the challenge with id. Expired challenges are
+// reported as not found.
+func (db *DB) GetMFALoginChallenge(ctx context.Context, id string) (MFALoginChallenge, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var challenge MFALoginChallenge
+	err := db.NewSelect().Model(&challenge).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MFALoginChallenge{}, false, nil
+	}
+	if err != nil {
+		return MFALoginChallenge{}, false, err
+	}
+	if !time.Now().Before(challenge.ExpiresAt) {
+		return MFALoginChallenge{}, false, nil
+	}
+	return challenge, true, nil
+}
+
+// RecordMFALoginChallengeFailure counts a wrong code against the challenge
+// and deletes it once maxAttempts is reached, so the password has to be
+// entered again.
+func (db *DB) RecordMFALoginChallengeFailure(ctx context.Context, id string, maxAttempts int) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model((*MFALoginChallenge)(nil)).
+			Set("attempts = attempts + 1").
+			Where("id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*MFALoginChallenge)(nil)).
+			Where("id = ? AND attempts >= ?", id, maxAttempts).
+			Exec(ctx)
+		return err
+	})
+}
+
+// ConsumeMFALoginChallenge deletes the challenge with id. It reports false if
+// another request consumed it first.
+func (db *DB) ConsumeMFALoginChallenge(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().
+		Model((*MFALoginChallenge)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestAccountMFAEnrollmentAndSingleUseFactors(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			account, err := db.InsertAccount(ctx, "mfa@example.com", "MFA", nil)
+			assert.Nil(t, err)
+			session, err := db.InsertAuthSession(ctx, account.ID, time.Now().Add(time.Hour), "agent", "127.0.0.1")
+			assert.Nil(t, err)
+
+			started, err := db.StartMFAEnrollment(ctx, account.ID, "enc:pending")
+			assert.Nil(t, err)
+			assert.True(t, started)
+			assert.Nil(t, db.EnableAccountMFA(ctx, account.ID, 100, []string{"hash-a", "hash-b"}, session.ID))
+
+			started, err = db.StartMFAEnrollment(ctx, account.ID, "enc:other")
+			assert.Nil(t, err)
+			assert.False(t, started)
+			mfa, found, err := db.GetAccountMFA(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.True(t, mfa.Enabled())
+			assert.Equal(t, mfa.TOTPSecretEncrypted, "enc:pending")
+			confirmed, _, err := db.GetAuthSession(ctx, session.ID, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, confirmed.MFAMethod, MFAMethodTOTP)
+
+			used, err := db.UseTOTPStep(ctx, account.ID, 100)
+			assert.Nil(t, err)
+			assert.False(t, used)
+			used, err = db.UseTOTPStep(ctx, account.ID, 101)
+			assert.Nil(t, err)
+			assert.True(t, used)
+
+			used, err = db.UseMFARecoveryCode(ctx, account.ID, "hash-a")
+			assert.Nil(t, err)
+			assert.True(t, used)
+			used, err = db.UseMFARecoveryCode(ctx, account.ID, "hash-a")
+			assert.Nil(t, err)
+			assert.False(t, used)
+			remaining, err := db.CountUnusedMFARecoveryCodes(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, remaining, 1)
+
+			deleted, err := db.DeleteAccountMFA(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, deleted)
+			remaining, err = db.CountUnusedMFARecoveryCodes(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, remaining, 0)
+		})
+	}
+}
+
+func TestMFALoginChallengeIsRemovedAfterMaxAttempts(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			account, err := db.InsertAccount(ctx, "challenge@example.com", "Challenge", nil)
+			assert.Nil(t, err)
+			assert.Nil(t, db.InsertMFALoginChallenge(ctx, MFALoginChallenge{ID: "challenge", AccountID: account.ID, ExpiresAt: time.Now().Add(time.Minute)}))
+
+			assert.Nil(t, db.RecordMFALoginChallengeFailure(ctx, "challenge", 2))
+			challenge, found, err := db.GetMFALoginChallenge(ctx, "challenge")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, challenge.Attempts, 1)
+
+			assert.Nil(t, db.RecordMFALoginChallengeFailure(ctx, "challenge", 2))
+			_, found, err = db.GetMFALoginChallenge(ctx, "challenge")
+			assert.Nil(t, err)
+			assert.False(t, found)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/sqlwarden/internal/response"
+)
+
+// MigrationSet registers a shared workspace folder of numbered SQL files as
+// migrations for target databases. TableName is the tracking table kept on
+// each target.
+type MigrationSet struct {
+	bun.BaseModel `bun:"table:migration_sets"`
+
+	ID          int64     `bun:",pk,autoincrement" json:"id"`
+	OrgID       int64     `bun:",notnull"          json:"org_id"`
+	WorkspaceID int64     `bun:",notnull"          json:"workspace_id"`
+	FolderID    int64     `bun:",notnull"          json:"folder_id"`
+	Name        string    `bun:",notnull"          json:"name"`
+	TableName   string    `bun:",notnull"          json:"table_name"`
+	CreatedBy   int64     `bun:",notnull"          json:"created_by"`
+	CreatedAt   time.Time `bun:",notnull"          json:"created_at"`
+	UpdatedAt   time.Time `bun:",notnull"          json:"updated_at"`
+}
+
+func (db *DB) InsertMigrationSet(ctx context.Context, set MigrationSet) (MigrationSet, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	set.CreatedAt = now
+	set.UpdatedAt = now
+	if _, err := db.NewInsert().Model(&set).Returning("id").Exec(ctx); err != nil {
+		return MigrationSet{}, err
+	}
+	return set, nil
+}
+
+func (db *DB) GetMigrationSet(ctx context.Context, id int64) (MigrationSet, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var set MigrationSet
+	err := db.NewSelect().Model(&set).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MigrationSet{}, false, nil
+	}
+	return set, err == nil, err
+}
+
+func (db *DB) ListMigrationSets(ctx context.Context, workspaceID int64, page, pageSize int) (response.Paginated[MigrationSet], error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var sets []MigrationSet
+	q := db.NewSelect().Model(&sets).Where("workspace_id = ?", workspaceID)
+	total, err := q.Count(ctx)
+	if err != nil {
+		return response.Paginated[MigrationSet]{}, err
+	}
+	err = q.OrderExpr("name ASC, id ASC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Scan(ctx)
+	if err != nil {
+		return response.Paginated[MigrationSet]{}, err
+	}
+	if sets == nil {
+		sets = []MigrationSet{}
+	}
+	return response.Paginated[MigrationSet]{
+		Items:    sets,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}, nil
+}
+
+func (db *DB) DeleteMigrationSet(ctx context.Context, id, workspaceID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().Model((*MigrationSet)(nil)).
+		Where("id = ? AND workspace_id = ?", id, workspaceID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
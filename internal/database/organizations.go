@@ -21,6 +21,7 @@ type Organization struct {
 	Name                            string    `bun:",notnull"          json:"name"`
 	SchemaSnapshotsEnabled          bool      `bun:",notnull,default:true" json:"schema_snapshots_enabled"`
 	MaskConnectionCredentialsOnEdit bool      `bun:",notnull,default:false" json:"mask_connection_credentials_on_edit"`
+	MFARequired                     bool      `bun:"mfa_required,notnull,default:false" json:"mfa_required"`
 	CreatedAt                       time.Time `bun:",notnull"          json:"created_at"`
 	UpdatedAt                       time.Time `bun:",notnull"          json:"updated_at"`
 }
@@ -73,6 +74,7 @@ type OrgMemberListItem struct {
 	AccountID int64     `json:"account_id"`
 	Email     string    `json:"email"`
 	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
 	Role      string    `json:"role"`
 	JoinedAt  time.Time `json:"joined_at"`
 }
@@ -172,7 +174,7 @@ func (db *DB) UpdateOrg(ctx context.Context, id int64, name string) error {
 	return err
 }
 
-func (db *DB) UpdateOrgSettings(ctx context.Context, id int64, name *string, snapshotsEnabled *bool, maskConnectionCredentialsOnEdit *bool) error {
+func (db *DB) UpdateOrgSettings(ctx context.Context, id int64, name *string, snapshotsEnabled *bool, maskConnectionCredentialsOnEdit *bool, mfaRequired *bool) error {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
@@ -186,6 +188,9 @@ func (db *DB) UpdateOrgSettings(ctx context.Context, id int64, name *string, sna
 	if maskConnectionCredentialsOnEdit != nil {
 		q = q.Set("mask_connection_credentials_on_edit = ?", *maskConnectionCredentialsOnEdit)
 	}
+	if mfaRequired != nil {
+		q = q.Set("mfa_required = ?", *mfaRequired)
+	}
 	_, err := q.Exec(ctx)
 	return err
 }
@@ -287,40 +292,44 @@ func (db *DB) RemoveOrgMember(ctx context.Context, orgID, accountID int64) error
 // clears derived memberships, direct account policy bindings, and org sessions.
 func (db *DB) RemoveOrgMemberAccess(ctx context.Context, orgID, accountID int64, revokedBy *int64, reason string) error {
 	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		if _, err := tx.NewDelete().Model((*WorkspaceMember)(nil)).
-			Where("account_id = ?", accountID).
-			Where("workspace_id IN (SELECT id FROM workspaces WHERE owner_type = 'org' AND owner_id = ?)", orgID).
-			Exec(ctx); err != nil {
-			return err
-		}
+		return removeOrgMemberAccessWithExecutor(ctx, tx, orgID, accountID, revokedBy, reason)
+	})
+}
 
-		if _, err := tx.NewDelete().Model((*TeamMember)(nil)).
-			Where("account_id = ?", accountID).
-			Where("team_id IN (SELECT id FROM teams WHERE org_id = ?)", orgID).
-			Exec(ctx); err != nil {
-			return err
-		}
+func removeOrgMemberAccessWithExecutor(ctx context.Context, tx bun.IDB, orgID, accountID int64, revokedBy *int64, reason string) error {
+	if _, err := tx.NewDelete().Model((*WorkspaceMember)(nil)).
+		Where("account_id = ?", accountID).
+		Where("workspace_id IN (SELECT id FROM workspaces WHERE owner_type = 'org' AND owner_id = ?)", orgID).
+		Exec(ctx); err != nil {
+		return err
+	}
 
-		if _, err := tx.NewDelete().Model((*RoleBinding)(nil)).
-			Where("org_id = ? AND subject_type = ? AND subject_id = ?", orgID, "account", accountID).
-			Exec(ctx); err != nil {
-			return err
-		}
+	if _, err := tx.NewDelete().Model((*TeamMember)(nil)).
+		Where("account_id = ?", accountID).
+		Where("team_id IN (SELECT id FROM teams WHERE org_id = ?)", orgID).
+		Exec(ctx); err != nil {
+		return err
+	}
 
-		if _, err := tx.NewDelete().Model((*OrgMember)(nil)).
-			Where("org_id = ? AND account_id = ?", orgID, accountID).Exec(ctx); err != nil {
-			return err
-		}
+	if _, err := tx.NewDelete().Model((*RoleBinding)(nil)).
+		Where("org_id = ? AND subject_type = ? AND subject_id = ?", orgID, "account", accountID).
+		Exec(ctx); err != nil {
+		return err
+	}
 
-		_, err := tx.NewUpdate().
-			Model((*OrgAccessSession)(nil)).
-			Set("revoked_at = ?", time.Now()).
-			Set("revoked_by_account_id = ?", revokedBy).
-			Set("revocation_reason = ?", reason).
-			Where("org_id = ? AND account_id = ? AND revoked_at IS NULL", orgID, accountID).
-			Exec(ctx)
+	if _, err := tx.NewDelete().Model((*OrgMember)(nil)).
+		Where("org_id = ? AND account_id = ?", orgID, accountID).Exec(ctx); err != nil {
 		return err
-	})
+	}
+
+	_, err := tx.NewUpdate().
+		Model((*OrgAccessSession)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Set("revoked_by_account_id = ?", revokedBy).
+		Set("revocation_reason = ?", reason).
+		Where("org_id = ? AND account_id = ? AND revoked_at IS NULL", orgID, accountID).
+		Exec(ctx)
+	return err
 }
 
 func (db *DB) GetOrgMembers(ctx context.Context, orgID int64) ([]OrgMember, error) {
@@ -344,6 +353,7 @@ SELECT
 	om.account_id,
 	a.email,
 	a.name,
+	a.kind,
 	COALESCE(MAX(CASE WHEN ro.name IN (?, ?) THEN ro.name END), '') AS role,
 	om.joined_at
 FROM org_members AS om
@@ -370,7 +380,7 @@ WHERE om.org_id = ?`
 
 	direction := sqlSortDirection(params.Order)
 	query += fmt.Sprintf(`
-GROUP BY om.org_id, om.account_id, a.email, a.name, om.joined_at
+GROUP BY om.org_id, om.account_id, a.email, a.name, a.kind, om.joined_at
 ORDER BY %s %s, om.account_id %s`, orgMemberSortColumn(params.Sort), direction, direction)
 
 	var items []OrgMemberListItem
@@ -396,6 +406,7 @@ SELECT
 	om.account_id,
 	a.email,
 	a.name,
+	a.kind,
 	COALESCE(MAX(CASE WHEN ro.name IN (?, ?, ?) THEN ro.name END), '') AS role,
 	om.joined_at
 FROM org_members AS om
@@ -408,7 +419,7 @@ LEFT JOIN role_bindings AS rb
 	AND rb.resource_id = om.org_id
 LEFT JOIN roles AS ro ON ro.id = rb.role_id
 WHERE om.org_id = ? AND om.account_id = ?
-GROUP BY om.org_id, om.account_id, a.email, a.name, om.joined_at`
+GROUP BY om.org_id, om.account_id, a.email, a.name, a.kind, om.joined_at`
 
 	var item OrgMemberListItem
 	err := db.NewRaw(query, access.BuiltinOrgOwnerRole, access.BuiltinOrgAdminRole, access.BuiltinOrgMemberRole, orgID, accountID).Scan(ctx, &item)
@@ -653,34 +664,37 @@ func (db *DB) DeleteAccountRoleBindings(ctx context.Context, orgID, accountID in
 	return err
 }
 
-func (db *DB) UpsertOrgIDPConfig(ctx context.Context, orgID int64, provider, displayName, encryptedConfig string, ssoRequired bool) (OrgIDPConfig, error) {
+// UpsertOrgIDPConfig stores the organization's identity provider. config is
+// the provider's JSON settings; secrets inside it must already be encrypted.
+func (db *DB) UpsertOrgIDPConfig(ctx context.Context, orgID int64, provider, displayName, config string, ssoRequired bool) (OrgIDPConfig, error) {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
 	now := time.Now()
-	config := OrgIDPConfig{
+	idpConfig := OrgIDPConfig{
 		ID:          newID(),
 		OrgID:       orgID,
 		Provider:    provider,
 		DisplayName: displayName,
-		Config:      encryptedConfig,
+		Config:      config,
 		SSORequired: ssoRequired,
 		IsActive:    true,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
-	_, err := db.NewInsert().Model(&config).
+	_, err := db.NewInsert().Model(&idpConfig).
 		On("CONFLICT (org_id) DO UPDATE").
 		Set("provider = EXCLUDED.provider").
 		Set("display_name = EXCLUDED.display_name").
 		Set("config = EXCLUDED.config").
 		Set("sso_required = EXCLUDED.sso_required").
+		Set("is_active = EXCLUDED.is_active").
 		Set("updated_at = EXCLUDED.updated_at").
 		Exec(ctx)
 	if err != nil {
 		return OrgIDPConfig{}, err
 	}
-	return config, nil
+	return idpConfig, nil
 }
 
 func (db *DB) GetOrgIDPConfig(ctx context.Context, orgID int64) (OrgIDPConfig, bool, error) {
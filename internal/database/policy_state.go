@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// PolicyStateAccount is an org member as referenced by policy-as-code.
+// ServiceAccountSlug is set when the account is one of the org's service
+// accounts.
+type PolicyStateAccount struct {
+	ID                 int64  `bun:"id"`
+	Email              string `bun:"email"`
+	ServiceAccountSlug string `bun:"service_account_slug"`
+}
+
+// PolicyStateResource is a workspace, environment or connection. WorkspaceID
+// is zero for workspaces.
+type PolicyStateResource struct {
+	ID          int64  `bun:"id"`
+	WorkspaceID int64  `bun:"workspace_id"`
+	Name        string `bun:"name"`
+}
+
+// OrgPolicyState is everything policy-as-code exports and imports for one
+// organization: its roles with their permissions, teams and team members,
+// workspace memberships and role bindings, together with the members,
+// workspaces, environments and connections they refer to.
+type OrgPolicyState struct {
+	Accounts         []PolicyStateAccount
+	Roles            []Role
+	Teams            []Team
+	TeamMembers      []TeamMember
+	Workspaces       []PolicyStateResource
+	Environments     []PolicyStateResource
+	Connections      []PolicyStateResource
+	WorkspaceMembers []WorkspaceMember
+	WorkspaceTeams   []WorkspaceTeam
+	Bindings         []RoleBinding
+}
+
+// LoadOrgPolicyState reads an organization's policy state.
+func (db *DB) LoadOrgPolicyState(ctx context.Context, orgID int64) (OrgPolicyState, error) {
+	return db.LoadOrgPolicyStateWithExecutor(ctx, db.DB, orgID)
+}
+
+// LoadOrgPolicyStateWithExecutor reads an organization's policy state using
+// exec, so an import can plan against the same snapshot it applies to.
+func (db *DB) LoadOrgPolicyStateWithExecutor(ctx context.Context, exec bun.IDB, orgID int64) (OrgPolicyState, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var state OrgPolicyState
+	err := exec.NewRaw(`
+SELECT a.id, a.email, COALESCE(sa.slug, '') AS service_account_slug
+FROM org_members AS om
+JOIN accounts AS a ON a.id = om.account_id
+LEFT JOIN service_accounts AS sa ON sa.account_id = a.id AND sa.org_id = om.org_id
+WHERE om.org_id = ?
+ORDER BY a.id ASC`, orgID).Scan(ctx, &state.Accounts)
+	if err != nil {
+		return OrgPolicyState{}, err
+	}
+
+	if err = exec.NewSelect().Model(&state.Roles).Where("org_id = ?", orgID).OrderExpr("id ASC").Scan(ctx); err != nil {
+		return OrgPolicyState{}, err
+	}
+	var permissions []struct {
+		RoleID     int64  `bun:"role_id"`
+		Permission string `bun:"permission"`
+	}
+	err = exec.NewSelect().
+		TableExpr("role_permissions AS rp").
+		ColumnExpr("rp.role_id, rp.permission").
+		Join("JOIN roles AS r ON r.id = rp.role_id").
+		Where("r.org_id = ?", orgID).
+		OrderExpr("rp.role_id ASC, rp.permission ASC").
+		Scan(ctx, &permissions)
+	if err != nil {
+		return OrgPolicyState{}, err
+	}
+	roleIndex := make(map[int64]int, len(state.Roles))
+	for i, role := range state.Roles {
+		roleIndex[role.ID] = i
+	}
+	for _, p := range permissions {
+		if i, ok := roleIndex[p.RoleID]; ok {
+			state.Roles[i].Permissions = append(state.Roles[i].Permissions, p.Permission)
+		}
+	}
+
+	if err = exec.NewSelect().Model(&state.Teams).Where("org_id = ?", orgID).OrderExpr("id ASC").Scan(ctx); err != nil {
+		return OrgPolicyState{}, err
+	}
+	err = exec.NewSelect().
+		Model(&state.TeamMembers).
+		Where("team_id IN (SELECT id FROM teams WHERE org_id = ?)", orgID).
+		OrderExpr("team_id ASC, account_id ASC").
+		Scan(ctx)
+	if err != nil {
+		return OrgPolicyState{}, err
+	}
+
+	err = exec.NewRaw(`
+SELECT id, 0 AS workspace_id, name
+FROM workspaces
+WHERE owner_type = 'org' AND owner_id = ?
+ORDER BY id ASC`, orgID).Scan(ctx, &state.Workspaces)
+	if err != nil {
+		return OrgPolicyState{}, err
+	}
+	err = exec.NewRaw(`
+SELECT e.id, e.workspace_id, e.name
+FROM environments AS e
+JOIN workspaces AS w ON w.id = e.workspace_id AND w.owner_type = 'org' AND w.owner_id = ?
+ORDER BY e.id ASC`, orgID).Scan(ctx, &state.Environments)
+	if err != nil {
+		return OrgPolicyState{}, err
+	}
+	err = exec.NewRaw(`
+SELECT c.id, c.workspace_id, c.name
+FROM connections AS c
+JOIN workspaces AS w ON w.id = c.workspace_id AND w.owner_type = 'org' AND w.owner_id = ?
+ORDER BY c.id ASC`, orgID).Scan(ctx, &state.Connections)
+	if err != nil {
+		return OrgPolicyState{}, err
+	}
+
+	const orgWorkspaces = "workspace_id IN (SELECT id FROM workspaces WHERE owner_type = 'org' AND owner_id = ?)"
+	if err = exec.NewSelect().Model(&state.WorkspaceMembers).Where(orgWorkspaces, orgID).OrderExpr("workspace_id ASC, account_id ASC").Scan(ctx); err != nil {
+		return OrgPolicyState{}, err
+	}
+	if err = exec.NewSelect().Model(&state.WorkspaceTeams).Where(orgWorkspaces, orgID).OrderExpr("workspace_id ASC, team_id ASC").Scan(ctx); err != nil {
+		return OrgPolicyState{}, err
+	}
+
+	if err = exec.NewSelect().Model(&state.Bindings).Where("org_id = ?", orgID).OrderExpr("id ASC").Scan(ctx); err != nil {
+		return OrgPolicyState{}, err
+	}
+	return state, nil
+}
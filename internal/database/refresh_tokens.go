@@ -93,6 +93,20 @@ func (db *DB) RevokeRefreshTokenWithExecutor(ctx context.Context, exec bun.IDB,
 
 // CreateAuthSessionWithRefreshToken atomically creates an auth session and its initial refresh token.
 func (db *DB) CreateAuthSessionWithRefreshToken(ctx context.Context, accountID int64, expiresAt time.Time, userAgent, ipAddress, refreshTokenHash, refreshTokenFamily string) (AuthSession, RefreshToken, error) {
+	return db.CreateSignInAuthSessionWithRefreshToken(ctx, accountID, AuthSessionSignIn{}, expiresAt, userAgent, ipAddress, refreshTokenHash, refreshTokenFamily)
+}
+
+// AuthSessionSignIn records how an auth session was signed in.
+type AuthSessionSignIn struct {
+	// SSOIssuer is the OpenID Connect issuer for SSO sign-ins.
+	SSOIssuer string
+	// MFAMethod is the second factor presented with the password, if any.
+	MFAMethod string
+}
+
+// CreateSignInAuthSessionWithRefreshToken is CreateAuthSessionWithRefreshToken
+// for a session whose sign-in method is recorded on it.
+func (db *DB) CreateSignInAuthSessionWithRefreshToken(ctx context.Context, accountID int64, signIn AuthSessionSignIn, expiresAt time.Time, userAgent, ipAddress, refreshTokenHash, refreshTokenFamily string) (AuthSession, RefreshToken, error) {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
@@ -104,6 +118,18 @@ func (db *DB) CreateAuthSessionWithRefreshToken(ctx context.Context, accountID i
 		if err != nil {
 			return err
 		}
+		if signIn != (AuthSessionSignIn{}) {
+			authSession.SSOIssuer = signIn.SSOIssuer
+			authSession.MFAMethod = signIn.MFAMethod
+			_, err = tx.NewUpdate().
+				Model(&authSession).
+				Column("sso_issuer", "mfa_method").
+				WherePK().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
 		refreshToken, err = db.InsertRefreshTokenWithExecutor(ctx, tx, accountID, authSession.ID, refreshTokenHash, refreshTokenFamily, expiresAt, userAgent, ipAddress)
 		return err
 	})
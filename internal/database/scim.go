@@ -0,0 +1,724 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sqlwarden/internal/response"
+	"github.com/uptrace/bun"
+)
+
+// SCIMToken authenticates an organization's SCIM client. It is not tied to an
+// account; requests made with it act for the organization's identity
+// provider.
+type SCIMToken struct {
+	bun.BaseModel `bun:"table:scim_tokens"`
+
+	ID                 string     `bun:",pk"             json:"id"`
+	OrgID              int64      `bun:",notnull"        json:"org_id"`
+	Name               string     `bun:",notnull"        json:"name"`
+	TokenPrefix        string     `bun:",notnull"        json:"token_prefix"`
+	TokenHash          string     `bun:",notnull,unique" json:"-"`
+	LastUsedAt         *time.Time `bun:",nullzero"       json:"last_used_at,omitempty"`
+	RevokedAt          *time.Time `bun:",nullzero"       json:"revoked_at,omitempty"`
+	CreatedByAccountID *int64     `bun:",nullzero"       json:"created_by_account_id,omitempty"`
+	CreatedAt          time.Time  `bun:",notnull"        json:"created_at"`
+}
+
+type InsertSCIMTokenParams struct {
+	OrgID              int64
+	Name               string
+	TokenPrefix        string
+	TokenHash          string
+	CreatedByAccountID *int64
+}
+
+// scimUserRow records that an organization's SCIM client knows an account.
+// The link is pending until ConfirmedAt is set, either because the client
+// created the account or because its holder confirmed the link.
+type scimUserRow struct {
+	bun.BaseModel `bun:"table:scim_users"`
+
+	OrgID           int64      `bun:",pk"`
+	AccountID       int64      `bun:",pk"`
+	ExternalID      string     `bun:",nullzero"`
+	ConfirmedAt     *time.Time `bun:",nullzero"`
+	RequestedActive bool       `bun:",notnull"`
+	CreatedAt       time.Time  `bun:",notnull"`
+	UpdatedAt       time.Time  `bun:",notnull"`
+}
+
+// scimGroupRow records the identity provider's id for a team.
+type scimGroupRow struct {
+	bun.BaseModel `bun:"table:scim_groups"`
+
+	TeamID     int64     `bun:",pk"`
+	OrgID      int64     `bun:",notnull"`
+	ExternalID string    `bun:",nullzero"`
+	CreatedAt  time.Time `bun:",notnull"`
+	UpdatedAt  time.Time `bun:",notnull"`
+}
+
+// SCIMUser is an account as seen by an organization's SCIM client: every
+// human member of the organization, plus accounts the client provisioned that
+// are currently deactivated. JoinedAt is nil when the account is not a member;
+// LinkedAt is nil until the SCIM client first provisions or updates it, and
+// ConfirmedAt is nil while the link awaits the account holder's confirmation.
+type SCIMUser struct {
+	AccountID   int64
+	ExternalID  string
+	Email       string
+	Name        string
+	JoinedAt    *time.Time
+	LinkedAt    *time.Time
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Active reports whether the account is a member of the organization.
+func (u SCIMUser) Active() bool {
+	return u.JoinedAt != nil
+}
+
+// Confirmed reports whether the SCIM client may manage the account: it created
+// the account, or the account holder confirmed the link.
+func (u SCIMUser) Confirmed() bool {
+	return u.ConfirmedAt != nil
+}
+
+// PendingSCIMLink is an organization's request, awaiting the account
+// holder's confirmation, to manage an existing account through SCIM.
+type PendingSCIMLink struct {
+	OrgID       int64     `json:"org_id"`
+	OrgSlug     string    `json:"org_slug"`
+	OrgName     string    `json:"org_name"`
+	ExternalID  string    `json:"external_id,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// SCIMGroup is a team as seen by an organization's SCIM client.
+type SCIMGroup struct {
+	TeamID     int64
+	Slug       string
+	Name       string
+	ExternalID string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// SCIMFilter narrows a SCIM listing to one equality match. At most one field
+// is set.
+type SCIMFilter struct {
+	UserName    string
+	DisplayName string
+	ExternalID  string
+}
+
+type ProvisionSCIMUserParams struct {
+	OrgID      int64
+	Email      string
+	Name       string
+	ExternalID string
+	Active     bool
+}
+
+// DeprovisionSCIMUserParams describes removing an account's access to an
+// organization on behalf of its identity provider.
+type DeprovisionSCIMUserParams struct {
+	OrgID     int64
+	AccountID int64
+	// SignOut also revokes the account's auth sessions, refresh tokens and
+	// API tokens.
+	SignOut bool
+	// Forget deletes the SCIM record as well, so the user is no longer
+	// listed.
+	Forget bool
+}
+
+func (db *DB) InsertSCIMToken(ctx context.Context, params InsertSCIMTokenParams) (SCIMToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	token := SCIMToken{
+		ID:                 newID(),
+		OrgID:              params.OrgID,
+		Name:               params.Name,
+		TokenPrefix:        params.TokenPrefix,
+		TokenHash:          params.TokenHash,
+		CreatedByAccountID: params.CreatedByAccountID,
+		CreatedAt:          time.Now(),
+	}
+	_, err := db.NewInsert().Model(&token).Exec(ctx)
+	if err != nil {
+		return SCIMToken{}, err
+	}
+	return token, nil
+}
+
+func (db *DB) GetSCIMTokenByHash(ctx context.Context, hash string) (SCIMToken, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var token SCIMToken
+	err := db.NewSelect().Model(&token).Where("token_hash = ?", hash).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SCIMToken{}, false, nil
+	}
+	if err != nil {
+		return SCIMToken{}, false, err
+	}
+	return token, true, nil
+}
+
+func (db *DB) GetSCIMToken(ctx context.Context, id string, orgID int64) (SCIMToken, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var token SCIMToken
+	err := db.NewSelect().Model(&token).Where("id = ? AND org_id = ?", id, orgID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SCIMToken{}, false, nil
+	}
+	if err != nil {
+		return SCIMToken{}, false, err
+	}
+	return token, true, nil
+}
+
+// ListSCIMTokensPage lists an organization's tokens that have not been
+// revoked, newest first.
+func (db *DB) ListSCIMTokensPage(ctx context.Context, orgID int64, page, pageSize int) (response.Paginated[SCIMToken], error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 25
+	}
+
+	var tokens []SCIMToken
+	err := db.NewSelect().
+		Model(&tokens).
+		Where("org_id = ? AND revoked_at IS NULL", orgID).
+		OrderExpr("created_at DESC, id DESC").
+		Scan(ctx)
+	if err != nil {
+		return response.Paginated[SCIMToken]{}, err
+	}
+	return response.PaginateItems(tokens, page, pageSize), nil
+}
+
+// TouchSCIMToken records token use at most once per
+// apiTokenLastUsedUpdateInterval.
+func (db *DB) TouchSCIMToken(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	_, err := db.NewUpdate().
+		Model((*SCIMToken)(nil)).
+		Set("last_used_at = ?", now).
+		Where("id = ?", id).
+		Where("(last_used_at IS NULL OR last_used_at < ?)", now.Add(-apiTokenLastUsedUpdateInterval)).
+		Exec(ctx)
+	return err
+}
+
+func (db *DB) RevokeSCIMToken(ctx context.Context, id string, orgID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*SCIMToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("id = ? AND org_id = ? AND revoked_at IS NULL", id, orgID).
+		Exec(ctx)
+	return err
+}
+
+const scimUserQuery = `
+SELECT
+	a.id AS account_id,
+	su.external_id,
+	a.email,
+	a.name,
+	om.joined_at,
+	su.created_at AS linked_at,
+	su.confirmed_at,
+	COALESCE(su.created_at, om.joined_at) AS created_at,
+	COALESCE(su.updated_at, a.updated_at) AS updated_at
+FROM accounts AS a
+LEFT JOIN org_members AS om ON om.account_id = a.id AND om.org_id = ?
+LEFT JOIN scim_users AS su ON su.account_id = a.id AND su.org_id = ?
+WHERE a.kind = 'user' AND (om.account_id IS NOT NULL OR su.account_id IS NOT NULL)`
+
+// ListSCIMUsers lists the organization's SCIM users ordered by account id.
+func (db *DB) ListSCIMUsers(ctx context.Context, orgID int64, filter SCIMFilter) ([]SCIMUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	query := scimUserQuery
+	args := []any{orgID, orgID}
+	if filter.UserName != "" {
+		query += " AND LOWER(a.email) = ?"
+		args = append(args, strings.ToLower(filter.UserName))
+	}
+	if filter.ExternalID != "" {
+		query += " AND su.external_id = ?"
+		args = append(args, filter.ExternalID)
+	}
+	query += " ORDER BY a.id ASC"
+
+	users := []SCIMUser{}
+	err := db.NewRaw(query, args...).Scan(ctx, &users)
+	return users, err
+}
+
+func (db *DB) GetSCIMUser(ctx context.Context, orgID, accountID int64) (SCIMUser, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var user SCIMUser
+	err := db.NewRaw(scimUserQuery+" AND a.id = ?", orgID, orgID, accountID).Scan(ctx, &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SCIMUser{}, false, nil
+	}
+	if err != nil {
+		return SCIMUser{}, false, err
+	}
+	return user, true, nil
+}
+
+// ProvisionSCIMUser creates a password-less account for the organization's
+// identity provider, and makes it a member when params.Active is set.
+func (db *DB) ProvisionSCIMUser(ctx context.Context, params ProvisionSCIMUserParams) (Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var account Account
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		account, err = db.InsertAccountWithExecutor(ctx, tx, params.Email, params.Name, nil)
+		if err != nil {
+			return err
+		}
+		return db.linkSCIMUserWithExecutor(ctx, tx, params.OrgID, account.ID, params.ExternalID, params.Active)
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+// LinkSCIMUser updates a confirmed SCIM link, and makes the account a member
+// when active is set. Links to existing accounts start with RequestSCIMUserLink
+// instead.
+func (db *DB) LinkSCIMUser(ctx context.Context, orgID, accountID int64, externalID string, active bool) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return db.linkSCIMUserWithExecutor(ctx, tx, orgID, accountID, externalID, active)
+	})
+}
+
+func (db *DB) linkSCIMUserWithExecutor(ctx context.Context, exec bun.IDB, orgID, accountID int64, externalID string, active bool) error {
+	now := time.Now()
+	row := scimUserRow{OrgID: orgID, AccountID: accountID, ExternalID: externalID, ConfirmedAt: &now, RequestedActive: active, CreatedAt: now, UpdatedAt: now}
+	_, err := exec.NewInsert().
+		Model(&row).
+		On("CONFLICT (org_id, account_id) DO UPDATE").
+		Set("external_id = EXCLUDED.external_id").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil || !active {
+		return err
+	}
+	return db.AddOrgMemberWithExecutor(ctx, exec, orgID, accountID)
+}
+
+// RequestSCIMUserLink records that the organization's SCIM client wants to
+// manage an existing account without granting it anything: the link stays
+// pending, and active is only remembered, until the account holder confirms
+// it with ConfirmSCIMUserLink. A link that is already confirmed stays
+// confirmed.
+func (db *DB) RequestSCIMUserLink(ctx context.Context, orgID, accountID int64, externalID string, active bool) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	row := scimUserRow{OrgID: orgID, AccountID: accountID, ExternalID: externalID, RequestedActive: active, CreatedAt: now, UpdatedAt: now}
+	_, err := db.NewInsert().
+		Model(&row).
+		On("CONFLICT (org_id, account_id) DO UPDATE").
+		Set("external_id = EXCLUDED.external_id").
+		Set("requested_active = EXCLUDED.requested_active").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	return err
+}
+
+// ConfirmSCIMUserLink confirms a pending link on behalf of the account holder
+// and, when the SCIM client last asked for the user to be active, makes the
+// account a member. It reports whether a pending link existed and whether the
+// account joined the organization.
+func (db *DB) ConfirmSCIMUserLink(ctx context.Context, orgID, accountID int64) (found, joined bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var row scimUserRow
+		err := tx.NewSelect().
+			Model(&row).
+			Where("org_id = ? AND account_id = ? AND confirmed_at IS NULL", orgID, accountID).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		result, err := tx.NewUpdate().
+			Model((*scimUserRow)(nil)).
+			Set("confirmed_at = ?", now).
+			Set("updated_at = ?", now).
+			Where("org_id = ? AND account_id = ? AND confirmed_at IS NULL", orgID, accountID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+		found = true
+		if !row.RequestedActive {
+			return nil
+		}
+		joined = true
+		return db.AddOrgMemberWithExecutor(ctx, tx, orgID, accountID)
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return found, joined, nil
+}
+
+// DeclineSCIMUserLink deletes a pending link on behalf of the account holder.
+// Membership the account already had is left alone.
+func (db *DB) DeclineSCIMUserLink(ctx context.Context, orgID, accountID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	result, err := db.NewDelete().
+		Model((*scimUserRow)(nil)).
+		Where("org_id = ? AND account_id = ? AND confirmed_at IS NULL", orgID, accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ListPendingSCIMLinks lists the links awaiting the account holder's
+// confirmation, oldest first.
+func (db *DB) ListPendingSCIMLinks(ctx context.Context, accountID int64) ([]PendingSCIMLink, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	links := []PendingSCIMLink{}
+	err := db.NewRaw(`
+SELECT o.id AS org_id, o.slug AS org_slug, o.name AS org_name, su.external_id, su.created_at AS requested_at
+FROM scim_users AS su
+JOIN organizations AS o ON o.id = su.org_id
+WHERE su.account_id = ? AND su.confirmed_at IS NULL
+ORDER BY su.created_at ASC, o.id ASC`, accountID).Scan(ctx, &links)
+	return links, err
+}
+
+// UpdateSCIMAccountProfile changes an account's email and name on behalf of
+// the organization's identity provider.
+func (db *DB) UpdateSCIMAccountProfile(ctx context.Context, orgID, accountID int64, email, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		_, err := tx.NewUpdate().
+			Model((*Account)(nil)).
+			Set("email = ?", email).
+			Set("name = ?", name).
+			Set("updated_at = ?", now).
+			Where("id = ?", accountID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().
+			Model((*scimUserRow)(nil)).
+			Set("updated_at = ?", now).
+			Where("org_id = ? AND account_id = ?", orgID, accountID).
+			Exec(ctx)
+		return err
+	})
+}
+
+// DeprovisionSCIMUser removes the account's access to the organization the
+// same way removing a member does, optionally signing the account out
+// everywhere.
+func (db *DB) DeprovisionSCIMUser(ctx context.Context, params DeprovisionSCIMUserParams) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		const reason = "scim_deprovisioned"
+		if err := removeOrgMemberAccessWithExecutor(ctx, tx, params.OrgID, params.AccountID, nil, reason); err != nil {
+			return err
+		}
+		if params.SignOut {
+			if err := revokeAuthSessionsForAccountWithExecutor(ctx, tx, params.AccountID, nil, reason); err != nil {
+				return err
+			}
+			if err := db.RevokeAPITokensForAccountWithExecutor(ctx, tx, params.AccountID); err != nil {
+				return err
+			}
+		}
+
+		if params.Forget {
+			_, err := tx.NewDelete().
+				Model((*scimUserRow)(nil)).
+				Where("org_id = ? AND account_id = ?", params.OrgID, params.AccountID).
+				Exec(ctx)
+			return err
+		}
+		_, err := tx.NewUpdate().
+			Model((*scimUserRow)(nil)).
+			Set("updated_at = ?", time.Now()).
+			Where("org_id = ? AND account_id = ?", params.OrgID, params.AccountID).
+			Exec(ctx)
+		return err
+	})
+}
+
+// AccountBelongsOnlyToOrg reports whether the organization is the only one
+// with a claim on the account: it is not an instance admin, not a member of
+// another organization, and not linked to another organization's SCIM client.
+// Links still awaiting the account holder's confirmation are not claims.
+func (db *DB) AccountBelongsOnlyToOrg(ctx context.Context, accountID, orgID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var claims int
+	err := db.NewRaw(`
+SELECT
+	(SELECT COUNT(*) FROM instance_admins WHERE account_id = ?) +
+	(SELECT COUNT(*) FROM org_members WHERE account_id = ? AND org_id <> ?) +
+	(SELECT COUNT(*) FROM scim_users WHERE account_id = ? AND org_id <> ? AND confirmed_at IS NOT NULL)`,
+		accountID, accountID, orgID, accountID, orgID).Scan(ctx, &claims)
+	return claims == 0, err
+}
+
+// FilterOrgMembers returns the accounts in accountIDs that are members of the
+// organization.
+func (db *DB) FilterOrgMembers(ctx context.Context, orgID int64, accountIDs []int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	members := []int64{}
+	if len(accountIDs) == 0 {
+		return members, nil
+	}
+	err := db.NewSelect().
+		Model((*OrgMember)(nil)).
+		Column("account_id").
+		Where("org_id = ? AND account_id IN (?)", orgID, bun.List(accountIDs)).
+		Scan(ctx, &members)
+	return members, err
+}
+
+const scimGroupQuery = `
+SELECT
+	t.id AS team_id,
+	t.slug,
+	t.name,
+	sg.external_id,
+	t.created_at,
+	t.updated_at
+FROM teams AS t
+LEFT JOIN scim_groups AS sg ON sg.team_id = t.id
+WHERE t.org_id = ?`
+
+// ListSCIMGroups lists the organization's teams ordered by id.
+func (db *DB) ListSCIMGroups(ctx context.Context, orgID int64, filter SCIMFilter) ([]SCIMGroup, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	query := scimGroupQuery
+	args := []any{orgID}
+	if filter.DisplayName != "" {
+		query += " AND t.name = ?"
+		args = append(args, filter.DisplayName)
+	}
+	if filter.ExternalID != "" {
+		query += " AND sg.external_id = ?"
+		args = append(args, filter.ExternalID)
+	}
+	query += " ORDER BY t.id ASC"
+
+	groups := []SCIMGroup{}
+	err := db.NewRaw(query, args...).Scan(ctx, &groups)
+	return groups, err
+}
+
+func (db *DB) GetSCIMGroup(ctx context.Context, orgID, teamID int64) (SCIMGroup, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var group SCIMGroup
+	err := db.NewRaw(scimGroupQuery+" AND t.id = ?", orgID, teamID).Scan(ctx, &group)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SCIMGroup{}, false, nil
+	}
+	if err != nil {
+		return SCIMGroup{}, false, err
+	}
+	return group, true, nil
+}
+
+// ListTeamMembersForTeams returns the members of each team, ordered by
+// account id.
+func (db *DB) ListTeamMembersForTeams(ctx context.Context, teamIDs []int64) (map[int64][]TeamMemberListItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	members := map[int64][]TeamMemberListItem{}
+	if len(teamIDs) == 0 {
+		return members, nil
+	}
+	var items []TeamMemberListItem
+	err := db.NewRaw(`
+SELECT tm.team_id, tm.account_id, a.email, a.name, tm.created_at
+FROM team_members AS tm
+JOIN accounts AS a ON a.id = tm.account_id
+WHERE tm.team_id IN (?)
+ORDER BY tm.team_id ASC, tm.account_id ASC`, bun.List(teamIDs)).Scan(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		members[item.TeamID] = append(members[item.TeamID], item)
+	}
+	return members, nil
+}
+
+// CreateSCIMGroup creates a team managed by the organization's identity
+// provider, with memberIDs as its members.
+func (db *DB) CreateSCIMGroup(ctx context.Context, orgID int64, slug, name, externalID string, memberIDs []int64) (Team, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	now := time.Now()
+	team := Team{OrgID: orgID, Slug: slug, Name: name, CreatedAt: now, UpdatedAt: now}
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&team).Returning("id").Exec(ctx); err != nil {
+			return err
+		}
+		row := scimGroupRow{TeamID: team.ID, OrgID: orgID, ExternalID: externalID, CreatedAt: now, UpdatedAt: now}
+		if _, err := tx.NewInsert().Model(&row).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := setTeamMembersWithExecutor(ctx, tx, team.ID, memberIDs)
+		return err
+	})
+	if err != nil {
+		return Team{}, err
+	}
+	return team, nil
+}
+
+// UpdateSCIMGroup renames the team, records its external id and makes
+// memberIDs its exact membership. It returns the accounts that joined or left.
+func (db *DB) UpdateSCIMGroup(ctx context.Context, orgID, teamID int64, name, externalID string, memberIDs []int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var changed []int64
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		_, err := tx.NewUpdate().
+			Model((*Team)(nil)).
+			Set("name = ?", name).
+			Set("updated_at = ?", now).
+			Where("id = ? AND org_id = ?", teamID, orgID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		row := scimGroupRow{TeamID: teamID, OrgID: orgID, ExternalID: externalID, CreatedAt: now, UpdatedAt: now}
+		_, err = tx.NewInsert().
+			Model(&row).
+			On("CONFLICT (team_id) DO UPDATE").
+			Set("external_id = EXCLUDED.external_id").
+			Set("updated_at = EXCLUDED.updated_at").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		changed, err = setTeamMembersWithExecutor(ctx, tx, teamID, memberIDs)
+		return err
+	})
+	return changed, err
+}
+
+func setTeamMembersWithExecutor(ctx context.Context, exec bun.IDB, teamID int64, memberIDs []int64) ([]int64, error) {
+	var current []int64
+	err := exec.NewSelect().
+		Model((*TeamMember)(nil)).
+		Column("account_id").
+		Where("team_id = ?", teamID).
+		Scan(ctx, &current)
+	if err != nil {
+		return nil, err
+	}
+
+	var added, removed []int64
+	for _, id := range memberIDs {
+		if !slices.Contains(current, id) && !slices.Contains(added, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current {
+		if !slices.Contains(memberIDs, id) {
+			removed = append(removed, id)
+		}
+	}
+
+	if len(added) > 0 {
+		now := time.Now()
+		rows := make([]TeamMember, len(added))
+		for i, id := range added {
+			rows[i] = TeamMember{TeamID: teamID, AccountID: id, CreatedAt: now}
+		}
+		if _, err := exec.NewInsert().Model(&rows).Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if len(removed) > 0 {
+		_, err := exec.NewDelete().
+			Model((*TeamMember)(nil)).
+			Where("team_id = ? AND account_id IN (?)", teamID, bun.List(removed)).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(added, removed...), nil
+}
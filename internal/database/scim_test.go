@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestSCIMUserLifecycle(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "scim-org", "SCIM Org")
+			assert.Nil(t, err)
+			account, err := db.ProvisionSCIMUser(ctx, ProvisionSCIMUserParams{
+				OrgID:      org.ID,
+				Email:      "ada@example.com",
+				Name:       "Ada",
+				ExternalID: "00u-ada",
+				Active:     true,
+			})
+			assert.Nil(t, err)
+			assert.Nil(t, account.Password)
+
+			users, err := db.ListSCIMUsers(ctx, org.ID, SCIMFilter{UserName: "ADA@example.com"})
+			assert.Nil(t, err)
+			assert.Equal(t, len(users), 1)
+			assert.Equal(t, users[0].ExternalID, "00u-ada")
+			assert.True(t, users[0].Active())
+
+			owned, err := db.AccountBelongsOnlyToOrg(ctx, account.ID, org.ID)
+			assert.Nil(t, err)
+			assert.True(t, owned)
+
+			session, err := db.InsertAuthSession(ctx, account.ID, time.Now().Add(time.Hour), "agent", "127.0.0.1")
+			assert.Nil(t, err)
+
+			err = db.DeprovisionSCIMUser(ctx, DeprovisionSCIMUserParams{OrgID: org.ID, AccountID: account.ID, SignOut: true})
+			assert.Nil(t, err)
+
+			user, found, err := db.GetSCIMUser(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.False(t, user.Active())
+			revoked, _, err := db.GetAuthSession(ctx, session.ID, account.ID)
+			assert.Nil(t, err)
+			assert.NotNil(t, revoked.RevokedAt)
+
+			err = db.DeprovisionSCIMUser(ctx, DeprovisionSCIMUserParams{OrgID: org.ID, AccountID: account.ID, Forget: true})
+			assert.Nil(t, err)
+			_, found, err = db.GetSCIMUser(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestAccountBelongsOnlyToOrgCountsOtherOrgs(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "scim-home", "SCIM Home")
+			assert.Nil(t, err)
+			other, err := db.InsertOrg(ctx, "scim-elsewhere", "SCIM Elsewhere")
+			assert.Nil(t, err)
+			account, err := db.InsertAccount(ctx, "shared@example.com", "Shared", nil)
+			assert.Nil(t, err)
+			assert.Nil(t, db.AddOrgMember(ctx, org.ID, account.ID))
+			assert.Nil(t, db.AddOrgMember(ctx, other.ID, account.ID))
+
+			owned, err := db.AccountBelongsOnlyToOrg(ctx, account.ID, org.ID)
+			assert.Nil(t, err)
+			assert.False(t, owned)
+		})
+	}
+}
+
+func TestPendingSCIMLinkGrantsNothingUntilConfirmed(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "scim-pending", "SCIM Pending")
+			assert.Nil(t, err)
+			other, err := db.InsertOrg(ctx, "scim-pending-other", "SCIM Pending Other")
+			assert.Nil(t, err)
+			account, err := db.InsertAccount(ctx, "existing@example.com", "Existing", nil)
+			assert.Nil(t, err)
+
+			assert.Nil(t, db.RequestSCIMUserLink(ctx, org.ID, account.ID, "00u-existing", true))
+			user, found, err := db.GetSCIMUser(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.False(t, user.Active())
+			assert.False(t, user.Confirmed())
+
+			// Another organization's pending request is not a claim.
+			assert.Nil(t, db.RequestSCIMUserLink(ctx, other.ID, account.ID, "", true))
+			owned, err := db.AccountBelongsOnlyToOrg(ctx, account.ID, org.ID)
+			assert.Nil(t, err)
+			assert.True(t, owned)
+
+			links, err := db.ListPendingSCIMLinks(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(links), 2)
+			assert.Equal(t, links[0].OrgSlug, "scim-pending")
+
+			found, joined, err := db.ConfirmSCIMUserLink(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.True(t, joined)
+			user, _, err = db.GetSCIMUser(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, user.Active())
+			assert.True(t, user.Confirmed())
+
+			// A confirmed link stays confirmed when the client asks again.
+			assert.Nil(t, db.RequestSCIMUserLink(ctx, org.ID, account.ID, "00u-existing", true))
+			user, _, err = db.GetSCIMUser(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, user.Confirmed())
+			found, _, err = db.ConfirmSCIMUserLink(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			declined, err := db.DeclineSCIMUserLink(ctx, other.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, declined)
+			_, found, err = db.GetSCIMUser(ctx, other.ID, account.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestUpdateSCIMGroupReturnsChangedMembers(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "scim-groups", "SCIM Groups")
+			assert.Nil(t, err)
+			var ids []int64
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				account, err := db.InsertAccount(ctx, email, email, nil)
+				assert.Nil(t, err)
+				assert.Nil(t, db.AddOrgMember(ctx, org.ID, account.ID))
+				ids = append(ids, account.ID)
+			}
+
+			team, err := db.CreateSCIMGroup(ctx, org.ID, "data", "Data", "00g-data", ids[:2])
+			assert.Nil(t, err)
+
+			changed, err := db.UpdateSCIMGroup(ctx, org.ID, team.ID, "Data Platform", "00g-data", ids[1:])
+			assert.Nil(t, err)
+			assert.Equal(t, len(changed), 2)
+
+			groups, err := db.ListSCIMGroups(ctx, org.ID, SCIMFilter{ExternalID: "00g-data"})
+			assert.Nil(t, err)
+			assert.Equal(t, len(groups), 1)
+			assert.Equal(t, groups[0].Name, "Data Platform")
+
+			members, err := db.ListTeamMembersForTeams(ctx, []int64{team.ID})
+			assert.Nil(t, err)
+			assert.Equal(t, len(members[team.ID]), 2)
+			assert.Equal(t, members[team.ID][0].AccountID, ids[1])
+		})
+	}
+}
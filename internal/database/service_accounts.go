@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sqlwarden/internal/response"
+	"github.com/uptrace/bun"
+)
+
+// serviceAccountEmailDomain is a reserved, undeliverable domain (RFC 2606) for
+// the synthetic addresses of service accounts, which have no mailbox.
+const serviceAccountEmailDomain = "service-accounts.invalid"
+
+// ServiceAccount is the organization-owned side of a service account. The
+// principal itself is the accounts row with the same ID, so role bindings,
+// team membership and org membership treat it like any other account.
+type ServiceAccount struct {
+	bun.BaseModel `bun:"table:service_accounts,alias:sa"`
+
+	AccountID          int64     `bun:",pk"            json:"account_id"`
+	OrgID              int64     `bun:",notnull"       json:"org_id"`
+	Slug               string    `bun:",notnull"       json:"slug"`
+	Name               string    `bun:",scanonly"      json:"name"`
+	Description        string    `bun:",nullzero"      json:"description,omitempty"`
+	CreatedByAccountID *int64    `bun:",nullzero"      json:"created_by_account_id,omitempty"`
+	CreatedAt          time.Time `bun:",notnull"       json:"created_at"`
+	UpdatedAt          time.Time `bun:",notnull"       json:"updated_at"`
+}
+
+type ListServiceAccountsParams struct {
+	OrgID    int64
+	Search   string
+	Page     int
+	PageSize int
+}
+
+// CreateServiceAccount creates the backing account, adds it to the
+// organization and records the service account in one transaction. The new
+// account has no password and no role bindings.
+func (db *DB) CreateServiceAccount(ctx context.Context, orgID int64, slug, name, description string, createdBy *int64) (ServiceAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var serviceAccount ServiceAccount
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		account := Account{
+			Email:     "svc-" + strings.ToLower(newID()) + "@" + serviceAccountEmailDomain,
+			Name:      name,
+			Kind:      AccountKindService,
+			IsActive:  true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if _, err := tx.NewInsert().Model(&account).Returning("id").Exec(ctx); err != nil {
+			return err
+		}
+		if err := db.AddOrgMemberWithExecutor(ctx, tx, orgID, account.ID); err != nil {
+			return err
+		}
+		serviceAccount = ServiceAccount{
+			AccountID:          account.ID,
+			OrgID:              orgID,
+			Slug:               slug,
+			Name:               name,
+			Description:        description,
+			CreatedByAccountID: createdBy,
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		}
+		_, err := tx.NewInsert().Model(&serviceAccount).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return ServiceAccount{}, err
+	}
+	return serviceAccount, nil
+}
+
+func (db *DB) GetServiceAccount(ctx context.Context, orgID, accountID int64) (ServiceAccount, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var serviceAccount ServiceAccount
+	err := db.serviceAccountQuery(&serviceAccount).
+		Where("sa.org_id = ? AND sa.account_id = ?", orgID, accountID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ServiceAccount{}, false, nil
+	}
+	if err != nil {
+		return ServiceAccount{}, false, err
+	}
+	return serviceAccount, true, nil
+}
+
+func (db *DB) ListServiceAccountsPage(ctx context.Context, params ListServiceAccountsParams) (response.Paginated[ServiceAccount], error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var serviceAccounts []ServiceAccount
+	query := db.serviceAccountQuery(&serviceAccounts).Where("sa.org_id = ?", params.OrgID)
+	if search := strings.TrimSpace(params.Search); search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		query = query.Where("(LOWER(sa.slug) LIKE ? OR LOWER(a.name) LIKE ?)", pattern, pattern)
+	}
+	err := query.OrderExpr("sa.slug ASC").Scan(ctx)
+	if err != nil {
+		return response.Paginated[ServiceAccount]{}, err
+	}
+	return response.PaginateItems(serviceAccounts, params.Page, params.PageSize), nil
+}
+
+func (db *DB) serviceAccountQuery(model any) *bun.SelectQuery {
+	return db.NewSelect().
+		Model(model).
+		ColumnExpr("sa.*").
+		ColumnExpr("a.name AS name").
+		Join("JOIN accounts AS a ON a.id = sa.account_id")
+}
+
+// DeleteServiceAccount removes the service account from its organization,
+// revokes its tokens and deactivates the backing account. The account row is
+// kept so audit records and query history keep resolving their actor.
+func (db *DB) DeleteServiceAccount(ctx context.Context, orgID, accountID int64, revokedBy *int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*ServiceAccount)(nil)).
+			Where("org_id = ? AND account_id = ?", orgID, accountID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if err := removeOrgMemberAccessWithExecutor(ctx, tx, orgID, accountID, revokedBy, "service_account_deleted"); err != nil {
+			return err
+		}
+		if err := db.RevokeAPITokensForAccountWithExecutor(ctx, tx, accountID); err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().
+			Model((*Account)(nil)).
+			Set("is_active = ?", false).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", accountID).
+			Exec(ctx)
+		return err
+	})
+}
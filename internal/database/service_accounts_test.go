@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestServiceAccountLifecycle(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "svc-org", "Service Org")
+			assert.Nil(t, err)
+
+			serviceAccount, err := db.CreateServiceAccount(ctx, org.ID, "deploy-bot", "Deploy Bot", "Runs deployments", nil)
+			assert.Nil(t, err)
+
+			account, found, err := db.GetAccount(ctx, serviceAccount.AccountID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.True(t, account.IsService())
+			assert.Nil(t, account.Password)
+
+			isMember, err := db.IsOrgMember(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, isMember)
+
+			fetched, found, err := db.GetServiceAccount(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, fetched.Name, "Deploy Bot")
+			assert.Equal(t, fetched.Slug, "deploy-bot")
+
+			_, err = db.InsertAPIToken(ctx, InsertAPITokenParams{
+				AccountID:   account.ID,
+				OrgID:       &org.ID,
+				Name:        "ci",
+				TokenPrefix: "sqlw_12345678",
+				TokenHash:   "hash-svc",
+				Scopes:      []string{"read"},
+			})
+			assert.Nil(t, err)
+
+			assert.Nil(t, db.DeleteServiceAccount(ctx, org.ID, account.ID, nil))
+
+			_, found, err = db.GetServiceAccount(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			account, _, err = db.GetAccount(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.False(t, account.IsActive)
+
+			token, _, err := db.GetAPITokenByHash(ctx, "hash-svc")
+			assert.Nil(t, err)
+			assert.NotNil(t, token.RevokedAt)
+
+			isMember, err = db.IsOrgMember(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.False(t, isMember)
+		})
+	}
+}
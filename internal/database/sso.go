@@ -0,0 +1,433 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// AccountIdentity links an OpenID Connect subject at one issuer to an account.
+// An account may have several identities; an identity belongs to one account.
+// OrgID is the organization whose provider created the link. An identity an
+// organization's provider matched to an existing account by email cannot sign
+// in until the account holder confirms it.
+type AccountIdentity struct {
+	bun.BaseModel `bun:"table:account_identities"`
+
+	ID          string     `bun:",pk"       json:"id"`
+	AccountID   int64      `bun:",notnull"  json:"account_id"`
+	OrgID       *int64     `bun:",nullzero" json:"org_id,omitempty"`
+	Issuer      string     `bun:",notnull"  json:"issuer"`
+	Subject     string     `bun:",notnull"  json:"subject"`
+	Email       string     `bun:",nullzero" json:"email,omitempty"`
+	ConfirmedAt *time.Time `bun:",nullzero" json:"confirmed_at,omitempty"`
+	CreatedAt   time.Time  `bun:",notnull"  json:"created_at"`
+	LastLoginAt time.Time  `bun:",notnull"  json:"last_login_at"`
+}
+
+// Confirmed reports whether the identity may sign in as its account.
+func (i AccountIdentity) Confirmed() bool {
+	return i.ConfirmedAt != nil
+}
+
+// PendingSSOLink is an organization provider's request, awaiting the account
+// holder, to sign in as their account.
+type PendingSSOLink struct {
+	ID          string    `bun:"id"           json:"id"`
+	OrgID       int64     `bun:"org_id"       json:"org_id"`
+	OrgSlug     string    `bun:"org_slug"     json:"org_slug"`
+	OrgName     string    `bun:"org_name"     json:"org_name"`
+	Issuer      string    `bun:"issuer"       json:"issuer"`
+	Email       string    `bun:"email"        json:"email"`
+	RequestedAt time.Time `bun:"requested_at" json:"requested_at"`
+}
+
+// OIDCLoginState is a pending authorization request. ID is the hash of the
+// state parameter sent to the provider; OrgID is set for organization sign-in.
+type OIDCLoginState struct {
+	bun.BaseModel `bun:"table:oidc_login_states"`
+
+	ID           string    `bun:",pk"`
+	OrgID        *int64    `bun:",nullzero"`
+	Nonce        string    `bun:",notnull"`
+	CodeVerifier string    `bun:",notnull"`
+	ReturnTo     string    `bun:",nullzero"`
+	ExpiresAt    time.Time `bun:",notnull"`
+	CreatedAt    time.Time `bun:",notnull"`
+}
+
+// SSOGroupMapping adds members of an identity provider group to a team.
+type SSOGroupMapping struct {
+	bun.BaseModel `bun:"table:sso_group_mappings,alias:sgm"`
+
+	OrgID     int64     `bun:",pk"       json:"-"`
+	GroupName string    `bun:",pk"       json:"group"`
+	TeamID    int64     `bun:",pk"       json:"team_id"`
+	TeamSlug  string    `bun:",scanonly" json:"team_slug"`
+	CreatedAt time.Time `bun:",notnull"  json:"created_at"`
+}
+
+// ProvisionSSOAccountParams describes an account created on first sign-in.
+type ProvisionSSOAccountParams struct {
+	Email   string
+	Name    string
+	Issuer  string
+	Subject string
+	// OrgID, when set, makes the new account a member of the organization
+	// whose identity provider vouched for it.
+	OrgID *int64
+}
+
+// InsertOIDCLoginState stores a pending authorization request and prunes
+// requests that expired without a callback.
+func (db *DB) InsertOIDCLoginState(ctx context.Context, state OIDCLoginState) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*OIDCLoginState)(nil)).
+			Where("expires_at < ?", time.Now()).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		state.CreatedAt = time.Now()
+		_, err = tx.NewInsert().Model(&state).Exec(ctx)
+		return err
+	})
+}
+
+// ConsumeOIDCLoginState deletes and returns the pending request with id, so a
+// state value can complete at most one sign-in. Expired requests are reported
+// as not found.
+func (db *DB) ConsumeOIDCLoginState(ctx context.Context, id string) (OIDCLoginState, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var state OIDCLoginState
+	found := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().Model(&state).Where("id = ?", id).Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.NewDelete().Model((*OIDCLoginState)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		found = affected == 1 && time.Now().Before(state.ExpiresAt)
+		return nil
+	})
+	if err != nil || !found {
+		return OIDCLoginState{}, false, err
+	}
+	return state, true, nil
+}
+
+func (db *DB) GetAccountIdentity(ctx context.Context, issuer, subject string) (AccountIdentity, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var identity AccountIdentity
+	err := db.NewSelect().
+		Model(&identity).
+		Where("issuer = ? AND subject = ?", issuer, subject).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AccountIdentity{}, false, nil
+	}
+	if err != nil {
+		return AccountIdentity{}, false, err
+	}
+	return identity, true, nil
+}
+
+// LinkAccountIdentity records that issuer+subject signs in as accountID.
+func (db *DB) LinkAccountIdentity(ctx context.Context, accountID int64, issuer, subject, email string) (AccountIdentity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return insertAccountIdentityWithExecutor(ctx, db.DB, accountID, nil, issuer, subject, email, true)
+}
+
+// RequestAccountIdentityLink records that the organization's provider asserts
+// issuer+subject is accountID. The identity cannot sign in until the account
+// holder confirms it with ConfirmAccountIdentityLink.
+func (db *DB) RequestAccountIdentityLink(ctx context.Context, orgID, accountID int64, issuer, subject, email string) (AccountIdentity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return insertAccountIdentityWithExecutor(ctx, db.DB, accountID, &orgID, issuer, subject, email, false)
+}
+
+func insertAccountIdentityWithExecutor(ctx context.Context, exec bun.IDB, accountID int64, orgID *int64, issuer, subject, email string, confirmed bool) (AccountIdentity, error) {
+	now := time.Now()
+	identity := AccountIdentity{
+		ID:          newID(),
+		AccountID:   accountID,
+		OrgID:       orgID,
+		Issuer:      issuer,
+		Subject:     subject,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	if confirmed {
+		identity.ConfirmedAt = &now
+	}
+	if _, err := exec.NewInsert().Model(&identity).Exec(ctx); err != nil {
+		return AccountIdentity{}, err
+	}
+	return identity, nil
+}
+
+// TouchAccountIdentity records a sign-in and the email the provider last
+// asserted for the identity.
+func (db *DB) TouchAccountIdentity(ctx context.Context, id, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*AccountIdentity)(nil)).
+		Set("email = ?", email).
+		Set("last_login_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// ConfirmAccountIdentityLink lets the identity with id sign in as accountID.
+// It reports false when accountID has no pending identity with that id.
+func (db *DB) ConfirmAccountIdentityLink(ctx context.Context, accountID int64, id string) (AccountIdentity, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var identity AccountIdentity
+	res, err := db.NewUpdate().
+		Model(&identity).
+		Set("confirmed_at = ?", time.Now()).
+		Where("id = ? AND account_id = ? AND confirmed_at IS NULL", id, accountID).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return AccountIdentity{}, false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return AccountIdentity{}, false, err
+	}
+	return identity, true, nil
+}
+
+// DeclineAccountIdentityLink deletes accountID's pending identity with id. It
+// reports false when there was none.
+func (db *DB) DeclineAccountIdentityLink(ctx context.Context, accountID int64, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().
+		Model((*AccountIdentity)(nil)).
+		Where("id = ? AND account_id = ? AND confirmed_at IS NULL", id, accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// ListPendingSSOLinks returns the identities organization providers asked to
+// link to accountID that the account holder has not confirmed.
+func (db *DB) ListPendingSSOLinks(ctx context.Context, accountID int64) ([]PendingSSOLink, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	links := []PendingSSOLink{}
+	err := db.NewRaw(`
+SELECT ai.id, o.id AS org_id, o.slug AS org_slug, o.name AS org_name, ai.issuer, COALESCE(ai.email, '') AS email, ai.created_at AS requested_at
+FROM account_identities AS ai
+JOIN organizations AS o ON o.id = ai.org_id
+WHERE ai.account_id = ? AND ai.confirmed_at IS NULL
+ORDER BY ai.created_at ASC, ai.id ASC`, accountID).Scan(ctx, &links)
+	return links, err
+}
+
+// ProvisionSSOAccount creates a password-less account together with its
+// identity link, and its org membership when params.OrgID is set.
+func (db *DB) ProvisionSSOAccount(ctx context.Context, params ProvisionSSOAccountParams) (Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var account Account
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		account, err = db.InsertAccountWithExecutor(ctx, tx, params.Email, params.Name, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := insertAccountIdentityWithExecutor(ctx, tx, account.ID, params.OrgID, params.Issuer, params.Subject, params.Email, true); err != nil {
+			return err
+		}
+		if params.OrgID != nil {
+			return db.AddOrgMemberWithExecutor(ctx, tx, *params.OrgID, account.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+func (db *DB) ListSSOGroupMappings(ctx context.Context, orgID int64) ([]SSOGroupMapping, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	mappings := []SSOGroupMapping{}
+	err := db.NewSelect().
+		Model(&mappings).
+		ColumnExpr("sgm.*").
+		ColumnExpr("t.slug AS team_slug").
+		Join("JOIN teams AS t ON t.id = sgm.team_id").
+		Where("sgm.org_id = ?", orgID).
+		OrderExpr("sgm.group_name ASC, t.slug ASC").
+		Scan(ctx)
+	return mappings, err
+}
+
+// ReplaceSSOGroupMappings swaps the organization's group mappings for
+// mappings. Existing team memberships are left alone until each member next
+// signs in.
+func (db *DB) ReplaceSSOGroupMappings(ctx context.Context, orgID int64, mappings []SSOGroupMapping) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*SSOGroupMapping)(nil)).
+			Where("org_id = ?", orgID).
+			Exec(ctx)
+		if err != nil || len(mappings) == 0 {
+			return err
+		}
+		now := time.Now()
+		rows := make([]SSOGroupMapping, len(mappings))
+		for i, mapping := range mappings {
+			rows[i] = SSOGroupMapping{OrgID: orgID, GroupName: mapping.GroupName, TeamID: mapping.TeamID, CreatedAt: now}
+		}
+		_, err = tx.NewInsert().Model(&rows).On("CONFLICT DO NOTHING").Exec(ctx)
+		return err
+	})
+}
+
+// SyncSSOTeamMemberships reconciles the account's membership of mapped teams
+// with the groups asserted at sign-in: it joins every team mapped from one of
+// groups and leaves every other mapped team. Teams without a mapping are not
+// touched. It reports whether any membership changed.
+func (db *DB) SyncSSOTeamMemberships(ctx context.Context, orgID, accountID int64, groups []string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	changed := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var mappings []SSOGroupMapping
+		if err := tx.NewSelect().Model(&mappings).Where("org_id = ?", orgID).Scan(ctx); err != nil {
+			return err
+		}
+		if len(mappings) == 0 {
+			return nil
+		}
+
+		managed := map[int64]bool{}
+		for _, mapping := range mappings {
+			managed[mapping.TeamID] = managed[mapping.TeamID] || slices.Contains(groups, mapping.GroupName)
+		}
+
+		var current []int64
+		err := tx.NewSelect().
+			Model((*TeamMember)(nil)).
+			Column("team_id").
+			Where("account_id = ?", accountID).
+			Scan(ctx, &current)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for teamID, wanted := range managed {
+			member := slices.Contains(current, teamID)
+			switch {
+			case wanted && !member:
+				_, err = tx.NewInsert().
+					Model(&TeamMember{TeamID: teamID, AccountID: accountID, CreatedAt: now}).
+					Exec(ctx)
+			case !wanted && member:
+				_, err = tx.NewDelete().
+					Model((*TeamMember)(nil)).
+					Where("team_id = ? AND account_id = ?", teamID, accountID).
+					Exec(ctx)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			changed = true
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// DeleteOrgIDPConfig removes the organization's identity provider and its
+// group mappings. Identity links are kept so the provider can be restored.
+func (db *DB) DeleteOrgIDPConfig(ctx context.Context, orgID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*SSOGroupMapping)(nil)).Where("org_id = ?", orgID).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*OrgIDPConfig)(nil)).Where("org_id = ?", orgID).Exec(ctx)
+		return err
+	})
+}
+
+// ListOrgIDPConfigs returns every organization's identity provider config.
+func (db *DB) ListOrgIDPConfigs(ctx context.Context) ([]OrgIDPConfig, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var configs []OrgIDPConfig
+	err := db.NewSelect().Model(&configs).OrderExpr("org_id ASC").Scan(ctx)
+	return configs, err
+}
+
+// UpdateOrgIDPConfigData replaces the stored provider settings without
+// changing anything else, e.g. when re-encrypting the client secret.
+func (db *DB) UpdateOrgIDPConfigData(ctx context.Context, orgID int64, config string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := db.NewUpdate().
+		Model((*OrgIDPConfig)(nil)).
+		Set("config = ?", config).
+		Set("updated_at = ?", time.Now()).
+		Where("org_id = ?", orgID).
+		Exec(ctx)
+	return err
+}
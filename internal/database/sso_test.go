@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestConsumeOIDCLoginStateIsOneTime(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			err := db.InsertOIDCLoginState(ctx, OIDCLoginState{
+				ID:           "state-hash",
+				Nonce:        "nonce",
+				CodeVerifier: "verifier",
+				ReturnTo:     "/workbench",
+				ExpiresAt:    time.Now().Add(time.Minute),
+			})
+			assert.Nil(t, err)
+			err = db.InsertOIDCLoginState(ctx, OIDCLoginState{
+				ID:           "expired-hash",
+				Nonce:        "nonce",
+				CodeVerifier: "verifier",
+				ExpiresAt:    time.Now().Add(-time.Minute),
+			})
+			assert.Nil(t, err)
+
+			state, found, err := db.ConsumeOIDCLoginState(ctx, "state-hash")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, state.ReturnTo, "/workbench")
+			assert.Nil(t, state.OrgID)
+
+			_, found, err = db.ConsumeOIDCLoginState(ctx, "state-hash")
+			assert.Nil(t, err)
+			assert.False(t, found)
+
+			_, found, err = db.ConsumeOIDCLoginState(ctx, "expired-hash")
+			assert.Nil(t, err)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestSyncSSOTeamMembershipsOnlyTouchesMappedTeams(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			org, err := db.InsertOrg(ctx, "sso-org", "SSO Org")
+			assert.Nil(t, err)
+			mapped, err := db.InsertTeam(ctx, org.ID, "dba", "DBA")
+			assert.Nil(t, err)
+			manual, err := db.InsertTeam(ctx, org.ID, "support", "Support")
+			assert.Nil(t, err)
+
+			account, err := db.ProvisionSSOAccount(ctx, ProvisionSSOAccountParams{
+				Email:   "ada@example.com",
+				Name:    "Ada",
+				Issuer:  "https://idp.example.com",
+				Subject: "ada",
+				OrgID:   &org.ID,
+			})
+			assert.Nil(t, err)
+			assert.Nil(t, account.Password)
+			assert.Nil(t, db.AddTeamMember(ctx, manual.ID, account.ID))
+
+			identity, found, err := db.GetAccountIdentity(ctx, "https://idp.example.com", "ada")
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, identity.AccountID, account.ID)
+
+			err = db.ReplaceSSOGroupMappings(ctx, org.ID, []SSOGroupMapping{{GroupName: "db-admins", TeamID: mapped.ID}})
+			assert.Nil(t, err)
+			mappings, err := db.ListSSOGroupMappings(ctx, org.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(mappings), 1)
+			assert.Equal(t, mappings[0].TeamSlug, "dba")
+
+			changed, err := db.SyncSSOTeamMemberships(ctx, org.ID, account.ID, []string{"db-admins"})
+			assert.Nil(t, err)
+			assert.True(t, changed)
+			teams, err := db.GetAccountTeams(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(teams), 2)
+
+			changed, err = db.SyncSSOTeamMemberships(ctx, org.ID, account.ID, []string{"db-admins"})
+			assert.Nil(t, err)
+			assert.False(t, changed)
+
+			changed, err = db.SyncSSOTeamMemberships(ctx, org.ID, account.ID, nil)
+			assert.Nil(t, err)
+			assert.True(t, changed)
+			teams, err = db.GetAccountTeams(ctx, org.ID, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(teams), 1)
+			assert.Equal(t, teams[0].ID, manual.ID)
+		})
+	}
+}
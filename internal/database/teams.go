@@ -57,8 +57,14 @@ func (db *DB) InsertTeam(ctx context.Context, orgID int64, slug, name string) (T
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
+	return db.InsertTeamWithExecutor(ctx, db.DB, orgID, slug, name)
+}
+
+// InsertTeamWithExecutor inserts a team using exec so callers can compose it
+// in a larger transaction.
+func (db *DB) InsertTeamWithExecutor(ctx context.Context, exec bun.IDB, orgID int64, slug, name string) (Team, error) {
 	team := Team{OrgID: orgID, Slug: slug, Name: name, CreatedAt: time.Now(), UpdatedAt: time.Now()}
-	_, err := db.NewInsert().Model(&team).Returning("id").Exec(ctx)
+	_, err := exec.NewInsert().Model(&team).Returning("id").Exec(ctx)
 	if err != nil {
 		return Team{}, err
 	}
@@ -124,23 +130,34 @@ func (db *DB) DeleteTeam(ctx context.Context, id, orgID int64) error {
 	defer cancel()
 
 	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		if _, err := tx.NewDelete().
-			Model((*RoleBinding)(nil)).
-			Where("org_id = ? AND subject_type = ? AND subject_id = ?", orgID, "team", id).
-			Exec(ctx); err != nil {
-			return err
-		}
-
-		_, err := tx.NewDelete().Model((*Team)(nil)).Where("id = ? AND org_id = ?", id, orgID).Exec(ctx)
-		return err
+		return db.DeleteTeamWithExecutor(ctx, tx, id, orgID)
 	})
 }
 
+// DeleteTeamWithExecutor deletes a team and the role bindings granted to it
+// using exec.
+func (db *DB) DeleteTeamWithExecutor(ctx context.Context, exec bun.IDB, id, orgID int64) error {
+	if _, err := exec.NewDelete().
+		Model((*RoleBinding)(nil)).
+		Where("org_id = ? AND subject_type = ? AND subject_id = ?", orgID, "team", id).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	_, err := exec.NewDelete().Model((*Team)(nil)).Where("id = ? AND org_id = ?", id, orgID).Exec(ctx)
+	return err
+}
+
 func (db *DB) UpdateTeam(ctx context.Context, id, orgID int64, name string) error {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	_, err := db.NewUpdate().Model((*Team)(nil)).
+	return db.UpdateTeamWithExecutor(ctx, db.DB, id, orgID, name)
+}
+
+// UpdateTeamWithExecutor renames a team using exec.
+func (db *DB) UpdateTeamWithExecutor(ctx context.Context, exec bun.IDB, id, orgID int64, name string) error {
+	_, err := exec.NewUpdate().Model((*Team)(nil)).
 		Set("name = ?", name).
 		Set("updated_at = ?", time.Now()).
 		Where("id = ? AND org_id = ?", id, orgID).
@@ -152,8 +169,13 @@ func (db *DB) AddTeamMember(ctx context.Context, teamID, accountID int64) error
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
+	return db.AddTeamMemberWithExecutor(ctx, db.DB, teamID, accountID)
+}
+
+// AddTeamMemberWithExecutor adds an account to a team using exec.
+func (db *DB) AddTeamMemberWithExecutor(ctx context.Context, exec bun.IDB, teamID, accountID int64) error {
 	member := TeamMember{TeamID: teamID, AccountID: accountID, CreatedAt: time.Now()}
-	_, err := db.NewInsert().Model(&member).Exec(ctx)
+	_, err := exec.NewInsert().Model(&member).Exec(ctx)
 	return err
 }
 
@@ -161,7 +183,12 @@ func (db *DB) RemoveTeamMember(ctx context.Context, teamID, accountID int64) err
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	_, err := db.NewDelete().Model((*TeamMember)(nil)).
+	return db.RemoveTeamMemberWithExecutor(ctx, db.DB, teamID, accountID)
+}
+
+// RemoveTeamMemberWithExecutor removes an account from a team using exec.
+func (db *DB) RemoveTeamMemberWithExecutor(ctx context.Context, exec bun.IDB, teamID, accountID int64) error {
+	_, err := exec.NewDelete().Model((*TeamMember)(nil)).
 		Where("team_id = ? AND account_id = ?", teamID, accountID).Exec(ctx)
 	return err
 }
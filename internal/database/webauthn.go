@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// WebAuthn ceremony purposes.
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+	WebAuthnCeremonyMFA          = "mfa"
+)
+
+// WebAuthnCredential is a passkey or security key registered to an account.
+// CredentialID and PublicKey are base64url.
+type WebAuthnCredential struct {
+	bun.BaseModel `bun:"table:webauthn_credentials"`
+
+	ID             string     `bun:",pk"                json:"id"`
+	AccountID      int64      `bun:",notnull"           json:"account_id"`
+	CredentialID   string     `bun:",notnull"           json:"credential_id"`
+	PublicKey      string     `bun:",notnull"           json:"-"`
+	Algorithm      int64      `bun:",notnull"           json:"algorithm"`
+	SignCount      int64      `bun:",notnull"           json:"-"`
+	AAGUID         string     `bun:"aaguid,notnull"     json:"aaguid"`
+	Transports     []string   `bun:",notnull"           json:"transports"`
+	Name           string     `bun:",notnull"           json:"name"`
+	BackupEligible bool       `bun:",notnull"           json:"backup_eligible"`
+	LastUsedAt     *time.Time `bun:",nullzero"          json:"last_used_at"`
+	CreatedAt      time.Time  `bun:",notnull"           json:"created_at"`
+}
+
+// WebAuthnCeremony is a registration or authentication ceremony waiting for
+// the authenticator's response. ID is the hash of its challenge. Login
+// ceremonies have no account until a credential is presented; MFA ceremonies
+// belong to a password sign-in's MFA login challenge.
+type WebAuthnCeremony struct {
+	bun.BaseModel `bun:"table:webauthn_ceremonies"`
+
+	ID               string    `bun:",pk"`
+	Purpose          string    `bun:",notnull"`
+	AccountID        *int64    `bun:",nullzero"`
+	MFAChallengeID   string    `bun:"mfa_challenge_id,nullzero"`
+	UserVerification string    `bun:",notnull"`
+	ExpiresAt        time.Time `bun:",notnull"`
+	CreatedAt        time.Time `bun:",notnull"`
+}
+
+func (db *DB) InsertWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) (WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	credential.ID = newID()
+	credential.CreatedAt = time.Now()
+	if credential.Transports == nil {
+		credential.Transports = []string{}
+	}
+	_, err := db.NewInsert().Model(&credential).Exec(ctx)
+	return credential, err
+}
+
+func (db *DB) ListWebAuthnCredentials(ctx context.Context, accountID int64) ([]WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	credentials := []WebAuthnCredential{}
+	err := db.NewSelect().
+		Model(&credentials).
+		Where("account_id = ?", accountID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	return credentials, err
+}
+
+func (db *DB) CountWebAuthnCredentials(ctx context.Context, accountID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.NewSelect().
+		Model((*WebAuthnCredential)(nil)).
+		Where("account_id = ?", accountID).
+		Count(ctx)
+}
+
+func (db *DB) GetWebAuthnCredentialByCredentialID(ctx context.Context, credentialID string) (WebAuthnCredential, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var credential WebAuthnCredential
+	err := db.NewSelect().Model(&credential).Where("credential_id = ?", credentialID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WebAuthnCredential{}, false, nil
+	}
+	if err != nil {
+		return WebAuthnCredential{}, false, err
+	}
+	return credential, true, nil
+}
+
+// UseWebAuthnCredential records a verified assertion's sign counter. It
+// reports false when the stored counter changed since previous was read, so
+// two assertions racing with the same counter cannot both succeed.
+func (db *DB) UseWebAuthnCredential(ctx context.Context, id string, previous, signCount int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewUpdate().
+		Model((*WebAuthnCredential)(nil)).
+		Set("sign_count = ?", signCount).
+		Set("last_used_at = ?", time.Now()).
+		Where("id = ? AND sign_count = ?", id, previous).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+func (db *DB) RenameWebAuthnCredential(ctx context.Context, accountID int64, id, name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewUpdate().
+		Model((*WebAuthnCredential)(nil)).
+		Set("name = ?", name).
+		Where("id = ? AND account_id = ?", id, accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+func (db *DB) DeleteWebAuthnCredential(ctx context.Context, accountID int64, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res, err := db.NewDelete().
+		Model((*WebAuthnCredential)(nil)).
+		Where("id = ? AND account_id = ?", id, accountID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// InsertWebAuthnCeremony stores a pending ceremony and prunes expired ones.
+func (db *DB) InsertWebAuthnCeremony(ctx context.Context, ceremony WebAuthnCeremony) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*WebAuthnCeremony)(nil)).
+			Where("expires_at < ?", time.Now()).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		ceremony.CreatedAt = time.Now()
+		_, err = tx.NewInsert().Model(&ceremony).Exec(ctx)
+		return err
+	})
+}
+
+// ConsumeWebAuthnCeremony deletes and returns the pending ceremony with id, so
+// a challenge can be answered at most once. Expired ceremonies are reported
+// as not found.
+func (db *DB) ConsumeWebAuthnCeremony(ctx context.Context, id string) (WebAuthnCeremony, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var ceremony WebAuthnCeremony
+	found := false
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().Model(&ceremony).Where("id = ?", id).Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.NewDelete().Model((*WebAuthnCeremony)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		found = affected == 1 && time.Now().Before(ceremony.ExpiresAt)
+		return nil
+	})
+	if err != nil || !found {
+		return WebAuthnCeremony{}, false, err
+	}
+	return ceremony, true, nil
+}
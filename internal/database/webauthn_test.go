@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestWebAuthnCredentialsAndCeremonies(t *testing.T) {
+	for _, driver := range testDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDB(t, driver)
+			ctx := context.Background()
+
+			account, err := db.InsertAccount(ctx, "passkey@example.com", "Passkey", nil)
+			assert.Nil(t, err)
+			credential, err := db.InsertWebAuthnCredential(ctx, WebAuthnCredential{
+				AccountID:    account.ID,
+				CredentialID: "cred-1",
+				PublicKey:    "key",
+				Algorithm:    -7,
+				Transports:   []string{"usb", "nfc"},
+				Name:         "Key",
+			})
+			assert.Nil(t, err)
+
+			_, err = db.InsertWebAuthnCredential(ctx, WebAuthnCredential{AccountID: account.ID, CredentialID: "cred-1", PublicKey: "key", Name: "Copy"})
+			assert.NotNil(t, err)
+
+			found, ok, err := db.GetWebAuthnCredentialByCredentialID(ctx, "cred-1")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, found.AccountID, account.ID)
+			assert.Equal(t, len(found.Transports), 2)
+
+			used, err := db.UseWebAuthnCredential(ctx, credential.ID, 0, 5)
+			assert.Nil(t, err)
+			assert.True(t, used)
+			used, err = db.UseWebAuthnCredential(ctx, credential.ID, 0, 6)
+			assert.Nil(t, err)
+			assert.False(t, used)
+			credentials, err := db.ListWebAuthnCredentials(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, len(credentials), 1)
+			assert.Equal(t, credentials[0].SignCount, int64(5))
+			assert.NotNil(t, credentials[0].LastUsedAt)
+
+			assert.Nil(t, db.InsertWebAuthnCeremony(ctx, WebAuthnCeremony{
+				ID:               "challenge-hash",
+				Purpose:          WebAuthnCeremonyRegistration,
+				AccountID:        &account.ID,
+				UserVerification: "preferred",
+				ExpiresAt:        time.Now().Add(time.Minute),
+			}))
+			ceremony, ok, err := db.ConsumeWebAuthnCeremony(ctx, "challenge-hash")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, *ceremony.AccountID, account.ID)
+			_, ok, err = db.ConsumeWebAuthnCeremony(ctx, "challenge-hash")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+
+			assert.Nil(t, db.InsertWebAuthnCeremony(ctx, WebAuthnCeremony{
+				ID:               "expired-hash",
+				Purpose:          WebAuthnCeremonyLogin,
+				UserVerification: "required",
+				ExpiresAt:        time.Now().Add(-time.Minute),
+			}))
+			_, ok, err = db.ConsumeWebAuthnCeremony(ctx, "expired-hash")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+
+			reset, err := db.ResetAccountMFA(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.True(t, reset)
+			count, err := db.CountWebAuthnCredentials(ctx, account.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, count, 0)
+		})
+	}
+}
@@ -353,6 +353,20 @@ func (db *DB) GetWorkspaceFileContent(ctx context.Context, contentID int64) (Wor
 	return content, err == nil, err
 }
 
+// GetWorkspaceFileContentVersion returns a file's content metadata row for
+// one revision number.
+func (db *DB) GetWorkspaceFileContentVersion(ctx context.Context, fileID int64, version int) (WorkspaceFileContent, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var content WorkspaceFileContent
+	err := db.NewSelect().Model(&content).Where("file_id = ? AND version = ?", fileID, version).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkspaceFileContent{}, false, nil
+	}
+	return content, err == nil, err
+}
+
 // ListApplicationEncryptedFileContents returns every content row whose bytes
 // were sealed by the application encryption key. It is used by encryption-key
 // rotation to re-encrypt at-rest file content and must not be tenant-scoped.
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/sqlwarden/internal/response"
+	"github.com/uptrace/bun"
 )
 
 type WorkspaceMember struct {
@@ -83,8 +84,13 @@ func (db *DB) AddWorkspaceMember(ctx context.Context, workspaceID, accountID int
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
+	return db.AddWorkspaceMemberWithExecutor(ctx, db.DB, workspaceID, accountID, createdBy)
+}
+
+// AddWorkspaceMemberWithExecutor adds an account to a workspace using exec.
+func (db *DB) AddWorkspaceMemberWithExecutor(ctx context.Context, exec bun.IDB, workspaceID, accountID int64, createdBy *int64) error {
 	member := WorkspaceMember{WorkspaceID: workspaceID, AccountID: accountID, CreatedBy: createdBy, CreatedAt: time.Now()}
-	_, err := db.NewInsert().Model(&member).Ignore().Exec(ctx)
+	_, err := exec.NewInsert().Model(&member).Ignore().Exec(ctx)
 	return err
 }
 
@@ -92,7 +98,12 @@ func (db *DB) RemoveWorkspaceMember(ctx context.Context, workspaceID, accountID
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	_, err := db.NewDelete().Model((*WorkspaceMember)(nil)).
+	return db.RemoveWorkspaceMemberWithExecutor(ctx, db.DB, workspaceID, accountID)
+}
+
+// RemoveWorkspaceMemberWithExecutor removes an account from a workspace using exec.
+func (db *DB) RemoveWorkspaceMemberWithExecutor(ctx context.Context, exec bun.IDB, workspaceID, accountID int64) error {
+	_, err := exec.NewDelete().Model((*WorkspaceMember)(nil)).
 		Where("workspace_id = ? AND account_id = ?", workspaceID, accountID).
 		Exec(ctx)
 	return err
@@ -102,8 +113,13 @@ func (db *DB) AddWorkspaceTeam(ctx context.Context, workspaceID, teamID int64, c
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
+	return db.AddWorkspaceTeamWithExecutor(ctx, db.DB, workspaceID, teamID, createdBy)
+}
+
+// AddWorkspaceTeamWithExecutor adds a team to a workspace using exec.
+func (db *DB) AddWorkspaceTeamWithExecutor(ctx context.Context, exec bun.IDB, workspaceID, teamID int64, createdBy *int64) error {
 	team := WorkspaceTeam{WorkspaceID: workspaceID, TeamID: teamID, CreatedBy: createdBy, CreatedAt: time.Now()}
-	_, err := db.NewInsert().Model(&team).Ignore().Exec(ctx)
+	_, err := exec.NewInsert().Model(&team).Ignore().Exec(ctx)
 	return err
 }
 
@@ -111,7 +127,12 @@ func (db *DB) RemoveWorkspaceTeam(ctx context.Context, workspaceID, teamID int64
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	_, err := db.NewDelete().Model((*WorkspaceTeam)(nil)).
+	return db.RemoveWorkspaceTeamWithExecutor(ctx, db.DB, workspaceID, teamID)
+}
+
+// RemoveWorkspaceTeamWithExecutor removes a team from a workspace using exec.
+func (db *DB) RemoveWorkspaceTeamWithExecutor(ctx context.Context, exec bun.IDB, workspaceID, teamID int64) error {
+	_, err := exec.NewDelete().Model((*WorkspaceTeam)(nil)).
 		Where("workspace_id = ? AND team_id = ?", workspaceID, teamID).
 		Exec(ctx)
 	return err
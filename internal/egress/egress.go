@@ -0,0 +1,293 @@
+// Package egress decides which network targets the server may open database
+// connections to. A Policy stacks rule sets, one per level such as the
+// instance and an organization; a target must pass every level. Checks run
+// when the connection is dialed, against the addresses the dial will use.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ErrBlocked is matched by every error the policy returns for a target it
+// does not allow.
+var ErrBlocked = errors.New("blocked by egress policy")
+
+// BlockedError reports a target the policy does not allow. Addr is the
+// resolved address that was refused, and is invalid when the name alone was.
+type BlockedError struct {
+	Host string
+	Addr netip.Addr
+}
+
+func (e *BlockedError) Error() string {
+	if e.Addr.IsValid() && e.Addr.String() != e.Host {
+		return fmt.Sprintf("target %s (%s) is %s", e.Host, e.Addr, ErrBlocked)
+	}
+	return fmt.Sprintf("target %s is %s", e.Host, ErrBlocked)
+}
+
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// Rules is one level of policy. Entries are host names, IP addresses, or CIDR
+// ranges; a host name starting with "*." also matches every name below it.
+// An empty Allowed list allows every target Denied does not name.
+type Rules struct {
+	Allowed []string `json:"allowed_targets"`
+	Denied  []string `json:"denied_targets"`
+}
+
+// Empty reports whether the rules allow everything.
+func (r Rules) Empty() bool {
+	return len(r.Allowed) == 0 && len(r.Denied) == 0
+}
+
+// ValidateEntry reports whether entry is a usable rule.
+func ValidateEntry(entry string) error {
+	_, err := parseRuleSet([]string{entry})
+	return err
+}
+
+// linkLocal covers link-local ranges, where cloud instance metadata services
+// answer, and metadata addresses outside them.
+var linkLocal = []netip.Prefix{
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fd00:ec2::254/128"),
+	netip.MustParsePrefix("100.100.100.200/32"),
+}
+
+// metadataHosts are names of metadata services, for targets resolved at the
+// far end of a tunnel or agent.
+var metadataHosts = []string{"metadata.google.internal", "metadata"}
+
+type ruleSet struct {
+	hosts    []string
+	suffixes []string
+	prefixes []netip.Prefix
+}
+
+func parseRuleSet(entries []string) (ruleSet, error) {
+	var set ruleSet
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			return ruleSet{}, errors.New("empty target")
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return ruleSet{}, fmt.Errorf("target %q is not a valid CIDR range", entry)
+			}
+			set.prefixes = append(set.prefixes, prefix.Masked())
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				set.prefixes = append(set.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			name, wildcard := strings.CutPrefix(entry, "*.")
+			if !validHostName(name) {
+				return ruleSet{}, fmt.Errorf("target %q is not a host name, IP address, or CIDR range", entry)
+			}
+			if wildcard {
+				set.suffixes = append(set.suffixes, "."+name)
+			} else {
+				set.hosts = append(set.hosts, name)
+			}
+		}
+	}
+	return set, nil
+}
+
+func validHostName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for label := range strings.SplitSeq(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s ruleSet) empty() bool {
+	return len(s.hosts) == 0 && len(s.suffixes) == 0 && len(s.prefixes) == 0
+}
+
+func (s ruleSet) matchesName(name string) bool {
+	for _, host := range s.hosts {
+		if name == host {
+			return true
+		}
+	}
+	for _, suffix := range s.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s ruleSet) contains(addr netip.Addr) bool {
+	for _, prefix := range s.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type level struct {
+	allow ruleSet
+	deny  ruleSet
+}
+
+// Policy decides which targets may be dialed. The zero Policy allows every
+// network target but no Unix sockets.
+type Policy struct {
+	levels           []level
+	blockLinkLocal   bool
+	allowUnixSockets bool
+	lookup           func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// New returns a policy made of the given levels. With blockLinkLocal,
+// link-local and cloud metadata addresses are refused whatever the levels
+// allow.
+func New(blockLinkLocal bool, levels ...Rules) (*Policy, error) {
+	p := &Policy{blockLinkLocal: blockLinkLocal}
+	for _, rules := range levels {
+		allow, err := parseRuleSet(rules.Allowed)
+		if err != nil {
+			return nil, err
+		}
+		deny, err := parseRuleSet(rules.Denied)
+		if err != nil {
+			return nil, err
+		}
+		p.levels = append(p.levels, level{allow: allow, deny: deny})
+	}
+	return p, nil
+}
+
+// AllowUnixSockets sets whether the policy's dialers may reach Unix domain
+// sockets. Rules cannot name sockets, so this is all or nothing; it is off by
+// default because a socket such as the application database's or the Docker
+// daemon's would otherwise be reachable past every rule.
+func (p *Policy) AllowUnixSockets(allow bool) *Policy {
+	p.allowUnixSockets = allow
+	return p
+}
+
+// check decides a target named host. addr is where the dial will go, or
+// invalid when the target is resolved elsewhere; then an allow list only
+// admits the target by name.
+func (p *Policy) check(host string, addr netip.Addr) error {
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	addr = addr.Unmap()
+	blocked := &BlockedError{Host: host, Addr: addr}
+	if p.blockLinkLocal {
+		for _, metadata := range metadataHosts {
+			if name == metadata {
+				return blocked
+			}
+		}
+		for _, prefix := range linkLocal {
+			if addr.IsValid() && prefix.Contains(addr) {
+				return blocked
+			}
+		}
+	}
+	for _, l := range p.levels {
+		if l.deny.matchesName(name) || addr.IsValid() && l.deny.contains(addr) {
+			return blocked
+		}
+		if !l.allow.empty() && !l.allow.matchesName(name) && !(addr.IsValid() && l.allow.contains(addr)) {
+			return blocked
+		}
+	}
+	return nil
+}
+
+// DialFunc opens a network connection, as net.Dialer.DialContext does.
+type DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dialer returns a dial function that only reaches targets the policy
+// allows. With dial nil it resolves host names itself and connects to an
+// allowed address, so a DNS answer that changes after the check cannot steer
+// the connection elsewhere. With dial set, which reaches the target from
+// somewhere else such as an SSH jump host, only the target as named is
+// checked. Unix sockets are refused unless AllowUnixSockets is set.
+func (p *Policy) Dialer(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(network, "unix") {
+			if !p.allowUnixSockets {
+				return nil, &BlockedError{Host: address}
+			}
+			if dial == nil {
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			}
+			return dial(ctx, network, address)
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		literal, err := netip.ParseAddr(host)
+		if err != nil {
+			literal = netip.Addr{}
+		}
+		if dial != nil {
+			if err := p.check(host, literal); err != nil {
+				return nil, err
+			}
+			return dial(ctx, network, address)
+		}
+
+		addrs := []netip.Addr{literal}
+		if !literal.IsValid() {
+			lookup := p.lookup
+			if lookup == nil {
+				lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+					return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+				}
+			}
+			addrs, err = lookup(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var d net.Dialer
+		var lastErr error
+		for _, addr := range addrs {
+			if err := p.check(host, addr); err != nil {
+				if lastErr == nil {
+					lastErr = err
+				}
+				continue
+			}
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("lookup %s: no addresses", host)
+		}
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,161 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := New(true,
+		Rules{Allowed: []string{"10.0.0.0/8", "*.db.example.com", "Reports.internal"}, Denied: []string{"10.9.0.0/16"}},
+		Rules{Denied: []string{"legacy.db.example.com"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host string
+		addr string
+		want bool
+	}{
+		{"10.1.2.3", "10.1.2.3", true},
+		{"10.9.1.1", "10.9.1.1", false},
+		{"192.168.1.5", "192.168.1.5", false},
+		{"orders.db.example.com", "", true},
+		{"orders.db.example.com", "203.0.113.9", true},
+		{"legacy.db.example.com", "10.1.2.3", false},
+		{"reports.internal.", "", true},
+		{"warehouse.internal", "", false},
+		{"warehouse.internal", "10.4.4.4", true},
+		{"::ffff:10.1.2.3", "::ffff:10.1.2.3", true},
+		{"orders.db.example.com", "169.254.169.254", false},
+		{"metadata.google.internal", "", false},
+	}
+	for _, tt := range tests {
+		var addr netip.Addr
+		if tt.addr != "" {
+			addr = netip.MustParseAddr(tt.addr)
+		}
+		err := policy.check(tt.host, addr)
+		if (err == nil) != tt.want {
+			t.Errorf("check(%q, %q) = %v, want allowed=%v", tt.host, tt.addr, err, tt.want)
+		}
+		if err != nil && !errors.Is(err, ErrBlocked) {
+			t.Errorf("check(%q, %q) = %v, want ErrBlocked", tt.host, tt.addr, err)
+		}
+	}
+
+	open, err := New(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := open.check("metadata.google.internal", netip.MustParseAddr("169.254.169.254")); err != nil {
+		t.Errorf("open policy blocked metadata: %v", err)
+	}
+
+	for _, entry := range []string{"10.0.0.0/33", "", "db..internal", "db internal", "-db.internal"} {
+		if ValidateEntry(entry) == nil {
+			t.Errorf("ValidateEntry(%q) accepted an invalid entry", entry)
+		}
+	}
+}
+
+func TestDialerPinsCheckedAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	policy, err := New(true, Rules{Denied: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.lookup = func(context.Context, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("10.1.1.1"), netip.MustParseAddr("127.0.0.1")}, nil
+	}
+	dial := policy.Dialer(nil)
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("db.example.com", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := conn.RemoteAddr().String(); got != listener.Addr().String() {
+		t.Errorf("dialed %s, want %s", got, listener.Addr())
+	}
+	conn.Close()
+
+	policy.lookup = func(context.Context, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("169.254.169.254")}, nil
+	}
+	if _, err := dial(context.Background(), "tcp", net.JoinHostPort("db.example.com", port)); !errors.Is(err, ErrBlocked) {
+		t.Errorf("dial to a metadata address = %v, want ErrBlocked", err)
+	}
+
+	var forwarded string
+	remote := policy.Dialer(func(_ context.Context, _, addr string) (net.Conn, error) {
+		forwarded = addr
+		return nil, errors.New("unreachable")
+	})
+	if _, err := remote(context.Background(), "tcp", "10.2.2.2:5432"); !errors.Is(err, ErrBlocked) || forwarded != "" {
+		t.Errorf("remote dial to a denied address = %v, forwarded %q", err, forwarded)
+	}
+	if _, _ = remote(context.Background(), "tcp", "db.internal:5432"); forwarded != "db.internal:5432" {
+		t.Errorf("remote dial forwarded %q, want the named target", forwarded)
+	}
+}
+
+func TestDialerRefusesUnixSocketsUnlessAllowed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	policy, err := New(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwarded bool
+	remote := policy.Dialer(func(context.Context, string, string) (net.Conn, error) {
+		forwarded = true
+		return nil, errors.New("unreachable")
+	})
+	for _, dial := range []DialFunc{policy.Dialer(nil), remote} {
+		if _, err := dial(context.Background(), "unix", path); !errors.Is(err, ErrBlocked) {
+			t.Errorf("dial to a unix socket = %v, want ErrBlocked", err)
+		}
+	}
+	if forwarded {
+		t.Error("a refused unix socket dial was forwarded")
+	}
+
+	conn, err := policy.AllowUnixSockets(true).Dialer(nil)(context.Background(), "unix", path)
+	if err != nil {
+		t.Fatalf("dial with unix sockets allowed: %v", err)
+	}
+	conn.Close()
+}
@@ -1,14 +1,20 @@
 package encrypt
 
 import (
+	"crypto/hkdf"
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
 )
 
-const envelopeVersion = "k2"
+const (
+	envelopeVersion      = "k2"
+	macDerivationContext = "sqlwarden/mac-key/v1"
+)
 
 // Keyring holds the active encryption key plus any retired keys kept around so
 // ciphertext produced with previous configured keys stays decryptable through
@@ -20,6 +26,7 @@ const envelopeVersion = "k2"
 type Keyring struct {
 	primaryID string
 	keys      map[string][]byte // key id -> 32-byte key material
+	macKeys   map[string][]byte // key id -> 32-byte HMAC key derived from key material
 }
 
 // NewKeyring builds a keyring from a primary passphrase and zero or more
@@ -31,7 +38,7 @@ func NewKeyring(primary string, previous ...string) (*Keyring, error) {
 		return nil, errors.New("encrypt: primary key must not be empty")
 	}
 
-	kr := &Keyring{keys: make(map[string][]byte)}
+	kr := &Keyring{keys: make(map[string][]byte), macKeys: make(map[string][]byte)}
 
 	add := func(passphrase string) (string, error) {
 		key, err := DeriveKey(passphrase)
@@ -40,7 +47,12 @@ func NewKeyring(primary string, previous ...string) (*Keyring, error) {
 		}
 		id := keyID(key)
 		if _, exists := kr.keys[id]; !exists {
+			macKey, err := hkdf.Key(sha256.New, key, nil, macDerivationContext, 32)
+			if err != nil {
+				return "", err
+			}
 			kr.keys[id] = key
+			kr.macKeys[id] = macKey
 		}
 		return id, nil
 	}
@@ -102,6 +114,35 @@ func (k *Keyring) NeedsRotation(ciphertext string) bool {
 	return id != k.primaryID
 }
 
+// Sign computes an HMAC-SHA-256 over message with a MAC key derived from the
+// primary key. It returns the id of the signing key and the hex-encoded MAC.
+// MAC keys are domain-separated from encryption keys, so a signature never
+// reveals anything usable for decryption.
+func (k *Keyring) Sign(message []byte) (id, mac string) {
+	return k.primaryID, hex.EncodeToString(computeMAC(k.macKeys[k.primaryID], message))
+}
+
+// Verify reports whether mac is a valid signature of message under the key
+// identified by id. It returns an error when id is not held by the keyring, so
+// callers can tell a retired-and-removed key apart from a forged signature.
+func (k *Keyring) Verify(id string, message []byte, mac string) (bool, error) {
+	macKey, ok := k.macKeys[id]
+	if !ok {
+		return false, fmt.Errorf("encrypt: no key for id %q", id)
+	}
+	decoded, err := hex.DecodeString(mac)
+	if err != nil {
+		return false, nil
+	}
+	return hmac.Equal(decoded, computeMAC(macKey, message)), nil
+}
+
+func computeMAC(key, message []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
 // keyID derives a short, stable, non-reversible fingerprint of a key. It hashes
 // the (already hashed) key material and truncates, so it leaks nothing about the
 // underlying passphrase while remaining deterministic across processes.
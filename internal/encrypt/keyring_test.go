@@ -232,3 +232,67 @@ func TestKeyringDuplicatePassphraseDeduped(t *testing.T) {
 		t.Errorf("expected %q, got %q", "data", got)
 	}
 }
+
+func TestKeyringSignVerify(t *testing.T) {
+	kr, err := encrypt.NewKeyring("primary-passphrase")
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+
+	id, mac := kr.Sign([]byte("audit record"))
+	if id != kr.PrimaryKeyID() {
+		t.Errorf("expected key id %q, got %q", kr.PrimaryKeyID(), id)
+	}
+
+	ok, err := kr.Verify(id, []byte("audit record"), mac)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected signature to verify")
+	}
+
+	ok, err = kr.Verify(id, []byte("edited record"), mac)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if ok {
+		t.Error("expected signature over a different message to fail")
+	}
+
+	ok, err = kr.Verify(id, []byte("audit record"), "not-hex")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if ok {
+		t.Error("expected malformed signature to fail")
+	}
+}
+
+func TestKeyringVerifiesWithPreviousKey(t *testing.T) {
+	oldKR, err := encrypt.NewKeyring("old-passphrase")
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	id, mac := oldKR.Sign([]byte("audit record"))
+
+	rotated, err := encrypt.NewKeyring("new-passphrase", "old-passphrase")
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	ok, err := rotated.Verify(id, []byte("audit record"), mac)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected signature from previous key to verify")
+	}
+
+	newOnly, err := encrypt.NewKeyring("new-passphrase")
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	if _, err := newOnly.Verify(id, []byte("audit record"), mac); err == nil {
+		t.Error("expected error for unknown key id, got nil")
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"github.com/sqlwarden/internal/engine/classifier"
 	"github.com/sqlwarden/internal/engine/completer"
+	"github.com/sqlwarden/internal/engine/connconfig"
 	"github.com/sqlwarden/internal/engine/cursor"
 	"github.com/sqlwarden/internal/engine/ddl"
 	"github.com/sqlwarden/internal/engine/metadata"
@@ -51,6 +52,10 @@ const (
 	// which is why this capability can read false while confirmation gating
 	// still applies (see connectionSafetyChecker in internal/web).
 	CapabilitySQLSafetyCheck Capability = "sql.safety_check"
+	// CapabilityConnectionConfig assembles DSNs from the structured fields in
+	// connconfig.Builder.ConnectionSpec and parses existing DSNs back into them.
+	// Engines without it accept raw DSNs only.
+	CapabilityConnectionConfig Capability = "connection.config"
 )
 
 // CapabilitySet is an engine's static capability report. Safe to compute and
@@ -64,6 +69,8 @@ type CapabilitySet struct {
 	DDL *ddl.Spec `json:"schema_edit,omitempty"`
 	// Statements accompanies sql.generate.
 	Statements *statement.Spec `json:"statements,omitempty"`
+	// Connection accompanies connection.config.
+	Connection *connconfig.Spec `json:"connection,omitempty"`
 }
 
 // capabilitiesOf derives an engine's capabilities by type-asserting a fresh,
@@ -71,18 +78,20 @@ type CapabilitySet struct {
 // DERIVED, never hand-declared, so a reported capability can never disagree with
 // what the engine actually implements. The probe is created but never connected,
 // which is why this works for the static /engines report.
-func capabilitiesOf(reg Registration) (map[Capability]bool, *metadata.SchemaSpec, *ddl.Spec, *statement.Spec) {
+func capabilitiesOf(reg Registration) (map[Capability]bool, *metadata.SchemaSpec, *ddl.Spec, *statement.Spec, *connconfig.Spec) {
 	probe := reg.New()
 	caps := map[Capability]bool{
-		CapabilitySchemaDirectory: false,
-		CapabilitySchemaObjects:   false,
-		CapabilityDDL:             false,
-		CapabilityQueryCursor:     false,
-		CapabilitySQLGenerate:     false,
+		CapabilitySchemaDirectory:  false,
+		CapabilitySchemaObjects:    false,
+		CapabilityDDL:              false,
+		CapabilityQueryCursor:      false,
+		CapabilitySQLGenerate:      false,
+		CapabilityConnectionConfig: false,
 	}
 	var spec *metadata.SchemaSpec
 	var ddlSpec *ddl.Spec
 	var statementSpec *statement.Spec
+	var connectionSpec *connconfig.Spec
 	if si, ok := probe.(metadata.SchemaInspector); ok {
 		caps[CapabilitySchemaDirectory] = true
 		caps[CapabilitySchemaObjects] = true
@@ -99,24 +108,30 @@ func capabilitiesOf(reg Registration) (map[Capability]bool, *metadata.SchemaSpec
 		s := generator.StatementSpec()
 		statementSpec = &s
 	}
+	if builder, ok := probe.(connconfig.Builder); ok {
+		caps[CapabilityConnectionConfig] = true
+		s := builder.ConnectionSpec()
+		connectionSpec = &s
+	}
 	_, caps[CapabilityQueryCursor] = probe.(cursor.QueryCursorDriver)
 	_, caps[CapabilitySQLClassify] = probe.(classifier.Classifier)
 	_, caps[CapabilitySQLSafetyCheck] = probe.(safety.Checker)
 	_, caps[CapabilitySQLParse] = probe.(parser.Parser)
 	_, caps[CapabilitySQLRewrite] = probe.(rewriter.Rewriter)
 	_, caps[CapabilitySQLComplete] = probe.(completer.Completer)
-	return caps, spec, ddlSpec, statementSpec
+	return caps, spec, ddlSpec, statementSpec, connectionSpec
 }
 
 // capabilityReport builds the full static capability report for an engine: its
 // descriptor plus the derived capability map and schema spec.
 func capabilityReport(reg Registration) CapabilitySet {
-	caps, spec, ddlSpec, statementSpec := capabilitiesOf(reg)
+	caps, spec, ddlSpec, statementSpec, connectionSpec := capabilitiesOf(reg)
 	return CapabilitySet{
 		Engine:       EngineDescriptor{ID: reg.ID, DisplayName: reg.DisplayName, Dialect: reg.Dialect},
 		Capabilities: caps,
 		Schema:       spec,
 		DDL:          ddlSpec,
 		Statements:   statementSpec,
+		Connection:   connectionSpec,
 	}
 }
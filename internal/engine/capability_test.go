@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/sqlwarden/internal/engine/connconfig"
 	"github.com/sqlwarden/internal/engine/cursor"
 	"github.com/sqlwarden/internal/engine/ddl"
 	"github.com/sqlwarden/internal/engine/metadata"
@@ -38,6 +39,11 @@ func (capabilityDriver) Generate(statement.Request) (string, error) { return "SE
 func (capabilityDriver) Check(context.Context, safety.Request) (safety.Result, error) {
 	return safety.Result{Source: "omni"}, nil
 }
+func (capabilityDriver) ConnectionSpec() connconfig.Spec {
+	return connconfig.Spec{Fields: []connconfig.Field{{Name: connconfig.FieldHost, Type: connconfig.TypeText}}}
+}
+func (capabilityDriver) BuildDSN(connconfig.Config) (string, error) { return "", nil }
+func (capabilityDriver) ParseDSN(string) (connconfig.Config, error) { return connconfig.Config{}, nil }
 
 func TestCapabilitiesDerivedFromInterfaces(t *testing.T) {
 	resetRegistry(t)
@@ -65,6 +71,9 @@ func TestCapabilitiesDerivedFromInterfaces(t *testing.T) {
 	if !set.Capabilities[CapabilitySQLGenerate] || set.Statements == nil {
 		t.Errorf("sql.generate and its spec should be derived from Generator: %+v", set)
 	}
+	if !set.Capabilities[CapabilityConnectionConfig] || set.Connection == nil {
+		t.Errorf("connection.config and its spec should be derived from Builder: %+v", set)
+	}
 	if !set.Capabilities[CapabilitySQLSafetyCheck] {
 		t.Errorf("sql.safety_check should be true (driver implements Check): %+v", set.Capabilities)
 	}
@@ -0,0 +1,247 @@
+// Package connconfig defines the optional engine capability for structured
+// connection settings: a per-engine field schema, server-side DSN assembly, and
+// parsing of existing DSNs back into fields.
+package connconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// FieldType tells a client which input to render for a field.
+type FieldType string
+
+const (
+	TypeText   FieldType = "text"
+	TypeNumber FieldType = "number"
+	TypeSecret FieldType = "secret"
+	TypeSelect FieldType = "select"
+	TypePEM    FieldType = "pem"
+	TypeParams FieldType = "params"
+)
+
+// Field names shared by every engine schema. Config has one member per name.
+const (
+	FieldHost       = "host"
+	FieldPort       = "port"
+	FieldDatabase   = "database"
+	FieldUser       = "user"
+	FieldPassword   = "password"
+	FieldSSLMode    = "sslmode"
+	FieldCACert     = "ca_cert"
+	FieldClientCert = "client_cert"
+	FieldClientKey  = "client_key"
+	FieldParams     = "params"
+)
+
+// MaskedValue replaces secret values in responses. A config submitted with
+// MaskedValue in a secret field keeps the stored secret; see WithSecretsFrom.
+const MaskedValue = "********"
+
+// ErrUnparseable reports a DSN that has no structured equivalent, such as one
+// with several hosts or certificate file paths.
+var ErrUnparseable = errors.New("dsn cannot be represented as structured settings")
+
+// Field describes one structured connection setting.
+type Field struct {
+	Name     string    `json:"name"`
+	Label    string    `json:"label"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required,omitempty"`
+	Secret   bool      `json:"secret,omitempty"`
+	Default  string    `json:"default,omitempty"`
+	Options  []string  `json:"options,omitempty"`
+}
+
+// Spec is the static connection schema advertised by an engine.
+type Spec struct {
+	Fields []Field `json:"fields"`
+}
+
+// Field returns the named field.
+func (s Spec) Field(name string) (Field, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Config is a structured connection configuration. Which members apply is
+// engine-specific and described by the engine's Spec.
+type Config struct {
+	Host       string            `json:"host,omitempty"`
+	Port       int               `json:"port,omitempty"`
+	Database   string            `json:"database,omitempty"`
+	User       string            `json:"user,omitempty"`
+	Password   string            `json:"password,omitempty"`
+	SSLMode    string            `json:"sslmode,omitempty"`
+	CACert     string            `json:"ca_cert,omitempty"`
+	ClientCert string            `json:"client_cert,omitempty"`
+	ClientKey  string            `json:"client_key,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+}
+
+// Builder assembles and parses DSNs for an engine. Both directions are pure:
+// neither opens a connection nor reads local files.
+type Builder interface {
+	// ConnectionSpec reports the fields BuildDSN accepts.
+	ConnectionSpec() Spec
+	// BuildDSN validates cfg and returns the engine DSN. Certificates are not
+	// part of the DSN; the engine receives them through TLS.
+	BuildDSN(cfg Config) (string, error)
+	// ParseDSN returns the structured form of dsn, or ErrUnparseable.
+	ParseDSN(dsn string) (Config, error)
+}
+
+// FieldError reports an invalid value for one field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Validate checks cfg against spec: unsupported and required fields, select
+// options, the port range, and that certificates parse.
+func Validate(spec Spec, cfg Config) error {
+	values := map[string]bool{
+		FieldHost:       cfg.Host != "",
+		FieldPort:       cfg.Port != 0,
+		FieldDatabase:   cfg.Database != "",
+		FieldUser:       cfg.User != "",
+		FieldPassword:   cfg.Password != "",
+		FieldSSLMode:    cfg.SSLMode != "",
+		FieldCACert:     cfg.CACert != "",
+		FieldClientCert: cfg.ClientCert != "",
+		FieldClientKey:  cfg.ClientKey != "",
+		FieldParams:     len(cfg.Params) > 0,
+	}
+	for _, name := range []string{FieldHost, FieldPort, FieldDatabase, FieldUser, FieldPassword, FieldSSLMode, FieldCACert, FieldClientCert, FieldClientKey, FieldParams} {
+		field, ok := spec.Field(name)
+		if !ok {
+			if values[name] {
+				return &FieldError{Field: name, Message: "is not supported by this engine"}
+			}
+			continue
+		}
+		if field.Required && !values[name] {
+			return &FieldError{Field: name, Message: "is required"}
+		}
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return &FieldError{Field: FieldPort, Message: "must be between 1 and 65535"}
+	}
+	if field, ok := spec.Field(FieldSSLMode); ok && cfg.SSLMode != "" && !slices.Contains(field.Options, cfg.SSLMode) {
+		return &FieldError{Field: FieldSSLMode, Message: "must be one of the listed modes"}
+	}
+	if cfg.Password == MaskedValue || cfg.ClientKey == MaskedValue {
+		field := FieldPassword
+		if cfg.ClientKey == MaskedValue {
+			field = FieldClientKey
+		}
+		return &FieldError{Field: field, Message: "must be entered again"}
+	}
+	if _, err := cfg.TLS().Config(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Masked returns a copy of cfg with secret values replaced by MaskedValue.
+func (c Config) Masked() Config {
+	if c.Password != "" {
+		c.Password = MaskedValue
+	}
+	if c.ClientKey != "" {
+		c.ClientKey = MaskedValue
+	}
+	return c
+}
+
+// WithSecretsFrom returns a copy of cfg where secrets submitted as
+// MaskedValue are taken from stored.
+func (c Config) WithSecretsFrom(stored Config) Config {
+	if c.Password == MaskedValue {
+		c.Password = stored.Password
+	}
+	if c.ClientKey == MaskedValue {
+		c.ClientKey = stored.ClientKey
+	}
+	return c
+}
+
+// PortOrDefault returns the configured port, or port when none is set.
+func (c Config) PortOrDefault(port int) string {
+	if c.Port != 0 {
+		port = c.Port
+	}
+	return strconv.Itoa(port)
+}
+
+// TLS returns the certificate material in cfg.
+func (c Config) TLS() TLS {
+	return TLS{CACert: c.CACert, ClientCert: c.ClientCert, ClientKey: c.ClientKey}
+}
+
+// TLS is PEM-encoded certificate material an engine adds to the TLS settings
+// it derives from its own sslmode.
+type TLS struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// Empty reports whether there is no material.
+func (t TLS) Empty() bool {
+	return t.CACert == "" && t.ClientCert == "" && t.ClientKey == ""
+}
+
+// Config parses the material into a partial tls.Config holding only RootCAs
+// and Certificates.
+func (t TLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if t.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.CACert)) {
+			return nil, &FieldError{Field: FieldCACert, Message: "must be a PEM-encoded certificate"}
+		}
+		cfg.RootCAs = pool
+	}
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return nil, &FieldError{Field: FieldClientKey, Message: "client certificate and key must be set together"}
+	}
+	if t.ClientCert != "" {
+		pair, err := tls.X509KeyPair([]byte(t.ClientCert), []byte(t.ClientKey))
+		if err != nil {
+			return nil, &FieldError{Field: FieldClientCert, Message: "must be a PEM-encoded certificate matching the client key"}
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// Apply adds the material to target, which the engine built from its sslmode.
+func (t TLS) Apply(target *tls.Config) error {
+	if target == nil || t.Empty() {
+		return nil
+	}
+	parsed, err := t.Config()
+	if err != nil {
+		return err
+	}
+	if parsed.RootCAs != nil {
+		target.RootCAs = parsed.RootCAs
+	}
+	if len(parsed.Certificates) > 0 {
+		target.Certificates = parsed.Certificates
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package connconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var testSpec = Spec{Fields: []Field{
+	{Name: FieldHost, Type: TypeText, Required: true},
+	{Name: FieldPort, Type: TypeNumber},
+	{Name: FieldPassword, Type: TypeSecret, Secret: true},
+	{Name: FieldSSLMode, Type: TypeSelect, Options: []string{"disable", "require"}},
+	{Name: FieldCACert, Type: TypePEM},
+	{Name: FieldClientCert, Type: TypePEM},
+	{Name: FieldClientKey, Type: TypePEM, Secret: true},
+}}
+
+func testCertificate(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestValidate(t *testing.T) {
+	certPEM, keyPEM := testCertificate(t)
+	tests := []struct {
+		name  string
+		cfg   Config
+		field string
+	}{
+		{name: "valid", cfg: Config{Host: "db", Port: 5432, SSLMode: "require", CACert: certPEM, ClientCert: certPEM, ClientKey: keyPEM}},
+		{name: "missing required", cfg: Config{Port: 5432}, field: FieldHost},
+		{name: "unsupported field", cfg: Config{Host: "db", User: "app"}, field: FieldUser},
+		{name: "port range", cfg: Config{Host: "db", Port: 70000}, field: FieldPort},
+		{name: "unknown option", cfg: Config{Host: "db", SSLMode: "maybe"}, field: FieldSSLMode},
+		{name: "masked secret", cfg: Config{Host: "db", Password: MaskedValue}, field: FieldPassword},
+		{name: "bad ca", cfg: Config{Host: "db", CACert: "junk"}, field: FieldCACert},
+		{name: "cert without key", cfg: Config{Host: "db", ClientCert: certPEM}, field: FieldClientKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(testSpec, tt.cfg)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v", err)
+				}
+				return
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.field {
+				t.Fatalf("Validate = %v, want a %s field error", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestMaskedSecretsRoundTrip(t *testing.T) {
+	stored := Config{Host: "db", User: "app", Password: "secret", ClientCert: "cert", ClientKey: "key"}
+	masked := stored.Masked()
+	if masked.Password != MaskedValue || masked.ClientKey != MaskedValue || masked.ClientCert != "cert" || masked.User != "app" {
+		t.Fatalf("Masked = %+v", masked)
+	}
+	if (Config{Host: "db"}).Masked().Password != "" {
+		t.Fatal("an empty secret must stay empty when masked")
+	}
+
+	edited := masked
+	edited.Host = "db2"
+	merged := edited.WithSecretsFrom(stored)
+	if merged.Password != "secret" || merged.ClientKey != "key" || merged.Host != "db2" {
+		t.Fatalf("WithSecretsFrom = %+v", merged)
+	}
+	edited.Password = "rotated"
+	if edited.WithSecretsFrom(stored).Password != "rotated" {
+		t.Fatal("a submitted secret must replace the stored one")
+	}
+}
+
+func TestTLSApply(t *testing.T) {
+	certPEM, keyPEM := testCertificate(t)
+	target := &tls.Config{ServerName: "db"}
+	if err := (TLS{CACert: certPEM, ClientCert: certPEM, ClientKey: keyPEM}).Apply(target); err != nil {
+		t.Fatal(err)
+	}
+	if target.RootCAs == nil || len(target.Certificates) != 1 || target.ServerName != "db" {
+		t.Fatalf("Apply left %+v", target)
+	}
+	if err := (TLS{CACert: "junk"}).Apply(&tls.Config{}); err == nil {
+		t.Fatal("Apply must reject an unreadable CA certificate")
+	}
+}
@@ -46,3 +46,23 @@ type ResultLimitDriver interface {
 	QueryWithOptions(ctx context.Context, query string, opts ScanOptions, args ...any) (*result.ResultSet, error)
 	ExecuteWithOptions(ctx context.Context, query string, opts ScanOptions, args ...any) (*result.ResultSet, error)
 }
+
+// MapFetchErrors wraps c so every Fetch error passes through mapErr. Engines
+// use it to translate driver errors that only surface while rows stream, such
+// as a statement timeout hit after the first page.
+func MapFetchErrors(c QueryCursor, mapErr func(error) error) QueryCursor {
+	return &errorMappingCursor{QueryCursor: c, mapErr: mapErr}
+}
+
+type errorMappingCursor struct {
+	QueryCursor
+	mapErr func(error) error
+}
+
+func (c *errorMappingCursor) Fetch(ctx context.Context, opts ScanOptions) (*result.ResultSet, QueryCursorState, error) {
+	rs, state, err := c.QueryCursor.Fetch(ctx, opts)
+	if err != nil {
+		err = c.mapErr(err)
+	}
+	return rs, state, err
+}
@@ -2,7 +2,10 @@ package engine
 
 import (
 	"context"
+	"net"
+	"time"
 
+	"github.com/sqlwarden/internal/engine/connconfig"
 	"github.com/sqlwarden/internal/engine/metadata"
 	"github.com/sqlwarden/pkg/result"
 )
@@ -10,8 +13,9 @@ import (
 // Driver is the connection capability every engine must implement. An engine
 // type also implements whichever optional capability interfaces it supports
 // (classifier.Classifier, parser.Parser, rewriter.Rewriter, completer.Completer,
-// metadata.SchemaInspector, cursor.QueryCursorDriver, ddl.Executor, and
-// statement.Generator), resolved by type assertion.
+// metadata.SchemaInspector, cursor.QueryCursorDriver, ddl.Executor,
+// statement.Generator, connconfig.Builder, and SessionVariableSetter), resolved
+// by type assertion.
 type Driver interface {
 	Connect(ctx context.Context, cfg ConnectionConfig) error
 	Ping(ctx context.Context) error
@@ -32,13 +36,26 @@ const (
 	DialectSQLite   Dialect = "sqlite"
 )
 
+// DialFunc opens a network connection to a database server.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 // ConnectionConfig holds the configuration an engine needs to open a connection.
+// Dial, when set, replaces the engine's default network dialer, for example to
+// reach the server through an SSH tunnel. Engines without a network transport
+// ignore it. TLS carries certificate material from a structured connection
+// config; engines add it to the TLS settings the DSN's sslmode selects.
 type ConnectionConfig struct {
 	DSN            string
 	Driver         string
 	MaxResultRows  int
 	MaxResultBytes int64
 	DefaultScope   metadata.ScopePath
+	Dial           DialFunc
+	TLS            connconfig.TLS
+	// StatementTimeout caps how long a single statement may run. Engines
+	// enforce it natively where the database supports it. Zero means no
+	// limit.
+	StatementTimeout time.Duration
 }
 
 // NormalizeName returns the canonical engine name for a user-facing name or
@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sqlwarden/internal/engine/connconfig"
+
+	mysqlconfig "github.com/go-sql-driver/mysql"
+)
+
+func TestMySQLBuildDSNRoundTrips(t *testing.T) {
+	driver := &mysqlDriver{}
+	cfg := connconfig.Config{
+		Host:     "db.example.com",
+		Port:     3307,
+		Database: "app",
+		User:     "reporter",
+		Password: "p@ss:word",
+		SSLMode:  "require",
+		Params:   map[string]string{"charset": "utf8mb4", "time_zone": "'+00:00'"},
+	}
+	dsn, err := driver.BuildDSN(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mysqlconfig.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("driver rejected %q: %v", dsn, err)
+	}
+	if parsed.Addr != "db.example.com:3307" || parsed.Passwd != "p@ss:word" || parsed.TLSConfig != "skip-verify" || parsed.Params["time_zone"] != "'+00:00'" {
+		t.Fatalf("driver parsed %+v", parsed)
+	}
+
+	back, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, cfg) {
+		t.Fatalf("ParseDSN(BuildDSN(cfg)) = %+v, want %+v", back, cfg)
+	}
+}
+
+func TestMySQLBuildDSNValidatesTLS(t *testing.T) {
+	driver := &mysqlDriver{}
+	var fieldErr *connconfig.FieldError
+	if _, err := driver.BuildDSN(connconfig.Config{Host: "db", User: "u", SSLMode: "require", CACert: "x"}); !errors.As(err, &fieldErr) {
+		t.Fatalf("BuildDSN with a CA and no verification = %v, want a field error", err)
+	}
+	if _, err := driver.BuildDSN(connconfig.Config{Host: "db", User: "u", Params: map[string]string{"tls": "custom"}}); !errors.As(err, &fieldErr) || fieldErr.Field != connconfig.FieldParams {
+		t.Fatalf("BuildDSN with a tls parameter = %v, want a params field error", err)
+	}
+}
+
+func TestMySQLParseDSN(t *testing.T) {
+	driver := &mysqlDriver{}
+	cfg, err := driver.ParseDSN("app:secret@tcp(db.internal)/main?parseTime=true&tls=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := connconfig.Config{Host: "db.internal", Port: 3306, Database: "main", User: "app", Password: "secret", SSLMode: "verify-full", Params: map[string]string{"parseTime": "true"}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("ParseDSN = %+v, want %+v", cfg, want)
+	}
+
+	for _, dsn := range []string{
+		"app:secret@unix(/var/run/mysqld.sock)/main",
+		"app:secret@tcp(db)/main?tls=custom-profile",
+		"not a dsn",
+	} {
+		if _, err := driver.ParseDSN(dsn); !errors.Is(err, connconfig.ErrUnparseable) {
+			t.Errorf("ParseDSN(%q) = %v, want ErrUnparseable", dsn, err)
+		}
+	}
+}
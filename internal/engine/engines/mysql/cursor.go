@@ -14,11 +14,11 @@ func (d *mysqlDriver) StartQuery(ctx context.Context, req cursor.QueryRequest) (
 	// codeql[go/sql-injection]
 	rows, err := d.db.QueryContext(ctx, req.SQL, req.Args...)
 	if err != nil {
-		return nil, fmt.Errorf("mysql: start query: %w", err)
+		return nil, fmt.Errorf("mysql: start query: %w", statementError(err))
 	}
-	cursor, err := cursor.NewSQLRowsCursor(rows)
+	rowsCursor, err := cursor.NewSQLRowsCursor(rows)
 	if err != nil {
 		return nil, fmt.Errorf("mysql: start query cursor: %w", err)
 	}
-	return cursor, nil
+	return cursor.MapFetchErrors(rowsCursor, statementError), nil
 }
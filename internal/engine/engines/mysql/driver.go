@@ -3,8 +3,11 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sqlwarden/internal/engine"
 	"github.com/sqlwarden/internal/engine/cursor"
@@ -67,18 +70,84 @@ func ensureParams(dsn string) string {
 
 func (d *mysqlDriver) Connect(ctx context.Context, cfg engine.ConnectionConfig) error {
 	dsn := ensureParams(cfg.DSN)
-	if selectedDatabase := cfg.DefaultScope.Name("database"); selectedDatabase != "" {
+	selectedDatabase := cfg.DefaultScope.Name("database")
+	if selectedDatabase != "" || cfg.Dial != nil || !cfg.TLS.Empty() || cfg.StatementTimeout > 0 {
 		config, err := mysqlconfig.ParseDSN(dsn)
 		if err != nil {
 			return fmt.Errorf("mysql: parse config: %w", err)
 		}
-		config.DBName = selectedDatabase
-		dsn = config.FormatDSN()
+		if selectedDatabase != "" {
+			config.DBName = selectedDatabase
+		}
+		if cfg.Dial != nil {
+			config.DialFunc = cfg.Dial
+		}
+		if !cfg.TLS.Empty() {
+			if config.TLS == nil {
+				return fmt.Errorf("mysql: certificates require an sslmode that enables TLS")
+			}
+			if err := cfg.TLS.Apply(config.TLS); err != nil {
+				return fmt.Errorf("mysql: tls: %w", err)
+			}
+		}
+		if cfg.StatementTimeout > 0 {
+			return d.openWithStatementTimeout(ctx, config, cfg)
+		}
+		connector, err := mysqlconfig.NewConnector(config)
+		if err != nil {
+			return fmt.Errorf("mysql: open: %w", err)
+		}
+		return d.open(ctx, sql.OpenDB(connector), cfg)
 	}
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return fmt.Errorf("mysql: open: %w", err)
 	}
+	return d.open(ctx, db, cfg)
+}
+
+// openWithStatementTimeout opens config with a session variable that caps
+// statement run time. MySQL and MariaDB name it differently and reject the
+// other's name at connect time, so a first connection asks which server this
+// is.
+func (d *mysqlDriver) openWithStatementTimeout(ctx context.Context, config *mysqlconfig.Config, cfg engine.ConnectionConfig) error {
+	connector, err := mysqlconfig.NewConnector(config)
+	if err != nil {
+		return fmt.Errorf("mysql: open: %w", err)
+	}
+	probe := sql.OpenDB(connector)
+	var version string
+	err = probe.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version)
+	probe.Close()
+	if err != nil {
+		return fmt.Errorf("mysql: ping: %w", err)
+	}
+
+	config = config.Clone()
+	if config.Params == nil {
+		config.Params = map[string]string{}
+	}
+	name, value := statementTimeoutVariable(version, cfg.StatementTimeout)
+	config.Params[name] = value
+	connector, err = mysqlconfig.NewConnector(config)
+	if err != nil {
+		return fmt.Errorf("mysql: open: %w", err)
+	}
+	return d.open(ctx, sql.OpenDB(connector), cfg)
+}
+
+// statementTimeoutVariable returns the session variable, and its value, that
+// limits statement time on the server version reports. MySQL's
+// max_execution_time only applies to SELECT; MariaDB's max_statement_time
+// covers every statement.
+func statementTimeoutVariable(version string, timeout time.Duration) (name, value string) {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return "max_statement_time", strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)
+	}
+	return "max_execution_time", strconv.FormatInt(max(timeout.Milliseconds(), 1), 10)
+}
+
+func (d *mysqlDriver) open(ctx context.Context, db *sql.DB, cfg engine.ConnectionConfig) error {
 	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return fmt.Errorf("mysql: ping: %w", err)
@@ -106,9 +175,13 @@ func (d *mysqlDriver) QueryWithOptions(ctx context.Context, query string, opts c
 	// codeql[go/sql-injection]
 	rows, err := d.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("mysql: query: %w", err)
+		return nil, fmt.Errorf("mysql: query: %w", statementError(err))
+	}
+	rs, err := cursor.ScanRows(rows, opts)
+	if err != nil {
+		return nil, statementError(err)
 	}
-	return cursor.ScanRows(rows, opts)
+	return rs, nil
 }
 
 func (d *mysqlDriver) Execute(ctx context.Context, query string, args ...any) (*result.ResultSet, error) {
@@ -120,7 +193,7 @@ func (d *mysqlDriver) ExecuteWithOptions(ctx context.Context, query string, _ cu
 	// codeql[go/sql-injection]
 	execResult, err := d.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("mysql: execute: %w", err)
+		return nil, fmt.Errorf("mysql: execute: %w", statementError(err))
 	}
 	rowsAffected, err := execResult.RowsAffected()
 	if err != nil {
@@ -129,6 +202,23 @@ func (d *mysqlDriver) ExecuteWithOptions(ctx context.Context, query string, _ cu
 	return result.NewExecutionResult(rowsAffected), nil
 }
 
+// Server errors for a statement stopped by max_execution_time (MySQL) or
+// max_statement_time (MariaDB).
+const (
+	errMySQLExecutionTimeExceeded   = 3024
+	errMariaDBStatementTimeExceeded = 1969
+)
+
+// statementError marks err as a statement timeout when the server stopped
+// the statement for exceeding its session time limit.
+func statementError(err error) error {
+	var myErr *mysqlconfig.MySQLError
+	if errors.As(err, &myErr) && (myErr.Number == errMySQLExecutionTimeExceeded || myErr.Number == errMariaDBStatementTimeExceeded) {
+		return fmt.Errorf("%w: %w", engine.ErrStatementTimeout, err)
+	}
+	return err
+}
+
 func (d *mysqlDriver) Dialect() engine.Dialect {
 	return engine.DialectMySQL
 }
@@ -485,6 +485,23 @@ func TestInspectDirectoryAndObjects(t *testing.T) {
 	}
 }
 
+func TestStatementTimeoutVariable(t *testing.T) {
+	tests := []struct {
+		version   string
+		wantName  string
+		wantValue string
+	}{
+		{"8.0.36", "max_execution_time", "1500"},
+		{"10.11.6-MariaDB-0+deb12u1", "max_statement_time", "1.5"},
+	}
+	for _, tt := range tests {
+		name, value := statementTimeoutVariable(tt.version, 1500*time.Millisecond)
+		if name != tt.wantName || value != tt.wantValue {
+			t.Errorf("statementTimeoutVariable(%q) = %s=%s, want %s=%s", tt.version, name, value, tt.wantName, tt.wantValue)
+		}
+	}
+}
+
 func TestToValue(t *testing.T) {
 	now := time.Now().UTC().Truncate(time.Second)
 
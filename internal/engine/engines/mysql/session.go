@@ -0,0 +1,26 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+var _ engine.SessionVariableSetter = (*mysqlDriver)(nil)
+
+// SessionVariableSQL sets a session system variable. Numbers stay unquoted
+// because numeric variables reject string values. Backslashes are refused
+// rather than escaped, since their meaning depends on NO_BACKSLASH_ESCAPES.
+func (d *mysqlDriver) SessionVariableSQL(name, value string) (string, error) {
+	if err := engine.CheckSessionVariable(name, value); err != nil {
+		return "", err
+	}
+	if strings.Contains(value, `\`) {
+		return "", fmt.Errorf("%w: value for %s must not contain a backslash", engine.ErrInvalidSessionVariable, name)
+	}
+	if engine.IsNumericLiteral(value) {
+		return "SET SESSION " + name + " = " + value, nil
+	}
+	return "SET SESSION " + name + " = '" + strings.ReplaceAll(value, "'", "''") + "'", nil
+}
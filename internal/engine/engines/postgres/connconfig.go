@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sqlwarden/internal/engine/connconfig"
+)
+
+var _ connconfig.Builder = (*postgresDriver)(nil)
+
+const postgresDefaultPort = 5432
+
+var postgresConnectionSpec = connconfig.Spec{Fields: []connconfig.Field{
+	{Name: connconfig.FieldHost, Label: "Host", Type: connconfig.TypeText, Required: true},
+	{Name: connconfig.FieldPort, Label: "Port", Type: connconfig.TypeNumber, Default: strconv.Itoa(postgresDefaultPort)},
+	{Name: connconfig.FieldDatabase, Label: "Database", Type: connconfig.TypeText},
+	{Name: connconfig.FieldUser, Label: "User", Type: connconfig.TypeText, Required: true},
+	{Name: connconfig.FieldPassword, Label: "Password", Type: connconfig.TypeSecret, Secret: true},
+	{Name: connconfig.FieldSSLMode, Label: "SSL mode", Type: connconfig.TypeSelect, Default: "prefer",
+		Options: []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}},
+	{Name: connconfig.FieldCACert, Label: "CA certificate", Type: connconfig.TypePEM},
+	{Name: connconfig.FieldClientCert, Label: "Client certificate", Type: connconfig.TypePEM},
+	{Name: connconfig.FieldClientKey, Label: "Client key", Type: connconfig.TypePEM, Secret: true},
+	{Name: connconfig.FieldParams, Label: "Parameters", Type: connconfig.TypeParams},
+}}
+
+// postgresStructuredKeys are the connection settings that have their own
+// field. postgresFileKeys name local files; a DSN using them stays raw because
+// the structured form carries certificates inline and never reads the server's
+// filesystem.
+var (
+	postgresStructuredKeys = map[string]bool{"host": true, "port": true, "dbname": true, "user": true, "password": true, "sslmode": true}
+	postgresFileKeys       = map[string]bool{"sslrootcert": true, "sslcert": true, "sslkey": true, "sslpassword": true, "passfile": true, "service": true, "servicefile": true}
+)
+
+func (*postgresDriver) ConnectionSpec() connconfig.Spec { return postgresConnectionSpec }
+
+// BuildDSN returns a postgres:// URL. A CA certificate upgrades sslmode=require
+// to verify-ca, as libpq does when sslrootcert is set.
+func (*postgresDriver) BuildDSN(cfg connconfig.Config) (string, error) {
+	if err := connconfig.Validate(postgresConnectionSpec, cfg); err != nil {
+		return "", err
+	}
+	sslMode := cfg.SSLMode
+	switch {
+	case cfg.CACert != "" && sslMode == "require":
+		sslMode = "verify-ca"
+	case cfg.CACert != "" && sslMode != "verify-ca" && sslMode != "verify-full":
+		return "", &connconfig.FieldError{Field: connconfig.FieldSSLMode, Message: "must be require, verify-ca, or verify-full to use a CA certificate"}
+	case cfg.ClientCert != "" && (sslMode == "disable" || sslMode == ""):
+		return "", &connconfig.FieldError{Field: connconfig.FieldSSLMode, Message: "must enable SSL to use a client certificate"}
+	}
+	for key := range cfg.Params {
+		if postgresStructuredKeys[key] || postgresFileKeys[key] {
+			return "", &connconfig.FieldError{Field: connconfig.FieldParams, Message: fmt.Sprintf("%q cannot be set as a parameter", key)}
+		}
+	}
+
+	u := url.URL{Scheme: "postgres", Host: cfg.Host, Path: "/" + cfg.Database}
+	if cfg.Port != 0 {
+		u.Host = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	} else if strings.Contains(cfg.Host, ":") {
+		u.Host = "[" + cfg.Host + "]"
+	}
+	if cfg.Password != "" {
+		u.User = url.UserPassword(cfg.User, cfg.Password)
+	} else {
+		u.User = url.User(cfg.User)
+	}
+	query := url.Values{}
+	for key, value := range cfg.Params {
+		query.Set(key, value)
+	}
+	if sslMode != "" {
+		query.Set("sslmode", sslMode)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// ParseDSN accepts URL and keyword/value DSNs. Several hosts, Unix sockets,
+// and file-based settings have no structured form.
+func (*postgresDriver) ParseDSN(dsn string) (connconfig.Config, error) {
+	var settings map[string]string
+	var err error
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		settings, err = parsePostgresURLSettings(dsn)
+	} else {
+		settings, err = parsePostgresKeywordSettings(dsn)
+	}
+	if err != nil {
+		return connconfig.Config{}, connconfig.ErrUnparseable
+	}
+
+	cfg := connconfig.Config{
+		Host:     settings["host"],
+		Database: settings["dbname"],
+		User:     settings["user"],
+		Password: settings["password"],
+		SSLMode:  settings["sslmode"],
+	}
+	if strings.Contains(cfg.Host, ",") || strings.HasPrefix(cfg.Host, "/") {
+		return connconfig.Config{}, connconfig.ErrUnparseable
+	}
+	if port := settings["port"]; port != "" {
+		if cfg.Port, err = strconv.Atoi(port); err != nil {
+			return connconfig.Config{}, connconfig.ErrUnparseable
+		}
+	}
+	for key, value := range settings {
+		if postgresFileKeys[key] {
+			return connconfig.Config{}, connconfig.ErrUnparseable
+		}
+		if postgresStructuredKeys[key] {
+			continue
+		}
+		if cfg.Params == nil {
+			cfg.Params = map[string]string{}
+		}
+		cfg.Params[key] = value
+	}
+	if err := connconfig.Validate(postgresConnectionSpec, cfg); err != nil {
+		return connconfig.Config{}, connconfig.ErrUnparseable
+	}
+	return cfg, nil
+}
+
+func parsePostgresURLSettings(dsn string) (map[string]string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]string{}
+	if u.User != nil {
+		settings["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			settings["password"] = password
+		}
+	}
+	if strings.Contains(u.Host, ",") {
+		return nil, fmt.Errorf("multiple hosts")
+	}
+	if u.Host != "" {
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host, port = strings.Trim(u.Host, "[]"), ""
+		}
+		settings["host"] = host
+		if port != "" {
+			settings["port"] = port
+		}
+	}
+	if database := strings.TrimPrefix(u.Path, "/"); database != "" {
+		settings["dbname"] = database
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range query {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("repeated parameter %q", key)
+		}
+		settings[key] = values[0]
+	}
+	return settings, nil
+}
+
+// parsePostgresKeywordSettings parses the libpq keyword/value form:
+// space-separated key=value pairs where values may be single-quoted with
+// backslash escapes.
+func parsePostgresKeywordSettings(dsn string) (map[string]string, error) {
+	settings := map[string]string{}
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing = after %q", rest)
+		}
+		key = strings.TrimSpace(key)
+		after = strings.TrimLeft(after, " \t\n\r")
+		var value strings.Builder
+		if strings.HasPrefix(after, "'") {
+			i := 1
+			for ; i < len(after) && after[i] != '\''; i++ {
+				if after[i] == '\\' {
+					i++
+					if i == len(after) {
+						return nil, fmt.Errorf("unterminated value for %q", key)
+					}
+				}
+				value.WriteByte(after[i])
+			}
+			if i == len(after) {
+				return nil, fmt.Errorf("unterminated value for %q", key)
+			}
+			rest = after[i+1:]
+		} else {
+			i := 0
+			for ; i < len(after) && !strings.ContainsRune(" \t\n\r", rune(after[i])); i++ {
+				if after[i] == '\\' && i+1 < len(after) {
+					i++
+				}
+				value.WriteByte(after[i])
+			}
+			rest = after[i:]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+		settings[key] = value.String()
+		rest = strings.TrimSpace(rest)
+	}
+	return settings, nil
+}
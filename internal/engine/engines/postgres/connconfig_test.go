@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sqlwarden/internal/engine/connconfig"
+)
+
+func TestPostgresBuildDSNRoundTrips(t *testing.T) {
+	driver := &postgresDriver{}
+	cfg := connconfig.Config{
+		Host:     "db.example.com",
+		Port:     6432,
+		Database: "app",
+		User:     "reporter",
+		Password: "p@ss word/?",
+		SSLMode:  "verify-full",
+		Params:   map[string]string{"application_name": "sqlwarden"},
+	}
+	dsn, err := driver.BuildDSN(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("pgx rejected %q: %v", dsn, err)
+	}
+	if parsed.Host != "db.example.com" || parsed.Port != 6432 || parsed.Database != "app" || parsed.User != "reporter" || parsed.Password != "p@ss word/?" {
+		t.Fatalf("pgx parsed %+v", parsed.Config)
+	}
+	if parsed.RuntimeParams["application_name"] != "sqlwarden" {
+		t.Fatalf("runtime params = %v", parsed.RuntimeParams)
+	}
+
+	back, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, cfg) {
+		t.Fatalf("ParseDSN(BuildDSN(cfg)) = %+v, want %+v", back, cfg)
+	}
+}
+
+func TestPostgresBuildDSNValidatesTLS(t *testing.T) {
+	driver := &postgresDriver{}
+	base := connconfig.Config{Host: "db", User: "u", CACert: "not a certificate", SSLMode: "verify-full"}
+	var fieldErr *connconfig.FieldError
+	if _, err := driver.BuildDSN(base); !errors.As(err, &fieldErr) || fieldErr.Field != connconfig.FieldCACert {
+		t.Fatalf("BuildDSN with a bad CA = %v, want a ca_cert field error", err)
+	}
+	if _, err := driver.BuildDSN(connconfig.Config{Host: "db", User: "u", SSLMode: "sometimes"}); !errors.As(err, &fieldErr) || fieldErr.Field != connconfig.FieldSSLMode {
+		t.Fatalf("BuildDSN with an unknown sslmode = %v, want an sslmode field error", err)
+	}
+	if _, err := driver.BuildDSN(connconfig.Config{Host: "db", User: "u", Params: map[string]string{"sslrootcert": "/etc/ca.pem"}}); !errors.As(err, &fieldErr) || fieldErr.Field != connconfig.FieldParams {
+		t.Fatalf("BuildDSN with a file parameter = %v, want a params field error", err)
+	}
+}
+
+func TestPostgresParseDSN(t *testing.T) {
+	driver := &postgresDriver{}
+	cfg, err := driver.ParseDSN(`host=db.internal port=5432 user=app password='it\'s quoted' dbname=main sslmode=require connect_timeout=5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := connconfig.Config{Host: "db.internal", Port: 5432, User: "app", Password: "it's quoted", Database: "main", SSLMode: "require", Params: map[string]string{"connect_timeout": "5"}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("ParseDSN = %+v, want %+v", cfg, want)
+	}
+
+	for _, dsn := range []string{
+		"postgres://app@a.internal:5432,b.internal:5432/main",
+		"host=/var/run/postgresql user=app",
+		"host=db user=app sslrootcert=/etc/ssl/ca.pem",
+		"postgres://app@db/main?service=prod",
+		"user=app",
+	} {
+		if _, err := driver.ParseDSN(dsn); !errors.Is(err, connconfig.ErrUnparseable) {
+			t.Errorf("ParseDSN(%q) = %v, want ErrUnparseable", dsn, err)
+		}
+	}
+}
@@ -14,11 +14,11 @@ func (d *postgresDriver) StartQuery(ctx context.Context, req cursor.QueryRequest
 	// codeql[go/sql-injection]
 	rows, err := d.db.QueryContext(ctx, req.SQL, req.Args...)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: start query: %w", err)
+		return nil, fmt.Errorf("postgres: start query: %w", statementError(err))
 	}
-	cursor, err := cursor.NewSQLRowsCursor(rows)
+	rowsCursor, err := cursor.NewSQLRowsCursor(rows)
 	if err != nil {
 		return nil, fmt.Errorf("postgres: start query cursor: %w", err)
 	}
-	return cursor, nil
+	return cursor.MapFetchErrors(rowsCursor, statementError), nil
 }
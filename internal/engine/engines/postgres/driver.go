@@ -3,10 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/sqlwarden/internal/engine"
 	"github.com/sqlwarden/internal/engine/cursor"
@@ -30,6 +33,34 @@ func (d *postgresDriver) Connect(ctx context.Context, cfg engine.ConnectionConfi
 		// Quote it as one identifier so punctuation cannot alter the path.
 		config.RuntimeParams["search_path"] = `"` + strings.ReplaceAll(selectedSchema, `"`, `""`) + `"`
 	}
+	if cfg.StatementTimeout > 0 {
+		// A startup parameter rather than SET, so every connection the pool
+		// opens carries the limit.
+		config.RuntimeParams["statement_timeout"] = strconv.FormatInt(max(cfg.StatementTimeout.Milliseconds(), 1), 10)
+	}
+	if cfg.Dial != nil {
+		config.DialFunc = pgconn.DialFunc(cfg.Dial)
+		// The host is resolved at the far end of the dialer; a local lookup
+		// would fail for names only the jump host can resolve.
+		config.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+			return []string{host}, nil
+		}
+	}
+	if !cfg.TLS.Empty() {
+		if config.TLSConfig == nil {
+			return fmt.Errorf("postgres: certificates require an sslmode that enables TLS")
+		}
+		// Fallbacks carry their own TLS settings, one per host and sslmode
+		// attempt; the certificates apply to each attempt that uses TLS.
+		if err := cfg.TLS.Apply(config.TLSConfig); err != nil {
+			return fmt.Errorf("postgres: tls: %w", err)
+		}
+		for _, fallback := range config.Fallbacks {
+			if err := cfg.TLS.Apply(fallback.TLSConfig); err != nil {
+				return fmt.Errorf("postgres: tls: %w", err)
+			}
+		}
+	}
 	db := stdlib.OpenDB(*config)
 	if err := db.PingContext(ctx); err != nil {
 		db.Close()
@@ -58,9 +89,13 @@ func (d *postgresDriver) QueryWithOptions(ctx context.Context, query string, opt
 	// codeql[go/sql-injection]
 	rows, err := d.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: query: %w", err)
+		return nil, fmt.Errorf("postgres: query: %w", statementError(err))
+	}
+	rs, err := cursor.ScanRows(rows, opts)
+	if err != nil {
+		return nil, statementError(err)
 	}
-	return cursor.ScanRows(rows, opts)
+	return rs, nil
 }
 
 func (d *postgresDriver) Execute(ctx context.Context, query string, args ...any) (*result.ResultSet, error) {
@@ -72,7 +107,7 @@ func (d *postgresDriver) ExecuteWithOptions(ctx context.Context, query string, _
 	// codeql[go/sql-injection]
 	execResult, err := d.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: execute: %w", err)
+		return nil, fmt.Errorf("postgres: execute: %w", statementError(err))
 	}
 	rowsAffected, err := execResult.RowsAffected()
 	if err != nil {
@@ -81,6 +116,23 @@ func (d *postgresDriver) ExecuteWithOptions(ctx context.Context, query string, _
 	return result.NewExecutionResult(rowsAffected), nil
 }
 
+// sqlStateQueryCanceled is the SQLSTATE of a statement PostgreSQL cancelled,
+// which is how statement_timeout stops one.
+const sqlStateQueryCanceled = "57014"
+
+// statementError marks err as a statement timeout when PostgreSQL cancelled
+// the statement. The SQLSTATE is matched rather than the message, which
+// follows the server's lc_messages. pgx interrupts the connection when a
+// context is cancelled rather than sending a cancel request, so the SQLSTATE
+// only comes from statement_timeout or an administrator's pg_cancel_backend.
+func statementError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == sqlStateQueryCanceled {
+		return fmt.Errorf("%w: %w", engine.ErrStatementTimeout, err)
+	}
+	return err
+}
+
 func (d *postgresDriver) Dialect() engine.Dialect {
 	return engine.DialectPostgres
 }
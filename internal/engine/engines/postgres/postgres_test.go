@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
@@ -9,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sqlwarden/internal/engine"
 	"github.com/sqlwarden/internal/engine/cursor"
 	"github.com/sqlwarden/internal/engine/metadata"
@@ -435,6 +437,18 @@ func TestDialect(t *testing.T) {
 	}
 }
 
+func TestStatementErrorMatchesSQLState(t *testing.T) {
+	// The message is localized by lc_messages, so only the SQLSTATE counts.
+	canceled := &pgconn.PgError{Code: "57014", Message: "Abbruch der Anweisung wegen Zeitüberschreitung"}
+	if err := statementError(fmt.Errorf("wrapped: %w", canceled)); !errors.Is(err, engine.ErrStatementTimeout) {
+		t.Errorf("statementError(57014) = %v, want ErrStatementTimeout", err)
+	}
+	other := &pgconn.PgError{Code: "42P01", Message: "canceling statement due to statement timeout"}
+	if err := statementError(other); errors.Is(err, engine.ErrStatementTimeout) {
+		t.Errorf("statementError(42P01) = %v, want it unchanged", err)
+	}
+}
+
 func mustExec(t *testing.T, d engine.Driver, sql string) {
 	t.Helper()
 	if _, err := d.Execute(context.Background(), sql); err != nil {
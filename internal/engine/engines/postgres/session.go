@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+var _ engine.SessionVariableSetter = (*postgresDriver)(nil)
+
+// SessionVariableSQL sets the variable through set_config, which parses value
+// the way SET does for list settings such as search_path, but takes both as
+// string literals.
+func (d *postgresDriver) SessionVariableSQL(name, value string) (string, error) {
+	if err := engine.CheckSessionVariable(name, value); err != nil {
+		return "", err
+	}
+	return "SELECT set_config(" + postgresStringLiteral(name) + ", " + postgresStringLiteral(value) + ", false)", nil
+}
+
+func postgresStringLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/sqlwarden/internal/engine/connconfig"
+)
+
+var _ connconfig.Builder = (*sqliteDriver)(nil)
+
+// SQLite has no server, so the structured form is the database file plus the
+// driver's query parameters (_pragma, _txlock, mode, ...).
+var sqliteConnectionSpec = connconfig.Spec{Fields: []connconfig.Field{
+	{Name: connconfig.FieldDatabase, Label: "Database file", Type: connconfig.TypeText, Required: true},
+	{Name: connconfig.FieldParams, Label: "Parameters", Type: connconfig.TypeParams},
+}}
+
+func (*sqliteDriver) ConnectionSpec() connconfig.Spec { return sqliteConnectionSpec }
+
+func (*sqliteDriver) BuildDSN(cfg connconfig.Config) (string, error) {
+	if err := connconfig.Validate(sqliteConnectionSpec, cfg); err != nil {
+		return "", err
+	}
+	if strings.Contains(cfg.Database, "?") {
+		return "", &connconfig.FieldError{Field: connconfig.FieldDatabase, Message: "must not contain \"?\"; use parameters instead"}
+	}
+	if len(cfg.Params) == 0 {
+		return cfg.Database, nil
+	}
+	query := url.Values{}
+	for key, value := range cfg.Params {
+		query.Set(key, value)
+	}
+	return cfg.Database + "?" + query.Encode(), nil
+}
+
+func (*sqliteDriver) ParseDSN(dsn string) (connconfig.Config, error) {
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	if path == "" {
+		return connconfig.Config{}, connconfig.ErrUnparseable
+	}
+	cfg := connconfig.Config{Database: path}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return connconfig.Config{}, connconfig.ErrUnparseable
+	}
+	for key, values := range query {
+		// Repeated keys such as several _pragma entries have no map form.
+		if len(values) != 1 {
+			return connconfig.Config{}, connconfig.ErrUnparseable
+		}
+		if cfg.Params == nil {
+			cfg.Params = map[string]string{}
+		}
+		cfg.Params[key] = values[0]
+	}
+	return cfg, nil
+}
@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sqlwarden/internal/engine/connconfig"
+)
+
+func TestSQLiteConnectionConfigRoundTrips(t *testing.T) {
+	driver := &sqliteDriver{}
+	cfg := connconfig.Config{Database: "/var/lib/app.db", Params: map[string]string{"_pragma": "busy_timeout(5000)", "mode": "ro"}}
+	dsn, err := driver.BuildDSN(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, cfg) {
+		t.Fatalf("ParseDSN(%q) = %+v, want %+v", dsn, back, cfg)
+	}
+	if dsn, _ := driver.BuildDSN(connconfig.Config{Database: ":memory:"}); dsn != ":memory:" {
+		t.Fatalf("BuildDSN without parameters = %q", dsn)
+	}
+
+	var fieldErr *connconfig.FieldError
+	if _, err := driver.BuildDSN(connconfig.Config{Database: "app.db", Host: "db"}); !errors.As(err, &fieldErr) || fieldErr.Field != connconfig.FieldHost {
+		t.Fatalf("BuildDSN with a host = %v, want a host field error", err)
+	}
+	if _, err := driver.ParseDSN("app.db?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)"); !errors.Is(err, connconfig.ErrUnparseable) {
+		t.Fatalf("ParseDSN with repeated pragmas = %v, want ErrUnparseable", err)
+	}
+}
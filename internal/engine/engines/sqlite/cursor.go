@@ -2,14 +2,36 @@ package sqlite
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/sqlwarden/internal/engine"
 	"github.com/sqlwarden/internal/engine/cursor"
+	"github.com/sqlwarden/pkg/result"
 )
 
 var _ cursor.QueryCursorDriver = (*sqliteDriver)(nil)
 
 func (d *sqliteDriver) StartQuery(ctx context.Context, req cursor.QueryRequest) (cursor.QueryCursor, error) {
+	if d.statementTimeout <= 0 {
+		return d.startQuery(ctx, req)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	timed := &timedCursor{ctx: ctx, cancel: cancel, timeout: d.statementTimeout}
+	err := timed.run(func() error {
+		var err error
+		timed.QueryCursor, err = d.startQuery(ctx, req)
+		return err
+	})
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	return timed, nil
+}
+
+func (d *sqliteDriver) startQuery(ctx context.Context, req cursor.QueryRequest) (cursor.QueryCursor, error) {
 	// SQL is intentionally user-authored editor input and is permission-gated by the web layer.
 	// codeql[go/sql-injection]
 	rows, err := d.db.QueryContext(ctx, req.SQL, req.Args...)
@@ -22,3 +44,41 @@ func (d *sqliteDriver) StartQuery(ctx context.Context, req cursor.QueryRequest)
 	}
 	return cursor, nil
 }
+
+// timedCursor holds each step of a cursor to the statement timeout. Rows read
+// through the context the query started with, so an expired step cancels that
+// context, which ends the cursor, while the time a caller spends between
+// fetches is not counted.
+type timedCursor struct {
+	cursor.QueryCursor
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+}
+
+func (c *timedCursor) run(step func() error) error {
+	timer := time.AfterFunc(c.timeout, func() { c.cancel(engine.ErrStatementTimeout) })
+	err := step()
+	timer.Stop()
+	if err != nil && errors.Is(context.Cause(c.ctx), engine.ErrStatementTimeout) {
+		return fmt.Errorf("%w: %w", engine.ErrStatementTimeout, err)
+	}
+	return err
+}
+
+func (c *timedCursor) Fetch(ctx context.Context, opts cursor.ScanOptions) (*result.ResultSet, cursor.QueryCursorState, error) {
+	var rs *result.ResultSet
+	var state cursor.QueryCursorState
+	err := c.run(func() error {
+		var err error
+		rs, state, err = c.QueryCursor.Fetch(ctx, opts)
+		return err
+	})
+	return rs, state, err
+}
+
+func (c *timedCursor) Close() error {
+	err := c.QueryCursor.Close()
+	c.cancel(nil)
+	return err
+}
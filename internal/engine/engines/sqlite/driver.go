@@ -3,7 +3,9 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/sqlwarden/internal/engine"
 	"github.com/sqlwarden/internal/engine/cursor"
@@ -17,6 +19,9 @@ type sqliteDriver struct {
 	db           *sql.DB
 	scanOptions  cursor.ScanOptions
 	defaultScope metadata.ScopePath
+	// statementTimeout bounds each statement through its context; the sqlite
+	// driver interrupts a running statement when that context ends.
+	statementTimeout time.Duration
 }
 
 func (d *sqliteDriver) Connect(ctx context.Context, cfg engine.ConnectionConfig) error {
@@ -36,6 +41,7 @@ func (d *sqliteDriver) Connect(ctx context.Context, cfg engine.ConnectionConfig)
 	d.db = db
 	d.scanOptions = cursor.ScanOptions{MaxRows: cfg.MaxResultRows, MaxBytes: cfg.MaxResultBytes}
 	d.defaultScope = cfg.DefaultScope
+	d.statementTimeout = cfg.StatementTimeout
 	return nil
 }
 
@@ -52,13 +58,19 @@ func (d *sqliteDriver) Query(ctx context.Context, query string, args ...any) (*r
 }
 
 func (d *sqliteDriver) QueryWithOptions(ctx context.Context, query string, opts cursor.ScanOptions, args ...any) (*result.ResultSet, error) {
+	ctx, cancel := d.statementContext(ctx)
+	defer cancel()
 	// SQL is intentionally user-authored editor input and is permission-gated by the web layer.
 	// codeql[go/sql-injection]
 	rows, err := d.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("sqlite: query: %w", err)
+		return nil, fmt.Errorf("sqlite: query: %w", statementError(ctx, err))
 	}
-	return cursor.ScanRows(rows, opts)
+	rs, err := cursor.ScanRows(rows, opts)
+	if err != nil {
+		return nil, statementError(ctx, err)
+	}
+	return rs, nil
 }
 
 func (d *sqliteDriver) Execute(ctx context.Context, query string, args ...any) (*result.ResultSet, error) {
@@ -66,11 +78,13 @@ func (d *sqliteDriver) Execute(ctx context.Context, query string, args ...any) (
 }
 
 func (d *sqliteDriver) ExecuteWithOptions(ctx context.Context, query string, _ cursor.ScanOptions, args ...any) (*result.ResultSet, error) {
+	ctx, cancel := d.statementContext(ctx)
+	defer cancel()
 	// SQL is intentionally user-authored editor input and is permission-gated by the web layer.
 	// codeql[go/sql-injection]
 	execResult, err := d.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("sqlite: execute: %w", err)
+		return nil, fmt.Errorf("sqlite: execute: %w", statementError(ctx, err))
 	}
 	rowsAffected, err := execResult.RowsAffected()
 	if err != nil {
@@ -79,6 +93,23 @@ func (d *sqliteDriver) ExecuteWithOptions(ctx context.Context, query string, _ c
 	return result.NewExecutionResult(rowsAffected), nil
 }
 
+// statementContext bounds ctx by the statement timeout, if there is one.
+func (d *sqliteDriver) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, d.statementTimeout, engine.ErrStatementTimeout)
+}
+
+// statementError marks err as a statement timeout when ctx, from
+// statementContext, ended because the timeout elapsed.
+func statementError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), engine.ErrStatementTimeout) {
+		return fmt.Errorf("%w: %w", engine.ErrStatementTimeout, err)
+	}
+	return err
+}
+
 func (d *sqliteDriver) Dialect() engine.Dialect {
 	return engine.DialectSQLite
 }
@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/sqlwarden/internal/engine"
+)
+
+var _ engine.SessionVariableSetter = (*sqliteDriver)(nil)
+
+// SessionVariableSQL sets a pragma, which is what SQLite has in place of
+// session variables. A qualified name selects the attached schema.
+func (d *sqliteDriver) SessionVariableSQL(name, value string) (string, error) {
+	if err := engine.CheckSessionVariable(name, value); err != nil {
+		return "", err
+	}
+	if engine.IsNumericLiteral(value) {
+		return "PRAGMA " + name + " = " + value, nil
+	}
+	return "PRAGMA " + name + " = '" + strings.ReplaceAll(value, "'", "''") + "'", nil
+}
@@ -231,6 +231,78 @@ func TestSQLiteQueryCursorCancelledFetchCanBeRetried(t *testing.T) {
 	}
 }
 
+func TestSQLiteStatementTimeout(t *testing.T) {
+	d := &sqliteDriver{}
+	ctx := context.Background()
+
+	if err := d.Connect(ctx, engine.ConnectionConfig{DSN: ":memory:", Driver: "sqlite", StatementTimeout: 100 * time.Millisecond}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer d.Close()
+
+	const endless = `WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n) SELECT count(*) FROM n`
+	if _, err := d.Query(ctx, endless); !errors.Is(err, engine.ErrStatementTimeout) {
+		t.Fatalf("query err = %v, want ErrStatementTimeout", err)
+	}
+	if _, err := d.StartQuery(ctx, cursor.QueryRequest{SQL: endless}); !errors.Is(err, engine.ErrStatementTimeout) {
+		t.Fatalf("start query err = %v, want ErrStatementTimeout", err)
+	}
+
+	// Time spent between fetches does not count against the limit.
+	cur, err := d.StartQuery(ctx, cursor.QueryRequest{SQL: `SELECT 1 UNION ALL SELECT 2`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	time.Sleep(150 * time.Millisecond)
+	if _, _, err := cur.Fetch(ctx, cursor.ScanOptions{MaxRows: 10}); err != nil {
+		t.Fatalf("fetch after idle: %v", err)
+	}
+
+	rs, err := d.Query(ctx, `SELECT 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.RowsReturned != 1 {
+		t.Fatalf("rows = %d, want 1", rs.RowsReturned)
+	}
+}
+
+func TestSQLiteSessionVariableSQL(t *testing.T) {
+	d := &sqliteDriver{}
+	ctx := context.Background()
+
+	if err := d.Connect(ctx, engine.ConnectionConfig{DSN: ":memory:", Driver: "sqlite"}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer d.Close()
+
+	statement, err := d.SessionVariableSQL("cache_size", "-4000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement != "PRAGMA cache_size = -4000" {
+		t.Fatalf("statement = %q", statement)
+	}
+	if _, err := d.Execute(ctx, statement); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := d.Query(ctx, "PRAGMA cache_size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Rows) != 1 || rs.Rows[0][0].Integer != -4000 {
+		t.Fatalf("cache_size = %v, want -4000", rs.Rows)
+	}
+
+	if statement, _ := d.SessionVariableSQL("journal_mode", "it's"); statement != "PRAGMA journal_mode = 'it''s'" {
+		t.Fatalf("quoted statement = %q", statement)
+	}
+	if _, err := d.SessionVariableSQL("cache_size; DROP TABLE t", "1"); !errors.Is(err, engine.ErrInvalidSessionVariable) {
+		t.Fatalf("err = %v, want ErrInvalidSessionVariable", err)
+	}
+}
+
 func TestToValue(t *testing.T) {
 	now := time.Now().UTC().Truncate(time.Second)
 
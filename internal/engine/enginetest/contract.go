@@ -12,16 +12,17 @@ import (
 
 // knownCapabilities is the closed set of capability keys an engine may report.
 var knownCapabilities = map[engine.Capability]bool{
-	engine.CapabilitySchemaDirectory: true,
-	engine.CapabilitySchemaObjects:   true,
-	engine.CapabilityDDL:             true,
-	engine.CapabilityQueryCursor:     true,
-	engine.CapabilitySQLParse:        true,
-	engine.CapabilitySQLClassify:     true,
-	engine.CapabilitySQLSafetyCheck:  true,
-	engine.CapabilitySQLRewrite:      true,
-	engine.CapabilitySQLComplete:     true,
-	engine.CapabilitySQLGenerate:     true,
+	engine.CapabilitySchemaDirectory:  true,
+	engine.CapabilitySchemaObjects:    true,
+	engine.CapabilityDDL:              true,
+	engine.CapabilityQueryCursor:      true,
+	engine.CapabilitySQLParse:         true,
+	engine.CapabilitySQLClassify:      true,
+	engine.CapabilitySQLSafetyCheck:   true,
+	engine.CapabilitySQLRewrite:       true,
+	engine.CapabilitySQLComplete:      true,
+	engine.CapabilitySQLGenerate:      true,
+	engine.CapabilityConnectionConfig: true,
 }
 
 // RunCapabilityContract asserts the static-capability invariants every engine
@@ -55,6 +56,9 @@ func RunCapabilityContract(t *testing.T, name string) {
 	if set.Capabilities[engine.CapabilitySQLGenerate] != (set.Statements != nil) {
 		t.Fatal("sql.generate capability and statement spec disagree")
 	}
+	if set.Capabilities[engine.CapabilityConnectionConfig] != (set.Connection != nil) {
+		t.Fatal("connection.config capability and connection spec disagree")
+	}
 }
 
 // RunConnectionContract opens a real connection — New + Connect — and verifies a
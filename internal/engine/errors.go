@@ -8,3 +8,8 @@ var ErrUnknownEngine = errors.New("engine: unknown engine")
 // ErrUnsupported is returned when an engine or connection does not implement a
 // requested optional capability. Web handlers map it to HTTP 501.
 var ErrUnsupported = errors.New("engine: capability not supported")
+
+// ErrStatementTimeout marks a statement stopped by the connection's
+// StatementTimeout. Engines wrap the driver's own error with it so callers can
+// tell the limit apart from a caller cancelling the query.
+var ErrStatementTimeout = errors.New("engine: statement timeout exceeded")
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidSessionVariable is returned by SessionVariableSQL for a name or
+// value it refuses to render.
+var ErrInvalidSessionVariable = errors.New("engine: invalid session variable")
+
+// SessionVariableSetter is implemented by engines whose connections carry
+// session variables. SessionVariableSQL returns the statement that sets name
+// to value for the rest of the connection's life. The value is always
+// rendered as a literal; the name must be a plain, optionally qualified,
+// identifier.
+type SessionVariableSetter interface {
+	SessionVariableSQL(name, value string) (string, error)
+}
+
+var (
+	sessionVariableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	numericLiteralPattern      = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+)
+
+// CheckSessionVariable validates a session variable before an engine renders
+// it: the name must be an identifier, optionally qualified once, and the
+// value must be a single line.
+func CheckSessionVariable(name, value string) error {
+	if !sessionVariableNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name %q must be an identifier", ErrInvalidSessionVariable, name)
+	}
+	if strings.ContainsAny(value, "\x00\r\n") {
+		return fmt.Errorf("%w: value for %s must be a single line", ErrInvalidSessionVariable, name)
+	}
+	return nil
+}
+
+// IsNumericLiteral reports whether value is a plain decimal number that an
+// engine may render without quotes.
+func IsNumericLiteral(value string) bool {
+	return numericLiteralPattern.MatchString(value)
+}
@@ -114,11 +114,12 @@ type DuplicateInput struct {
 	Name string
 }
 
-// ReadContentResult contains an opened content reader and its current metadata.
+// ReadContentResult contains an opened content reader and its metadata.
 type ReadContentResult struct {
-	File   database.WorkspaceFile
-	Object filestore.StoredObject
-	Reader io.ReadCloser
+	File    database.WorkspaceFile
+	Content database.WorkspaceFileContent
+	Object  filestore.StoredObject
+	Reader  io.ReadCloser
 }
 
 // PathSegment is one breadcrumb entry for a workspace file browser location.
@@ -518,6 +519,30 @@ func (s *Service) ReadContent(ctx context.Context, scope Scope, fileID int64) (R
 	if !found {
 		return ReadContentResult{}, ErrNotFound
 	}
+	return s.openContent(ctx, file, content)
+}
+
+// ReadContentVersion opens the stored bytes of one revision of an authorized
+// file. Revisions that were pruned, or overwritten in place, are not found.
+func (s *Service) ReadContentVersion(ctx context.Context, scope Scope, fileID int64, version int) (ReadContentResult, error) {
+	file, err := s.authorizedFile(ctx, scope, access.PermWsFileRead, fileID)
+	if err != nil {
+		return ReadContentResult{}, err
+	}
+	if file.ObjectType != database.FileObjectTypeFile {
+		return ReadContentResult{}, ErrFolderContent
+	}
+	content, found, err := s.db.GetWorkspaceFileContentVersion(ctx, file.ID, version)
+	if err != nil {
+		return ReadContentResult{}, err
+	}
+	if !found {
+		return ReadContentResult{}, ErrNotFound
+	}
+	return s.openContent(ctx, file, content)
+}
+
+func (s *Service) openContent(ctx context.Context, file database.WorkspaceFile, content database.WorkspaceFileContent) (ReadContentResult, error) {
 	store, err := s.storeForContent(ctx, content)
 	if err != nil {
 		return ReadContentResult{}, err
@@ -526,7 +551,7 @@ func (s *Service) ReadContent(ctx context.Context, scope Scope, fileID int64) (R
 	if err != nil {
 		return ReadContentResult{}, err
 	}
-	return ReadContentResult{File: file, Object: object, Reader: reader}, nil
+	return ReadContentResult{File: file, Content: content, Object: object, Reader: reader}, nil
 }
 
 // WriteContent stores new bytes for an authorized file, enforcing If-Match for
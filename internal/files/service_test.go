@@ -759,6 +759,46 @@ func TestContentReadsUseSavedBackendAndWritesUseActiveBackend(t *testing.T) {
 	}
 }
 
+func TestReadContentVersionOpensEarlierRevision(t *testing.T) {
+	f := newServiceFixture(t, Config{StorageMode: StorageModeObject, RevisionPolicy: RevisionPolicyVersioned, RevisionKeepLatest: 10})
+	scope := f.privateScope(f.member)
+
+	file, err := f.service.Create(f.ctx, scope, CreateInput{Name: "migrate.sql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := f.service.WriteContent(f.ctx, scope, file.ID, "", strings.NewReader("create table a (id int);"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.WriteContent(f.ctx, scope, file.ID, first.ContentHash, strings.NewReader("create table b (id int);")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := f.service.ReadContentVersion(f.ctx, scope, file.ID, first.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(result.Reader)
+	result.Reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "create table a (id int);" {
+		t.Fatalf("revision body = %q, want first revision", string(body))
+	}
+	if result.Content.ID != first.ID || result.Content.ContentHash != first.ContentHash {
+		t.Fatalf("revision content = %+v, want %+v", result.Content, first)
+	}
+
+	if _, err := f.service.ReadContentVersion(f.ctx, scope, file.ID, first.Version+5); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing revision error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := f.service.ReadContentVersion(f.ctx, f.privateScope(f.owner), file.ID, first.Version); err == nil {
+		t.Fatal("expected another account to be refused the revision")
+	}
+}
+
 func TestReadContentFailsWhenSavedBackendIsUnavailable(t *testing.T) {
 	f := newServiceFixture(t, Config{StorageMode: StorageModeObject, ActiveStorageBackendID: "local", RevisionPolicy: RevisionPolicyDisabled})
 	resolver := &mutableStoreResolver{active: "local", stores: map[string]filestore.Store{"local": f.store}}
@@ -10,9 +10,14 @@ import (
 )
 
 const (
-	TypeFileContentReap = "file_content_reap"
-	TypeExportQueryCSV  = "export_query_csv"
-	TypeSchemaSync      = "schema_sync"
+	TypeFileContentReap  = "file_content_reap"
+	TypeExportQueryCSV   = "export_query_csv"
+	TypeSchemaSync       = "schema_sync"
+	TypeAuditCheckpoint  = "audit_checkpoint"
+	TypeAuditForward     = "audit_forward"
+	TypeDeploySQL        = "deploy_sql"
+	TypeApplyMigrations  = "apply_migrations"
+	TypeConnectionHealth = "connection_health"
 
 	EventLevelInfo  = "info"
 	EventLevelWarn  = "warn"
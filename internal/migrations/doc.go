@@ -0,0 +1,11 @@
+// Package migrations tracks numbered SQL migrations applied to target
+// databases.
+//
+// It is unrelated to SQLWarden's own schema migrations. A migration set is a
+// folder of shared workspace SQL files whose names start with a version number
+// (for example 0003_add_orders.sql). The applied state lives on the target in
+// a tracking table the package creates and manages, so it stays accurate when
+// a database is migrated from more than one SQLWarden instance. Each applied
+// row keeps the checksum of the SQL that ran; a file whose content no longer
+// matches its applied checksum is reported as drifted.
+package migrations
@@ -0,0 +1,188 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTable is the tracking table used when a migration set does not
+	// name one.
+	DefaultTable = "sqlwarden_schema_migrations"
+
+	StatePending = "pending"
+	StateApplied = "applied"
+	StateFailed  = "failed"
+	StateDrifted = "drifted"
+	StateMissing = "missing"
+
+	// MaxErrorLength bounds the target error kept on a failed row.
+	MaxErrorLength = 2000
+)
+
+var (
+	ErrInvalidTable     = errors.New("invalid migrations table name")
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrDrift            = errors.New("applied migrations have drifted")
+	ErrUnsupported      = errors.New("migrations are not supported for this engine")
+)
+
+var (
+	tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+	fileNamePattern  = regexp.MustCompile(`^([0-9]{1,18})[_.-]`)
+)
+
+// Source is one migration file as it is now in the workspace.
+type Source struct {
+	Version        int64  `json:"version"`
+	Name           string `json:"name"`
+	FileID         int64  `json:"file_id"`
+	ContentVersion int    `json:"content_version"`
+	Checksum       string `json:"checksum"`
+	SQL            string `json:"-"`
+}
+
+// Record is one row of the target's tracking table.
+type Record struct {
+	Version      int64     `json:"version"`
+	Name         string    `json:"name"`
+	Checksum     string    `json:"checksum"`
+	Status       string    `json:"status"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	AppliedBy    string    `json:"applied_by"`
+	AppliedAt    time.Time `json:"applied_at"`
+	DurationMS   int64     `json:"duration_ms"`
+}
+
+// Entry is the state of one migration version on one target.
+type Entry struct {
+	Version         int64      `json:"version"`
+	Name            string     `json:"name"`
+	State           string     `json:"state"`
+	FileID          *int64     `json:"file_id"`
+	Checksum        string     `json:"checksum,omitempty"`
+	AppliedChecksum string     `json:"applied_checksum,omitempty"`
+	AppliedBy       string     `json:"applied_by,omitempty"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	ErrorMessage    string     `json:"error_message,omitempty"`
+}
+
+// ValidTable reports whether name can be used as a tracking table name. Names
+// are unquoted lowercase identifiers so they resolve the same way on every
+// engine.
+func ValidTable(name string) bool {
+	return tableNamePattern.MatchString(name)
+}
+
+// ParseVersion returns the version number a migration file name starts with.
+// Only .sql files named like 0001_create_users.sql are migrations.
+func ParseVersion(name string) (int64, bool) {
+	if !strings.EqualFold(path.Ext(name), ".sql") {
+		return 0, false
+	}
+	match := fileNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// Checksum returns the checksum stored for SQL when it is applied.
+func Checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// SortSources orders sources by version and rejects duplicate versions.
+func SortSources(sources []Source) error {
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Version < sources[j].Version })
+	for i := 1; i < len(sources); i++ {
+		if sources[i].Version == sources[i-1].Version {
+			return fmt.Errorf("%w: %d is used by %s and %s", ErrDuplicateVersion, sources[i].Version, sources[i-1].Name, sources[i].Name)
+		}
+	}
+	return nil
+}
+
+// Plan compares the workspace files with the target's tracking rows and
+// returns one entry per version, in version order. Rows with no matching file
+// are reported as missing.
+func Plan(sources []Source, records []Record) ([]Entry, error) {
+	if err := SortSources(sources); err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	entries := make([]Entry, 0, len(sources)+len(records))
+	for _, source := range sources {
+		fileID := source.FileID
+		entry := Entry{Version: source.Version, Name: source.Name, FileID: &fileID, Checksum: source.Checksum, State: StatePending}
+		if record, ok := applied[source.Version]; ok {
+			delete(applied, source.Version)
+			appliedAt := record.AppliedAt
+			entry.AppliedChecksum = record.Checksum
+			entry.AppliedBy = record.AppliedBy
+			entry.AppliedAt = &appliedAt
+			entry.ErrorMessage = record.ErrorMessage
+			switch {
+			case record.Status == StateFailed:
+				entry.State = StateFailed
+			case record.Checksum != source.Checksum:
+				entry.State = StateDrifted
+			default:
+				entry.State = StateApplied
+			}
+		}
+		entries = append(entries, entry)
+	}
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		entries = append(entries, Entry{
+			Version:         record.Version,
+			Name:            record.Name,
+			State:           StateMissing,
+			AppliedChecksum: record.Checksum,
+			AppliedBy:       record.AppliedBy,
+			AppliedAt:       &appliedAt,
+			ErrorMessage:    record.ErrorMessage,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Version < entries[j].Version })
+	return entries, nil
+}
+
+// Drifted returns the versions whose applied checksum no longer matches the
+// workspace file.
+func Drifted(entries []Entry) []int64 {
+	var versions []int64
+	for _, entry := range entries {
+		if entry.State == StateDrifted {
+			versions = append(versions, entry.Version)
+		}
+	}
+	return versions
+}
+
+func errorMessage(err error) string {
+	message := strings.TrimSpace(err.Error())
+	if len(message) > MaxErrorLength {
+		message = strings.ToValidUTF8(message[:MaxErrorLength], "")
+	}
+	return message
+}
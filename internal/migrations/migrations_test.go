@@ -0,0 +1,169 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/sqlwarden/internal/engine"
+	_ "github.com/sqlwarden/internal/engine/engines/sqlite"
+)
+
+func openSQLite(t *testing.T) engine.Driver {
+	t.Helper()
+	driver, err := engine.New("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Connect(context.Background(), engine.ConnectionConfig{DSN: filepath.Join(t.TempDir(), "target.db"), Driver: "sqlite"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { driver.Close() })
+	return driver
+}
+
+func source(version int64, name, sql string) Source {
+	return Source{Version: version, Name: name, FileID: version * 10, SQL: sql, Checksum: Checksum(sql)}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int64
+		ok      bool
+	}{
+		{name: "0001_create_users.sql", version: 1, ok: true},
+		{name: "20260101120000-add-orders.SQL", version: 20260101120000, ok: true},
+		{name: "3.seed.sql", version: 3, ok: true},
+		{name: "create_users.sql", ok: false},
+		{name: "0001_create_users.txt", ok: false},
+		{name: "0000_zero.sql", ok: false},
+		{name: "0001create.sql", ok: false},
+	}
+	for _, tt := range tests {
+		version, ok := ParseVersion(tt.name)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("ParseVersion(%q) = %d, %v; want %d, %v", tt.name, version, ok, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestPlanReportsDriftAndMissingRows(t *testing.T) {
+	sources := []Source{
+		source(3, "0003_c.sql", "SELECT 3"),
+		source(1, "0001_a.sql", "SELECT 1"),
+		source(2, "0002_b.sql", "SELECT 2 -- edited"),
+	}
+	records := []Record{
+		{Version: 1, Name: "0001_a.sql", Checksum: Checksum("SELECT 1"), Status: StateApplied},
+		{Version: 2, Name: "0002_b.sql", Checksum: Checksum("SELECT 2"), Status: StateApplied},
+		{Version: 7, Name: "0007_gone.sql", Checksum: Checksum("SELECT 7"), Status: StateApplied},
+	}
+	entries, err := Plan(sources, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		version int64
+		state   string
+	}{{1, StateApplied}, {2, StateDrifted}, {3, StatePending}, {7, StateMissing}}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %d, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		if entries[i].Version != w.version || entries[i].State != w.state {
+			t.Errorf("entry %d = %d %s, want %d %s", i, entries[i].Version, entries[i].State, w.version, w.state)
+		}
+	}
+
+	_, err = Plan([]Source{source(1, "0001_a.sql", "SELECT 1"), source(1, "1_again.sql", "SELECT 1")}, nil)
+	if !errors.Is(err, ErrDuplicateVersion) {
+		t.Fatalf("duplicate versions error = %v, want ErrDuplicateVersion", err)
+	}
+}
+
+func TestTrackerAppliesInOrderAndRetriesFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	driver := openSQLite(t)
+	tracker, err := NewTracker(driver, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := tracker.Records(ctx)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("records before apply = %v, %v; want none", records, err)
+	}
+
+	sources := []Source{
+		source(1, "0001_widgets.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)"),
+		source(2, "0002_gadgets.sql", "INSERT INTO gadgets VALUES (1)"),
+		source(3, "0003_more.sql", "CREATE TABLE more_widgets (id INTEGER PRIMARY KEY)"),
+	}
+	result, err := tracker.Apply(ctx, sources, ApplyOptions{AppliedBy: "ada@example.com"})
+	var applyErr *ApplyError
+	if !errors.As(err, &applyErr) || applyErr.Version != 2 {
+		t.Fatalf("apply error = %v, want failure on version 2", err)
+	}
+	if len(result.Applied) != 1 || result.Failed == nil || result.Failed.ErrorMessage == "" {
+		t.Fatalf("result = %+v, want one applied and a recorded failure", result)
+	}
+
+	records, err = tracker.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Plan(sources, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].State != StateApplied || entries[1].State != StateFailed || entries[2].State != StatePending {
+		t.Fatalf("states = %s %s %s, want applied failed pending", entries[0].State, entries[1].State, entries[2].State)
+	}
+	if entries[0].AppliedBy != "ada@example.com" || entries[0].AppliedAt == nil || entries[0].AppliedAt.IsZero() {
+		t.Fatalf("applied entry = %+v, want actor and time", entries[0])
+	}
+
+	sources[1] = source(2, "0002_gadgets.sql", "CREATE TABLE gadgets (id INTEGER PRIMARY KEY)")
+	result, err = tracker.Apply(ctx, sources, ApplyOptions{TargetVersion: 2, AppliedBy: "ada@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != 1 || result.Applied[0].Version != 2 {
+		t.Fatalf("applied = %+v, want version 2 only", result.Applied)
+	}
+	records, err = tracker.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Status != StateApplied || records[1].ErrorMessage != "" {
+		t.Fatalf("records = %+v, want two applied rows", records)
+	}
+}
+
+func TestTrackerRefusesToApplyOverDrift(t *testing.T) {
+	ctx := context.Background()
+	tracker, err := NewTracker(openSQLite(t), "app_migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := []Source{source(1, "0001_widgets.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)")}
+	if _, err := tracker.Apply(ctx, sources, ApplyOptions{AppliedBy: "ada@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := []Source{
+		source(1, "0001_widgets.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT)"),
+		source(2, "0002_gadgets.sql", "CREATE TABLE gadgets (id INTEGER PRIMARY KEY)"),
+	}
+	if _, err := tracker.Apply(ctx, edited, ApplyOptions{AppliedBy: "ada@example.com"}); !errors.Is(err, ErrDrift) {
+		t.Fatalf("apply over drift error = %v, want ErrDrift", err)
+	}
+	if _, err := tracker.Apply(ctx, edited, ApplyOptions{TargetVersion: 9}); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("unknown target error = %v, want ErrUnknownVersion", err)
+	}
+	if _, err := NewTracker(openSQLite(t), "Bad-Name"); !errors.Is(err, ErrInvalidTable) {
+		t.Fatalf("invalid table error = %v, want ErrInvalidTable", err)
+	}
+}
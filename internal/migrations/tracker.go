@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/pkg/result"
+)
+
+// Tracker reads and writes a tracking table on a connected target.
+type Tracker struct {
+	driver engine.Driver
+	table  string
+}
+
+// ApplyOptions controls one Apply run. A zero TargetVersion applies every
+// outstanding migration.
+type ApplyOptions struct {
+	TargetVersion int64
+	AppliedBy     string
+	OnApplied     func(Record)
+}
+
+// ApplyResult lists the migrations an Apply run recorded.
+type ApplyResult struct {
+	Applied []Record `json:"applied"`
+	Failed  *Record  `json:"failed,omitempty"`
+}
+
+// ApplyError reports the migration that failed on the target.
+type ApplyError struct {
+	Version int64
+	Name    string
+	Err     error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("migration %d (%s) failed: %v", e.Version, e.Name, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// NewTracker returns a tracker for table on a connected driver.
+func NewTracker(driver engine.Driver, table string) (*Tracker, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+	if !ValidTable(table) {
+		return nil, ErrInvalidTable
+	}
+	switch driver.Dialect() {
+	case engine.DialectPostgres, engine.DialectMySQL, engine.DialectSQLite:
+	default:
+		return nil, ErrUnsupported
+	}
+	return &Tracker{driver: driver, table: table}, nil
+}
+
+// Records returns the tracking rows in version order. A target without the
+// tracking table has no rows; Records never creates it.
+func (t *Tracker) Records(ctx context.Context) ([]Record, error) {
+	exists, err := t.tableExists(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+	rs, err := t.driver.Query(ctx, "SELECT version, name, checksum, status, error_message, applied_by, applied_at, duration_ms FROM "+t.table+" ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	if rs.Truncated {
+		return nil, errors.New("migrations: tracking table is larger than the result limit")
+	}
+	records := make([]Record, 0, len(rs.Rows))
+	for _, row := range rs.Rows {
+		if len(row) != 8 {
+			return nil, errors.New("migrations: unexpected tracking table shape")
+		}
+		appliedAt, _ := time.Parse(time.RFC3339Nano, valueText(row[6]))
+		records = append(records, Record{
+			Version:      valueInt(row[0]),
+			Name:         valueText(row[1]),
+			Checksum:     valueText(row[2]),
+			Status:       valueText(row[3]),
+			ErrorMessage: valueText(row[4]),
+			AppliedBy:    valueText(row[5]),
+			AppliedAt:    appliedAt,
+			DurationMS:   valueInt(row[7]),
+		})
+	}
+	return records, nil
+}
+
+// Apply runs outstanding migrations up to opts.TargetVersion in version order
+// and records each outcome. Failed migrations are retried. It refuses to run
+// while any applied migration has drifted, and stops at the first failure.
+func (t *Tracker) Apply(ctx context.Context, sources []Source, opts ApplyOptions) (ApplyResult, error) {
+	if opts.TargetVersion != 0 && !hasVersion(sources, opts.TargetVersion) {
+		return ApplyResult{}, ErrUnknownVersion
+	}
+	if err := t.ensureTable(ctx); err != nil {
+		return ApplyResult{}, err
+	}
+	records, err := t.Records(ctx)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	entries, err := Plan(sources, records)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	if drifted := Drifted(entries); len(drifted) > 0 {
+		return ApplyResult{}, fmt.Errorf("%w: versions %v", ErrDrift, drifted)
+	}
+
+	bySource := make(map[int64]Source, len(sources))
+	for _, source := range sources {
+		bySource[source.Version] = source
+	}
+	result := ApplyResult{Applied: []Record{}}
+	for _, entry := range entries {
+		if entry.State != StatePending && entry.State != StateFailed {
+			continue
+		}
+		if opts.TargetVersion != 0 && entry.Version > opts.TargetVersion {
+			break
+		}
+		source := bySource[entry.Version]
+		started := time.Now()
+		_, execErr := t.driver.Execute(ctx, source.SQL)
+		record := Record{
+			Version:    source.Version,
+			Name:       source.Name,
+			Checksum:   source.Checksum,
+			Status:     StateApplied,
+			AppliedBy:  opts.AppliedBy,
+			AppliedAt:  time.Now().UTC(),
+			DurationMS: time.Since(started).Milliseconds(),
+		}
+		if execErr != nil {
+			record.Status = StateFailed
+			record.ErrorMessage = errorMessage(execErr)
+			if err := t.write(context.WithoutCancel(ctx), record); err != nil {
+				return result, err
+			}
+			result.Failed = &record
+			return result, &ApplyError{Version: source.Version, Name: source.Name, Err: execErr}
+		}
+		if err := t.write(ctx, record); err != nil {
+			return result, err
+		}
+		result.Applied = append(result.Applied, record)
+		if opts.OnApplied != nil {
+			opts.OnApplied(record)
+		}
+	}
+	return result, nil
+}
+
+func (t *Tracker) ensureTable(ctx context.Context) error {
+	_, err := t.driver.Execute(ctx, "CREATE TABLE IF NOT EXISTS "+t.table+` (
+    version       BIGINT       NOT NULL PRIMARY KEY,
+    name          VARCHAR(255) NOT NULL,
+    checksum      VARCHAR(64)  NOT NULL,
+    status        VARCHAR(16)  NOT NULL,
+    error_message TEXT,
+    applied_by    VARCHAR(320) NOT NULL,
+    applied_at    VARCHAR(40)  NOT NULL,
+    duration_ms   BIGINT       NOT NULL
+)`)
+	return err
+}
+
+func (t *Tracker) tableExists(ctx context.Context) (bool, error) {
+	var query string
+	switch t.driver.Dialect() {
+	case engine.DialectPostgres:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	case engine.DialectMySQL:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	}
+	rs, err := t.driver.Query(ctx, query, t.table)
+	if err != nil {
+		return false, err
+	}
+	return len(rs.Rows) == 1 && len(rs.Rows[0]) == 1 && valueInt(rs.Rows[0][0]) > 0, nil
+}
+
+// write replaces the tracking row for record's version.
+func (t *Tracker) write(ctx context.Context, record Record) error {
+	if _, err := t.driver.Execute(ctx, "DELETE FROM "+t.table+" WHERE version = "+t.placeholder(1), record.Version); err != nil {
+		return err
+	}
+	var errorMessage any
+	if record.ErrorMessage != "" {
+		errorMessage = record.ErrorMessage
+	}
+	placeholders := make([]string, 8)
+	for i := range placeholders {
+		placeholders[i] = t.placeholder(i + 1)
+	}
+	_, err := t.driver.Execute(ctx,
+		"INSERT INTO "+t.table+" (version, name, checksum, status, error_message, applied_by, applied_at, duration_ms) VALUES ("+strings.Join(placeholders, ", ")+")",
+		record.Version, record.Name, record.Checksum, record.Status, errorMessage, record.AppliedBy, record.AppliedAt.Format(time.RFC3339Nano), record.DurationMS)
+	return err
+}
+
+func (t *Tracker) placeholder(n int) string {
+	if t.driver.Dialect() == engine.DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func hasVersion(sources []Source, version int64) bool {
+	for _, source := range sources {
+		if source.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func valueText(v result.Value) string {
+	switch v.Type {
+	case result.ValueTypeText:
+		return v.Text
+	case result.ValueTypeBytes:
+		return string(v.Bytes)
+	case result.ValueTypeInteger:
+		return strconv.FormatInt(v.Integer, 10)
+	case result.ValueTypeDecimal:
+		return v.Decimal
+	default:
+		return ""
+	}
+}
+
+func valueInt(v result.Value) int64 {
+	switch v.Type {
+	case result.ValueTypeInteger:
+		return v.Integer
+	case result.ValueTypeFloat:
+		return int64(v.Float)
+	default:
+		n, _ := strconv.ParseInt(strings.TrimSpace(valueText(v)), 10, 64)
+		return n
+	}
+}
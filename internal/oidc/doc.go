@@ -0,0 +1,4 @@
+// Package oidc is a minimal OpenID Connect relying party: issuer discovery,
+// the authorization-code flow with PKCE, and ID token verification against the
+// issuer's published signing keys.
+package oidc
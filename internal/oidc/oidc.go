@@ -0,0 +1,354 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pascaldekloe/jwt"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// metadataTTL bounds how long discovery documents and signing keys are
+	// cached. Keys are also refetched early when a token names an unknown kid.
+	metadataTTL = time.Hour
+	// clockLeeway tolerates skew between this server and the issuer.
+	clockLeeway  = 2 * time.Minute
+	maxBodyBytes = 1 << 20
+)
+
+var (
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+	ErrNonceMismatch  = errors.New("oidc: id token nonce does not match")
+)
+
+// Metadata is the subset of the OpenID Provider discovery document the relying
+// party needs.
+type Metadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// AuthRequest describes one authorization redirect.
+type AuthRequest struct {
+	ClientID      string
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	Nonce         string
+	CodeChallenge string
+}
+
+// ExchangeRequest redeems an authorization code at the token endpoint.
+type ExchangeRequest struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Code         string
+	CodeVerifier string
+}
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Claims        map[string]any
+}
+
+// StringList returns a claim that holds either a string or a list of strings,
+// as group claims commonly do. Other values yield nil.
+func (t IDToken) StringList(claim string) []string {
+	switch value := t.Claims[claim].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// S256Challenge derives the PKCE code challenge for verifier (RFC 7636).
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type issuerState struct {
+	metadata  Metadata
+	keys      *jwt.KeyRegister
+	fetchedAt time.Time
+	keysAt    time.Time
+}
+
+// Client talks to OpenID providers and caches their discovery documents and
+// signing keys per issuer. It is safe for concurrent use.
+type Client struct {
+	http *http.Client
+	now  func() time.Time
+
+	mu      sync.Mutex
+	issuers map[string]*issuerState
+}
+
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &Client{http: httpClient, now: time.Now, issuers: map[string]*issuerState{}}
+}
+
+// Discover returns the provider metadata for issuer, fetching
+// /.well-known/openid-configuration when it is not cached.
+func (c *Client) Discover(ctx context.Context, issuer string) (Metadata, error) {
+	state, err := c.issuer(ctx, issuer)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return state.metadata, nil
+}
+
+func (c *Client) issuer(ctx context.Context, issuer string) (*issuerState, error) {
+	c.mu.Lock()
+	state, ok := c.issuers[issuer]
+	c.mu.Unlock()
+	if ok && c.now().Sub(state.fetchedAt) < metadataTTL {
+		return state, nil
+	}
+
+	var metadata Metadata
+	err := c.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if metadata.Issuer != issuer {
+		return nil, fmt.Errorf("oidc: discovery document issuer %q does not match %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing required endpoints")
+	}
+	if len(metadata.CodeChallengeMethodsSupported) > 0 && !slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		return nil, errors.New("oidc: provider does not support the S256 code challenge method")
+	}
+
+	state = &issuerState{metadata: metadata, fetchedAt: c.now()}
+	c.mu.Lock()
+	c.issuers[issuer] = state
+	c.mu.Unlock()
+	return state, nil
+}
+
+// AuthCodeURL builds the authorization endpoint URL for req. The "openid"
+// scope is always requested.
+func AuthCodeURL(metadata Metadata, req AuthRequest) string {
+	scopes := []string{"openid"}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {req.ClientID},
+		"redirect_uri":          {req.RedirectURI},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {req.CodeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return metadata.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (c *Client) Exchange(ctx context.Context, issuer string, req ExchangeRequest) (string, error) {
+	state, err := c.issuer(ctx, issuer)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {req.Code},
+		"redirect_uri":  {req.RedirectURI},
+		"client_id":     {req.ClientID},
+		"code_verifier": {req.CodeVerifier},
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, state.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if req.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(req.ClientID), url.QueryEscape(req.ClientSecret))
+	}
+
+	res, err := c.http.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBodyBytes))
+	if err != nil {
+		return "", fmt.Errorf("oidc: token response: %w", err)
+	}
+
+	var payload struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil && res.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("oidc: token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		if payload.Error != "" {
+			return "", fmt.Errorf("oidc: token endpoint returned %s: %s", payload.Error, payload.ErrorDescription)
+		}
+		return "", fmt.Errorf("oidc: token endpoint returned status %d", res.StatusCode)
+	}
+	if payload.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+	return payload.IDToken, nil
+}
+
+// VerifyIDToken checks the signature of raw against the issuer's keys and
+// validates issuer, audience, lifetime and nonce (OpenID Connect Core 3.1.3.7).
+func (c *Client) VerifyIDToken(ctx context.Context, issuer, raw, clientID, nonce string) (IDToken, error) {
+	state, err := c.issuer(ctx, issuer)
+	if err != nil {
+		return IDToken{}, err
+	}
+	keys, err := c.signingKeys(ctx, state, false)
+	if err != nil {
+		return IDToken{}, err
+	}
+	claims, err := keys.Check([]byte(raw))
+	if errors.Is(err, jwt.ErrSigMiss) {
+		// The issuer may have rotated its keys since they were cached.
+		if keys, err = c.signingKeys(ctx, state, true); err == nil {
+			claims, err = keys.Check([]byte(raw))
+		}
+	}
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Issuer != issuer {
+		return IDToken{}, fmt.Errorf("%w: issuer %q does not match", ErrInvalidIDToken, claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return IDToken{}, fmt.Errorf("%w: subject is missing", ErrInvalidIDToken)
+	}
+	if len(claims.Audiences) == 0 || !slices.Contains(claims.Audiences, clientID) {
+		return IDToken{}, fmt.Errorf("%w: audience does not include the client", ErrInvalidIDToken)
+	}
+	if azp, ok := claims.String("azp"); ok && azp != clientID {
+		return IDToken{}, fmt.Errorf("%w: authorized party %q does not match", ErrInvalidIDToken, azp)
+	}
+	if claims.Expires == nil {
+		return IDToken{}, fmt.Errorf("%w: expiry is missing", ErrInvalidIDToken)
+	}
+	if err := claims.AcceptTemporal(c.now(), clockLeeway); err != nil {
+		return IDToken{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if got, _ := claims.String("nonce"); got != nonce {
+		return IDToken{}, ErrNonceMismatch
+	}
+
+	token := IDToken{Issuer: claims.Issuer, Subject: claims.Subject, Claims: claims.Set}
+	token.Email, _ = claims.String("email")
+	token.Name, _ = claims.String("name")
+	// Some providers encode email_verified as the string "true".
+	switch verified := claims.Set["email_verified"].(type) {
+	case bool:
+		token.EmailVerified = verified
+	case string:
+		token.EmailVerified = verified == "true"
+	}
+	return token, nil
+}
+
+// signingKeys returns the issuer's asymmetric signing keys. Symmetric keys are
+// never loaded, so an ID token cannot be forged by signing it with HS256.
+func (c *Client) signingKeys(ctx context.Context, state *issuerState, refresh bool) (*jwt.KeyRegister, error) {
+	c.mu.Lock()
+	keys, keysAt := state.keys, state.keysAt
+	c.mu.Unlock()
+	if keys != nil && !refresh && c.now().Sub(keysAt) < metadataTTL {
+		return keys, nil
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := c.getJSON(ctx, state.metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: fetch signing keys: %w", err)
+	}
+	keys = new(jwt.KeyRegister)
+	for _, raw := range set.Keys {
+		var header struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+		}
+		if json.Unmarshal(raw, &header) != nil || header.Kty == "oct" || (header.Use != "" && header.Use != "sig") {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the set.
+		_, _ = keys.LoadJWK(raw)
+	}
+	if len(keys.RSAs)+len(keys.ECDSAs)+len(keys.EdDSAs) == 0 {
+		return nil, errors.New("oidc: issuer publishes no usable signing keys")
+	}
+
+	c.mu.Lock()
+	state.keys, state.keysAt = keys, c.now()
+	c.mu.Unlock()
+	return keys, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", endpoint, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxBodyBytes)).Decode(dst)
+}
@@ -0,0 +1,177 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/oidc"
+	"github.com/sqlwarden/internal/oidc/oidctest"
+)
+
+const (
+	testClientID     = "sqlwarden"
+	testClientSecret = "client-secret"
+	testRedirectURI  = "https://sqlwarden.example.com/api/v1/auth/sso/callback"
+)
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, testClientID, testClientSecret)
+	client := oidc.NewClient(nil)
+	ctx := context.Background()
+
+	metadata, err := client.Discover(ctx, issuer.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	verifier := "0123456789abcdef0123456789abcdef0123456789abcdef"
+	authURL := oidc.AuthCodeURL(metadata, oidc.AuthRequest{
+		ClientID:      testClientID,
+		RedirectURI:   testRedirectURI,
+		Scopes:        []string{"email", "openid"},
+		State:         "state-1",
+		Nonce:         "nonce-1",
+		CodeChallenge: oidc.S256Challenge(verifier),
+	})
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Query().Get("scope"); got != "openid email" {
+		t.Errorf("scope = %q; want %q", got, "openid email")
+	}
+
+	callback, err := issuer.Authorize(authURL, map[string]any{
+		"sub":            "user-1",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"groups":         []string{"dba", "eng"},
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	callbackURL, _ := url.Parse(callback)
+	if got := callbackURL.Query().Get("state"); got != "state-1" {
+		t.Errorf("state = %q; want %q", got, "state-1")
+	}
+
+	exchange := oidc.ExchangeRequest{
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURI:  testRedirectURI,
+		Code:         callbackURL.Query().Get("code"),
+		CodeVerifier: verifier,
+	}
+	raw, err := client.Exchange(ctx, issuer.URL, exchange)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := client.Exchange(ctx, issuer.URL, exchange); err == nil {
+		t.Error("Exchange reused an authorization code")
+	}
+
+	idToken, err := client.VerifyIDToken(ctx, issuer.URL, raw, testClientID, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if idToken.Subject != "user-1" || idToken.Email != "ada@example.com" || !idToken.EmailVerified {
+		t.Errorf("claims = %+v", idToken)
+	}
+	if groups := idToken.StringList("groups"); len(groups) != 2 || groups[0] != "dba" {
+		t.Errorf("groups = %v", groups)
+	}
+
+	if _, err := client.VerifyIDToken(ctx, issuer.URL, raw, testClientID, "other-nonce"); !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Errorf("nonce mismatch error = %v", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, testClientID, testClientSecret)
+	client := oidc.NewClient(nil)
+	ctx := context.Background()
+
+	metadata, err := client.Discover(ctx, issuer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL := oidc.AuthCodeURL(metadata, oidc.AuthRequest{
+		ClientID:      testClientID,
+		RedirectURI:   testRedirectURI,
+		State:         "s",
+		Nonce:         "n",
+		CodeChallenge: oidc.S256Challenge("the-real-verifier-the-real-verifier-the-real"),
+	})
+	callback, err := issuer.Authorize(authURL, map[string]any{"sub": "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackURL, _ := url.Parse(callback)
+
+	_, err = client.Exchange(ctx, issuer.URL, oidc.ExchangeRequest{
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURI:  testRedirectURI,
+		Code:         callbackURL.Query().Get("code"),
+		CodeVerifier: "a-guessed-verifier-a-guessed-verifier-a-guessed",
+	})
+	if err == nil {
+		t.Fatal("Exchange accepted a wrong code verifier")
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidClaims(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, testClientID, testClientSecret)
+	client := oidc.NewClient(nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   issuer.URL,
+			"sub":   "user-1",
+			"aud":   testClientID,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Minute).Unix(),
+			"nonce": "n",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(map[string]any)
+	}{
+		{"wrong issuer", func(c map[string]any) { c["iss"] = "https://evil.example.com" }},
+		{"wrong audience", func(c map[string]any) { c["aud"] = "someone-else" }},
+		{"expired", func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() }},
+		{"missing expiry", func(c map[string]any) { delete(c, "exp") }},
+		{"missing subject", func(c map[string]any) { delete(c, "sub") }},
+		{"wrong authorized party", func(c map[string]any) { c["azp"] = "someone-else" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+			raw, err := issuer.SignIDToken(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.VerifyIDToken(ctx, issuer.URL, raw, testClientID, "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("error = %v; want ErrInvalidIDToken", err)
+			}
+		})
+	}
+
+	other := oidctest.NewIssuer(t, testClientID, testClientSecret)
+	claims := valid()
+	raw, err := other.SignIDToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.VerifyIDToken(ctx, issuer.URL, raw, testClientID, "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("token signed by another key: error = %v; want ErrInvalidIDToken", err)
+	}
+}
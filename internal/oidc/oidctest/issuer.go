@@ -0,0 +1,201 @@
+// Package oidctest runs an in-process OpenID provider for tests. It supports
+// discovery, the signing key set, and the authorization-code flow with PKCE.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/jwt"
+	"github.com/sqlwarden/internal/oidc"
+)
+
+const keyID = "oidctest-key"
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]any
+}
+
+// Issuer is a mock OpenID provider backed by an httptest server.
+type Issuer struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+	seq    int
+}
+
+// NewIssuer starts an issuer that accepts one client. It is closed when the
+// test ends.
+func NewIssuer(t testing.TB, clientID, clientSecret string) *Issuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &Issuer{ClientID: clientID, ClientSecret: clientSecret, key: key, grants: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /jwks", issuer.jwks)
+	mux.HandleFunc("POST /token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	issuer.URL = issuer.server.URL
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// Authorize plays the user signing in at the provider: it checks the
+// authorization URL the relying party redirected to and returns the callback
+// URL the provider would send the browser back to. claims are added to the ID
+// token issued for the resulting code; "sub" is required.
+func (i *Issuer) Authorize(authURL string, claims map[string]any) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if q.Get("client_id") != i.ClientID {
+		return "", fmt.Errorf("oidctest: unknown client_id %q", q.Get("client_id"))
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return "", fmt.Errorf("oidctest: authorization request must use the code flow with S256 PKCE")
+	}
+	if _, ok := claims["sub"]; !ok {
+		return "", fmt.Errorf("oidctest: claims must include sub")
+	}
+
+	i.mu.Lock()
+	i.seq++
+	code := fmt.Sprintf("code-%d", i.seq)
+	i.grants[code] = grant{
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		claims:        claims,
+	}
+	i.mu.Unlock()
+
+	callback, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return "", err
+	}
+	params := callback.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	callback.RawQuery = params.Encode()
+	return callback.String(), nil
+}
+
+// SignIDToken signs claims with the issuer key, for tests that craft tokens
+// directly.
+func (i *Issuer) SignIDToken(claims map[string]any) (string, error) {
+	c := &jwt.Claims{KeyID: keyID, Set: map[string]any{}}
+	for name, value := range claims {
+		c.Set[name] = value
+	}
+	token, err := c.RSASign(jwt.RS256, i.key)
+	return string(token), err
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Metadata{
+		Issuer:                        i.URL,
+		AuthorizationEndpoint:         i.URL + "/authorize",
+		TokenEndpoint:                 i.URL + "/token",
+		JWKSURI:                       i.URL + "/jwks",
+		CodeChallengeMethodsSupported: []string{"S256"},
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := i.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": jwt.RS256,
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if clientID != i.ClientID || clientSecret != i.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	i.mu.Lock()
+	g, ok := i.grants[code]
+	delete(i.grants, code)
+	i.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != g.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if oidc.S256Challenge(r.PostForm.Get("code_verifier")) != g.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   i.URL,
+		"aud":   g.clientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": g.nonce,
+	}
+	for name, value := range g.claims {
+		claims[name] = value
+	}
+	idToken, err := i.SignIDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "oidctest-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
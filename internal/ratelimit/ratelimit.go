@@ -0,0 +1,90 @@
+// Package ratelimit provides an in-process token bucket rate limiter keyed by
+// string, such as a client IP. Limits apply per process; replicas behind a
+// load balancer each enforce their own budget.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// maxKeys bounds the number of tracked keys. When it is reached, idle buckets
+// are swept; if none are idle the oldest bucket is dropped.
+const maxKeys = 100000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter allows Requests per Window for each key, with bursts of up to
+// Requests. It is safe for concurrent use.
+type Limiter struct {
+	requests float64
+	window   time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New returns a limiter that allows requests per window for each key.
+func New(requests int, window time.Duration) *Limiter {
+	return &Limiter{
+		requests: float64(requests),
+		window:   window,
+		now:      time.Now,
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// Allow takes one token from key's bucket. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxKeys {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.requests, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.requests, b.tokens+l.refill(now.Sub(b.updated)))
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.requests * float64(l.window))
+	return false, wait
+}
+
+func (l *Limiter) refill(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return elapsed.Seconds() / l.window.Seconds() * l.requests
+}
+
+// sweep drops buckets that have refilled completely, or the least recently
+// used bucket if every bucket is active.
+func (l *Limiter) sweep(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.window {
+			delete(l.buckets, key)
+			continue
+		}
+		if oldestKey == "" || b.updated.Before(oldest) {
+			oldestKey, oldest = key, b.updated
+		}
+	}
+	if len(l.buckets) >= maxKeys {
+		delete(l.buckets, oldestKey)
+	}
+}
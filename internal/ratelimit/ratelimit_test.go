@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllowsBurstThenRefills(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d: want allowed", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok || wait != 20*time.Second {
+		t.Fatalf("want denied for 20s, got %v %v", ok, wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("keys must not share a bucket")
+	}
+
+	now = now.Add(20 * time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("want a token after 20s")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("want only one refilled token")
+	}
+}
+
+func TestLimiterSweepsIdleKeys(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := New(1, time.Second)
+	l.now = func() time.Time { return now }
+
+	l.Allow("idle")
+	now = now.Add(time.Second)
+	l.Allow("active")
+	l.sweep(now)
+	if _, ok := l.buckets["idle"]; ok {
+		t.Fatal("want idle bucket swept")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Fatal("want active bucket kept")
+	}
+}
@@ -0,0 +1,56 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// DecodeYAML decodes a single YAML document into dst, rejecting keys dst does
+// not declare. dst's fields are matched by their yaml tags.
+func DecodeYAML(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	// The YAML decoder does not preserve read errors, so the body is read
+	// up front to report an oversized body.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(body))
+	dec.KnownFields(true)
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var typeError *yaml.TypeError
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+
+		case errors.As(err, &typeError):
+			return fmt.Errorf("body contains invalid YAML: %s", strings.Join(typeError.Errors, "; "))
+
+		default:
+			return fmt.Errorf("body contains badly-formed YAML: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+	}
+
+	var extra any
+	err = dec.Decode(&extra)
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single YAML document")
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package request
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+type testDecodeYAMLTarget struct {
+	Name  string   `yaml:"name"`
+	Age   int      `yaml:"age"`
+	Roles []string `yaml:"roles"`
+}
+
+func TestDecodeYAML(t *testing.T) {
+	t.Run("Decode valid YAML successfully", func(t *testing.T) {
+		body := "name: John\nage: 30\nroles:\n  - admin\n  - viewer\n"
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.Nil(t, err)
+		assert.Equal(t, target.Name, "John")
+		assert.Equal(t, target.Age, 30)
+		assert.Equal(t, len(target.Roles), 2)
+	})
+
+	t.Run("Decode JSON successfully", func(t *testing.T) {
+		body := `{"name":"John","age":30,"roles":["admin"]}`
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.Nil(t, err)
+		assert.Equal(t, target.Name, "John")
+		assert.Equal(t, target.Roles[0], "admin")
+	})
+
+	t.Run("Return error for empty body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(""))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.NotNil(t, err)
+		assert.Equal(t, err.Error(), "body must not be empty")
+	})
+
+	t.Run("Return error for unknown fields", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader("name: John\nunknown: value\n"))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.NotNil(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "body contains invalid YAML"))
+	})
+
+	t.Run("Return error for incorrect type", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader("age: thirty\n"))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.NotNil(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "body contains invalid YAML"))
+	})
+
+	t.Run("Return error for malformed YAML", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader("name: [John\n"))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.NotNil(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "body contains badly-formed YAML"))
+	})
+
+	t.Run("Return error for multiple documents", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader("name: John\n---\nname: Jane\n"))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.NotNil(t, err)
+		assert.Equal(t, err.Error(), "body must only contain a single YAML document")
+	})
+
+	t.Run("Return error for body larger than limit", func(t *testing.T) {
+		body := "name: " + strings.Repeat("a", 1_048_577) + "\n"
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		var target testDecodeYAMLTarget
+		err := DecodeYAML(w, req, &target)
+		assert.NotNil(t, err)
+		assert.Equal(t, err.Error(), "body must not be larger than 1048576 bytes")
+	})
+}
@@ -0,0 +1,27 @@
+package response
+
+import (
+	"bytes"
+	"net/http"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// YAML writes data as a YAML document. Fields are named by their yaml tags.
+func YAML(w http.ResponseWriter, status int, data any) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestYAML(t *testing.T) {
+	rec := httptest.NewRecorder()
+	data := struct {
+		Name  string   `yaml:"name"`
+		Roles []string `yaml:"roles"`
+	}{Name: "sqlwarden", Roles: []string{"admin"}}
+	if err := YAML(rec, http.StatusOK, data); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("unexpected content type: %s", rec.Header().Get("Content-Type"))
+	}
+	if got, want := rec.Body.String(), "name: sqlwarden\nroles:\n  - admin\n"; got != want {
+		t.Fatalf("unexpected body: %q", got)
+	}
+}
@@ -0,0 +1,7 @@
+// Package scim holds the SCIM 2.0 (RFC 7643, RFC 7644) wire types used by the
+// provisioning endpoints: resource and list representations, error bodies,
+// the equality filters identity providers send, and PATCH operations.
+//
+// It knows nothing about accounts or teams; internal/web maps SCIM Users and
+// Groups onto them.
+package scim
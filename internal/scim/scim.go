@@ -0,0 +1,239 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema URNs used by the resources and messages this package encodes.
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Error types (scimType) from RFC 7644 section 3.12.
+const (
+	ErrorInvalidFilter = "invalidFilter"
+	ErrorUniqueness    = "uniqueness"
+	ErrorMutability    = "mutability"
+	ErrorInvalidSyntax = "invalidSyntax"
+	ErrorInvalidPath   = "invalidPath"
+	ErrorNoTarget      = "noTarget"
+	ErrorInvalidValue  = "invalidValue"
+)
+
+// Error is a SCIM error response body. Status is a string on the wire.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewError builds an error body for an HTTP status.
+func NewError(status int, scimType, detail string) Error {
+	return Error{Schemas: []string{SchemaError}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail}
+}
+
+// Error returns the detail, so an Error can be returned as an error and
+// written as the response body.
+func (e Error) Error() string {
+	return e.Detail
+}
+
+// Meta is the common resource metadata.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// Name is the structured name of a User.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Display returns the formatted name, or the given and family names joined.
+func (n *Name) Display() string {
+	if n == nil {
+		return ""
+	}
+	if formatted := strings.TrimSpace(n.Formatted); formatted != "" {
+		return formatted
+	}
+	return strings.TrimSpace(strings.TrimSpace(n.GivenName) + " " + strings.TrimSpace(n.FamilyName))
+}
+
+// Email is one entry of a User's emails.
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// User is the core User resource, limited to the attributes SQLWarden keeps.
+// Active is a pointer so a request that omits it can be told apart from one
+// that deactivates the user.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email, or the first one when none is
+// marked primary.
+func (u User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// Member is a reference from a Group to one of its members.
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Group is the core Group resource. Members is omitted when the client asks
+// to exclude it.
+type Group struct {
+	Schemas     []string  `json:"schemas"`
+	ID          string    `json:"id,omitempty"`
+	ExternalID  string    `json:"externalId,omitempty"`
+	DisplayName string    `json:"displayName"`
+	Members     *[]Member `json:"members,omitempty"`
+	Meta        *Meta     `json:"meta,omitempty"`
+}
+
+// ListResponse is a page of query results.
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []T      `json:"Resources"`
+}
+
+// NewListResponse wraps one page of resources. startIndex is 1-based.
+func NewListResponse[T any](resources []T, total, startIndex int) ListResponse[T] {
+	if resources == nil {
+		resources = []T{}
+	}
+	return ListResponse[T]{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// ErrUnsupportedFilter reports a filter expression outside the supported
+// subset.
+var ErrUnsupportedFilter = errors.New("scim: unsupported filter")
+
+// Filter is a single equality comparison, the only filter form identity
+// providers use to look up existing resources.
+type Filter struct {
+	Attribute string
+	Value     string
+}
+
+// ParseFilter parses `attribute eq "value"`. Attribute names are matched
+// case-insensitively and returned lower-cased, as RFC 7644 requires. An empty
+// expression yields a zero Filter.
+func ParseFilter(expression string) (Filter, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return Filter{}, nil
+	}
+	attribute, rest, ok := strings.Cut(expression, " ")
+	if !ok {
+		return Filter{}, ErrUnsupportedFilter
+	}
+	operator, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(operator, "eq") {
+		return Filter{}, ErrUnsupportedFilter
+	}
+	unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil {
+		return Filter{}, ErrUnsupportedFilter
+	}
+	return Filter{Attribute: strings.ToLower(attribute), Value: unquoted}, nil
+}
+
+// PatchRequest is the body of a PATCH request.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is one add, remove or replace operation. Value stays raw
+// because its shape depends on Path.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Operation returns the lower-cased operation name; some providers send
+// "Replace" or "Add".
+func (o PatchOperation) Operation() string {
+	return strings.ToLower(o.Op)
+}
+
+// BoolValue decodes a boolean value, accepting the "True"/"False" strings
+// some providers send for active.
+func BoolValue(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return false, fmt.Errorf("scim: value is not a boolean")
+	}
+	return strconv.ParseBool(s)
+}
+
+// MemberPathFilter extracts the member id from a path of the form
+// `members[value eq "id"]`. ok is false for any other path.
+func MemberPathFilter(path string) (string, bool) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(strings.ToLower(path), "members[") || !strings.HasSuffix(path, "]") {
+		return "", false
+	}
+	filter, err := ParseFilter(path[len("members[") : len(path)-1])
+	if err != nil || filter.Attribute != "value" {
+		return "", false
+	}
+	return filter.Value, true
+}
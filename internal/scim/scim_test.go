@@ -0,0 +1,72 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       Filter
+		wantErr    bool
+	}{
+		{``, Filter{}, false},
+		{`userName eq "ada@example.com"`, Filter{Attribute: "username", Value: "ada@example.com"}, false},
+		{`externalId EQ "00u1 abc"`, Filter{Attribute: "externalid", Value: "00u1 abc"}, false},
+		{`displayName eq "Data \"Platform\""`, Filter{Attribute: "displayname", Value: `Data "Platform"`}, false},
+		{`userName sw "ada"`, Filter{}, true},
+		{`userName eq ada`, Filter{}, true},
+		{`userName eq "a" and active eq true`, Filter{}, true},
+		{`userName`, Filter{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.expression)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsupportedFilter) {
+				t.Errorf("ParseFilter(%q) error = %v; want ErrUnsupportedFilter", tt.expression, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseFilter(%q) = %+v, %v; want %+v", tt.expression, got, err, tt.want)
+		}
+	}
+}
+
+func TestMemberPathFilter(t *testing.T) {
+	if id, ok := MemberPathFilter(`members[value eq "42"]`); !ok || id != "42" {
+		t.Errorf("MemberPathFilter = %q, %v; want 42, true", id, ok)
+	}
+	for _, path := range []string{"members", `members[display eq "x"]`, `emails[value eq "x"]`, "displayName"} {
+		if _, ok := MemberPathFilter(path); ok {
+			t.Errorf("MemberPathFilter(%q) matched", path)
+		}
+	}
+}
+
+func TestBoolValueAcceptsStrings(t *testing.T) {
+	for raw, want := range map[string]bool{`true`: true, `false`: false, `"True"`: true, `"False"`: false} {
+		got, err := BoolValue(json.RawMessage(raw))
+		if err != nil || got != want {
+			t.Errorf("BoolValue(%s) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+	if _, err := BoolValue(json.RawMessage(`"maybe"`)); err == nil {
+		t.Error("BoolValue accepted a non-boolean string")
+	}
+}
+
+func TestNameDisplay(t *testing.T) {
+	if got := (&Name{GivenName: "Ada", FamilyName: "Lovelace"}).Display(); got != "Ada Lovelace" {
+		t.Errorf("Display = %q", got)
+	}
+	if got := (&Name{Formatted: "Countess Lovelace", GivenName: "Ada"}).Display(); got != "Countess Lovelace" {
+		t.Errorf("Display = %q", got)
+	}
+	var missing *Name
+	if got := missing.Display(); got != "" {
+		t.Errorf("nil Display = %q", got)
+	}
+}
@@ -0,0 +1,7 @@
+// Package secrets resolves connection credentials that live outside SQLWarden.
+// A connection stores a reference such as "vault:database/creds/readonly"
+// instead of a password, and the reference is resolved each time a connection
+// is opened. Providers read environment variables, files under a mounted
+// directory, or a HashiCorp Vault-compatible HTTP API. Dynamic secrets come
+// with a lease that a Keeper renews until the connection closes.
+package secrets
@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Env reads passwords from environment variables of the SQLWarden process.
+// Only variables starting with the configured prefix can be referenced, so a
+// connection cannot read the instance's own settings, such as its encryption
+// key, and send them to a database it controls.
+type Env struct {
+	prefix string
+	lookup func(string) (string, bool)
+}
+
+func NewEnv(prefix string) (*Env, error) {
+	if prefix == "" {
+		return nil, errors.New("env secret prefix is required")
+	}
+	return &Env{prefix: prefix, lookup: os.LookupEnv}, nil
+}
+
+func (e *Env) Check(ref Reference) error {
+	if ref.Key != "" {
+		return errors.New("env references do not take a #key")
+	}
+	if !strings.HasPrefix(ref.Path, e.prefix) || ref.Path == e.prefix {
+		return fmt.Errorf("environment variable must start with %q", e.prefix)
+	}
+	return nil
+}
+
+func (e *Env) Resolve(_ context.Context, ref Reference) (Credential, error) {
+	value, ok := e.lookup(ref.Path)
+	if !ok || value == "" {
+		return Credential{}, fmt.Errorf("environment variable %s is not set", ref.Path)
+	}
+	return Credential{Password: value}, nil
+}
@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File reads passwords from files under a root directory, such as a mounted
+// Kubernetes or Docker secret. References name a path relative to the root and
+// cannot leave it, through ".." or symbolic links. A trailing newline is
+// dropped.
+type File struct {
+	rootDir string
+}
+
+func NewFile(rootDir string) (*File, error) {
+	if rootDir == "" {
+		return nil, errors.New("file secret root directory is required")
+	}
+	return &File{rootDir: rootDir}, nil
+}
+
+func (f *File) Check(ref Reference) error {
+	if ref.Key != "" {
+		return errors.New("file references do not take a #key")
+	}
+	if !filepath.IsLocal(ref.Path) {
+		return errors.New("file path must be relative to the secrets directory")
+	}
+	return nil
+}
+
+func (f *File) Resolve(_ context.Context, ref Reference) (Credential, error) {
+	// The root is opened per read so secrets mounted or rotated after
+	// startup are picked up.
+	root, err := os.OpenRoot(f.rootDir)
+	if err != nil {
+		return Credential{}, err
+	}
+	defer root.Close()
+	data, err := root.ReadFile(ref.Path)
+	if err != nil {
+		return Credential{}, fmt.Errorf("read secret file: %w", err)
+	}
+	password := strings.TrimRight(string(data), "\r\n")
+	if password == "" {
+		return Credential{}, fmt.Errorf("secret file %s is empty", ref.Path)
+	}
+	return Credential{Password: password}, nil
+}
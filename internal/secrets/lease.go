@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	minRenewInterval = 100 * time.Millisecond
+	revokeTimeout    = 10 * time.Second
+)
+
+// Keeper holds a leased credential for as long as the connection using it is
+// open: it renews the lease in the background and revokes it on Close.
+type Keeper struct {
+	manager LeaseManager
+	leaseID string
+	logger  *slog.Logger
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// Keep starts keeping cred's lease. It returns nil when cred has no lease or
+// its provider cannot manage leases; closing a nil Keeper does nothing.
+func (r *Resolver) Keep(cred Credential) *Keeper {
+	if cred.Lease == nil || cred.Lease.ID == "" {
+		return nil
+	}
+	manager, ok := r.providers[cred.Lease.Provider].(LeaseManager)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	k := &Keeper{
+		manager: manager,
+		leaseID: cred.Lease.ID,
+		logger:  r.logger.With(slog.String("secret_provider", cred.Lease.Provider)),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go k.run(ctx, *cred.Lease)
+	return k
+}
+
+// run renews the lease once two thirds of its TTL have passed. A failed
+// renewal is retried at half the remaining time until the lease expires.
+func (k *Keeper) run(ctx context.Context, lease Lease) {
+	defer close(k.done)
+	increment := lease.TTL
+	expires := time.Now().Add(lease.TTL)
+	wait := lease.TTL * 2 / 3
+	for lease.Renewable {
+		timer := time.NewTimer(max(wait, minRenewInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		renewed, err := k.manager.Renew(ctx, k.leaseID, increment)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			remaining := time.Until(expires)
+			if remaining <= 0 {
+				k.logger.Warn("secret lease expired after failed renewals", slog.String("error", err.Error()))
+				return
+			}
+			k.logger.Warn("secret lease renewal failed", slog.String("error", err.Error()), slog.Duration("remaining", remaining))
+			wait = remaining / 2
+			continue
+		}
+		lease = renewed
+		if lease.TTL <= 0 {
+			// The lease reached its maximum TTL and cannot grow further.
+			return
+		}
+		expires = time.Now().Add(lease.TTL)
+		wait = lease.TTL * 2 / 3
+	}
+}
+
+// Close stops renewing and revokes the lease. It is safe to call more than
+// once and on a nil Keeper.
+func (k *Keeper) Close() error {
+	if k == nil {
+		return nil
+	}
+	k.once.Do(func() {
+		k.cancel()
+		<-k.done
+		ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+		defer cancel()
+		k.err = k.manager.Revoke(ctx, k.leaseID)
+	})
+	return k.err
+}
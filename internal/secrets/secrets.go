@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Provider names used as the reference prefix.
+const (
+	ProviderEnv   = "env"
+	ProviderFile  = "file"
+	ProviderVault = "vault"
+)
+
+var (
+	ErrInvalidReference = errors.New("invalid secret reference")
+	ErrUnknownProvider  = errors.New("secret provider is not configured")
+)
+
+var providerNameRX = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Reference points at a secret: "<provider>:<path>" with an optional
+// "#<key>" selecting one field of a structured secret.
+type Reference struct {
+	Provider string
+	Path     string
+	Key      string
+}
+
+func ParseReference(s string) (Reference, error) {
+	provider, rest, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || !providerNameRX.MatchString(provider) {
+		return Reference{}, fmt.Errorf("%w: must look like <provider>:<path>", ErrInvalidReference)
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	if path == "" {
+		return Reference{}, fmt.Errorf("%w: path is required", ErrInvalidReference)
+	}
+	if strings.Contains(rest, "#") && key == "" {
+		return Reference{}, fmt.Errorf("%w: key after # is empty", ErrInvalidReference)
+	}
+	return Reference{Provider: provider, Path: path, Key: key}, nil
+}
+
+func (r Reference) String() string {
+	if r.Key == "" {
+		return r.Provider + ":" + r.Path
+	}
+	return r.Provider + ":" + r.Path + "#" + r.Key
+}
+
+// Credential is a resolved secret. User is empty when the secret only holds a
+// password; callers keep their configured user then.
+type Credential struct {
+	User     string
+	Password string
+	// Lease is set for dynamic secrets that expire unless renewed.
+	Lease *Lease
+}
+
+// Lease identifies a dynamic secret at the provider that issued it.
+type Lease struct {
+	Provider  string
+	ID        string
+	TTL       time.Duration
+	Renewable bool
+}
+
+// Provider resolves references addressed to it. Resolve fails rather than
+// return an empty password.
+type Provider interface {
+	// Check reports whether ref is acceptable to this provider without
+	// reading the secret, so references can be validated when saved.
+	Check(ref Reference) error
+	Resolve(ctx context.Context, ref Reference) (Credential, error)
+}
+
+// LeaseManager is implemented by providers that issue leased secrets.
+type LeaseManager interface {
+	// Renew extends the lease by about increment and returns its new state.
+	Renew(ctx context.Context, leaseID string, increment time.Duration) (Lease, error)
+	Revoke(ctx context.Context, leaseID string) error
+}
+
+// Resolver dispatches references to the configured providers. It is safe for
+// concurrent use once providers are registered.
+type Resolver struct {
+	providers map[string]Provider
+	logger    *slog.Logger
+}
+
+func NewResolver(logger *slog.Logger) *Resolver {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Resolver{providers: map[string]Provider{}, logger: logger}
+}
+
+// Register makes p handle references whose provider is name.
+func (r *Resolver) Register(name string, p Provider) {
+	r.providers[name] = p
+}
+
+// Providers returns the registered provider names, sorted.
+func (r *Resolver) Providers() []string {
+	return slices.Sorted(maps.Keys(r.providers))
+}
+
+// Check parses ref and asks its provider to validate it.
+func (r *Resolver) Check(ref string) error {
+	_, _, err := r.lookup(ref)
+	return err
+}
+
+// Resolve reads the secret ref points at. The credential's lease, if any, is
+// attributed to the provider so Keep can renew it.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (Credential, error) {
+	parsed, provider, err := r.lookup(ref)
+	if err != nil {
+		return Credential{}, err
+	}
+	cred, err := provider.Resolve(ctx, parsed)
+	if err != nil {
+		return Credential{}, fmt.Errorf("resolve %s secret: %w", parsed.Provider, err)
+	}
+	if cred.Lease != nil {
+		cred.Lease.Provider = parsed.Provider
+	}
+	return cred, nil
+}
+
+func (r *Resolver) lookup(ref string) (Reference, Provider, error) {
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return Reference{}, nil, err
+	}
+	provider, ok := r.providers[parsed.Provider]
+	if !ok {
+		return Reference{}, nil, fmt.Errorf("%w: %q", ErrUnknownProvider, parsed.Provider)
+	}
+	if err := provider.Check(parsed); err != nil {
+		return Reference{}, nil, fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	}
+	return parsed, provider, nil
+}
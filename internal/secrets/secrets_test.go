@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	ref, err := ParseReference("vault:secret/data/app/db#password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != (Reference{Provider: "vault", Path: "secret/data/app/db", Key: "password"}) {
+		t.Fatalf("ParseReference = %+v", ref)
+	}
+	if ref.String() != "vault:secret/data/app/db#password" {
+		t.Fatalf("String() = %q", ref.String())
+	}
+	for _, s := range []string{"", "nopath", "env:", "Vault:x", "vault:x#"} {
+		if _, err := ParseReference(s); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("ParseReference(%q) = %v, want ErrInvalidReference", s, err)
+		}
+	}
+}
+
+func TestResolverRejectsUnconfiguredProviders(t *testing.T) {
+	resolver := NewResolver(nil)
+	if err := resolver.Check("vault:database/creds/app"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("Check = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestEnvOnlyReadsPrefixedVariables(t *testing.T) {
+	env, err := NewEnv("SQLWARDEN_SECRET_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.lookup = func(name string) (string, bool) {
+		if name == "SQLWARDEN_SECRET_DB" {
+			return "s3cret", true
+		}
+		return "", false
+	}
+	resolver := NewResolver(nil)
+	resolver.Register(ProviderEnv, env)
+
+	cred, err := resolver.Resolve(context.Background(), "env:SQLWARDEN_SECRET_DB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "s3cret" || cred.User != "" || cred.Lease != nil {
+		t.Fatalf("Resolve = %+v", cred)
+	}
+	for _, ref := range []string{"env:ENCRYPTION_KEY", "env:SQLWARDEN_SECRET_", "env:SQLWARDEN_SECRET_DB#key"} {
+		if err := resolver.Check(ref); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("Check(%q) = %v, want ErrInvalidReference", ref, err)
+		}
+	}
+	if _, err := resolver.Resolve(context.Background(), "env:SQLWARDEN_SECRET_MISSING"); err == nil {
+		t.Fatal("Resolve of an unset variable succeeded")
+	}
+}
+
+func TestFileStaysInsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "secrets")
+	if err := os.MkdirAll(filepath.Join(root, "db"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "db", "password"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "outside"), []byte("private"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewFile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewResolver(nil)
+	resolver.Register(ProviderFile, file)
+
+	cred, err := resolver.Resolve(context.Background(), "file:db/password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "s3cret" {
+		t.Fatalf("Password = %q, want the trailing newline dropped", cred.Password)
+	}
+	for _, ref := range []string{"file:../outside", "file:" + filepath.Join(dir, "outside")} {
+		if err := resolver.Check(ref); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("Check(%q) = %v, want ErrInvalidReference", ref, err)
+		}
+	}
+	if _, err := resolver.Resolve(context.Background(), "file:link"); err == nil {
+		t.Fatal("Resolve followed a symbolic link out of the root")
+	}
+}
@@ -0,0 +1,202 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderVaultToken     = "X-Vault-Token"
+	HeaderVaultNamespace = "X-Vault-Namespace"
+
+	defaultVaultTimeout = 10 * time.Second
+	maxVaultErrorBody   = 512
+)
+
+type VaultConfig struct {
+	// Address is the base URL of the Vault API, without /v1.
+	Address string
+	// Token authenticates requests. TokenFile, when set instead, is read on
+	// every request so a token renewed by a Vault Agent sidecar is picked up.
+	Token     string
+	TokenFile string
+	Namespace string
+	Timeout   time.Duration
+	Client    *http.Client
+}
+
+// Vault reads secrets over the HashiCorp Vault HTTP API. A reference path is
+// the API path after /v1/: "database/creds/readonly" for a dynamic database
+// secret, or "secret/data/app/db" for a KV version 2 secret. Without a #key the
+// secret's "username" and "password" fields are used; with one, that field is
+// the password.
+type Vault struct {
+	address   string
+	token     string
+	tokenFile string
+	namespace string
+	client    *http.Client
+}
+
+var _ LeaseManager = (*Vault)(nil)
+
+func NewVault(cfg VaultConfig) (*Vault, error) {
+	u, err := url.Parse(cfg.Address)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, errors.New("vault address must be an absolute http or https URL")
+	}
+	if (cfg.Token == "") == (cfg.TokenFile == "") {
+		return nil, errors.New("exactly one of vault token or token file is required")
+	}
+	client := cfg.Client
+	if client == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = defaultVaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	return &Vault{
+		address:   strings.TrimRight(cfg.Address, "/"),
+		token:     cfg.Token,
+		tokenFile: cfg.TokenFile,
+		namespace: cfg.Namespace,
+		client:    client,
+	}, nil
+}
+
+func (v *Vault) Check(ref Reference) error {
+	if strings.HasPrefix(ref.Path, "/") || strings.HasSuffix(ref.Path, "/") {
+		return errors.New("vault path must not start or end with /")
+	}
+	for segment := range strings.SplitSeq(ref.Path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return errors.New("vault path has an empty or relative segment")
+		}
+	}
+	if strings.ContainsAny(ref.Path, "?%") {
+		return errors.New("vault path must not contain ? or %")
+	}
+	return nil
+}
+
+// vaultSecret is the response envelope shared by reads and lease renewals.
+type vaultSecret struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+}
+
+func (v *Vault) Resolve(ctx context.Context, ref Reference) (Credential, error) {
+	var secret vaultSecret
+	if err := v.do(ctx, http.MethodGet, ref.Path, nil, &secret); err != nil {
+		return Credential{}, err
+	}
+	data := secret.Data
+	// KV version 2 nests the secret under data.data, next to data.metadata.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = inner
+		}
+	}
+
+	var cred Credential
+	if ref.Key != "" {
+		cred.Password, _ = data[ref.Key].(string)
+		if cred.Password == "" {
+			return Credential{}, fmt.Errorf("vault secret has no string field %q", ref.Key)
+		}
+	} else {
+		cred.User, _ = data["username"].(string)
+		cred.Password, _ = data["password"].(string)
+		if cred.Password == "" {
+			return Credential{}, errors.New(`vault secret has no string field "password"`)
+		}
+	}
+	if secret.LeaseID != "" {
+		cred.Lease = &Lease{
+			ID:        secret.LeaseID,
+			TTL:       time.Duration(secret.LeaseDuration) * time.Second,
+			Renewable: secret.Renewable,
+		}
+	}
+	return cred, nil
+}
+
+func (v *Vault) Renew(ctx context.Context, leaseID string, increment time.Duration) (Lease, error) {
+	body := map[string]any{"lease_id": leaseID, "increment": int(increment.Seconds())}
+	var secret vaultSecret
+	if err := v.do(ctx, http.MethodPut, "sys/leases/renew", body, &secret); err != nil {
+		return Lease{}, err
+	}
+	return Lease{ID: leaseID, TTL: time.Duration(secret.LeaseDuration) * time.Second, Renewable: secret.Renewable}, nil
+}
+
+func (v *Vault) Revoke(ctx context.Context, leaseID string) error {
+	return v.do(ctx, http.MethodPut, "sys/leases/revoke", map[string]any{"lease_id": leaseID}, nil)
+}
+
+func (v *Vault) do(ctx context.Context, method, path string, body any, out any) error {
+	token := v.token
+	if v.tokenFile != "" {
+		data, err := os.ReadFile(v.tokenFile)
+		if err != nil {
+			return fmt.Errorf("read vault token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.address+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderVaultToken, token)
+	if v.namespace != "" {
+		req.Header.Set(HeaderVaultNamespace, v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(res.Body, maxVaultErrorBody))
+		var payload struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(snippet, &payload) == nil && len(payload.Errors) > 0 {
+			return fmt.Errorf("vault returned %d: %s", res.StatusCode, strings.Join(payload.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %d", res.StatusCode)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode vault response: %w", err)
+	}
+	return nil
+}
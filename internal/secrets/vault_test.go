@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// vaultStub serves the subset of the Vault API the provider uses: a KV
+// version 2 secret, a dynamic database secret, and lease renewal and
+// revocation.
+type vaultStub struct {
+	mu      sync.Mutex
+	renewed int
+	revoked []string
+}
+
+func (s *vaultStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(HeaderVaultToken) != "test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/secret/data/app/db":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"lease_id": "",
+			"data": map[string]any{
+				"data":     map[string]any{"username": "app", "password": "kv-pass", "api_key": "k"},
+				"metadata": map[string]any{"version": 3},
+			},
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/database/creds/readonly":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"lease_id":       "database/creds/readonly/abc",
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]any{"username": "v-token-readonly-x", "password": "dyn-pass"},
+		})
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/renew":
+		s.renewed++
+		_ = json.NewEncoder(w).Encode(map[string]any{"lease_id": "database/creds/readonly/abc", "lease_duration": 1, "renewable": true})
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/revoke":
+		var body struct {
+			LeaseID string `json:"lease_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.revoked = append(s.revoked, body.LeaseID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+	}
+}
+
+func newTestVaultResolver(t *testing.T, stub *vaultStub, token string) *Resolver {
+	t.Helper()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	vault, err := NewVault(VaultConfig{Address: server.URL, Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewResolver(nil)
+	resolver.Register(ProviderVault, vault)
+	return resolver
+}
+
+func TestVaultReadsKVSecrets(t *testing.T) {
+	resolver := newTestVaultResolver(t, &vaultStub{}, "test-token")
+	ctx := context.Background()
+
+	cred, err := resolver.Resolve(ctx, "vault:secret/data/app/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.User != "app" || cred.Password != "kv-pass" || cred.Lease != nil {
+		t.Fatalf("Resolve = %+v", cred)
+	}
+	cred, err = resolver.Resolve(ctx, "vault:secret/data/app/db#api_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.User != "" || cred.Password != "k" {
+		t.Fatalf("Resolve with a key = %+v", cred)
+	}
+	if _, err := resolver.Resolve(ctx, "vault:secret/data/app/db#missing"); err == nil {
+		t.Fatal("Resolve of a missing key succeeded")
+	}
+	if err := resolver.Check("vault:secret/../sys/leases"); err == nil {
+		t.Fatal("Check accepted a relative path segment")
+	}
+}
+
+func TestVaultReportsErrors(t *testing.T) {
+	resolver := newTestVaultResolver(t, &vaultStub{}, "wrong-token")
+	_, err := resolver.Resolve(context.Background(), "vault:secret/data/app/db")
+	if err == nil || !strings.Contains(err.Error(), "403: permission denied") {
+		t.Fatalf("Resolve = %v, want the Vault error", err)
+	}
+}
+
+func TestKeeperRenewsAndRevokesDynamicSecrets(t *testing.T) {
+	stub := &vaultStub{}
+	resolver := newTestVaultResolver(t, stub, "test-token")
+
+	cred, err := resolver.Resolve(context.Background(), "vault:database/creds/readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.User != "v-token-readonly-x" || cred.Lease == nil || cred.Lease.Provider != ProviderVault || cred.Lease.TTL != time.Second {
+		t.Fatalf("Resolve = %+v", cred)
+	}
+
+	keeper := resolver.Keep(cred)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stub.mu.Lock()
+		renewed := stub.renewed
+		stub.mu.Unlock()
+		if renewed >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lease renewed %d times, want at least 2", renewed)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := keeper.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := keeper.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.revoked) != 1 || stub.revoked[0] != "database/creds/readonly/abc" {
+		t.Fatalf("revoked = %v, want the lease revoked once", stub.revoked)
+	}
+
+	var none *Keeper
+	if err := none.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// APITokenPrefix marks personal access and service account tokens so they can
+// be told apart from JWT access tokens and recognised by secret scanners.
+const APITokenPrefix = "sqlw_"
+
+// apiTokenDisplayLength is how much of a token is kept in plaintext so users
+// can recognise it in listings.
+const apiTokenDisplayLength = len(APITokenPrefix) + 8
+
+// GenerateAPIToken creates a prefixed, cryptographically random API token.
+// Returns: (plaintext, sha256HashHex, displayPrefix, error)
+func GenerateAPIToken() (string, string, string, error) {
+	secret, _, err := Generate()
+	if err != nil {
+		return "", "", "", fmt.Errorf("token: generate api token: %w", err)
+	}
+	plain := APITokenPrefix + secret
+	return plain, Hash(plain), plain[:apiTokenDisplayLength], nil
+}
+
+// IsAPIToken reports whether value has the API token prefix.
+func IsAPIToken(value string) bool {
+	return strings.HasPrefix(value, APITokenPrefix)
+}
+
+// SCIMTokenPrefix marks organization SCIM tokens. It extends APITokenPrefix so
+// secret scanners that recognise API tokens recognise these too.
+const SCIMTokenPrefix = APITokenPrefix + "scim_"
+
+// GenerateSCIMToken creates a prefixed, cryptographically random SCIM token.
+// Returns: (plaintext, sha256HashHex, displayPrefix, error)
+func GenerateSCIMToken() (string, string, string, error) {
+	secret, _, err := Generate()
+	if err != nil {
+		return "", "", "", fmt.Errorf("token: generate scim token: %w", err)
+	}
+	plain := SCIMTokenPrefix + secret
+	return plain, Hash(plain), plain[:len(SCIMTokenPrefix)+8], nil
+}
+
+// IsSCIMToken reports whether value has the SCIM token prefix.
+func IsSCIMToken(value string) bool {
+	return strings.HasPrefix(value, SCIMTokenPrefix)
+}
+
+// AgentTokenPrefix marks connector agent registration tokens.
+const AgentTokenPrefix = APITokenPrefix + "agent_"
+
+// GenerateAgentToken creates a prefixed, cryptographically random connector
+// agent registration token.
+// Returns: (plaintext, sha256HashHex, displayPrefix, error)
+func GenerateAgentToken() (string, string, string, error) {
+	secret, _, err := Generate()
+	if err != nil {
+		return "", "", "", fmt.Errorf("token: generate agent token: %w", err)
+	}
+	plain := AgentTokenPrefix + secret
+	return plain, Hash(plain), plain[:len(AgentTokenPrefix)+8], nil
+}
+
+// IsAgentToken reports whether value has the connector agent token prefix.
+func IsAgentToken(value string) bool {
+	return strings.HasPrefix(value, AgentTokenPrefix)
+}
@@ -0,0 +1,92 @@
+package token
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIToken(t *testing.T) {
+	plain, hash, prefix, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken error: %v", err)
+	}
+	if !IsAPIToken(plain) {
+		t.Errorf("plaintext %q does not carry the API token prefix", plain)
+	}
+	// prefix + 32 bytes = 64 hex chars
+	if len(plain) != len(APITokenPrefix)+64 {
+		t.Errorf("plaintext length = %d; want %d", len(plain), len(APITokenPrefix)+64)
+	}
+	if hash != Hash(plain) {
+		t.Error("hash does not match Hash(plaintext)")
+	}
+	if !strings.HasPrefix(plain, prefix) || len(prefix) != apiTokenDisplayLength {
+		t.Errorf("display prefix = %q; want the first %d characters of the token", prefix, apiTokenDisplayLength)
+	}
+
+	other, _, _, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken error: %v", err)
+	}
+	if plain == other {
+		t.Error("GenerateAPIToken produced duplicate plaintexts on successive calls")
+	}
+}
+
+func TestIsAPITokenRejectsJWT(t *testing.T) {
+	tokenStr, _, err := Issue("acc-1", "user@example.com", "User", testSecret)
+	if err != nil {
+		t.Fatalf("Issue returned error: %v", err)
+	}
+	if IsAPIToken(tokenStr) {
+		t.Error("IsAPIToken accepted a JWT access token")
+	}
+}
+
+func TestGenerateSCIMToken(t *testing.T) {
+	plain, hash, prefix, err := GenerateSCIMToken()
+	if err != nil {
+		t.Fatalf("GenerateSCIMToken error: %v", err)
+	}
+	if !IsSCIMToken(plain) {
+		t.Errorf("plaintext %q does not carry the SCIM token prefix", plain)
+	}
+	if hash != Hash(plain) {
+		t.Error("hash does not match Hash(plaintext)")
+	}
+	if !strings.HasPrefix(plain, prefix) || len(prefix) != len(SCIMTokenPrefix)+8 {
+		t.Errorf("display prefix = %q; want the first %d characters of the token", prefix, len(SCIMTokenPrefix)+8)
+	}
+
+	apiToken, _, _, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsSCIMToken(apiToken) {
+		t.Error("IsSCIMToken accepted an API token")
+	}
+}
+
+func TestGenerateAgentToken(t *testing.T) {
+	plain, hash, prefix, err := GenerateAgentToken()
+	if err != nil {
+		t.Fatalf("GenerateAgentToken error: %v", err)
+	}
+	if !IsAgentToken(plain) {
+		t.Errorf("plaintext %q does not carry the agent token prefix", plain)
+	}
+	if hash != Hash(plain) {
+		t.Error("hash does not match Hash(plaintext)")
+	}
+	if !strings.HasPrefix(plain, prefix) || len(prefix) != len(AgentTokenPrefix)+8 {
+		t.Errorf("display prefix = %q; want the first %d characters of the token", prefix, len(AgentTokenPrefix)+8)
+	}
+
+	scimToken, _, _, err := GenerateSCIMToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsAgentToken(scimToken) {
+		t.Error("IsAgentToken accepted a SCIM token")
+	}
+}
@@ -0,0 +1,5 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with the
+// parameters authenticator apps assume: HMAC-SHA1, six digits and a 30 second
+// period. It also generates the single-use recovery codes handed out at
+// enrollment.
+package totp
@@ -0,0 +1,98 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from RFC 6238 appendix B, base32-encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil || got != tt.want {
+			t.Errorf("Code at %d = %q, %v; want %q", tt.unix, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateAcceptsSkewAndRejectsReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	previous, _ := Code(rfcSecret, Step(now)-1)
+
+	step, ok := Validate(rfcSecret, previous, now, 0)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("Validate(previous) = %d, %v", step, ok)
+	}
+	if _, ok := Validate(rfcSecret, previous, now, step); ok {
+		t.Fatal("Validate accepted a replayed code")
+	}
+
+	old, _ := Code(rfcSecret, Step(now)-2)
+	if _, ok := Validate(rfcSecret, old, now, 0); ok {
+		t.Fatal("Validate accepted a code outside the skew window")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 0); ok {
+		t.Fatal("Validate accepted a short code")
+	}
+}
+
+func TestGenerateSecretRoundTrips(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("secret length = %d; want 32", len(secret))
+	}
+	code, err := Code(secret, Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(secret, code, time.Now(), 0); !ok {
+		t.Fatal("Validate rejected a fresh code")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("SQLWarden", "ada@example.com", rfcSecret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/SQLWarden:ada@example.com" {
+		t.Fatalf("URI = %q", uri)
+	}
+	if u.Query().Get("secret") != rfcSecret || u.Query().Get("issuer") != "SQLWarden" {
+		t.Fatalf("URI query = %q", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Fatalf("bad or duplicate code %q", code)
+		}
+		seen[code] = true
+		if got := NormalizeRecoveryCode(" " + strings.ToUpper(strings.ReplaceAll(code, "-", " "))); got != code {
+			t.Fatalf("NormalizeRecoveryCode = %q; want %q", got, code)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/agent"
 	"github.com/sqlwarden/internal/cache"
 	completionapp "github.com/sqlwarden/internal/completion"
 	"github.com/sqlwarden/internal/connection"
@@ -23,7 +24,10 @@ import (
 	"github.com/sqlwarden/internal/files"
 	"github.com/sqlwarden/internal/filestore"
 	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/oidc"
+	"github.com/sqlwarden/internal/ratelimit"
 	schemaapp "github.com/sqlwarden/internal/schema"
+	"github.com/sqlwarden/internal/secrets"
 	"github.com/sqlwarden/internal/smtp"
 )
 
@@ -32,33 +36,48 @@ const (
 	schemaCacheCapacity = 256
 	fileReaperInterval  = 15 * time.Minute
 	fileReaperRetry     = time.Minute
+
+	authzInvalidationRetryInterval = 5 * time.Second
 )
 
 type App = application
 
 type application struct {
-	config            Config
-	db                *database.DB
-	logger            *slog.Logger
-	mailer            *smtp.Mailer
-	mailerMu          sync.RWMutex
-	wg                sync.WaitGroup
-	connManager       *connection.Manager
-	queryCursors      *connection.QueryCursorManager
-	schemaService     *schemaapp.Service
-	schemaSnapshots   *schemaapp.SnapshotStore
-	completionService *completionapp.Service
-	keyring           *encrypt.Keyring
-	enforcer          *access.Enforcer
-	fileStores        *fileStoreRegistry
-	fileLocks         sync.Map
-	fileReaperCancel  context.CancelFunc
-	jobStore          *jobs.Store
-	jobRegistry       *jobs.Registry
-	runtimeCancel     context.CancelFunc
-	runtimeUpdates    chan database.InstanceSettings
-	runtimeSettings   *runtimeSettingsService
-	accessLogsEnabled atomic.Bool
+	config                  Config
+	db                      *database.DB
+	logger                  *slog.Logger
+	mailer                  *smtp.Mailer
+	mailerMu                sync.RWMutex
+	wg                      sync.WaitGroup
+	connManager             *connection.Manager
+	agentHub                *agent.Hub
+	queryCursors            *connection.QueryCursorManager
+	schemaService           *schemaapp.Service
+	schemaSnapshots         *schemaapp.SnapshotStore
+	completionService       *completionapp.Service
+	keyring                 *encrypt.Keyring
+	secrets                 *secrets.Resolver
+	oidc                    *oidc.Client
+	enforcer                *access.Enforcer
+	authzCache              *access.DistributedAuthorizationCache
+	authzInvalidationCancel context.CancelFunc
+	fileStores              *fileStoreRegistry
+	fileLocks               sync.Map
+	fileReaperCancel        context.CancelFunc
+	auditCheckpointCancel   context.CancelFunc
+	auditForwardCancel      context.CancelFunc
+	connectionHealthCancel  context.CancelFunc
+	replicaID               string
+	replicaHeartbeatCancel  context.CancelFunc
+	draining                atomic.Bool
+	jobStore                *jobs.Store
+	jobRegistry             *jobs.Registry
+	runtimeCancel           context.CancelFunc
+	runtimeUpdates          chan database.InstanceSettings
+	runtimeSettings         *runtimeSettingsService
+	accessLogsEnabled       atomic.Bool
+	rateLimiters            map[string]*ratelimit.Limiter
+	rateLimitersMu          sync.Mutex
 }
 
 type fileStoreRegistry struct {
@@ -137,7 +156,15 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		return nil, err
 	}
 
-	enforcer, err := access.New(db.DB)
+	// A SQLite database cannot be shared by replicas, so there is nothing to
+	// invalidate beyond this process.
+	var authzCache *access.DistributedAuthorizationCache
+	var enforcerCache access.AuthorizationCache = access.NewMemoryAuthorizationCache()
+	if transport := access.NewInvalidationTransport(db.DB, logger); transport != nil {
+		authzCache = access.NewDistributedAuthorizationCache(transport, logger)
+		enforcerCache = authzCache
+	}
+	enforcer, err := access.NewWithCache(db.DB, enforcerCache)
 	if err != nil {
 		db.Close()
 		return nil, fmt.Errorf("enforcer init: %w", err)
@@ -160,6 +187,15 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		return nil, fmt.Errorf("encryption keyring init: %w", err)
 	}
 
+	secretResolver, err := newSecretResolver(cfg, logger)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("secret providers init: %w", err)
+	}
+	if providers := secretResolver.Providers(); len(providers) > 0 {
+		logger.Info("secret providers configured", slog.Any("providers", providers))
+	}
+
 	snapshotStore := schemaapp.NewSnapshotStore(db)
 	app := &application{
 		config:            cfg,
@@ -167,16 +203,24 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		logger:            logger,
 		mailer:            smtp.NewDisabledMailer(""),
 		connManager:       connection.New(30 * time.Minute),
+		agentHub:          agent.NewHub(agent.DefaultHeartbeatInterval),
 		queryCursors:      connection.NewQueryCursorManager(30 * time.Minute),
 		schemaService:     schemaapp.NewServiceWithLogger(cache.NewMemCache(schemaCacheCapacity), schemaCacheTTL, logger),
 		schemaSnapshots:   snapshotStore,
 		completionService: completionapp.NewService(),
 		keyring:           keyring,
+		secrets:           secretResolver,
+		oidc:              oidc.NewClient(nil),
 		enforcer:          enforcer,
+		authzCache:        authzCache,
 		fileStores:        fileStores,
 		jobStore:          jobs.NewStore(db),
 		runtimeSettings:   newRuntimeSettingsService(db),
 		runtimeUpdates:    make(chan database.InstanceSettings, 1),
+		replicaID:         cfg.Cluster.ReplicaID,
+	}
+	if app.replicaID == "" {
+		app.replicaID = defaultReplicaID(cfg)
 	}
 	initialSettings, err := app.instanceSettings(context.Background())
 	if err != nil {
@@ -188,9 +232,16 @@ func New(cfg Config, logger *slog.Logger) (*App, error) {
 		return nil, err
 	}
 	app.configureConnectionCacheInvalidation()
+	app.configureLiveSessionRoutes()
+	app.backfillConnectionConfigs(context.Background())
 	app.jobRegistry = app.defaultJobRegistry()
 	app.startRuntimeSupervisor(initialSettings)
 	app.startFileContentDeletionReaper()
+	app.startAuditCheckpointer()
+	app.startAuditForwarder()
+	app.startConnectionHealthMonitor()
+	app.startAuthorizationInvalidation()
+	app.startReplicaHeartbeat()
 	return app, nil
 }
 
@@ -218,15 +269,33 @@ func (app *application) Close() error {
 	if app.fileReaperCancel != nil {
 		app.fileReaperCancel()
 	}
+	if app.auditCheckpointCancel != nil {
+		app.auditCheckpointCancel()
+	}
+	if app.auditForwardCancel != nil {
+		app.auditForwardCancel()
+	}
+	if app.connectionHealthCancel != nil {
+		app.connectionHealthCancel()
+	}
+	if app.authzInvalidationCancel != nil {
+		app.authzInvalidationCancel()
+	}
 	if app.runtimeCancel != nil {
 		app.runtimeCancel()
 	}
+	if app.replicaHeartbeatCancel != nil {
+		app.replicaHeartbeatCancel()
+	}
 	app.wg.Wait()
 	app.logger.Info("background workers stopped", "duration_ms", time.Since(startedAt).Milliseconds())
 
 	if app.queryCursors != nil {
 		app.queryCursors.Close()
 	}
+	if app.agentHub != nil {
+		app.agentHub.Close()
+	}
 	if app.connManager != nil {
 		connCloseStartedAt := time.Now()
 		app.connManager.Close()
@@ -288,6 +357,50 @@ func (app *application) defaultJobRegistry() *jobs.Registry {
 			return app.handleSchemaSyncJob(ctx, runtime)
 		}),
 	})
+	registry.Register(jobs.Definition{
+		Type:        jobs.TypeAuditCheckpoint,
+		MaxAttempts: 3,
+		Backoff: func(attempt int) time.Duration {
+			return time.Duration(attempt) * time.Minute
+		},
+		Handler: jobs.HandlerFunc(func(ctx context.Context, _ jobs.Runtime) (any, error) {
+			return app.handleAuditCheckpointJob(ctx)
+		}),
+	})
+	// A deployment target runs at most once; the handler queues the next
+	// target itself so that a failure stops the rest.
+	registry.Register(jobs.Definition{
+		Type:        jobs.TypeDeploySQL,
+		MaxAttempts: 1,
+		Handler: jobs.HandlerFunc(func(ctx context.Context, runtime jobs.Runtime) (any, error) {
+			return app.handleDeploymentJob(ctx, runtime)
+		}),
+	})
+	registry.Register(jobs.Definition{
+		Type:        jobs.TypeApplyMigrations,
+		MaxAttempts: 1,
+		Handler: jobs.HandlerFunc(func(ctx context.Context, runtime jobs.Runtime) (any, error) {
+			return app.handleMigrationJob(ctx, runtime)
+		}),
+	})
+	// Outbox entries carry their own retry schedule, so a failed forward job
+	// is not retried; the forwarder queues a fresh one once entries are due.
+	registry.Register(jobs.Definition{
+		Type:        jobs.TypeAuditForward,
+		MaxAttempts: 1,
+		Handler: jobs.HandlerFunc(func(ctx context.Context, runtime jobs.Runtime) (any, error) {
+			return app.handleAuditForwardJob(ctx, runtime)
+		}),
+	})
+	// Each run checks every opted-in connection once; the monitor queues the
+	// next run, so a failed one is not retried.
+	registry.Register(jobs.Definition{
+		Type:        jobs.TypeConnectionHealth,
+		MaxAttempts: 1,
+		Handler: jobs.HandlerFunc(func(ctx context.Context, _ jobs.Runtime) (any, error) {
+			return app.handleConnectionHealthJob(ctx)
+		}),
+	})
 	return registry
 }
 
@@ -314,6 +427,33 @@ func (app *application) startFileContentDeletionReaper() {
 	}()
 }
 
+// startAuthorizationInvalidation applies RBAC cache invalidations published by
+// other replicas. If the subscription fails it is retried; cached entries still
+// expire after the fallback TTL meanwhile.
+func (app *application) startAuthorizationInvalidation() {
+	if app.authzCache == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	app.authzInvalidationCancel = cancel
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.logger.Info("authorization cache invalidation started")
+		for {
+			if err := app.authzCache.Run(ctx); err != nil {
+				app.logger.ErrorContext(ctx, "authorization cache invalidation subscription failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				app.logger.Info("authorization cache invalidation stopped")
+				return
+			case <-time.After(authzInvalidationRetryInterval):
+			}
+		}
+	}()
+}
+
 func (app *application) enqueueFileContentReapJob(ctx context.Context) error {
 	if app.jobStore == nil {
 		app.jobStore = jobs.NewStore(app.db)
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const auditCheckpointInterval = 15 * time.Minute
+
+// auditStore signs with the current keyring, so records and checkpoints always
+// use the primary key while retired keys still verify. Appends also enqueue
+// outbox entries for every configured sink.
+func (app *application) auditStore() *audit.Store {
+	return audit.NewStore(app.db, app.keyring).ForwardTo(app.auditSinkIDs())
+}
+
+// recordAudit appends a tamper-evident audit record for an action that has
+// already taken effect. The acting account defaults to the authenticated
+// account. A failed append is logged rather than surfaced: the action cannot be
+// undone at this point, and the chain stays verifiable either way.
+func (app *application) recordAudit(r *http.Request, event audit.Event) {
+	if event.ActorAccountID == nil {
+		if account := contextGetAccount(r); account.ID != 0 {
+			event.ActorAccountID = &account.ID
+		}
+	}
+	record, err := app.auditStore().Append(r.Context(), event)
+	if err != nil {
+		app.logger.LogAttrs(r.Context(), slog.LevelError, "audit record append failed",
+			slog.Group("request", attrsToAny(app.requestAttrs(r))...),
+			slog.Group("resource", attrsToAny(resourceAttrs(r))...),
+			slog.String("audit.action", event.Action),
+			slog.Any("error", err),
+		)
+		return
+	}
+	app.logDebug(r, "audit record appended",
+		slog.String("audit.id", record.ID),
+		slog.String("audit.chain", record.Chain),
+		slog.Int64("audit.seq", record.Seq),
+		slog.String("audit.action", record.Action),
+	)
+}
+
+// orgAuditEvent builds an audit event scoped to the organization in the
+// request context.
+func orgAuditEvent(r *http.Request, action, resourceType string, resourceID int64, details any) audit.Event {
+	org := contextGetOrg(r)
+	return audit.Event{
+		OrgID:        &org.ID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   strconv.FormatInt(resourceID, 10),
+		Details:      details,
+	}
+}
+
+// workspaceAuditEvent builds an audit event for the workspace in the request
+// context. Personal workspaces have no organization and land on the instance
+// chain.
+func workspaceAuditEvent(r *http.Request, action, resourceType string, resourceID int64, details any) audit.Event {
+	ws := contextGetWorkspace(r)
+	return audit.Event{
+		OrgID:        ws.OrgID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   strconv.FormatInt(resourceID, 10),
+		Details:      details,
+	}
+}
+
+// instanceAuditEvent builds an audit event on the instance chain.
+func instanceAuditEvent(action, resourceType string, resourceID int64, details any) audit.Event {
+	return audit.Event{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   strconv.FormatInt(resourceID, 10),
+		Details:      details,
+	}
+}
+
+// verifyAuditChainsHandler walks the audit hash chains and reports the first
+// broken link in each. It is an instance-admin-only operation; org_id narrows
+// verification to a single organization's chain.
+func (app *application) verifyAuditChainsHandler(w http.ResponseWriter, r *http.Request) {
+	var orgID *int64
+	if raw := r.URL.Query().Get("org_id"); raw != "" {
+		var v validator.Validator
+		id, err := strconv.ParseInt(raw, 10, 64)
+		v.CheckField(err == nil && id > 0, "org_id", "Must be a positive integer.")
+		if v.HasErrors() {
+			app.failedValidation(w, r, v)
+			return
+		}
+		orgID = &id
+	}
+
+	report, err := app.VerifyAuditChains(r.Context(), orgID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := response.JSON(w, http.StatusOK, report); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// VerifyAuditChains verifies every audit chain, or only orgID's chain when
+// orgID is set. Records and checkpoints signed with retired keys verify as long
+// as those keys remain in ENCRYPTION_PREVIOUS_KEYS.
+func (app *application) VerifyAuditChains(ctx context.Context, orgID *int64) (audit.Report, error) {
+	var (
+		report audit.Report
+		err    error
+	)
+	if orgID != nil {
+		report, err = app.auditStore().VerifyOrg(ctx, *orgID)
+	} else {
+		report, err = app.auditStore().VerifyAll(ctx)
+	}
+	if err != nil {
+		return audit.Report{}, err
+	}
+	for _, chain := range report.Chains {
+		if chain.FirstBreak != nil {
+			app.logger.WarnContext(ctx, "audit chain verification failed",
+				"audit.chain", chain.Chain,
+				"audit.seq", chain.FirstBreak.Seq,
+				"audit.reason", chain.FirstBreak.Reason,
+			)
+		}
+	}
+	app.logger.InfoContext(ctx, "audit chain verification complete", "chains", len(report.Chains), "valid", report.Valid)
+	return report, nil
+}
+
+func (app *application) startAuditCheckpointer() {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.auditCheckpointCancel = cancel
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.logger.Info("audit checkpointer started")
+		ticker := time.NewTicker(auditCheckpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				app.logger.Info("audit checkpointer stopped")
+				return
+			case <-ticker.C:
+			}
+			if err := app.enqueueAuditCheckpointJob(ctx); err != nil {
+				app.logger.ErrorContext(ctx, "audit checkpoint job enqueue failed", "error", err)
+			}
+		}
+	}()
+}
+
+func (app *application) enqueueAuditCheckpointJob(ctx context.Context) error {
+	if app.jobStore == nil {
+		app.jobStore = jobs.NewStore(app.db)
+	}
+	job, created, err := app.jobStore.EnqueueSingleton(ctx, jobs.EnqueueInput{
+		Type:         jobs.TypeAuditCheckpoint,
+		SingletonKey: jobs.TypeAuditCheckpoint,
+		Visibility:   jobs.VisibilityInternal,
+		Priority:     jobs.PriorityLow,
+		MaxAttempts:  3,
+	})
+	if errors.Is(err, jobs.ErrActiveExists) {
+		app.logger.DebugContext(ctx, "audit checkpoint job already active", "job.type", jobs.TypeAuditCheckpoint)
+		return nil
+	}
+	if err == nil && created {
+		app.logger.InfoContext(ctx, "audit checkpoint job queued", "job.id", job.ID, "job.type", job.Type, "job.priority", job.Priority)
+	}
+	return err
+}
+
+func (app *application) handleAuditCheckpointJob(ctx context.Context) (any, error) {
+	written, err := app.auditStore().Checkpoint(ctx)
+	if err != nil {
+		return nil, jobs.Retryable("audit_checkpoint_failed", err.Error())
+	}
+	if written > 0 {
+		app.logger.InfoContext(ctx, "audit checkpoint job signed chain heads", "checkpoints", written)
+	} else {
+		app.logger.DebugContext(ctx, "audit checkpoint job found no advanced chains")
+	}
+	return map[string]any{"checkpoints": written}, nil
+}
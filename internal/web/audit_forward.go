@@ -0,0 +1,242 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/auditsink"
+	"github.com/sqlwarden/internal/jobs"
+)
+
+const (
+	auditForwardInterval     = 5 * time.Second
+	auditForwardMaxBatches   = 20
+	auditForwardBaseBackoff  = 15 * time.Second
+	auditForwardMaxBackoff   = 30 * time.Minute
+	auditForwardDeliverLimit = time.Minute
+)
+
+type auditForwardInput struct {
+	SinkID string `json:"sink_id"`
+}
+
+type auditForwardOutput struct {
+	SinkID    string `json:"sink_id"`
+	Delivered int    `json:"delivered"`
+	Dropped   int    `json:"dropped"`
+}
+
+func auditForwardSingletonKey(sinkID string) string {
+	return jobs.TypeAuditForward + ":" + sinkID
+}
+
+// auditSinkIDs returns the configured sink IDs in a stable order.
+func (app *application) auditSinkIDs() []string {
+	ids := make([]string, 0, len(app.config.Audit.Sinks))
+	for id := range app.config.Audit.Sinks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func newAuditSink(cfg AuditSink) (auditsink.Sink, error) {
+	switch cfg.Type {
+	case auditsink.TypeSyslog:
+		var tlsConfig *tls.Config
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read syslog ca_file: %w", err)
+			}
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("syslog ca_file contains no PEM certificates")
+			}
+			tlsConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		}
+		return auditsink.NewSyslog(auditsink.SyslogConfig{
+			Network:  cfg.Network,
+			Address:  cfg.Address,
+			Facility: cfg.Facility,
+			TLS:      tlsConfig,
+		})
+	case auditsink.TypeWebhook:
+		return auditsink.NewWebhook(auditsink.WebhookConfig{URL: cfg.URL, Secret: cfg.Secret})
+	case auditsink.TypeFile:
+		return auditsink.NewFile(auditsink.FileConfig{
+			Path:         cfg.Path,
+			MaxSizeBytes: int64(cfg.MaxSizeMB) << 20,
+			MaxBackups:   cfg.MaxBackups,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported audit sink type %q", cfg.Type)
+	}
+}
+
+// auditForwardBackoff grows exponentially per failed attempt so an unavailable
+// sink is probed less often the longer it stays down.
+func auditForwardBackoff(attempt int) time.Duration {
+	delay := auditForwardBaseBackoff
+	for i := 1; i < attempt && delay < auditForwardMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, auditForwardMaxBackoff)
+}
+
+// startAuditForwarder drops outbox entries left behind by sinks that were
+// removed from the configuration, then periodically queues one delivery job
+// per sink with due entries. Request handlers only write outbox rows, so a
+// slow or unavailable sink never delays them.
+func (app *application) startAuditForwarder() {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.auditForwardCancel = cancel
+
+	sinkIDs := app.auditSinkIDs()
+	if pruned, err := app.auditStore().PruneOutbox(ctx, sinkIDs); err != nil {
+		app.logger.Error("audit outbox prune failed", "error", err)
+	} else if pruned > 0 {
+		app.logger.Warn("audit outbox entries for unconfigured sinks dropped", "entries", pruned)
+	}
+	if len(sinkIDs) == 0 {
+		return
+	}
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.logger.Info("audit forwarder started", "sinks", sinkIDs)
+		ticker := time.NewTicker(auditForwardInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				app.logger.Info("audit forwarder stopped")
+				return
+			case <-ticker.C:
+			}
+			if err := app.enqueueAuditForwardJobs(ctx); err != nil {
+				app.logger.ErrorContext(ctx, "audit forward job enqueue failed", "error", err)
+			}
+		}
+	}()
+}
+
+func (app *application) enqueueAuditForwardJobs(ctx context.Context) error {
+	if app.jobStore == nil {
+		app.jobStore = jobs.NewStore(app.db)
+	}
+	due, err := app.auditStore().DueSinks(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, sinkID := range due {
+		if _, ok := app.config.Audit.Sinks[sinkID]; !ok {
+			continue
+		}
+		job, created, err := app.jobStore.EnqueueSingleton(ctx, jobs.EnqueueInput{
+			Type:         jobs.TypeAuditForward,
+			SingletonKey: auditForwardSingletonKey(sinkID),
+			Visibility:   jobs.VisibilityInternal,
+			Priority:     jobs.PriorityLow,
+			MaxAttempts:  1,
+			Input:        auditForwardInput{SinkID: sinkID},
+		})
+		if errors.Is(err, jobs.ErrActiveExists) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if created {
+			app.logger.DebugContext(ctx, "audit forward job queued", "job.id", job.ID, "audit.sink", sinkID)
+		}
+	}
+	return nil
+}
+
+// handleAuditForwardJob drains one sink's due outbox entries in batches.
+// Delivery is at-least-once: a failed batch is rescheduled as a whole, so a
+// sink may see a record again and should de-duplicate on its ID.
+func (app *application) handleAuditForwardJob(ctx context.Context, runtime jobs.Runtime) (any, error) {
+	var input auditForwardInput
+	if err := json.Unmarshal([]byte(runtime.Job.InputJSON), &input); err != nil || input.SinkID == "" {
+		return nil, jobs.Permanent("invalid_audit_forward_input", "Audit forward input is invalid.")
+	}
+	cfg, ok := app.config.Audit.Sinks[input.SinkID]
+	if !ok {
+		return nil, jobs.Permanent("audit_sink_not_configured", "Audit sink is not configured.")
+	}
+	sink, err := newAuditSink(cfg)
+	if err != nil {
+		return nil, jobs.Permanent("audit_sink_invalid", err.Error())
+	}
+
+	store := app.auditStore()
+	output := auditForwardOutput{SinkID: input.SinkID}
+	output.Dropped, err = store.TrimOutbox(ctx, input.SinkID, cfg.MaxPending)
+	if err != nil {
+		return nil, err
+	}
+	if output.Dropped > 0 {
+		app.logger.WarnContext(ctx, "audit sink backlog exceeded; oldest entries dropped",
+			"audit.sink", input.SinkID,
+			"dropped", output.Dropped,
+			"max_pending", cfg.MaxPending,
+		)
+	}
+
+	for range auditForwardMaxBatches {
+		if ctx.Err() != nil {
+			break
+		}
+		deliveries, err := store.DueDeliveries(ctx, input.SinkID, time.Now(), cfg.BatchSize)
+		if err != nil {
+			return output, err
+		}
+		if len(deliveries) == 0 {
+			break
+		}
+		entryIDs := make([]string, 0, len(deliveries))
+		records := make([]audit.Record, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			entryIDs = append(entryIDs, delivery.Entry.ID)
+			records = append(records, delivery.Record)
+		}
+
+		deliverCtx, cancel := context.WithTimeout(ctx, auditForwardDeliverLimit)
+		deliverErr := sink.Deliver(deliverCtx, records)
+		cancel()
+		if deliverErr != nil {
+			attempt := deliveries[0].Entry.Attempts + 1
+			next := time.Now().Add(auditForwardBackoff(attempt))
+			if err := store.Defer(ctx, entryIDs, next, deliverErr.Error()); err != nil {
+				return output, err
+			}
+			app.logger.WarnContext(ctx, "audit sink delivery failed",
+				"audit.sink", input.SinkID,
+				"records", len(records),
+				"attempt", attempt,
+				"next_attempt_at", next,
+				"error", deliverErr,
+			)
+			return output, jobs.Retryable("audit_sink_delivery_failed", deliverErr.Error())
+		}
+		if err := store.Acknowledge(ctx, entryIDs); err != nil {
+			return output, err
+		}
+		output.Delivered += len(records)
+	}
+	if output.Delivered > 0 {
+		app.logger.InfoContext(ctx, "audit records forwarded", "audit.sink", input.SinkID, "records", output.Delivered)
+	}
+	return output, nil
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/jobs"
+)
+
+func auditForwardRuntime(t *testing.T, sinkID string) jobs.Runtime {
+	t.Helper()
+	input, err := json.Marshal(auditForwardInput{SinkID: sinkID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jobs.Runtime{Job: jobs.Record{Type: jobs.TypeAuditForward, InputJSON: string(input)}}
+}
+
+func createAuditTestTeams(t *testing.T, app *application, orgSlug, token string, slugs ...string) {
+	t.Helper()
+	for _, slug := range slugs {
+		res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+orgSlug+"/teams", map[string]any{"slug": slug, "name": slug}, token), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusCreated)
+	}
+}
+
+func TestAuditForwardJobDeliversOutboxToFileSink(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	app.config.Audit.Sinks = map[string]AuditSink{
+		"archive": {Type: "file", Path: path, MaxSizeMB: 1, MaxBackups: 1, BatchSize: 2, MaxPending: 100},
+	}
+	_, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "forward-owner"), "Owner", "Forward Org")
+	createAuditTestTeams(t, app, org.Slug, ownerTok, "alpha", "beta", "gamma")
+
+	depth, err := app.auditStore().OutboxDepth(context.Background(), "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, depth, 3)
+
+	output, err := app.handleAuditForwardJob(context.Background(), auditForwardRuntime(t, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, output.(auditForwardOutput).Delivered, 3)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var seqs []int64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record audit.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, record.Chain, audit.OrgChain(org.ID))
+		seqs = append(seqs, record.Seq)
+	}
+	assert.Equal(t, len(seqs), 3)
+	assert.Equal(t, seqs[2], int64(3))
+
+	depth, err = app.auditStore().OutboxDepth(context.Background(), "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, depth, 0)
+}
+
+func TestAuditForwardJobDefersFailedDeliveries(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	app.config.Audit.Sinks = map[string]AuditSink{
+		"siem": {Type: "webhook", URL: server.URL, Secret: "secret", BatchSize: 10, MaxPending: 100},
+	}
+	_, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "forward-owner"), "Owner", "Forward Org")
+	createAuditTestTeams(t, app, org.Slug, ownerTok, "alpha")
+
+	_, err := app.handleAuditForwardJob(context.Background(), auditForwardRuntime(t, "siem"))
+	var coded jobs.CodedError
+	assert.ErrorAs(t, err, &coded)
+	assert.Equal(t, coded.Code, "audit_sink_delivery_failed")
+	assert.True(t, coded.Retryable)
+
+	var entries []audit.OutboxEntry
+	if err := app.db.NewSelect().Model(&entries).Where("sink_id = ?", "siem").Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].Attempts, 1)
+	assert.True(t, entries[0].NextAttemptAt.After(time.Now()))
+
+	due, err := app.auditStore().DueSinks(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 0)
+}
+
+func TestAuditForwardJobDropsOldestEntriesBeyondMaxPending(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	app.config.Audit.Sinks = map[string]AuditSink{
+		"archive": {Type: "file", Path: path, MaxSizeMB: 1, MaxBackups: 1, BatchSize: 2, MaxPending: 2},
+	}
+	_, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "forward-owner"), "Owner", "Forward Org")
+	createAuditTestTeams(t, app, org.Slug, ownerTok, "alpha", "beta", "gamma", "delta")
+
+	output, err := app.handleAuditForwardJob(context.Background(), auditForwardRuntime(t, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, output.(auditForwardOutput).Dropped, 2)
+	assert.Equal(t, output.(auditForwardOutput).Delivered, 2)
+
+	report, err := app.VerifyAuditChains(context.Background(), &org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, report.Valid)
+	assert.Equal(t, report.Chains[0].Records, int64(4))
+}
+
+func TestAuditForwardBackoffIsCapped(t *testing.T) {
+	assert.Equal(t, auditForwardBackoff(1), auditForwardBaseBackoff)
+	assert.Equal(t, auditForwardBackoff(3), 4*auditForwardBaseBackoff)
+	assert.Equal(t, auditForwardBackoff(50), auditForwardMaxBackoff)
+}
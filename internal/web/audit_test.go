@@ -0,0 +1,111 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/audit"
+)
+
+func TestVerifyAuditChainsEndpointRequiresInstanceAdmin(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+
+	res := send(t, newTestRequest(t, http.MethodGet, "/api/v1/instance/audit/verify", nil), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	_, tok := seedAccountWithToken(t, app, uniqueEmail(t, "audit-regular"), "Regular")
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/instance/audit/verify", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+}
+
+func TestVerifyAuditChainsEndpointValidatesOrgID(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, adminTok := seedInstanceAdminAccount(t, app, uniqueEmail(t, "audit-admin"), "Admin")
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/instance/audit/verify?org_id=abc", nil, adminTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "org_id")
+}
+
+func TestAuditRecordsOrgChangesAndDetectsTampering(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, adminTok := seedInstanceAdminAccount(t, app, uniqueEmail(t, "audit-admin"), "Admin")
+	owner, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "audit-owner"), "Owner", "Audit Org")
+
+	for _, team := range []map[string]any{
+		{"slug": "alpha", "name": "Alpha Team"},
+		{"slug": "beta", "name": "Beta Team"},
+		{"slug": "gamma", "name": "Gamma Team"},
+	} {
+		res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+org.Slug+"/teams", team, ownerTok), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusCreated)
+	}
+
+	var records []audit.Record
+	if err := app.db.NewSelect().Model(&records).
+		Where("chain = ?", audit.OrgChain(org.ID)).
+		OrderExpr("seq ASC").
+		Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(records), 3)
+	assert.Equal(t, records[0].Action, "org.team.create")
+	assert.Equal(t, *records[0].ActorAccountID, owner.ID)
+	assert.Equal(t, records[1].PrevHash, records[0].Hash)
+
+	path := "/api/v1/instance/audit/verify?org_id=" + strconv.FormatInt(org.ID, 10)
+	var report audit.Report
+	res := send(t, newAuthRequest(t, http.MethodGet, path, nil, adminTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	decodeJSONResponse(t, res.BodyBytes, &report)
+	assert.True(t, report.Valid)
+	assert.Equal(t, len(report.Chains), 1)
+	assert.Equal(t, report.Chains[0].Records, int64(3))
+
+	if _, err := app.db.NewUpdate().Model((*audit.Record)(nil)).
+		Set("details_json = ?", `{"slug":"renamed"}`).
+		Where("id = ?", records[1].ID).
+		Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	report = audit.Report{}
+	res = send(t, newAuthRequest(t, http.MethodGet, path, nil, adminTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	decodeJSONResponse(t, res.BodyBytes, &report)
+	assert.False(t, report.Valid)
+	if report.Chains[0].FirstBreak == nil {
+		t.Fatalf("expected first break, got %+v", report.Chains[0])
+	}
+	assert.Equal(t, report.Chains[0].FirstBreak.Seq, int64(2))
+	assert.Equal(t, report.Chains[0].FirstBreak.RecordID, records[1].ID)
+	assert.Equal(t, report.Chains[0].FirstBreak.Reason, audit.BreakSignatureInvalid)
+}
+
+func TestAuditCheckpointJobSignsChainHeads(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "audit-owner"), "Owner", "Checkpoint Org")
+
+	res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+org.Slug+"/teams", map[string]any{"slug": "alpha", "name": "Alpha Team"}, ownerTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+
+	output, err := app.handleAuditCheckpointJob(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, output.(map[string]any)["checkpoints"].(int), 1)
+
+	report, err := app.VerifyAuditChains(context.Background(), &org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, report.Valid)
+	assert.Equal(t, report.Chains[0].Checkpoints, 1)
+}
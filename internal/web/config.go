@@ -2,12 +2,16 @@ package web
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/sqlwarden/internal/auditsink"
 	"github.com/sqlwarden/internal/validator"
 )
 
@@ -32,10 +36,19 @@ const (
 	defaultDesktopAppDir        = ""
 	defaultDesktopActiveBackend = "local"
 	defaultAllowUserBackends    = true
+	defaultAuditSinkBatchSize   = 100
+	defaultAuditSinkMaxPending  = 100000
+	defaultAuditFileMaxSizeMB   = 100
+	defaultAuditFileMaxBackups  = 5
+	defaultClusterForwardMode   = ClusterForwardProxy
+	defaultClusterDrainTimeout  = "5m"
+	defaultEgressBlockLinkLocal = true
 )
 
 var defaultSQLiteDriverSources = []string{}
 
+var auditSinkIDRX = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
 const (
 	DeploymentModeServer  = "server"
 	DeploymentModeDesktop = "desktop"
@@ -55,6 +68,12 @@ const (
 	SQLiteDriverSourceLocal = "local"
 )
 
+// How a replica answers a request for a live session another replica holds.
+const (
+	ClusterForwardProxy    = "proxy"
+	ClusterForwardRedirect = "redirect"
+)
+
 const (
 	LogLevelDebug = "debug"
 	LogLevelInfo  = "info"
@@ -109,6 +128,18 @@ type Config struct {
 			AllowedSources []string
 		}
 	}
+	Egress struct {
+		// AllowedTargets and DeniedTargets are the instance egress policy
+		// for target database connections: host names, IP addresses, and
+		// CIDR ranges. Organizations can narrow it further.
+		AllowedTargets []string
+		DeniedTargets  []string
+		// BlockLinkLocal refuses link-local and cloud metadata addresses.
+		BlockLinkLocal bool
+		// AllowUnixSockets lets target connections use Unix domain sockets,
+		// which the egress rules cannot name.
+		AllowUnixSockets bool
+	}
 	Files struct {
 		StorageMode          string
 		ActiveStorageBackend string
@@ -120,6 +151,53 @@ type Config struct {
 		AllowUserBackends bool
 		Backends          []DesktopBackend
 	}
+	Audit struct {
+		// Sinks forward every audit record appended after startup to an
+		// external system. They are keyed by a stable sink ID.
+		Sinks map[string]AuditSink
+	}
+	SSO struct {
+		// OIDC configures instance-wide OpenID Connect sign-in. It is
+		// disabled while Issuer is empty. Organizations configure their own
+		// provider through the API instead.
+		OIDC OIDCProvider
+	}
+	Secrets struct {
+		// Env, File, and Vault are the providers connection credentials can
+		// reference. Each is disabled while its settings are empty.
+		Env struct {
+			Prefix string
+		}
+		File struct {
+			RootDir string
+		}
+		Vault struct {
+			Address   string
+			Token     string
+			TokenFile string
+			Namespace string
+		}
+	}
+	Cluster struct {
+		// ReplicaID names this process among the replicas sharing the app
+		// database. It defaults to the host name and HTTP port.
+		ReplicaID string
+		// AdvertiseURL is the base URL other replicas reach this one at.
+		// Routing live sessions between replicas is off while it is empty.
+		AdvertiseURL string
+		// ForwardMode is proxy or redirect.
+		ForwardMode string
+		// DrainTimeout bounds how long shutdown waits for live sessions to
+		// end once the replica stops taking new ones.
+		DrainTimeout time.Duration
+	}
+	// RateLimits throttles each client IP per route group: auth, api, or
+	// scim. Groups without an entry, or with zero requests, are not limited.
+	RateLimits map[string]RateLimit
+	// TrustedProxies lists the IP addresses and CIDR ranges of reverse
+	// proxies whose X-Forwarded-For and X-Real-IP headers name the client.
+	// Requests from any other peer are attributed to the peer address.
+	TrustedProxies []netip.Prefix
 }
 
 type FileStorageBackend struct {
@@ -127,6 +205,59 @@ type FileStorageBackend struct {
 	RootDir string `mapstructure:"root_dir"`
 }
 
+// AuditSink configures one audit forwarding destination. Type selects which
+// of the transport fields apply.
+type AuditSink struct {
+	Type string `mapstructure:"type"`
+
+	// Syslog
+	Network  string `mapstructure:"network"`
+	Address  string `mapstructure:"address"`
+	Facility int    `mapstructure:"facility"`
+	CAFile   string `mapstructure:"ca_file"`
+
+	// Webhook
+	URL    string `mapstructure:"url"`
+	Secret string `mapstructure:"secret"`
+
+	// File
+	Path       string `mapstructure:"path"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"`
+	MaxBackups int    `mapstructure:"max_backups"`
+
+	// BatchSize bounds how many records one delivery carries. MaxPending
+	// bounds the sink's outbox; beyond it the oldest undelivered entries are
+	// dropped so a stalled sink cannot grow the database without limit.
+	BatchSize  int `mapstructure:"batch_size"`
+	MaxPending int `mapstructure:"max_pending"`
+}
+
+// RateLimit allows Requests per Window, with bursts of up to Requests.
+type RateLimit struct {
+	Requests int           `mapstructure:"requests"`
+	Window   time.Duration `mapstructure:"window"`
+}
+
+// Route groups that accept a rate limit.
+const (
+	RateLimitGroupAuth = "auth"
+	RateLimitGroupAPI  = "api"
+	RateLimitGroupSCIM = "scim"
+)
+
+// OIDCProvider is an OpenID Connect provider SQLWarden signs users in with.
+type OIDCProvider struct {
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	DisplayName  string   `mapstructure:"display_name"`
+	Scopes       []string `mapstructure:"scopes"`
+	// TrustMFA treats every sign-in through the provider as having presented
+	// a second factor, for providers that enforce MFA without reporting it in
+	// the amr claim.
+	TrustMFA bool `mapstructure:"trust_mfa"`
+}
+
 type DesktopBackend struct {
 	ID          string `mapstructure:"id"`
 	Name        string `mapstructure:"name"`
@@ -154,6 +285,7 @@ func DefaultConfig() Config {
 	cfg.TLS.CertFile = defaultTLSCertFile
 	cfg.TLS.KeyFile = defaultTLSKeyFile
 	cfg.Drivers.SQLite.AllowedSources = append([]string(nil), defaultSQLiteDriverSources...)
+	cfg.Egress.BlockLinkLocal = defaultEgressBlockLinkLocal
 	cfg.Files.StorageMode = defaultFilesStorageMode
 	cfg.Files.ActiveStorageBackend = defaultFilesActiveBackend
 	cfg.Files.StorageBackends = defaultFileStorageBackends()
@@ -161,6 +293,9 @@ func DefaultConfig() Config {
 	cfg.Desktop.ActiveBackend = defaultDesktopActiveBackend
 	cfg.Desktop.AllowUserBackends = defaultAllowUserBackends
 	cfg.Desktop.Backends = defaultDesktopBackends()
+	cfg.Cluster.ForwardMode = defaultClusterForwardMode
+	cfg.Cluster.DrainTimeout, _ = time.ParseDuration(defaultClusterDrainTimeout)
+	cfg.RateLimits = defaultRateLimits()
 	return cfg
 }
 
@@ -173,6 +308,12 @@ func defaultFileStorageBackends() map[string]FileStorageBackend {
 	}
 }
 
+func defaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		RateLimitGroupAuth: {Requests: 60, Window: time.Minute},
+	}
+}
+
 func defaultDesktopBackends() []DesktopBackend {
 	return []DesktopBackend{
 		{
@@ -207,7 +348,22 @@ var configOptions = []configOption{
 	{key: "tls.cert_file", env: "TLS_CERT_FILE", flagName: "tls-cert-file", defaultValue: defaultTLSCertFile, usage: "Path to PEM encoded TLS certificate file"},
 	{key: "tls.key_file", env: "TLS_KEY_FILE", flagName: "tls-key-file", defaultValue: defaultTLSKeyFile, usage: "Path to PEM encoded TLS private key file"},
 	{key: "drivers.sqlite.allowed_sources", env: "DRIVERS_SQLITE_ALLOWED_SOURCES", flagName: "drivers-sqlite-allowed-sources", defaultValue: defaultSQLiteDriverSources, usage: "Comma-separated SQLite target sources to allow (currently: local)"},
+	{key: "egress.allowed_targets", env: "EGRESS_ALLOWED_TARGETS", flagName: "egress-allowed-targets", defaultValue: []string{}, usage: "Comma-separated host names, IP addresses, and CIDR ranges target connections may reach; empty allows all"},
+	{key: "egress.denied_targets", env: "EGRESS_DENIED_TARGETS", flagName: "egress-denied-targets", defaultValue: []string{}, usage: "Comma-separated host names, IP addresses, and CIDR ranges target connections must not reach"},
+	{key: "egress.block_link_local", env: "EGRESS_BLOCK_LINK_LOCAL", flagName: "egress-block-link-local", defaultValue: defaultEgressBlockLinkLocal, usage: "Refuse target connections to link-local and cloud metadata addresses"},
+	{key: "egress.allow_unix_sockets", env: "EGRESS_ALLOW_UNIX_SOCKETS", flagName: "egress-allow-unix-sockets", defaultValue: false, usage: "Allow target connections over Unix domain sockets, which bypass the egress allow and deny lists"},
+	{key: "trusted_proxies", env: "TRUSTED_PROXIES", flagName: "trusted-proxies", defaultValue: []string{}, usage: "Comma-separated IP addresses and CIDR ranges of reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted"},
 	{key: "files.root_dir", env: "FILES_ROOT_DIR", flagName: "files-root-dir", defaultValue: defaultFilesRootDir, usage: "Filesystem root directory for stored workspace files"},
+	{key: "secrets.env.prefix", env: "SECRETS_ENV_PREFIX", flagName: "secrets-env-prefix", defaultValue: "", usage: "Prefix of the environment variables connection credentials may reference"},
+	{key: "secrets.file.root_dir", env: "SECRETS_FILE_ROOT_DIR", flagName: "secrets-file-root-dir", defaultValue: "", usage: "Directory of secret files connection credentials may reference"},
+	{key: "secrets.vault.address", env: "SECRETS_VAULT_ADDRESS", flagName: "secrets-vault-address", defaultValue: "", usage: "Vault API address for connection credentials"},
+	{key: "secrets.vault.token", env: "SECRETS_VAULT_TOKEN", flagName: "secrets-vault-token", defaultValue: "", usage: "Vault token"},
+	{key: "secrets.vault.token_file", env: "SECRETS_VAULT_TOKEN_FILE", flagName: "secrets-vault-token-file", defaultValue: "", usage: "File holding the Vault token, re-read on every request"},
+	{key: "secrets.vault.namespace", env: "SECRETS_VAULT_NAMESPACE", flagName: "secrets-vault-namespace", defaultValue: "", usage: "Vault namespace"},
+	{key: "cluster.replica_id", env: "CLUSTER_REPLICA_ID", flagName: "cluster-replica-id", defaultValue: "", usage: "Name of this replica; defaults to the host name and HTTP port"},
+	{key: "cluster.advertise_url", env: "CLUSTER_ADVERTISE_URL", flagName: "cluster-advertise-url", defaultValue: "", usage: "Base URL other replicas reach this one at; enables routing live sessions between replicas"},
+	{key: "cluster.forward_mode", env: "CLUSTER_FORWARD_MODE", flagName: "cluster-forward-mode", defaultValue: defaultClusterForwardMode, usage: "How requests for a session on another replica are handled (proxy or redirect)"},
+	{key: "cluster.drain_timeout", env: "CLUSTER_DRAIN_TIMEOUT", flagName: "cluster-drain-timeout", defaultValue: defaultClusterDrainTimeout, usage: "How long shutdown waits for live sessions to end, such as 5m"},
 }
 
 func LoadConfig(args []string) (Config, bool, error) {
@@ -291,6 +447,15 @@ func loadConfig(args []string) (Config, bool, error) {
 	cfg.TLS.CertFile = v.GetString("tls.cert_file")
 	cfg.TLS.KeyFile = v.GetString("tls.key_file")
 	cfg.Drivers.SQLite.AllowedSources = splitConfigStringList(v.GetStringSlice("drivers.sqlite.allowed_sources"))
+	cfg.Egress.AllowedTargets = splitConfigStringList(v.GetStringSlice("egress.allowed_targets"))
+	cfg.Egress.DeniedTargets = splitConfigStringList(v.GetStringSlice("egress.denied_targets"))
+	cfg.Egress.BlockLinkLocal = v.GetBool("egress.block_link_local")
+	cfg.Egress.AllowUnixSockets = v.GetBool("egress.allow_unix_sockets")
+	trustedProxies, err := parseTrustedProxies(splitConfigStringList(v.GetStringSlice("trusted_proxies")))
+	if err != nil {
+		return Config{}, false, fmt.Errorf("trusted_proxies: %w", err)
+	}
+	cfg.TrustedProxies = trustedProxies
 	cfg.Files.StorageBackends = defaultFileStorageBackends()
 	localBackend := cfg.Files.StorageBackends[defaultFilesActiveBackend]
 	localBackend.RootDir = v.GetString("files.root_dir")
@@ -298,6 +463,31 @@ func loadConfig(args []string) (Config, bool, error) {
 	if len(cfg.Files.StorageBackends) == 0 {
 		cfg.Files.StorageBackends = defaultFileStorageBackends()
 	}
+	cfg.Secrets.Env.Prefix = v.GetString("secrets.env.prefix")
+	cfg.Secrets.File.RootDir = v.GetString("secrets.file.root_dir")
+	cfg.Secrets.Vault.Address = v.GetString("secrets.vault.address")
+	cfg.Secrets.Vault.Token = v.GetString("secrets.vault.token")
+	cfg.Secrets.Vault.TokenFile = v.GetString("secrets.vault.token_file")
+	cfg.Secrets.Vault.Namespace = v.GetString("secrets.vault.namespace")
+	cfg.Cluster.ReplicaID = strings.TrimSpace(v.GetString("cluster.replica_id"))
+	cfg.Cluster.AdvertiseURL = strings.TrimRight(strings.TrimSpace(v.GetString("cluster.advertise_url")), "/")
+	cfg.Cluster.ForwardMode = strings.ToLower(strings.TrimSpace(v.GetString("cluster.forward_mode")))
+	drainTimeout, err := time.ParseDuration(strings.TrimSpace(v.GetString("cluster.drain_timeout")))
+	if err != nil {
+		return Config{}, false, fmt.Errorf("cluster.drain_timeout: %w", err)
+	}
+	cfg.Cluster.DrainTimeout = drainTimeout
+	if err := v.UnmarshalKey("audit.sinks", &cfg.Audit.Sinks); err != nil {
+		return Config{}, false, fmt.Errorf("read audit.sinks: %w", err)
+	}
+	applyAuditSinkDefaults(cfg.Audit.Sinks)
+	if err := v.UnmarshalKey("sso.oidc", &cfg.SSO.OIDC); err != nil {
+		return Config{}, false, fmt.Errorf("read sso.oidc: %w", err)
+	}
+
+	if err := v.UnmarshalKey("rate_limits", &cfg.RateLimits); err != nil {
+		return Config{}, false, fmt.Errorf("read rate_limits: %w", err)
+	}
 
 	if err := normalizeConfigPaths(&cfg); err != nil {
 		return Config{}, false, err
@@ -346,6 +536,24 @@ func validateConfig(cfg Config) error {
 	if err := validateFileStorageBackends(cfg); err != nil {
 		return err
 	}
+	if err := validateAuditSinks(cfg.Audit.Sinks); err != nil {
+		return err
+	}
+	if err := validateOIDCProvider(cfg.SSO.OIDC); err != nil {
+		return err
+	}
+	if err := validateRateLimits(cfg.RateLimits); err != nil {
+		return err
+	}
+	if err := validateSecretProviders(cfg); err != nil {
+		return err
+	}
+	if err := validateCluster(cfg); err != nil {
+		return err
+	}
+	if _, err := instanceEgressPolicy(cfg); err != nil {
+		return fmt.Errorf("egress: %w", err)
+	}
 	if strings.TrimSpace(cfg.Desktop.ActiveBackend) == "" {
 		return fmt.Errorf("desktop.active_backend is required")
 	}
@@ -387,6 +595,75 @@ func validateConfig(cfg Config) error {
 	return nil
 }
 
+// validateSecretProviders rejects provider settings that could not work, and an
+// env prefix that would let connections read SQLWarden's own settings.
+func validateSecretProviders(cfg Config) error {
+	if prefix := cfg.Secrets.Env.Prefix; prefix != "" {
+		for _, opt := range configOptions {
+			if strings.HasPrefix(opt.env, prefix) {
+				return fmt.Errorf("secrets.env.prefix must not match the setting %s", opt.env)
+			}
+		}
+	}
+	vault := cfg.Secrets.Vault
+	if vault.Address == "" {
+		if vault.Token != "" || vault.TokenFile != "" || vault.Namespace != "" {
+			return fmt.Errorf("secrets.vault.address is required when other secrets.vault settings are set")
+		}
+		return nil
+	}
+	if !validator.IsURL(vault.Address) {
+		return fmt.Errorf("secrets.vault.address must be a valid URL")
+	}
+	if (vault.Token == "") == (vault.TokenFile == "") {
+		return fmt.Errorf("exactly one of secrets.vault.token and secrets.vault.token_file is required")
+	}
+	return nil
+}
+
+func validateCluster(cfg Config) error {
+	if cfg.Cluster.ForwardMode != ClusterForwardProxy && cfg.Cluster.ForwardMode != ClusterForwardRedirect {
+		return fmt.Errorf("cluster.forward_mode must be %q or %q", ClusterForwardProxy, ClusterForwardRedirect)
+	}
+	if cfg.Cluster.AdvertiseURL != "" && !validator.IsURL(cfg.Cluster.AdvertiseURL) {
+		return fmt.Errorf("cluster.advertise_url must be a valid URL")
+	}
+	if cfg.Cluster.DrainTimeout < 0 {
+		return fmt.Errorf("cluster.drain_timeout must not be negative")
+	}
+	return nil
+}
+
+func validateOIDCProvider(provider OIDCProvider) error {
+	if strings.TrimSpace(provider.Issuer) == "" {
+		return nil
+	}
+	if !validator.IsURL(provider.Issuer) {
+		return fmt.Errorf("sso.oidc.issuer must be a valid URL")
+	}
+	if strings.TrimSpace(provider.ClientID) == "" {
+		return fmt.Errorf("sso.oidc.client_id is required when sso.oidc.issuer is set")
+	}
+	return nil
+}
+
+func validateRateLimits(limits map[string]RateLimit) error {
+	for group, limit := range limits {
+		switch group {
+		case RateLimitGroupAuth, RateLimitGroupAPI, RateLimitGroupSCIM:
+		default:
+			return fmt.Errorf("rate_limits.%s: group must be %q, %q, or %q", group, RateLimitGroupAuth, RateLimitGroupAPI, RateLimitGroupSCIM)
+		}
+		if limit.Requests < 0 {
+			return fmt.Errorf("rate_limits.%s.requests must be 0 or greater", group)
+		}
+		if limit.Requests > 0 && limit.Window <= 0 {
+			return fmt.Errorf("rate_limits.%s.window is required when requests is set", group)
+		}
+	}
+	return nil
+}
+
 func isSupportedLogLevel(level string) bool {
 	switch level {
 	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
@@ -441,6 +718,77 @@ func validateFileStorageBackends(cfg Config) error {
 	return nil
 }
 
+func applyAuditSinkDefaults(sinks map[string]AuditSink) {
+	for id, sink := range sinks {
+		sink.Type = strings.ToLower(strings.TrimSpace(sink.Type))
+		sink.Network = strings.ToLower(strings.TrimSpace(sink.Network))
+		if sink.Type == auditsink.TypeSyslog && sink.Facility == 0 {
+			sink.Facility = auditsink.FacilityLogAudit
+		}
+		if sink.Type == auditsink.TypeFile {
+			if sink.MaxSizeMB == 0 {
+				sink.MaxSizeMB = defaultAuditFileMaxSizeMB
+			}
+			if sink.MaxBackups == 0 {
+				sink.MaxBackups = defaultAuditFileMaxBackups
+			}
+		}
+		if sink.BatchSize == 0 {
+			sink.BatchSize = defaultAuditSinkBatchSize
+		}
+		if sink.MaxPending == 0 {
+			sink.MaxPending = defaultAuditSinkMaxPending
+		}
+		sinks[id] = sink
+	}
+}
+
+func validateAuditSinks(sinks map[string]AuditSink) error {
+	for id, sink := range sinks {
+		if !auditSinkIDRX.MatchString(id) {
+			return fmt.Errorf("audit.sinks.%s must be named with lowercase letters, digits, '-' or '_'", id)
+		}
+		switch sink.Type {
+		case auditsink.TypeSyslog:
+			if sink.Network != auditsink.NetworkUDP && sink.Network != auditsink.NetworkTCP && sink.Network != auditsink.NetworkTLS {
+				return fmt.Errorf("audit.sinks.%s.network must be %q, %q, or %q", id, auditsink.NetworkUDP, auditsink.NetworkTCP, auditsink.NetworkTLS)
+			}
+			if strings.TrimSpace(sink.Address) == "" {
+				return fmt.Errorf("audit.sinks.%s.address is required", id)
+			}
+			if sink.Facility < 0 || sink.Facility > 23 {
+				return fmt.Errorf("audit.sinks.%s.facility must be between 0 and 23", id)
+			}
+			if sink.CAFile != "" && sink.Network != auditsink.NetworkTLS {
+				return fmt.Errorf("audit.sinks.%s.ca_file requires network %q", id, auditsink.NetworkTLS)
+			}
+		case auditsink.TypeWebhook:
+			if !validator.IsURL(sink.URL) {
+				return fmt.Errorf("audit.sinks.%s.url must be a valid URL", id)
+			}
+			if strings.TrimSpace(sink.Secret) == "" {
+				return fmt.Errorf("audit.sinks.%s.secret is required", id)
+			}
+		case auditsink.TypeFile:
+			if strings.TrimSpace(sink.Path) == "" {
+				return fmt.Errorf("audit.sinks.%s.path is required", id)
+			}
+			if sink.MaxSizeMB < 0 || sink.MaxBackups < 0 {
+				return fmt.Errorf("audit.sinks.%s.max_size_mb and max_backups must not be negative", id)
+			}
+		default:
+			return fmt.Errorf("audit.sinks.%s.type must be %q, %q, or %q", id, auditsink.TypeSyslog, auditsink.TypeWebhook, auditsink.TypeFile)
+		}
+		if sink.BatchSize < 1 || sink.BatchSize > 1000 {
+			return fmt.Errorf("audit.sinks.%s.batch_size must be between 1 and 1000", id)
+		}
+		if sink.MaxPending < sink.BatchSize {
+			return fmt.Errorf("audit.sinks.%s.max_pending must be at least batch_size", id)
+		}
+	}
+	return nil
+}
+
 func normalizeConfigPaths(cfg *Config) error {
 	var err error
 	if cfg.DB.Driver == "sqlite" {
@@ -459,6 +807,28 @@ func normalizeConfigPaths(cfg *Config) error {
 		}
 		cfg.Files.StorageBackends[id] = backend
 	}
+	if cfg.Secrets.File.RootDir != "" {
+		cfg.Secrets.File.RootDir, err = expandHomePath(cfg.Secrets.File.RootDir)
+		if err != nil {
+			return fmt.Errorf("expand secrets.file.root_dir: %w", err)
+		}
+	}
+	if cfg.Secrets.Vault.TokenFile != "" {
+		cfg.Secrets.Vault.TokenFile, err = expandHomePath(cfg.Secrets.Vault.TokenFile)
+		if err != nil {
+			return fmt.Errorf("expand secrets.vault.token_file: %w", err)
+		}
+	}
+	for id, sink := range cfg.Audit.Sinks {
+		if sink.Type != auditsink.TypeFile {
+			continue
+		}
+		sink.Path, err = expandHomePath(sink.Path)
+		if err != nil {
+			return fmt.Errorf("expand audit.sinks.%s.path: %w", id, err)
+		}
+		cfg.Audit.Sinks[id] = sink
+	}
 	return nil
 }
 
@@ -1,9 +1,12 @@
 package web
 
 import (
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigDefaults(t *testing.T) {
@@ -325,6 +328,244 @@ func TestLoadConfigRejectsEnabledTLSWithoutCertOrKey(t *testing.T) {
 	}
 }
 
+func TestLoadConfigReadsAuditSinksFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := []byte(`
+audit:
+  sinks:
+    siem:
+      type: syslog
+      network: tls
+      address: siem.example.com:6514
+    hook:
+      type: webhook
+      url: https://hooks.example.com/audit
+      secret: shared-secret
+      batch_size: 50
+    archive:
+      type: file
+      path: /var/log/sqlwarden/audit.ndjson
+`)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := loadConfig([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Audit.Sinks) != 3 {
+		t.Fatalf("audit sinks = %+v", cfg.Audit.Sinks)
+	}
+	siem := cfg.Audit.Sinks["siem"]
+	if siem.Network != "tls" || siem.Facility != 13 || siem.BatchSize != defaultAuditSinkBatchSize || siem.MaxPending != defaultAuditSinkMaxPending {
+		t.Fatalf("unexpected syslog sink: %+v", siem)
+	}
+	if hook := cfg.Audit.Sinks["hook"]; hook.Secret != "shared-secret" || hook.BatchSize != 50 {
+		t.Fatalf("unexpected webhook sink: %+v", hook)
+	}
+	archive := cfg.Audit.Sinks["archive"]
+	if archive.MaxSizeMB != defaultAuditFileMaxSizeMB || archive.MaxBackups != defaultAuditFileMaxBackups {
+		t.Fatalf("unexpected file sink: %+v", archive)
+	}
+}
+
+func TestLoadConfigRejectsInvalidAuditSinks(t *testing.T) {
+	for _, sink := range []string{
+		"type: kafka",
+		"type: syslog\n      network: http\n      address: siem:514",
+		"type: syslog\n      network: udp",
+		"type: syslog\n      network: udp\n      address: siem:514\n      ca_file: /ca.pem",
+		"type: webhook\n      url: https://hooks.example.com",
+		"type: file",
+		"type: file\n      path: /tmp/audit.ndjson\n      batch_size: 5000",
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		content := "audit:\n  sinks:\n    bad:\n      " + sink + "\n"
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := loadConfig([]string{"--config", path}); err == nil {
+			t.Fatalf("expected audit sink %q to fail", sink)
+		}
+	}
+}
+
+func TestLoadConfigReadsOIDCProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := []byte(`
+sso:
+  oidc:
+    issuer: https://idp.example.com
+    client_id: sqlwarden
+    client_secret: shh
+    display_name: Example SSO
+    scopes: [email, profile]
+`)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := loadConfig([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := cfg.SSO.OIDC
+	if provider.Issuer != "https://idp.example.com" || provider.ClientID != "sqlwarden" || provider.ClientSecret != "shh" || len(provider.Scopes) != 2 {
+		t.Fatalf("unexpected oidc provider: %+v", provider)
+	}
+
+	if err := os.WriteFile(path, []byte("sso:\n  oidc:\n    issuer: https://idp.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadConfig([]string{"--config", path}); err == nil {
+		t.Fatal("expected sso.oidc without client_id to fail")
+	}
+}
+
+func TestLoadConfigReadsRateLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := []byte(`
+rate_limits:
+  api:
+    requests: 600
+    window: 1m
+  scim:
+    requests: 100
+    window: 10s
+`)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := loadConfig([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api := cfg.RateLimits[RateLimitGroupAPI]; api.Requests != 600 || api.Window != time.Minute {
+		t.Fatalf("unexpected api rate limit: %+v", api)
+	}
+	if scim := cfg.RateLimits[RateLimitGroupSCIM]; scim.Requests != 100 || scim.Window != 10*time.Second {
+		t.Fatalf("unexpected scim rate limit: %+v", scim)
+	}
+	if auth := cfg.RateLimits[RateLimitGroupAuth]; auth != defaultRateLimits()[RateLimitGroupAuth] {
+		t.Fatalf("expected default auth rate limit, got %+v", auth)
+	}
+
+	for _, limit := range []string{"uploads:\n    requests: 10\n    window: 1m", "api:\n    requests: 10", "api:\n    requests: -1\n    window: 1m"} {
+		if err := os.WriteFile(path, []byte("rate_limits:\n  "+limit+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := loadConfig([]string{"--config", path}); err == nil {
+			t.Fatalf("expected rate limit %q to fail", limit)
+		}
+	}
+}
+
+func TestLoadConfigReadsSecretProviders(t *testing.T) {
+	t.Setenv("SECRETS_VAULT_TOKEN", "s.env-token")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := []byte(`
+secrets:
+  env:
+    prefix: DBCRED_
+  file:
+    root_dir: /run/secrets
+  vault:
+    address: https://vault.example.com:8200
+    namespace: team-a
+`)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := loadConfig([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Secrets.Env.Prefix != "DBCRED_" || cfg.Secrets.File.RootDir != "/run/secrets" {
+		t.Fatalf("unexpected secrets config: %+v", cfg.Secrets)
+	}
+	if vault := cfg.Secrets.Vault; vault.Address != "https://vault.example.com:8200" || vault.Token != "s.env-token" || vault.Namespace != "team-a" {
+		t.Fatalf("unexpected vault config: %+v", vault)
+	}
+
+	for _, secrets := range []string{
+		"env:\n    prefix: ENCRYPTION_",
+		"vault:\n    token: s.only-a-token",
+		"vault:\n    address: https://vault.example.com\n    token_file: /run/vault/token",
+	} {
+		if err := os.WriteFile(path, []byte("secrets:\n  "+secrets+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := loadConfig([]string{"--config", path}); err == nil {
+			t.Fatalf("expected secrets config %q to fail", secrets)
+		}
+	}
+}
+
+func TestLoadConfigReadsCluster(t *testing.T) {
+	t.Setenv("CLUSTER_ADVERTISE_URL", "http://10.0.0.7:6020/")
+
+	cfg, _, err := loadConfig([]string{"--cluster-replica-id", "api-1", "--cluster-drain-timeout", "90s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cfg.Cluster; c.ReplicaID != "api-1" || c.AdvertiseURL != "http://10.0.0.7:6020" || c.ForwardMode != ClusterForwardProxy || c.DrainTimeout != 90*time.Second {
+		t.Fatalf("unexpected cluster config: %+v", c)
+	}
+
+	for _, args := range [][]string{
+		{"--cluster-forward-mode", "teleport"},
+		{"--cluster-drain-timeout", "soon"},
+		{"--cluster-advertise-url", "not a url"},
+	} {
+		if _, _, err := loadConfig(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestLoadConfigReadsEgressPolicy(t *testing.T) {
+	t.Setenv("EGRESS_ALLOWED_TARGETS", "10.0.0.0/8, *.db.example.com")
+
+	cfg, _, err := loadConfig([]string{"--egress-denied-targets", "10.9.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := cfg.Egress; !slices.Equal(e.AllowedTargets, []string{"10.0.0.0/8", "*.db.example.com"}) || !slices.Equal(e.DeniedTargets, []string{"10.9.0.0/16"}) || !e.BlockLinkLocal || e.AllowUnixSockets {
+		t.Fatalf("unexpected egress config: %+v", e)
+	}
+
+	cfg, _, err = loadConfig([]string{"--egress-allow-unix-sockets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Egress.AllowUnixSockets {
+		t.Fatal("expected --egress-allow-unix-sockets to allow unix sockets")
+	}
+
+	if _, _, err := loadConfig([]string{"--egress-denied-targets", "10.0.0.0/40"}); err == nil {
+		t.Fatal("expected an invalid CIDR range to fail")
+	}
+}
+
+func TestLoadConfigReadsTrustedProxies(t *testing.T) {
+	cfg, _, err := loadConfig([]string{"--trusted-proxies", "10.0.0.0/8, 192.0.2.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.10/32")}
+	if !slices.Equal(cfg.TrustedProxies, want) {
+		t.Fatalf("unexpected trusted proxies: %v", cfg.TrustedProxies)
+	}
+
+	if _, _, err := loadConfig([]string{"--trusted-proxies", "proxy.internal"}); err == nil {
+		t.Fatal("expected a host name to fail")
+	}
+}
+
 func TestLoadConfigVersionFlag(t *testing.T) {
 	cfg, showVersion, err := loadConfig([]string{"--version"})
 	if err != nil {
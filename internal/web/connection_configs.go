@@ -0,0 +1,149 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/connconfig"
+	"github.com/sqlwarden/internal/validator"
+)
+
+// connectionBuilder resolves the structured-config builder for a driver by
+// type-asserting a fresh (unconnected) instance, like connectionClassifier.
+func connectionBuilder(driverName string) (connconfig.Builder, bool) {
+	d, err := engine.New(driverName)
+	if err != nil {
+		return nil, false
+	}
+	b, ok := d.(connconfig.Builder)
+	return b, ok
+}
+
+// buildConnectionDSN assembles the DSN for a structured config. Field errors
+// are keyed under "config.".
+func buildConnectionDSN(v *validator.Validator, driverName string, cfg connconfig.Config) string {
+	builder, ok := connectionBuilder(driverName)
+	if !ok {
+		v.AddFieldError("config", "This driver does not support structured settings. Use a DSN instead.")
+		return ""
+	}
+	dsn, err := builder.BuildDSN(cfg)
+	if err != nil {
+		var fieldErr *connconfig.FieldError
+		if errors.As(err, &fieldErr) {
+			v.AddFieldError("config."+fieldErr.Field, fieldErr.Message)
+		} else {
+			v.AddFieldError("config", err.Error())
+		}
+		return ""
+	}
+	return dsn
+}
+
+// parseConnectionDSN returns the structured form of a raw DSN, or nil when
+// the driver has no builder or the DSN has no structured equivalent.
+func parseConnectionDSN(driverName, dsn string) *connconfig.Config {
+	builder, ok := connectionBuilder(driverName)
+	if !ok {
+		return nil
+	}
+	cfg, err := builder.ParseDSN(dsn)
+	if err != nil {
+		return nil
+	}
+	return &cfg
+}
+
+// encryptConnectionConfig serializes and encrypts cfg for storage. A nil cfg
+// encrypts to the empty string, meaning a raw DSN only.
+func (app *application) encryptConnectionConfig(cfg *connconfig.Config) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return app.keyring.Encrypt(string(data))
+}
+
+// connectionConfig decrypts the structured config stored for conn. It returns
+// nil when the connection only has a raw DSN.
+func (app *application) connectionConfig(conn database.Connection) (*connconfig.Config, error) {
+	if conn.ConfigEncrypted == "" {
+		return nil, nil
+	}
+	plaintext, err := app.keyring.Decrypt(conn.ConfigEncrypted)
+	if err != nil {
+		return nil, err
+	}
+	var cfg connconfig.Config
+	if err := json.Unmarshal([]byte(plaintext), &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// connectionTLS returns the certificate material stored for conn, which the
+// DSN cannot carry.
+func (app *application) connectionTLS(conn database.Connection) (connconfig.TLS, error) {
+	cfg, err := app.connectionConfig(conn)
+	if err != nil || cfg == nil {
+		return connconfig.TLS{}, err
+	}
+	return cfg.TLS(), nil
+}
+
+// withMaskedConfig fills conn.Config with its structured config, secrets
+// masked, for responses.
+func (app *application) withMaskedConfig(conn database.Connection) (database.Connection, error) {
+	cfg, err := app.connectionConfig(conn)
+	if err != nil || cfg == nil {
+		return conn, err
+	}
+	masked := cfg.Masked()
+	conn.Config = &masked
+	return conn, nil
+}
+
+// backfillConnectionConfigs parses the DSN of every connection stored before
+// structured configs existed. DSNs with no structured equivalent stay raw.
+// Failures are logged so they never block startup.
+func (app *application) backfillConnectionConfigs(ctx context.Context) {
+	conns, err := app.db.ListAllConnections(ctx)
+	if err != nil {
+		app.logger.Warn("connection config backfill failed", slog.String("error", err.Error()))
+		return
+	}
+	converted := 0
+	for _, conn := range conns {
+		if conn.ConfigEncrypted != "" {
+			continue
+		}
+		dsn, err := app.keyring.Decrypt(conn.DSNEncrypted)
+		if err != nil {
+			app.logger.Warn("connection config backfill skipped connection", slog.Int64("connection_id", conn.ID), slog.String("error", err.Error()))
+			continue
+		}
+		cfg := parseConnectionDSN(conn.Driver, dsn)
+		if cfg == nil {
+			continue
+		}
+		configEncrypted, err := app.encryptConnectionConfig(cfg)
+		if err == nil {
+			err = app.db.UpdateConnectionConfig(ctx, conn.ID, configEncrypted)
+		}
+		if err != nil {
+			app.logger.Warn("connection config backfill skipped connection", slog.Int64("connection_id", conn.ID), slog.String("error", err.Error()))
+			continue
+		}
+		converted++
+	}
+	if converted > 0 {
+		app.logger.Info("connection configs backfilled", slog.Int("converted", converted))
+	}
+}
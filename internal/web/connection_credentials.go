@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine/connconfig"
+	"github.com/sqlwarden/internal/secrets"
+	"github.com/sqlwarden/internal/validator"
+)
+
+var errConnectionCredentials = errors.New("could not resolve the connection credentials")
+
+// newSecretResolver registers the secret providers enabled in cfg.
+func newSecretResolver(cfg Config, logger *slog.Logger) (*secrets.Resolver, error) {
+	resolver := secrets.NewResolver(logger)
+	if cfg.Secrets.Env.Prefix != "" {
+		env, err := secrets.NewEnv(cfg.Secrets.Env.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		resolver.Register(secrets.ProviderEnv, env)
+	}
+	if cfg.Secrets.File.RootDir != "" {
+		file, err := secrets.NewFile(cfg.Secrets.File.RootDir)
+		if err != nil {
+			return nil, err
+		}
+		resolver.Register(secrets.ProviderFile, file)
+	}
+	if vaultCfg := cfg.Secrets.Vault; vaultCfg.Address != "" {
+		vault, err := secrets.NewVault(secrets.VaultConfig{
+			Address:   vaultCfg.Address,
+			Token:     vaultCfg.Token,
+			TokenFile: vaultCfg.TokenFile,
+			Namespace: vaultCfg.Namespace,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resolver.Register(secrets.ProviderVault, vault)
+	}
+	return resolver, nil
+}
+
+// validateCredentialRef checks a connection's secret reference against the
+// structured config it will be merged into. The password must come from the
+// secret alone so SQLWarden stores none.
+func (app *application) validateCredentialRef(v *validator.Validator, driverName, ref string, cfg *connconfig.Config) {
+	if ref == "" {
+		return
+	}
+	builder, ok := connectionBuilder(driverName)
+	if !ok || cfg == nil {
+		v.AddFieldError("credential_ref", "Secret references need structured settings.")
+		return
+	}
+	if _, ok := builder.ConnectionSpec().Field(connconfig.FieldPassword); !ok {
+		v.AddFieldError("credential_ref", "This driver does not use a password.")
+		return
+	}
+	if cfg.Password != "" {
+		v.AddFieldError("config.password", "Leave the password empty when it comes from a secret reference.")
+	}
+	if err := app.secrets.Check(ref); err != nil {
+		v.AddFieldError("credential_ref", err.Error())
+	}
+}
+
+// resolveCredentials returns the DSN to connect with. Without a secret
+// reference that is dsn itself. Otherwise the secret is resolved now and its
+// user and password are merged into cfg; the returned Keeper renews a leased
+// secret until closed, which also revokes it. Resolution failures wrap
+// errConnectionCredentials.
+func (app *application) resolveCredentials(ctx context.Context, driverName, dsn, ref string, cfg *connconfig.Config) (string, *secrets.Keeper, error) {
+	if ref == "" {
+		return dsn, nil, nil
+	}
+	builder, ok := connectionBuilder(driverName)
+	if !ok || cfg == nil {
+		return "", nil, fmt.Errorf("%w: the connection has no structured settings", errConnectionCredentials)
+	}
+	cred, err := app.secrets.Resolve(ctx, ref)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %w", errConnectionCredentials, err)
+	}
+	keeper := app.secrets.Keep(cred)
+
+	merged := *cfg
+	merged.Password = cred.Password
+	if cred.User != "" {
+		merged.User = cred.User
+	}
+	resolved, err := builder.BuildDSN(merged)
+	if err != nil {
+		_ = keeper.Close()
+		return "", nil, fmt.Errorf("%w: %w", errConnectionCredentials, err)
+	}
+	return resolved, keeper, nil
+}
+
+// connectionCredentials resolves the DSN to open conn with; see
+// resolveCredentials. plainDSN is the stored DSN, already decrypted.
+func (app *application) connectionCredentials(ctx context.Context, conn database.Connection, plainDSN string) (string, *secrets.Keeper, error) {
+	if conn.CredentialRef == "" {
+		return plainDSN, nil, nil
+	}
+	cfg, err := app.connectionConfig(conn)
+	if err != nil {
+		return "", nil, err
+	}
+	return app.resolveCredentials(ctx, conn.Driver, plainDSN, conn.CredentialRef, cfg)
+}
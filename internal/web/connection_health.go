@@ -0,0 +1,322 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/smtp"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const (
+	connectionHealthInterval    = time.Minute
+	connectionHealthTimeout     = 10 * time.Second
+	connectionHealthConcurrency = 8
+	connectionHealthRetention   = 30 * 24 * time.Hour
+
+	defaultConnectionHealthWindow = 24 * time.Hour
+	defaultConnectionHealthLimit  = 500
+	maxConnectionHealthLimit      = 5000
+)
+
+func (app *application) startConnectionHealthMonitor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.connectionHealthCancel = cancel
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.logger.Info("connection health monitor started")
+		ticker := time.NewTicker(connectionHealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				app.logger.Info("connection health monitor stopped")
+				return
+			case <-ticker.C:
+			}
+			if err := app.enqueueConnectionHealthJob(ctx); err != nil {
+				app.logger.ErrorContext(ctx, "connection health job enqueue failed", "error", err)
+			}
+		}
+	}()
+}
+
+func (app *application) enqueueConnectionHealthJob(ctx context.Context) error {
+	if app.jobStore == nil {
+		app.jobStore = jobs.NewStore(app.db)
+	}
+	job, created, err := app.jobStore.EnqueueSingleton(ctx, jobs.EnqueueInput{
+		Type:         jobs.TypeConnectionHealth,
+		SingletonKey: jobs.TypeConnectionHealth,
+		Visibility:   jobs.VisibilityInternal,
+		Priority:     jobs.PriorityLow,
+		MaxAttempts:  1,
+	})
+	if errors.Is(err, jobs.ErrActiveExists) {
+		app.logger.DebugContext(ctx, "connection health job already active", "job.type", jobs.TypeConnectionHealth)
+		return nil
+	}
+	if err == nil && created {
+		app.logger.DebugContext(ctx, "connection health job queued", "job.id", job.ID, "job.type", job.Type)
+	}
+	return err
+}
+
+// handleConnectionHealthJob pings every connection opted into health checks
+// and records the outcome. Workspace admins are emailed when a connection
+// that was reachable, or had not been checked yet, stops answering.
+func (app *application) handleConnectionHealthJob(ctx context.Context) (any, error) {
+	conns, err := app.db.ListHealthCheckedConnections(ctx)
+	if err != nil {
+		return nil, jobs.Retryable("connection_health_list_failed", err.Error())
+	}
+
+	checks := make([]database.ConnectionHealthCheck, len(conns))
+	sem := make(chan struct{}, connectionHealthConcurrency)
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			checks[i] = app.probeConnection(ctx, conn)
+		}()
+	}
+	wg.Wait()
+
+	unreachable := 0
+	for i, check := range checks {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		previous, found, err := app.db.GetLatestConnectionHealthCheck(ctx, check.ConnectionID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := app.db.InsertConnectionHealthCheck(ctx, check); err != nil {
+			return nil, err
+		}
+		if check.Status != database.ConnectionHealthUnreachable {
+			continue
+		}
+		unreachable++
+		if !found || previous.Status == database.ConnectionHealthReachable {
+			app.logger.WarnContext(ctx, "connection became unreachable", "connection_id", check.ConnectionID, "stage", check.Stage, "error_category", check.ErrorCategory)
+			app.notifyConnectionUnreachable(ctx, conns[i], check)
+		}
+	}
+
+	pruned, err := app.db.DeleteConnectionHealthChecksBefore(ctx, time.Now().Add(-connectionHealthRetention))
+	if err != nil {
+		return nil, err
+	}
+	app.logger.DebugContext(ctx, "connection health job checked connections", "checked", len(checks), "unreachable", unreachable, "pruned", pruned)
+	return map[string]any{"checked": len(checks), "unreachable": unreachable}, nil
+}
+
+// probeConnection opens conn with its own credentials, tunnel, and TLS
+// settings and pings it. A failure records the stage and error category a
+// connection test would report for it.
+func (app *application) probeConnection(ctx context.Context, conn database.Connection) database.ConnectionHealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, connectionHealthTimeout)
+	defer cancel()
+
+	start := time.Now()
+	failed := func(stage string, err error) database.ConnectionHealthCheck {
+		return database.ConnectionHealthCheck{
+			ConnectionID:  conn.ID,
+			Status:        database.ConnectionHealthUnreachable,
+			LatencyMs:     time.Since(start).Milliseconds(),
+			Stage:         stage,
+			ErrorCategory: connectionTestErrorCategory(err),
+			Error:         err.Error(),
+			CheckedAt:     start,
+		}
+	}
+
+	plainDSN, err := app.keyring.Decrypt(conn.DSNEncrypted)
+	if err != nil {
+		return failed("credentials", err)
+	}
+	if err := app.validateTargetConnection(conn.Driver, plainDSN); err != nil {
+		return failed("driver_init", err)
+	}
+	d, err := engine.New(conn.Driver)
+	if err != nil {
+		return failed("driver_init", err)
+	}
+	ws, _, err := app.db.GetWorkspace(ctx, conn.WorkspaceID)
+	if err != nil {
+		return failed("driver_init", err)
+	}
+	settings, err := app.effectiveRuntimeSettingsForWorkspace(ctx, ws)
+	if err != nil {
+		return failed("driver_init", err)
+	}
+	tlsMaterial, err := app.connectionTLS(conn)
+	if err != nil {
+		return failed("credentials", err)
+	}
+	dsn, lease, err := app.connectionCredentials(ctx, conn, plainDSN)
+	if err != nil {
+		return failed("credentials", err)
+	}
+	defer lease.Close()
+	tunnel, err := app.openConnectionTunnel(ctx, conn)
+	if err != nil {
+		return failed("tunnel", err)
+	}
+	defer tunnel.Close()
+	cfg := app.driverConnectionConfig(conn.Driver, dsn, settings, conn.DefaultScope)
+	cfg.Dial = app.connectionDialer(conn, tunnel.Dialer())
+	cfg.TLS = tlsMaterial
+	if err := d.Connect(ctx, cfg); err != nil {
+		return failed("connect", err)
+	}
+	defer d.Close()
+	if err := d.Ping(ctx); err != nil {
+		return failed("ping", err)
+	}
+	return database.ConnectionHealthCheck{
+		ConnectionID: conn.ID,
+		Status:       database.ConnectionHealthReachable,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		CheckedAt:    start,
+	}
+}
+
+// notifyConnectionUnreachable emails the admins of conn's workspace. Failing
+// to notify is logged and does not fail the health job.
+func (app *application) notifyConnectionUnreachable(ctx context.Context, conn database.Connection, check database.ConnectionHealthCheck) {
+	ws, found, err := app.db.GetWorkspace(ctx, conn.WorkspaceID)
+	if err != nil || !found {
+		app.logger.WarnContext(ctx, "connection health notification skipped", "connection_id", conn.ID, "error", err)
+		return
+	}
+	recipients, err := app.workspaceAdminEmails(ctx, ws)
+	if err != nil {
+		app.logger.WarnContext(ctx, "connection health notification recipients lookup failed", "connection_id", conn.ID, "error", err)
+		return
+	}
+	settings, err := app.instanceSettings(ctx)
+	if err != nil {
+		app.logger.WarnContext(ctx, "connection health notification skipped", "connection_id", conn.ID, "error", err)
+		return
+	}
+	data := newEmailData(settings.BaseURL)
+	data["ConnectionName"] = conn.Name
+	data["WorkspaceName"] = ws.Name
+	data["Stage"] = check.Stage
+	data["ErrorCategory"] = check.ErrorCategory
+	data["CheckedAt"] = check.CheckedAt
+	for _, recipient := range recipients {
+		err := app.sendEmail(false, recipient, data, "connection-unreachable.tmpl")
+		if err != nil && !errors.Is(err, smtp.ErrDisabled) {
+			app.logger.WarnContext(ctx, "connection health notification failed", "connection_id", conn.ID, "error", err)
+		}
+	}
+}
+
+// workspaceAdminEmails returns the email addresses of the active accounts
+// that manage ws: org members who hold ws:write on it, or the owner of a
+// personal workspace.
+func (app *application) workspaceAdminEmails(ctx context.Context, ws database.Workspace) ([]string, error) {
+	var accountIDs []int64
+	if ws.OwnerType == "org" && ws.OrgID != nil {
+		members, err := app.db.GetOrgMembers(ctx, *ws.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if app.enforcer.Can(ctx, member.AccountID, *ws.OrgID, ws.OwnerType, "workspace", ws.ID, access.PermWsWrite) {
+				accountIDs = append(accountIDs, member.AccountID)
+			}
+		}
+	} else {
+		accountIDs = append(accountIDs, ws.OwnerID)
+	}
+
+	emails := make([]string, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		account, found, err := app.db.GetAccount(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if found && account.IsActive {
+			emails = append(emails, account.Email)
+		}
+	}
+	return emails, nil
+}
+
+func (app *application) getConnectionHealth(w http.ResponseWriter, r *http.Request) {
+	conn := contextGetConnection(r)
+
+	payload := map[string]any{
+		"enabled": conn.HealthCheckEnabled,
+		"current": nil,
+	}
+	check, found, err := app.db.GetLatestConnectionHealthCheck(r.Context(), conn.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if found {
+		payload["current"] = check
+	}
+	err = response.JSON(w, http.StatusOK, payload)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// listConnectionHealthHistory returns the connection's checks since the
+// since parameter, oldest first. It defaults to the last day and keeps the
+// most recent limit checks.
+func (app *application) listConnectionHealthHistory(w http.ResponseWriter, r *http.Request) {
+	conn := contextGetConnection(r)
+
+	since := time.Now().Add(-defaultConnectionHealthWindow)
+	limit := defaultConnectionHealthLimit
+	var v validator.Validator
+	if raw := strings.TrimSpace(r.URL.Query().Get("since")); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		v.CheckField(err == nil, "since", "Must be an RFC 3339 timestamp.")
+		since = parsed
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		v.CheckField(err == nil && parsed > 0 && parsed <= maxConnectionHealthLimit, "limit", "Must be between 1 and "+strconv.Itoa(maxConnectionHealthLimit)+".")
+		limit = parsed
+	}
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	checks, err := app.db.ListConnectionHealthChecks(r.Context(), conn.ID, since, limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{
+		"enabled": conn.HealthCheckEnabled,
+		"since":   since.UTC(),
+		"checks":  checks,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
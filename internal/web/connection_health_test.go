@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+)
+
+func TestConnectionHealthMonitoring(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+	owner, tok, org := seedOrgOwner(t, app, uniqueEmail(t, "health-owner"), "Health Owner", "Acme")
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Primary Workspace", "")
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	connectionsURL := orgEnvConnectionsURL(org.Slug, ws.ID, envID)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, connectionsURL, map[string]any{
+		"name": "Scratch", "driver": "sqlite", "dsn": ":memory:", "health_check_enabled": true,
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["health_check_enabled"], any(true))
+	connID := int64(res.BodyFields["id"].(float64))
+	connURL := orgConnectionURL(org.Slug, ws.ID, envID, strconv.FormatInt(connID, 10))
+
+	res = send(t, newAuthRequest(t, http.MethodGet, connURL+"/health", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["current"], nil)
+
+	output, err := app.handleConnectionHealthJob(t.Context())
+	assert.Nil(t, err)
+	assert.Equal(t, output.(map[string]any)["unreachable"], any(0))
+	res = send(t, newAuthRequest(t, http.MethodGet, connURL+"/health", nil, tok), app.routes())
+	current := res.BodyFields["current"].(map[string]any)
+	assert.Equal(t, current["status"], any(database.ConnectionHealthReachable))
+	assert.Equal(t, len(app.mailer.SentMessages), 0)
+
+	missing := filepath.Join(t.TempDir(), "missing", "app.db")
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"dsn": "file:" + missing + "?mode=ro"}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	for range 2 {
+		output, err = app.handleConnectionHealthJob(t.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, output.(map[string]any)["unreachable"], any(1))
+	}
+	res = send(t, newAuthRequest(t, http.MethodGet, connURL+"/health", nil, tok), app.routes())
+	current = res.BodyFields["current"].(map[string]any)
+	assert.Equal(t, current["status"], any(database.ConnectionHealthUnreachable))
+	assert.Equal(t, current["error_category"], any("target_unreachable"))
+	assert.Equal(t, len(app.mailer.SentMessages), 1)
+	assert.True(t, strings.Contains(app.mailer.SentMessages[0], "To: <"+owner.Email+">"))
+	assert.True(t, strings.Contains(app.mailer.SentMessages[0], "Connection Scratch is unreachable"))
+
+	res = send(t, newAuthRequest(t, http.MethodGet, connURL+"/health/history", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	checks := res.BodyFields["checks"].([]any)
+	assert.Equal(t, len(checks), 3)
+	assert.Equal(t, checks[0].(map[string]any)["status"], any(database.ConnectionHealthReachable))
+
+	res = send(t, newAuthRequest(t, http.MethodGet, connURL+"/health/history?limit=1", nil, tok), app.routes())
+	assert.Equal(t, len(res.BodyFields["checks"].([]any)), 1)
+	res = send(t, newAuthRequest(t, http.MethodGet, connURL+"/health/history?since=yesterday&limit=0", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "since")
+	assertValidationField(t, res, "limit")
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"health_check_enabled": false}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	output, err = app.handleConnectionHealthJob(t.Context())
+	assert.Nil(t, err)
+	assert.Equal(t, output.(map[string]any)["checked"], any(0))
+}
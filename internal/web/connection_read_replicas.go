@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sqlwarden/internal/connection"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/connconfig"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const maxReadReplicas = 8
+
+var readReplicaNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// validateReadReplicas checks the read replicas requested for a connection.
+// A replica reuses the primary's structured settings with its own host and
+// port, so the connection needs structured settings. Field errors are keyed
+// under "read_replicas".
+func validateReadReplicas(v *validator.Validator, driverName string, replicas []database.ConnectionReadReplica, cfg *connconfig.Config) {
+	if len(replicas) == 0 {
+		return
+	}
+	if _, ok := connectionBuilder(driverName); !ok || cfg == nil {
+		v.AddFieldError("read_replicas", "Read replicas need structured settings.")
+		return
+	}
+	if len(replicas) > maxReadReplicas {
+		v.AddFieldError("read_replicas", fmt.Sprintf("A connection can have at most %d read replicas.", maxReadReplicas))
+		return
+	}
+	seen := map[string]bool{}
+	for i := range replicas {
+		replica := &replicas[i]
+		replica.Name = strings.TrimSpace(replica.Name)
+		replica.Host = strings.TrimSpace(replica.Host)
+		switch {
+		case !readReplicaNamePattern.MatchString(replica.Name):
+			v.AddFieldError("read_replicas", "Replica names must be lowercase letters, digits, dashes, or underscores.")
+		case replica.Name == connection.PrimaryEndpoint:
+			v.AddFieldError("read_replicas", `"primary" is reserved for the connection's own server.`)
+		case seen[replica.Name]:
+			v.AddFieldError("read_replicas", "Replica names must be unique.")
+		case replica.Host == "":
+			v.AddFieldError("read_replicas", "Replica host is required.")
+		case replica.Port < 0 || replica.Port > 65535:
+			v.AddFieldError("read_replicas", "Replica port must be between 1 and 65535.")
+		}
+		seen[replica.Name] = true
+	}
+}
+
+// readReplicaDSN returns the DSN that reaches replica: the primary's resolved
+// DSN with its host and, when set, its port swapped for the replica's.
+func readReplicaDSN(driverName, primaryDSN string, replica database.ConnectionReadReplica) (string, error) {
+	builder, ok := connectionBuilder(driverName)
+	if !ok {
+		return "", fmt.Errorf("driver %q does not support read replicas", driverName)
+	}
+	cfg, err := builder.ParseDSN(primaryDSN)
+	if err != nil {
+		return "", err
+	}
+	cfg.Host = replica.Host
+	if replica.Port != 0 {
+		cfg.Port = replica.Port
+	}
+	return builder.BuildDSN(cfg)
+}
+
+// validateReadReplicaTargets applies the target connection policy to every
+// replica, as validateTargetConnection does to the primary.
+func (app *application) validateReadReplicaTargets(v *validator.Validator, driverName, primaryDSN string, replicas []database.ConnectionReadReplica) {
+	for _, replica := range replicas {
+		dsn, err := readReplicaDSN(driverName, primaryDSN, replica)
+		if err == nil {
+			err = app.validateTargetConnection(driverName, dsn)
+		}
+		if err != nil {
+			v.AddFieldError("read_replicas", fmt.Sprintf("Replica %s: %s", replica.Name, targetConnectionFieldError(err)))
+			return
+		}
+	}
+}
+
+// readReplicaSpecs describes conn's replicas to a session. Each replica
+// connects like the primary, with the DSN primaryDSN points at once the
+// primary has resolved its credentials.
+func (app *application) readReplicaSpecs(conn database.Connection, replicas []database.ConnectionReadReplica, settings effectiveRuntimeSettings, tlsMaterial connconfig.TLS, primaryDSN *string) []connection.ReplicaSpec {
+	specs := make([]connection.ReplicaSpec, 0, len(replicas))
+	for _, replica := range replicas {
+		specs = append(specs, connection.ReplicaSpec{
+			Name: replica.Name,
+			Open: func(ctx context.Context, dial engine.DialFunc) (engine.Driver, error) {
+				dsn, err := readReplicaDSN(conn.Driver, *primaryDSN, replica)
+				if err != nil {
+					return nil, err
+				}
+				cfg := app.driverConnectionConfig(conn.Driver, dsn, settings, conn.DefaultScope)
+				cfg.Dial = app.connectionDialer(conn, dial)
+				cfg.TLS = tlsMaterial
+				cfg.StatementTimeout = settings.QueryMaxExecutionTime
+				return app.connectReadReplica(ctx, cfg)
+			},
+		})
+	}
+	return specs
+}
+
+func (app *application) connectReadReplica(ctx context.Context, cfg engine.ConnectionConfig) (engine.Driver, error) {
+	if err := app.validateTargetConnection(cfg.Driver, cfg.DSN); err != nil {
+		return nil, err
+	}
+	d, err := engine.New(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Connect(ctx, cfg); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// connectPreferringReplicas opens a single connection for a background read,
+// trying conn's replicas in order before the primary. It returns the driver
+// and the endpoint it reached, which is empty when conn has no replicas.
+// Replicas that fail are reported through onReplicaFailed; only the
+// primary's error is returned.
+func (app *application) connectPreferringReplicas(ctx context.Context, conn database.Connection, cfg engine.ConnectionConfig, onReplicaFailed func(name string, err error)) (engine.Driver, string, error) {
+	replicas, err := app.db.ListConnectionReadReplicas(ctx, conn.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, replica := range replicas {
+		replicaCfg := cfg
+		replicaCfg.DSN, err = readReplicaDSN(conn.Driver, cfg.DSN, replica)
+		if err == nil {
+			var d engine.Driver
+			if d, err = app.connectReadReplica(ctx, replicaCfg); err == nil {
+				return d, replica.Name, nil
+			}
+		}
+		onReplicaFailed(replica.Name, err)
+	}
+	d, err := engine.New(conn.Driver)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := d.Connect(ctx, cfg); err != nil {
+		return nil, "", err
+	}
+	if len(replicas) == 0 {
+		return d, "", nil
+	}
+	return d, connection.PrimaryEndpoint, nil
+}
+
+// withReadReplicas fills in conn's read replicas for API responses.
+func (app *application) withReadReplicas(ctx context.Context, conn database.Connection) (database.Connection, error) {
+	replicas, err := app.db.ListConnectionReadReplicas(ctx, conn.ID)
+	if err != nil {
+		return conn, err
+	}
+	conn.ReadReplicas = replicas
+	return conn, nil
+}
+
+func sameReadReplicas(a, b []database.ConnectionReadReplica) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Host != b[i].Host || a[i].Port != b[i].Port {
+			return false
+		}
+	}
+	return true
+}
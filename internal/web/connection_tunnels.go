@@ -0,0 +1,81 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/sqlwarden/internal/connection"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/validator"
+)
+
+// validateSSHTunnel checks a requested SSH jump host. Field errors are keyed
+// under "ssh_tunnel.".
+func validateSSHTunnel(v *validator.Validator, driverName string, tunnel *connection.TunnelConfig) {
+	if tunnel == nil {
+		return
+	}
+	if engine.NormalizeName(strings.TrimSpace(driverName)) == string(engine.DialectSQLite) {
+		v.AddFieldError("ssh_tunnel", "SSH tunnels are not supported for SQLite connections.")
+		return
+	}
+	tunnel.Host = strings.TrimSpace(tunnel.Host)
+	tunnel.User = strings.TrimSpace(tunnel.User)
+	tunnel.HostKeyFingerprint = strings.TrimSpace(tunnel.HostKeyFingerprint)
+
+	v.CheckField(tunnel.Host != "", "ssh_tunnel.host", "SSH host is required.")
+	v.CheckField(tunnel.Port >= 0 && tunnel.Port <= 65535, "ssh_tunnel.port", "SSH port must be between 1 and 65535.")
+	v.CheckField(tunnel.User != "", "ssh_tunnel.user", "SSH user is required.")
+	v.CheckField(tunnel.PrivateKey == "" || tunnel.Password == "", "ssh_tunnel.password", "Use either a private key or a password, not both.")
+	v.CheckField(tunnel.HasSecret(), "ssh_tunnel.private_key", "A private key or password is required.")
+	if tunnel.PrivateKey != "" {
+		v.CheckField(connection.ValidatePrivateKey(tunnel.PrivateKey, tunnel.Passphrase) == nil,
+			"ssh_tunnel.private_key", "Private key could not be read. Check the key and its passphrase.")
+	}
+	v.CheckField(connection.ValidFingerprint(tunnel.HostKeyFingerprint),
+		"ssh_tunnel.host_key_fingerprint", "Host key fingerprint must be a SHA256 fingerprint, as printed by ssh-keygen -lf.")
+}
+
+// encryptSSHTunnel serializes and encrypts tunnel for storage. A nil tunnel
+// encrypts to the empty string, meaning a direct connection.
+func (app *application) encryptSSHTunnel(tunnel *connection.TunnelConfig) (string, error) {
+	if tunnel == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(tunnel)
+	if err != nil {
+		return "", err
+	}
+	return app.keyring.Encrypt(string(data))
+}
+
+// connectionTunnelConfig decrypts the SSH tunnel stored for conn. It returns
+// nil when the connection connects directly.
+func (app *application) connectionTunnelConfig(conn database.Connection) (*connection.TunnelConfig, error) {
+	if !conn.SSHTunnelEnabled || conn.SSHTunnelEncrypted == "" {
+		return nil, nil
+	}
+	plaintext, err := app.keyring.Decrypt(conn.SSHTunnelEncrypted)
+	if err != nil {
+		return nil, err
+	}
+	var tunnel connection.TunnelConfig
+	if err := json.Unmarshal([]byte(plaintext), &tunnel); err != nil {
+		return nil, err
+	}
+	tunnel.Dial = app.egressDialer(conn.WorkspaceID, nil)
+	return &tunnel, nil
+}
+
+// openConnectionTunnel opens the SSH tunnel stored for conn for a short-lived
+// connection. The returned tunnel is nil for direct connections; callers close
+// it after the driver either way.
+func (app *application) openConnectionTunnel(ctx context.Context, conn database.Connection) (*connection.Tunnel, error) {
+	cfg, err := app.connectionTunnelConfig(conn)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return connection.OpenTunnel(ctx, *cfg)
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+)
+
+const testHostKeyFingerprint = "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+
+func testSSHTunnel(host string, port int) map[string]any {
+	return map[string]any{
+		"host":                 host,
+		"port":                 port,
+		"user":                 "bastion",
+		"password":             "tunnel-secret",
+		"host_key_fingerprint": testHostKeyFingerprint,
+	}
+}
+
+func closedTCPPort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestTestConnectionReportsTunnelFailureSeparately(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	testURL := orgEnvConnectionsURL(org.Slug, ws.ID, envID) + "/test"
+	dsn := "host=127.0.0.1 port=" + strconv.Itoa(closedTCPPort(t)) + " user=test dbname=test sslmode=disable connect_timeout=1"
+
+	res := send(t, newAuthRequest(t, http.MethodPost, testURL, map[string]any{
+		"driver":     "postgres",
+		"dsn":        dsn,
+		"ssh_tunnel": testSSHTunnel("127.0.0.1", closedTCPPort(t)),
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["ok"], false)
+	assert.Equal(t, res.BodyFields["stage"], any("tunnel"))
+	assert.Equal(t, res.BodyFields["error_category"], any("tunnel_unreachable"))
+
+	res = send(t, newAuthRequest(t, http.MethodPost, testURL, map[string]any{"driver": "postgres", "dsn": dsn}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["stage"], any("connect"))
+	assert.Equal(t, res.BodyFields["error_category"], any("target_unreachable"))
+}
+
+func TestCreateConnectionStoresSSHTunnelEncrypted(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	connectionsURL := orgEnvConnectionsURL(org.Slug, ws.ID, envID)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, connectionsURL, map[string]any{
+		"name":       "Behind bastion",
+		"driver":     "postgres",
+		"dsn":        "host=db.internal port=5432 user=test dbname=test",
+		"ssh_tunnel": testSSHTunnel("bastion.example.com", 2222),
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["ssh_tunnel_enabled"], any(true))
+	assert.False(t, strings.Contains(string(res.BodyBytes), "tunnel-secret"))
+
+	connID := int64(res.BodyFields["id"].(float64))
+	conn, found, err := app.db.GetConnection(context.Background(), connID)
+	if err != nil || !found {
+		t.Fatalf("GetConnection = %v, %v", found, err)
+	}
+	assert.False(t, strings.Contains(conn.SSHTunnelEncrypted, "tunnel-secret"))
+	tunnel, err := app.connectionTunnelConfig(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tunnel.Address(), "bastion.example.com:2222")
+	assert.Equal(t, tunnel.Password, "tunnel-secret")
+
+	tests := []struct {
+		name  string
+		body  map[string]any
+		field string
+	}{
+		{
+			name:  "sqlite",
+			body:  map[string]any{"name": "Local", "driver": "sqlite", "dsn": ":memory:", "ssh_tunnel": testSSHTunnel("bastion.example.com", 22)},
+			field: "ssh_tunnel",
+		},
+		{
+			name: "missing secret",
+			body: map[string]any{"name": "No secret", "driver": "postgres", "dsn": "host=db", "ssh_tunnel": map[string]any{
+				"host": "bastion.example.com", "user": "bastion", "host_key_fingerprint": testHostKeyFingerprint,
+			}},
+			field: "ssh_tunnel.private_key",
+		},
+		{
+			name: "unreadable key",
+			body: map[string]any{"name": "Bad key", "driver": "postgres", "dsn": "host=db", "ssh_tunnel": map[string]any{
+				"host": "bastion.example.com", "user": "bastion", "private_key": "not a key", "host_key_fingerprint": testHostKeyFingerprint,
+			}},
+			field: "ssh_tunnel.private_key",
+		},
+		{
+			name: "bad fingerprint",
+			body: map[string]any{"name": "Bad fingerprint", "driver": "postgres", "dsn": "host=db", "ssh_tunnel": map[string]any{
+				"host": "bastion.example.com", "user": "bastion", "password": "x", "host_key_fingerprint": "MD5:aa:bb",
+			}},
+			field: "ssh_tunnel.host_key_fingerprint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, newAuthRequest(t, http.MethodPost, connectionsURL, tt.body, tok), app.routes())
+			assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+			assertValidationField(t, res, tt.field)
+		})
+	}
+}
+
+func TestUpdateConnectionSSHTunnelKeepsStoredSecret(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	envID := defaultEnvironmentID(t, app, ws.ID)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, orgEnvConnectionsURL(org.Slug, ws.ID, envID), map[string]any{
+		"name":   "Direct",
+		"driver": "postgres",
+		"dsn":    "host=db.internal port=5432 user=test dbname=test",
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["ssh_tunnel_enabled"], any(false))
+	connID := strconv.FormatInt(int64(res.BodyFields["id"].(float64)), 10)
+	connURL := orgConnectionURL(org.Slug, ws.ID, envID, connID)
+	stored := func() database.Connection {
+		t.Helper()
+		id, _ := strconv.ParseInt(connID, 10, 64)
+		conn, _, err := app.db.GetConnection(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"ssh_tunnel": testSSHTunnel("bastion.example.com", 22)}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	assert.True(t, stored().SSHTunnelEnabled)
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"ssh_tunnel": map[string]any{
+		"host": "bastion2.example.com", "user": "bastion", "host_key_fingerprint": testHostKeyFingerprint,
+	}}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	tunnel, err := app.connectionTunnelConfig(stored())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tunnel.Host, "bastion2.example.com")
+	assert.Equal(t, tunnel.Password, "tunnel-secret")
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"ssh_tunnel": testSSHTunnel("bastion.example.com", 22), "remove_ssh_tunnel": true}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "ssh_tunnel")
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"remove_ssh_tunnel": true}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	conn := stored()
+	assert.False(t, conn.SSHTunnelEnabled)
+	assert.Equal(t, conn.SSHTunnelEncrypted, "")
+}
@@ -0,0 +1,352 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine/connconfig"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/secrets"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const apiErrorConnectionCredentialsRequired = "connection_credentials_required"
+
+var errUserCredentialMissing = errors.New("no credentials are set for this connection")
+
+// userCredential is the document stored encrypted in
+// connection_user_credentials. With a secret reference only User is kept, and
+// only when the secret does not name the user itself.
+type userCredential struct {
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Credential sources reported for a stored per-user credential.
+const (
+	userCredentialSourcePassword  = "password"
+	userCredentialSourceSecretRef = "secret_ref"
+)
+
+// connectionUserCredentialView describes a stored per-user credential without
+// its secret.
+type connectionUserCredentialView struct {
+	AccountID     int64     `json:"account_id"`
+	AccountEmail  string    `json:"account_email,omitempty"`
+	AccountName   string    `json:"account_name,omitempty"`
+	Source        string    `json:"source"`
+	User          string    `json:"user,omitempty"`
+	CredentialRef string    `json:"credential_ref,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func userCredentialSource(stored database.ConnectionUserCredential) string {
+	if stored.CredentialRef != "" {
+		return userCredentialSourceSecretRef
+	}
+	return userCredentialSourcePassword
+}
+
+// validateCredentialMode checks that a connection can use mode. Per-user
+// credentials are merged into the structured config, so the driver must
+// support one with a password, and only organization workspaces have more
+// than one person to tell apart.
+func validateCredentialMode(v *validator.Validator, ws database.Workspace, driverName, mode string, cfg *connconfig.Config) {
+	if mode == database.CredentialModeShared {
+		return
+	}
+	if mode != database.CredentialModePerUser {
+		v.AddFieldError("credential_mode", "Credential mode must be shared or per_user.")
+		return
+	}
+	if ws.OwnerType != "org" {
+		v.AddFieldError("credential_mode", "Per-user credentials are only available in organization workspaces.")
+		return
+	}
+	builder, ok := connectionBuilder(driverName)
+	if !ok || cfg == nil {
+		v.AddFieldError("credential_mode", "Per-user credentials need structured settings.")
+		return
+	}
+	if _, ok := builder.ConnectionSpec().Field(connconfig.FieldPassword); !ok {
+		v.AddFieldError("credential_mode", "This driver does not use a password.")
+	}
+}
+
+func (app *application) decryptUserCredential(stored database.ConnectionUserCredential) (userCredential, error) {
+	var cred userCredential
+	if stored.CredentialEncrypted == "" {
+		return cred, nil
+	}
+	plaintext, err := app.keyring.Decrypt(stored.CredentialEncrypted)
+	if err != nil {
+		return userCredential{}, err
+	}
+	err = json.Unmarshal([]byte(plaintext), &cred)
+	return cred, err
+}
+
+// accountConnectionCredentials resolves the DSN accountID opens conn with.
+// Shared connections use their own credentials; see connectionCredentials. On
+// a per-user connection the account's stored user and password, or the secret
+// its reference names, replace those of the structured config, and an account
+// without stored credentials gets errUserCredentialMissing.
+func (app *application) accountConnectionCredentials(ctx context.Context, conn database.Connection, accountID int64, plainDSN string) (string, *secrets.Keeper, error) {
+	if conn.CredentialMode != database.CredentialModePerUser {
+		return app.connectionCredentials(ctx, conn, plainDSN)
+	}
+	stored, found, err := app.db.GetConnectionUserCredential(ctx, conn.ID, accountID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		return "", nil, errUserCredentialMissing
+	}
+	cred, err := app.decryptUserCredential(stored)
+	if err != nil {
+		return "", nil, err
+	}
+	cfg, err := app.connectionConfig(conn)
+	if err != nil {
+		return "", nil, err
+	}
+	builder, ok := connectionBuilder(conn.Driver)
+	if !ok || cfg == nil {
+		return "", nil, fmt.Errorf("%w: the connection has no structured settings", errConnectionCredentials)
+	}
+
+	merged := *cfg
+	if cred.User != "" {
+		merged.User = cred.User
+	}
+	if stored.CredentialRef != "" {
+		return app.resolveCredentials(ctx, conn.Driver, "", stored.CredentialRef, &merged)
+	}
+	merged.Password = cred.Password
+	dsn, err := builder.BuildDSN(merged)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %w", errConnectionCredentials, err)
+	}
+	return dsn, nil, nil
+}
+
+// canOpenConnection reports whether the caller may open sessions on the
+// connection in the request context.
+func (app *application) canOpenConnection(r *http.Request) bool {
+	org := contextGetOrg(r)
+	ws := contextGetWorkspace(r)
+	conn := contextGetConnection(r)
+	return app.hasAnyConnectionRuntimePermission(r, org.ID, ws.OwnerType, conn.ID,
+		access.PermConnExecute,
+		access.PermConnDQL,
+		access.PermConnDML,
+		access.PermConnDDL,
+	)
+}
+
+// listConnectionUserCredentials shows connection admins who has stored
+// credentials for a per-user connection. Secrets and secret references are
+// never included.
+func (app *application) listConnectionUserCredentials(w http.ResponseWriter, r *http.Request) {
+	conn := contextGetConnection(r)
+	stored, err := app.db.ListConnectionUserCredentials(r.Context(), conn.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	views := make([]connectionUserCredentialView, 0, len(stored))
+	for _, s := range stored {
+		views = append(views, connectionUserCredentialView{
+			AccountID:    s.AccountID,
+			AccountEmail: s.AccountEmail,
+			AccountName:  s.AccountName,
+			Source:       userCredentialSource(s),
+			CreatedAt:    s.CreatedAt,
+			UpdatedAt:    s.UpdatedAt,
+		})
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{
+		"credential_mode": conn.CredentialMode,
+		"credentials":     views,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getMyConnectionCredential tells the caller whether they have stored
+// credentials for the connection. The password is never returned.
+func (app *application) getMyConnectionCredential(w http.ResponseWriter, r *http.Request) {
+	if !app.canOpenConnection(r) {
+		app.notPermitted(w, r)
+		return
+	}
+	account := contextGetAccount(r)
+	conn := contextGetConnection(r)
+	stored, found, err := app.db.GetConnectionUserCredential(r.Context(), conn.ID, account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	body := map[string]any{
+		"credential_mode": conn.CredentialMode,
+		"configured":      found,
+	}
+	if found {
+		cred, err := app.decryptUserCredential(stored)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		body["credential"] = connectionUserCredentialView{
+			AccountID:     account.ID,
+			Source:        userCredentialSource(stored),
+			User:          cred.User,
+			CredentialRef: stored.CredentialRef,
+			CreatedAt:     stored.CreatedAt,
+			UpdatedAt:     stored.UpdatedAt,
+		}
+	}
+	if err := response.JSON(w, http.StatusOK, body); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// setMyConnectionCredential stores the caller's own credentials for a
+// per-user connection: a user and password, or, for connection editors, a
+// secret reference. The
+// caller's open session, if any, is dropped so the next query reconnects as
+// the new user.
+func (app *application) setMyConnectionCredential(w http.ResponseWriter, r *http.Request) {
+	if !app.canOpenConnection(r) {
+		app.notPermitted(w, r)
+		return
+	}
+	var input struct {
+		User          string              `json:"user"`
+		Password      string              `json:"password"`
+		CredentialRef string              `json:"credential_ref"`
+		V             validator.Validator `json:"-"`
+	}
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	conn := contextGetConnection(r)
+	if conn.CredentialMode != database.CredentialModePerUser {
+		app.errorMessage(w, r, http.StatusConflict, "This connection does not use per-user credentials.", nil)
+		return
+	}
+	input.User = strings.TrimSpace(input.User)
+	input.CredentialRef = strings.TrimSpace(input.CredentialRef)
+	if input.CredentialRef != "" {
+		input.V.CheckField(input.Password == "", "password", "Leave the password empty when it comes from a secret reference.")
+		// A reference can name any secret the providers can read, so only
+		// those who may already set the connection's own reference can use
+		// one; others could otherwise borrow a more privileged login.
+		ws := contextGetWorkspace(r)
+		if !app.hasConnectionPermission(r, contextGetOrg(r).ID, ws.OwnerType, conn.ID, "conn:update") {
+			input.V.AddFieldError("credential_ref", "Only connection editors can use secret references.")
+		} else if err := app.secrets.Check(input.CredentialRef); err != nil {
+			input.V.AddFieldError("credential_ref", err.Error())
+		}
+	} else {
+		input.V.CheckField(input.User != "", "user", "User is required.")
+		input.V.CheckField(input.Password != "", "password", "Password is required.")
+	}
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	stored := database.ConnectionUserCredential{
+		ConnectionID:  conn.ID,
+		AccountID:     contextGetAccount(r).ID,
+		CredentialRef: input.CredentialRef,
+	}
+	if input.User != "" || input.Password != "" {
+		data, err := json.Marshal(userCredential{User: input.User, Password: input.Password})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		stored.CredentialEncrypted, err = app.keyring.Encrypt(string(data))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	stored, err := app.db.UpsertConnectionUserCredential(r.Context(), stored)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	dropped := app.connManager.RemoveForAccountConnection(strconv.FormatInt(stored.AccountID, 10), strconv.FormatInt(conn.ID, 10))
+
+	source := userCredentialSource(stored)
+	app.logInfo(r, "connection user credential set", slog.Int64("connection_id", conn.ID), slog.String("source", source), slog.Bool("session_dropped", dropped))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.credential.set", "connection", conn.ID, map[string]any{"account_id": stored.AccountID, "source": source}))
+	err = response.JSON(w, http.StatusOK, connectionUserCredentialView{
+		AccountID:     stored.AccountID,
+		Source:        source,
+		User:          input.User,
+		CredentialRef: stored.CredentialRef,
+		CreatedAt:     stored.CreatedAt,
+		UpdatedAt:     stored.UpdatedAt,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteMyConnectionCredential(w http.ResponseWriter, r *http.Request) {
+	if !app.canOpenConnection(r) {
+		app.notPermitted(w, r)
+		return
+	}
+	app.removeConnectionUserCredential(w, r, contextGetAccount(r).ID)
+}
+
+// deleteConnectionUserCredential lets connection admins remove a member's
+// stored credentials, for example after the database user was dropped.
+func (app *application) deleteConnectionUserCredential(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+	app.removeConnectionUserCredential(w, r, accountID)
+}
+
+func (app *application) removeConnectionUserCredential(w http.ResponseWriter, r *http.Request, accountID int64) {
+	conn := contextGetConnection(r)
+	found, err := app.db.DeleteConnectionUserCredential(r.Context(), conn.ID, accountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+	dropped := false
+	if conn.CredentialMode == database.CredentialModePerUser {
+		dropped = app.connManager.RemoveForAccountConnection(strconv.FormatInt(accountID, 10), strconv.FormatInt(conn.ID, 10))
+	}
+	app.logInfo(r, "connection user credential deleted", slog.Int64("connection_id", conn.ID), slog.Int64("account_id", accountID), slog.Bool("session_dropped", dropped))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.credential.delete", "connection", conn.ID, map[string]any{"account_id": accountID}))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,283 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sqlwarden/internal/agent"
+	"github.com/sqlwarden/internal/connection"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const maxConnectorAgentName = 100
+
+// createdConnectorAgentResponse carries the plaintext registration token. It
+// is only returned when the agent is created or its token rotated.
+type createdConnectorAgentResponse struct {
+	database.ConnectorAgent
+	Token string `json:"token"`
+}
+
+func (app *application) listConnectorAgents(w http.ResponseWriter, r *http.Request) {
+	q, errs := readListQuery(r.URL.Query(), map[string]string{
+		"name": "name",
+	})
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+
+	org := contextGetOrg(r)
+	agents, err := app.db.ListConnectorAgents(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	for i := range agents {
+		agents[i].Connected = app.agentHub.Connected(agents[i].ID)
+	}
+	err = response.JSON(w, http.StatusOK, response.PaginateItems(agents, q.Page, q.PageSize))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createConnectorAgent(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string              `json:"name"`
+		V    validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(len(input.Name) <= maxConnectorAgentName, "name", "Name must be 100 characters or fewer.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	org := contextGetOrg(r)
+	creator := contextGetAccount(r)
+	plaintext, hash, prefix, err := token.GenerateAgentToken()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	connector, err := app.db.InsertConnectorAgent(r.Context(), database.InsertConnectorAgentParams{
+		OrgID:              org.ID,
+		Name:               input.Name,
+		TokenPrefix:        prefix,
+		TokenHash:          hash,
+		CreatedByAccountID: &creator.ID,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			app.failedDuplicateField(w, r, "name", "A connector agent with this name already exists in this organization.")
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "connector agent created", slog.Int64("org_id", org.ID), slog.Int64("agent_id", connector.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.connector_agent.create", "connector_agent", connector.ID, map[string]any{"name": connector.Name}))
+	err = response.JSON(w, http.StatusCreated, createdConnectorAgentResponse{ConnectorAgent: connector, Token: plaintext})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) connectorAgentFromRequest(w http.ResponseWriter, r *http.Request) (database.ConnectorAgent, bool) {
+	agentID, err := strconv.ParseInt(chi.URLParam(r, "agent_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return database.ConnectorAgent{}, false
+	}
+	org := contextGetOrg(r)
+	connector, found, err := app.db.GetConnectorAgent(r.Context(), agentID, org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return database.ConnectorAgent{}, false
+	}
+	if !found {
+		app.notFound(w, r)
+		return database.ConnectorAgent{}, false
+	}
+	connector.Connected = app.agentHub.Connected(connector.ID)
+	return connector, true
+}
+
+func (app *application) getConnectorAgent(w http.ResponseWriter, r *http.Request) {
+	connector, ok := app.connectorAgentFromRequest(w, r)
+	if !ok {
+		return
+	}
+	err := response.JSON(w, http.StatusOK, connector)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// rotateConnectorAgentToken issues a new registration token and drops the
+// agent's link, so it has to reconnect with the new one.
+func (app *application) rotateConnectorAgentToken(w http.ResponseWriter, r *http.Request) {
+	connector, ok := app.connectorAgentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	org := contextGetOrg(r)
+	plaintext, hash, prefix, err := token.GenerateAgentToken()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.db.RotateConnectorAgentToken(r.Context(), connector.ID, org.ID, prefix, hash); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.agentHub.Disconnect(connector.ID)
+	connector.TokenPrefix = prefix
+	connector.Connected = false
+
+	app.logInfo(r, "connector agent token rotated", slog.Int64("org_id", org.ID), slog.Int64("agent_id", connector.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.connector_agent.rotate_token", "connector_agent", connector.ID, nil))
+	err = response.JSON(w, http.StatusOK, createdConnectorAgentResponse{ConnectorAgent: connector, Token: plaintext})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteConnectorAgent(w http.ResponseWriter, r *http.Request) {
+	connector, ok := app.connectorAgentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	org := contextGetOrg(r)
+	bound, err := app.db.CountConnectorAgentConnections(r.Context(), connector.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if bound > 0 {
+		app.apiError(w, r, http.StatusConflict, apiErrorResourceInUse, "Connector agent is still used by connections. Move those connections off the agent before deleting it.", response.APIError{
+			Details: map[string]any{"connection_count": bound},
+		}, nil)
+		return
+	}
+	if err := app.db.DeleteConnectorAgent(r.Context(), connector.ID, org.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.agentHub.Disconnect(connector.ID)
+
+	app.logInfo(r, "connector agent deleted", slog.Int64("org_id", org.ID), slog.Int64("agent_id", connector.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.connector_agent.delete", "connector_agent", connector.ID, map[string]any{"name": connector.Name}))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// connectAgentLink authenticates a connector agent by its registration token
+// and keeps its WebSocket link open for as long as the agent stays
+// connected.
+func (app *application) connectAgentLink(w http.ResponseWriter, r *http.Request) {
+	plaintext, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !token.IsAgentToken(plaintext) {
+		app.authenticationRequired(w, r)
+		return
+	}
+	connector, found, err := app.db.GetConnectorAgentByTokenHash(r.Context(), token.Hash(plaintext))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.logWarn(r, "connector agent rejected", slog.String("reason", "agent_token_not_found"))
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	fingerprint := strings.TrimSpace(r.Header.Get(agent.HeaderHostKey))
+	if !connection.ValidFingerprint(fingerprint) {
+		app.errorMessage(w, r, http.StatusBadRequest, "The agent host key fingerprint is missing or invalid.", nil)
+		return
+	}
+
+	conn, err := agent.Upgrade(w, r, nil)
+	if errors.Is(err, agent.ErrNotWebSocket) {
+		app.errorMessage(w, r, http.StatusBadRequest, "Connector agents must connect with a WebSocket upgrade.", nil)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	remoteAddr := app.clientIP(r)
+	attrs := []slog.Attr{slog.Int64("org_id", connector.OrgID), slog.Int64("agent_id", connector.ID), slog.String("remote_addr", remoteAddr)}
+	app.logInfo(r, "connector agent connected", append(attrs, slog.String("version", r.Header.Get(agent.HeaderVersion)))...)
+	ctx := context.WithoutCancel(r.Context())
+	err = app.agentHub.Serve(r.Context(), connector.ID, conn, fingerprint, func(version string) {
+		if err := app.db.RecordConnectorAgentHeartbeat(ctx, connector.ID, version, remoteAddr); err != nil {
+			app.reportServerError(r, err)
+		}
+	})
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	app.logInfo(r, "connector agent disconnected", attrs...)
+}
+
+// validateConnectionAgent checks binding a connection in ws to agentID. A
+// connection reaches its database either through an SSH tunnel or through an
+// agent, never both.
+func (app *application) validateConnectionAgent(ctx context.Context, v *validator.Validator, ws database.Workspace, driverName string, agentID *int64, hasTunnel bool) error {
+	if agentID == nil {
+		return nil
+	}
+	if engine.NormalizeName(strings.TrimSpace(driverName)) == string(engine.DialectSQLite) {
+		v.AddFieldError("agent_id", "Connector agents are not supported for SQLite connections.")
+		return nil
+	}
+	if hasTunnel {
+		v.AddFieldError("agent_id", "Use either an SSH tunnel or a connector agent, not both.")
+		return nil
+	}
+	if ws.OrgID == nil {
+		v.AddFieldError("agent_id", "Connector agents are only available to organization workspaces.")
+		return nil
+	}
+	_, found, err := app.db.GetConnectorAgent(ctx, *agentID, *ws.OrgID)
+	if err != nil {
+		return err
+	}
+	v.CheckField(found, "agent_id", "Connector agent was not found.")
+	return nil
+}
+
+// connectionDialer returns how conn reaches its database: through its
+// connector agent when it is bound to one, otherwise with dial, which is nil
+// for a direct connection. Either way only targets the egress policy allows
+// are reached.
+func (app *application) connectionDialer(conn database.Connection, dial engine.DialFunc) engine.DialFunc {
+	if conn.AgentID != nil {
+		dial = app.agentHub.Dialer(*conn.AgentID)
+	}
+	return app.egressDialer(conn.WorkspaceID, dial)
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/agent"
+	"github.com/sqlwarden/internal/assert"
+)
+
+func orgAgentsURL(orgSlug string) string {
+	return fmt.Sprintf("/api/v1/orgs/%s/agents", orgSlug)
+}
+
+func TestConnectorAgentLifecycle(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	connectionsURL := orgEnvConnectionsURL(org.Slug, ws.ID, envID)
+	agentsURL := orgAgentsURL(org.Slug)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, agentsURL, map[string]any{"name": "Datacenter"}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.True(t, strings.HasPrefix(res.BodyFields["token"].(string), "sqlw_agent_"))
+	assert.Equal(t, res.BodyFields["connected"], any(false))
+	agentID := int64(res.BodyFields["id"].(float64))
+	firstToken := res.BodyFields["token"].(string)
+
+	res = send(t, newAuthRequest(t, http.MethodPost, agentsURL, map[string]any{"name": "Datacenter"}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "name")
+
+	res = send(t, newAuthRequest(t, http.MethodGet, agentsURL, nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.False(t, strings.Contains(string(res.BodyBytes), firstToken))
+	assert.Equal(t, len(res.BodyFields["items"].([]any)), 1)
+
+	_, otherTok, otherOrg := seedOrgOwner(t, app, uniqueEmail(t, "other-owner"), "Other Owner", "Other")
+	res = send(t, newAuthRequest(t, http.MethodPost, orgAgentsURL(otherOrg.Slug), map[string]any{"name": "Elsewhere"}, otherTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	otherAgentID := int64(res.BodyFields["id"].(float64))
+
+	tests := []struct {
+		name string
+		body map[string]any
+	}{
+		{
+			name: "sqlite",
+			body: map[string]any{"name": "Local", "driver": "sqlite", "dsn": ":memory:", "agent_id": agentID},
+		},
+		{
+			name: "with tunnel",
+			body: map[string]any{"name": "Both", "driver": "postgres", "dsn": "host=db.internal", "agent_id": agentID, "ssh_tunnel": testSSHTunnel("bastion.example.com", 22)},
+		},
+		{
+			name: "other organization",
+			body: map[string]any{"name": "Foreign", "driver": "postgres", "dsn": "host=db.internal", "agent_id": otherAgentID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, newAuthRequest(t, http.MethodPost, connectionsURL, tt.body, tok), app.routes())
+			assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+			assertValidationField(t, res, "agent_id")
+		})
+	}
+
+	res = send(t, newAuthRequest(t, http.MethodPost, connectionsURL, map[string]any{
+		"name":     "Behind firewall",
+		"driver":   "postgres",
+		"dsn":      "host=db.internal port=5432 user=test dbname=test",
+		"agent_id": agentID,
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, int64(res.BodyFields["agent_id"].(float64)), agentID)
+	connID := int64(res.BodyFields["id"].(float64))
+
+	agentURL := fmt.Sprintf("%s/%d", agentsURL, agentID)
+	res = send(t, newAuthRequest(t, http.MethodDelete, agentURL, nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusConflict)
+	assertAPIError(t, res, apiErrorResourceInUse, "")
+	assert.Equal(t, apiErrorDetails(t, res)["connection_count"], any(float64(1)))
+
+	res = send(t, newAuthRequest(t, http.MethodPost, agentURL+"/token", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.NotEqual(t, res.BodyFields["token"], any(firstToken))
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, orgConnectionURL(org.Slug, ws.ID, envID, fmt.Sprint(connID)), map[string]any{"agent_id": nil}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	_, bound := res.BodyFields["agent_id"]
+	assert.False(t, bound)
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, agentURL, nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	res = send(t, newAuthRequest(t, http.MethodGet, agentURL, nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestConnectAgentLink(t *testing.T) {
+	t.Parallel()
+	app, org, _, tok := setupWorkspaceOwner(t)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, orgAgentsURL(org.Slug), map[string]any{"name": "Datacenter"}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	agentID := int64(res.BodyFields["id"].(float64))
+	agentToken := res.BodyFields["token"].(string)
+
+	linkRequest := func(bearer, fingerprint string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, agent.ConnectPath, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		if fingerprint != "" {
+			req.Header.Set(agent.HeaderHostKey, fingerprint)
+		}
+		return req
+	}
+
+	res = send(t, linkRequest("", testHostKeyFingerprint), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	res = send(t, linkRequest(tok, testHostKeyFingerprint), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	res = send(t, linkRequest(agentToken+"x", testHostKeyFingerprint), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	res = send(t, linkRequest(agentToken, ""), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	res = send(t, linkRequest(agentToken, testHostKeyFingerprint), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+
+	server := httptest.NewServer(app.routes())
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = agent.Run(ctx, agent.Config{ServerURL: server.URL, Token: agentToken, Version: "9.9.9"})
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		connector, found, err := app.db.GetConnectorAgent(context.Background(), agentID, org.ID)
+		if err != nil || !found {
+			t.Fatalf("GetConnectorAgent = %v, %v", found, err)
+		}
+		if connector.LastHeartbeatAt != nil && app.agentHub.Connected(agentID) {
+			assert.Equal(t, connector.Version, "9.9.9")
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not connect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	res = send(t, newAuthRequest(t, http.MethodGet, fmt.Sprintf("%s/%d", orgAgentsURL(org.Slug), agentID), nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["connected"], any(true))
+}
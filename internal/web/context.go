@@ -12,6 +12,8 @@ type contextKey string
 const (
 	authenticatedAccountKey contextKey = "authenticatedAccount"
 	authSessionKey          contextKey = "authSession"
+	apiTokenKey             contextKey = "apiToken"
+	scimTokenKey            contextKey = "scimToken"
 	orgKey                  contextKey = "org"
 	workspaceKey            contextKey = "workspace"
 	environmentKey          contextKey = "environment"
@@ -57,6 +59,33 @@ func contextGetAuthSession(r *http.Request) database.AuthSession {
 	return session
 }
 
+// API token context helpers. An API token is present only when the request
+// authenticated with one instead of a session-bound access token.
+func contextSetAPIToken(r *http.Request, apiToken database.APIToken) *http.Request {
+	if meta := contextGetRequestLogContext(r); meta != nil {
+		meta.APITokenID = apiToken.ID
+	}
+	ctx := context.WithValue(r.Context(), apiTokenKey, apiToken)
+	return r.WithContext(ctx)
+}
+
+func contextGetAPIToken(r *http.Request) (database.APIToken, bool) {
+	apiToken, ok := r.Context().Value(apiTokenKey).(database.APIToken)
+	return apiToken, ok
+}
+
+// SCIM token context helpers. A SCIM token is present only on requests to the
+// SCIM endpoints, which carry no authenticated account.
+func contextSetSCIMToken(r *http.Request, scimToken database.SCIMToken) *http.Request {
+	ctx := context.WithValue(r.Context(), scimTokenKey, scimToken)
+	return r.WithContext(ctx)
+}
+
+func contextGetSCIMToken(r *http.Request) database.SCIMToken {
+	scimToken, _ := r.Context().Value(scimTokenKey).(database.SCIMToken)
+	return scimToken
+}
+
 // Org context helpers.
 func contextSetOrg(r *http.Request, org database.Organization) *http.Request {
 	if meta := contextGetRequestLogContext(r); meta != nil {
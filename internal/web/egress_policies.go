@@ -0,0 +1,175 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/egress"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const maxEgressPolicyTargets = 200
+
+func instanceEgressPolicy(cfg Config) (*egress.Policy, error) {
+	return egress.New(cfg.Egress.BlockLinkLocal, egress.Rules{Allowed: cfg.Egress.AllowedTargets, Denied: cfg.Egress.DeniedTargets})
+}
+
+// egressPolicy returns the policy for target connections in the organization,
+// or in a personal space when orgID is nil: the instance policy narrowed by
+// the organization's own.
+func (app *application) egressPolicy(ctx context.Context, orgID *int64) (*egress.Policy, error) {
+	levels := []egress.Rules{{Allowed: app.config.Egress.AllowedTargets, Denied: app.config.Egress.DeniedTargets}}
+	if orgID != nil {
+		policy, found, err := app.db.GetOrganizationEgressPolicy(ctx, *orgID)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			levels = append(levels, egress.Rules{Allowed: policy.AllowedTargets, Denied: policy.DeniedTargets})
+		}
+	}
+	policy, err := egress.New(app.config.Egress.BlockLinkLocal, levels...)
+	if err != nil {
+		return nil, err
+	}
+	return policy.AllowUnixSockets(app.config.Egress.AllowUnixSockets), nil
+}
+
+// egressDialer restricts dial, or a direct dial when it is nil, to the
+// targets the egress policy of workspace wsID allows. The policy is loaded on
+// every dial, so a change applies to the next connection a pool opens.
+func (app *application) egressDialer(wsID int64, dial engine.DialFunc) engine.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ws, found, err := app.db.GetWorkspace(ctx, wsID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("workspace %d not found", wsID)
+		}
+		return app.orgEgressDialer(ws.OrgID, dial)(ctx, network, addr)
+	}
+}
+
+// orgEgressDialer is egressDialer for connections not yet saved, such as
+// connection tests.
+func (app *application) orgEgressDialer(orgID *int64, dial engine.DialFunc) engine.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		policy, err := app.egressPolicy(ctx, orgID)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := policy.Dialer(dial)(ctx, network, addr)
+		var blocked *egress.BlockedError
+		if errors.As(err, &blocked) {
+			attrs := []any{"target", blocked.Host}
+			if blocked.Addr.IsValid() {
+				attrs = append(attrs, "addr", blocked.Addr.String())
+			}
+			if orgID != nil {
+				attrs = append(attrs, "org_id", *orgID)
+			}
+			app.logger.WarnContext(ctx, "target connection blocked by egress policy", attrs...)
+		}
+		return conn, err
+	}
+}
+
+func (app *application) getOrgEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	policy, found, err := app.db.GetOrganizationEgressPolicy(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		policy = database.OrganizationEgressPolicy{AllowedTargets: []string{}, DeniedTargets: []string{}}
+	}
+	err = response.JSON(w, http.StatusOK, policy)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateOrgEgressPolicy replaces the organization's egress policy. It can
+// only narrow what the instance policy allows.
+func (app *application) updateOrgEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AllowedTargets []string            `json:"allowed_targets"`
+		DeniedTargets  []string            `json:"denied_targets"`
+		V              validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.AllowedTargets = normalizeEgressTargets(&input.V, "allowed_targets", input.AllowedTargets)
+	input.DeniedTargets = normalizeEgressTargets(&input.V, "denied_targets", input.DeniedTargets)
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	org := contextGetOrg(r)
+	policy, err := app.db.UpsertOrganizationEgressPolicy(r.Context(), org.ID, input.AllowedTargets, input.DeniedTargets)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "organization egress policy updated", slog.Int64("org_id", org.ID), slog.Int("allowed_targets", len(policy.AllowedTargets)), slog.Int("denied_targets", len(policy.DeniedTargets)))
+	app.recordAudit(r, orgAuditEvent(r, "org.egress_policy.update", "organization", org.ID, map[string]any{
+		"allowed_targets": policy.AllowedTargets,
+		"denied_targets":  policy.DeniedTargets,
+	}))
+	err = response.JSON(w, http.StatusOK, policy)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteOrgEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	deleted, err := app.db.DeleteOrganizationEgressPolicy(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !deleted {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "organization egress policy deleted", slog.Int64("org_id", org.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.egress_policy.delete", "organization", org.ID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeEgressTargets trims, validates, and de-duplicates policy entries.
+func normalizeEgressTargets(v *validator.Validator, field string, targets []string) []string {
+	v.CheckField(len(targets) <= maxEgressPolicyTargets, field, "At most 200 targets are allowed.")
+	normalized := make([]string, 0, len(targets))
+	for _, target := range targets {
+		target = strings.ToLower(strings.TrimSpace(target))
+		if err := egress.ValidateEntry(target); err != nil {
+			v.AddFieldError(field, "Targets must be host names, IP addresses, or CIDR ranges.")
+			continue
+		}
+		normalized = append(normalized, target)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
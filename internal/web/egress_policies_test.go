@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/egress"
+)
+
+func TestOrgEgressPolicyLifecycle(t *testing.T) {
+	t.Parallel()
+	app, org, _, token := setupWorkspaceOwner(t)
+	url := "/api/v1/orgs/" + org.Slug + "/egress-policy"
+
+	res := send(t, newAuthRequest(t, http.MethodGet, url, nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, len(res.BodyFields["allowed_targets"].([]any)), 0)
+
+	res = send(t, newAuthRequest(t, http.MethodPut, url, map[string]any{
+		"allowed_targets": []string{"10.0.0.0/33"},
+	}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "allowed_targets")
+
+	res = send(t, newAuthRequest(t, http.MethodPut, url, map[string]any{
+		"allowed_targets": []string{" *.DB.example.com", "10.0.0.0/8", "*.db.example.com"},
+		"denied_targets":  []string{"10.9.0.0/16"},
+	}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["allowed_targets"].([]any), []any{"*.db.example.com", "10.0.0.0/8"})
+
+	res = send(t, newAuthRequest(t, http.MethodGet, url, nil, token), app.routes())
+	assert.Equal(t, res.BodyFields["denied_targets"].([]any), []any{"10.9.0.0/16"})
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, url, nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	res = send(t, newAuthRequest(t, http.MethodDelete, url, nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestConnectionDialerAppliesEgressPolicy(t *testing.T) {
+	t.Parallel()
+	app, org, ws, _ := setupWorkspaceOwner(t)
+	conn := seedConnection(t, app, ws.ID, nil, org.ID, "postgres", "Orders", "read_write")
+	ctx := context.Background()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	dial := app.connectionDialer(conn, nil)
+	_, err = dial(ctx, "tcp", "169.254.169.254:5432")
+	assert.True(t, errors.Is(err, egress.ErrBlocked))
+
+	c, err := dial(ctx, "tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	c.Close()
+
+	// The organization policy applies to the next dial.
+	_, err = app.db.UpsertOrganizationEgressPolicy(ctx, org.ID, nil, []string{"127.0.0.0/8"})
+	assert.Nil(t, err)
+	_, err = dial(ctx, "tcp", listener.Addr().String())
+	assert.True(t, errors.Is(err, egress.ErrBlocked))
+	assert.Equal(t, connectionTestErrorCategory(err), "egress_blocked")
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine/classifier"
+	"github.com/sqlwarden/internal/engine/safety"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const (
+	apiErrorEnvironmentConfirmationRequired = "environment_confirmation_required"
+	apiErrorUnsafeQueryRefused              = "unsafe_query_refused"
+	apiErrorDDLWindowClosed                 = "ddl_window_closed"
+)
+
+const maxDDLWindows = 20
+
+var ddlWindowDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// validateEnvironmentProtection checks a protection level and its DDL
+// windows. Windows are only allowed on production environments.
+func validateEnvironmentProtection(v *validator.Validator, level string, windows []database.DDLWindow) {
+	v.CheckField(validator.In(level,
+		database.EnvironmentProtectionDevelopment,
+		database.EnvironmentProtectionStaging,
+		database.EnvironmentProtectionProduction,
+	), "protection_level", "Protection level must be development, staging, or production.")
+	if len(windows) == 0 {
+		return
+	}
+	v.CheckField(level == database.EnvironmentProtectionProduction, "ddl_windows", "DDL windows only apply to production environments.")
+	v.CheckField(len(windows) <= maxDDLWindows, "ddl_windows", fmt.Sprintf("At most %d DDL windows are allowed.", maxDDLWindows))
+	for _, window := range windows {
+		v.CheckField(len(window.Days) > 0, "ddl_windows", "Each DDL window needs at least one day.")
+		v.CheckField(validator.AllIn(window.Days, ddlWindowDays...), "ddl_windows", "Days must be sun, mon, tue, wed, thu, fri, or sat.")
+		start, startErr := time.Parse("15:04", window.Start)
+		end, endErr := time.Parse("15:04", window.End)
+		v.CheckField(startErr == nil && endErr == nil, "ddl_windows", "Start and end must be times such as 02:00.")
+		v.CheckField(startErr != nil || endErr != nil || !start.Equal(end), "ddl_windows", "Start and end must differ.")
+		_, tzErr := time.LoadLocation(window.TimeZone)
+		v.CheckField(tzErr == nil, "ddl_windows", "Time zone must be an IANA time zone name such as Europe/Berlin.")
+	}
+}
+
+// ddlWindowOpen reports whether now falls inside window.
+func ddlWindowOpen(window database.DDLWindow, now time.Time) bool {
+	loc, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return false
+	}
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", window.End)
+	if err != nil {
+		return false
+	}
+	now = now.In(loc)
+	minute := now.Hour()*60 + now.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	today := ddlWindowDays[now.Weekday()]
+	yesterday := ddlWindowDays[(now.Weekday()+6)%7]
+
+	if startMinute < endMinute {
+		return slices.Contains(window.Days, today) && minute >= startMinute && minute < endMinute
+	}
+	// The window runs past midnight into the next day.
+	return slices.Contains(window.Days, today) && minute >= startMinute ||
+		slices.Contains(window.Days, yesterday) && minute < endMinute
+}
+
+// environmentRefusal is a production guardrail's reason for refusing SQL.
+type environmentRefusal struct {
+	code    string
+	message string
+	details any
+}
+
+// checkEnvironmentProtection applies the guardrails of env to sql of the
+// given kind as if it ran at now. Nothing is refused unless env is production;
+// there DDL must fall inside a DDL window, DML must not affect every row
+// whatever confirm_unsafe says, and any write must be confirmed by typing the
+// environment name. Unclassified SQL, including batches mixing DML and DDL,
+// is held to both the DDL and the DML rules. It returns nil when sql may run.
+func (app *application) checkEnvironmentProtection(ctx context.Context, env database.Environment, conn database.Connection, sql string, kind classifier.Kind, confirmed bool, now time.Time) (*environmentRefusal, error) {
+	if kind == classifier.KindDQL || env.ProtectionLevel != database.EnvironmentProtectionProduction {
+		return nil, nil
+	}
+	if kind == classifier.KindDDL || kind == classifier.KindUnknown {
+		if !slices.ContainsFunc(env.DDLWindows, func(window database.DDLWindow) bool { return ddlWindowOpen(window, now) }) {
+			windows := env.DDLWindows
+			if windows == nil {
+				windows = []database.DDLWindow{}
+			}
+			return &environmentRefusal{
+				code:    apiErrorDDLWindowClosed,
+				message: "DDL can only run in this production environment during its DDL windows.",
+				details: map[string]any{"ddl_windows": windows},
+			}, nil
+		}
+	}
+	if kind == classifier.KindDML || kind == classifier.KindUnknown {
+		safetyResult, err := connectionSafetyChecker(conn.Driver).Check(ctx, safety.Request{SQL: sql})
+		if err != nil {
+			return nil, err
+		}
+		if safetyResult.Unsafe {
+			return &environmentRefusal{
+				code:    apiErrorUnsafeQueryRefused,
+				message: "This statement has no WHERE clause and would affect every row, which production environments do not allow.",
+				details: safetyResult.Statements,
+			}, nil
+		}
+	}
+	if !confirmed {
+		return &environmentRefusal{
+			code:    apiErrorEnvironmentConfirmationRequired,
+			message: fmt.Sprintf("Type the environment name %q to run this statement in production.", env.Name),
+			details: map[string]any{"environment_name": env.Name, "protection_level": env.ProtectionLevel},
+		}, nil
+	}
+	return nil, nil
+}
+
+// environmentConfirmed reports whether one of confirmations names env.
+func environmentConfirmed(env database.Environment, confirmations ...string) bool {
+	return slices.ContainsFunc(confirmations, func(name string) bool { return strings.TrimSpace(name) == env.Name })
+}
+
+// enforceEnvironmentProtection runs checkEnvironmentProtection for sql about
+// to run on conn now, treating it as confirmed when one of confirmations names
+// the environment. It writes the refusal and returns false when sql may not
+// run.
+func (app *application) enforceEnvironmentProtection(w http.ResponseWriter, r *http.Request, conn database.Connection, sql string, kind classifier.Kind, confirmations ...string) bool {
+	if kind == classifier.KindDQL {
+		return true
+	}
+	env, found, err := app.db.GetEnvironment(r.Context(), conn.EnvironmentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if !found {
+		return true
+	}
+	refusal, err := app.checkEnvironmentProtection(r.Context(), env, conn, sql, kind, environmentConfirmed(env, confirmations...), time.Now())
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if refusal != nil {
+		app.environmentProtectionRefused(w, r, conn, env, kind, refusal)
+		return false
+	}
+	return true
+}
+
+// recheckEnvironmentProtection applies the guardrails again when queued work
+// starts. The environment name was confirmed when the work was queued, so only
+// the DDL window and safety rules can refuse it now.
+func (app *application) recheckEnvironmentProtection(ctx context.Context, conn database.Connection, sql string, kind classifier.Kind) (*environmentRefusal, error) {
+	env, found, err := app.db.GetEnvironment(ctx, conn.EnvironmentID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return app.checkEnvironmentProtection(ctx, env, conn, sql, kind, true, time.Now())
+}
+
+// environmentProtectionRefused logs and writes a guardrail refusal.
+func (app *application) environmentProtectionRefused(w http.ResponseWriter, r *http.Request, conn database.Connection, env database.Environment, kind classifier.Kind, refusal *environmentRefusal) {
+	attrs := []slog.Attr{
+		slog.Int64("connection_id", conn.ID),
+		slog.Int64("environment_id", env.ID),
+		slog.String("query_kind", string(kind)),
+		slog.String("refusal", refusal.code),
+	}
+	if refusal.code == apiErrorEnvironmentConfirmationRequired {
+		app.logInfo(r, "statement awaiting environment confirmation", attrs...)
+	} else {
+		app.logWarn(r, "statement refused by environment protection", attrs...)
+	}
+	app.apiError(w, r, http.StatusUnprocessableEntity, refusal.code, refusal.message, response.APIError{Details: refusal.details}, nil)
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine/classifier"
+)
+
+// openDDLWindows returns DDL windows open for the next hour.
+func openDDLWindows() []database.DDLWindow {
+	now := time.Now().UTC()
+	return []database.DDLWindow{{
+		Days:  ddlWindowDays,
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(time.Hour).Format("15:04"),
+	}}
+}
+
+func TestDDLWindowOpen(t *testing.T) {
+	t.Parallel()
+	// 2026-10-17 is a Saturday.
+	at := func(day, clock string) time.Time {
+		ts, err := time.Parse("2006-01-02 15:04", "2026-10-"+day+" "+clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	weekend := database.DDLWindow{Days: []string{"sat", "sun"}, Start: "02:00", End: "04:00"}
+	overnight := database.DDLWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00"}
+	berlin := database.DDLWindow{Days: []string{"sat"}, Start: "02:00", End: "04:00", TimeZone: "Europe/Berlin"}
+
+	tests := []struct {
+		window database.DDLWindow
+		now    time.Time
+		want   bool
+	}{
+		{weekend, at("17", "02:00"), true},
+		{weekend, at("18", "03:59"), true},
+		{weekend, at("17", "04:00"), false},
+		{weekend, at("16", "03:00"), false},
+		{overnight, at("16", "23:00"), true},
+		{overnight, at("17", "01:30"), true},
+		{overnight, at("17", "02:30"), false},
+		{overnight, at("16", "21:00"), false},
+		{berlin, at("17", "00:30"), true},
+		{berlin, at("17", "02:30"), false},
+	}
+	for _, tt := range tests {
+		if got := ddlWindowOpen(tt.window, tt.now); got != tt.want {
+			t.Errorf("ddlWindowOpen(%+v, %s) = %v, want %v", tt.window, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestProductionEnvironmentGuardrails(t *testing.T) {
+	t.Parallel()
+	app, org, ws, token := setupWorkspaceOwner(t)
+	envsURL := fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/environments", org.Slug, ws.ID)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, envsURL, map[string]any{
+		"name": "Staging", "protection_level": "staging",
+		"ddl_windows": []map[string]any{{"days": []string{"sat"}, "start": "02:00", "end": "04:00"}},
+	}, token), app.routes())
+	assertValidationField(t, res, "ddl_windows")
+	res = send(t, newAuthRequest(t, http.MethodPost, envsURL, map[string]any{"name": "Prod", "protection_level": "prod"}, token), app.routes())
+	assertValidationField(t, res, "protection_level")
+
+	res = send(t, newAuthRequest(t, http.MethodPost, envsURL, map[string]any{"name": "Prod", "protection_level": "production"}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["protection_level"], any("production"))
+	envID := int64(res.BodyFields["id"].(float64))
+
+	res = send(t, newAuthRequest(t, http.MethodPost, orgEnvConnectionsURL(org.Slug, ws.ID, envID),
+		map[string]any{"name": "Orders", "driver": "sqlite", "dsn": ":memory:"}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	connIDInt := int64(res.BodyFields["id"].(float64))
+	connID := fmt.Sprintf("%d", connIDInt)
+	connURL := orgConnectionURL(org.Slug, ws.ID, envID, connID)
+	res = send(t, newAuthRequest(t, http.MethodPost, connURL+"/connect", nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	sessionID := res.BodyFields["session_id"].(string)
+	query := func(path string, body map[string]any) testResponse {
+		req := newAuthRequest(t, http.MethodPost, connURL+path, body, token)
+		req.Header.Set("X-Warden-Session", sessionID)
+		return send(t, req, app.routes())
+	}
+
+	// DDL needs an open window even when confirmed.
+	res = query("/query", map[string]any{"sql": "CREATE TABLE t (id INTEGER)", "confirm_environment": "Prod"})
+	assertAPIError(t, res, apiErrorDDLWindowClosed, "")
+
+	now := time.Now().UTC()
+	res = send(t, newAuthRequest(t, http.MethodPatch, fmt.Sprintf("%s/%d", envsURL, envID), map[string]any{
+		"name": "Prod",
+		"ddl_windows": []map[string]any{{
+			"days":  ddlWindowDays,
+			"start": now.Add(-time.Hour).Format("15:04"),
+			"end":   now.Add(time.Hour).Format("15:04"),
+		}},
+	}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	res = query("/query", map[string]any{"sql": "CREATE TABLE t (id INTEGER)"})
+	assertAPIError(t, res, apiErrorEnvironmentConfirmationRequired, "")
+	res = query("/query", map[string]any{"sql": "CREATE TABLE t (id INTEGER)", "confirm_environment": "Prod"})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	res = query("/query", map[string]any{"sql": "INSERT INTO t (id) VALUES (1), (2)", "confirm_environment": "prod"})
+	assertAPIError(t, res, apiErrorEnvironmentConfirmationRequired, "")
+	res = query("/query", map[string]any{"sql": "INSERT INTO t (id) VALUES (1), (2)", "confirm_environment": "Prod"})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	// confirm_unsafe does not lift the safety check in production.
+	res = query("/query", map[string]any{"sql": "DELETE FROM t", "confirm_unsafe": true, "confirm_environment": "Prod"})
+	assertAPIError(t, res, apiErrorUnsafeQueryRefused, "")
+	res = query("/query", map[string]any{"sql": "DELETE FROM t WHERE id = 1", "confirm_environment": "Prod"})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = query("/query-cursors", map[string]any{"sql": "INSERT INTO t (id) VALUES (3)"})
+	assertAPIError(t, res, apiErrorEnvironmentConfirmationRequired, "")
+	res = query("/query", map[string]any{"sql": "SELECT COUNT(*) AS n FROM t", "use_cursor": false})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, effectivePermissionsURL(org.Slug, "connection", connIDInt), nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["protection_level"], any("production"))
+
+	// Leaving production drops the windows.
+	res = send(t, newAuthRequest(t, http.MethodPatch, fmt.Sprintf("%s/%d", envsURL, envID), map[string]any{
+		"name": "Prod", "protection_level": "staging",
+	}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	env, _, err := app.db.GetEnvironment(t.Context(), envID)
+	assert.Nil(t, err)
+	assert.Equal(t, env.ProtectionLevel, database.EnvironmentProtectionStaging)
+	assert.Equal(t, len(env.DDLWindows), 0)
+	res = query("/query", map[string]any{"sql": "DELETE FROM t", "confirm_unsafe": true})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestProductionEnvironmentHoldsUnknownSQLToDDLAndDMLRules(t *testing.T) {
+	t.Parallel()
+	app, org, ws, _ := setupWorkspaceOwner(t)
+	ctx := t.Context()
+	env, err := app.db.InsertEnvironment(ctx, ws.ID, "Prod", "")
+	assert.Nil(t, err)
+	conn := seedConnection(t, app, ws.ID, &env.ID, org.ID, "postgres", "Orders", "read_write")
+	enforce := func(sql string) string {
+		rec := httptest.NewRecorder()
+		if app.enforceEnvironmentProtection(rec, httptest.NewRequest(http.MethodPost, "/", nil), conn, sql, classifier.KindUnknown, "Prod") {
+			return ""
+		}
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Error.Code
+	}
+	mixed := "DELETE FROM t; DROP TABLE t"
+
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, env.ID, database.EnvironmentProtectionProduction, nil))
+	assert.Equal(t, enforce(mixed), apiErrorDDLWindowClosed)
+
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, env.ID, database.EnvironmentProtectionProduction, openDDLWindows()))
+	assert.Equal(t, enforce(mixed), apiErrorUnsafeQueryRefused)
+	assert.Equal(t, enforce("DELETE FROM t WHERE id = 1; DROP TABLE t"), "")
+}
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sqlwarden/internal/response"
 	"github.com/sqlwarden/internal/smtp"
@@ -21,6 +24,11 @@ const (
 	apiErrorAuthenticationRequired     = "authentication_required"
 	apiErrorInvalidAuthenticationToken = "invalid_authentication_token"
 	apiErrorNotPermitted               = "not_permitted"
+	apiErrorInsufficientScope          = "insufficient_scope"
+	apiErrorInteractiveSessionRequired = "interactive_session_required"
+	apiErrorSSORequired                = "sso_required"
+	apiErrorMFARequired                = "mfa_required"
+	apiErrorRateLimited                = "rate_limited"
 	apiErrorNotFound                   = "not_found"
 	apiErrorMethodNotAllowed           = "method_not_allowed"
 	apiErrorValidationFailed           = "validation_failed"
@@ -39,7 +47,7 @@ func (app *application) reportServerError(r *http.Request, err error) {
 	)
 
 	app.logger.ErrorContext(r.Context(), message,
-		slog.Group("request", attrsToAny(requestAttrs(r))...),
+		slog.Group("request", attrsToAny(app.requestAttrs(r))...),
 		slog.Group("resource", attrsToAny(resourceAttrs(r))...),
 		"trace", trace,
 	)
@@ -66,7 +74,7 @@ func (app *application) reportServerError(r *http.Request, err error) {
 		if err != nil {
 			trace = string(debug.Stack())
 			app.logger.ErrorContext(r.Context(), err.Error(),
-				slog.Group("request", attrsToAny(requestAttrs(r))...),
+				slog.Group("request", attrsToAny(app.requestAttrs(r))...),
 				slog.Group("resource", attrsToAny(resourceAttrs(r))...),
 				"trace", trace,
 			)
@@ -138,6 +146,38 @@ func (app *application) notPermitted(w http.ResponseWriter, r *http.Request) {
 	app.apiError(w, r, http.StatusForbidden, apiErrorNotPermitted, message, response.APIError{}, nil)
 }
 
+func (app *application) insufficientTokenScope(w http.ResponseWriter, r *http.Request) {
+	message := "The API token does not have the scope required for this request."
+	app.apiError(w, r, http.StatusForbidden, apiErrorInsufficientScope, message, response.APIError{}, nil)
+}
+
+func (app *application) interactiveSessionRequired(w http.ResponseWriter, r *http.Request) {
+	message := "This action requires signing in; API tokens cannot be used for it."
+	app.apiError(w, r, http.StatusForbidden, apiErrorInteractiveSessionRequired, message, response.APIError{}, nil)
+}
+
+func (app *application) ssoRequired(w http.ResponseWriter, r *http.Request) {
+	message := "This organization requires signing in through its identity provider."
+	app.apiError(w, r, http.StatusForbidden, apiErrorSSORequired, message, response.APIError{}, nil)
+}
+
+func (app *application) mfaRequired(w http.ResponseWriter, r *http.Request) {
+	message := "Multi-factor authentication is required. Enroll an authenticator app and sign in again."
+	app.apiError(w, r, http.StatusForbidden, apiErrorMFARequired, message, response.APIError{}, nil)
+}
+
+func (app *application) tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, message string) {
+	headers := make(http.Header)
+	headers.Set("Retry-After", retryAfterSeconds(retryAfter))
+
+	app.apiError(w, r, http.StatusTooManyRequests, apiErrorRateLimited, message, response.APIError{}, headers)
+}
+
+// retryAfterSeconds rounds wait up to whole seconds for a Retry-After header.
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(wait.Seconds()))))
+}
+
 // isUniqueViolation returns true if err is a unique-constraint violation from
 // either the PostgreSQL (pgx) or SQLite driver.
 func isUniqueViolation(err error) bool {
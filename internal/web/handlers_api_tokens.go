@@ -0,0 +1,189 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/validator"
+)
+
+// API token scopes. "read" allows safe methods only; "write" allows every
+// method. Scopes narrow a token but never widen the RBAC permissions of the
+// account it belongs to.
+const (
+	apiTokenScopeRead  = "read"
+	apiTokenScopeWrite = "write"
+
+	maxAPITokenNameLength   = 100
+	maxAPITokenLifetimeDays = 365
+)
+
+type apiTokenInput struct {
+	Name          string              `json:"name"`
+	Scopes        []string            `json:"scopes"`
+	ExpiresInDays *int                `json:"expires_in_days"`
+	V             validator.Validator `json:"-"`
+}
+
+// createdAPITokenResponse carries the plaintext token. It is only ever
+// returned by the create endpoints; the server keeps just its hash.
+type createdAPITokenResponse struct {
+	database.APIToken
+	Token string `json:"token"`
+}
+
+func apiTokenAllowsMethod(scopes []string, method string) bool {
+	if slices.Contains(scopes, apiTokenScopeWrite) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(scopes, apiTokenScopeRead)
+	default:
+		return false
+	}
+}
+
+func (input *apiTokenInput) validate() {
+	input.Name = strings.TrimSpace(input.Name)
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(len(input.Name) <= maxAPITokenNameLength, "name", "Name must be 100 characters or fewer.")
+
+	input.V.CheckField(len(input.Scopes) > 0, "scopes", "At least one scope is required.")
+	for _, scope := range input.Scopes {
+		input.V.CheckField(scope == apiTokenScopeRead || scope == apiTokenScopeWrite, "scopes", "Scopes must be read or write.")
+	}
+	slices.Sort(input.Scopes)
+	input.Scopes = slices.Compact(input.Scopes)
+
+	if input.ExpiresInDays != nil {
+		days := *input.ExpiresInDays
+		input.V.CheckField(days >= 1 && days <= maxAPITokenLifetimeDays, "expires_in_days", "Expiry must be between 1 and 365 days.")
+	}
+}
+
+// issueAPIToken generates a token for accountID and stores its hash. orgID
+// pins the token to one organization. mfaVerified records that the creating
+// session presented a second factor.
+func (app *application) issueAPIToken(ctx context.Context, accountID int64, orgID *int64, input apiTokenInput, createdBy int64, mfaVerified bool) (createdAPITokenResponse, error) {
+	plaintext, hash, prefix, err := token.GenerateAPIToken()
+	if err != nil {
+		return createdAPITokenResponse{}, err
+	}
+	var expiresAt *time.Time
+	if input.ExpiresInDays != nil {
+		expiry := time.Now().AddDate(0, 0, *input.ExpiresInDays)
+		expiresAt = &expiry
+	}
+	apiToken, err := app.db.InsertAPIToken(ctx, database.InsertAPITokenParams{
+		AccountID:          accountID,
+		OrgID:              orgID,
+		Name:               input.Name,
+		TokenPrefix:        prefix,
+		TokenHash:          hash,
+		Scopes:             input.Scopes,
+		ExpiresAt:          expiresAt,
+		CreatedByAccountID: &createdBy,
+		MFAVerified:        mfaVerified,
+	})
+	if err != nil {
+		return createdAPITokenResponse{}, err
+	}
+	return createdAPITokenResponse{APIToken: apiToken, Token: plaintext}, nil
+}
+
+func (app *application) listAccountAPITokens(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	q, errs := readListQuery(r.URL.Query(), map[string]string{
+		"created_at": "created_at",
+	})
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+
+	tokens, err := app.db.ListAPITokensPage(r.Context(), database.ListAPITokensParams{
+		AccountID: account.ID,
+		Page:      q.Page,
+		PageSize:  q.PageSize,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, tokens)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createAccountAPIToken(w http.ResponseWriter, r *http.Request) {
+	var input apiTokenInput
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.validate()
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	account := contextGetAccount(r)
+	created, err := app.issueAPIToken(r.Context(), account.ID, nil, input, account.ID, sessionPresentedMFA(contextGetAuthSession(r)))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "personal access token created", slog.Int64("account_id", account.ID), slog.String("api_token_id", created.ID))
+	app.recordAudit(r, instanceAuditEvent("account.api_token.create", "account", account.ID, map[string]any{
+		"api_token_id": created.ID,
+		"name":         created.Name,
+		"scopes":       created.Scopes,
+		"expires_at":   created.ExpiresAt,
+		"mfa_verified": created.MFAVerified,
+	}))
+	err = response.JSON(w, http.StatusCreated, created)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) revokeAccountAPIToken(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	tokenID := strings.TrimSpace(chi.URLParam(r, "token_id"))
+	if tokenID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	apiToken, found, err := app.db.GetAPIToken(r.Context(), tokenID, account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || apiToken.RevokedAt != nil {
+		app.notFound(w, r)
+		return
+	}
+	if err = app.db.RevokeAPIToken(r.Context(), apiToken.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "personal access token revoked", slog.Int64("account_id", account.ID), slog.String("api_token_id", apiToken.ID), slog.String("reason", "user_revoked"))
+	app.recordAudit(r, instanceAuditEvent("account.api_token.revoke", "account", account.ID, map[string]any{"api_token_id": apiToken.ID}))
+	w.WriteHeader(http.StatusNoContent)
+}
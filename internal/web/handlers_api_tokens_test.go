@@ -0,0 +1,166 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/token"
+)
+
+func createAPITokenForTest(t *testing.T, app *application, path, bearer string, body map[string]any) (string, string) {
+	t.Helper()
+
+	res := send(t, newAuthRequest(t, http.MethodPost, path, body, bearer), app.routes())
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create api token: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	plaintext, _ := res.BodyFields["token"].(string)
+	tokenID, _ := res.BodyFields["id"].(string)
+	return tokenID, plaintext
+}
+
+func TestAccountAPITokenLifecycle(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	account, jwt := seedAccountWithToken(t, app, uniqueEmail(t, "pat-owner"), "PAT Owner")
+
+	tokenID, plaintext := createAPITokenForTest(t, app, "/api/v1/account/tokens", jwt, map[string]any{
+		"name":            "ci",
+		"scopes":          []string{"read"},
+		"expires_in_days": 30,
+	})
+	assert.True(t, strings.HasPrefix(plaintext, token.APITokenPrefix))
+
+	stored, found, err := app.db.GetAPIToken(context.Background(), tokenID, account.ID)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, stored.TokenHash, token.Hash(plaintext))
+	assert.True(t, strings.HasPrefix(plaintext, stored.TokenPrefix))
+	assert.NotNil(t, stored.ExpiresAt)
+	assert.Nil(t, stored.LastUsedAt)
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["email"].(string), account.Email)
+
+	stored, _, err = app.db.GetAPIToken(context.Background(), tokenID, account.ID)
+	assert.Nil(t, err)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	list := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account/tokens", nil, jwt), app.routes())
+	assert.Equal(t, list.StatusCode, http.StatusOK)
+	var payload struct {
+		Items []map[string]any `json:"items"`
+	}
+	decodeJSONResponse(t, list.BodyBytes, &payload)
+	assert.Equal(t, len(payload.Items), 1)
+	assert.Equal(t, payload.Items[0]["id"].(string), tokenID)
+	_, hasPlaintext := payload.Items[0]["token"]
+	assert.False(t, hasPlaintext)
+
+	revoke := send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/account/tokens/"+tokenID, nil, jwt), app.routes())
+	assert.Equal(t, revoke.StatusCode, http.StatusNoContent)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, jwt := seedAccountWithToken(t, app, uniqueEmail(t, "pat-scope"), "PAT Scope")
+
+	_, readOnly := createAPITokenForTest(t, app, "/api/v1/account/tokens", jwt, map[string]any{
+		"name":   "read only",
+		"scopes": []string{"read"},
+	})
+	res := send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/account", map[string]any{"name": "Renamed"}, readOnly), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+	assertAPIError(t, res, apiErrorInsufficientScope, "The API token does not have the scope required for this request.")
+
+	_, readWrite := createAPITokenForTest(t, app, "/api/v1/account/tokens", jwt, map[string]any{
+		"name":   "read write",
+		"scopes": []string{"read", "write"},
+	})
+	res = send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/account", map[string]any{"name": "Renamed"}, readWrite), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestAPITokenCannotManageCredentials(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, jwt := seedAccountWithToken(t, app, uniqueEmail(t, "pat-creds"), "PAT Creds")
+
+	_, plaintext := createAPITokenForTest(t, app, "/api/v1/account/tokens", jwt, map[string]any{
+		"name":   "automation",
+		"scopes": []string{"write"},
+	})
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   map[string]any
+	}{
+		{http.MethodPost, "/api/v1/account/tokens", map[string]any{"name": "escalate", "scopes": []string{"write"}}},
+		{http.MethodGet, "/api/v1/account/tokens", nil},
+		{http.MethodPatch, "/api/v1/account/password", map[string]any{"current_password": "x", "new_password": "newpassword"}},
+		{http.MethodDelete, "/api/v1/account/sessions", nil},
+	} {
+		res := send(t, newAuthRequest(t, tc.method, tc.path, tc.body, plaintext), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusForbidden)
+		assertAPIError(t, res, apiErrorInteractiveSessionRequired, "This action requires signing in; API tokens cannot be used for it.")
+	}
+}
+
+func TestAPITokenRejectsExpiredAndUnknownTokens(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	account, _ := seedAccountWithToken(t, app, uniqueEmail(t, "pat-expired"), "PAT Expired")
+
+	plaintext, hash, prefix, err := token.GenerateAPIToken()
+	assert.Nil(t, err)
+	expiredAt := time.Now().Add(-time.Minute)
+	_, err = app.db.InsertAPIToken(context.Background(), database.InsertAPITokenParams{
+		AccountID:   account.ID,
+		Name:        "expired",
+		TokenPrefix: prefix,
+		TokenHash:   hash,
+		Scopes:      []string{"read"},
+		ExpiresAt:   &expiredAt,
+	})
+	assert.Nil(t, err)
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	unknown, _, _, err := token.GenerateAPIToken()
+	assert.Nil(t, err)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account", nil, unknown), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestCreateAPITokenValidation(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, jwt := seedAccountWithToken(t, app, uniqueEmail(t, "pat-validation"), "PAT Validation")
+
+	for _, tc := range []struct {
+		body  map[string]any
+		field string
+	}{
+		{map[string]any{"scopes": []string{"read"}}, "name"},
+		{map[string]any{"name": "ci"}, "scopes"},
+		{map[string]any{"name": "ci", "scopes": []string{"admin"}}, "scopes"},
+		{map[string]any{"name": "ci", "scopes": []string{"read"}, "expires_in_days": 0}, "expires_in_days"},
+		{map[string]any{"name": "ci", "scopes": []string{"read"}, "expires_in_days": 366}, "expires_in_days"},
+	} {
+		res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/tokens", tc.body, jwt), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+		assertValidationField(t, res, tc.field)
+	}
+}
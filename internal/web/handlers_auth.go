@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
 	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/password"
 	"github.com/sqlwarden/internal/request"
@@ -80,6 +81,7 @@ func (app *application) registerAccount(w http.ResponseWriter, r *http.Request)
 	}
 
 	app.logInfo(r, "account registered", slog.Int64("account_id", account.ID))
+	app.recordAudit(r, audit.Event{ActorAccountID: &account.ID, Action: "account.register", ResourceType: "account", ResourceID: strconv.FormatInt(account.ID, 10)})
 	err = response.JSON(w, http.StatusCreated, account)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -107,24 +109,50 @@ func (app *application) loginAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	wait, err := app.loginRetryAfter(r, input.Email, true)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.loginThrottled(w, r, wait)
+		return
+	}
+
 	account, found, err := app.db.GetAccountByEmail(r.Context(), input.Email)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	if !found || account.Password == nil || !account.IsActive {
+	match := false
+	if found && account.Password != nil && account.IsActive {
+		match, err = password.Matches(input.Password, *account.Password)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if !match {
+		var failed *database.Account
+		if found {
+			failed = &account
+		}
+		if err := app.recordLoginFailure(r, input.Email, failed); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 		app.invalidAuthenticationToken(w, r)
 		return
 	}
 
-	match, err := password.Matches(input.Password, *account.Password)
+	methods, err := app.accountMFAMethods(r.Context(), account.ID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
-	if !match {
-		app.invalidAuthenticationToken(w, r)
+	if len(methods) > 0 {
+		app.startMFAChallenge(w, r, account, methods)
 		return
 	}
 
@@ -134,6 +162,12 @@ func (app *application) loginAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	app.logInfo(r, "account logged in", slog.Int64("account_id", account.ID), slog.String("auth_session_id", authSessionID))
+	app.recordAudit(r, audit.Event{
+		ActorAccountID: &account.ID,
+		Action:         "auth.login",
+		ResourceType:   "auth_session",
+		ResourceID:     authSessionID,
+	})
 
 	err = response.JSON(w, http.StatusOK, map[string]string{"access_token": accessToken})
 	if err != nil {
@@ -142,15 +176,25 @@ func (app *application) loginAccount(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) issueAccountSession(w http.ResponseWriter, r *http.Request, account database.Account) (string, string, error) {
+	return app.issueAuthSession(w, r, account, database.AuthSessionSignIn{})
+}
+
+// issueAuthSession starts an auth session, sets its refresh cookie and returns
+// an access token. signIn records the OpenID Connect issuer the account signed
+// in through, or the second factor it presented, if any.
+func (app *application) issueAuthSession(w http.ResponseWriter, r *http.Request, account database.Account, signIn database.AuthSessionSignIn) (string, string, error) {
 	const refreshTTL = 7 * 24 * time.Hour
 	family := database.NewID()
-	authSession, _, err := app.db.CreateAuthSessionWithRefreshToken(
-		r.Context(), account.ID, time.Now().Add(refreshTTL), r.Header.Get("User-Agent"),
+	authSession, _, err := app.db.CreateSignInAuthSessionWithRefreshToken(
+		r.Context(), account.ID, signIn, time.Now().Add(refreshTTL), r.Header.Get("User-Agent"),
 		r.RemoteAddr, token.Hash(family), family,
 	)
 	if err != nil {
 		return "", "", err
 	}
+	if err := app.clearLoginFailures(r.Context(), account.Email); err != nil {
+		return "", "", err
+	}
 	settings, err := app.runtimeSettingsService().effectiveForOrg(r.Context(), nil)
 	if err != nil {
 		return "", "", err
@@ -174,11 +218,29 @@ func (app *application) refreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	wait, err := app.clientRetryAfter(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.loginThrottled(w, r, wait)
+		return
+	}
+
 	rt, found, err := app.db.GetRefreshTokenByHash(r.Context(), token.Hash(cookie.Value))
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+	if !found {
+		// Unknown tokens are guesses, not stale sessions; count them against
+		// the client.
+		if err := app.recordClientFailure(r); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 	if !found || rt.RevokedAt != nil || time.Now().After(rt.ExpiresAt) {
 		if found && rt.RevokedAt == nil {
 			_ = app.db.RevokeFamilyTokens(r.Context(), rt.Family)
@@ -276,6 +338,12 @@ func (app *application) logoutAccount(w http.ResponseWriter, r *http.Request) {
 			if rt.AuthSessionID != "" {
 				_ = app.db.RevokeAuthSession(r.Context(), rt.AuthSessionID, &rt.AccountID, "logout")
 				app.logInfo(r, "account logged out", slog.Int64("account_id", rt.AccountID), slog.String("auth_session_id", rt.AuthSessionID))
+				app.recordAudit(r, audit.Event{
+					ActorAccountID: &rt.AccountID,
+					Action:         "auth.logout",
+					ResourceType:   "auth_session",
+					ResourceID:     rt.AuthSessionID,
+				})
 			}
 		}
 	}
@@ -357,6 +425,7 @@ func (app *application) revokeAccountSession(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	app.logInfo(r, "auth session revoked", slog.Int64("target_account_id", account.ID), slog.String("auth_session_id", session.ID), slog.String("reason", "user_revoked"))
+	app.recordAudit(r, instanceAuditEvent("auth.session.revoke", "account", account.ID, map[string]any{"auth_session_id": session.ID, "reason": "user_revoked"}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -367,6 +436,7 @@ func (app *application) revokeAccountSessions(w http.ResponseWriter, r *http.Req
 		return
 	}
 	app.logInfo(r, "auth sessions revoked", slog.Int64("target_account_id", account.ID), slog.String("reason", "user_revoked_all"))
+	app.recordAudit(r, instanceAuditEvent("auth.session.revoke_all", "account", account.ID, map[string]any{"reason": "user_revoked_all"}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -429,6 +499,7 @@ func (app *application) revokeInstanceAccountSession(w http.ResponseWriter, r *h
 		return
 	}
 	app.logInfo(r, "auth session revoked", slog.Int64("target_account_id", accountID), slog.String("auth_session_id", session.ID), slog.String("reason", "instance_admin_revoked"))
+	app.recordAudit(r, instanceAuditEvent("auth.session.revoke", "account", accountID, map[string]any{"auth_session_id": session.ID, "reason": "instance_admin_revoked"}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -451,6 +522,7 @@ func (app *application) revokeInstanceAccountSessions(w http.ResponseWriter, r *
 		return
 	}
 	app.logInfo(r, "auth sessions revoked", slog.Int64("target_account_id", accountID), slog.String("reason", "instance_admin_revoked_all"))
+	app.recordAudit(r, instanceAuditEvent("auth.session.revoke_all", "account", accountID, map[string]any{"reason": "instance_admin_revoked_all"}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -511,6 +583,7 @@ func (app *application) revokeOrgMemberAccessSession(w http.ResponseWriter, r *h
 		return
 	}
 	app.logInfo(r, "org access session revoked", slog.Int64("target_account_id", accountID), slog.String("org_access_session_id", session.ID), slog.String("reason", "org_admin_revoked"))
+	app.recordAudit(r, orgAuditEvent(r, "org.access_session.revoke", "account", accountID, map[string]any{"org_access_session_id": session.ID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -606,6 +679,7 @@ func (app *application) updateAccountPassword(w http.ResponseWriter, r *http.Req
 	}
 
 	app.logInfo(r, "account password changed", slog.Int64("account_id", account.ID))
+	app.recordAudit(r, instanceAuditEvent("account.password.change", "account", account.ID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
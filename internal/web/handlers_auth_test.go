@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/agent"
 	"github.com/sqlwarden/internal/assert"
 	"github.com/sqlwarden/internal/cache"
 	completionapp "github.com/sqlwarden/internal/completion"
@@ -32,11 +33,13 @@ func newTestApp(t *testing.T) *application {
 	}
 	app.enforcer = enforcer
 	app.connManager = connection.New(30 * time.Minute)
+	app.agentHub = agent.NewHub(agent.DefaultHeartbeatInterval)
 	app.schemaService = schemaapp.NewService(cache.NewMemCache(schemaCacheCapacity), schemaCacheTTL)
 	app.schemaSnapshots = schemaapp.NewSnapshotStore(app.db)
 	app.completionService = completionapp.NewService()
 	app.configureConnectionCacheInvalidation()
 	t.Cleanup(func() { app.connManager.Close() })
+	t.Cleanup(app.agentHub.Close)
 	return app
 }
 
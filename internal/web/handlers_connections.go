@@ -3,8 +3,10 @@ package web
 import (
 	"context"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,10 +14,13 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/agent"
 	"github.com/sqlwarden/internal/connection"
 	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/egress"
 	"github.com/sqlwarden/internal/engine"
 	"github.com/sqlwarden/internal/engine/classifier"
+	"github.com/sqlwarden/internal/engine/connconfig"
 	metadata "github.com/sqlwarden/internal/engine/metadata"
 	"github.com/sqlwarden/internal/engine/safety"
 	"github.com/sqlwarden/internal/jobs"
@@ -247,13 +252,22 @@ func connectionSafetyChecker(driverName string) safety.Checker {
 
 func (app *application) createConnection(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name          string              `json:"name"`
-		Driver        string              `json:"driver"`
-		DSN           string              `json:"dsn"`
-		EnvironmentID *int64              `json:"environment_id"`
-		AccessMode    string              `json:"access_mode"`
-		DefaultScope  metadata.ScopePath  `json:"default_scope,omitempty"`
-		V             validator.Validator `json:"-"`
+		Name              string                           `json:"name"`
+		Driver            string                           `json:"driver"`
+		DSN               string                           `json:"dsn"`
+		Config            *connconfig.Config               `json:"config"`
+		CredentialRef     string                           `json:"credential_ref"`
+		CredentialMode    string                           `json:"credential_mode"`
+		ReadReplicas      []database.ConnectionReadReplica `json:"read_replicas"`
+		SSHTunnel         *connection.TunnelConfig         `json:"ssh_tunnel"`
+		EnvironmentID     *int64                           `json:"environment_id"`
+		AccessMode        string                           `json:"access_mode"`
+		DefaultScope      metadata.ScopePath               `json:"default_scope,omitempty"`
+		HealthCheck       bool                             `json:"health_check_enabled"`
+		QueryMaxExecution *int64                           `json:"query_max_execution_seconds"`
+		SessionInit       *database.SessionInit            `json:"session_init"`
+		AgentID           *int64                           `json:"agent_id"`
+		V                 validator.Validator              `json:"-"`
 	}
 
 	err := request.DecodeJSON(w, r, &input)
@@ -264,13 +278,32 @@ func (app *application) createConnection(w http.ResponseWriter, r *http.Request)
 
 	input.V.CheckField(input.Name != "", "name", "Name is required.")
 	input.V.CheckField(input.Driver != "", "driver", "Driver is required.")
-	input.V.CheckField(input.DSN != "", "dsn", "DSN is required.")
-	if input.Driver != "" {
+	input.V.CheckField(input.DSN != "" || input.Config != nil, "dsn", "DSN or structured settings are required.")
+	input.V.CheckField(input.DSN == "" || input.Config == nil, "config", "Use either a DSN or structured settings, not both.")
+	validateSSHTunnel(&input.V, input.Driver, input.SSHTunnel)
+	if input.Driver != "" && input.Config != nil && input.DSN == "" {
+		input.DSN = buildConnectionDSN(&input.V, input.Driver, *input.Config)
+	} else if input.Driver != "" && input.DSN != "" {
+		input.Config = parseConnectionDSN(input.Driver, input.DSN)
+	}
+	input.CredentialRef = strings.TrimSpace(input.CredentialRef)
+	if input.CredentialMode == "" {
+		input.CredentialMode = database.CredentialModeShared
+	}
+	if input.Driver != "" && !input.V.HasErrors() {
+		app.validateCredentialRef(&input.V, input.Driver, input.CredentialRef, input.Config)
+		validateCredentialMode(&input.V, contextGetWorkspace(r), input.Driver, input.CredentialMode, input.Config)
+		validateReadReplicas(&input.V, input.Driver, input.ReadReplicas, input.Config)
+		validateSessionInit(r.Context(), &input.V, input.Driver, input.SessionInit)
+	}
+	if input.Driver != "" && input.DSN != "" {
 		if err := app.validateTargetConnection(input.Driver, input.DSN); err != nil {
 			if errors.Is(err, errSQLiteTargetDisabled) {
 				app.logWarn(r, "sqlite target connection blocked", slog.String("operation", "create_connection"), slog.String("driver", input.Driver))
 			}
 			input.V.CheckField(false, "driver", targetConnectionFieldError(err))
+		} else if !input.V.HasErrors() {
+			app.validateReadReplicaTargets(&input.V, input.Driver, input.DSN, input.ReadReplicas)
 		}
 	}
 	if input.AccessMode == "" {
@@ -280,6 +313,10 @@ func (app *application) createConnection(w http.ResponseWriter, r *http.Request)
 		input.AccessMode == "open" || input.AccessMode == "restricted",
 		"access_mode", "Access mode must be open or restricted.",
 	)
+	if err := app.validateConnectionAgent(r.Context(), &input.V, contextGetWorkspace(r), input.Driver, input.AgentID, input.SSHTunnel != nil); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
@@ -291,6 +328,16 @@ func (app *application) createConnection(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
+	configEncrypted, err := app.encryptConnectionConfig(input.Config)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	sshTunnelEncrypted, err := app.encryptSSHTunnel(input.SSHTunnel)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	ws := contextGetWorkspace(r)
 	env := contextGetEnvironment(r)
@@ -309,17 +356,72 @@ func (app *application) createConnection(w http.ResponseWriter, r *http.Request)
 			return
 		}
 	}
+	if input.QueryMaxExecution != nil {
+		parent, err := app.environmentQueryExecutionLimit(r.Context(), ws, targetEnvID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		validateQueryExecutionOverride(&input.V, input.QueryMaxExecution, parent)
+		if input.V.HasErrors() {
+			app.failedValidation(w, r, input.V)
+			return
+		}
+	}
 
-	conn, err := app.db.InsertConnectionWithScope(context.Background(),
+	conn, err := app.db.InsertConnectionWithConfig(context.Background(),
 		ws.ID, targetEnvID,
-		input.Name, input.Driver, dsnEncrypted, input.AccessMode, input.DefaultScope,
+		input.Name, input.Driver, dsnEncrypted, configEncrypted, input.CredentialRef, input.CredentialMode, sshTunnelEncrypted, input.AccessMode, input.DefaultScope,
 	)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+	if len(input.ReadReplicas) > 0 {
+		if err := app.db.ReplaceConnectionReadReplicas(r.Context(), conn.ID, input.ReadReplicas); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if input.HealthCheck {
+		if err := app.db.UpdateConnectionHealthCheck(r.Context(), conn.ID, true); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		conn.HealthCheckEnabled = true
+	}
+	if input.QueryMaxExecution != nil {
+		if err := app.db.UpdateConnectionQueryMaxExecution(r.Context(), conn.ID, input.QueryMaxExecution); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		conn.QueryMaxExecutionSeconds = input.QueryMaxExecution
+	}
+	if input.SessionInit != nil {
+		if err := app.db.UpdateConnectionSessionInit(r.Context(), conn.ID, input.SessionInit); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		conn.SessionInit = input.SessionInit
+	}
+	if input.AgentID != nil {
+		if err := app.db.UpdateConnectionAgent(r.Context(), conn.ID, input.AgentID); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		conn.AgentID = input.AgentID
+	}
+	conn, err = app.withMaskedConfig(conn)
+	if err == nil {
+		conn, err = app.withReadReplicas(r.Context(), conn)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	app.logInfo(r, "connection created", slog.Int64("workspace_id", ws.ID), slog.Int64("connection_id", conn.ID), slog.String("driver", conn.Driver), slog.String("access_mode", conn.AccessMode))
+	app.logInfo(r, "connection created", slog.Int64("workspace_id", ws.ID), slog.Int64("connection_id", conn.ID), slog.String("driver", conn.Driver), slog.String("access_mode", conn.AccessMode), slog.Bool("ssh_tunnel", conn.SSHTunnelEnabled), slog.Bool("structured_config", conn.Config != nil), slog.Bool("credential_ref", conn.CredentialRef != ""), slog.String("credential_mode", conn.CredentialMode), slog.Int("read_replicas", len(conn.ReadReplicas)), slog.Bool("health_check", conn.HealthCheckEnabled), slog.Bool("query_time_limit", conn.QueryMaxExecutionSeconds != nil), slog.Bool("session_init", conn.SessionInit != nil), slog.Bool("connector_agent", conn.AgentID != nil))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.create", "connection", conn.ID, map[string]any{"driver": conn.Driver, "access_mode": conn.AccessMode, "ssh_tunnel": conn.SSHTunnelEnabled, "agent_id": conn.AgentID, "structured_config": conn.Config != nil, "credential_ref": conn.CredentialRef, "credential_mode": conn.CredentialMode, "read_replicas": len(conn.ReadReplicas), "health_check": conn.HealthCheckEnabled}))
 	err = response.JSON(w, http.StatusCreated, conn)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -342,30 +444,64 @@ func (app *application) getConnection(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	err := response.JSON(w, http.StatusOK, conn)
+	conn, err := app.withMaskedConfig(conn)
+	if err == nil {
+		conn, err = app.withReadReplicas(r.Context(), conn)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, conn)
 	if err != nil {
 		app.serverError(w, r, err)
 	}
 }
 
-// getConnectionDSN reveals the decrypted DSN so it can be pre-filled when editing a
-// connection. The route requires conn:update, since holding conn:update is what makes
-// re-entering the DSN unnecessary.
+// getConnectionDSN reveals the decrypted DSN and structured config so they can be
+// pre-filled when editing a connection. The route requires conn:update, since holding
+// conn:update is what makes re-entering the DSN unnecessary. When the org masks
+// credentials on edit, structured connections still return their settings with
+// secrets masked, and the DSN assembled from them; raw DSNs cannot be masked
+// field by field, so they stay forbidden.
 func (app *application) getConnectionDSN(w http.ResponseWriter, r *http.Request) {
 	org := contextGetOrg(r)
-	if org.MaskConnectionCredentialsOnEdit {
-		app.notPermitted(w, r)
-		return
-	}
-
 	conn := contextGetConnection(r)
-	dsn, err := app.keyring.Decrypt(conn.DSNEncrypted)
+	cfg, err := app.connectionConfig(conn)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
-	app.logInfo(r, "connection dsn revealed", slog.Int64("connection_id", conn.ID))
-	err = response.JSON(w, http.StatusOK, map[string]string{"dsn": dsn})
+
+	var dsn string
+	masked := org.MaskConnectionCredentialsOnEdit
+	if masked {
+		builder, ok := connectionBuilder(conn.Driver)
+		if cfg == nil || !ok {
+			app.notPermitted(w, r)
+			return
+		}
+		// The masked DSN leaves the secrets out entirely; the client
+		// certificate goes with its key since neither is part of the DSN.
+		display := *cfg
+		display.Password, display.ClientCert, display.ClientKey = "", "", ""
+		dsn, err = builder.BuildDSN(display)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		maskedConfig := cfg.Masked()
+		cfg = &maskedConfig
+	} else {
+		dsn, err = app.keyring.Decrypt(conn.DSNEncrypted)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	app.logInfo(r, "connection dsn revealed", slog.Int64("connection_id", conn.ID), slog.Bool("masked", masked))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.dsn.reveal", "connection", conn.ID, map[string]any{"masked": masked}))
+	err = response.JSON(w, http.StatusOK, map[string]any{"dsn": dsn, "config": cfg})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
@@ -373,14 +509,24 @@ func (app *application) getConnectionDSN(w http.ResponseWriter, r *http.Request)
 
 func (app *application) updateConnection(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name                 *string             `json:"name"`
-		Driver               *string             `json:"driver"`
-		DSN                  *string             `json:"dsn"`
-		AccessMode           *string             `json:"access_mode"`
-		SchemaSnapshotPolicy *string             `json:"schema_snapshot_policy"`
-		DefaultScope         *metadata.ScopePath `json:"default_scope"`
-		Force                bool                `json:"force"`
-		V                    validator.Validator `json:"-"`
+		Name                 *string                             `json:"name"`
+		Driver               *string                             `json:"driver"`
+		DSN                  *string                             `json:"dsn"`
+		Config               *connconfig.Config                  `json:"config"`
+		CredentialRef        *string                             `json:"credential_ref"`
+		CredentialMode       *string                             `json:"credential_mode"`
+		ReadReplicas         *[]database.ConnectionReadReplica   `json:"read_replicas"`
+		AccessMode           *string                             `json:"access_mode"`
+		SchemaSnapshotPolicy *string                             `json:"schema_snapshot_policy"`
+		DefaultScope         *metadata.ScopePath                 `json:"default_scope"`
+		HealthCheck          *bool                               `json:"health_check_enabled"`
+		QueryMaxExecution    nullablePatch[int64]                `json:"query_max_execution_seconds"`
+		SessionInit          nullablePatch[database.SessionInit] `json:"session_init"`
+		SSHTunnel            *connection.TunnelConfig            `json:"ssh_tunnel"`
+		RemoveSSHTunnel      bool                                `json:"remove_ssh_tunnel"`
+		AgentID              nullablePatch[int64]                `json:"agent_id"`
+		Force                bool                                `json:"force"`
+		V                    validator.Validator                 `json:"-"`
 	}
 
 	err := request.DecodeJSON(w, r, &input)
@@ -407,7 +553,10 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 			*input.SchemaSnapshotPolicy == database.SchemaSnapshotPolicyDisabled,
 			"schema_snapshot_policy", "Schema snapshot policy must be inherit or disabled.")
 	}
-	input.V.CheckField(input.Name != nil || input.DSN != nil || input.AccessMode != nil || input.SchemaSnapshotPolicy != nil || input.DefaultScope != nil,
+	input.V.CheckField(input.DSN == nil || input.Config == nil, "config", "Use either a DSN or structured settings, not both.")
+	input.V.CheckField(input.SSHTunnel == nil || !input.RemoveSSHTunnel, "ssh_tunnel", "Set or remove the SSH tunnel, not both.")
+	input.V.CheckField(input.Name != nil || input.DSN != nil || input.Config != nil || input.CredentialRef != nil || input.CredentialMode != nil || input.ReadReplicas != nil || input.AccessMode != nil || input.SchemaSnapshotPolicy != nil || input.DefaultScope != nil ||
+		input.HealthCheck != nil || input.QueryMaxExecution.Set || input.SessionInit.Set || input.SSHTunnel != nil || input.RemoveSSHTunnel || input.AgentID.Set,
 		"request", "At least one setting is required.")
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
@@ -420,9 +569,59 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
+	currentConfig, err := app.connectionConfig(conn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	nextDSN := currentDSN
+	nextConfig := currentConfig
 	if input.DSN != nil {
 		nextDSN = *input.DSN
+		nextConfig = parseConnectionDSN(conn.Driver, nextDSN)
+	}
+	if input.Config != nil {
+		// Secrets left masked keep their stored values.
+		merged := *input.Config
+		if currentConfig != nil {
+			merged = merged.WithSecretsFrom(*currentConfig)
+		}
+		nextDSN = buildConnectionDSN(&input.V, conn.Driver, merged)
+		if input.V.HasErrors() {
+			app.failedValidation(w, r, input.V)
+			return
+		}
+		nextConfig = &merged
+	}
+	configChanged := !reflect.DeepEqual(currentConfig, nextConfig)
+	nextCredentialRef := conn.CredentialRef
+	if input.CredentialRef != nil {
+		nextCredentialRef = strings.TrimSpace(*input.CredentialRef)
+	}
+	credentialRefChanged := nextCredentialRef != conn.CredentialRef
+	app.validateCredentialRef(&input.V, conn.Driver, nextCredentialRef, nextConfig)
+	nextCredentialMode := conn.CredentialMode
+	if input.CredentialMode != nil {
+		nextCredentialMode = *input.CredentialMode
+	}
+	credentialModeChanged := nextCredentialMode != conn.CredentialMode
+	validateCredentialMode(&input.V, contextGetWorkspace(r), conn.Driver, nextCredentialMode, nextConfig)
+	currentReplicas, err := app.db.ListConnectionReadReplicas(r.Context(), conn.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	nextReplicas := currentReplicas
+	if input.ReadReplicas != nil {
+		nextReplicas = *input.ReadReplicas
+	}
+	// Existing replicas are checked too, since they stop working when the
+	// connection moves to a raw DSN.
+	validateReadReplicas(&input.V, conn.Driver, nextReplicas, nextConfig)
+	replicasChanged := input.ReadReplicas != nil && !sameReadReplicas(currentReplicas, nextReplicas)
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
 	}
 	if err := app.validateTargetConnection(conn.Driver, nextDSN); err != nil {
 		if errors.Is(err, errSQLiteTargetDisabled) {
@@ -433,9 +632,53 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 		app.failedValidation(w, r, v)
 		return
 	}
+	app.validateReadReplicaTargets(&input.V, conn.Driver, nextDSN, nextReplicas)
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	currentTunnel, err := app.connectionTunnelConfig(conn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if input.SSHTunnel != nil && !input.SSHTunnel.HasSecret() && currentTunnel != nil {
+		// Editing the jump host without re-entering its key or password keeps
+		// the stored credentials.
+		input.SSHTunnel.PrivateKey = currentTunnel.PrivateKey
+		input.SSHTunnel.Passphrase = currentTunnel.Passphrase
+		input.SSHTunnel.Password = currentTunnel.Password
+	}
+	validateSSHTunnel(&input.V, conn.Driver, input.SSHTunnel)
+	if input.QueryMaxExecution.Set && input.QueryMaxExecution.Value != nil {
+		parent, err := app.environmentQueryExecutionLimit(r.Context(), contextGetWorkspace(r), &conn.EnvironmentID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		validateQueryExecutionOverride(&input.V, input.QueryMaxExecution.Value, parent)
+	}
+	validateSessionInit(r.Context(), &input.V, conn.Driver, input.SessionInit.Value)
+	nextAgentID := conn.AgentID
+	if input.AgentID.Set {
+		nextAgentID = input.AgentID.Value
+	}
+	nextHasTunnel := input.SSHTunnel != nil || (currentTunnel != nil && !input.RemoveSSHTunnel)
+	if err := app.validateConnectionAgent(r.Context(), &input.V, contextGetWorkspace(r), conn.Driver, nextAgentID, nextHasTunnel); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+	tunnelChanged := (input.RemoveSSHTunnel && currentTunnel != nil) ||
+		(input.SSHTunnel != nil && (currentTunnel == nil || !input.SSHTunnel.Equal(*currentTunnel)))
+	agentChanged := input.AgentID.Set && !reflect.DeepEqual(nextAgentID, conn.AgentID)
 
 	dsnEncrypted := conn.DSNEncrypted
-	if input.DSN != nil {
+	if input.DSN != nil || input.Config != nil {
 		dsnEncrypted, err = app.keyring.Encrypt(nextDSN)
 	}
 	if err != nil {
@@ -443,11 +686,21 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	dsnChanged := currentDSN != nextDSN
-	if dsnChanged {
+	// Certificates live only in the structured config, and credentials behind
+	// a secret reference or stored per user not in the DSN at all, so any of
+	// those changes reconnects even when the stored DSN is unchanged. Sessions
+	// keep the replicas they opened with, so a new replica list does too.
+	dsnChanged := currentDSN != nextDSN || configChanged || credentialRefChanged || credentialModeChanged || replicasChanged
+	if dsnChanged || tunnelChanged || agentChanged {
 		activeSessions := app.connManager.CountForConnection(strconv.FormatInt(conn.ID, 10))
 		if activeSessions > 0 && !input.Force {
-			app.errorMessage(w, r, http.StatusConflict, "Connection has active sessions. Retry with force=true to rotate the DSN and drop them.", nil)
+			message := "Connection has active sessions. Retry with force=true to rotate the DSN and drop them."
+			if !dsnChanged && tunnelChanged {
+				message = "Connection has active sessions. Retry with force=true to change its SSH tunnel and drop them."
+			} else if !dsnChanged {
+				message = "Connection has active sessions. Retry with force=true to change its connector agent and drop them."
+			}
+			app.errorMessage(w, r, http.StatusConflict, message, nil)
 			return
 		}
 		if input.Force && activeSessions > 0 {
@@ -475,10 +728,17 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 		nextDefaultScope = *input.DefaultScope
 	}
 	scopeChanged := nextDefaultScope != conn.DefaultScope
-	if scopeChanged && !dsnChanged {
+	// Sessions run their init only when they open, so changing it drops them
+	// like a new default scope does.
+	sessionInitChanged := input.SessionInit.Set && !reflect.DeepEqual(input.SessionInit.Value, conn.SessionInit)
+	if (scopeChanged || sessionInitChanged) && !dsnChanged && !tunnelChanged && !agentChanged {
 		activeSessions := app.connManager.CountForConnection(strconv.FormatInt(conn.ID, 10))
 		if activeSessions > 0 && !input.Force {
-			app.errorMessage(w, r, http.StatusConflict, "Connection has active sessions. Retry with force=true to change its default scope and drop them.", nil)
+			message := "Connection has active sessions. Retry with force=true to change its default scope and drop them."
+			if !scopeChanged {
+				message = "Connection has active sessions. Retry with force=true to change its session initialization and drop them."
+			}
+			app.errorMessage(w, r, http.StatusConflict, message, nil)
 			return
 		}
 		if input.Force && activeSessions > 0 {
@@ -490,6 +750,74 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
+	if configChanged {
+		configEncrypted, err := app.encryptConnectionConfig(nextConfig)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if err := app.db.UpdateConnectionConfig(r.Context(), conn.ID, configEncrypted); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if credentialRefChanged {
+		if err := app.db.UpdateConnectionCredentialRef(r.Context(), conn.ID, nextCredentialRef); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if credentialModeChanged {
+		if err := app.db.UpdateConnectionCredentialMode(r.Context(), conn.ID, nextCredentialMode); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if replicasChanged {
+		if err := app.db.ReplaceConnectionReadReplicas(r.Context(), conn.ID, nextReplicas); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	healthCheckChanged := input.HealthCheck != nil && *input.HealthCheck != conn.HealthCheckEnabled
+	if healthCheckChanged {
+		if err := app.db.UpdateConnectionHealthCheck(r.Context(), conn.ID, *input.HealthCheck); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if input.QueryMaxExecution.Set {
+		if err := app.db.UpdateConnectionQueryMaxExecution(r.Context(), conn.ID, input.QueryMaxExecution.Value); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if sessionInitChanged {
+		if err := app.db.UpdateConnectionSessionInit(r.Context(), conn.ID, input.SessionInit.Value); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if tunnelChanged {
+		var sshTunnelEncrypted string
+		if input.SSHTunnel != nil {
+			sshTunnelEncrypted, err = app.encryptSSHTunnel(input.SSHTunnel)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+		if err := app.db.UpdateConnectionSSHTunnel(r.Context(), conn.ID, sshTunnelEncrypted); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if agentChanged {
+		if err := app.db.UpdateConnectionAgent(r.Context(), conn.ID, nextAgentID); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 	if conn.SchemaSnapshotPolicy != database.SchemaSnapshotPolicyDisabled &&
 		nextSnapshotPolicy == database.SchemaSnapshotPolicyDisabled {
 		if err := app.disableConnectionSnapshots(r.Context(), conn.ID); err != nil {
@@ -511,7 +839,8 @@ func (app *application) updateConnection(w http.ResponseWriter, r *http.Request)
 			}
 		}
 	}
-	app.logInfo(r, "connection updated", slog.Int64("connection_id", conn.ID), slog.Bool("dsn_rotated", dsnChanged), slog.Bool("scope_changed", scopeChanged), slog.String("access_mode", nextAccessMode), slog.String("schema_snapshot_policy", nextSnapshotPolicy))
+	app.logInfo(r, "connection updated", slog.Int64("connection_id", conn.ID), slog.Bool("dsn_rotated", dsnChanged), slog.Bool("credential_ref_changed", credentialRefChanged), slog.String("credential_mode", nextCredentialMode), slog.Bool("read_replicas_changed", replicasChanged), slog.Bool("health_check_changed", healthCheckChanged), slog.Bool("query_time_limit_changed", input.QueryMaxExecution.Set), slog.Bool("session_init_changed", sessionInitChanged), slog.Bool("ssh_tunnel_changed", tunnelChanged), slog.Bool("connector_agent_changed", agentChanged), slog.Bool("scope_changed", scopeChanged), slog.String("access_mode", nextAccessMode), slog.String("schema_snapshot_policy", nextSnapshotPolicy))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.update", "connection", conn.ID, map[string]any{"dsn_rotated": dsnChanged, "credential_ref": nextCredentialRef, "credential_mode": nextCredentialMode, "read_replicas_changed": replicasChanged, "health_check_changed": healthCheckChanged, "query_time_limit_changed": input.QueryMaxExecution.Set, "session_init_changed": sessionInitChanged, "ssh_tunnel_changed": tunnelChanged, "agent_id": nextAgentID, "scope_changed": scopeChanged, "access_mode": nextAccessMode}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -524,15 +853,20 @@ func (app *application) deleteConnection(w http.ResponseWriter, r *http.Request)
 	}
 	app.enforcer.InvalidateAncestry("connection", conn.ID)
 	app.logInfo(r, "connection deleted", slog.Int64("connection_id", conn.ID), slog.Int64("workspace_id", conn.WorkspaceID), slog.String("driver", conn.Driver))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.delete", "connection", conn.ID, map[string]any{"driver": conn.Driver}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (app *application) testConnection(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Driver      string              `json:"driver"`
-		DSN         string              `json:"dsn"`
-		ParentScope metadata.ScopePath  `json:"parent_scope,omitempty"`
-		V           validator.Validator `json:"-"`
+		Driver        string                   `json:"driver"`
+		DSN           string                   `json:"dsn"`
+		Config        *connconfig.Config       `json:"config"`
+		CredentialRef string                   `json:"credential_ref"`
+		SSHTunnel     *connection.TunnelConfig `json:"ssh_tunnel"`
+		AgentID       *int64                   `json:"agent_id"`
+		ParentScope   metadata.ScopePath       `json:"parent_scope,omitempty"`
+		V             validator.Validator      `json:"-"`
 	}
 
 	err := request.DecodeJSON(w, r, &input)
@@ -542,7 +876,20 @@ func (app *application) testConnection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	input.V.CheckField(input.Driver != "", "driver", "Driver is required.")
-	input.V.CheckField(input.DSN != "", "dsn", "DSN is required.")
+	input.V.CheckField(input.DSN != "" || input.Config != nil, "dsn", "DSN or structured settings are required.")
+	input.V.CheckField(input.DSN == "" || input.Config == nil, "config", "Use either a DSN or structured settings, not both.")
+	validateSSHTunnel(&input.V, input.Driver, input.SSHTunnel)
+	if input.Driver != "" && input.Config != nil && input.DSN == "" {
+		input.DSN = buildConnectionDSN(&input.V, input.Driver, *input.Config)
+	}
+	input.CredentialRef = strings.TrimSpace(input.CredentialRef)
+	if input.Driver != "" && !input.V.HasErrors() {
+		app.validateCredentialRef(&input.V, input.Driver, input.CredentialRef, input.Config)
+	}
+	if err := app.validateConnectionAgent(r.Context(), &input.V, contextGetWorkspace(r), input.Driver, input.AgentID, input.SSHTunnel != nil); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
 		return
@@ -566,8 +913,9 @@ func (app *application) testConnection(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		app.logWarn(r, "connection test failed", slog.String("driver", input.Driver), slog.Int64("latency_ms", time.Since(start).Milliseconds()), slog.String("stage", "driver_init"), slog.String("error_category", connectionTestErrorCategory(err)))
 		err = response.JSON(w, http.StatusUnprocessableEntity, map[string]any{
-			"ok":    false,
-			"error": err.Error(),
+			"ok":             false,
+			"error":          err.Error(),
+			"error_category": connectionTestErrorCategory(err),
 		})
 		if err != nil {
 			app.serverError(w, r, err)
@@ -580,18 +928,34 @@ func (app *application) testConnection(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
-	err = d.Connect(ctx, app.driverConnectionConfig(input.Driver, input.DSN, settings))
+	dsn, lease, err := app.resolveCredentials(ctx, input.Driver, input.DSN, input.CredentialRef, input.Config)
 	if err != nil {
-		latency := time.Since(start).Milliseconds()
-		app.logWarn(r, "connection test failed", slog.String("driver", input.Driver), slog.Int64("latency_ms", latency), slog.String("stage", "connect"), slog.String("error_category", connectionTestErrorCategory(err)))
-		err = response.JSON(w, http.StatusOK, map[string]any{
-			"ok":         false,
-			"latency_ms": latency,
-			"error":      err.Error(),
-		})
+		app.connectionTestFailed(w, r, input.Driver, start, "credentials", err)
+		return
+	}
+	defer lease.Close()
+	cfg := app.driverConnectionConfig(input.Driver, dsn, settings)
+	if input.Config != nil {
+		cfg.TLS = input.Config.TLS()
+	}
+	orgID := contextGetWorkspace(r).OrgID
+	if input.SSHTunnel != nil {
+		input.SSHTunnel.Dial = app.orgEgressDialer(orgID, nil)
+		tunnel, err := connection.OpenTunnel(ctx, *input.SSHTunnel)
 		if err != nil {
-			app.serverError(w, r, err)
+			app.connectionTestFailed(w, r, input.Driver, start, "tunnel", err)
+			return
 		}
+		defer tunnel.Close()
+		cfg.Dial = tunnel.Dialer()
+	}
+	if input.AgentID != nil {
+		cfg.Dial = app.agentHub.Dialer(*input.AgentID)
+	}
+	cfg.Dial = app.orgEgressDialer(orgID, cfg.Dial)
+	err = d.Connect(ctx, cfg)
+	if err != nil {
+		app.connectionTestFailed(w, r, input.Driver, start, "connect", err)
 		return
 	}
 	defer d.Close()
@@ -599,15 +963,7 @@ func (app *application) testConnection(w http.ResponseWriter, r *http.Request) {
 	err = d.Ping(ctx)
 	latency := time.Since(start).Milliseconds()
 	if err != nil {
-		app.logWarn(r, "connection test failed", slog.String("driver", input.Driver), slog.Int64("latency_ms", latency), slog.String("stage", "ping"), slog.String("error_category", connectionTestErrorCategory(err)))
-		err = response.JSON(w, http.StatusOK, map[string]any{
-			"ok":         false,
-			"latency_ms": latency,
-			"error":      err.Error(),
-		})
-		if err != nil {
-			app.serverError(w, r, err)
-		}
+		app.connectionTestFailed(w, r, input.Driver, start, "ping", err)
 		return
 	}
 
@@ -636,16 +992,13 @@ func (app *application) connectToDatabase(w http.ResponseWriter, r *http.Request
 	conn := contextGetConnection(r)
 	ws := contextGetWorkspace(r)
 
-	allowed := app.hasAnyConnectionRuntimePermission(r, org.ID, ws.OwnerType, conn.ID,
-		access.PermConnExecute,
-		access.PermConnDQL,
-		access.PermConnDML,
-		access.PermConnDDL,
-	)
-	if !allowed {
+	if !app.canOpenConnection(r) {
 		app.notPermitted(w, r)
 		return
 	}
+	if app.refuseWhileDraining(w, r) {
+		return
+	}
 
 	plainDSN, err := app.keyring.Decrypt(conn.DSNEncrypted)
 	if err != nil {
@@ -657,6 +1010,17 @@ func (app *application) connectToDatabase(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	tunnel, err := app.connectionTunnelConfig(conn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	tlsMaterial, err := app.connectionTLS(conn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	connID := strconv.FormatInt(conn.ID, 10)
 	accountID := strconv.FormatInt(account.ID, 10)
 	settings, err := app.effectiveRuntimeSettingsForWorkspace(r.Context(), ws)
@@ -664,32 +1028,106 @@ func (app *application) connectToDatabase(w http.ResponseWriter, r *http.Request
 		app.serverError(w, r, err)
 		return
 	}
+	settings, err = app.connectionRuntimeSettings(r.Context(), conn, settings)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	replicas, err := app.db.ListConnectionReadReplicas(r.Context(), conn.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	// Replicas connect later with the credentials the primary resolves.
+	var sessionDSN string
+	replicaSpecs := app.readReplicaSpecs(conn, replicas, settings, tlsMaterial, &sessionDSN)
+	sessionInit, err := app.connectionSessionInit(r.Context(), conn)
+	if err != nil {
+		app.apiError(w, r, http.StatusUnprocessableEntity, apiErrorSessionInitFailed, err.Error(), response.APIError{}, nil)
+		return
+	}
 
-	session, created, err := app.connManager.GetOrCreateWithMetadata(accountID, connID, connection.SessionMetadata{
-		OrgID:       strconv.FormatInt(org.ID, 10),
-		WorkspaceID: strconv.FormatInt(ws.ID, 10),
-	}, func() (engine.Driver, error) {
-		d, err := engine.New(conn.Driver)
-		if err != nil {
-			return nil, err
-		}
-		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-		defer cancel()
-		if err := d.Connect(ctx, app.driverConnectionConfig(conn.Driver, plainDSN, settings, conn.DefaultScope)); err != nil {
-			return nil, err
-		}
-		return d, nil
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	session, created, err := app.connManager.GetOrCreate(ctx, connection.SessionKey{AccountID: accountID, ConnectionID: connID}, connection.SessionOptions{
+		Metadata: connection.SessionMetadata{
+			OrgID:       strconv.FormatInt(org.ID, 10),
+			WorkspaceID: strconv.FormatInt(ws.ID, 10),
+		},
+		Tunnel:   tunnel,
+		Replicas: replicaSpecs,
+		Init:     sessionInit,
+		Open: func(dial engine.DialFunc) (engine.Driver, io.Closer, error) {
+			d, err := engine.New(conn.Driver)
+			if err != nil {
+				return nil, nil, err
+			}
+			dsn, lease, err := app.accountConnectionCredentials(ctx, conn, account.ID, plainDSN)
+			if err != nil {
+				return nil, nil, err
+			}
+			sessionDSN = dsn
+			cfg := app.driverConnectionConfig(conn.Driver, dsn, settings, conn.DefaultScope)
+			cfg.Dial = app.connectionDialer(conn, dial)
+			cfg.TLS = tlsMaterial
+			cfg.StatementTimeout = settings.QueryMaxExecutionTime
+			if err := d.Connect(ctx, cfg); err != nil {
+				_ = lease.Close()
+				return nil, nil, err
+			}
+			if lease == nil {
+				return d, nil, nil
+			}
+			return d, lease, nil
+		},
 	})
+	if errors.Is(err, errUserCredentialMissing) {
+		app.apiError(w, r, http.StatusUnprocessableEntity, apiErrorConnectionCredentialsRequired, "Set your credentials for this connection first.", response.APIError{}, nil)
+		return
+	}
+	var initErr *connection.SessionInitError
+	if errors.As(err, &initErr) {
+		app.logWarn(r, "database session init failed", slog.Int64("connection_id", conn.ID), slog.Int("statement", initErr.Index+1))
+		app.apiError(w, r, http.StatusUnprocessableEntity, apiErrorSessionInitFailed, "Session initialization failed: "+initErr.Error(),
+			response.APIError{Details: map[string]any{"statement": initErr.Index + 1, "sql": initErr.Statement}}, nil)
+		return
+	}
 	if err != nil {
 		app.errorMessage(w, r, http.StatusUnprocessableEntity, err.Error(), nil)
 		return
 	}
 
 	app.logInfo(r, "database session opened", slog.Int64("connection_id", conn.ID), slog.String("session_id", session.ID), slog.Bool("reused", !created))
+	if created {
+		app.recordLiveSessionRoute(r, database.LiveRouteSession, session.ID, session.ID, conn)
+	}
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.session.open", "connection", conn.ID, map[string]any{"session_id": session.ID, "reused": !created}))
 	app.maybeEnqueueSchemaSync(context.WithoutCancel(r.Context()), conn, ws.OrgID)
-	err = response.JSON(w, http.StatusOK, map[string]any{
+	payload := map[string]any{
 		"session_id": session.ID,
 		"reused":     !created,
+	}
+	if session.HasReplicas() {
+		payload["endpoints"] = session.Endpoints()
+	}
+	err = response.JSON(w, http.StatusOK, payload)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// connectionTestFailed reports a failed connection test. The error category
+// tells a tunnel failure apart from the database rejecting the connection.
+func (app *application) connectionTestFailed(w http.ResponseWriter, r *http.Request, driverName string, start time.Time, stage string, testErr error) {
+	latency := time.Since(start).Milliseconds()
+	category := connectionTestErrorCategory(testErr)
+	app.logWarn(r, "connection test failed", slog.String("driver", driverName), slog.Int64("latency_ms", latency), slog.String("stage", stage), slog.String("error_category", category))
+	err := response.JSON(w, http.StatusOK, map[string]any{
+		"ok":             false,
+		"latency_ms":     latency,
+		"stage":          stage,
+		"error":          testErr.Error(),
+		"error_category": category,
 	})
 	if err != nil {
 		app.serverError(w, r, err)
@@ -697,6 +1135,22 @@ func (app *application) connectToDatabase(w http.ResponseWriter, r *http.Request
 }
 
 func connectionTestErrorCategory(err error) string {
+	if errors.Is(err, egress.ErrBlocked) {
+		return "egress_blocked"
+	}
+	var tunnelErr *connection.TunnelError
+	if errors.As(err, &tunnelErr) {
+		switch tunnelErr.Stage {
+		case connection.TunnelStageHostKey:
+			return "tunnel_host_key_mismatch"
+		case connection.TunnelStageAuth, connection.TunnelStageConfig:
+			return "tunnel_auth_failed"
+		case connection.TunnelStageForward:
+			return "tunnel_forward_failed"
+		default:
+			return "tunnel_unreachable"
+		}
+	}
 	switch {
 	case errors.Is(err, context.DeadlineExceeded):
 		return "timeout"
@@ -704,6 +1158,10 @@ func connectionTestErrorCategory(err error) string {
 		return "cancelled"
 	case errors.Is(err, errSQLiteTargetDisabled):
 		return "policy_denied"
+	case errors.Is(err, agent.ErrAgentOffline):
+		return "agent_offline"
+	case errors.Is(err, errConnectionCredentials):
+		return "credentials_unavailable"
 	case strings.Contains(err.Error(), "unknown driver"):
 		return "unsupported_driver"
 	default:
@@ -723,6 +1181,7 @@ func (app *application) listActiveSessions(w http.ResponseWriter, r *http.Reques
 		ConnectionID int64  `json:"connection_id"`
 		AccountID    int64  `json:"account_id"`
 		SessionID    string `json:"session_id"`
+		LastEndpoint string `json:"last_endpoint,omitempty"`
 	}
 	result := make([]sessionInfo, 0)
 
@@ -747,6 +1206,7 @@ func (app *application) listActiveSessions(w http.ResponseWriter, r *http.Reques
 			ConnectionID: connIDInt,
 			AccountID:    accountIDInt,
 			SessionID:    ref.SessionID,
+			LastEndpoint: ref.LastEndpoint,
 		})
 	}
 
@@ -827,6 +1287,7 @@ func (app *application) revokeWorkspaceDatabaseSession(w http.ResponseWriter, r
 
 	app.connManager.Remove(sessionID)
 	app.logInfo(r, "database session revoked", slog.Int64("workspace_id", ws.ID), slog.String("session_id", sessionID), slog.String("session_account_id", session.AccountID))
+	app.recordAudit(r, workspaceAuditEvent(r, "connection.session.revoke", "workspace", ws.ID, map[string]any{"session_id": sessionID, "session_account_id": session.AccountID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -845,11 +1306,12 @@ func (app *application) driverConnectionConfig(driverName, dsn string, settings
 
 func (app *application) executeQuery(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		SQL           string              `json:"sql"`
-		PageSize      *int                `json:"page_size"`
-		UseCursor     *bool               `json:"use_cursor"`
-		ConfirmUnsafe bool                `json:"confirm_unsafe"`
-		V             validator.Validator `json:"-"`
+		SQL                string              `json:"sql"`
+		PageSize           *int                `json:"page_size"`
+		UseCursor          *bool               `json:"use_cursor"`
+		ConfirmUnsafe      bool                `json:"confirm_unsafe"`
+		ConfirmEnvironment string              `json:"confirm_environment"`
+		V                  validator.Validator `json:"-"`
 	}
 
 	err := request.DecodeJSON(w, r, &input)
@@ -866,17 +1328,22 @@ func (app *application) executeQuery(w http.ResponseWriter, r *http.Request) {
 		app.failedValidation(w, r, input.V)
 		return
 	}
-	runtimeSettings, err := app.effectiveRuntimeSettingsForWorkspace(r.Context(), contextGetWorkspace(r))
-	if err != nil {
-		app.serverError(w, r, err)
-		return
-	}
-
 	account := contextGetAccount(r)
 	org := contextGetOrg(r)
 	conn := contextGetConnection(r)
 	ws := contextGetWorkspace(r)
 
+	runtimeSettings, err := app.effectiveRuntimeSettingsForWorkspace(r.Context(), ws)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	runtimeSettings, err = app.connectionRuntimeSettings(r.Context(), conn, runtimeSettings)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	sessionID := r.Header.Get("X-Warden-Session")
 	if sessionID == "" {
 		app.errorMessage(w, r, http.StatusBadRequest, "X-Warden-Session header is required.", nil)
@@ -913,6 +1380,8 @@ func (app *application) executeQuery(w http.ResponseWriter, r *http.Request) {
 
 	var rs *result.ResultSet
 	var execErr error
+	r, cancelQuery := withQueryDeadline(r, runtimeSettings.QueryMaxExecutionTime)
+	defer cancelQuery()
 	start := time.Now()
 
 	switch classification.Kind {
@@ -937,6 +1406,9 @@ func (app *application) executeQuery(w http.ResponseWriter, r *http.Request) {
 			app.notPermitted(w, r)
 			return
 		}
+		if !app.enforceEnvironmentProtection(w, r, conn, input.SQL, classification.Kind, input.ConfirmEnvironment) {
+			return
+		}
 		if !input.ConfirmUnsafe {
 			safetyResult, safetyErr := app.checkConnectionSQLSafety(r, conn, input.SQL)
 			if safetyErr != nil {
@@ -965,6 +1437,9 @@ func (app *application) executeQuery(w http.ResponseWriter, r *http.Request) {
 			app.notPermitted(w, r)
 			return
 		}
+		if !app.enforceEnvironmentProtection(w, r, conn, input.SQL, classification.Kind, input.ConfirmEnvironment) {
+			return
+		}
 		rs, execErr = session.ExecuteWithOptions(r.Context(), input.SQL, queryCursorScanOptions(runtimeSettings.QueryMaxResultRows, runtimeSettings))
 	default:
 		if !hasBroadExecute {
@@ -972,10 +1447,23 @@ func (app *application) executeQuery(w http.ResponseWriter, r *http.Request) {
 			app.notPermitted(w, r)
 			return
 		}
+		if !app.enforceEnvironmentProtection(w, r, conn, input.SQL, classification.Kind, input.ConfirmEnvironment) {
+			return
+		}
 		rs, execErr = session.ExecuteWithOptions(r.Context(), input.SQL, queryCursorScanOptions(runtimeSettings.QueryMaxResultRows, runtimeSettings))
 	}
 
 	if execErr != nil {
+		if isQueryTimeout(r, execErr) {
+			if !errors.Is(execErr, engine.ErrStatementTimeout) {
+				// Only the request deadline stopped it; the statement may
+				// still be running on the session's connection.
+				app.connManager.Remove(sessionID)
+			}
+			app.logger.Warn("query timed out", append(logAttrs, "duration_ms", time.Since(start).Milliseconds(), "limit_seconds", int64(runtimeSettings.QueryMaxExecutionTime.Seconds()))...)
+			app.queryTimedOut(w, r, runtimeSettings.QueryMaxExecutionTime)
+			return
+		}
 		if errors.Is(execErr, context.Canceled) || errors.Is(execErr, context.DeadlineExceeded) || r.Context().Err() != nil {
 			app.connManager.Remove(sessionID)
 			app.logger.Warn("query cancelled", append(logAttrs, "duration_ms", time.Since(start).Milliseconds())...)
@@ -1024,7 +1512,7 @@ func (app *application) executeDQLQuery(r *http.Request, session *connection.Ses
 			)
 		}
 	}
-	return session.QueryWithOptions(r.Context(), sql, queryCursorScanOptions(runtimeSettings.QueryMaxResultRows, runtimeSettings))
+	return session.ReadWithOptions(r.Context(), sql, queryCursorScanOptions(runtimeSettings.QueryMaxResultRows, runtimeSettings))
 }
 
 func (app *application) executeQueryWithCursor(r *http.Request, session *connection.Session, sql string, pageSize int, start time.Time, runtimeSettings effectiveRuntimeSettings) (*result.ResultSet, error) {
@@ -1033,7 +1521,7 @@ func (app *application) executeQueryWithCursor(r *http.Request, session *connect
 		slog.Int("page_size", pageSize),
 	)
 
-	cursorHandle, err := session.StartQueryCursor(queryCursorLifetimeContext(r.Context()), sql)
+	cursorHandle, err := session.StartReadCursor(queryCursorLifetimeContext(r.Context()), sql)
 	if err != nil {
 		return nil, err
 	}
@@ -1057,6 +1545,7 @@ func (app *application) executeQueryWithCursor(r *http.Request, session *connect
 		exhausted := false
 		rs.QueryCursorID = qc.ID
 		rs.Exhausted = &exhausted
+		app.recordLiveSessionRoute(r, database.LiveRouteQueryCursor, qc.ID, session.ID, contextGetConnection(r))
 	}
 	app.logInfo(r, "query cursor initial page returned",
 		queryCursorRecordAttrs(qc,
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -520,25 +521,39 @@ func TestGetConnectionDSNMaskedByOrgSetting(t *testing.T) {
 	ws := seedWorkspaceForAccount(t, app, org, owner, "Conn DSN Masked WS", "")
 	envID := defaultEnvironmentID(t, app, ws.ID)
 
-	dsn := "host=localhost port=5432 user=test dbname=test sslmode=disable"
-	createRes := send(t, newAuthRequest(t, http.MethodPost,
-		orgEnvConnectionsURL(org.Slug, ws.ID, envID),
-		map[string]any{
-			"name":   "Primary",
-			"driver": "postgres",
-			"dsn":    dsn,
-		}, ownerTok), app.routes())
-	assert.Equal(t, createRes.StatusCode, http.StatusCreated)
-	connID := fmt.Sprintf("%v", createRes.BodyFields["id"])
+	createConn := func(name, dsn string) string {
+		t.Helper()
+		createRes := send(t, newAuthRequest(t, http.MethodPost,
+			orgEnvConnectionsURL(org.Slug, ws.ID, envID),
+			map[string]any{
+				"name":   name,
+				"driver": "postgres",
+				"dsn":    dsn,
+			}, ownerTok), app.routes())
+		assert.Equal(t, createRes.StatusCode, http.StatusCreated)
+		return fmt.Sprintf("%v", createRes.BodyFields["id"])
+	}
+	structuredID := createConn("Primary", "host=localhost port=5432 user=test password=hunter2 dbname=test sslmode=disable")
+	rawID := createConn("Socket", "host=/var/run/postgresql user=test password=hunter2 dbname=test")
 
 	masked := true
-	if err := app.db.UpdateOrgSettings(context.Background(), org.ID, nil, nil, &masked); err != nil {
+	if err := app.db.UpdateOrgSettings(context.Background(), org.ID, nil, nil, &masked, nil); err != nil {
 		t.Fatal(err)
 	}
 
-	// Even the owner, who holds conn:update, must be forbidden once the org masks credentials on edit.
+	// Once the org masks credentials on edit, even the owner, who holds
+	// conn:update, only sees structured settings with secrets masked.
 	ownerRes := send(t, newAuthRequest(t, http.MethodGet,
-		orgConnectionURL(org.Slug, ws.ID, envID, connID)+"/dsn", nil, ownerTok), app.routes())
+		orgConnectionURL(org.Slug, ws.ID, envID, structuredID)+"/dsn", nil, ownerTok), app.routes())
+	assert.Equal(t, ownerRes.StatusCode, http.StatusOK)
+	assert.False(t, strings.Contains(string(ownerRes.BodyBytes), "hunter2"))
+	config := ownerRes.BodyFields["config"].(map[string]any)
+	assert.Equal(t, config["password"], any("********"))
+	assert.Equal(t, config["host"], any("localhost"))
+
+	// A raw DSN cannot be masked field by field, so it stays forbidden.
+	ownerRes = send(t, newAuthRequest(t, http.MethodGet,
+		orgConnectionURL(org.Slug, ws.ID, envID, rawID)+"/dsn", nil, ownerTok), app.routes())
 	assert.Equal(t, ownerRes.StatusCode, http.StatusForbidden)
 }
 
@@ -1918,15 +1933,16 @@ func TestRevokeWorkspaceDatabaseSession_OwnerCanRevokeOwnSession(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	session, _, err := app.connManager.GetOrCreateWithMetadata(
-		claims.AccountID,
-		strconv.FormatInt(conn.ID, 10),
-		connection.SessionMetadata{
+	session, _, err := app.connManager.GetOrCreate(context.Background(), connection.SessionKey{
+		AccountID:    claims.AccountID,
+		ConnectionID: strconv.FormatInt(conn.ID, 10),
+	}, connection.SessionOptions{
+		Metadata: connection.SessionMetadata{
 			OrgID:       strconv.FormatInt(org.ID, 10),
 			WorkspaceID: strconv.FormatInt(ws.ID, 10),
 		},
-		func() (engine.Driver, error) { return newIdleQueryDriver(), nil },
-	)
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) { return newIdleQueryDriver(), nil, nil },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1950,15 +1966,16 @@ func TestRevokeWorkspaceDatabaseSession_AdminCanRevokeWorkspaceSession(t *testin
 		t.Fatal(err)
 	}
 
-	session, _, err := app.connManager.GetOrCreateWithMetadata(
-		strconv.FormatInt(member.ID, 10),
-		strconv.FormatInt(conn.ID, 10),
-		connection.SessionMetadata{
+	session, _, err := app.connManager.GetOrCreate(context.Background(), connection.SessionKey{
+		AccountID:    strconv.FormatInt(member.ID, 10),
+		ConnectionID: strconv.FormatInt(conn.ID, 10),
+	}, connection.SessionOptions{
+		Metadata: connection.SessionMetadata{
 			OrgID:       strconv.FormatInt(org.ID, 10),
 			WorkspaceID: strconv.FormatInt(ws.ID, 10),
 		},
-		func() (engine.Driver, error) { return newIdleQueryDriver(), nil },
-	)
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) { return newIdleQueryDriver(), nil, nil },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1980,15 +1997,16 @@ func TestRevokeWorkspaceDatabaseSession_CrossWorkspaceHidden(t *testing.T) {
 	envID := defaultEnvironmentID(t, app, wsB.ID)
 	conn := seedConnection(t, app, wsB.ID, &envID, org.ID, "sqlite", "Cross WS Session", "open")
 
-	session, _, err := app.connManager.GetOrCreateWithMetadata(
-		strconv.FormatInt(owner.ID, 10),
-		strconv.FormatInt(conn.ID, 10),
-		connection.SessionMetadata{
+	session, _, err := app.connManager.GetOrCreate(context.Background(), connection.SessionKey{
+		AccountID:    strconv.FormatInt(owner.ID, 10),
+		ConnectionID: strconv.FormatInt(conn.ID, 10),
+	}, connection.SessionOptions{
+		Metadata: connection.SessionMetadata{
 			OrgID:       strconv.FormatInt(org.ID, 10),
 			WorkspaceID: strconv.FormatInt(wsB.ID, 10),
 		},
-		func() (engine.Driver, error) { return newIdleQueryDriver(), nil },
-	)
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) { return newIdleQueryDriver(), nil, nil },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2071,20 +2089,22 @@ func TestExecuteQueryCancellationRemovesOnlyCancelledSession(t *testing.T) {
 	connB := seedConnection(t, app, ws.ID, &envID, org.ID, "sqlite", "Unrelated Conn", "open")
 
 	blockingDriver := newBlockingQueryDriver()
-	cancelledSession, _, err := app.connManager.GetOrCreate(
-		strconv.FormatInt(owner.ID, 10),
-		strconv.FormatInt(connA.ID, 10),
-		func() (engine.Driver, error) { return blockingDriver, nil },
-	)
+	cancelledSession, _, err := app.connManager.GetOrCreate(context.Background(), connection.SessionKey{
+		AccountID:    strconv.FormatInt(owner.ID, 10),
+		ConnectionID: strconv.FormatInt(connA.ID, 10),
+	}, connection.SessionOptions{
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) { return blockingDriver, nil, nil },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	unrelatedSession, _, err := app.connManager.GetOrCreate(
-		strconv.FormatInt(owner.ID, 10),
-		strconv.FormatInt(connB.ID, 10),
-		func() (engine.Driver, error) { return newIdleQueryDriver(), nil },
-	)
+	unrelatedSession, _, err := app.connManager.GetOrCreate(context.Background(), connection.SessionKey{
+		AccountID:    strconv.FormatInt(owner.ID, 10),
+		ConnectionID: strconv.FormatInt(connB.ID, 10),
+	}, connection.SessionOptions{
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) { return newIdleQueryDriver(), nil, nil },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
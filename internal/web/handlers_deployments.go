@@ -0,0 +1,469 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine/classifier"
+	"github.com/sqlwarden/internal/files"
+	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const (
+	deploymentMaxTargets     = 20
+	deploymentMaxSQLBytes    = 1 << 20
+	deploymentMaxErrorLength = 2000
+)
+
+type deploymentRequest struct {
+	FileID              int64               `json:"file_id"`
+	ContentVersion      *int                `json:"content_version"`
+	ConnectionIDs       []int64             `json:"connection_ids"`
+	ConfirmEnvironments []string            `json:"confirm_environments"`
+	V                   validator.Validator `json:"-"`
+}
+
+type deploymentJobInput struct {
+	DeploymentID int64 `json:"deployment_id"`
+	TargetID     int64 `json:"target_id"`
+}
+
+type deploymentJobOutput struct {
+	DeploymentID int64 `json:"deployment_id"`
+	TargetID     int64 `json:"target_id"`
+	DurationMS   int64 `json:"duration_ms"`
+}
+
+// createDeployment pins a revision of a shared SQL file and queues it to run
+// on each requested connection in order. The caller needs env:deploy on every
+// target's environment, and production targets apply the same guardrails as
+// interactive queries; confirm_environments names each production
+// environment.
+func (app *application) createDeployment(w http.ResponseWriter, r *http.Request) {
+	var input deploymentRequest
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	input.V.CheckField(input.FileID > 0, "file_id", "File ID is required.")
+	if input.ContentVersion != nil {
+		input.V.CheckField(*input.ContentVersion > 0, "content_version", "Content version must be greater than 0.")
+	}
+	input.V.CheckField(len(input.ConnectionIDs) > 0, "connection_ids", "At least one connection is required.")
+	input.V.CheckField(len(input.ConnectionIDs) <= deploymentMaxTargets, "connection_ids", "A deployment can target at most "+strconv.Itoa(deploymentMaxTargets)+" connections.")
+	seen := map[int64]bool{}
+	for _, id := range input.ConnectionIDs {
+		input.V.CheckField(!seen[id], "connection_ids", "Each connection can only be targeted once.")
+		seen[id] = true
+	}
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	account := contextGetAccount(r)
+	org := contextGetOrg(r)
+	ws := contextGetWorkspace(r)
+
+	targets := make([]database.DeploymentTarget, 0, len(input.ConnectionIDs))
+	conns := make([]database.Connection, 0, len(input.ConnectionIDs))
+	for _, id := range input.ConnectionIDs {
+		conn, found, err := app.db.GetConnection(r.Context(), id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !found || conn.WorkspaceID != ws.ID {
+			app.failedValidation(w, r, fieldErrors(map[string]string{"connection_ids": "Connection " + strconv.FormatInt(id, 10) + " was not found in this workspace."}))
+			return
+		}
+		if !app.enforcer.Can(r.Context(), account.ID, org.ID, ws.OwnerType, "environment", conn.EnvironmentID, access.PermEnvDeploy) {
+			app.logWarn(r, "deployment permission denied", slog.Int64("connection_id", conn.ID), slog.Int64("environment_id", conn.EnvironmentID))
+			app.notPermitted(w, r)
+			return
+		}
+		conns = append(conns, conn)
+		connectionID := conn.ID
+		targets = append(targets, database.DeploymentTarget{
+			EnvironmentID:  conn.EnvironmentID,
+			ConnectionID:   &connectionID,
+			ConnectionName: conn.Name,
+		})
+	}
+
+	revision, sql, ok := app.readDeploymentRevision(w, r, input.FileID, input.ContentVersion)
+	if !ok {
+		return
+	}
+	for _, conn := range conns {
+		classification, err := app.classifyConnectionSQL(r, conn, sql)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !app.enforceEnvironmentProtection(w, r, conn, sql, classification.Kind, input.ConfirmEnvironments...) {
+			return
+		}
+	}
+
+	fileID := revision.File.ID
+	deployment := database.Deployment{
+		OrgID:          org.ID,
+		WorkspaceID:    ws.ID,
+		FileID:         &fileID,
+		FileName:       revision.File.Name,
+		ContentID:      revision.Content.ID,
+		ContentVersion: revision.Content.Version,
+		ContentHash:    revision.Content.ContentHash,
+		SQL:            sql,
+		CreatedBy:      account.ID,
+	}
+	if err := app.db.InsertDeployment(r.Context(), &deployment, targets); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	job, err := app.enqueueDeploymentTarget(r.Context(), deployment, deployment.Targets[0])
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	deployment.Targets[0].JobID = job.ID
+
+	app.logInfo(r, "deployment queued", slog.Int64("deployment_id", deployment.ID), slog.Int64("file_id", fileID), slog.Int("content_version", deployment.ContentVersion), slog.Int("target_count", len(targets)), slog.String("job.id", job.ID))
+	app.recordAudit(r, workspaceAuditEvent(r, "deployment.create", "deployment", deployment.ID, map[string]any{
+		"file_id":         fileID,
+		"content_version": deployment.ContentVersion,
+		"content_hash":    deployment.ContentHash,
+		"connection_ids":  input.ConnectionIDs,
+	}))
+	if err := response.JSON(w, http.StatusCreated, deployment); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// readDeploymentRevision reads the requested revision of a shared SQL file,
+// or its current revision when no version is given.
+func (app *application) readDeploymentRevision(w http.ResponseWriter, r *http.Request, fileID int64, version *int) (files.ReadContentResult, string, bool) {
+	service, err := app.workspaceFileServiceForRequest(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return files.ReadContentResult{}, "", false
+	}
+	scope := app.workspaceFileScope(r, database.FileVisibilityShared)
+	var result files.ReadContentResult
+	if version != nil {
+		result, err = service.ReadContentVersion(r.Context(), scope, fileID, *version)
+	} else {
+		result, err = service.ReadContent(r.Context(), scope, fileID)
+	}
+	switch {
+	case errors.Is(err, files.ErrNotFound), errors.Is(err, files.ErrFolderContent):
+		if version != nil {
+			app.failedValidation(w, r, fieldErrors(map[string]string{"content_version": "File revision was not found."}))
+		} else {
+			app.failedValidation(w, r, fieldErrors(map[string]string{"file_id": "Shared file was not found or has no content."}))
+		}
+		return files.ReadContentResult{}, "", false
+	case err != nil:
+		app.workspaceFileError(w, r, err)
+		return files.ReadContentResult{}, "", false
+	}
+	defer result.Reader.Close()
+
+	if !strings.EqualFold(path.Ext(result.File.Name), ".sql") {
+		app.failedValidation(w, r, fieldErrors(map[string]string{"file_id": "Only .sql files can be deployed."}))
+		return files.ReadContentResult{}, "", false
+	}
+	body, err := io.ReadAll(io.LimitReader(result.Reader, deploymentMaxSQLBytes+1))
+	if err != nil {
+		app.serverError(w, r, err)
+		return files.ReadContentResult{}, "", false
+	}
+	if len(body) > deploymentMaxSQLBytes {
+		app.failedValidation(w, r, fieldErrors(map[string]string{"file_id": "File is larger than the 1 MiB deployment limit."}))
+		return files.ReadContentResult{}, "", false
+	}
+	sql := string(body)
+	if strings.TrimSpace(sql) == "" {
+		app.failedValidation(w, r, fieldErrors(map[string]string{"file_id": "File is empty."}))
+		return files.ReadContentResult{}, "", false
+	}
+	return result, sql, true
+}
+
+// getDeployment returns a deployment and its targets. Callers must be able to
+// read every environment it targets.
+func (app *application) getDeployment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "deployment_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+	account := contextGetAccount(r)
+	org := contextGetOrg(r)
+	ws := contextGetWorkspace(r)
+	deployment, found, err := app.db.GetDeployment(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || deployment.WorkspaceID != ws.ID {
+		app.notFound(w, r)
+		return
+	}
+	for _, target := range deployment.Targets {
+		if !app.enforcer.Can(r.Context(), account.ID, org.ID, ws.OwnerType, "environment", target.EnvironmentID, access.PermEnvRead) {
+			app.notFound(w, r)
+			return
+		}
+	}
+	if err := response.JSON(w, http.StatusOK, deployment); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// listEnvironmentDeployments returns the environment's deployment history,
+// newest first.
+func (app *application) listEnvironmentDeployments(w http.ResponseWriter, r *http.Request) {
+	env := contextGetEnvironment(r)
+	q, errs := readListQuery(r.URL.Query(), nil)
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+	result, err := app.db.ListEnvironmentDeployments(r.Context(), env.ID, q.Page, q.PageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := response.JSON(w, http.StatusOK, result); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) enqueueDeploymentTarget(ctx context.Context, deployment database.Deployment, target database.DeploymentTarget) (jobs.Record, error) {
+	job, err := app.workspaceJobStore().Enqueue(ctx, jobs.EnqueueInput{
+		Type:           jobs.TypeDeploySQL,
+		Visibility:     jobs.VisibilityUser,
+		OrgID:          &deployment.OrgID,
+		WorkspaceID:    &deployment.WorkspaceID,
+		OwnerAccountID: &deployment.CreatedBy,
+		Priority:       jobs.PriorityNormal,
+		MaxAttempts:    1,
+		Input:          deploymentJobInput{DeploymentID: deployment.ID, TargetID: target.ID},
+	})
+	if err != nil {
+		return jobs.Record{}, err
+	}
+	return job, app.db.SetDeploymentTargetJob(ctx, target.ID, job.ID)
+}
+
+// handleDeploymentJob runs a deployment on one target. On success it queues
+// the next target, so targets run in order; any failure ends the deployment
+// and skips the targets that have not run.
+func (app *application) handleDeploymentJob(ctx context.Context, runtime jobs.Runtime) (any, error) {
+	var input deploymentJobInput
+	if err := json.Unmarshal([]byte(runtime.Job.InputJSON), &input); err != nil {
+		return nil, jobs.Permanent("invalid_deployment_input", "Deployment job input is invalid.")
+	}
+	deployment, found, err := app.db.GetDeployment(ctx, input.DeploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, jobs.Permanent("deployment_not_found", "Deployment was not found.")
+	}
+	target, found, err := app.db.GetDeploymentTarget(ctx, input.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if !found || target.DeploymentID != deployment.ID {
+		return nil, jobs.Permanent("deployment_target_not_found", "Deployment target was not found.")
+	}
+	if target.Status != database.DeploymentTargetStatusPending {
+		return nil, jobs.Permanent("deployment_target_finished", "Deployment target has already run.")
+	}
+	details := map[string]any{"deployment_id": deployment.ID, "target_id": target.ID, "connection_name": target.ConnectionName}
+	runtime.Events.Info(ctx, "deployment_target_started", "Deployment started on connection.", details)
+
+	conn, ok, err := app.deploymentTargetConnection(ctx, deployment, target)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return app.failDeploymentTarget(ctx, deployment, target, "deployment_target_unavailable", "The connection was removed or moved to another environment.")
+	}
+	if !app.enforcer.Can(ctx, deployment.CreatedBy, deployment.OrgID, "org", "environment", target.EnvironmentID, access.PermEnvDeploy) {
+		return app.failDeploymentTarget(ctx, deployment, target, "deployment_not_permitted", "You no longer have permission to deploy to this environment.")
+	}
+	classification, err := connectionClassifier(conn.Driver).Classify(ctx, classifier.Request{SQL: deployment.SQL})
+	if err != nil {
+		return nil, err
+	}
+	refusal, err := app.recheckEnvironmentProtection(ctx, conn, deployment.SQL, classification.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if refusal != nil {
+		return app.failDeploymentTarget(ctx, deployment, target, refusal.code, refusal.message)
+	}
+
+	startedAt := time.Now()
+	started, err := app.db.StartDeploymentTarget(ctx, target, startedAt)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, jobs.Permanent("deployment_target_finished", "Deployment target has already run.")
+	}
+	target.StartedAt = &startedAt
+
+	driver, resources, err := app.openTargetConnection(ctx, conn)
+	if err != nil {
+		if ctx.Err() != nil {
+			app.cancelDeploymentTarget(context.WithoutCancel(ctx), deployment, target)
+			return nil, ctx.Err()
+		}
+		var coded jobs.CodedError
+		if errors.As(err, &coded) {
+			return app.failDeploymentTarget(ctx, deployment, target, coded.Code, coded.Message)
+		}
+		return app.failDeploymentTarget(ctx, deployment, target, "deployment_failed", "Deployment failed.")
+	}
+	defer resources.Close()
+	defer driver.Close()
+	runtime.Events.Info(ctx, "target_connected", "Connected to database.", details)
+
+	if _, err := driver.Execute(ctx, deployment.SQL); err != nil {
+		if ctx.Err() != nil {
+			app.cancelDeploymentTarget(context.WithoutCancel(ctx), deployment, target)
+			return nil, ctx.Err()
+		}
+		app.logger.WarnContext(ctx, "deployment target failed", "deployment_id", deployment.ID, "target_id", target.ID, "connection_id", conn.ID)
+		return app.failDeploymentTarget(ctx, deployment, target, "deployment_sql_failed", deploymentErrorMessage(err))
+	}
+
+	finishedAt := time.Now()
+	if err := app.db.FinishDeploymentTarget(ctx, target, database.DeploymentTargetStatusSucceeded, "", "", finishedAt); err != nil {
+		return nil, err
+	}
+	durationMS := finishedAt.Sub(startedAt).Milliseconds()
+	runtime.Events.Info(ctx, "deployment_target_succeeded", "Deployment succeeded on connection.", map[string]any{"deployment_id": deployment.ID, "target_id": target.ID, "duration_ms": durationMS})
+	app.logger.InfoContext(ctx, "deployment target succeeded", "deployment_id", deployment.ID, "target_id", target.ID, "connection_id", conn.ID, "duration_ms", durationMS)
+	if _, _, err := app.enqueueSchemaSync(context.WithoutCancel(ctx), conn.ID, &deployment.OrgID); err != nil && !errors.Is(err, jobs.ErrActiveExists) {
+		app.logger.WarnContext(ctx, "post-deployment schema snapshot enqueue failed", "connection_id", conn.ID, "error", err)
+	}
+
+	next, found, err := app.db.NextDeploymentTarget(ctx, deployment.ID, target.Position)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if _, err := app.enqueueDeploymentTarget(ctx, deployment, next); err != nil {
+			return nil, err
+		}
+	} else {
+		app.finishDeployment(ctx, deployment, database.DeploymentStatusSucceeded)
+	}
+	return deploymentJobOutput{DeploymentID: deployment.ID, TargetID: target.ID, DurationMS: durationMS}, nil
+}
+
+// deploymentTargetConnection loads the target's connection and reports false
+// when it no longer exists in the environment it was targeted in.
+func (app *application) deploymentTargetConnection(ctx context.Context, deployment database.Deployment, target database.DeploymentTarget) (database.Connection, bool, error) {
+	if target.ConnectionID == nil {
+		return database.Connection{}, false, nil
+	}
+	conn, found, err := app.db.GetConnection(ctx, *target.ConnectionID)
+	if err != nil || !found {
+		return database.Connection{}, false, err
+	}
+	if conn.WorkspaceID != deployment.WorkspaceID || conn.EnvironmentID != target.EnvironmentID {
+		return database.Connection{}, false, nil
+	}
+	return conn, true, nil
+}
+
+// failDeploymentTarget records a failed target, ends the deployment, and
+// returns the job error.
+func (app *application) failDeploymentTarget(ctx context.Context, deployment database.Deployment, target database.DeploymentTarget, code, message string) (any, error) {
+	ctx = context.WithoutCancel(ctx)
+	if err := app.db.FinishDeploymentTarget(ctx, target, database.DeploymentTargetStatusFailed, code, message, time.Now()); err != nil {
+		return nil, err
+	}
+	app.finishDeployment(ctx, deployment, database.DeploymentStatusFailed)
+	return nil, jobs.Permanent(code, "Deployment failed on "+target.ConnectionName+".")
+}
+
+func (app *application) cancelDeploymentTarget(ctx context.Context, deployment database.Deployment, target database.DeploymentTarget) {
+	if err := app.db.FinishDeploymentTarget(ctx, target, database.DeploymentTargetStatusCancelled, "", "", time.Now()); err != nil {
+		app.logger.ErrorContext(ctx, "deployment target cancel update failed", "deployment_id", deployment.ID, "target_id", target.ID, "error", err)
+	}
+	app.finishDeployment(ctx, deployment, database.DeploymentStatusCancelled)
+}
+
+// finishDeployment records a deployment's outcome and audits it on behalf of
+// the account that created it.
+func (app *application) finishDeployment(ctx context.Context, deployment database.Deployment, status string) {
+	if err := app.db.FinishDeployment(ctx, deployment.ID, status, time.Now()); err != nil {
+		app.logger.ErrorContext(ctx, "deployment finish update failed", "deployment_id", deployment.ID, "error", err)
+		return
+	}
+	app.logger.InfoContext(ctx, "deployment finished", "deployment_id", deployment.ID, "status", status)
+	_, err := app.auditStore().Append(ctx, audit.Event{
+		OrgID:          &deployment.OrgID,
+		ActorAccountID: &deployment.CreatedBy,
+		Action:         "deployment.finish",
+		ResourceType:   "deployment",
+		ResourceID:     strconv.FormatInt(deployment.ID, 10),
+		Details:        map[string]any{"status": status, "file_id": deployment.FileID, "content_version": deployment.ContentVersion},
+	})
+	if err != nil {
+		app.logger.ErrorContext(ctx, "audit record append failed", "audit.action", "deployment.finish", "error", err)
+	}
+}
+
+// cancelQueuedDeploymentJob ends a deployment whose next target's job was
+// cancelled before a worker picked it up. Running targets record their own
+// cancellation.
+func (app *application) cancelQueuedDeploymentJob(ctx context.Context, job jobs.Record) {
+	var input deploymentJobInput
+	if err := json.Unmarshal([]byte(job.InputJSON), &input); err != nil {
+		return
+	}
+	target, found, err := app.db.GetDeploymentTarget(ctx, input.TargetID)
+	if err != nil || !found || target.Status != database.DeploymentTargetStatusPending {
+		return
+	}
+	deployment, found, err := app.db.GetDeployment(ctx, target.DeploymentID)
+	if err != nil || !found {
+		return
+	}
+	app.cancelDeploymentTarget(ctx, deployment, target)
+}
+
+// deploymentErrorMessage keeps the target database's error for the
+// deployment history, bounded in length.
+func deploymentErrorMessage(err error) string {
+	message := strings.TrimSpace(err.Error())
+	if len(message) > deploymentMaxErrorLength {
+		message = strings.ToValidUTF8(message[:deploymentMaxErrorLength], "")
+	}
+	return message
+}
@@ -0,0 +1,327 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/jobs"
+)
+
+func deploymentsURL(orgSlug string, workspaceID int64) string {
+	return fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/deployments", orgSlug, workspaceID)
+}
+
+// seedDeploymentFile creates a shared workspace file and saves each content
+// in turn as a new revision.
+func seedDeploymentFile(t *testing.T, app *application, orgSlug string, workspaceID int64, token, name string, contents ...string) database.WorkspaceFile {
+	t.Helper()
+
+	create := send(t, newAuthRequest(t, http.MethodPost, orgSharedFilesURL(orgSlug, workspaceID), map[string]any{"name": name}, token), app.routes())
+	assert.Equal(t, create.StatusCode, http.StatusCreated)
+	file := decodeWorkspaceFile(t, create)
+	contentURL := orgSharedFilesURL(orgSlug, workspaceID) + "/" + strconv.FormatInt(file.ID, 10) + "/content"
+	etag := ""
+	for _, content := range contents {
+		res := send(t, newAuthContentRequest(t, http.MethodPut, contentURL, content, token, etag), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		etag = res.Header.Get("ETag")
+	}
+	return file
+}
+
+// seedSQLiteFileConnection stores a connection to a fresh SQLite file.
+func seedSQLiteFileConnection(t *testing.T, app *application, workspaceID, environmentID int64, name string) database.Connection {
+	t.Helper()
+
+	encryptedDSN, err := app.keyring.Encrypt(filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := app.db.InsertConnection(context.Background(), workspaceID, &environmentID, name, "sqlite", encryptedDSN, "open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.enforcer.InvalidateAncestry("connection", conn.ID)
+	return conn
+}
+
+// runQueuedDeploymentJob runs the single queued deploy_sql job in place of a
+// worker and returns its outcome.
+func runQueuedDeploymentJob(t *testing.T, app *application) (any, error) {
+	t.Helper()
+
+	var records []jobs.Record
+	err := app.db.NewSelect().Model(&records).
+		Where("type = ? AND status = ?", jobs.TypeDeploySQL, jobs.StatusQueued).
+		Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("queued deployment jobs = %d, want 1", len(records))
+	}
+	record := records[0]
+	if _, err := app.db.NewUpdate().Model((*jobs.Record)(nil)).
+		Set("status = ?", jobs.StatusSucceeded).
+		Where("id = ?", record.ID).
+		Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return app.handleDeploymentJob(context.Background(), jobs.Runtime{Job: record, Events: recordingEventWriter{}})
+}
+
+func getDeploymentForTest(t *testing.T, app *application, id int64) database.Deployment {
+	t.Helper()
+
+	deployment, found, err := app.db.GetDeployment(context.Background(), id)
+	if err != nil || !found {
+		t.Fatalf("get deployment: found=%v err=%v", found, err)
+	}
+	return deployment
+}
+
+func decodeDeployment(t *testing.T, res testResponse) database.Deployment {
+	t.Helper()
+
+	var deployment database.Deployment
+	if err := json.Unmarshal(res.BodyBytes, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
+
+func TestDeploymentRunsPinnedRevisionAcrossEnvironmentsInOrder(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+
+	staging := seedEnvironment(t, app, ws.ID, org.ID, "staging")
+	production := seedEnvironment(t, app, ws.ID, org.ID, "production")
+	stagingConn := seedSQLiteFileConnection(t, app, ws.ID, staging.ID, "staging-db")
+	productionConn := seedSQLiteFileConnection(t, app, ws.ID, production.ID, "production-db")
+	file := seedDeploymentFile(t, app, org.Slug, ws.ID, tok, "migrate.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY);")
+
+	res := send(t, newAuthRequest(t, http.MethodPost, deploymentsURL(org.Slug, ws.ID), map[string]any{
+		"file_id":        file.ID,
+		"connection_ids": []int64{stagingConn.ID, productionConn.ID},
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	created := decodeDeployment(t, res)
+	assert.Equal(t, created.Status, database.DeploymentStatusQueued)
+	assert.Equal(t, created.FileName, "migrate.sql")
+	assert.Equal(t, len(created.Targets), 2)
+	assert.Equal(t, created.Targets[0].EnvironmentID, staging.ID)
+	assert.Equal(t, created.Targets[1].EnvironmentID, production.ID)
+	if created.Targets[0].JobID == "" || created.Targets[1].JobID != "" {
+		t.Fatalf("only the first target should be queued, got jobs %q and %q", created.Targets[0].JobID, created.Targets[1].JobID)
+	}
+
+	// Editing the file after queueing must not change what runs.
+	contentURL := orgSharedFilesURL(org.Slug, ws.ID) + "/" + strconv.FormatInt(file.ID, 10) + "/content"
+	current := send(t, newAuthRequest(t, http.MethodGet, contentURL, nil, tok), app.routes())
+	assert.Equal(t, current.StatusCode, http.StatusOK)
+	edit := send(t, newAuthContentRequest(t, http.MethodPut, contentURL, "DROP TABLE nothing_here;", tok, current.Header.Get("ETag")), app.routes())
+	assert.Equal(t, edit.StatusCode, http.StatusOK)
+
+	if _, err := runQueuedDeploymentJob(t, app); err != nil {
+		t.Fatalf("first target: %v", err)
+	}
+	mid := getDeploymentForTest(t, app, created.ID)
+	assert.Equal(t, mid.Status, database.DeploymentStatusRunning)
+	assert.Equal(t, mid.Targets[0].Status, database.DeploymentTargetStatusSucceeded)
+	assert.Equal(t, mid.Targets[1].Status, database.DeploymentTargetStatusPending)
+
+	if _, err := runQueuedDeploymentJob(t, app); err != nil {
+		t.Fatalf("second target: %v", err)
+	}
+	done := getDeploymentForTest(t, app, created.ID)
+	assert.Equal(t, done.Status, database.DeploymentStatusSucceeded)
+	if done.FinishedAt == nil {
+		t.Fatal("finished deployment should record finished_at")
+	}
+	for _, target := range done.Targets {
+		assert.Equal(t, target.Status, database.DeploymentTargetStatusSucceeded)
+		if target.DurationMS == nil {
+			t.Fatalf("target %d should record its duration", target.ID)
+		}
+	}
+
+	getRes := send(t, newAuthRequest(t, http.MethodGet, deploymentsURL(org.Slug, ws.ID)+"/"+strconv.FormatInt(created.ID, 10), nil, tok), app.routes())
+	assert.Equal(t, getRes.StatusCode, http.StatusOK)
+	assert.Equal(t, getRes.BodyFields["status"], any(database.DeploymentStatusSucceeded))
+
+	historyRes := send(t, newAuthRequest(t, http.MethodGet,
+		fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/environments/%d/deployments", org.Slug, ws.ID, production.ID), nil, tok), app.routes())
+	assert.Equal(t, historyRes.StatusCode, http.StatusOK)
+	var history struct {
+		Items []database.Deployment `json:"items"`
+		Total int                   `json:"total"`
+	}
+	if err := json.Unmarshal(historyRes.BodyBytes, &history); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, history.Total, 1)
+	assert.Equal(t, history.Items[0].ContentVersion, created.ContentVersion)
+	assert.Equal(t, history.Items[0].ContentHash, created.ContentHash)
+	assert.Equal(t, history.Items[0].CreatedBy, created.CreatedBy)
+	assert.Equal(t, len(history.Items[0].Targets), 1)
+	assert.Equal(t, history.Items[0].Targets[0].ConnectionName, "production-db")
+}
+
+func TestDeploymentStopsAtFirstFailedTarget(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+
+	staging := seedEnvironment(t, app, ws.ID, org.ID, "staging")
+	production := seedEnvironment(t, app, ws.ID, org.ID, "production")
+	stagingConn := seedSQLiteFileConnection(t, app, ws.ID, staging.ID, "staging-db")
+	productionConn := seedSQLiteFileConnection(t, app, ws.ID, production.ID, "production-db")
+	file := seedDeploymentFile(t, app, org.Slug, ws.ID, tok, "broken.sql", "INSERT INTO missing_table VALUES (1);")
+
+	res := send(t, newAuthRequest(t, http.MethodPost, deploymentsURL(org.Slug, ws.ID), map[string]any{
+		"file_id":        file.ID,
+		"connection_ids": []int64{stagingConn.ID, productionConn.ID},
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	created := decodeDeployment(t, res)
+
+	_, err := runQueuedDeploymentJob(t, app)
+	var coded jobs.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("error = %v, want jobs.CodedError", err)
+	}
+	assert.Equal(t, coded.Code, "deployment_sql_failed")
+	assert.Equal(t, coded.Retryable, false)
+
+	deployment := getDeploymentForTest(t, app, created.ID)
+	assert.Equal(t, deployment.Status, database.DeploymentStatusFailed)
+	assert.Equal(t, deployment.Targets[0].Status, database.DeploymentTargetStatusFailed)
+	assert.Equal(t, deployment.Targets[0].ErrorCode, "deployment_sql_failed")
+	if deployment.Targets[0].ErrorMessage == "" {
+		t.Fatal("failed target should keep the database error")
+	}
+	assert.Equal(t, deployment.Targets[1].Status, database.DeploymentTargetStatusSkipped)
+
+	count, err := app.db.NewSelect().Model((*jobs.Record)(nil)).
+		Where("type = ? AND status = ?", jobs.TypeDeploySQL, jobs.StatusQueued).
+		Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 0)
+}
+
+func TestDeploymentToProductionAppliesEnvironmentProtection(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+	ctx := context.Background()
+
+	production := seedEnvironment(t, app, ws.ID, org.ID, "Prod")
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, production.ID, database.EnvironmentProtectionProduction, nil))
+	conn := seedSQLiteFileConnection(t, app, ws.ID, production.ID, "production-db")
+	file := seedDeploymentFile(t, app, org.Slug, ws.ID, tok, "migrate.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY);")
+	deploy := func(confirm ...string) testResponse {
+		return send(t, newAuthRequest(t, http.MethodPost, deploymentsURL(org.Slug, ws.ID), map[string]any{
+			"file_id":              file.ID,
+			"connection_ids":       []int64{conn.ID},
+			"confirm_environments": confirm,
+		}, tok), app.routes())
+	}
+
+	assertAPIError(t, deploy("Prod"), apiErrorDDLWindowClosed, "")
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, production.ID, database.EnvironmentProtectionProduction, openDDLWindows()))
+	assertAPIError(t, deploy(), apiErrorEnvironmentConfirmationRequired, "")
+	res := deploy("Staging", "Prod")
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	created := decodeDeployment(t, res)
+
+	// The window is checked again when the target runs.
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, production.ID, database.EnvironmentProtectionProduction, nil))
+	_, err := runQueuedDeploymentJob(t, app)
+	var coded jobs.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("error = %v, want jobs.CodedError", err)
+	}
+	deployment := getDeploymentForTest(t, app, created.ID)
+	assert.Equal(t, deployment.Status, database.DeploymentStatusFailed)
+	assert.Equal(t, deployment.Targets[0].ErrorCode, apiErrorDDLWindowClosed)
+}
+
+func TestDeploymentRequiresEnvDeployOnEveryTarget(t *testing.T) {
+	t.Parallel()
+	app, org, ws, ownerTok := setupWorkspaceOwner(t)
+
+	member, memberTok := seedAccountWithToken(t, app, uniqueEmail(t, "deploy-member"), "Deploy Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	readerRoleID := createRoleForTest(t, app, org.ID, nil, "org", "ws:read", "wsfile:read", "env:read")
+	assert.Equal(t, grantOrgPolicyRole(t, app, ownerTok, org.Slug, readerRoleID, "account", member.ID).StatusCode, http.StatusNoContent)
+
+	staging := seedEnvironment(t, app, ws.ID, org.ID, "staging")
+	production := seedEnvironment(t, app, ws.ID, org.ID, "production")
+	deployRoleID := createRoleForTest(t, app, org.ID, nil, "environment", "env:deploy")
+	grantRes := grantWorkspacePolicyRole(t, app, ownerTok, org.Slug, strconv.FormatInt(ws.ID, 10), deployRoleID, "account", member.ID, "environment", staging.ID)
+	assert.Equal(t, grantRes.StatusCode, http.StatusNoContent)
+
+	stagingConn := seedConnection(t, app, ws.ID, &staging.ID, org.ID, "sqlite", "staging-db", "open")
+	productionConn := seedConnection(t, app, ws.ID, &production.ID, org.ID, "sqlite", "production-db", "open")
+	file := seedDeploymentFile(t, app, org.Slug, ws.ID, ownerTok, "migrate.sql", "SELECT 1;")
+
+	denied := send(t, newAuthRequest(t, http.MethodPost, deploymentsURL(org.Slug, ws.ID), map[string]any{
+		"file_id":        file.ID,
+		"connection_ids": []int64{stagingConn.ID, productionConn.ID},
+	}, memberTok), app.routes())
+	assert.Equal(t, denied.StatusCode, http.StatusForbidden)
+
+	allowed := send(t, newAuthRequest(t, http.MethodPost, deploymentsURL(org.Slug, ws.ID), map[string]any{
+		"file_id":        file.ID,
+		"connection_ids": []int64{stagingConn.ID},
+	}, memberTok), app.routes())
+	assert.Equal(t, allowed.StatusCode, http.StatusCreated)
+
+	count, err := app.db.NewSelect().Model((*database.Deployment)(nil)).Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 1)
+}
+
+func TestDeploymentValidatesFileAndRevision(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	conn := seedConnection(t, app, ws.ID, &envID, org.ID, "sqlite", "target-db", "open")
+	notes := seedDeploymentFile(t, app, org.Slug, ws.ID, tok, "notes.txt", "SELECT 1;")
+	migration := seedDeploymentFile(t, app, org.Slug, ws.ID, tok, "migrate.sql", "SELECT 1;")
+
+	tests := []struct {
+		name  string
+		body  map[string]any
+		field string
+	}{
+		{name: "not sql", body: map[string]any{"file_id": notes.ID, "connection_ids": []int64{conn.ID}}, field: "file_id"},
+		{name: "missing file", body: map[string]any{"file_id": 999999, "connection_ids": []int64{conn.ID}}, field: "file_id"},
+		{name: "missing revision", body: map[string]any{"file_id": migration.ID, "content_version": 42, "connection_ids": []int64{conn.ID}}, field: "content_version"},
+		{name: "no targets", body: map[string]any{"file_id": migration.ID, "connection_ids": []int64{}}, field: "connection_ids"},
+		{name: "duplicate target", body: map[string]any{"file_id": migration.ID, "connection_ids": []int64{conn.ID, conn.ID}}, field: "connection_ids"},
+		{name: "unknown connection", body: map[string]any{"file_id": migration.ID, "connection_ids": []int64{999999}}, field: "connection_ids"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, newAuthRequest(t, http.MethodPost, deploymentsURL(org.Slug, ws.ID), tt.body, tok), app.routes())
+			assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+			assertValidationField(t, res, tt.field)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -10,9 +11,10 @@ import (
 )
 
 type effectivePermissionsResponse struct {
-	ResourceType string   `json:"resource_type"`
-	ResourceID   int64    `json:"resource_id"`
-	Permissions  []string `json:"permissions"`
+	ResourceType    string   `json:"resource_type"`
+	ResourceID      int64    `json:"resource_id"`
+	Permissions     []string `json:"permissions"`
+	ProtectionLevel string   `json:"protection_level,omitempty"`
 }
 
 func (app *application) getEffectivePermissions(w http.ResponseWriter, r *http.Request) {
@@ -24,7 +26,7 @@ func (app *application) getEffectivePermissions(w http.ResponseWriter, r *http.R
 		resourceType = "org"
 	}
 
-	resourceID, ok := app.resolveEffectivePermissionResource(w, r, resourceType)
+	resourceID, ok := app.resolveEffectivePermissionResource(w, r, resourceType, strings.TrimSpace(r.URL.Query().Get("resource_id")))
 	if !ok {
 		return
 	}
@@ -38,27 +40,57 @@ func (app *application) getEffectivePermissions(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	protectionLevel, err := app.resourceProtectionLevel(r.Context(), resourceType, resourceID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	app.logDebug(r, "effective permissions resolved",
 		slog.String("resource_type", resourceType),
 		slog.Int64("resource_id", resourceID),
 		slog.Int("permission_count", len(permissions)),
 	)
 	err = response.JSON(w, http.StatusOK, effectivePermissionsResponse{
-		ResourceType: resourceType,
-		ResourceID:   resourceID,
-		Permissions:  permissions,
+		ResourceType:    resourceType,
+		ResourceID:      resourceID,
+		Permissions:     permissions,
+		ProtectionLevel: protectionLevel,
 	})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
 }
 
-func (app *application) resolveEffectivePermissionResource(w http.ResponseWriter, r *http.Request, resourceType string) (int64, bool) {
+// resourceProtectionLevel returns the protection level of the environment an
+// environment or connection belongs to, and "" for other resources.
+func (app *application) resourceProtectionLevel(ctx context.Context, resourceType string, resourceID int64) (string, error) {
+	environmentID := resourceID
+	switch resourceType {
+	case "environment":
+	case "connection":
+		conn, found, err := app.db.GetConnection(ctx, resourceID)
+		if err != nil || !found {
+			return "", err
+		}
+		environmentID = conn.EnvironmentID
+	default:
+		return "", nil
+	}
+	env, found, err := app.db.GetEnvironment(ctx, environmentID)
+	if err != nil || !found {
+		return "", err
+	}
+	return env.ProtectionLevel, nil
+}
+
+// resolveEffectivePermissionResource parses rawID and verifies that the
+// resource belongs to the request's org. An empty rawID means the org itself.
+func (app *application) resolveEffectivePermissionResource(w http.ResponseWriter, r *http.Request, resourceType, rawID string) (int64, bool) {
 	org := contextGetOrg(r)
 
 	switch resourceType {
 	case "org":
-		rawID := strings.TrimSpace(r.URL.Query().Get("resource_id"))
 		if rawID == "" {
 			return org.ID, true
 		}
@@ -79,7 +111,7 @@ func (app *application) resolveEffectivePermissionResource(w http.ResponseWriter
 		return resourceID, true
 
 	case "workspace":
-		resourceID, ok := app.requiredEffectivePermissionResourceID(w, r)
+		resourceID, ok := app.requiredEffectivePermissionResourceID(w, r, rawID)
 		if !ok {
 			return 0, false
 		}
@@ -101,7 +133,7 @@ func (app *application) resolveEffectivePermissionResource(w http.ResponseWriter
 		return resourceID, true
 
 	case "environment":
-		resourceID, ok := app.requiredEffectivePermissionResourceID(w, r)
+		resourceID, ok := app.requiredEffectivePermissionResourceID(w, r, rawID)
 		if !ok {
 			return 0, false
 		}
@@ -137,7 +169,7 @@ func (app *application) resolveEffectivePermissionResource(w http.ResponseWriter
 		return resourceID, true
 
 	case "connection":
-		resourceID, ok := app.requiredEffectivePermissionResourceID(w, r)
+		resourceID, ok := app.requiredEffectivePermissionResourceID(w, r, rawID)
 		if !ok {
 			return 0, false
 		}
@@ -180,8 +212,7 @@ func (app *application) resolveEffectivePermissionResource(w http.ResponseWriter
 	}
 }
 
-func (app *application) requiredEffectivePermissionResourceID(w http.ResponseWriter, r *http.Request) (int64, bool) {
-	rawID := strings.TrimSpace(r.URL.Query().Get("resource_id"))
+func (app *application) requiredEffectivePermissionResourceID(w http.ResponseWriter, r *http.Request, rawID string) (int64, bool) {
 	if rawID == "" {
 		app.failedValidation(w, r, fieldErrors(map[string]string{
 			"resource_id": "Resource is required.",
@@ -8,6 +8,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	completionapp "github.com/sqlwarden/internal/completion"
 	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/connconfig"
 	"github.com/sqlwarden/internal/engine/ddl"
 	"github.com/sqlwarden/internal/engine/statement"
 	"github.com/sqlwarden/internal/response"
@@ -21,6 +22,7 @@ type engineView struct {
 	Schema       *schemaSpecPayload         `json:"schema,omitempty"`
 	DDL          *ddl.Spec                  `json:"schema_edit,omitempty"`
 	Statements   *statement.Spec            `json:"statements,omitempty"`
+	Connection   *connconfig.Spec           `json:"connection,omitempty"`
 }
 
 // schemaSpecPayload mirrors schema.SchemaSpec but lives here so the engines API
@@ -42,6 +44,7 @@ func engineToView(set engine.CapabilitySet) engineView {
 		Capabilities: set.Capabilities,
 		DDL:          set.DDL,
 		Statements:   set.Statements,
+		Connection:   set.Connection,
 	}
 	if set.Schema != nil {
 		v.Schema = &schemaSpecPayload{Dialect: set.Schema.Dialect, Kinds: set.Schema.Kinds}
@@ -93,9 +93,13 @@ func compareEnvironment(left, right database.Environment, sortBy string) int {
 
 func (app *application) createEnvironment(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name        string              `json:"name"`
-		Description string              `json:"description"`
-		V           validator.Validator `json:"-"`
+		Name              string                `json:"name"`
+		Description       string                `json:"description"`
+		QueryMaxExecution *int64                `json:"query_max_execution_seconds"`
+		SessionInit       *database.SessionInit `json:"session_init"`
+		ProtectionLevel   *string               `json:"protection_level"`
+		DDLWindows        []database.DDLWindow  `json:"ddl_windows"`
+		V                 validator.Validator   `json:"-"`
 	}
 
 	err := request.DecodeJSON(w, r, &input)
@@ -105,12 +109,26 @@ func (app *application) createEnvironment(w http.ResponseWriter, r *http.Request
 	}
 
 	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	ws := contextGetWorkspace(r)
+	if input.QueryMaxExecution != nil {
+		settings, err := app.effectiveRuntimeSettingsForWorkspace(r.Context(), ws)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		validateQueryExecutionOverride(&input.V, input.QueryMaxExecution, settings.QueryMaxExecutionTime)
+	}
+	validateSessionInit(r.Context(), &input.V, "", input.SessionInit)
+	protectionLevel := database.EnvironmentProtectionDevelopment
+	if input.ProtectionLevel != nil {
+		protectionLevel = *input.ProtectionLevel
+	}
+	validateEnvironmentProtection(&input.V, protectionLevel, input.DDLWindows)
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
 		return
 	}
 
-	ws := contextGetWorkspace(r)
 	env, err := app.db.InsertEnvironment(r.Context(), ws.ID, input.Name, input.Description)
 	if err != nil {
 		if isUniqueViolation(err) {
@@ -120,8 +138,31 @@ func (app *application) createEnvironment(w http.ResponseWriter, r *http.Request
 		app.serverError(w, r, err)
 		return
 	}
+	if input.QueryMaxExecution != nil {
+		if err := app.db.UpdateEnvironmentQueryMaxExecution(r.Context(), env.ID, input.QueryMaxExecution); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		env.QueryMaxExecutionSeconds = input.QueryMaxExecution
+	}
+	if input.SessionInit != nil {
+		if err := app.db.UpdateEnvironmentSessionInit(r.Context(), env.ID, input.SessionInit); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		env.SessionInit = input.SessionInit
+	}
+	if protectionLevel != env.ProtectionLevel || len(input.DDLWindows) > 0 {
+		if err := app.db.UpdateEnvironmentProtection(r.Context(), env.ID, protectionLevel, input.DDLWindows); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		env.ProtectionLevel = protectionLevel
+		env.DDLWindows = input.DDLWindows
+	}
 
-	app.logInfo(r, "environment created", slog.Int64("workspace_id", ws.ID), slog.Int64("environment_id", env.ID))
+	app.logInfo(r, "environment created", slog.Int64("workspace_id", ws.ID), slog.Int64("environment_id", env.ID), slog.String("protection_level", env.ProtectionLevel))
+	app.recordAudit(r, workspaceAuditEvent(r, "environment.create", "environment", env.ID, nil))
 	err = response.JSON(w, http.StatusCreated, env)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -152,10 +193,14 @@ func (app *application) getEnvironment(w http.ResponseWriter, r *http.Request) {
 
 func (app *application) updateEnvironment(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name        string              `json:"name"`
-		Description string              `json:"description"`
-		WorkspaceID *int64              `json:"workspace_id"`
-		V           validator.Validator `json:"-"`
+		Name              string                              `json:"name"`
+		Description       string                              `json:"description"`
+		WorkspaceID       *int64                              `json:"workspace_id"`
+		QueryMaxExecution nullablePatch[int64]                `json:"query_max_execution_seconds"`
+		SessionInit       nullablePatch[database.SessionInit] `json:"session_init"`
+		ProtectionLevel   *string                             `json:"protection_level"`
+		DDLWindows        nullablePatch[[]database.DDLWindow] `json:"ddl_windows"`
+		V                 validator.Validator                 `json:"-"`
 	}
 
 	err := request.DecodeJSON(w, r, &input)
@@ -166,12 +211,38 @@ func (app *application) updateEnvironment(w http.ResponseWriter, r *http.Request
 
 	input.V.CheckField(input.Name != "", "name", "Name is required.")
 	input.V.CheckField(input.WorkspaceID == nil, "workspace_id", "Workspace is immutable.")
+	if input.QueryMaxExecution.Set {
+		settings, err := app.effectiveRuntimeSettingsForWorkspace(r.Context(), contextGetWorkspace(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		validateQueryExecutionOverride(&input.V, input.QueryMaxExecution.Value, settings.QueryMaxExecutionTime)
+	}
+	validateSessionInit(r.Context(), &input.V, "", input.SessionInit.Value)
+	env := contextGetEnvironment(r)
+	// Windows are kept while the environment stays production and dropped
+	// when it leaves it, unless the request sets them.
+	protectionChanged := input.ProtectionLevel != nil || input.DDLWindows.Set
+	protectionLevel := env.ProtectionLevel
+	if input.ProtectionLevel != nil {
+		protectionLevel = *input.ProtectionLevel
+	}
+	var ddlWindows []database.DDLWindow
+	switch {
+	case input.DDLWindows.Set && input.DDLWindows.Value != nil:
+		ddlWindows = *input.DDLWindows.Value
+	case !input.DDLWindows.Set && protectionLevel == database.EnvironmentProtectionProduction:
+		ddlWindows = env.DDLWindows
+	}
+	if protectionChanged {
+		validateEnvironmentProtection(&input.V, protectionLevel, ddlWindows)
+	}
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
 		return
 	}
 
-	env := contextGetEnvironment(r)
 	err = app.db.UpdateEnvironment(r.Context(), env.ID, input.Name, input.Description)
 	if err != nil {
 		if isUniqueViolation(err) {
@@ -181,8 +252,41 @@ func (app *application) updateEnvironment(w http.ResponseWriter, r *http.Request
 		app.serverError(w, r, err)
 		return
 	}
+	var auditDetails map[string]any
+	if input.QueryMaxExecution.Set {
+		if err := app.db.UpdateEnvironmentQueryMaxExecution(r.Context(), env.ID, input.QueryMaxExecution.Value); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		auditDetails = map[string]any{"query_max_execution_seconds": input.QueryMaxExecution.Value}
+	}
+	// Open sessions keep the init they ran; the new one applies to sessions
+	// opened from now on.
+	if input.SessionInit.Set {
+		if err := app.db.UpdateEnvironmentSessionInit(r.Context(), env.ID, input.SessionInit.Value); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if auditDetails == nil {
+			auditDetails = map[string]any{}
+		}
+		auditDetails["session_init_changed"] = true
+	}
+	if protectionChanged {
+		if err := app.db.UpdateEnvironmentProtection(r.Context(), env.ID, protectionLevel, ddlWindows); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if auditDetails == nil {
+			auditDetails = map[string]any{}
+		}
+		auditDetails["protection_level"] = protectionLevel
+		auditDetails["previous_protection_level"] = env.ProtectionLevel
+		auditDetails["ddl_windows"] = len(ddlWindows)
+	}
 
-	app.logInfo(r, "environment updated", slog.Int64("environment_id", env.ID), slog.Int64("workspace_id", env.WorkspaceID))
+	app.logInfo(r, "environment updated", slog.Int64("environment_id", env.ID), slog.Int64("workspace_id", env.WorkspaceID), slog.Bool("query_time_limit_changed", input.QueryMaxExecution.Set), slog.Bool("session_init_changed", input.SessionInit.Set), slog.String("protection_level", protectionLevel))
+	app.recordAudit(r, workspaceAuditEvent(r, "environment.update", "environment", env.ID, auditDetails))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -212,5 +316,6 @@ func (app *application) deleteEnvironment(w http.ResponseWriter, r *http.Request
 		app.enforcer.InvalidateAncestry("connection", cid)
 	}
 	app.logInfo(r, "environment deleted", slog.Int64("environment_id", env.ID), slog.Int64("workspace_id", env.WorkspaceID), slog.Int("affected_connections", len(connIDs)))
+	app.recordAudit(r, workspaceAuditEvent(r, "environment.delete", "environment", env.ID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
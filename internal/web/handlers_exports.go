@@ -51,6 +51,7 @@ type exportJobOutput struct {
 	Format    string `json:"format"`
 	RowCount  int64  `json:"row_count"`
 	ByteCount int64  `json:"byte_count"`
+	Endpoint  string `json:"endpoint,omitempty"`
 }
 
 func (app *application) createConnectionExport(w http.ResponseWriter, r *http.Request) {
@@ -260,15 +261,39 @@ func (app *application) handleExportJob(ctx context.Context, runtime jobs.Runtim
 	if err := app.validateTargetConnection(conn.Driver, plainDSN); err != nil {
 		return nil, jobs.Permanent("export_target_blocked", targetConnectionFieldError(err))
 	}
-	driver, err := engine.New(conn.Driver)
+	tlsMaterial, err := app.connectionTLS(conn)
 	if err != nil {
 		return nil, err
 	}
-	if err := driver.Connect(ctx, engine.ConnectionConfig{DSN: plainDSN, Driver: conn.Driver, DefaultScope: conn.DefaultScope}); err != nil {
+	dsn, lease, err := app.accountConnectionCredentials(ctx, conn, input.AccountID, plainDSN)
+	if err != nil {
+		if errors.Is(err, errUserCredentialMissing) {
+			return nil, jobs.Permanent("export_credentials_missing", "Set your credentials for this connection before exporting.")
+		}
+		if errors.Is(err, errConnectionCredentials) {
+			return nil, jobs.Retryable("export_credentials_failed", "Could not resolve the target database credentials.")
+		}
+		return nil, err
+	}
+	defer lease.Close()
+	tunnel, err := app.openConnectionTunnel(ctx, conn)
+	if err != nil {
+		return nil, jobs.Retryable("export_tunnel_failed", "Could not open the SSH tunnel to the target database.")
+	}
+	defer tunnel.Close()
+	// Exports are long reads, so they run on a replica when one answers.
+	driver, endpoint, err := app.connectPreferringReplicas(ctx, conn, engine.ConnectionConfig{DSN: dsn, Driver: conn.Driver, DefaultScope: conn.DefaultScope, Dial: app.connectionDialer(conn, tunnel.Dialer()), TLS: tlsMaterial}, func(name string, err error) {
+		runtime.Events.Warn(ctx, "replica_unavailable", "Read replica could not be reached.", map[string]any{"endpoint": name})
+	})
+	if err != nil {
 		return nil, jobs.Retryable("export_connect_failed", "Could not connect to the target database.")
 	}
 	defer driver.Close()
-	runtime.Events.Info(ctx, "target_connected", "Connected to database.", nil)
+	var connectedDetails any
+	if endpoint != "" {
+		connectedDetails = map[string]any{"endpoint": endpoint}
+	}
+	runtime.Events.Info(ctx, "target_connected", "Connected to database.", connectedDetails)
 	runtimeSettings, err := app.effectiveRuntimeSettingsForWorkspace(ctx, ws)
 	if err != nil {
 		return nil, err
@@ -315,7 +340,7 @@ func (app *application) handleExportJob(ctx context.Context, runtime jobs.Runtim
 		return nil, exportJobError(err)
 	}
 	runtime.Events.Info(ctx, "export_succeeded", "Export completed.", map[string]any{"file_id": file.ID, "rows": streamResult.Rows, "bytes": streamResult.Bytes})
-	return exportJobOutput{FileID: file.ID, Filename: file.Name, Format: input.Format, RowCount: streamResult.Rows, ByteCount: streamResult.Bytes}, nil
+	return exportJobOutput{FileID: file.ID, Filename: file.Name, Format: input.Format, RowCount: streamResult.Rows, ByteCount: streamResult.Bytes, Endpoint: endpoint}, nil
 }
 
 func (app *application) createExportFile(ctx context.Context, scope files.Scope, requested string) (database.WorkspaceFile, error) {
@@ -17,6 +17,7 @@ import (
 	"github.com/sqlwarden/internal/response"
 	"github.com/sqlwarden/internal/token"
 	"github.com/sqlwarden/internal/validator"
+	"github.com/sqlwarden/internal/webauthn"
 	"github.com/uptrace/bun"
 )
 
@@ -350,6 +351,7 @@ func (app *application) createInstanceAccount(w http.ResponseWriter, r *http.Req
 		return
 	}
 	app.logInfo(r, "instance account created", slog.Int64("account_id", account.ID))
+	app.recordAudit(r, instanceAuditEvent("instance.account.create", "account", account.ID, nil))
 	err = response.JSON(w, http.StatusCreated, account)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -381,6 +383,7 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 		QueryMaxResultRows             *int                  `json:"query_max_result_rows"`
 		QueryCursorPageSize            *int                  `json:"query_cursor_page_size"`
 		QueryMaxResultBytes            *int64                `json:"query_max_result_bytes"`
+		QueryMaxExecutionSeconds       *int64                `json:"query_max_execution_seconds"`
 		ExportsSyncMaxBytes            *int64                `json:"exports_sync_max_bytes"`
 		ExportsBackgroundMaxBytes      *int64                `json:"exports_background_max_bytes"`
 		SchemaSnapshotFreshnessSeconds *int64                `json:"schema_snapshot_freshness_seconds"`
@@ -404,6 +407,11 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 		QueryHistoryRetentionCount     *int                  `json:"query_history_retention_count"`
 		QueryHistoryRetentionCountMax  *int                  `json:"query_history_retention_count_max"`
 		QueryFavoritesMode             *string               `json:"query_favorites_mode"`
+		MFARequired                    *bool                 `json:"mfa_required"`
+		WebAuthnUserVerification       *string               `json:"webauthn_user_verification"`
+		LoginLockoutThreshold          *int                  `json:"login_lockout_threshold"`
+		LoginIPLockoutThreshold        *int                  `json:"login_ip_lockout_threshold"`
+		LoginLockoutSeconds            *int64                `json:"login_lockout_seconds"`
 		V                              validator.Validator   `json:"-"`
 	}
 
@@ -423,6 +431,7 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 		input.QueryMaxResultRows != nil ||
 		input.QueryCursorPageSize != nil ||
 		input.QueryMaxResultBytes != nil ||
+		input.QueryMaxExecutionSeconds != nil ||
 		input.ExportsSyncMaxBytes != nil ||
 		input.ExportsBackgroundMaxBytes != nil ||
 		input.SchemaSnapshotFreshnessSeconds != nil ||
@@ -435,7 +444,9 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 		input.SMTPHost != nil || input.SMTPPort != nil || input.SMTPUsername != nil ||
 		input.SMTPPassword.Set || input.SMTPFrom != nil ||
 		input.QueryHistoryMode != nil || input.QueryHistoryRetentionCount != nil ||
-		input.QueryHistoryRetentionCountMax != nil || input.QueryFavoritesMode != nil
+		input.QueryHistoryRetentionCountMax != nil || input.QueryFavoritesMode != nil ||
+		input.MFARequired != nil || input.WebAuthnUserVerification != nil ||
+		input.LoginLockoutThreshold != nil || input.LoginIPLockoutThreshold != nil || input.LoginLockoutSeconds != nil
 	input.V.Check(hasPatch, "At least one setting is required.")
 	if input.InstanceName != nil {
 		*input.InstanceName = strings.TrimSpace(*input.InstanceName)
@@ -466,6 +477,9 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 	if input.QueryMaxResultBytes != nil {
 		input.V.CheckField(*input.QueryMaxResultBytes > 0, "query_max_result_bytes", "Query byte limit must be greater than 0.")
 	}
+	if input.QueryMaxExecutionSeconds != nil {
+		input.V.CheckField(*input.QueryMaxExecutionSeconds >= 0 && *input.QueryMaxExecutionSeconds <= maxRuntimeDurationSeconds, "query_max_execution_seconds", "Query time limit must be 0 (unlimited) or a positive number of seconds.")
+	}
 	if input.ExportsSyncMaxBytes != nil {
 		input.V.CheckField(*input.ExportsSyncMaxBytes > 0, "exports_sync_max_bytes", "Synchronous export limit must be greater than 0.")
 	}
@@ -528,6 +542,18 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 	if input.QueryFavoritesMode != nil {
 		input.V.CheckField(isSupportedQueryHistoryMode(*input.QueryFavoritesMode), "query_favorites_mode", "Query favorites mode must be backend, local, or off.")
 	}
+	if input.WebAuthnUserVerification != nil {
+		input.V.CheckField(webauthn.UserVerification(*input.WebAuthnUserVerification).Valid(), "webauthn_user_verification", "User verification must be required, preferred, or discouraged.")
+	}
+	if input.LoginLockoutThreshold != nil {
+		input.V.CheckField(*input.LoginLockoutThreshold >= 0 && *input.LoginLockoutThreshold <= 1000, "login_lockout_threshold", "Lockout threshold must be between 0 and 1000.")
+	}
+	if input.LoginIPLockoutThreshold != nil {
+		input.V.CheckField(*input.LoginIPLockoutThreshold >= 0 && *input.LoginIPLockoutThreshold <= 100000, "login_ip_lockout_threshold", "IP lockout threshold must be between 0 and 100000.")
+	}
+	if input.LoginLockoutSeconds != nil {
+		input.V.CheckField(*input.LoginLockoutSeconds > 0 && *input.LoginLockoutSeconds <= 86400, "login_lockout_seconds", "Lockout duration must be between 1 and 86400 seconds.")
+	}
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
 		return
@@ -570,6 +596,9 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 	if input.QueryMaxResultBytes != nil {
 		nextSettings.QueryMaxResultBytes = *input.QueryMaxResultBytes
 	}
+	if input.QueryMaxExecutionSeconds != nil {
+		nextSettings.QueryMaxExecutionSeconds = *input.QueryMaxExecutionSeconds
+	}
 	if input.ExportsSyncMaxBytes != nil {
 		nextSettings.ExportsSyncMaxBytes = *input.ExportsSyncMaxBytes
 	}
@@ -646,6 +675,24 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 	if input.QueryFavoritesMode != nil {
 		nextSettings.QueryFavoritesMode = *input.QueryFavoritesMode
 	}
+	if input.WebAuthnUserVerification != nil {
+		nextSettings.WebAuthnUserVerification = *input.WebAuthnUserVerification
+	}
+	if input.LoginLockoutThreshold != nil {
+		nextSettings.LoginLockoutThreshold = *input.LoginLockoutThreshold
+	}
+	if input.LoginIPLockoutThreshold != nil {
+		nextSettings.LoginIPLockoutThreshold = *input.LoginIPLockoutThreshold
+	}
+	if input.LoginLockoutSeconds != nil {
+		nextSettings.LoginLockoutSeconds = *input.LoginLockoutSeconds
+	}
+	if input.MFARequired != nil {
+		nextSettings.MFARequired = *input.MFARequired
+		if nextSettings.MFARequired && !currentSettings.MFARequired && !sessionPresentedMFA(contextGetAuthSession(r)) {
+			input.V.AddFieldError("mfa_required", "Sign in with a second factor before requiring one.")
+		}
+	}
 	if err := validateInstanceSettings(nextSettings); err != nil {
 		input.V.AddError(err.Error())
 	}
@@ -671,7 +718,8 @@ func (app *application) updateInstanceSettings(w http.ResponseWriter, r *http.Re
 		}
 	}
 
-	app.logInfo(r, "instance settings updated", slog.Bool("personal_spaces_enabled", settings.PersonalSpacesEnabled))
+	app.logInfo(r, "instance settings updated", slog.Bool("personal_spaces_enabled", settings.PersonalSpacesEnabled), slog.Bool("mfa_required", settings.MFARequired))
+	app.recordAudit(r, instanceAuditEvent("instance.settings.update", "instance_settings", 1, nil))
 	err = response.JSON(w, http.StatusOK, app.instanceSettingsResponse(settings))
 	if err != nil {
 		app.serverError(w, r, err)
@@ -707,6 +755,11 @@ func (app *application) addInstanceAdmin(w http.ResponseWriter, r *http.Request)
 		app.notFound(w, r)
 		return
 	}
+	if account.IsService() {
+		input.V.AddFieldError("email", "Service accounts cannot be instance admins.")
+		app.failedValidation(w, r, input.V)
+		return
+	}
 
 	err = app.db.InsertInstanceAdmin(r.Context(), account.ID)
 	if err != nil {
@@ -715,6 +768,7 @@ func (app *application) addInstanceAdmin(w http.ResponseWriter, r *http.Request)
 	}
 
 	app.logInfo(r, "instance admin added", slog.Int64("target_account_id", account.ID))
+	app.recordAudit(r, instanceAuditEvent("instance.admin.add", "account", account.ID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -741,5 +795,6 @@ func (app *application) removeInstanceAdmin(w http.ResponseWriter, r *http.Reque
 	}
 
 	app.logInfo(r, "instance admin removed", slog.Int64("target_account_id", accountID))
+	app.recordAudit(r, instanceAuditEvent("instance.admin.remove", "account", accountID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
 	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/password"
 	"github.com/sqlwarden/internal/request"
@@ -110,6 +111,7 @@ func (app *application) createOrganizationInvitation(w http.ResponseWriter, r *h
 	}
 	deliveryStatus := app.deliverOrganizationInvitation(r, invitation, org, inviter.Name, inviteURL)
 	invitation.LastDeliveryStatus = deliveryStatus
+	app.recordAudit(r, audit.Event{OrgID: &org.ID, Action: "org.invitation.create", ResourceType: "invitation", ResourceID: invitation.ID})
 	if err := response.JSON(w, http.StatusCreated, invitationResponse{Invitation: invitation, InviteURL: inviteURL, DeliveryStatus: deliveryStatus}); err != nil {
 		app.serverError(w, r, err)
 	}
@@ -161,6 +163,7 @@ func (app *application) revokeOrganizationInvitation(w http.ResponseWriter, r *h
 		app.notFound(w, r)
 		return
 	}
+	app.recordAudit(r, audit.Event{OrgID: &org.ID, Action: "org.invitation.revoke", ResourceType: "invitation", ResourceID: chi.URLParam(r, "invitation_id")})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -292,6 +295,14 @@ func (app *application) acceptOrganizationInvitation(w http.ResponseWriter, r *h
 	if app.enforcer != nil {
 		app.enforcer.InvalidatePrincipals(org.ID, account.ID)
 	}
+	app.recordAudit(r, audit.Event{
+		OrgID:          &org.ID,
+		ActorAccountID: &account.ID,
+		Action:         "org.invitation.accept",
+		ResourceType:   "invitation",
+		ResourceID:     invitation.ID,
+		Details:        map[string]any{"account_created": created},
+	})
 	result := map[string]any{"organization": org}
 	status := http.StatusOK
 	if created {
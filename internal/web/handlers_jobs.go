@@ -109,6 +109,9 @@ func (app *application) cancelWorkspaceJob(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	app.logInfo(r, "job cancellation requested", slog.String("job.id", job.ID), slog.String("job.type", job.Type), slog.String("job.status", job.Status))
+	if job.Type == jobs.TypeDeploySQL && job.Status == jobs.StatusCancelled {
+		app.cancelQueuedDeploymentJob(r.Context(), job)
+	}
 	if err := response.JSON(w, http.StatusOK, job); err != nil {
 		app.serverError(w, r, err)
 	}
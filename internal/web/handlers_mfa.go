@@ -0,0 +1,590 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/totp"
+	"github.com/sqlwarden/internal/validator"
+	"github.com/sqlwarden/internal/webauthn"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	maxMFAChallengeAttempts = 5
+	mfaRecoveryCodeCount    = 10
+)
+
+// sessionPresentedMFA reports whether the auth session was signed in, or has
+// since been confirmed, with a second factor.
+func sessionPresentedMFA(authSession database.AuthSession) bool {
+	return authSession.MFAMethod != ""
+}
+
+// satisfiesMFA reports whether the request meets an MFA requirement. Auth
+// sessions qualify when they presented a second factor, which for SSO
+// sign-ins means the identity provider reported one (see ssoPresentedMFA).
+// Service account tokens are exempt; personal API tokens qualify only when
+// the session that created them had presented a second factor.
+func (app *application) satisfiesMFA(r *http.Request, account database.Account) bool {
+	if apiToken, ok := contextGetAPIToken(r); ok {
+		return account.IsService() || apiToken.MFAVerified
+	}
+	return sessionPresentedMFA(contextGetAuthSession(r))
+}
+
+// enforceMFA writes 403 mfa_required and returns false when required is set
+// and the request does not satisfy it.
+func (app *application) enforceMFA(w http.ResponseWriter, r *http.Request, required bool) bool {
+	if !required {
+		return true
+	}
+	if !app.satisfiesMFA(r, contextGetAccount(r)) {
+		app.mfaRequired(w, r)
+		return false
+	}
+	return true
+}
+
+// requireInstanceMFA applies the instance-wide MFA requirement to routes
+// outside organizations. Account routes stay reachable so members can enroll.
+func (app *application) requireInstanceMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings, err := app.instanceSettings(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !app.enforceMFA(w, r, settings.MFARequired) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// enforceOrgMFA applies the MFA requirements that cover org: the instance-wide
+// one and the organization's own. Only the latter can be met by a sign-in
+// through the organization's provider when it is set to trust_mfa, since the
+// organization's admins configure that provider and vouch for it themselves.
+func (app *application) enforceOrgMFA(w http.ResponseWriter, r *http.Request, org database.Organization) bool {
+	settings, err := app.instanceSettings(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if settings.MFARequired || !org.MFARequired {
+		return app.enforceMFA(w, r, settings.MFARequired)
+	}
+	if app.satisfiesMFA(r, contextGetAccount(r)) {
+		return true
+	}
+	trusted, err := app.orgProviderVouchesMFA(r, org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if !trusted {
+		app.mfaRequired(w, r)
+		return false
+	}
+	return true
+}
+
+// startMFAChallenge answers a correct password for an account with a second
+// factor. No session is issued; the client exchanges the challenge token and
+// one of methods at /auth/login/mfa.
+func (app *application) startMFAChallenge(w http.ResponseWriter, r *http.Request, account database.Account, methods []string) {
+	plaintext, hash, err := token.Generate()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	expiresAt := time.Now().Add(mfaChallengeTTL)
+	err = app.db.InsertMFALoginChallenge(r.Context(), database.MFALoginChallenge{
+		ID:        hash,
+		AccountID: account.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "account login awaiting second factor", slog.Int64("account_id", account.ID))
+	err = response.JSON(w, http.StatusOK, map[string]any{
+		"mfa_required": true,
+		"mfa_token":    plaintext,
+		"mfa_methods":  methods,
+		"expires_at":   expiresAt,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) completeMFALogin(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken     string                      `json:"mfa_token"`
+		Code         string                      `json:"code"`
+		RecoveryCode string                      `json:"recovery_code"`
+		WebAuthn     *webauthn.AssertionResponse `json:"webauthn"`
+		V            validator.Validator         `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	answers := 0
+	for _, given := range []bool{input.Code != "", input.RecoveryCode != "", input.WebAuthn != nil} {
+		if given {
+			answers++
+		}
+	}
+	input.V.CheckField(input.MFAToken != "", "mfa_token", "MFA token is required.")
+	input.V.CheckField(answers == 1, "code", "Enter a code, a recovery code, or use a security key.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	challengeID := token.Hash(input.MFAToken)
+	challenge, found, err := app.db.GetMFALoginChallenge(r.Context(), challengeID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	account, found, err := app.db.GetAccount(r.Context(), challenge.AccountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || !account.IsActive {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	wait, err := app.loginRetryAfter(r, account.Email, false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.loginThrottled(w, r, wait)
+		return
+	}
+
+	var method string
+	var ok bool
+	if input.WebAuthn != nil {
+		method = database.MFAMethodWebAuthn
+		ok, err = app.verifyWebAuthnSecondFactor(r, *input.WebAuthn, challengeID, account.ID)
+	} else {
+		method, ok, err = app.verifySecondFactor(r.Context(), account.ID, input.Code, input.RecoveryCode)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !ok {
+		if err := app.db.RecordMFALoginChallengeFailure(r.Context(), challengeID, maxMFAChallengeAttempts); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		// Wrong codes count toward the account lockout too, so a known
+		// password cannot buy unlimited fresh challenges.
+		if err := app.recordLoginFailure(r, account.Email, &account); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.logWarn(r, "second factor rejected", slog.Int64("account_id", account.ID), slog.Int("attempt", challenge.Attempts+1))
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	consumed, err := app.db.ConsumeMFALoginChallenge(r.Context(), challengeID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !consumed {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+
+	accessToken, authSessionID, err := app.issueAuthSession(w, r, account, database.AuthSessionSignIn{MFAMethod: method})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logInfo(r, "account logged in", slog.Int64("account_id", account.ID), slog.String("auth_session_id", authSessionID), slog.String("mfa_method", method))
+	app.recordAudit(r, audit.Event{
+		ActorAccountID: &account.ID,
+		Action:         "auth.login",
+		ResourceType:   "auth_session",
+		ResourceID:     authSessionID,
+		Details:        map[string]any{"mfa_method": method},
+	})
+
+	err = response.JSON(w, http.StatusOK, map[string]string{"access_token": accessToken})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code against the
+// account's enabled enrollment and uses it up. It returns the method that
+// matched.
+func (app *application) verifySecondFactor(ctx context.Context, accountID int64, code, recoveryCode string) (string, bool, error) {
+	mfa, found, err := app.db.GetAccountMFA(ctx, accountID)
+	if err != nil || !found || !mfa.Enabled() {
+		return "", false, err
+	}
+	if recoveryCode != "" {
+		used, err := app.db.UseMFARecoveryCode(ctx, accountID, token.Hash(totp.NormalizeRecoveryCode(recoveryCode)))
+		return database.MFAMethodRecoveryCode, used, err
+	}
+	step, ok, err := app.checkTOTPCode(mfa, code)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	used, err := app.db.UseTOTPStep(ctx, accountID, step)
+	return database.MFAMethodTOTP, used, err
+}
+
+// checkTOTPCode validates code against the enrollment's secret without using
+// it up.
+func (app *application) checkTOTPCode(mfa database.AccountMFA, code string) (int64, bool, error) {
+	secret, err := app.keyring.Decrypt(mfa.TOTPSecretEncrypted)
+	if err != nil {
+		return 0, false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), mfa.TOTPLastStep)
+	return step, ok, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.GenerateRecoveryCodes(mfaRecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = token.Hash(code)
+	}
+	return codes, hashes, nil
+}
+
+func (app *application) getAccountMFA(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	mfa, found, err := app.db.GetAccountMFA(r.Context(), account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	remaining, err := app.db.CountUnusedMFARecoveryCodes(r.Context(), account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	required, err := app.accountRequiresMFA(r.Context(), account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	methods, err := app.accountMFAMethods(r.Context(), account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	result := map[string]any{
+		"enabled":                  found && mfa.Enabled(),
+		"enabled_at":               nil,
+		"methods":                  methods,
+		"recovery_codes_remaining": remaining,
+		"required":                 required,
+		"session_verified":         sessionPresentedMFA(contextGetAuthSession(r)),
+	}
+	if found && mfa.Enabled() {
+		result["enabled_at"] = mfa.EnabledAt
+	}
+	err = response.JSON(w, http.StatusOK, result)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// accountRequiresMFA reports whether the instance or any of the account's
+// organizations requires MFA.
+func (app *application) accountRequiresMFA(ctx context.Context, accountID int64) (bool, error) {
+	settings, err := app.instanceSettings(ctx)
+	if err != nil || settings.MFARequired {
+		return settings.MFARequired, err
+	}
+	orgs, err := app.db.GetAccountOrgs(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	for _, org := range orgs {
+		if org.MFARequired {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// startTOTPEnrollment generates a secret for the account. It is not used for
+// sign-in until confirmed with a code from the authenticator app.
+func (app *application) startTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	secretEncrypted, err := app.keyring.Encrypt(secret)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	started, err := app.db.StartMFAEnrollment(r.Context(), account.ID, secretEncrypted)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !started {
+		app.errorMessage(w, r, http.StatusConflict, "Multi-factor authentication is already enabled.", nil)
+		return
+	}
+	settings, err := app.instanceSettings(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "mfa enrollment started", slog.Int64("account_id", account.ID))
+	err = response.JSON(w, http.StatusCreated, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(settings.InstanceName, account.Email, secret),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) confirmTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string              `json:"code"`
+		V    validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	account := contextGetAccount(r)
+	mfa, found, err := app.db.GetAccountMFA(r.Context(), account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+	if mfa.Enabled() {
+		app.errorMessage(w, r, http.StatusConflict, "Multi-factor authentication is already enabled.", nil)
+		return
+	}
+	step, ok, err := app.checkTOTPCode(mfa, input.Code)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	input.V.CheckField(ok, "code", "The code is not valid.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.db.EnableAccountMFA(r.Context(), account.ID, step, hashes, contextGetAuthSession(r).ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "mfa enabled", slog.Int64("account_id", account.ID))
+	app.recordAudit(r, audit.Event{Action: "account.mfa.enable", ResourceType: "account", ResourceID: strconv.FormatInt(account.ID, 10)})
+	err = response.JSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) regenerateMFARecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string              `json:"code"`
+		V    validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	account := contextGetAccount(r)
+	_, ok, err := app.verifySecondFactor(r.Context(), account.ID, input.Code, "")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	input.V.CheckField(ok, "code", "The code is not valid.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.db.ReplaceMFARecoveryCodes(r.Context(), account.ID, hashes); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "mfa recovery codes regenerated", slog.Int64("account_id", account.ID))
+	app.recordAudit(r, audit.Event{Action: "account.mfa.recovery_codes.regenerate", ResourceType: "account", ResourceID: strconv.FormatInt(account.ID, 10)})
+	err = response.JSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// disableAccountMFA turns MFA off after checking a current code or a
+// recovery code, so a hijacked session alone cannot remove the factor.
+func (app *application) disableAccountMFA(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code         string              `json:"code"`
+		RecoveryCode string              `json:"recovery_code"`
+		V            validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	account := contextGetAccount(r)
+	input.V.CheckField((input.Code == "") != (input.RecoveryCode == ""), "code", "Enter either a code or a recovery code.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+	_, ok, err := app.verifySecondFactor(r.Context(), account.ID, input.Code, input.RecoveryCode)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	input.V.CheckField(ok, "code", "The code is not valid.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+	if _, err := app.db.DeleteAccountMFA(r.Context(), account.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "mfa disabled", slog.Int64("account_id", account.ID))
+	app.recordAudit(r, audit.Event{Action: "account.mfa.disable", ResourceType: "account", ResourceID: strconv.FormatInt(account.ID, 10)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetInstanceAccountMFA removes an account's TOTP enrollment and WebAuthn
+// credentials for a user who lost their authenticators and recovery codes.
+func (app *application) resetInstanceAccountMFA(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+	reset, err := app.db.ResetAccountMFA(r.Context(), accountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !reset {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "mfa reset", slog.Int64("target_account_id", accountID))
+	app.recordAudit(r, instanceAuditEvent("instance.account.mfa.reset", "account", accountID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetOrgMemberMFA lets an org admin reset a member's MFA. Accounts are
+// global, so this is limited to accounts no other organization or instance
+// admin grant has a claim on; anything else needs an instance admin.
+func (app *application) resetOrgMemberMFA(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+	if member, err := app.db.IsOrgMember(r.Context(), org.ID, accountID); err != nil {
+		app.serverError(w, r, err)
+		return
+	} else if !member {
+		app.notFound(w, r)
+		return
+	}
+	owned, err := app.db.AccountBelongsOnlyToOrg(r.Context(), accountID, org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !owned {
+		app.errorMessage(w, r, http.StatusForbidden, "This account also belongs elsewhere; an instance administrator must reset its multi-factor authentication.", nil)
+		return
+	}
+	reset, err := app.db.ResetAccountMFA(r.Context(), accountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !reset {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "mfa reset", slog.Int64("org_id", org.ID), slog.Int64("target_account_id", accountID))
+	app.recordAudit(r, orgAuditEvent(r, "org.member.mfa.reset", "account", accountID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
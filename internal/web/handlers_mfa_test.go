@@ -0,0 +1,239 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/oidc/oidctest"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/totp"
+)
+
+// enrollTOTPForTest enrolls the token's account in TOTP and returns the secret
+// and its recovery codes. The enrollment uses the current time step, so later
+// sign-ins in the same test should use the next one.
+func enrollTOTPForTest(t *testing.T, app *application, accessToken string) (string, []string) {
+	t.Helper()
+
+	res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/mfa/totp", nil, accessToken), app.routes())
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("start enrollment: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	secret := res.BodyFields["secret"].(string)
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.Nil(t, err)
+	res = send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/mfa/totp/confirm", map[string]any{"code": code}, accessToken), app.routes())
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("confirm enrollment: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	var recoveryCodes []string
+	for _, code := range res.BodyFields["recovery_codes"].([]any) {
+		recoveryCodes = append(recoveryCodes, code.(string))
+	}
+	return secret, recoveryCodes
+}
+
+func TestMFALoginRequiresSecondFactor(t *testing.T) {
+	app := newTestApp(t)
+	setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "mfa@example.com", "MFA User", "securepass99")
+
+	accessToken := extractAccessToken(t, loginTestUser(t, app, "mfa@example.com", "securepass99"))
+	secret, recoveryCodes := enrollTOTPForTest(t, app, accessToken)
+	assert.Equal(t, len(recoveryCodes), mfaRecoveryCodeCount)
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account/mfa", nil, accessToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["enabled"], true)
+	assert.Equal(t, res.BodyFields["session_verified"], true)
+
+	res = loginTestUser(t, app, "mfa@example.com", "securepass99")
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["mfa_required"], true)
+	assert.Nil(t, res.BodyFields["access_token"])
+	mfaToken := res.BodyFields["mfa_token"].(string)
+
+	code, err := totp.Code(secret, totp.Step(time.Now())+1)
+	assert.Nil(t, err)
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "code": code}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	claims, err := token.Verify(extractAccessToken(t, res), app.config.JWT.SecretKey)
+	assert.Nil(t, err)
+	account, _, err := app.db.GetAccountByEmail(context.Background(), "mfa@example.com")
+	assert.Nil(t, err)
+	authSession, found, err := app.db.GetAuthSession(context.Background(), claims.AuthSessionID, account.ID)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, authSession.MFAMethod, database.MFAMethodTOTP)
+
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "code": code}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	res = loginTestUser(t, app, "mfa@example.com", "securepass99")
+	mfaToken = res.BodyFields["mfa_token"].(string)
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "code": code}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "recovery_code": recoveryCodes[0]}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = loginTestUser(t, app, "mfa@example.com", "securepass99")
+	mfaToken = res.BodyFields["mfa_token"].(string)
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "recovery_code": recoveryCodes[0]}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestMFALoginChallengeExpiresAfterRepeatedFailures(t *testing.T) {
+	app := newTestApp(t)
+	setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "guess@example.com", "Guess", "securepass99")
+
+	accessToken := extractAccessToken(t, loginTestUser(t, app, "guess@example.com", "securepass99"))
+	_, recoveryCodes := enrollTOTPForTest(t, app, accessToken)
+
+	mfaToken := loginTestUser(t, app, "guess@example.com", "securepass99").BodyFields["mfa_token"].(string)
+	for range maxMFAChallengeAttempts {
+		res := send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "code": "000000"}), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	}
+	res := send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "recovery_code": recoveryCodes[0]}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestOrgMFARequiredRejectsSessionsWithoutSecondFactor(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "mfa-owner"), "MFA Owner", "MFA Org")
+
+	res := send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+org.Slug+"/", map[string]any{"mfa_required": true}, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+
+	enrollTOTPForTest(t, app, ownerToken)
+	res = send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+org.Slug+"/", map[string]any{"mfa_required": true}, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	memberEmail := uniqueEmail(t, "mfa-member")
+	_, memberToken := seedAccountWithToken(t, app, memberEmail, "MFA Member")
+	addOrgMemberDirect(t, app, org.Slug, memberEmail)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+	assert.Equal(t, res.BodyFields["error"].(map[string]any)["code"], apiErrorMFARequired)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account/mfa", nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["required"], true)
+
+	enrollTOTPForTest(t, app, memberToken)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestPersonalAccessTokenSatisfiesMFAOnlyWhenCreatedWithSecondFactor(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "pat-mfa-owner"), "PAT MFA Owner", "PAT MFA Org")
+	enrollTOTPForTest(t, app, ownerToken)
+	res := send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+org.Slug+"/", map[string]any{"mfa_required": true}, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	memberEmail := uniqueEmail(t, "pat-mfa-member")
+	_, memberToken := seedAccountWithToken(t, app, memberEmail, "PAT MFA Member")
+	addOrgMemberDirect(t, app, org.Slug, memberEmail)
+	_, earlyPAT := createAPITokenForTest(t, app, "/api/v1/account/tokens", memberToken, map[string]any{"name": "before mfa", "scopes": []string{"read"}})
+
+	// Enrolling confirms the session's second factor, but a token minted
+	// before then was never backed by one.
+	enrollTOTPForTest(t, app, memberToken)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, earlyPAT), app.routes())
+	assertAPIError(t, res, apiErrorMFARequired, "")
+
+	_, laterPAT := createAPITokenForTest(t, app, "/api/v1/account/tokens", memberToken, map[string]any{"name": "after mfa", "scopes": []string{"read"}})
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, laterPAT), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestSSOSessionSatisfiesMFAOnlyWithProviderEvidence(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sso-mfa-owner"), "SSO MFA Owner", "SSO MFA Org")
+	enrollTOTPForTest(t, app, ownerToken)
+	res := configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, nil)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	res = send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+org.Slug+"/", map[string]any{"mfa_required": true}, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	member, memberToken := seedAccountWithToken(t, app, uniqueEmail(t, "sso-mfa-member"), "SSO MFA Member")
+	addOrgMemberDirect(t, app, org.Slug, member.Email)
+	linkSSOIdentityForTest(t, app, issuer, org.Slug, memberToken, map[string]any{"sub": "member", "email": member.Email, "email_verified": true})
+	orgStatus := func(claims map[string]any) int {
+		claims["sub"] = "member"
+		claims["email"] = member.Email
+		claims["email_verified"] = true
+		token := ssoAccessToken(t, app, ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims))
+		return send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, token), app.routes()).StatusCode
+	}
+
+	assert.Equal(t, orgStatus(map[string]any{}), http.StatusForbidden)
+	assert.Equal(t, orgStatus(map[string]any{"amr": []string{"pwd"}}), http.StatusForbidden)
+	assert.Equal(t, orgStatus(map[string]any{"amr": []string{"pwd", "otp"}}), http.StatusOK)
+	assert.Equal(t, orgStatus(map[string]any{"amr": []string{"mfa"}}), http.StatusOK)
+
+	res = configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, map[string]any{"trust_mfa": true})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["trust_mfa"].(bool), true)
+	assert.Equal(t, orgStatus(map[string]any{}), http.StatusOK)
+
+	// The organization's trust_mfa only vouches for its own requirement, not
+	// another organization's or the instance's.
+	_, otherOwnerToken, otherOrg := seedOrgOwner(t, app, uniqueEmail(t, "sso-mfa-other"), "SSO MFA Other", "SSO MFA Other Org")
+	enrollTOTPForTest(t, app, otherOwnerToken)
+	res = send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+otherOrg.Slug+"/", map[string]any{"mfa_required": true}, otherOwnerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	addOrgMemberDirect(t, app, otherOrg.Slug, member.Email)
+	token := ssoAccessToken(t, app, ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, map[string]any{"sub": "member", "email": member.Email, "email_verified": true}))
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+otherOrg.Slug+"/", nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+
+	settings, _, err := app.db.GetInstanceSettings(context.Background())
+	assert.Nil(t, err)
+	settings.MFARequired = true
+	_, err = app.db.UpsertInstanceSettings(context.Background(), settings)
+	assert.Nil(t, err)
+	assert.Equal(t, orgStatus(map[string]any{}), http.StatusForbidden)
+	assert.Equal(t, orgStatus(map[string]any{"amr": []string{"mfa"}}), http.StatusOK)
+}
+
+func TestResetMemberMFA(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "mfa-reset-owner"), "Reset Owner", "MFA Reset Org")
+	_, _, otherOrg := seedOrgOwner(t, app, uniqueEmail(t, "mfa-reset-other"), "Other Owner", "MFA Reset Other Org")
+
+	memberEmail := uniqueEmail(t, "mfa-reset-member")
+	member, memberToken := seedAccountWithToken(t, app, memberEmail, "Reset Member")
+	addOrgMemberDirect(t, app, org.Slug, memberEmail)
+	addOrgMemberDirect(t, app, otherOrg.Slug, memberEmail)
+	enrollTOTPForTest(t, app, memberToken)
+
+	memberPath := "/api/v1/orgs/" + org.Slug + "/members/" + strconv.FormatInt(member.ID, 10) + "/mfa"
+	res := send(t, newAuthRequest(t, http.MethodDelete, memberPath, nil, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/instance/accounts/"+strconv.FormatInt(member.ID, 10)+"/mfa", nil, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	_, found, err := app.db.GetAccountMFA(context.Background(), member.ID)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/instance/accounts/"+strconv.FormatInt(member.ID, 10)+"/mfa", nil, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
@@ -0,0 +1,572 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine/classifier"
+	"github.com/sqlwarden/internal/files"
+	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/migrations"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const (
+	migrationMaxFiles      = 1000
+	migrationMaxFileBytes  = 1 << 20
+	migrationStatusTimeout = 30 * time.Second
+)
+
+type migrationSetRequest struct {
+	Name      string              `json:"name"`
+	FolderID  int64               `json:"folder_id"`
+	TableName string              `json:"table_name"`
+	V         validator.Validator `json:"-"`
+}
+
+type migrationApplyRequest struct {
+	TargetVersion      *int64              `json:"target_version"`
+	ConfirmEnvironment string              `json:"confirm_environment"`
+	V                  validator.Validator `json:"-"`
+}
+
+type migrationStatusResponse struct {
+	MigrationSet   database.MigrationSet `json:"migration_set"`
+	ConnectionID   int64                 `json:"connection_id"`
+	CurrentVersion int64                 `json:"current_version"`
+	PendingCount   int                   `json:"pending_count"`
+	Drifted        []int64               `json:"drifted_versions"`
+	Entries        []migrations.Entry    `json:"entries"`
+}
+
+type migrationJobInput struct {
+	MigrationSetID int64 `json:"migration_set_id"`
+	ConnectionID   int64 `json:"connection_id"`
+	AccountID      int64 `json:"account_id"`
+	TargetVersion  int64 `json:"target_version,omitempty"`
+}
+
+type migrationJobOutput struct {
+	MigrationSetID int64               `json:"migration_set_id"`
+	ConnectionID   int64               `json:"connection_id"`
+	Applied        []migrations.Record `json:"applied"`
+}
+
+// migrationSourceError is a problem with the files in a migration set that
+// the user has to fix in the workspace.
+type migrationSourceError struct {
+	message string
+}
+
+func (e migrationSourceError) Error() string {
+	return e.message
+}
+
+func (app *application) createMigrationSet(w http.ResponseWriter, r *http.Request) {
+	var input migrationSetRequest
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	input.TableName = strings.TrimSpace(input.TableName)
+	if input.TableName == "" {
+		input.TableName = migrations.DefaultTable
+	}
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(len(input.Name) <= 100, "name", "Name must not be more than 100 characters.")
+	input.V.CheckField(input.FolderID > 0, "folder_id", "Folder ID is required.")
+	input.V.CheckField(migrations.ValidTable(input.TableName), "table_name", "Table name must be a lowercase identifier of at most 63 characters.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	service, err := app.workspaceFileServiceForRequest(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	folder, err := service.Get(r.Context(), app.workspaceFileScope(r, database.FileVisibilityShared), input.FolderID)
+	switch {
+	case errors.Is(err, files.ErrNotFound):
+		app.failedValidation(w, r, fieldErrors(map[string]string{"folder_id": "Shared folder was not found."}))
+		return
+	case err != nil:
+		app.workspaceFileError(w, r, err)
+		return
+	}
+	if folder.ObjectType != database.FileObjectTypeFolder {
+		app.failedValidation(w, r, fieldErrors(map[string]string{"folder_id": "Migration sets must be a folder."}))
+		return
+	}
+
+	account := contextGetAccount(r)
+	org := contextGetOrg(r)
+	ws := contextGetWorkspace(r)
+	set, err := app.db.InsertMigrationSet(r.Context(), database.MigrationSet{
+		OrgID:       org.ID,
+		WorkspaceID: ws.ID,
+		FolderID:    folder.ID,
+		Name:        input.Name,
+		TableName:   input.TableName,
+		CreatedBy:   account.ID,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			if strings.Contains(err.Error(), "folder_id") {
+				app.failedDuplicateField(w, r, "folder_id", "This folder is already registered as a migration set.")
+			} else {
+				app.failedDuplicateField(w, r, "name", "A migration set with this name already exists in this workspace.")
+			}
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "migration set created", slog.Int64("migration_set_id", set.ID), slog.Int64("folder_id", set.FolderID))
+	app.recordAudit(r, workspaceAuditEvent(r, "migration_set.create", "migration_set", set.ID, map[string]any{"folder_id": set.FolderID, "table_name": set.TableName}))
+	if err := response.JSON(w, http.StatusCreated, set); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) listMigrationSets(w http.ResponseWriter, r *http.Request) {
+	q, errs := readListQuery(r.URL.Query(), nil)
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+	result, err := app.db.ListMigrationSets(r.Context(), contextGetWorkspace(r).ID, q.Page, q.PageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := response.JSON(w, http.StatusOK, result); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getMigrationSet(w http.ResponseWriter, r *http.Request) {
+	set, ok := app.migrationSetFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := response.JSON(w, http.StatusOK, set); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteMigrationSet unregisters a migration set. Tracking tables on targets
+// are left in place.
+func (app *application) deleteMigrationSet(w http.ResponseWriter, r *http.Request) {
+	set, ok := app.migrationSetFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, err := app.db.DeleteMigrationSet(r.Context(), set.ID, set.WorkspaceID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logInfo(r, "migration set deleted", slog.Int64("migration_set_id", set.ID))
+	app.recordAudit(r, workspaceAuditEvent(r, "migration_set.delete", "migration_set", set.ID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getConnectionMigrationStatus compares a migration set's files with the
+// tracking table on the connection's database.
+func (app *application) getConnectionMigrationStatus(w http.ResponseWriter, r *http.Request) {
+	set, ok := app.migrationSetFromRequest(w, r)
+	if !ok {
+		return
+	}
+	conn := contextGetConnection(r)
+	sources, ok := app.migrationSourcesForRequest(w, r, set)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), migrationStatusTimeout)
+	defer cancel()
+	driver, resources, err := app.openTargetConnection(ctx, conn)
+	if err != nil {
+		var coded jobs.CodedError
+		if errors.As(err, &coded) {
+			app.errorMessage(w, r, http.StatusUnprocessableEntity, coded.Message, nil)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+	defer resources.Close()
+	defer driver.Close()
+	tracker, err := migrations.NewTracker(driver, set.TableName)
+	if err != nil {
+		app.errorMessage(w, r, http.StatusUnprocessableEntity, "Migrations are not supported for this connection.", nil)
+		return
+	}
+	records, err := tracker.Records(ctx)
+	if err != nil {
+		app.errorMessage(w, r, http.StatusUnprocessableEntity, "Could not read the migrations table: "+err.Error(), nil)
+		return
+	}
+	entries, err := migrations.Plan(sources, records)
+	if err != nil {
+		app.failedValidation(w, r, fieldErrors(map[string]string{"migration_set": err.Error()}))
+		return
+	}
+
+	status := migrationStatusResponse{
+		MigrationSet: set,
+		ConnectionID: conn.ID,
+		Drifted:      migrations.Drifted(entries),
+		Entries:      entries,
+	}
+	if status.Drifted == nil {
+		status.Drifted = []int64{}
+	}
+	for _, entry := range entries {
+		switch entry.State {
+		case migrations.StateApplied, migrations.StateDrifted, migrations.StateMissing:
+			status.CurrentVersion = max(status.CurrentVersion, entry.Version)
+		case migrations.StatePending, migrations.StateFailed:
+			status.PendingCount++
+		}
+	}
+	if err := response.JSON(w, http.StatusOK, status); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// applyConnectionMigrations queues a job that applies a migration set to the
+// connection's database, up to target_version when given. The caller needs
+// env:deploy on the connection's environment. Migrations count as DDL for the
+// environment's guardrails, so production needs an open DDL window and
+// confirm_environment.
+func (app *application) applyConnectionMigrations(w http.ResponseWriter, r *http.Request) {
+	set, ok := app.migrationSetFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var input migrationApplyRequest
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	if input.TargetVersion != nil {
+		input.V.CheckField(*input.TargetVersion > 0, "target_version", "Target version must be greater than 0.")
+	}
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	account := contextGetAccount(r)
+	org := contextGetOrg(r)
+	ws := contextGetWorkspace(r)
+	conn := contextGetConnection(r)
+	if !app.enforcer.Can(r.Context(), account.ID, org.ID, ws.OwnerType, "environment", conn.EnvironmentID, access.PermEnvDeploy) {
+		app.logWarn(r, "migration apply permission denied", slog.Int64("connection_id", conn.ID), slog.Int64("environment_id", conn.EnvironmentID))
+		app.notPermitted(w, r)
+		return
+	}
+	if !app.enforceEnvironmentProtection(w, r, conn, "", classifier.KindDDL, input.ConfirmEnvironment) {
+		return
+	}
+	sources, ok := app.migrationSourcesForRequest(w, r, set)
+	if !ok {
+		return
+	}
+	var targetVersion int64
+	if input.TargetVersion != nil {
+		targetVersion = *input.TargetVersion
+		found := false
+		for _, source := range sources {
+			found = found || source.Version == targetVersion
+		}
+		if !found {
+			app.failedValidation(w, r, fieldErrors(map[string]string{"target_version": "No migration file has this version."}))
+			return
+		}
+	}
+
+	job, err := app.workspaceJobStore().Enqueue(r.Context(), jobs.EnqueueInput{
+		Type:           jobs.TypeApplyMigrations,
+		Visibility:     jobs.VisibilityUser,
+		OrgID:          &org.ID,
+		WorkspaceID:    &ws.ID,
+		OwnerAccountID: &account.ID,
+		Priority:       jobs.PriorityNormal,
+		MaxAttempts:    1,
+		Input: migrationJobInput{
+			MigrationSetID: set.ID,
+			ConnectionID:   conn.ID,
+			AccountID:      account.ID,
+			TargetVersion:  targetVersion,
+		},
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logInfo(r, "migration apply queued", slog.String("job.id", job.ID), slog.Int64("migration_set_id", set.ID), slog.Int64("connection_id", conn.ID), slog.Int64("target_version", targetVersion))
+	app.recordAudit(r, workspaceAuditEvent(r, "migration_set.apply", "migration_set", set.ID, map[string]any{
+		"connection_id":  conn.ID,
+		"target_version": targetVersion,
+		"job_id":         job.ID,
+	}))
+	if err := response.JSON(w, http.StatusCreated, job); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// handleMigrationJob applies a migration set to one connection. Permission
+// and the set's files are checked again as the account that queued it.
+func (app *application) handleMigrationJob(ctx context.Context, runtime jobs.Runtime) (any, error) {
+	var input migrationJobInput
+	if err := json.Unmarshal([]byte(runtime.Job.InputJSON), &input); err != nil {
+		return nil, jobs.Permanent("invalid_migration_input", "Migration job input is invalid.")
+	}
+	set, found, err := app.db.GetMigrationSet(ctx, input.MigrationSetID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, jobs.Permanent("migration_set_not_found", "Migration set was not found.")
+	}
+	conn, found, err := app.db.GetConnection(ctx, input.ConnectionID)
+	if err != nil {
+		return nil, err
+	}
+	if !found || conn.WorkspaceID != set.WorkspaceID {
+		return nil, jobs.Permanent("connection_not_found", "Connection was not found.")
+	}
+	ws, found, err := app.db.GetWorkspace(ctx, set.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, jobs.Permanent("workspace_not_found", "Workspace was not found.")
+	}
+	org, found, err := app.db.GetOrg(ctx, set.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, jobs.Permanent("org_not_found", "Organization was not found.")
+	}
+	account, found, err := app.db.GetAccount(ctx, input.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, jobs.Permanent("account_not_found", "Account was not found.")
+	}
+	if !app.enforcer.Can(ctx, account.ID, org.ID, ws.OwnerType, "environment", conn.EnvironmentID, access.PermEnvDeploy) {
+		return nil, jobs.Permanent("migration_not_permitted", "You no longer have permission to deploy to this environment.")
+	}
+	refusal, err := app.recheckEnvironmentProtection(ctx, conn, "", classifier.KindDDL)
+	if err != nil {
+		return nil, err
+	}
+	if refusal != nil {
+		return nil, jobs.Permanent(refusal.code, refusal.message)
+	}
+
+	settings, err := app.effectiveRuntimeSettingsForWorkspace(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	scope := files.Scope{AccountID: account.ID, OrgID: org.ID, OrgSlug: org.Slug, Workspace: ws, Visibility: database.FileVisibilityShared}
+	sources, err := app.loadMigrationSources(ctx, app.workspaceFileServiceWithSettings(settings), scope, set)
+	if err != nil {
+		var sourceErr migrationSourceError
+		if errors.As(err, &sourceErr) {
+			return nil, jobs.Permanent("migration_source_invalid", sourceErr.message)
+		}
+		if errors.Is(err, files.ErrForbidden) || errors.Is(err, files.ErrNotFound) {
+			return nil, jobs.Permanent("migration_source_unavailable", "The migration folder is no longer available.")
+		}
+		return nil, err
+	}
+	runtime.Events.Info(ctx, "migration_files_loaded", "Migration files loaded.", map[string]any{"migration_set_id": set.ID, "file_count": len(sources)})
+
+	driver, resources, err := app.openTargetConnection(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer resources.Close()
+	defer driver.Close()
+	runtime.Events.Info(ctx, "target_connected", "Connected to database.", nil)
+	tracker, err := migrations.NewTracker(driver, set.TableName)
+	if err != nil {
+		return nil, jobs.Permanent("migration_unsupported", "Migrations are not supported for this connection.")
+	}
+
+	result, applyErr := tracker.Apply(ctx, sources, migrations.ApplyOptions{
+		TargetVersion: input.TargetVersion,
+		AppliedBy:     account.Email,
+		OnApplied: func(record migrations.Record) {
+			runtime.Events.Info(ctx, "migration_applied", "Migration applied.", map[string]any{"version": record.Version, "name": record.Name, "duration_ms": record.DurationMS})
+		},
+	})
+	if len(result.Applied) > 0 {
+		if _, _, err := app.enqueueSchemaSync(context.WithoutCancel(ctx), conn.ID, &org.ID); err != nil && !errors.Is(err, jobs.ErrActiveExists) {
+			app.logger.WarnContext(ctx, "post-migration schema snapshot enqueue failed", "connection_id", conn.ID, "error", err)
+		}
+	}
+	app.recordMigrationAudit(context.WithoutCancel(ctx), set, conn, account.ID, result, applyErr)
+	if applyErr != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var failed *migrations.ApplyError
+		switch {
+		case errors.As(applyErr, &failed):
+			runtime.Events.Error(ctx, "migration_failed", "Migration failed.", map[string]any{"version": failed.Version, "name": failed.Name})
+			return nil, jobs.Permanent("migration_failed", "Migration "+strconv.FormatInt(failed.Version, 10)+" ("+failed.Name+") failed. See the migration status for the database error.")
+		case errors.Is(applyErr, migrations.ErrDrift):
+			return nil, jobs.Permanent("migration_drift", "Applied migrations no longer match their files. Resolve the drift before applying.")
+		case errors.Is(applyErr, migrations.ErrUnknownVersion):
+			return nil, jobs.Permanent("migration_unknown_version", "No migration file has the target version.")
+		default:
+			app.logger.WarnContext(ctx, "migration tracking failed", "migration_set_id", set.ID, "connection_id", conn.ID, "error", applyErr)
+			return nil, jobs.Permanent("migration_tracking_failed", "Could not update the migrations table on the target database.")
+		}
+	}
+	runtime.Events.Info(ctx, "migrations_completed", "Migrations completed.", map[string]any{"applied": len(result.Applied)})
+	return migrationJobOutput{MigrationSetID: set.ID, ConnectionID: conn.ID, Applied: result.Applied}, nil
+}
+
+func (app *application) recordMigrationAudit(ctx context.Context, set database.MigrationSet, conn database.Connection, accountID int64, result migrations.ApplyResult, applyErr error) {
+	versions := make([]int64, len(result.Applied))
+	for i, record := range result.Applied {
+		versions[i] = record.Version
+	}
+	details := map[string]any{"connection_id": conn.ID, "applied_versions": versions, "status": "succeeded"}
+	if applyErr != nil {
+		details["status"] = "failed"
+	}
+	if result.Failed != nil {
+		details["failed_version"] = result.Failed.Version
+	}
+	_, err := app.auditStore().Append(ctx, audit.Event{
+		OrgID:          &set.OrgID,
+		ActorAccountID: &accountID,
+		Action:         "migration_set.apply.finish",
+		ResourceType:   "migration_set",
+		ResourceID:     strconv.FormatInt(set.ID, 10),
+		Details:        details,
+	})
+	if err != nil {
+		app.logger.ErrorContext(ctx, "audit record append failed", "audit.action", "migration_set.apply.finish", "error", err)
+	}
+}
+
+func (app *application) migrationSetFromRequest(w http.ResponseWriter, r *http.Request) (database.MigrationSet, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "set_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return database.MigrationSet{}, false
+	}
+	set, found, err := app.db.GetMigrationSet(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return database.MigrationSet{}, false
+	}
+	if !found || set.WorkspaceID != contextGetWorkspace(r).ID {
+		app.notFound(w, r)
+		return database.MigrationSet{}, false
+	}
+	return set, true
+}
+
+func (app *application) migrationSourcesForRequest(w http.ResponseWriter, r *http.Request, set database.MigrationSet) ([]migrations.Source, bool) {
+	service, err := app.workspaceFileServiceForRequest(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	sources, err := app.loadMigrationSources(r.Context(), service, app.workspaceFileScope(r, database.FileVisibilityShared), set)
+	var sourceErr migrationSourceError
+	switch {
+	case errors.As(err, &sourceErr):
+		app.failedValidation(w, r, fieldErrors(map[string]string{"migration_set": sourceErr.message}))
+		return nil, false
+	case errors.Is(err, files.ErrNotFound):
+		app.failedValidation(w, r, fieldErrors(map[string]string{"migration_set": "The migration folder no longer exists."}))
+		return nil, false
+	case err != nil:
+		app.workspaceFileError(w, r, err)
+		return nil, false
+	}
+	return sources, true
+}
+
+// loadMigrationSources reads the numbered .sql files directly inside a
+// migration set's folder. Other files are ignored.
+func (app *application) loadMigrationSources(ctx context.Context, service *files.Service, scope files.Scope, set database.MigrationSet) ([]migrations.Source, error) {
+	children, err := service.List(ctx, scope, &set.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	var sources []migrations.Source
+	for _, child := range children {
+		if child.ObjectType != database.FileObjectTypeFile {
+			continue
+		}
+		version, ok := migrations.ParseVersion(child.Name)
+		if !ok {
+			continue
+		}
+		if len(sources) == migrationMaxFiles {
+			return nil, migrationSourceError{"A migration set can hold at most " + strconv.Itoa(migrationMaxFiles) + " migrations."}
+		}
+		content, err := service.ReadContent(ctx, scope, child.ID)
+		if errors.Is(err, files.ErrNotFound) {
+			return nil, migrationSourceError{child.Name + " has no content."}
+		}
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(io.LimitReader(content.Reader, migrationMaxFileBytes+1))
+		content.Reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > migrationMaxFileBytes {
+			return nil, migrationSourceError{child.Name + " is larger than the 1 MiB migration limit."}
+		}
+		sql := string(body)
+		sources = append(sources, migrations.Source{
+			Version:        version,
+			Name:           child.Name,
+			FileID:         child.ID,
+			ContentVersion: content.Content.Version,
+			Checksum:       migrations.Checksum(sql),
+			SQL:            sql,
+		})
+	}
+	if err := migrations.SortSources(sources); err != nil {
+		return nil, migrationSourceError{err.Error()}
+	}
+	if sources == nil {
+		sources = []migrations.Source{}
+	}
+	return sources, nil
+}
@@ -0,0 +1,251 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/migrations"
+)
+
+func migrationSetsURL(orgSlug string, workspaceID int64) string {
+	return fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/migration-sets", orgSlug, workspaceID)
+}
+
+func connectionMigrationURL(orgSlug string, workspaceID, connectionID, setID int64) string {
+	return fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/connections/%d/migration-sets/%d", orgSlug, workspaceID, connectionID, setID)
+}
+
+// seedMigrationFolder creates a shared folder holding one file per name and
+// content pair.
+func seedMigrationFolder(t *testing.T, app *application, orgSlug string, workspaceID int64, token string, nameAndContent ...string) (database.WorkspaceFile, []database.WorkspaceFile) {
+	t.Helper()
+
+	res := send(t, newAuthRequest(t, http.MethodPost, orgSharedFilesURL(orgSlug, workspaceID), map[string]any{"name": "migrations", "object_type": "folder"}, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	folder := decodeWorkspaceFile(t, res)
+	var created []database.WorkspaceFile
+	for i := 0; i+1 < len(nameAndContent); i += 2 {
+		res := send(t, newAuthRequest(t, http.MethodPost, orgSharedFilesURL(orgSlug, workspaceID), map[string]any{"name": nameAndContent[i], "parent_id": folder.ID}, token), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusCreated)
+		file := decodeWorkspaceFile(t, res)
+		saveWorkspaceFileContent(t, app, orgSlug, workspaceID, token, file.ID, nameAndContent[i+1])
+		created = append(created, file)
+	}
+	return folder, created
+}
+
+func saveWorkspaceFileContent(t *testing.T, app *application, orgSlug string, workspaceID int64, token string, fileID int64, content string) {
+	t.Helper()
+
+	contentURL := orgSharedFilesURL(orgSlug, workspaceID) + "/" + strconv.FormatInt(fileID, 10) + "/content"
+	etag := ""
+	if current := send(t, newAuthRequest(t, http.MethodGet, contentURL, nil, token), app.routes()); current.StatusCode == http.StatusOK {
+		etag = current.Header.Get("ETag")
+	}
+	res := send(t, newAuthContentRequest(t, http.MethodPut, contentURL, content, token, etag), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func runQueuedMigrationJob(t *testing.T, app *application) (any, error) {
+	t.Helper()
+
+	var record jobs.Record
+	err := app.db.NewSelect().Model(&record).
+		Where("type = ? AND status = ?", jobs.TypeApplyMigrations, jobs.StatusQueued).
+		Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.db.NewUpdate().Model((*jobs.Record)(nil)).
+		Set("status = ?", jobs.StatusSucceeded).
+		Where("id = ?", record.ID).
+		Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return app.handleMigrationJob(context.Background(), jobs.Runtime{Job: record, Events: recordingEventWriter{}})
+}
+
+func decodeMigrationStatus(t *testing.T, res testResponse) migrationStatusResponse {
+	t.Helper()
+
+	var status migrationStatusResponse
+	if err := json.Unmarshal(res.BodyBytes, &status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestMigrationSetAppliesUpToVersionAndFlagsDrift(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	conn := seedSQLiteFileConnection(t, app, ws.ID, envID, "app-db")
+	folder, created := seedMigrationFolder(t, app, org.Slug, ws.ID, tok,
+		"0001_widgets.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY);",
+		"0002_gadgets.sql", "CREATE TABLE gadgets (id INTEGER PRIMARY KEY);",
+		"README.md", "not a migration",
+	)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, migrationSetsURL(org.Slug, ws.ID), map[string]any{"name": "App schema", "folder_id": folder.ID}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	var set database.MigrationSet
+	if err := json.Unmarshal(res.BodyBytes, &set); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, set.TableName, migrations.DefaultTable)
+
+	statusURL := connectionMigrationURL(org.Slug, ws.ID, conn.ID, set.ID)
+	statusRes := send(t, newAuthRequest(t, http.MethodGet, statusURL, nil, tok), app.routes())
+	assert.Equal(t, statusRes.StatusCode, http.StatusOK)
+	status := decodeMigrationStatus(t, statusRes)
+	assert.Equal(t, len(status.Entries), 2)
+	assert.Equal(t, status.PendingCount, 2)
+	assert.Equal(t, status.CurrentVersion, int64(0))
+
+	applyRes := send(t, newAuthRequest(t, http.MethodPost, statusURL+"/apply", map[string]any{"target_version": 1}, tok), app.routes())
+	assert.Equal(t, applyRes.StatusCode, http.StatusCreated)
+	output, err := runQueuedMigrationJob(t, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(output.(migrationJobOutput).Applied), 1)
+
+	status = decodeMigrationStatus(t, send(t, newAuthRequest(t, http.MethodGet, statusURL, nil, tok), app.routes()))
+	assert.Equal(t, status.CurrentVersion, int64(1))
+	assert.Equal(t, status.PendingCount, 1)
+	assert.Equal(t, status.Entries[0].State, migrations.StateApplied)
+	assert.Equal(t, status.Entries[1].State, migrations.StatePending)
+
+	saveWorkspaceFileContent(t, app, org.Slug, ws.ID, tok, created[0].ID, "CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT);")
+	status = decodeMigrationStatus(t, send(t, newAuthRequest(t, http.MethodGet, statusURL, nil, tok), app.routes()))
+	assert.Equal(t, status.Entries[0].State, migrations.StateDrifted)
+	assert.Equal(t, len(status.Drifted), 1)
+
+	applyRes = send(t, newAuthRequest(t, http.MethodPost, statusURL+"/apply", map[string]any{}, tok), app.routes())
+	assert.Equal(t, applyRes.StatusCode, http.StatusCreated)
+	_, err = runQueuedMigrationJob(t, app)
+	var coded jobs.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("error = %v, want jobs.CodedError", err)
+	}
+	assert.Equal(t, coded.Code, "migration_drift")
+}
+
+func TestMigrationApplyRecordsFailureAndRequiresEnvDeploy(t *testing.T) {
+	t.Parallel()
+	app, org, ws, ownerTok := setupWorkspaceOwner(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	conn := seedSQLiteFileConnection(t, app, ws.ID, envID, "app-db")
+	folder, _ := seedMigrationFolder(t, app, org.Slug, ws.ID, ownerTok,
+		"0001_broken.sql", "INSERT INTO missing_table VALUES (1);",
+	)
+	res := send(t, newAuthRequest(t, http.MethodPost, migrationSetsURL(org.Slug, ws.ID), map[string]any{"name": "Broken", "folder_id": folder.ID, "table_name": "schema_history"}, ownerTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	setID := int64(res.BodyFields["id"].(float64))
+	statusURL := connectionMigrationURL(org.Slug, ws.ID, conn.ID, setID)
+
+	member, memberTok := seedAccountWithToken(t, app, uniqueEmail(t, "migration-member"), "Migration Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	roleID := createRoleForTest(t, app, org.ID, nil, "org", "ws:read", "wsfile:read", "env:read", "conn:read")
+	assert.Equal(t, grantOrgPolicyRole(t, app, ownerTok, org.Slug, roleID, "account", member.ID).StatusCode, http.StatusNoContent)
+
+	memberStatus := send(t, newAuthRequest(t, http.MethodGet, statusURL, nil, memberTok), app.routes())
+	assert.Equal(t, memberStatus.StatusCode, http.StatusOK)
+	memberApply := send(t, newAuthRequest(t, http.MethodPost, statusURL+"/apply", map[string]any{}, memberTok), app.routes())
+	assert.Equal(t, memberApply.StatusCode, http.StatusForbidden)
+
+	unknown := send(t, newAuthRequest(t, http.MethodPost, statusURL+"/apply", map[string]any{"target_version": 5}, ownerTok), app.routes())
+	assert.Equal(t, unknown.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, unknown, "target_version")
+
+	apply := send(t, newAuthRequest(t, http.MethodPost, statusURL+"/apply", map[string]any{}, ownerTok), app.routes())
+	assert.Equal(t, apply.StatusCode, http.StatusCreated)
+	_, err := runQueuedMigrationJob(t, app)
+	var coded jobs.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("error = %v, want jobs.CodedError", err)
+	}
+	assert.Equal(t, coded.Code, "migration_failed")
+
+	status := decodeMigrationStatus(t, send(t, newAuthRequest(t, http.MethodGet, statusURL, nil, ownerTok), app.routes()))
+	assert.Equal(t, status.Entries[0].State, migrations.StateFailed)
+	if status.Entries[0].ErrorMessage == "" {
+		t.Fatal("failed migration should keep the database error")
+	}
+}
+
+func TestMigrationApplyToProductionAppliesEnvironmentProtection(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	app.config.Drivers.SQLite.AllowedSources = []string{SQLiteDriverSourceLocal}
+	ctx := context.Background()
+	production := seedEnvironment(t, app, ws.ID, org.ID, "Prod")
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, production.ID, database.EnvironmentProtectionProduction, nil))
+	conn := seedSQLiteFileConnection(t, app, ws.ID, production.ID, "production-db")
+	folder, _ := seedMigrationFolder(t, app, org.Slug, ws.ID, tok,
+		"0001_widgets.sql", "CREATE TABLE widgets (id INTEGER PRIMARY KEY);",
+	)
+	res := send(t, newAuthRequest(t, http.MethodPost, migrationSetsURL(org.Slug, ws.ID), map[string]any{"name": "App schema", "folder_id": folder.ID}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	applyURL := connectionMigrationURL(org.Slug, ws.ID, conn.ID, int64(res.BodyFields["id"].(float64))) + "/apply"
+
+	res = send(t, newAuthRequest(t, http.MethodPost, applyURL, map[string]any{"confirm_environment": "Prod"}, tok), app.routes())
+	assertAPIError(t, res, apiErrorDDLWindowClosed, "")
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, production.ID, database.EnvironmentProtectionProduction, openDDLWindows()))
+	res = send(t, newAuthRequest(t, http.MethodPost, applyURL, map[string]any{}, tok), app.routes())
+	assertAPIError(t, res, apiErrorEnvironmentConfirmationRequired, "")
+	res = send(t, newAuthRequest(t, http.MethodPost, applyURL, map[string]any{"confirm_environment": "Prod"}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+
+	// The window is checked again when the job runs.
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(ctx, production.ID, database.EnvironmentProtectionProduction, nil))
+	_, err := runQueuedMigrationJob(t, app)
+	var coded jobs.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("error = %v, want jobs.CodedError", err)
+	}
+	assert.Equal(t, coded.Code, apiErrorDDLWindowClosed)
+}
+
+func TestCreateMigrationSetValidatesFolder(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	folder, created := seedMigrationFolder(t, app, org.Slug, ws.ID, tok, "0001_a.sql", "SELECT 1;")
+
+	first := send(t, newAuthRequest(t, http.MethodPost, migrationSetsURL(org.Slug, ws.ID), map[string]any{"name": "Schema", "folder_id": folder.ID}, tok), app.routes())
+	assert.Equal(t, first.StatusCode, http.StatusCreated)
+
+	tests := []struct {
+		name  string
+		body  map[string]any
+		field string
+	}{
+		{name: "file not folder", body: map[string]any{"name": "File", "folder_id": created[0].ID}, field: "folder_id"},
+		{name: "missing folder", body: map[string]any{"name": "Missing", "folder_id": 999999}, field: "folder_id"},
+		{name: "bad table", body: map[string]any{"name": "Bad", "folder_id": folder.ID, "table_name": "Schema-History"}, field: "table_name"},
+		{name: "folder registered", body: map[string]any{"name": "Again", "folder_id": folder.ID}, field: "folder_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, newAuthRequest(t, http.MethodPost, migrationSetsURL(org.Slug, ws.ID), tt.body, tok), app.routes())
+			assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+			assertValidationField(t, res, tt.field)
+		})
+	}
+
+	list := send(t, newAuthRequest(t, http.MethodGet, migrationSetsURL(org.Slug, ws.ID), nil, tok), app.routes())
+	assert.Equal(t, list.StatusCode, http.StatusOK)
+	assert.Equal(t, list.BodyFields["total"], any(float64(1)))
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/audit"
 	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/request"
 	"github.com/sqlwarden/internal/response"
@@ -67,6 +68,7 @@ func (app *application) updateOrg(w http.ResponseWriter, r *http.Request) {
 		Name                            *string             `json:"name"`
 		SchemaSnapshotsEnabled          *bool               `json:"schema_snapshots_enabled"`
 		MaskConnectionCredentialsOnEdit *bool               `json:"mask_connection_credentials_on_edit"`
+		MFARequired                     *bool               `json:"mfa_required"`
 		V                               validator.Validator `json:"-"`
 	}
 
@@ -82,8 +84,11 @@ func (app *application) updateOrg(w http.ResponseWriter, r *http.Request) {
 		input.V.CheckField(name != "", "name", "Name must not be empty.")
 	}
 	input.V.CheckField(
-		input.Name != nil || input.SchemaSnapshotsEnabled != nil || input.MaskConnectionCredentialsOnEdit != nil,
+		input.Name != nil || input.SchemaSnapshotsEnabled != nil || input.MaskConnectionCredentialsOnEdit != nil || input.MFARequired != nil,
 		"request", "At least one setting is required.")
+	if input.MFARequired != nil && *input.MFARequired && !org.MFARequired {
+		input.V.CheckField(sessionPresentedMFA(contextGetAuthSession(r)), "mfa_required", "Sign in with a second factor before requiring one.")
+	}
 
 	if input.V.HasErrors() {
 		app.failedValidation(w, r, input.V)
@@ -91,7 +96,7 @@ func (app *application) updateOrg(w http.ResponseWriter, r *http.Request) {
 	}
 
 	wasEnabled := org.SchemaSnapshotsEnabled
-	err = app.db.UpdateOrgSettings(r.Context(), org.ID, input.Name, input.SchemaSnapshotsEnabled, input.MaskConnectionCredentialsOnEdit)
+	err = app.db.UpdateOrgSettings(r.Context(), org.ID, input.Name, input.SchemaSnapshotsEnabled, input.MaskConnectionCredentialsOnEdit, input.MFARequired)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -103,6 +108,7 @@ func (app *application) updateOrg(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	app.logInfo(r, "organization updated", slog.Int64("org_id", org.ID), slog.String("org_slug", org.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.update", "org", org.ID, nil))
 
 	updated, found, err := app.db.GetOrg(r.Context(), org.ID)
 	if err != nil {
@@ -131,6 +137,7 @@ func (app *application) deleteOrg(w http.ResponseWriter, r *http.Request) {
 
 	app.enforcer.InvalidateOrgPolicy(org.ID)
 	app.logInfo(r, "organization deleted", slog.Int64("org_id", org.ID), slog.String("org_slug", org.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.delete", "org", org.ID, map[string]any{"slug": org.Slug}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -184,6 +191,7 @@ func (app *application) createOrg(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "organization created", slog.Int64("org_id", org.ID), slog.String("org_slug", org.Slug), slog.Int64("owner_account_id", account.ID))
+	app.recordAudit(r, audit.Event{OrgID: &org.ID, Action: "org.create", ResourceType: "org", ResourceID: strconv.FormatInt(org.ID, 10), Details: map[string]any{"slug": org.Slug}})
 	err = response.JSON(w, http.StatusCreated, org)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -304,6 +312,16 @@ func (app *application) removeOrgMember(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if _, isServiceAccount, err := app.db.GetServiceAccount(r.Context(), org.ID, accountID); err != nil {
+		app.serverError(w, r, err)
+		return
+	} else if isServiceAccount {
+		v := validator.Validator{}
+		v.AddError("Service accounts leave an organization by deleting the service account.")
+		app.failedValidation(w, r, v)
+		return
+	}
+
 	// Prevent removing the last owner.
 	if isLastOwner, checkErr := app.isLastOrgOwner(r, org.ID, accountID); checkErr != nil {
 		app.serverError(w, r, checkErr)
@@ -326,6 +344,7 @@ func (app *application) removeOrgMember(w http.ResponseWriter, r *http.Request)
 
 	app.enforcer.InvalidatePrincipals(org.ID, accountID)
 	app.logInfo(r, "organization member removed", slog.Int64("target_account_id", accountID), slog.Int64("org_id", org.ID), slog.String("org_slug", org.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.member.remove", "account", accountID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -413,6 +432,7 @@ func (app *application) updateOrgMemberRole(w http.ResponseWriter, r *http.Reque
 
 	app.enforcer.InvalidatePrincipals(org.ID, accountID)
 	app.logInfo(r, "organization member builtin role updated", slog.Int64("target_account_id", accountID), slog.Int64("role_id", roleID), slog.String("role", input.Role))
+	app.recordAudit(r, orgAuditEvent(r, "org.member.role.update", "account", accountID, map[string]any{"role": input.Role, "role_id": roleID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
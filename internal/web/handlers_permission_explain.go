@@ -0,0 +1,279 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+type permissionExplainSubject struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id"`
+}
+
+type permissionExplainLevel struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   int64  `json:"resource_id"`
+}
+
+type permissionExplainBinding struct {
+	BindingID     int64  `json:"binding_id,omitempty"`
+	RoleID        int64  `json:"role_id"`
+	RoleName      string `json:"role_name"`
+	RoleScopeType string `json:"role_scope_type"`
+	SubjectType   string `json:"subject_type"`
+	SubjectID     int64  `json:"subject_id"`
+	ResourceType  string `json:"resource_type"`
+	ResourceID    int64  `json:"resource_id"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+type permissionExplainWhatIf struct {
+	Allowed bool                     `json:"allowed"`
+	Binding permissionExplainBinding `json:"binding"`
+}
+
+type permissionExplainResponse struct {
+	AccountID    int64                      `json:"account_id"`
+	ResourceType string                     `json:"resource_type"`
+	ResourceID   int64                      `json:"resource_id"`
+	Permission   string                     `json:"permission"`
+	Allowed      bool                       `json:"allowed"`
+	Principals   []permissionExplainSubject `json:"principals"`
+	Ancestry     []permissionExplainLevel   `json:"ancestry"`
+	Grants       []permissionExplainBinding `json:"grants"`
+	Ignored      []permissionExplainBinding `json:"ignored"`
+	WhatIf       *permissionExplainWhatIf   `json:"what_if,omitempty"`
+}
+
+// explainPermission reports which role bindings grant an org member a
+// permission on a resource and why the member's other bindings on the
+// resource path do not. An optional what_if binding is evaluated without
+// being saved.
+func (app *application) explainPermission(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AccountID    int64  `json:"account_id"`
+		ResourceType string `json:"resource_type"`
+		ResourceID   int64  `json:"resource_id"`
+		Permission   string `json:"permission"`
+		WhatIf       *struct {
+			RoleID       int64  `json:"role_id"`
+			SubjectType  string `json:"subject_type"`
+			SubjectID    int64  `json:"subject_id"`
+			ResourceType string `json:"resource_type"`
+			ResourceID   int64  `json:"resource_id"`
+		} `json:"what_if"`
+		V validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	org := contextGetOrg(r)
+	if input.AccountID == 0 {
+		input.AccountID = contextGetAccount(r).ID
+	}
+	if input.ResourceType == "" {
+		input.ResourceType = "org"
+	}
+
+	input.V.CheckField(input.AccountID > 0, "account_id", "Account must be a positive integer.")
+	input.V.CheckField(access.ValidPermission(input.Permission), "permission", "Permission is not recognized.")
+	if input.WhatIf != nil {
+		input.V.CheckField(input.WhatIf.RoleID > 0, "what_if.role_id", "Role is required.")
+		input.V.CheckField(validWorkspacePolicySubjectType(input.WhatIf.SubjectType), "what_if.subject_type", "Subject type must be account, team, org_members, or workspace_members.")
+		input.V.CheckField(input.WhatIf.SubjectID > 0, "what_if.subject_id", "Subject is required.")
+		validTypes := map[string]bool{"org": true, "workspace": true, "environment": true, "connection": true}
+		input.V.CheckField(validTypes[input.WhatIf.ResourceType], "what_if.resource_type", "Resource type must be org, workspace, environment, or connection.")
+		if input.WhatIf.ResourceType != "org" {
+			input.V.CheckField(input.WhatIf.ResourceID > 0, "what_if.resource_id", "Resource is required for non-org resources.")
+		}
+	}
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	if ok, err := app.policySubjectExists(r, org.ID, access.SubjectTypeAccount, input.AccountID); err != nil {
+		app.serverError(w, r, err)
+		return
+	} else if !ok {
+		app.notFound(w, r)
+		return
+	}
+	resourceID, ok := app.resolveEffectivePermissionResource(w, r, input.ResourceType, explainResourceID(input.ResourceID))
+	if !ok {
+		return
+	}
+
+	var proposed *access.ProposedBinding
+	if input.WhatIf != nil {
+		proposed = &access.ProposedBinding{
+			RoleID:       input.WhatIf.RoleID,
+			SubjectType:  input.WhatIf.SubjectType,
+			SubjectID:    input.WhatIf.SubjectID,
+			ResourceType: input.WhatIf.ResourceType,
+		}
+		proposed.ResourceID, ok = app.resolveEffectivePermissionResource(w, r, proposed.ResourceType, explainResourceID(input.WhatIf.ResourceID))
+		if !ok {
+			return
+		}
+		if !app.validateProposedBinding(w, r, proposed) {
+			return
+		}
+	}
+
+	explanation, err := app.enforcer.Explain(r.Context(), input.AccountID, org.ID, input.ResourceType, resourceID, input.Permission, proposed)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logDebug(r, "permission explained",
+		slog.Int64("target_account_id", input.AccountID),
+		slog.String("resource_type", input.ResourceType),
+		slog.Int64("resource_id", resourceID),
+		slog.String("permission", input.Permission),
+		slog.Bool("allowed", explanation.Allowed),
+		slog.Bool("what_if", proposed != nil),
+	)
+	err = response.JSON(w, http.StatusOK, newPermissionExplainResponse(input.AccountID, input.ResourceType, resourceID, input.Permission, explanation))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// validateProposedBinding applies the checks a real grant of proposed would
+// pass: the subject and role exist in the org, and the role's scope matches
+// the resource.
+func (app *application) validateProposedBinding(w http.ResponseWriter, r *http.Request, proposed *access.ProposedBinding) bool {
+	org := contextGetOrg(r)
+
+	subjectWorkspaceID := int64(0)
+	if proposed.SubjectType == access.SubjectTypeWorkspaceMembers {
+		subjectWorkspaceID = proposed.SubjectID
+		ws, found, err := app.db.GetWorkspace(r.Context(), subjectWorkspaceID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return false
+		}
+		if !found || ws.OrgID == nil || *ws.OrgID != org.ID {
+			app.notFound(w, r)
+			return false
+		}
+	}
+	if ok, err := app.workspacePolicySubjectExists(r, org.ID, subjectWorkspaceID, proposed.SubjectType, proposed.SubjectID); err != nil {
+		app.serverError(w, r, err)
+		return false
+	} else if !ok {
+		app.notFound(w, r)
+		return false
+	}
+
+	role, found, err := app.db.GetRole(r.Context(), proposed.RoleID, org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if !found {
+		app.notFound(w, r)
+		return false
+	}
+	if role.ScopeType != proposed.ResourceType || (proposed.ResourceType == "org" && role.WorkspaceID != nil) {
+		v := validator.Validator{}
+		v.AddFieldError("what_if.role_id", "Role scope must match resource type.")
+		app.failedValidation(w, r, v)
+		return false
+	}
+	if role.WorkspaceID != nil {
+		workspaceID, found, err := app.policyResourceWorkspaceID(r, proposed.ResourceType, proposed.ResourceID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return false
+		}
+		if !found || workspaceID != *role.WorkspaceID {
+			app.notFound(w, r)
+			return false
+		}
+	}
+	return true
+}
+
+// policyResourceWorkspaceID returns the workspace a workspace, environment,
+// or connection belongs to.
+func (app *application) policyResourceWorkspaceID(r *http.Request, resourceType string, resourceID int64) (int64, bool, error) {
+	switch resourceType {
+	case "workspace":
+		return resourceID, true, nil
+	case "environment":
+		env, found, err := app.db.GetEnvironment(r.Context(), resourceID)
+		return env.WorkspaceID, found, err
+	case "connection":
+		conn, found, err := app.db.GetConnection(r.Context(), resourceID)
+		return conn.WorkspaceID, found, err
+	default:
+		return 0, false, nil
+	}
+}
+
+func explainResourceID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+func newPermissionExplainResponse(accountID int64, resourceType string, resourceID int64, permission string, explanation access.Explanation) permissionExplainResponse {
+	resp := permissionExplainResponse{
+		AccountID:    accountID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Permission:   permission,
+		Allowed:      explanation.Allowed,
+		Principals:   make([]permissionExplainSubject, 0, len(explanation.Principals)),
+		Ancestry:     make([]permissionExplainLevel, 0, len(explanation.Ancestry)),
+		Grants:       make([]permissionExplainBinding, 0, len(explanation.Grants)),
+		Ignored:      make([]permissionExplainBinding, 0, len(explanation.Ignored)),
+	}
+	for _, subject := range explanation.Principals {
+		resp.Principals = append(resp.Principals, permissionExplainSubject{Type: subject.Type, ID: subject.ID})
+	}
+	for _, level := range explanation.Ancestry {
+		resp.Ancestry = append(resp.Ancestry, permissionExplainLevel{ResourceType: level.ResourceType, ResourceID: level.ResourceID})
+	}
+	for _, binding := range explanation.Grants {
+		resp.Grants = append(resp.Grants, newPermissionExplainBinding(binding))
+	}
+	for _, binding := range explanation.Ignored {
+		resp.Ignored = append(resp.Ignored, newPermissionExplainBinding(binding))
+	}
+	if explanation.WhatIf != nil {
+		resp.WhatIf = &permissionExplainWhatIf{
+			Allowed: explanation.WhatIf.Allowed,
+			Binding: newPermissionExplainBinding(explanation.WhatIf.Binding),
+		}
+	}
+	return resp
+}
+
+func newPermissionExplainBinding(binding access.BindingExplanation) permissionExplainBinding {
+	return permissionExplainBinding{
+		BindingID:     binding.BindingID,
+		RoleID:        binding.RoleID,
+		RoleName:      binding.RoleName,
+		RoleScopeType: binding.RoleScopeType,
+		SubjectType:   binding.SubjectType,
+		SubjectID:     binding.SubjectID,
+		ResourceType:  binding.ResourceType,
+		ResourceID:    binding.ResourceID,
+		Reason:        binding.Reason,
+	}
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func explainPermissionRequest(t *testing.T, app *application, token, orgSlug string, body map[string]any) testResponse {
+	t.Helper()
+	return send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+orgSlug+"/permissions/explain", body, token), app.routes())
+}
+
+func explainBindings(t *testing.T, res testResponse, key string) []map[string]any {
+	t.Helper()
+	raw, ok := res.BodyFields[key].([]any)
+	if !ok {
+		t.Fatalf("%s response has unexpected shape: %#v", key, res.BodyFields[key])
+	}
+	bindings := make([]map[string]any, 0, len(raw))
+	for _, item := range raw {
+		bindings = append(bindings, item.(map[string]any))
+	}
+	return bindings
+}
+
+func TestExplainPermissionListsGrantsAndIgnoredBindings(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "explain-owner"), "Owner", "Explain")
+	member := seedAccount(t, app, uniqueEmail(t, "explain-member"), "Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	other := seedAccount(t, app, uniqueEmail(t, "explain-other"), "Other")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Workspace", "")
+	wsID := strconv.FormatInt(ws.ID, 10)
+	envID := seedEnvironment(t, app, ws.ID, org.ID, "staging").ID
+	connID := seedConnection(t, app, ws.ID, &envID, org.ID, "postgres", "primary", "open").ID
+	orgRoleID := createRoleForTest(t, app, org.ID, nil, "org", "conn:update")
+	envRoleID := createRoleForTest(t, app, org.ID, nil, "environment", "env:read")
+
+	assert.Equal(t, grantOrgPolicyRole(t, app, ownerTok, org.Slug, orgRoleID, "account", member.ID).StatusCode, http.StatusNoContent)
+	assert.Equal(t, grantWorkspacePolicyRole(t, app, ownerTok, org.Slug, wsID, envRoleID, "account", member.ID, "environment", envID).StatusCode, http.StatusNoContent)
+	assert.Equal(t, grantWorkspacePolicyRole(t, app, ownerTok, org.Slug, wsID, envRoleID, "account", other.ID, "environment", envID).StatusCode, http.StatusNoContent)
+
+	res := explainPermissionRequest(t, app, ownerTok, org.Slug, map[string]any{
+		"account_id":    member.ID,
+		"resource_type": "connection",
+		"resource_id":   connID,
+		"permission":    "conn:update",
+	})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["allowed"], true)
+
+	grants := explainBindings(t, res, "grants")
+	assert.Equal(t, len(grants), 1)
+	assert.Equal(t, int64(grants[0]["role_id"].(float64)), orgRoleID)
+	assert.Equal(t, grants[0]["subject_type"], "account")
+	assert.Equal(t, grants[0]["resource_type"], "org")
+
+	ignored := explainBindings(t, res, "ignored")
+	type subjectRole struct{ subjectID, roleID int64 }
+	reasons := map[subjectRole]string{}
+	for _, binding := range ignored {
+		reasons[subjectRole{int64(binding["subject_id"].(float64)), int64(binding["role_id"].(float64))}] = binding["reason"].(string)
+	}
+	assert.Equal(t, reasons[subjectRole{member.ID, envRoleID}], "role_lacks_permission")
+	// Bindings for other principals are listed rather than dropped.
+	assert.Equal(t, reasons[subjectRole{other.ID, envRoleID}], "principal_not_matched")
+
+	// env:read is granted to the environment but does not apply to connections.
+	res = explainPermissionRequest(t, app, ownerTok, org.Slug, map[string]any{
+		"account_id":    member.ID,
+		"resource_type": "connection",
+		"resource_id":   connID,
+		"permission":    "env:read",
+	})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["allowed"], false)
+	for _, binding := range explainBindings(t, res, "ignored") {
+		if int64(binding["role_id"].(float64)) == envRoleID && int64(binding["subject_id"].(float64)) == member.ID {
+			assert.Equal(t, binding["reason"], "resource_not_applicable")
+		}
+	}
+}
+
+func TestExplainPermissionWhatIfDoesNotSaveBinding(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "explain-whatif-owner"), "Owner", "Explain What If")
+	member, memberTok := seedAccountWithToken(t, app, uniqueEmail(t, "explain-whatif-member"), "Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Workspace", "")
+	envID := seedEnvironment(t, app, ws.ID, org.ID, "staging").ID
+	connID := seedConnection(t, app, ws.ID, &envID, org.ID, "postgres", "primary", "open").ID
+	connRoleID := createRoleForTest(t, app, org.ID, nil, "connection", "conn:execute")
+
+	whatIf := map[string]any{
+		"role_id":       connRoleID,
+		"subject_type":  "account",
+		"subject_id":    member.ID,
+		"resource_type": "connection",
+		"resource_id":   connID,
+	}
+	res := explainPermissionRequest(t, app, ownerTok, org.Slug, map[string]any{
+		"account_id":    member.ID,
+		"resource_type": "connection",
+		"resource_id":   connID,
+		"permission":    "conn:execute",
+		"what_if":       whatIf,
+	})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["allowed"], false)
+	result := res.BodyFields["what_if"].(map[string]any)
+	assert.Equal(t, result["allowed"], true)
+	assert.Equal(t, int64(result["binding"].(map[string]any)["role_id"].(float64)), connRoleID)
+
+	assertMissingPermissions(t, effectivePermissions(t, app, memberTok, org.Slug, "connection", connID), "conn:execute")
+
+	whatIf["subject_id"] = owner.ID
+	res = explainPermissionRequest(t, app, ownerTok, org.Slug, map[string]any{
+		"account_id":    member.ID,
+		"resource_type": "connection",
+		"resource_id":   connID,
+		"permission":    "conn:execute",
+		"what_if":       whatIf,
+	})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	result = res.BodyFields["what_if"].(map[string]any)
+	assert.Equal(t, result["allowed"], false)
+	assert.Equal(t, result["binding"].(map[string]any)["reason"], "principal_not_matched")
+
+	whatIf["subject_id"] = member.ID
+	whatIf["resource_type"] = "environment"
+	whatIf["resource_id"] = envID
+	res = explainPermissionRequest(t, app, ownerTok, org.Slug, map[string]any{
+		"account_id":    member.ID,
+		"resource_type": "connection",
+		"resource_id":   connID,
+		"permission":    "conn:execute",
+		"what_if":       whatIf,
+	})
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+}
+
+func TestExplainPermissionRequiresPolicyRead(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, _, org := seedOrgOwner(t, app, uniqueEmail(t, "explain-forbidden-owner"), "Owner", "Explain Forbidden")
+	member, memberTok := seedAccountWithToken(t, app, uniqueEmail(t, "explain-forbidden-member"), "Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	res := explainPermissionRequest(t, app, memberTok, org.Slug, map[string]any{"permission": "org:read"})
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+}
@@ -136,6 +136,7 @@ func (app *application) createRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "organization role created", slog.Int64("role_id", role.ID), slog.String("scope_type", role.ScopeType), slog.Int("permission_count", len(input.Permissions)))
+	app.recordAudit(r, orgAuditEvent(r, "org.role.create", "role", role.ID, map[string]any{"name": role.Name, "permissions": input.Permissions}))
 	err = response.JSON(w, http.StatusCreated, role)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -229,6 +230,7 @@ func (app *application) updateRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "organization role updated", slog.Int64("role_id", role.ID), slog.Int("permission_count", len(input.Permissions)))
+	app.recordAudit(r, orgAuditEvent(r, "org.role.update", "role", role.ID, map[string]any{"permissions": input.Permissions}))
 	err = response.JSON(w, http.StatusOK, role)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -263,6 +265,7 @@ func (app *application) deleteRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "organization role deleted", slog.Int64("role_id", roleID))
+	app.recordAudit(r, orgAuditEvent(r, "org.role.delete", "role", roleID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -384,6 +387,7 @@ func (app *application) grantOrgPolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "organization policy granted", slog.Int64("role_id", input.RoleID), slog.String("subject_type", input.SubjectType), slog.Int64("subject_id", input.SubjectID), slog.String("resource_type", "org"), slog.Int64("resource_id", org.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.policy.grant", "role", input.RoleID, map[string]any{"subject_type": input.SubjectType, "subject_id": input.SubjectID, "resource_type": "org", "resource_id": org.ID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -437,6 +441,7 @@ func (app *application) revokeOrgPolicy(w http.ResponseWriter, r *http.Request)
 	}
 
 	app.logInfo(r, "organization policy revoked", slog.Int64("binding_id", bindingID), slog.Int64("role_id", rb.RoleID), slog.String("subject_type", rb.SubjectType), slog.Int64("subject_id", rb.SubjectID), slog.String("resource_type", rb.ResourceType), slog.Int64("resource_id", rb.ResourceID))
+	app.recordAudit(r, orgAuditEvent(r, "org.policy.revoke", "role_binding", bindingID, map[string]any{"role_id": rb.RoleID, "subject_type": rb.SubjectType, "subject_id": rb.SubjectID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+// exportOrgPolicy writes the organization's policy document as YAML, or as
+// JSON with format=json.
+func (app *application) exportOrgPolicy(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "json" {
+		v := validator.Validator{}
+		v.AddFieldError("format", "Format must be yaml or json.")
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	org := contextGetOrg(r)
+	doc, err := app.orgPolicyDocument(r.Context(), org.ID)
+	if err != nil {
+		if errors.Is(err, errPolicyAmbiguousName) {
+			app.errorMessage(w, r, http.StatusConflict, "The policy cannot be exported: "+err.Error()+".", nil)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logDebug(r, "organization policy exported", slog.String("format", format), slog.Int("role_count", len(doc.Roles)), slog.Int("binding_count", len(doc.Bindings)))
+	if format == "json" {
+		err = response.JSON(w, http.StatusOK, doc)
+	} else {
+		err = response.YAML(w, http.StatusOK, doc)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// planOrgPolicyImport reports the changes importing the posted policy
+// document would make, without making them.
+func (app *application) planOrgPolicyImport(w http.ResponseWriter, r *http.Request) {
+	doc, ok := app.decodePolicyDocument(w, r)
+	if !ok {
+		return
+	}
+
+	org := contextGetOrg(r)
+	state, err := app.db.LoadOrgPolicyState(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	plan, err := planOrgPolicy(org.ID, state, doc)
+	if err != nil {
+		app.policyImportError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, plan)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// applyOrgPolicyImport makes the organization's policy match the posted
+// document in one transaction and returns the changes made.
+func (app *application) applyOrgPolicyImport(w http.ResponseWriter, r *http.Request) {
+	doc, ok := app.decodePolicyDocument(w, r)
+	if !ok {
+		return
+	}
+
+	org := contextGetOrg(r)
+	actor := app.newPolicyImportActor(r.Context(), org.ID, contextGetAccount(r).ID)
+	plan, err := app.applyOrgPolicy(r.Context(), org.ID, actor, doc)
+	if err != nil {
+		app.policyImportError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "organization policy imported", slog.Int("change_count", len(plan.Changes)))
+	if len(plan.Changes) > 0 {
+		app.recordAudit(r, orgAuditEvent(r, "org.policy.import", "org", org.ID, plan))
+	}
+	err = response.JSON(w, http.StatusOK, plan)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// decodePolicyDocument reads a policy document sent as YAML or JSON,
+// according to the request's content type.
+func (app *application) decodePolicyDocument(w http.ResponseWriter, r *http.Request) (PolicyDocument, bool) {
+	var doc PolicyDocument
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		err = request.DecodeYAML(w, r, &doc)
+	default:
+		err = request.DecodeJSONStrict(w, r, &doc)
+	}
+	if err != nil {
+		app.badRequest(w, r, err)
+		return PolicyDocument{}, false
+	}
+	return doc, true
+}
+
+func (app *application) policyImportError(w http.ResponseWriter, r *http.Request, err error) {
+	var docErr PolicyDocumentError
+	switch {
+	case errors.As(err, &docErr):
+		app.failedValidation(w, r, docErr.Validator)
+	case errors.Is(err, errPolicyProtectedChange):
+		app.logWarn(r, "protected organization policy import blocked")
+		app.protectedOrgPolicyNotPermitted(w, r)
+	case errors.Is(err, errPolicyTeamChange):
+		app.logWarn(r, "organization policy import team change blocked")
+		app.errorMessage(w, r, http.StatusForbidden, "Changing teams requires the org:write permission.", nil)
+	default:
+		app.serverError(w, r, err)
+	}
+}
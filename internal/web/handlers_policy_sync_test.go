@@ -0,0 +1,250 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/assert"
+)
+
+func policyDocumentBody(t *testing.T, doc PolicyDocument) map[string]any {
+	t.Helper()
+	js, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(js, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func exportPolicyDocument(t *testing.T, app *application, token, orgSlug string) PolicyDocument {
+	t.Helper()
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+orgSlug+"/policies/export?format=json", nil, token), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	var doc PolicyDocument
+	if err := json.Unmarshal(res.BodyBytes, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func policyImportRequest(t *testing.T, app *application, token, orgSlug, action string, doc PolicyDocument) testResponse {
+	t.Helper()
+	return send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+orgSlug+"/policies/"+action, policyDocumentBody(t, doc), token), app.routes())
+}
+
+func policyChanges(t *testing.T, res testResponse) []string {
+	t.Helper()
+	var plan PolicyPlan
+	if err := json.Unmarshal(res.BodyBytes, &plan); err != nil {
+		t.Fatal(err)
+	}
+	changes := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		changes = append(changes, change.Action+" "+change.Kind+" "+change.Target)
+	}
+	return changes
+}
+
+func TestPolicyExportRoundTripPlansNoChanges(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "policy-sync-owner"), "Owner", "Policy Sync")
+	member := seedAccount(t, app, uniqueEmail(t, "policy-sync-member"), "Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Analytics", "")
+	envID := seedEnvironment(t, app, ws.ID, org.ID, "staging").ID
+	team, err := app.db.InsertTeam(context.Background(), org.ID, "data", "Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.db.AddTeamMember(context.Background(), team.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	envRoleID := createRoleForTest(t, app, org.ID, &ws.ID, "environment", "env:read")
+	if err := app.enforcer.BindRole(context.Background(), org.ID, envRoleID, access.SubjectTypeTeam, team.ID, "environment", envID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := exportPolicyDocument(t, app, ownerTok, org.Slug)
+	assert.Equal(t, len(doc.Roles), 1)
+	assert.Equal(t, doc.Roles[0].Workspace, "Analytics")
+	assert.Equal(t, len(doc.Teams), 1)
+	assert.Equal(t, doc.Teams[0].Members[0], strings.ToLower(member.Email))
+	found := false
+	for _, binding := range doc.Bindings {
+		if binding.Subject.Type == access.SubjectTypeTeam {
+			assert.Equal(t, binding.Subject.Name, "data")
+			assert.Equal(t, binding.Resource, PolicyResource{Type: "environment", Workspace: "Analytics", Environment: "staging"})
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	res := policyImportRequest(t, app, ownerTok, org.Slug, "plan", doc)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, len(policyChanges(t, res)), 0)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/policies/export", nil, ownerTok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), "application/yaml")
+	req := newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+org.Slug+"/policies/plan", nil, ownerTok)
+	req.Body = io.NopCloser(bytes.NewReader(res.BodyBytes))
+	req.ContentLength = int64(len(res.BodyBytes))
+	req.Header.Set("Content-Type", "application/yaml")
+	res = send(t, req, app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, len(policyChanges(t, res)), 0)
+}
+
+func TestPolicyApplyCreatesAndDeletes(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "policy-apply-owner"), "Owner", "Policy Apply")
+	member, memberTok := seedAccountWithToken(t, app, uniqueEmail(t, "policy-apply-member"), "Member")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Warehouse", "")
+	envID := seedEnvironment(t, app, ws.ID, org.ID, "prod").ID
+	connID := seedConnection(t, app, ws.ID, &envID, org.ID, "postgres", "primary", "open").ID
+
+	base := exportPolicyDocument(t, app, ownerTok, org.Slug)
+	doc := base
+	doc.Workspaces = slices.Clone(base.Workspaces)
+	doc.Roles = append(doc.Roles, PolicyRole{Name: "Query Runner", Scope: "connection", Permissions: []string{"conn:execute"}})
+	doc.Teams = append(doc.Teams, PolicyTeam{Slug: "analysts", Name: "Analysts", Members: []string{member.Email}})
+	for i := range doc.Workspaces {
+		if doc.Workspaces[i].Name == "Warehouse" {
+			doc.Workspaces[i].Teams = []string{"analysts"}
+		}
+	}
+	doc.Bindings = append(doc.Bindings, PolicyBinding{
+		Role:     PolicyRoleRef{Name: "Query Runner"},
+		Subject:  PolicySubject{Type: access.SubjectTypeTeam, Name: "analysts"},
+		Resource: PolicyResource{Type: "connection", Workspace: "Warehouse", Connection: "primary"},
+	})
+
+	res := policyImportRequest(t, app, ownerTok, org.Slug, "plan", doc)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, strings.Join(policyChanges(t, res), "\n"), strings.Join([]string{
+		"create role Query Runner",
+		"create team analysts",
+		"create team_member analysts: " + member.Email,
+		"create workspace_team Warehouse: analysts",
+		"create binding Query Runner to team analysts on connection Warehouse/primary",
+	}, "\n"))
+	assertMissingPermissions(t, effectivePermissions(t, app, memberTok, org.Slug, "connection", connID), "conn:execute")
+
+	res = policyImportRequest(t, app, ownerTok, org.Slug, "apply", doc)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, len(policyChanges(t, res)), 5)
+	permissions := effectivePermissions(t, app, memberTok, org.Slug, "connection", connID)
+	assert.True(t, strings.Contains(strings.Join(permissions, ","), "conn:execute"))
+
+	res = policyImportRequest(t, app, ownerTok, org.Slug, "apply", base)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, strings.Join(policyChanges(t, res), "\n"), strings.Join([]string{
+		"delete binding Query Runner to team analysts on connection Warehouse/primary",
+		"delete role Query Runner",
+		"delete team analysts",
+	}, "\n"))
+	assertMissingPermissions(t, effectivePermissions(t, app, memberTok, org.Slug, "connection", connID), "conn:execute")
+	_, found, err := app.db.GetTeam(context.Background(), org.ID, "analysts")
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestPolicyImportRejectsInvalidDocuments(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "policy-invalid-owner"), "Owner", "Policy Invalid")
+	base := exportPolicyDocument(t, app, ownerTok, org.Slug)
+
+	doc := base
+	doc.Roles = append(doc.Roles, PolicyRole{Name: access.BuiltinOrgAdminRole, Scope: "org", Permissions: []string{"org:read"}})
+	res := policyImportRequest(t, app, ownerTok, org.Slug, "apply", doc)
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+
+	doc = base
+	doc.Bindings = nil
+	for _, binding := range base.Bindings {
+		if binding.Role.Name != access.BuiltinOrgOwnerRole {
+			doc.Bindings = append(doc.Bindings, binding)
+		}
+	}
+	res = policyImportRequest(t, app, ownerTok, org.Slug, "apply", doc)
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+
+	doc = base
+	doc.Bindings = append(slices.Clone(base.Bindings), PolicyBinding{
+		Role:     PolicyRoleRef{Name: access.BuiltinOrgAdminRole},
+		Subject:  PolicySubject{Type: access.SubjectTypeAccount, Name: "nobody@example.com"},
+		Resource: PolicyResource{Type: "org"},
+	})
+	res = policyImportRequest(t, app, ownerTok, org.Slug, "plan", doc)
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+}
+
+func TestPolicyImportAppliesPrivilegedGrantGuard(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerTok, org := seedOrgOwner(t, app, uniqueEmail(t, "policy-guard-owner"), "Owner", "Policy Guard")
+	admin, adminTok := seedAccountWithToken(t, app, uniqueEmail(t, "policy-guard-admin"), "Admin")
+	if err := app.db.AddOrgMember(context.Background(), org.ID, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	state, err := app.db.LoadOrgPolicyState(context.Background(), org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range state.Roles {
+		if role.Name == access.BuiltinOrgAdminRole {
+			assert.Equal(t, grantOrgPolicyRole(t, app, ownerTok, org.Slug, role.ID, "account", admin.ID).StatusCode, http.StatusNoContent)
+		}
+	}
+
+	doc := exportPolicyDocument(t, app, adminTok, org.Slug)
+	doc.Roles = append(doc.Roles, PolicyRole{Name: "Deleter", Scope: "org", Permissions: []string{access.PermOrgDelete}})
+	doc.Bindings = append(doc.Bindings, PolicyBinding{
+		Role:     PolicyRoleRef{Name: "Deleter"},
+		Subject:  PolicySubject{Type: access.SubjectTypeAccount, Name: admin.Email},
+		Resource: PolicyResource{Type: "org"},
+	})
+
+	res := policyImportRequest(t, app, adminTok, org.Slug, "plan", doc)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	var plan PolicyPlan
+	if err := json.Unmarshal(res.BodyBytes, &plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range plan.Changes {
+		assert.True(t, change.Protected)
+	}
+
+	res = policyImportRequest(t, app, adminTok, org.Slug, "apply", doc)
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+	state, err = app.db.LoadOrgPolicyState(context.Background(), org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range state.Roles {
+		assert.NotEqual(t, role.Name, "Deleter")
+	}
+
+	res = policyImportRequest(t, app, ownerTok, org.Slug, "apply", doc)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, len(policyChanges(t, res)), 2)
+}
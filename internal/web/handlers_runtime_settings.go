@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/request"
@@ -86,6 +87,7 @@ func organizationRuntimeSettingsResponse(overrides database.OrganizationRuntimeS
 		"overrides": map[string]any{
 			"query_max_result_rows":             overrides.QueryMaxResultRows,
 			"query_max_result_bytes":            overrides.QueryMaxResultBytes,
+			"query_max_execution_seconds":       overrides.QueryMaxExecutionSeconds,
 			"exports_sync_max_bytes":            overrides.ExportsSyncMaxBytes,
 			"exports_background_max_bytes":      overrides.ExportsBackgroundMaxBytes,
 			"schema_snapshot_freshness_seconds": overrides.SchemaSnapshotFreshnessSeconds,
@@ -98,6 +100,7 @@ func organizationRuntimeSettingsResponse(overrides database.OrganizationRuntimeS
 		"effective": map[string]any{
 			"query_max_result_rows":             effective.QueryMaxResultRows,
 			"query_max_result_bytes":            effective.QueryMaxResultBytes,
+			"query_max_execution_seconds":       int64(effective.QueryMaxExecutionTime.Seconds()),
 			"exports_sync_max_bytes":            effective.ExportsSyncMaxBytes,
 			"exports_background_max_bytes":      effective.ExportsBackgroundMaxBytes,
 			"schema_snapshot_freshness_seconds": int64(effective.SchemaSnapshotFreshness.Seconds()),
@@ -110,6 +113,7 @@ func organizationRuntimeSettingsResponse(overrides database.OrganizationRuntimeS
 		"constraints": map[string]any{
 			"query_max_result_rows_max":             instance.QueryMaxResultRows,
 			"query_max_result_bytes_max":            instance.QueryMaxResultBytes,
+			"query_max_execution_seconds_max":       instance.QueryMaxExecutionSeconds,
 			"exports_sync_max_bytes_max":            instance.ExportsSyncMaxBytes,
 			"exports_background_max_bytes_max":      instance.ExportsBackgroundMaxBytes,
 			"schema_snapshot_freshness_seconds_min": instance.SchemaSnapshotFreshnessSeconds,
@@ -125,6 +129,7 @@ func (app *application) updateOrganizationRuntimeSettings(w http.ResponseWriter,
 	var input struct {
 		QueryMaxResultRows             nullablePatch[int]    `json:"query_max_result_rows"`
 		QueryMaxResultBytes            nullablePatch[int64]  `json:"query_max_result_bytes"`
+		QueryMaxExecutionSeconds       nullablePatch[int64]  `json:"query_max_execution_seconds"`
 		ExportsSyncMaxBytes            nullablePatch[int64]  `json:"exports_sync_max_bytes"`
 		ExportsBackgroundMaxBytes      nullablePatch[int64]  `json:"exports_background_max_bytes"`
 		SchemaSnapshotFreshnessSeconds nullablePatch[int64]  `json:"schema_snapshot_freshness_seconds"`
@@ -139,7 +144,7 @@ func (app *application) updateOrganizationRuntimeSettings(w http.ResponseWriter,
 		app.badRequest(w, r, err)
 		return
 	}
-	hasPatch := input.QueryMaxResultRows.Set || input.QueryMaxResultBytes.Set ||
+	hasPatch := input.QueryMaxResultRows.Set || input.QueryMaxResultBytes.Set || input.QueryMaxExecutionSeconds.Set ||
 		input.ExportsSyncMaxBytes.Set || input.ExportsBackgroundMaxBytes.Set ||
 		input.SchemaSnapshotFreshnessSeconds.Set || input.FileRevisionsEnabled.Set ||
 		input.FileRevisionsKeepLatest.Set || input.QueryHistoryMode.Set ||
@@ -162,6 +167,9 @@ func (app *application) updateOrganizationRuntimeSettings(w http.ResponseWriter,
 	if input.QueryMaxResultBytes.Set {
 		settings.QueryMaxResultBytes = input.QueryMaxResultBytes.Value
 	}
+	if input.QueryMaxExecutionSeconds.Set {
+		settings.QueryMaxExecutionSeconds = input.QueryMaxExecutionSeconds.Value
+	}
 	if input.ExportsSyncMaxBytes.Set {
 		settings.ExportsSyncMaxBytes = input.ExportsSyncMaxBytes.Value
 	}
@@ -247,6 +255,10 @@ func validateOrganizationRuntimeSettings(v *validator.Validator, settings databa
 		v.CheckField(*settings.QueryMaxResultBytes > 0 && *settings.QueryMaxResultBytes <= instance.QueryMaxResultBytes,
 			"query_max_result_bytes", "Query byte limit must be greater than 0 and no greater than the instance limit.")
 	}
+	if settings.QueryMaxExecutionSeconds != nil {
+		v.CheckField(queryExecutionLimitAllowed(*settings.QueryMaxExecutionSeconds, time.Duration(instance.QueryMaxExecutionSeconds)*time.Second),
+			"query_max_execution_seconds", "Query time limit must be greater than 0 and no greater than the instance limit.")
+	}
 	if settings.ExportsSyncMaxBytes != nil {
 		v.CheckField(*settings.ExportsSyncMaxBytes > 0 && *settings.ExportsSyncMaxBytes <= instance.ExportsSyncMaxBytes,
 			"exports_sync_max_bytes", "Synchronous export limit must be greater than 0 and no greater than the instance limit.")
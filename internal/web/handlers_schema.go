@@ -12,6 +12,7 @@ import (
 	"github.com/sqlwarden/internal/access"
 	"github.com/sqlwarden/internal/connection"
 	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/classifier"
 	"github.com/sqlwarden/internal/engine/ddl"
 	"github.com/sqlwarden/internal/engine/metadata"
 	"github.com/sqlwarden/internal/engine/statement"
@@ -378,16 +379,22 @@ func (app *application) applyConnectionDDL(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	var input ddl.Request
+	var input struct {
+		ddl.Request
+		ConfirmEnvironment string `json:"confirm_environment"`
+	}
 	if err := request.DecodeJSON(w, r, &input); err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
-	if err := ddl.Validate(input, executor.DDLSpec()); err != nil {
+	if err := ddl.Validate(input.Request, executor.DDLSpec()); err != nil {
 		app.apiError(w, r, http.StatusUnprocessableEntity, "invalid_schema_edit", err.Error(), response.APIError{}, nil)
 		return
 	}
-	if err := session.ApplyDDL(r.Context(), input); err != nil {
+	if !app.enforceEnvironmentProtection(w, r, conn, "", classifier.KindDDL, input.ConfirmEnvironment) {
+		return
+	}
+	if err := session.ApplyDDL(r.Context(), input.Request); err != nil {
 		app.apiError(w, r, http.StatusUnprocessableEntity, "schema_edit_failed", err.Error(), response.APIError{}, nil)
 		return
 	}
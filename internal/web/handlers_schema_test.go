@@ -3,6 +3,7 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"maps"
 	"net/http"
 	"net/url"
@@ -336,6 +337,39 @@ func TestApplyConnectionDDLRejectsInvalidInput(t *testing.T) {
 	}
 }
 
+func TestApplyConnectionDDLAppliesEnvironmentProtection(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, tok, org := seedOrgOwner(t, app, uniqueEmail(t, "schema-edit-prod"), "Schema Edit", "Schema Edit Org")
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Schema WS", "")
+	env := seedEnvironment(t, app, ws.ID, org.ID, "Prod")
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(context.Background(), env.ID, database.EnvironmentProtectionProduction, nil))
+	conn := seedConnection(t, app, ws.ID, &env.ID, org.ID, "sqlite", "Schema Conn", "open")
+	driver := &ddlFakeDriver{}
+	sess := openSchemaSession(t, app, owner.ID, conn.ID, driver)
+	edit := func(confirm string) testResponse {
+		req := newAuthRequest(t, http.MethodPost,
+			orgConnectionURL(org.Slug, ws.ID, env.ID, strconv.FormatInt(conn.ID, 10))+"/schema/mutations",
+			map[string]any{
+				"operation":           "create_table",
+				"scope":               metadata.NewScopePath(metadata.ScopeSegment{Kind: "database", Name: "main"}),
+				"name":                "events",
+				"columns":             []map[string]any{{"name": "id", "data_type": "integer", "primary_key": true}},
+				"confirm_environment": confirm,
+			}, tok)
+		req.Header.Set("X-Warden-Session", sess.ID)
+		return send(t, req, app.routes())
+	}
+
+	assertAPIError(t, edit("Prod"), apiErrorDDLWindowClosed, "")
+	assert.Nil(t, app.db.UpdateEnvironmentProtection(context.Background(), env.ID, database.EnvironmentProtectionProduction, openDDLWindows()))
+	assertAPIError(t, edit(""), apiErrorEnvironmentConfirmationRequired, "")
+	driver.mu.Lock()
+	assert.Equal(t, len(driver.applied), 0)
+	driver.mu.Unlock()
+	assert.Equal(t, edit("Prod").StatusCode, http.StatusOK)
+}
+
 func TestPostConnectionObjects(t *testing.T) {
 	t.Parallel()
 	app := newTestApp(t)
@@ -463,11 +497,12 @@ func schemaScopeParam(scope metadata.ScopePath) string {
 func openSchemaSession(t *testing.T, app *application, accountID, connectionID int64, drv engine.Driver) *connection.Session {
 	t.Helper()
 	disableSchemaSnapshots(t, app, connectionID)
-	sess, _, err := app.connManager.GetOrCreate(
-		strconv.FormatInt(accountID, 10),
-		strconv.FormatInt(connectionID, 10),
-		func() (engine.Driver, error) { return drv, nil },
-	)
+	sess, _, err := app.connManager.GetOrCreate(context.Background(), connection.SessionKey{
+		AccountID:    strconv.FormatInt(accountID, 10),
+		ConnectionID: strconv.FormatInt(connectionID, 10),
+	}, connection.SessionOptions{
+		Open: func(engine.DialFunc) (engine.Driver, io.Closer, error) { return drv, nil, nil },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,839 @@
+package web
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/scim"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const (
+	scimBasePath = "/scim/v2/orgs/"
+
+	defaultSCIMPageSize = 100
+	maxSCIMPageSize     = 200
+	maxSCIMTokenName    = 100
+)
+
+// errSCIMLastOwner blocks deprovisioning the organization's last owner, which
+// would leave nobody able to administer it.
+var errSCIMLastOwner = errors.New("scim: cannot deprovision the last owner")
+
+// createdSCIMTokenResponse carries the plaintext token and the base URL to
+// configure in the identity provider. It is only returned on creation.
+type createdSCIMTokenResponse struct {
+	database.SCIMToken
+	Token   string `json:"token"`
+	BaseURL string `json:"base_url"`
+}
+
+func (app *application) scimBaseURL(ctx context.Context, orgSlug string) (string, error) {
+	settings, err := app.instanceSettings(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(settings.BaseURL, "/") + scimBasePath + orgSlug, nil
+}
+
+func (app *application) listSCIMTokens(w http.ResponseWriter, r *http.Request) {
+	q, errs := readListQuery(r.URL.Query(), map[string]string{
+		"created_at": "created_at",
+	})
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+
+	org := contextGetOrg(r)
+	tokens, err := app.db.ListSCIMTokensPage(r.Context(), org.ID, q.Page, q.PageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, tokens)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createSCIMToken(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string              `json:"name"`
+		V    validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(len(input.Name) <= maxSCIMTokenName, "name", "Name must be 100 characters or fewer.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	org := contextGetOrg(r)
+	creator := contextGetAccount(r)
+	plaintext, hash, prefix, err := token.GenerateSCIMToken()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	scimToken, err := app.db.InsertSCIMToken(r.Context(), database.InsertSCIMTokenParams{
+		OrgID:              org.ID,
+		Name:               input.Name,
+		TokenPrefix:        prefix,
+		TokenHash:          hash,
+		CreatedByAccountID: &creator.ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	baseURL, err := app.scimBaseURL(r.Context(), org.Slug)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "scim token created", slog.Int64("org_id", org.ID), slog.String("scim_token_id", scimToken.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.scim_token.create", "organization", org.ID, map[string]any{
+		"scim_token_id": scimToken.ID,
+		"name":          scimToken.Name,
+	}))
+	err = response.JSON(w, http.StatusCreated, createdSCIMTokenResponse{SCIMToken: scimToken, Token: plaintext, BaseURL: baseURL})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) revokeSCIMToken(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	tokenID := strings.TrimSpace(chi.URLParam(r, "token_id"))
+	scimToken, found, err := app.db.GetSCIMToken(r.Context(), tokenID, org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || scimToken.RevokedAt != nil {
+		app.notFound(w, r)
+		return
+	}
+	if err = app.db.RevokeSCIMToken(r.Context(), scimToken.ID, org.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "scim token revoked", slog.Int64("org_id", org.ID), slog.String("scim_token_id", scimToken.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.scim_token.revoke", "organization", org.ID, map[string]any{"scim_token_id": scimToken.ID}))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateSCIM accepts only a SCIM token issued by the organization named
+// in the URL, and puts that organization in the request context. SCIM
+// requests have no authenticated account; audit records name the token.
+func (app *application) authenticateSCIM(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plaintext, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !token.IsSCIMToken(plaintext) {
+			app.scimError(w, r, http.StatusUnauthorized, "", "A SCIM bearer token is required.")
+			return
+		}
+
+		scimToken, found, err := app.db.GetSCIMTokenByHash(r.Context(), token.Hash(plaintext))
+		if err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+		org, orgFound, err := app.db.GetOrgBySlug(r.Context(), chi.URLParam(r, "org_slug"))
+		if err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+		if !found || scimToken.RevokedAt != nil || !orgFound || org.ID != scimToken.OrgID {
+			reason := "scim_token_not_found"
+			if found && scimToken.RevokedAt != nil {
+				reason = "scim_token_revoked"
+			} else if found {
+				reason = "scim_token_wrong_org"
+			}
+			app.logWarn(r, "scim token rejected", slog.String("scim_token_id", scimToken.ID), slog.String("reason", reason))
+			app.scimError(w, r, http.StatusUnauthorized, "", "The SCIM bearer token is invalid.")
+			return
+		}
+		if err = app.db.TouchSCIMToken(r.Context(), scimToken.ID); err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+
+		r = contextSetOrg(r, org)
+		r = contextSetSCIMToken(r, scimToken)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scimAuditEvent builds an audit event for a change made by the SCIM client.
+func scimAuditEvent(r *http.Request, action, resourceType string, resourceID int64, details map[string]any) audit.Event {
+	if details == nil {
+		details = map[string]any{}
+	}
+	details["scim_token_id"] = contextGetSCIMToken(r).ID
+	return orgAuditEvent(r, action, resourceType, resourceID, details)
+}
+
+func (app *application) scimJSON(w http.ResponseWriter, r *http.Request, status int, data any, headers http.Header) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for key, values := range headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+func (app *application) scimError(w http.ResponseWriter, r *http.Request, status int, scimType, detail string) {
+	app.scimJSON(w, r, status, scim.NewError(status, scimType, detail), nil)
+}
+
+func (app *application) scimServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.reportServerError(r, err)
+	app.scimError(w, r, http.StatusInternalServerError, "", "The server encountered a problem and could not process your request.")
+}
+
+func (app *application) scimNotFound(w http.ResponseWriter, r *http.Request) {
+	app.scimError(w, r, http.StatusNotFound, "", "The requested resource could not be found.")
+}
+
+// readSCIMPage reads the 1-based startIndex and count query parameters,
+// clamping them the way RFC 7644 section 3.4.2.4 asks instead of rejecting
+// them.
+func readSCIMPage(r *http.Request) (startIndex, count int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err = strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil {
+		count = defaultSCIMPageSize
+	}
+	return startIndex, min(max(count, 0), maxSCIMPageSize)
+}
+
+// scimPage returns the window of items selected by startIndex and count.
+func scimPage[T any](items []T, startIndex, count int) []T {
+	start := min(startIndex-1, len(items))
+	end := min(start+count, len(items))
+	return items[start:end]
+}
+
+// readSCIMFilter maps the filter query parameter onto the attributes the
+// resource type can be looked up by.
+func readSCIMFilter(r *http.Request, attributes map[string]func(*database.SCIMFilter, string)) (database.SCIMFilter, bool) {
+	filter, err := scim.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		return database.SCIMFilter{}, false
+	}
+	var result database.SCIMFilter
+	if filter.Attribute == "" {
+		return result, true
+	}
+	set, ok := attributes[filter.Attribute]
+	if !ok {
+		return database.SCIMFilter{}, false
+	}
+	set(&result, filter.Value)
+	return result, true
+}
+
+func parseSCIMID(value string) (int64, bool) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	return id, err == nil && id > 0
+}
+
+func (app *application) scimServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	app.scimJSON(w, r, http.StatusOK, map[string]any{
+		"schemas":        []string{scim.SchemaServiceProviderConfig},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": maxSCIMPageSize},
+		"changePassword": map[string]any{"supported": false},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication with an organization SCIM token.",
+			"primary":     true,
+		}},
+	}, nil)
+}
+
+func (app *application) scimResourceTypes(w http.ResponseWriter, r *http.Request) {
+	resourceTypes := []map[string]any{
+		{"schemas": []string{scim.SchemaResourceType}, "id": "User", "name": "User", "endpoint": "/Users", "schema": scim.SchemaUser},
+		{"schemas": []string{scim.SchemaResourceType}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": scim.SchemaGroup},
+	}
+	app.scimJSON(w, r, http.StatusOK, scim.NewListResponse(resourceTypes, len(resourceTypes), 1), nil)
+}
+
+// scimUserState is the part of a User that SQLWarden stores.
+type scimUserState struct {
+	UserName   string
+	Name       string
+	ExternalID string
+	Active     bool
+}
+
+func (app *application) scimUserResource(baseURL string, user database.SCIMUser) scim.User {
+	id := strconv.FormatInt(user.AccountID, 10)
+	active := user.Active()
+	return scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          id,
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		Name:        &scim.Name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scim.Email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     baseURL + "/Users/" + id,
+		},
+	}
+}
+
+func (app *application) writeSCIMUser(w http.ResponseWriter, r *http.Request, status int, accountID int64) {
+	org := contextGetOrg(r)
+	user, found, err := app.db.GetSCIMUser(r.Context(), org.ID, accountID)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	if !found {
+		app.scimNotFound(w, r)
+		return
+	}
+	baseURL, err := app.scimBaseURL(r.Context(), org.Slug)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	resource := app.scimUserResource(baseURL, user)
+	var headers http.Header
+	if status == http.StatusCreated {
+		headers = http.Header{"Location": []string{resource.Meta.Location}}
+	}
+	app.scimJSON(w, r, status, resource, headers)
+}
+
+// scimUserFromRequest loads the user named by {user_id}. It writes a 404 and
+// returns false when the organization's SCIM client cannot see it.
+func (app *application) scimUserFromRequest(w http.ResponseWriter, r *http.Request) (database.SCIMUser, bool) {
+	accountID, ok := parseSCIMID(chi.URLParam(r, "user_id"))
+	if !ok {
+		app.scimNotFound(w, r)
+		return database.SCIMUser{}, false
+	}
+	user, found, err := app.db.GetSCIMUser(r.Context(), contextGetOrg(r).ID, accountID)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return database.SCIMUser{}, false
+	}
+	if !found {
+		app.scimNotFound(w, r)
+		return database.SCIMUser{}, false
+	}
+	return user, true
+}
+
+func (app *application) listSCIMUsers(w http.ResponseWriter, r *http.Request) {
+	filter, ok := readSCIMFilter(r, map[string]func(*database.SCIMFilter, string){
+		"username":   func(f *database.SCIMFilter, v string) { f.UserName = v },
+		"externalid": func(f *database.SCIMFilter, v string) { f.ExternalID = v },
+	})
+	if !ok {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidFilter, `Only "userName eq" and "externalId eq" filters are supported.`)
+		return
+	}
+
+	org := contextGetOrg(r)
+	users, err := app.db.ListSCIMUsers(r.Context(), org.ID, filter)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	baseURL, err := app.scimBaseURL(r.Context(), org.Slug)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+
+	startIndex, count := readSCIMPage(r)
+	page := scimPage(users, startIndex, count)
+	resources := make([]scim.User, len(page))
+	for i, user := range page {
+		resources[i] = app.scimUserResource(baseURL, user)
+	}
+	app.scimJSON(w, r, http.StatusOK, scim.NewListResponse(resources, len(users), startIndex), nil)
+}
+
+func (app *application) getSCIMUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.scimUserFromRequest(w, r)
+	if !ok {
+		return
+	}
+	app.writeSCIMUser(w, r, http.StatusOK, user.AccountID)
+}
+
+// createSCIMUser provisions a user. Accounts are global, so an existing
+// account with the same email is not duplicated; the link is only requested,
+// and the account gains nothing until its holder confirms it. Requesting an
+// account this organization's SCIM client already manages is a conflict.
+func (app *application) createSCIMUser(w http.ResponseWriter, r *http.Request) {
+	var input scim.User
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, err.Error())
+		return
+	}
+	state := scimUserState{
+		UserName:   strings.TrimSpace(input.UserName),
+		ExternalID: strings.TrimSpace(input.ExternalID),
+		Active:     input.Active == nil || *input.Active,
+	}
+	state.Name = cmp.Or(input.Name.Display(), strings.TrimSpace(input.DisplayName), state.UserName)
+	if !validator.IsEmail(state.UserName) {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidValue, "userName must be an email address.")
+		return
+	}
+
+	org := contextGetOrg(r)
+	account, found, err := app.db.GetAccountByEmail(r.Context(), state.UserName)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+
+	outcome := "provisioned"
+	if found {
+		if account.IsService() {
+			app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A user with this userName already exists.")
+			return
+		}
+		existing, known, err := app.db.GetSCIMUser(r.Context(), org.ID, account.ID)
+		if err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+		if known && existing.LinkedAt != nil {
+			app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A user with this userName already exists.")
+			return
+		}
+		if err := app.db.RequestSCIMUserLink(r.Context(), org.ID, account.ID, state.ExternalID, state.Active); err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+		if known && existing.Active() && !state.Active {
+			if err := app.deprovisionSCIMUser(r, existing, false); err != nil {
+				app.scimDeprovisionError(w, r, err)
+				return
+			}
+		}
+		outcome = "pending_confirmation"
+	} else {
+		account, err = app.db.ProvisionSCIMUser(r.Context(), database.ProvisionSCIMUserParams{
+			OrgID:      org.ID,
+			Email:      state.UserName,
+			Name:       state.Name,
+			ExternalID: state.ExternalID,
+			Active:     state.Active,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A user with this userName already exists.")
+				return
+			}
+			app.scimServerError(w, r, err)
+			return
+		}
+	}
+	app.enforcer.InvalidatePrincipals(org.ID, account.ID)
+
+	app.logInfo(r, "scim user created", slog.Int64("org_id", org.ID), slog.Int64("target_account_id", account.ID), slog.String("outcome", outcome))
+	app.recordAudit(r, scimAuditEvent(r, "org.scim.user.create", "account", account.ID, map[string]any{
+		"user_name":   state.UserName,
+		"external_id": state.ExternalID,
+		"active":      state.Active,
+		"outcome":     outcome,
+	}))
+	app.writeSCIMUser(w, r, http.StatusCreated, account.ID)
+}
+
+// replaceSCIMUser handles PUT. Attributes SQLWarden does not store are
+// ignored; an omitted active leaves the user's state unchanged.
+func (app *application) replaceSCIMUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.scimUserFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var input scim.User
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, err.Error())
+		return
+	}
+
+	state := scimUserState{
+		UserName:   strings.TrimSpace(input.UserName),
+		ExternalID: strings.TrimSpace(input.ExternalID),
+		Active:     user.Active(),
+	}
+	state.Name = cmp.Or(input.Name.Display(), strings.TrimSpace(input.DisplayName), user.Name)
+	if input.Active != nil {
+		state.Active = *input.Active
+	}
+	app.applySCIMUserState(w, r, user, state)
+}
+
+// patchSCIMUser handles PATCH. It understands active, userName, displayName,
+// externalId and name, with or without a path; other attributes are ignored.
+func (app *application) patchSCIMUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.scimUserFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var input scim.PatchRequest
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, err.Error())
+		return
+	}
+
+	state := scimUserState{UserName: user.Email, Name: user.Name, ExternalID: user.ExternalID, Active: user.Active()}
+	var name scim.Name
+	for _, op := range input.Operations {
+		switch op.Operation() {
+		case "add", "replace":
+			values := map[string]json.RawMessage{}
+			if op.Path == "" {
+				if err := json.Unmarshal(op.Value, &values); err != nil {
+					app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidValue, "A PATCH operation without a path needs an object value.")
+					return
+				}
+			} else {
+				values[op.Path] = op.Value
+			}
+			for attribute, value := range values {
+				if err := applySCIMUserAttribute(&state, &name, attribute, value); err != nil {
+					app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidValue, err.Error())
+					return
+				}
+			}
+		case "remove":
+			if strings.EqualFold(op.Path, "externalId") {
+				state.ExternalID = ""
+			}
+		default:
+			app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, "PATCH operations must be add, remove or replace.")
+			return
+		}
+	}
+	if display := name.Display(); display != "" {
+		state.Name = display
+	}
+	app.applySCIMUserState(w, r, user, state)
+}
+
+func applySCIMUserAttribute(state *scimUserState, name *scim.Name, attribute string, value json.RawMessage) error {
+	var s string
+	switch strings.ToLower(attribute) {
+	case "active":
+		active, err := scim.BoolValue(value)
+		if err != nil {
+			return errors.New("active must be a boolean.")
+		}
+		state.Active = active
+		return nil
+	case "name":
+		return json.Unmarshal(value, name)
+	case "username", "displayname", "externalid", "name.formatted", "name.givenname", "name.familyname":
+		if err := json.Unmarshal(value, &s); err != nil {
+			return errors.New(attribute + " must be a string.")
+		}
+	default:
+		return nil
+	}
+	switch strings.ToLower(attribute) {
+	case "username":
+		state.UserName = strings.TrimSpace(s)
+	case "displayname":
+		state.Name = strings.TrimSpace(s)
+	case "externalid":
+		state.ExternalID = strings.TrimSpace(s)
+	case "name.formatted":
+		name.Formatted = s
+	case "name.givenname":
+		name.GivenName = s
+	case "name.familyname":
+		name.FamilyName = s
+	}
+	return nil
+}
+
+// applySCIMUserState moves the user from its current state to state and
+// writes the result. Profile changes (userName and name) are only applied to
+// confirmed links to accounts that belong to this organization alone, so one
+// organization's identity provider cannot rename or re-address an account
+// other organizations rely on. Until the account holder confirms the link,
+// deactivation still removes the account from the organization, but
+// activation is only remembered.
+func (app *application) applySCIMUserState(w http.ResponseWriter, r *http.Request, user database.SCIMUser, state scimUserState) {
+	if !validator.IsEmail(state.UserName) {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidValue, "userName must be an email address.")
+		return
+	}
+	org := contextGetOrg(r)
+	ctx := r.Context()
+
+	if state.UserName != user.Email || (state.Name != "" && state.Name != user.Name) {
+		owned := false
+		if user.Confirmed() {
+			var err error
+			owned, err = app.db.AccountBelongsOnlyToOrg(ctx, user.AccountID, org.ID)
+			if err != nil {
+				app.scimServerError(w, r, err)
+				return
+			}
+		}
+		if owned {
+			if !strings.EqualFold(state.UserName, user.Email) {
+				_, taken, err := app.db.GetAccountByEmail(ctx, state.UserName)
+				if err != nil {
+					app.scimServerError(w, r, err)
+					return
+				}
+				if taken {
+					app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A user with this userName already exists.")
+					return
+				}
+			}
+			err := app.db.UpdateSCIMAccountProfile(ctx, org.ID, user.AccountID, state.UserName, cmp.Or(state.Name, user.Name))
+			if err != nil {
+				if isUniqueViolation(err) {
+					app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A user with this userName already exists.")
+					return
+				}
+				app.scimServerError(w, r, err)
+				return
+			}
+		} else {
+			app.logInfo(r, "scim profile change skipped for shared or unconfirmed account", slog.Int64("org_id", org.ID), slog.Int64("target_account_id", user.AccountID))
+		}
+	}
+
+	reactivate := state.Active && !user.Active()
+	if user.Confirmed() {
+		if err := app.db.LinkSCIMUser(ctx, org.ID, user.AccountID, state.ExternalID, reactivate); err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+	} else {
+		if err := app.db.RequestSCIMUserLink(ctx, org.ID, user.AccountID, state.ExternalID, state.Active); err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+		if reactivate {
+			app.logInfo(r, "scim activation awaiting account holder confirmation", slog.Int64("org_id", org.ID), slog.Int64("target_account_id", user.AccountID))
+			reactivate = false
+		}
+	}
+	switch {
+	case reactivate:
+		app.enforcer.InvalidatePrincipals(org.ID, user.AccountID)
+		app.logInfo(r, "scim user reactivated", slog.Int64("org_id", org.ID), slog.Int64("target_account_id", user.AccountID))
+		app.recordAudit(r, scimAuditEvent(r, "org.scim.user.reactivate", "account", user.AccountID, nil))
+	case !state.Active && user.Active():
+		if err := app.deprovisionSCIMUser(r, user, false); err != nil {
+			app.scimDeprovisionError(w, r, err)
+			return
+		}
+	default:
+		app.recordAudit(r, scimAuditEvent(r, "org.scim.user.update", "account", user.AccountID, map[string]any{
+			"user_name":   state.UserName,
+			"external_id": state.ExternalID,
+		}))
+	}
+	app.writeSCIMUser(w, r, http.StatusOK, user.AccountID)
+}
+
+func (app *application) deleteSCIMUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.scimUserFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := app.deprovisionSCIMUser(r, user, true); err != nil {
+		app.scimDeprovisionError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deprovisionSCIMUser removes the account from the organization the way
+// removing a member does: memberships, direct bindings, org access sessions
+// and live database sessions go. When the link is confirmed and the
+// organization is the only one with a claim on the account, its auth sessions
+// and API tokens are revoked too, so a deprovisioned person is signed out
+// everywhere; shared accounts and accounts whose holder never confirmed the
+// link keep their sign-in. forget also drops the SCIM record.
+func (app *application) deprovisionSCIMUser(r *http.Request, user database.SCIMUser, forget bool) error {
+	org := contextGetOrg(r)
+	ctx := r.Context()
+	accountID := user.AccountID
+
+	isLastOwner, err := app.isLastOrgOwner(r, org.ID, accountID)
+	if err != nil {
+		return err
+	}
+	if isLastOwner {
+		app.logWarn(r, "last organization owner deprovisioning blocked", slog.Int64("target_account_id", accountID), slog.Int64("org_id", org.ID))
+		return errSCIMLastOwner
+	}
+	signOut := false
+	if user.Confirmed() {
+		signOut, err = app.db.AccountBelongsOnlyToOrg(ctx, accountID, org.ID)
+		if err != nil {
+			return err
+		}
+	}
+	err = app.db.DeprovisionSCIMUser(ctx, database.DeprovisionSCIMUserParams{
+		OrgID:     org.ID,
+		AccountID: accountID,
+		SignOut:   signOut,
+		Forget:    forget,
+	})
+	if err != nil {
+		return err
+	}
+	app.connManager.RemoveForOrgAccount(strconv.FormatInt(org.ID, 10), strconv.FormatInt(accountID, 10))
+	app.enforcer.InvalidatePrincipals(org.ID, accountID)
+
+	action := "org.scim.user.deactivate"
+	if forget {
+		action = "org.scim.user.delete"
+	}
+	app.logInfo(r, "scim user deprovisioned", slog.Int64("org_id", org.ID), slog.Int64("target_account_id", accountID), slog.Bool("signed_out", signOut), slog.Bool("forgotten", forget))
+	app.recordAudit(r, scimAuditEvent(r, action, "account", accountID, map[string]any{"signed_out": signOut}))
+	return nil
+}
+
+func (app *application) scimDeprovisionError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errSCIMLastOwner) {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorMutability, "The last owner of an organization cannot be deprovisioned.")
+		return
+	}
+	app.scimServerError(w, r, err)
+}
+
+// listAccountSCIMLinks lists the organizations whose identity providers asked
+// to manage the signed-in account and are waiting for its confirmation.
+func (app *application) listAccountSCIMLinks(w http.ResponseWriter, r *http.Request) {
+	links, err := app.db.ListPendingSCIMLinks(r.Context(), contextGetAccount(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{"items": links})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// confirmAccountSCIMLink lets the account holder hand an organization's
+// identity provider control of their membership there, joining the
+// organization if the provider last marked them active.
+func (app *application) confirmAccountSCIMLink(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	orgID, err := strconv.ParseInt(chi.URLParam(r, "org_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+	found, joined, err := app.db.ConfirmSCIMUserLink(r.Context(), orgID, account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+	if app.enforcer != nil {
+		app.enforcer.InvalidatePrincipals(orgID, account.ID)
+	}
+
+	app.logInfo(r, "scim link confirmed", slog.Int64("org_id", orgID), slog.Int64("account_id", account.ID), slog.Bool("joined", joined))
+	app.recordAudit(r, audit.Event{
+		OrgID:        &orgID,
+		Action:       "account.scim_link.confirm",
+		ResourceType: "account",
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+		Details:      map[string]any{"joined": joined},
+	})
+	err = response.JSON(w, http.StatusOK, map[string]any{"joined": joined})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// declineAccountSCIMLink drops an organization's pending request to manage the
+// account. Membership the account already had is unchanged.
+func (app *application) declineAccountSCIMLink(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	orgID, err := strconv.ParseInt(chi.URLParam(r, "org_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+	declined, err := app.db.DeclineSCIMUserLink(r.Context(), orgID, account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !declined {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "scim link declined", slog.Int64("org_id", orgID), slog.Int64("account_id", account.ID))
+	app.recordAudit(r, audit.Event{
+		OrgID:        &orgID,
+		Action:       "account.scim_link.decline",
+		ResourceType: "account",
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,467 @@
+package web
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/scim"
+)
+
+// scimGroupState is the part of a Group that SQLWarden stores. Members holds
+// account ids in the order the client sent them.
+type scimGroupState struct {
+	DisplayName string
+	ExternalID  string
+	Members     []int64
+}
+
+func (app *application) scimGroupResource(baseURL string, group database.SCIMGroup, members []database.TeamMemberListItem, withMembers bool) scim.Group {
+	id := strconv.FormatInt(group.TeamID, 10)
+	resource := scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          id,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.Name,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     baseURL + "/Groups/" + id,
+		},
+	}
+	if withMembers {
+		list := make([]scim.Member, len(members))
+		for i, member := range members {
+			memberID := strconv.FormatInt(member.AccountID, 10)
+			list[i] = scim.Member{Value: memberID, Display: member.Email, Ref: baseURL + "/Users/" + memberID}
+		}
+		resource.Members = &list
+	}
+	return resource
+}
+
+// scimExcludesMembers reports whether the client asked to leave members out,
+// which identity providers do to keep large groups cheap to read.
+func scimExcludesMembers(r *http.Request) bool {
+	for _, attribute := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *application) writeSCIMGroup(w http.ResponseWriter, r *http.Request, status int, teamID int64) {
+	org := contextGetOrg(r)
+	group, found, err := app.db.GetSCIMGroup(r.Context(), org.ID, teamID)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	if !found {
+		app.scimNotFound(w, r)
+		return
+	}
+	members, err := app.db.ListTeamMembersForTeams(r.Context(), []int64{teamID})
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	baseURL, err := app.scimBaseURL(r.Context(), org.Slug)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	resource := app.scimGroupResource(baseURL, group, members[teamID], !scimExcludesMembers(r))
+	var headers http.Header
+	if status == http.StatusCreated {
+		headers = http.Header{"Location": []string{resource.Meta.Location}}
+	}
+	app.scimJSON(w, r, status, resource, headers)
+}
+
+// scimGroupFromRequest loads the team named by {group_id}. It writes a 404 and
+// returns false when the team is not in the organization.
+func (app *application) scimGroupFromRequest(w http.ResponseWriter, r *http.Request) (database.SCIMGroup, bool) {
+	teamID, ok := parseSCIMID(chi.URLParam(r, "group_id"))
+	if !ok {
+		app.scimNotFound(w, r)
+		return database.SCIMGroup{}, false
+	}
+	group, found, err := app.db.GetSCIMGroup(r.Context(), contextGetOrg(r).ID, teamID)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return database.SCIMGroup{}, false
+	}
+	if !found {
+		app.scimNotFound(w, r)
+		return database.SCIMGroup{}, false
+	}
+	return group, true
+}
+
+func (app *application) listSCIMGroups(w http.ResponseWriter, r *http.Request) {
+	filter, ok := readSCIMFilter(r, map[string]func(*database.SCIMFilter, string){
+		"displayname": func(f *database.SCIMFilter, v string) { f.DisplayName = v },
+		"externalid":  func(f *database.SCIMFilter, v string) { f.ExternalID = v },
+	})
+	if !ok {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidFilter, `Only "displayName eq" and "externalId eq" filters are supported.`)
+		return
+	}
+
+	org := contextGetOrg(r)
+	groups, err := app.db.ListSCIMGroups(r.Context(), org.ID, filter)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	startIndex, count := readSCIMPage(r)
+	page := scimPage(groups, startIndex, count)
+
+	withMembers := !scimExcludesMembers(r)
+	members := map[int64][]database.TeamMemberListItem{}
+	if withMembers {
+		teamIDs := make([]int64, len(page))
+		for i, group := range page {
+			teamIDs[i] = group.TeamID
+		}
+		members, err = app.db.ListTeamMembersForTeams(r.Context(), teamIDs)
+		if err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+	}
+	baseURL, err := app.scimBaseURL(r.Context(), org.Slug)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+
+	resources := make([]scim.Group, len(page))
+	for i, group := range page {
+		resources[i] = app.scimGroupResource(baseURL, group, members[group.TeamID], withMembers)
+	}
+	app.scimJSON(w, r, http.StatusOK, scim.NewListResponse(resources, len(groups), startIndex), nil)
+}
+
+func (app *application) getSCIMGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := app.scimGroupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	app.writeSCIMGroup(w, r, http.StatusOK, group.TeamID)
+}
+
+// parseSCIMMembers reads member ids. Ids that are not members of the
+// organization are dropped: a team can only hold org members, and identity
+// providers routinely push groups before every user in them is provisioned.
+// A malformed id is returned as a scim.Error.
+func (app *application) parseSCIMMembers(r *http.Request, members []scim.Member) ([]int64, error) {
+	ids := make([]int64, 0, len(members))
+	for _, member := range members {
+		id, ok := parseSCIMID(member.Value)
+		if !ok {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrorInvalidValue, fmt.Sprintf("Member value %q is not a user id.", member.Value))
+		}
+		ids = append(ids, id)
+	}
+	return app.db.FilterOrgMembers(r.Context(), contextGetOrg(r).ID, ids)
+}
+
+// scimRequestError writes err as a 400 when it is a scim.Error and as a
+// server error otherwise.
+func (app *application) scimRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	var scimErr scim.Error
+	if errors.As(err, &scimErr) {
+		app.scimJSON(w, r, http.StatusBadRequest, scimErr, nil)
+		return
+	}
+	app.scimServerError(w, r, err)
+}
+
+// uniqueTeamSlug derives a slug from the group's display name, adding a
+// numeric suffix when a team already uses it.
+func (app *application) uniqueTeamSlug(r *http.Request, name string) (string, error) {
+	base := cmp.Or(slugify(name), "group")
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			suffix := "-" + strconv.Itoa(n)
+			slug = base[:min(len(base), maxOrganizationSlugLength-len(suffix))] + suffix
+		}
+		_, found, err := app.db.GetTeam(r.Context(), contextGetOrg(r).ID, slug)
+		if err != nil || !found {
+			return slug, err
+		}
+	}
+}
+
+func (app *application) createSCIMGroup(w http.ResponseWriter, r *http.Request) {
+	var input scim.Group
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, err.Error())
+		return
+	}
+	input.DisplayName = strings.TrimSpace(input.DisplayName)
+	if input.DisplayName == "" {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidValue, "displayName is required.")
+		return
+	}
+
+	org := contextGetOrg(r)
+	existing, err := app.db.ListSCIMGroups(r.Context(), org.ID, database.SCIMFilter{DisplayName: input.DisplayName})
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	if len(existing) > 0 {
+		app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A group with this displayName already exists.")
+		return
+	}
+
+	var memberIDs []int64
+	if input.Members != nil {
+		memberIDs, err = app.parseSCIMMembers(r, *input.Members)
+		if err != nil {
+			app.scimRequestError(w, r, err)
+			return
+		}
+	}
+	slug, err := app.uniqueTeamSlug(r, input.DisplayName)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	team, err := app.db.CreateSCIMGroup(r.Context(), org.ID, slug, input.DisplayName, strings.TrimSpace(input.ExternalID), memberIDs)
+	if err != nil {
+		if isUniqueViolation(err) {
+			app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A group with this displayName already exists.")
+			return
+		}
+		app.scimServerError(w, r, err)
+		return
+	}
+	for _, accountID := range memberIDs {
+		app.enforcer.InvalidatePrincipals(org.ID, accountID)
+	}
+
+	app.logInfo(r, "scim group created", slog.Int64("org_id", org.ID), slog.Int64("team_id", team.ID), slog.String("team_slug", team.Slug))
+	app.recordAudit(r, scimAuditEvent(r, "org.scim.group.create", "team", team.ID, map[string]any{
+		"slug":        team.Slug,
+		"external_id": strings.TrimSpace(input.ExternalID),
+		"members":     memberIDs,
+	}))
+	app.writeSCIMGroup(w, r, http.StatusCreated, team.ID)
+}
+
+// currentSCIMGroupState loads the stored state of a group.
+func (app *application) currentSCIMGroupState(r *http.Request, group database.SCIMGroup) (scimGroupState, error) {
+	members, err := app.db.ListTeamMembersForTeams(r.Context(), []int64{group.TeamID})
+	if err != nil {
+		return scimGroupState{}, err
+	}
+	state := scimGroupState{DisplayName: group.Name, ExternalID: group.ExternalID, Members: []int64{}}
+	for _, member := range members[group.TeamID] {
+		state.Members = append(state.Members, member.AccountID)
+	}
+	return state, nil
+}
+
+func (app *application) replaceSCIMGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := app.scimGroupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var input scim.Group
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, err.Error())
+		return
+	}
+
+	state, err := app.currentSCIMGroupState(r, group)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	state.DisplayName = cmp.Or(strings.TrimSpace(input.DisplayName), group.Name)
+	state.ExternalID = strings.TrimSpace(input.ExternalID)
+	if input.Members != nil {
+		state.Members, err = app.parseSCIMMembers(r, *input.Members)
+		if err != nil {
+			app.scimRequestError(w, r, err)
+			return
+		}
+	}
+	app.applySCIMGroupState(w, r, group, state)
+}
+
+// patchSCIMGroup applies add, remove and replace operations on members,
+// displayName and externalId in order. Member additions are filtered to org
+// members, as on create.
+func (app *application) patchSCIMGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := app.scimGroupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var input scim.PatchRequest
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.scimError(w, r, http.StatusBadRequest, scim.ErrorInvalidSyntax, err.Error())
+		return
+	}
+
+	state, err := app.currentSCIMGroupState(r, group)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	for _, op := range input.Operations {
+		if err := app.applySCIMGroupOperation(r, &state, op); err != nil {
+			app.scimRequestError(w, r, err)
+			return
+		}
+	}
+	app.applySCIMGroupState(w, r, group, state)
+}
+
+func (app *application) applySCIMGroupOperation(r *http.Request, state *scimGroupState, op scim.PatchOperation) error {
+	path := strings.TrimSpace(op.Path)
+	switch op.Operation() {
+	case "add", "replace":
+		values := map[string]json.RawMessage{}
+		if path == "" {
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrorInvalidValue, "A PATCH operation without a path needs an object value.")
+			}
+		} else {
+			values[path] = op.Value
+		}
+		for attribute, value := range values {
+			switch strings.ToLower(attribute) {
+			case "members":
+				var members []scim.Member
+				if err := json.Unmarshal(value, &members); err != nil {
+					return scim.NewError(http.StatusBadRequest, scim.ErrorInvalidValue, "members must be a list of members.")
+				}
+				ids, err := app.parseSCIMMembers(r, members)
+				if err != nil {
+					return err
+				}
+				if op.Operation() == "replace" {
+					state.Members = ids
+					continue
+				}
+				for _, id := range ids {
+					if !slices.Contains(state.Members, id) {
+						state.Members = append(state.Members, id)
+					}
+				}
+			case "displayname", "externalid":
+				var s string
+				if err := json.Unmarshal(value, &s); err != nil {
+					return scim.NewError(http.StatusBadRequest, scim.ErrorInvalidValue, attribute+" must be a string.")
+				}
+				if strings.EqualFold(attribute, "displayName") {
+					state.DisplayName = cmp.Or(strings.TrimSpace(s), state.DisplayName)
+				} else {
+					state.ExternalID = strings.TrimSpace(s)
+				}
+			}
+		}
+	case "remove":
+		if id, ok := scim.MemberPathFilter(path); ok {
+			state.Members = slices.DeleteFunc(state.Members, func(member int64) bool {
+				return strconv.FormatInt(member, 10) == id
+			})
+			return nil
+		}
+		switch strings.ToLower(path) {
+		case "members":
+			var members []scim.Member
+			if len(op.Value) == 0 || json.Unmarshal(op.Value, &members) != nil {
+				state.Members = []int64{}
+				return nil
+			}
+			state.Members = slices.DeleteFunc(state.Members, func(member int64) bool {
+				return slices.ContainsFunc(members, func(m scim.Member) bool {
+					return m.Value == strconv.FormatInt(member, 10)
+				})
+			})
+		case "externalid":
+			state.ExternalID = ""
+		case "":
+			return scim.NewError(http.StatusBadRequest, scim.ErrorNoTarget, "A remove operation needs a path.")
+		}
+	default:
+		return scim.NewError(http.StatusBadRequest, scim.ErrorInvalidSyntax, "PATCH operations must be add, remove or replace.")
+	}
+	return nil
+}
+
+// applySCIMGroupState stores state and writes the resulting group. Accounts
+// that joined or left the team have their cached principals dropped.
+func (app *application) applySCIMGroupState(w http.ResponseWriter, r *http.Request, group database.SCIMGroup, state scimGroupState) {
+	org := contextGetOrg(r)
+	if state.DisplayName != group.Name {
+		existing, err := app.db.ListSCIMGroups(r.Context(), org.ID, database.SCIMFilter{DisplayName: state.DisplayName})
+		if err != nil {
+			app.scimServerError(w, r, err)
+			return
+		}
+		if len(existing) > 0 {
+			app.scimError(w, r, http.StatusConflict, scim.ErrorUniqueness, "A group with this displayName already exists.")
+			return
+		}
+	}
+
+	changed, err := app.db.UpdateSCIMGroup(r.Context(), org.ID, group.TeamID, state.DisplayName, state.ExternalID, state.Members)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	for _, accountID := range changed {
+		app.enforcer.InvalidatePrincipals(org.ID, accountID)
+	}
+
+	app.logInfo(r, "scim group updated", slog.Int64("org_id", org.ID), slog.Int64("team_id", group.TeamID), slog.Int("changed_members", len(changed)))
+	app.recordAudit(r, scimAuditEvent(r, "org.scim.group.update", "team", group.TeamID, map[string]any{
+		"name":            state.DisplayName,
+		"external_id":     state.ExternalID,
+		"changed_members": changed,
+	}))
+	app.writeSCIMGroup(w, r, http.StatusOK, group.TeamID)
+}
+
+func (app *application) deleteSCIMGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := app.scimGroupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	state, err := app.currentSCIMGroupState(r, group)
+	if err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	org := contextGetOrg(r)
+	if err := app.db.DeleteTeam(r.Context(), group.TeamID, org.ID); err != nil {
+		app.scimServerError(w, r, err)
+		return
+	}
+	for _, accountID := range state.Members {
+		app.enforcer.InvalidatePrincipals(org.ID, accountID)
+	}
+
+	app.logInfo(r, "scim group deleted", slog.Int64("org_id", org.ID), slog.Int64("team_id", group.TeamID), slog.String("team_slug", group.Slug))
+	app.recordAudit(r, scimAuditEvent(r, "org.scim.group.delete", "team", group.TeamID, map[string]any{"slug": group.Slug}))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,381 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/scim"
+)
+
+func createSCIMTokenForTest(t *testing.T, app *application, orgSlug, ownerToken string) (string, string) {
+	t.Helper()
+
+	res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+orgSlug+"/scim/tokens", map[string]any{"name": "okta"}, ownerToken), app.routes())
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create scim token: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	return res.BodyFields["id"].(string), res.BodyFields["token"].(string)
+}
+
+func scimRequest(t *testing.T, method, path string, body map[string]any, scimToken string) *http.Request {
+	t.Helper()
+	req := newAuthRequest(t, method, path, body, scimToken)
+	if body != nil {
+		req.Header.Set("Content-Type", scim.ContentType)
+	}
+	return req
+}
+
+func scimPatch(ops ...map[string]any) map[string]any {
+	return map[string]any{"schemas": []string{scim.SchemaPatchOp}, "Operations": ops}
+}
+
+func TestSCIMTokenIsScopedToItsOrganization(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-owner"), "SCIM Owner", "SCIM Org")
+	_, otherToken, otherOrg := seedOrgOwner(t, app, uniqueEmail(t, "scim-other"), "SCIM Other", "SCIM Other Org")
+
+	tokenID, plaintext := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+
+	res := send(t, scimRequest(t, http.MethodGet, "/scim/v2/orgs/"+org.Slug+"/Users", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), scim.ContentType)
+
+	res = send(t, scimRequest(t, http.MethodGet, "/scim/v2/orgs/"+otherOrg.Slug+"/Users", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	assert.Equal(t, res.BodyFields["status"].(string), "401")
+
+	res = send(t, scimRequest(t, http.MethodGet, "/scim/v2/orgs/"+otherOrg.Slug+"/Users", nil, otherToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/orgs/"+org.Slug+"/scim/tokens/"+tokenID, nil, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	res = send(t, scimRequest(t, http.MethodGet, "/scim/v2/orgs/"+org.Slug+"/Users", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestSCIMTokenManagementRequiresOrgWrite(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, _, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-perm-owner"), "SCIM Perm Owner", "SCIM Perm Org")
+	memberEmail := uniqueEmail(t, "scim-perm-member")
+	_, memberToken := seedAccountWithToken(t, app, memberEmail, "Member")
+	addOrgMemberDirect(t, app, org.Slug, memberEmail)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+org.Slug+"/scim/tokens", map[string]any{"name": "okta"}, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+}
+
+func TestSCIMUserProvisioning(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-users-owner"), "SCIM Users Owner", "SCIM Users Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+	base := "/scim/v2/orgs/" + org.Slug + "/Users"
+	email := uniqueEmail(t, "scim-ada")
+
+	res := send(t, scimRequest(t, http.MethodPost, base, map[string]any{
+		"schemas":    []string{scim.SchemaUser},
+		"userName":   email,
+		"externalId": "00u-ada",
+		"name":       map[string]any{"givenName": "Ada", "familyName": "Lovelace"},
+		"active":     true,
+	}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["displayName"].(string), "Ada Lovelace")
+	assert.Equal(t, res.BodyFields["active"].(bool), true)
+	userID := res.BodyFields["id"].(string)
+	assert.Equal(t, res.Header.Get("Location"), res.BodyFields["meta"].(map[string]any)["location"].(string))
+
+	accountID, _ := strconv.ParseInt(userID, 10, 64)
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, accountID)
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+
+	res = send(t, scimRequest(t, http.MethodPost, base, map[string]any{"userName": email}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusConflict)
+	assert.Equal(t, res.BodyFields["scimType"].(string), scim.ErrorUniqueness)
+
+	res = send(t, scimRequest(t, http.MethodGet, base+`?filter=userName+eq+"`+email+`"`, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["totalResults"].(float64), 1)
+
+	res = send(t, scimRequest(t, http.MethodGet, base+`?filter=userName+sw+"ada"`, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, res.BodyFields["scimType"].(string), scim.ErrorInvalidFilter)
+
+	res = send(t, scimRequest(t, http.MethodPatch, base+"/"+userID, scimPatch(
+		map[string]any{"op": "Replace", "path": "displayName", "value": "Ada King"},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["displayName"].(string), "Ada King")
+
+	res = send(t, scimRequest(t, http.MethodDelete, base+"/"+userID, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	res = send(t, scimRequest(t, http.MethodGet, base+"/"+userID, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestSCIMUserDeactivationSignsOutAndRemovesAccess(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-deact-owner"), "SCIM Deact Owner", "SCIM Deact Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+	base := "/scim/v2/orgs/" + org.Slug + "/Users"
+
+	email := uniqueEmail(t, "scim-leaver")
+	account, accountToken := seedAccountWithToken(t, app, email, "Leaver")
+	addOrgMemberDirect(t, app, org.Slug, email)
+
+	res := send(t, scimRequest(t, http.MethodPost, base, map[string]any{"userName": email, "externalId": "00u-leaver"}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	userID := res.BodyFields["id"].(string)
+	assert.Equal(t, userID, strconv.FormatInt(account.ID, 10))
+
+	res = send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/scim-links/"+strconv.FormatInt(org.ID, 10)+"/confirm", nil, accountToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["joined"].(bool), true)
+
+	res = send(t, scimRequest(t, http.MethodPatch, base+"/"+userID, scimPatch(
+		map[string]any{"op": "replace", "value": map[string]any{"active": "False"}},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["active"].(bool), false)
+
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, account.ID)
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+
+	sessions, err := app.db.ListAuthSessionsPage(context.Background(), database.ListAuthSessionsParams{AccountID: account.ID})
+	assert.Nil(t, err)
+	for _, session := range sessions.Items {
+		assert.NotNil(t, session.RevokedAt)
+	}
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/me", nil, accountToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	res = send(t, scimRequest(t, http.MethodGet, base+"/"+userID, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["active"].(bool), false)
+
+	res = send(t, scimRequest(t, http.MethodPut, base+"/"+userID, map[string]any{
+		"userName": email, "externalId": "00u-leaver", "active": true,
+	}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["active"].(bool), true)
+
+	isMember, err = app.db.IsOrgMember(context.Background(), org.ID, account.ID)
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+}
+
+func TestSCIMCannotDeprovisionLastOwner(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-last-owner"), "SCIM Last Owner", "SCIM Last Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+
+	res := send(t, scimRequest(t, http.MethodPatch, "/scim/v2/orgs/"+org.Slug+"/Users/"+strconv.FormatInt(owner.ID, 10), scimPatch(
+		map[string]any{"op": "replace", "path": "active", "value": false},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, res.BodyFields["scimType"].(string), scim.ErrorMutability)
+
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, owner.ID)
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+}
+
+func TestSCIMGroupProvisioning(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-groups-owner"), "SCIM Groups Owner", "SCIM Groups Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+	users := "/scim/v2/orgs/" + org.Slug + "/Users"
+	groups := "/scim/v2/orgs/" + org.Slug + "/Groups"
+
+	var ids []string
+	for _, name := range []string{"scim-grace", "scim-linus"} {
+		res := send(t, scimRequest(t, http.MethodPost, users, map[string]any{"userName": uniqueEmail(t, name)}, scimToken), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusCreated)
+		ids = append(ids, res.BodyFields["id"].(string))
+	}
+
+	res := send(t, scimRequest(t, http.MethodPost, groups, map[string]any{
+		"schemas":     []string{scim.SchemaGroup},
+		"displayName": "Data Platform",
+		"externalId":  "00g-data",
+		"members":     []map[string]any{{"value": ids[0]}},
+	}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	groupID := res.BodyFields["id"].(string)
+	assert.Equal(t, len(res.BodyFields["members"].([]any)), 1)
+
+	teamID, _ := strconv.ParseInt(groupID, 10, 64)
+	team, found, err := app.db.GetTeamByID(context.Background(), teamID)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, team.Slug, "data-platform")
+
+	res = send(t, scimRequest(t, http.MethodPost, groups, map[string]any{"displayName": "Data Platform"}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusConflict)
+
+	res = send(t, scimRequest(t, http.MethodPatch, groups+"/"+groupID, scimPatch(
+		map[string]any{"op": "add", "path": "members", "value": []map[string]any{{"value": ids[1]}}},
+		map[string]any{"op": "remove", "path": `members[value eq "` + ids[0] + `"]`},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	members := res.BodyFields["members"].([]any)
+	assert.Equal(t, len(members), 1)
+	assert.Equal(t, members[0].(map[string]any)["value"].(string), ids[1])
+
+	res = send(t, scimRequest(t, http.MethodGet, groups+`?filter=displayName+eq+"Data+Platform"&excludedAttributes=members`, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["totalResults"].(float64), 1)
+	_, hasMembers := res.BodyFields["Resources"].([]any)[0].(map[string]any)["members"]
+	assert.False(t, hasMembers)
+
+	res = send(t, scimRequest(t, http.MethodPatch, groups+"/"+groupID, scimPatch(
+		map[string]any{"op": "add", "path": "members", "value": []map[string]any{{"value": "not-a-user"}}},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, res.BodyFields["scimType"].(string), scim.ErrorInvalidValue)
+
+	res = send(t, scimRequest(t, http.MethodDelete, groups+"/"+groupID, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	_, found, err = app.db.GetTeamByID(context.Background(), teamID)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestSCIMExistingAccountLinkNeedsAccountHolderConfirmation(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-confirm-owner"), "SCIM Confirm Owner", "SCIM Confirm Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+	base := "/scim/v2/orgs/" + org.Slug + "/Users"
+	linksURL := "/api/v1/account/scim-links"
+
+	email := uniqueEmail(t, "scim-existing")
+	account, accountToken := seedAccountWithToken(t, app, email, "Existing")
+
+	res := send(t, scimRequest(t, http.MethodPost, base, map[string]any{"userName": email, "externalId": "00u-existing"}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["active"].(bool), false)
+	userID := res.BodyFields["id"].(string)
+
+	// Activation is only remembered while the link is pending.
+	res = send(t, scimRequest(t, http.MethodPatch, base+"/"+userID, scimPatch(
+		map[string]any{"op": "replace", "value": map[string]any{"active": true, "userName": uniqueEmail(t, "scim-renamed")}},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["active"].(bool), false)
+	assert.Equal(t, res.BodyFields["userName"].(string), email)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, linksURL, nil, accountToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	items := res.BodyFields["items"].([]any)
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].(map[string]any)["org_slug"].(string), org.Slug)
+
+	res = send(t, newAuthRequest(t, http.MethodPost, linksURL+"/"+strconv.FormatInt(org.ID, 10)+"/confirm", nil, accountToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["joined"].(bool), true)
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, account.ID)
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+
+	res = send(t, newAuthRequest(t, http.MethodPost, linksURL+"/"+strconv.FormatInt(org.ID, 10)+"/confirm", nil, accountToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestSCIMCannotTakeOverInstanceAdmin(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-admin-owner"), "SCIM Admin Owner", "SCIM Admin Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+	base := "/scim/v2/orgs/" + org.Slug + "/Users"
+
+	email := uniqueEmail(t, "scim-admin")
+	admin, adminToken := seedInstanceAdminAccount(t, app, email, "Admin")
+
+	res := send(t, scimRequest(t, http.MethodPost, base, map[string]any{"userName": email, "active": true}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["active"].(bool), false)
+	userID := res.BodyFields["id"].(string)
+
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, admin.ID)
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+
+	res = send(t, scimRequest(t, http.MethodPut, base+"/"+userID, map[string]any{
+		"userName": uniqueEmail(t, "scim-attacker"), "displayName": "Attacker", "active": true,
+	}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	res = send(t, scimRequest(t, http.MethodDelete, base+"/"+userID, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	account, found, err := app.db.GetAccount(context.Background(), admin.ID)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, account.Email, email)
+	assert.Equal(t, account.Name, "Admin")
+	isMember, err = app.db.IsOrgMember(context.Background(), org.ID, admin.ID)
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/me", nil, adminToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestSCIMCannotTakeOverOtherOrganizationMember(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "scim-cross-owner"), "SCIM Cross Owner", "SCIM Cross Org")
+	_, _, otherOrg := seedOrgOwner(t, app, uniqueEmail(t, "scim-cross-other"), "SCIM Cross Other", "SCIM Cross Other Org")
+	_, scimToken := createSCIMTokenForTest(t, app, org.Slug, ownerToken)
+	base := "/scim/v2/orgs/" + org.Slug + "/Users"
+
+	email := uniqueEmail(t, "scim-cross-member")
+	member, memberToken := seedAccountWithToken(t, app, email, "Member")
+	addOrgMemberDirect(t, app, otherOrg.Slug, email)
+
+	res := send(t, scimRequest(t, http.MethodPost, base, map[string]any{"userName": email, "active": true}, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["active"].(bool), false)
+	userID := res.BodyFields["id"].(string)
+
+	res = send(t, scimRequest(t, http.MethodPatch, base+"/"+userID, scimPatch(
+		map[string]any{"op": "replace", "path": "userName", "value": uniqueEmail(t, "scim-hijack")},
+		map[string]any{"op": "replace", "path": "active", "value": false},
+	), scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	account, _, err := app.db.GetAccount(context.Background(), member.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, account.Email, email)
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, member.ID)
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+	isMember, err = app.db.IsOrgMember(context.Background(), otherOrg.ID, member.ID)
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/me", nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	// Declining drops the request; the other organization is unaffected.
+	res = send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/account/scim-links/"+strconv.FormatInt(org.ID, 10), nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	res = send(t, scimRequest(t, http.MethodGet, base+"/"+userID, nil, scimToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
@@ -0,0 +1,238 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const maxServiceAccountDescriptionLength = 500
+
+func (app *application) listServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	q, errs := readListQuery(r.URL.Query(), map[string]string{
+		"slug": "slug",
+	})
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+
+	serviceAccounts, err := app.db.ListServiceAccountsPage(r.Context(), database.ListServiceAccountsParams{
+		OrgID:    org.ID,
+		Search:   q.Search,
+		Page:     q.Page,
+		PageSize: q.PageSize,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, serviceAccounts)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createServiceAccount(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Slug        string              `json:"slug"`
+		Name        string              `json:"name"`
+		Description string              `json:"description"`
+		V           validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.Description = strings.TrimSpace(input.Description)
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(input.Slug != "", "slug", "Slug is required.")
+	if input.Slug != "" {
+		input.V.CheckField(isValidSlug(input.Slug), "slug", "Slug may only contain lowercase letters, numbers, and hyphens.")
+		input.V.CheckField(len(input.Slug) <= maxOrganizationSlugLength, "slug", "Slug must be 64 characters or fewer.")
+	}
+	input.V.CheckField(len(input.Description) <= maxServiceAccountDescriptionLength, "description", "Description must be 500 characters or fewer.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	org := contextGetOrg(r)
+	creator := contextGetAccount(r)
+	serviceAccount, err := app.db.CreateServiceAccount(r.Context(), org.ID, input.Slug, input.Name, input.Description, &creator.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			app.failedDuplicateField(w, r, "slug", "A service account with this slug already exists in this organization.")
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "service account created", slog.Int64("org_id", org.ID), slog.Int64("service_account_id", serviceAccount.AccountID), slog.String("service_account_slug", serviceAccount.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.service_account.create", "service_account", serviceAccount.AccountID, map[string]any{"slug": serviceAccount.Slug}))
+	err = response.JSON(w, http.StatusCreated, serviceAccount)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// serviceAccountFromRequest loads the service account named by
+// {service_account_id} within the organization in context. It writes a 404
+// and returns false when there is none.
+func (app *application) serviceAccountFromRequest(w http.ResponseWriter, r *http.Request) (database.ServiceAccount, bool) {
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "service_account_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return database.ServiceAccount{}, false
+	}
+	org := contextGetOrg(r)
+	serviceAccount, found, err := app.db.GetServiceAccount(r.Context(), org.ID, accountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return database.ServiceAccount{}, false
+	}
+	if !found {
+		app.notFound(w, r)
+		return database.ServiceAccount{}, false
+	}
+	return serviceAccount, true
+}
+
+func (app *application) getServiceAccount(w http.ResponseWriter, r *http.Request) {
+	serviceAccount, ok := app.serviceAccountFromRequest(w, r)
+	if !ok {
+		return
+	}
+	err := response.JSON(w, http.StatusOK, serviceAccount)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	serviceAccount, ok := app.serviceAccountFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	org := contextGetOrg(r)
+	admin := contextGetAccount(r)
+	err := app.db.DeleteServiceAccount(r.Context(), org.ID, serviceAccount.AccountID, &admin.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.connManager.RemoveForOrgAccount(strconv.FormatInt(org.ID, 10), strconv.FormatInt(serviceAccount.AccountID, 10))
+	app.enforcer.InvalidatePrincipals(org.ID, serviceAccount.AccountID)
+
+	app.logInfo(r, "service account deleted", slog.Int64("org_id", org.ID), slog.Int64("service_account_id", serviceAccount.AccountID), slog.String("service_account_slug", serviceAccount.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.service_account.delete", "service_account", serviceAccount.AccountID, map[string]any{"slug": serviceAccount.Slug}))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listServiceAccountTokens(w http.ResponseWriter, r *http.Request) {
+	serviceAccount, ok := app.serviceAccountFromRequest(w, r)
+	if !ok {
+		return
+	}
+	q, errs := readListQuery(r.URL.Query(), map[string]string{
+		"created_at": "created_at",
+	})
+	if len(errs) != 0 {
+		app.failedValidation(w, r, fieldErrors(errs))
+		return
+	}
+
+	tokens, err := app.db.ListAPITokensPage(r.Context(), database.ListAPITokensParams{
+		AccountID: serviceAccount.AccountID,
+		Page:      q.Page,
+		PageSize:  q.PageSize,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, tokens)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createServiceAccountToken(w http.ResponseWriter, r *http.Request) {
+	serviceAccount, ok := app.serviceAccountFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input apiTokenInput
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.validate()
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	org := contextGetOrg(r)
+	creator := contextGetAccount(r)
+	created, err := app.issueAPIToken(r.Context(), serviceAccount.AccountID, &org.ID, input, creator.ID, false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "service account token created", slog.Int64("org_id", org.ID), slog.Int64("service_account_id", serviceAccount.AccountID), slog.String("api_token_id", created.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.service_account.token.create", "service_account", serviceAccount.AccountID, map[string]any{
+		"api_token_id": created.ID,
+		"name":         created.Name,
+		"scopes":       created.Scopes,
+		"expires_at":   created.ExpiresAt,
+	}))
+	err = response.JSON(w, http.StatusCreated, created)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) revokeServiceAccountToken(w http.ResponseWriter, r *http.Request) {
+	serviceAccount, ok := app.serviceAccountFromRequest(w, r)
+	if !ok {
+		return
+	}
+	tokenID := strings.TrimSpace(chi.URLParam(r, "token_id"))
+	apiToken, found, err := app.db.GetAPIToken(r.Context(), tokenID, serviceAccount.AccountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || apiToken.RevokedAt != nil {
+		app.notFound(w, r)
+		return
+	}
+	if err = app.db.RevokeAPIToken(r.Context(), apiToken.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	org := contextGetOrg(r)
+	app.logInfo(r, "service account token revoked", slog.Int64("org_id", org.ID), slog.Int64("service_account_id", serviceAccount.AccountID), slog.String("api_token_id", apiToken.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.service_account.token.revoke", "service_account", serviceAccount.AccountID, map[string]any{"api_token_id": apiToken.ID}))
+	w.WriteHeader(http.StatusNoContent)
+}
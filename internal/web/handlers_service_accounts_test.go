@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+)
+
+func createServiceAccountForTest(t *testing.T, app *application, orgSlug, slug, ownerToken string) int64 {
+	t.Helper()
+
+	res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+orgSlug+"/service-accounts", map[string]any{
+		"slug": slug,
+		"name": "Deploy Bot",
+	}, ownerToken), app.routes())
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create service account: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	return int64(res.BodyFields["account_id"].(float64))
+}
+
+func TestServiceAccountIsBindablePrincipal(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sa-owner"), "SA Owner", "SA Org")
+
+	accountID := createServiceAccountForTest(t, app, org.Slug, "deploy-bot", ownerToken)
+	base := "/api/v1/orgs/" + org.Slug + "/service-accounts/" + strconv.FormatInt(accountID, 10)
+
+	member := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/members/"+strconv.FormatInt(accountID, 10), nil, ownerToken), app.routes())
+	assert.Equal(t, member.StatusCode, http.StatusOK)
+	assert.Equal(t, member.BodyFields["kind"].(string), database.AccountKindService)
+
+	_, plaintext := createAPITokenForTest(t, app, base+"/tokens", ownerToken, map[string]any{
+		"name":   "ci",
+		"scopes": []string{"read"},
+	})
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/roles", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+
+	roleID := createRoleForTest(t, app, org.ID, nil, "org", "policy:read")
+	grant := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+org.Slug+"/policies", map[string]any{
+		"role_id":      roleID,
+		"subject_type": "account",
+		"subject_id":   accountID,
+	}, ownerToken), app.routes())
+	assert.Equal(t, grant.StatusCode, http.StatusNoContent)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/roles", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = send(t, newAuthRequest(t, http.MethodPost, base+"/tokens", map[string]any{"name": "more", "scopes": []string{"write"}}, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+
+	del := send(t, newAuthRequest(t, http.MethodDelete, base, nil, ownerToken), app.routes())
+	assert.Equal(t, del.StatusCode, http.StatusNoContent)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, accountID)
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+}
+
+func TestServiceAccountTokenIsScopedToItsOrganization(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sa-scope-owner"), "SA Scope Owner", "SA Scope Org")
+	_, _, otherOrg := seedOrgOwner(t, app, uniqueEmail(t, "sa-other-owner"), "SA Other Owner", "SA Other Org")
+
+	accountID := createServiceAccountForTest(t, app, org.Slug, "reporter", ownerToken)
+	_, plaintext := createAPITokenForTest(t, app, "/api/v1/orgs/"+org.Slug+"/service-accounts/"+strconv.FormatInt(accountID, 10)+"/tokens", ownerToken, map[string]any{
+		"name":   "ci",
+		"scopes": []string{"read"},
+	})
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug+"/", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+otherOrg.Slug+"/", nil, plaintext), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+
+	for _, path := range []string{"/api/v1/me", "/api/v1/me/workspaces", "/api/v1/orgs/" + org.Slug + "-other/"} {
+		res = send(t, newAuthRequest(t, http.MethodGet, path, nil, plaintext), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestServiceAccountCannotBeRemovedAsMember(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sa-member-owner"), "SA Member Owner", "SA Member Org")
+
+	accountID := createServiceAccountForTest(t, app, org.Slug, "builder", ownerToken)
+	res := send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/orgs/"+org.Slug+"/members/"+strconv.FormatInt(accountID, 10), nil, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+
+	duplicate := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/orgs/"+org.Slug+"/service-accounts", map[string]any{
+		"slug": "builder",
+		"name": "Builder Again",
+	}, ownerToken), app.routes())
+	assert.Equal(t, duplicate.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, duplicate, "slug")
+}
@@ -0,0 +1,830 @@
+package web
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/oidc"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const (
+	ssoProviderOIDC      = "oidc"
+	ssoCallbackPath      = "/api/v1/auth/sso/callback"
+	ssoLoginStateTTL     = 10 * time.Minute
+	defaultSSOGroupClaim = "groups"
+
+	maxSSODisplayNameLength = 100
+	maxSSOGroupMappings     = 200
+)
+
+// Reasons the callback reports to the login page as ?sso_error=.
+const (
+	ssoErrorProviderDenied      = "provider_denied"
+	ssoErrorInvalidState        = "invalid_state"
+	ssoErrorProviderUnavailable = "provider_unavailable"
+	ssoErrorInvalidIDToken      = "invalid_id_token"
+	ssoErrorEmailNotVerified    = "email_not_verified"
+	ssoErrorAccountNotLinkable  = "account_not_linkable"
+	ssoErrorAccountInactive     = "account_inactive"
+	ssoErrorLinkPending         = "link_pending_confirmation"
+	ssoErrorNotOrgMember        = "not_org_member"
+)
+
+var defaultSSOScopes = []string{"email", "profile"}
+
+// orgOIDCSettings is the JSON stored in org_idp_configs.config. Only the
+// client secret is encrypted; the rest is needed to render the settings page.
+type orgOIDCSettings struct {
+	Issuer                string   `json:"issuer"`
+	ClientID              string   `json:"client_id"`
+	ClientSecretEncrypted string   `json:"client_secret_encrypted,omitempty"`
+	Scopes                []string `json:"scopes,omitempty"`
+	GroupsClaim           string   `json:"groups_claim,omitempty"`
+	TrustMFA              bool     `json:"trust_mfa,omitempty"`
+}
+
+// ssoProvider is a resolved provider ready for a sign-in. OrgID is nil for the
+// instance provider.
+type ssoProvider struct {
+	OrgID        *int64
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	GroupsClaim  string
+	TrustMFA     bool
+}
+
+type ssoLoginError struct {
+	reason string
+}
+
+func (e ssoLoginError) Error() string {
+	return "sso login failed: " + e.reason
+}
+
+func (app *application) oidcClient() *oidc.Client {
+	if app.oidc != nil {
+		return app.oidc
+	}
+	return oidc.NewClient(nil)
+}
+
+func (app *application) ssoRedirectURI(ctx context.Context) (string, error) {
+	settings, err := app.instanceSettings(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(settings.BaseURL, "/") + ssoCallbackPath, nil
+}
+
+// ssoProviderFor resolves the organization's provider, or the instance
+// provider when orgID is nil. It reports false when none is configured.
+func (app *application) ssoProviderFor(ctx context.Context, orgID *int64) (ssoProvider, bool, error) {
+	if orgID == nil {
+		configured := app.config.SSO.OIDC
+		if strings.TrimSpace(configured.Issuer) == "" {
+			return ssoProvider{}, false, nil
+		}
+		scopes := configured.Scopes
+		if len(scopes) == 0 {
+			scopes = defaultSSOScopes
+		}
+		return ssoProvider{
+			Issuer:       configured.Issuer,
+			ClientID:     configured.ClientID,
+			ClientSecret: configured.ClientSecret,
+			Scopes:       scopes,
+			TrustMFA:     configured.TrustMFA,
+		}, true, nil
+	}
+
+	config, found, err := app.db.GetOrgIDPConfig(ctx, *orgID)
+	if err != nil || !found || !config.IsActive || config.Provider != ssoProviderOIDC {
+		return ssoProvider{}, false, err
+	}
+	var settings orgOIDCSettings
+	if err := json.Unmarshal([]byte(config.Config), &settings); err != nil {
+		return ssoProvider{}, false, err
+	}
+	provider := ssoProvider{
+		OrgID:       orgID,
+		Issuer:      settings.Issuer,
+		ClientID:    settings.ClientID,
+		Scopes:      settings.Scopes,
+		GroupsClaim: settings.GroupsClaim,
+		TrustMFA:    settings.TrustMFA,
+	}
+	if settings.ClientSecretEncrypted != "" {
+		provider.ClientSecret, err = app.keyring.Decrypt(settings.ClientSecretEncrypted)
+		if err != nil {
+			return ssoProvider{}, false, err
+		}
+	}
+	return provider, true, nil
+}
+
+// isSafeReturnPath accepts only same-origin absolute paths, mirroring the
+// login page's own redirect check.
+func isSafeReturnPath(value string) bool {
+	if !strings.HasPrefix(value, "/") || strings.HasPrefix(value, "//") || strings.Contains(value, `\`) {
+		return false
+	}
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// startSSOLogin redirects the browser to the identity provider. ?org=<slug>
+// selects the organization's provider; without it the instance provider is
+// used. ?return_to= is where the browser lands after signing in.
+func (app *application) startSSOLogin(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	returnTo := query.Get("return_to")
+	if returnTo != "" && !isSafeReturnPath(returnTo) {
+		v := validator.Validator{}
+		v.AddFieldError("return_to", "Return path must be a path on this site.")
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	var orgID *int64
+	if slug := query.Get("org"); slug != "" {
+		org, found, err := app.db.GetOrgBySlug(r.Context(), slug)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !found {
+			app.notFound(w, r)
+			return
+		}
+		orgID = &org.ID
+	} else {
+		configured, err := app.db.HasAnyInstanceAdmin(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !configured {
+			app.errorMessage(w, r, http.StatusForbidden, "Instance setup is not complete.", nil)
+			return
+		}
+	}
+
+	provider, found, err := app.ssoProviderFor(r.Context(), orgID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+
+	metadata, err := app.oidcClient().Discover(r.Context(), provider.Issuer)
+	if err != nil {
+		app.logWarn(r, "sso discovery failed", slog.String("issuer", provider.Issuer), slog.String("error", err.Error()))
+		app.errorMessage(w, r, http.StatusBadGateway, "The identity provider could not be reached.", nil)
+		return
+	}
+	redirectURI, err := app.ssoRedirectURI(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	state, stateHash, err := token.Generate()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	nonce, _, err := token.Generate()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	verifier, _, err := token.Generate()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.db.InsertOIDCLoginState(r.Context(), database.OIDCLoginState{
+		ID:           stateHash,
+		OrgID:        orgID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ReturnTo:     returnTo,
+		ExpiresAt:    time.Now().Add(ssoLoginStateTTL),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, oidc.AuthCodeURL(metadata, oidc.AuthRequest{
+		ClientID:      provider.ClientID,
+		RedirectURI:   redirectURI,
+		Scopes:        provider.Scopes,
+		State:         state,
+		Nonce:         nonce,
+		CodeChallenge: oidc.S256Challenge(verifier),
+	}), http.StatusFound)
+}
+
+// completeSSOLogin handles the provider's redirect back. On success it starts
+// an auth session exactly like a password sign-in (the refresh cookie is set
+// and the client obtains an access token from /auth/refresh) and sends the
+// browser on to the requested page. Failures go to the login page with an
+// sso_error reason.
+func (app *application) completeSSOLogin(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		app.logWarn(r, "sso login rejected by provider", slog.String("error", providerError), slog.String("error_description", query.Get("error_description")))
+		app.redirectSSOFailure(w, r, ssoErrorProviderDenied)
+		return
+	}
+
+	state, found, err := app.db.ConsumeOIDCLoginState(r.Context(), token.Hash(query.Get("state")))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || query.Get("code") == "" {
+		app.logWarn(r, "sso login rejected", slog.String("reason", ssoErrorInvalidState))
+		app.redirectSSOFailure(w, r, ssoErrorInvalidState)
+		return
+	}
+
+	provider, found, err := app.ssoProviderFor(r.Context(), state.OrgID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.redirectSSOFailure(w, r, ssoErrorProviderUnavailable)
+		return
+	}
+	redirectURI, err := app.ssoRedirectURI(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	client := app.oidcClient()
+	rawIDToken, err := client.Exchange(r.Context(), provider.Issuer, oidc.ExchangeRequest{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURI:  redirectURI,
+		Code:         query.Get("code"),
+		CodeVerifier: state.CodeVerifier,
+	})
+	if err != nil {
+		app.logWarn(r, "sso code exchange failed", slog.String("issuer", provider.Issuer), slog.String("error", err.Error()))
+		app.redirectSSOFailure(w, r, ssoErrorProviderUnavailable)
+		return
+	}
+	idToken, err := client.VerifyIDToken(r.Context(), provider.Issuer, rawIDToken, provider.ClientID, state.Nonce)
+	if err != nil {
+		app.logWarn(r, "sso id token rejected", slog.String("issuer", provider.Issuer), slog.String("error", err.Error()))
+		app.redirectSSOFailure(w, r, ssoErrorInvalidIDToken)
+		return
+	}
+
+	account, outcome, err := app.resolveSSOAccount(r.Context(), provider, idToken)
+	if err != nil {
+		var loginErr ssoLoginError
+		if errors.As(err, &loginErr) {
+			app.logWarn(r, "sso login rejected", slog.String("issuer", provider.Issuer), slog.String("reason", loginErr.reason))
+			app.redirectSSOFailure(w, r, loginErr.reason)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	if provider.OrgID != nil {
+		groups := idToken.StringList(cmp.Or(provider.GroupsClaim, defaultSSOGroupClaim))
+		changed, err := app.db.SyncSSOTeamMemberships(r.Context(), *provider.OrgID, account.ID, groups)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if changed && app.enforcer != nil {
+			app.enforcer.InvalidatePrincipals(*provider.OrgID, account.ID)
+		}
+	}
+
+	signIn := database.AuthSessionSignIn{SSOIssuer: provider.Issuer}
+	if ssoPresentedMFA(provider, idToken) {
+		signIn.MFAMethod = database.MFAMethodSSO
+	}
+	_, authSessionID, err := app.issueAuthSession(w, r, account, signIn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logInfo(r, "account logged in with sso", slog.Int64("account_id", account.ID), slog.String("auth_session_id", authSessionID), slog.String("issuer", provider.Issuer), slog.String("outcome", outcome))
+	app.recordAudit(r, audit.Event{
+		ActorAccountID: &account.ID,
+		OrgID:          provider.OrgID,
+		Action:         "auth.sso.login",
+		ResourceType:   "auth_session",
+		ResourceID:     authSessionID,
+		Details:        map[string]any{"issuer": provider.Issuer, "subject": idToken.Subject, "outcome": outcome, "mfa": signIn.MFAMethod != ""},
+	})
+
+	returnTo := state.ReturnTo
+	if returnTo == "" {
+		returnTo = "/"
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+// ssoPresentedMFA reports whether an SSO sign-in counts as having presented a
+// second factor: the instance provider is trusted to enforce one, or the ID
+// token's amr claim (RFC 8176) says "mfa" or lists more than one
+// authentication method. An organization's trust_mfa is not honored here
+// because it would then satisfy requirements beyond that organization's own;
+// see orgProviderVouchesMFA.
+func ssoPresentedMFA(provider ssoProvider, idToken oidc.IDToken) bool {
+	if provider.OrgID == nil && provider.TrustMFA {
+		return true
+	}
+	methods := idToken.StringList("amr")
+	if slices.Contains(methods, "mfa") {
+		return true
+	}
+	slices.Sort(methods)
+	return len(slices.Compact(methods)) > 1
+}
+
+func (app *application) redirectSSOFailure(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, "/login?sso_error="+url.QueryEscape(reason), http.StatusFound)
+}
+
+// resolveSSOAccount finds or creates the account for a verified ID token. The
+// outcome is "existing", "linked" or "provisioned".
+//
+// An identity seen before signs in as its linked account once the link is
+// confirmed, and through an organization provider only while the account is
+// still a member of that organization. Otherwise the provider must assert a
+// verified email: an existing account with that email is linked, and if there
+// is none an account is provisioned. An organization provider can only
+// propose links to accounts that already belong to its organization, and the
+// account holder must confirm the link before it signs in, so an org admin
+// cannot take over an account by configuring a provider that asserts its
+// email. Accounts provisioned through an organization provider become members
+// of that organization.
+func (app *application) resolveSSOAccount(ctx context.Context, provider ssoProvider, idToken oidc.IDToken) (database.Account, string, error) {
+	identity, found, err := app.db.GetAccountIdentity(ctx, idToken.Issuer, idToken.Subject)
+	if err != nil {
+		return database.Account{}, "", err
+	}
+	if found {
+		if !identity.Confirmed() {
+			return database.Account{}, "", ssoLoginError{ssoErrorLinkPending}
+		}
+		account, found, err := app.db.GetAccount(ctx, identity.AccountID)
+		if err != nil {
+			return database.Account{}, "", err
+		}
+		if !found || !account.IsActive {
+			return database.Account{}, "", ssoLoginError{ssoErrorAccountInactive}
+		}
+		if provider.OrgID != nil {
+			isMember, err := app.db.IsOrgMember(ctx, *provider.OrgID, account.ID)
+			if err != nil {
+				return database.Account{}, "", err
+			}
+			if !isMember {
+				return database.Account{}, "", ssoLoginError{ssoErrorNotOrgMember}
+			}
+		}
+		if err := app.db.TouchAccountIdentity(ctx, identity.ID, idToken.Email); err != nil {
+			return database.Account{}, "", err
+		}
+		return account, "existing", nil
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified || !validator.IsEmail(idToken.Email) {
+		return database.Account{}, "", ssoLoginError{ssoErrorEmailNotVerified}
+	}
+
+	account, found, err := app.db.GetAccountByEmail(ctx, idToken.Email)
+	if err != nil {
+		return database.Account{}, "", err
+	}
+	if found {
+		if account.IsService() {
+			return database.Account{}, "", ssoLoginError{ssoErrorAccountNotLinkable}
+		}
+		if !account.IsActive {
+			return database.Account{}, "", ssoLoginError{ssoErrorAccountInactive}
+		}
+		if provider.OrgID != nil {
+			isMember, err := app.db.IsOrgMember(ctx, *provider.OrgID, account.ID)
+			if err != nil {
+				return database.Account{}, "", err
+			}
+			if !isMember {
+				return database.Account{}, "", ssoLoginError{ssoErrorAccountNotLinkable}
+			}
+			if _, err := app.db.RequestAccountIdentityLink(ctx, *provider.OrgID, account.ID, idToken.Issuer, idToken.Subject, idToken.Email); err != nil {
+				return database.Account{}, "", err
+			}
+			return database.Account{}, "", ssoLoginError{ssoErrorLinkPending}
+		}
+		if _, err := app.db.LinkAccountIdentity(ctx, account.ID, idToken.Issuer, idToken.Subject, idToken.Email); err != nil {
+			return database.Account{}, "", err
+		}
+		return account, "linked", nil
+	}
+
+	name := strings.TrimSpace(idToken.Name)
+	if name == "" {
+		name = idToken.Email
+	}
+	account, err = app.db.ProvisionSSOAccount(ctx, database.ProvisionSSOAccountParams{
+		Email:   idToken.Email,
+		Name:    name,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		OrgID:   provider.OrgID,
+	})
+	if err != nil {
+		return database.Account{}, "", err
+	}
+	return account, "provisioned", nil
+}
+
+// orgSSOResponse is the organization's provider as shown to admins. The
+// client secret is never returned.
+type orgSSOResponse struct {
+	Provider        string                     `json:"provider"`
+	DisplayName     string                     `json:"display_name"`
+	Issuer          string                     `json:"issuer"`
+	ClientID        string                     `json:"client_id"`
+	ClientSecretSet bool                       `json:"client_secret_set"`
+	Scopes          []string                   `json:"scopes"`
+	GroupsClaim     string                     `json:"groups_claim"`
+	TrustMFA        bool                       `json:"trust_mfa"`
+	SSORequired     bool                       `json:"sso_required"`
+	RedirectURI     string                     `json:"redirect_uri"`
+	GroupMappings   []database.SSOGroupMapping `json:"group_mappings"`
+	UpdatedAt       time.Time                  `json:"updated_at"`
+}
+
+func (app *application) orgSSOResponse(ctx context.Context, config database.OrgIDPConfig) (orgSSOResponse, error) {
+	var settings orgOIDCSettings
+	if err := json.Unmarshal([]byte(config.Config), &settings); err != nil {
+		return orgSSOResponse{}, err
+	}
+	mappings, err := app.db.ListSSOGroupMappings(ctx, config.OrgID)
+	if err != nil {
+		return orgSSOResponse{}, err
+	}
+	redirectURI, err := app.ssoRedirectURI(ctx)
+	if err != nil {
+		return orgSSOResponse{}, err
+	}
+	return orgSSOResponse{
+		Provider:        config.Provider,
+		DisplayName:     config.DisplayName,
+		Issuer:          settings.Issuer,
+		ClientID:        settings.ClientID,
+		ClientSecretSet: settings.ClientSecretEncrypted != "",
+		Scopes:          settings.Scopes,
+		GroupsClaim:     cmp.Or(settings.GroupsClaim, defaultSSOGroupClaim),
+		TrustMFA:        settings.TrustMFA,
+		SSORequired:     config.SSORequired,
+		RedirectURI:     redirectURI,
+		GroupMappings:   mappings,
+		UpdatedAt:       config.UpdatedAt,
+	}, nil
+}
+
+func (app *application) getOrgSSO(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	config, found, err := app.db.GetOrgIDPConfig(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+	payload, err := app.orgSSOResponse(r.Context(), config)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, payload)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateOrgSSO creates or replaces the organization's OpenID Connect
+// provider. Omitting client_secret keeps the stored one. Requiring SSO is only
+// accepted from a session signed in through the same issuer, so an admin
+// cannot lock everyone out with a provider that does not work.
+func (app *application) updateOrgSSO(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		DisplayName   string   `json:"display_name"`
+		Issuer        string   `json:"issuer"`
+		ClientID      string   `json:"client_id"`
+		ClientSecret  *string  `json:"client_secret"`
+		Scopes        []string `json:"scopes"`
+		GroupsClaim   string   `json:"groups_claim"`
+		TrustMFA      bool     `json:"trust_mfa"`
+		SSORequired   bool     `json:"sso_required"`
+		GroupMappings []struct {
+			Group    string `json:"group"`
+			TeamSlug string `json:"team_slug"`
+		} `json:"group_mappings"`
+		V validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	org := contextGetOrg(r)
+	existing, exists, err := app.db.GetOrgIDPConfig(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	var settings orgOIDCSettings
+	if exists {
+		if err := json.Unmarshal([]byte(existing.Config), &settings); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	input.DisplayName = strings.TrimSpace(input.DisplayName)
+	input.Issuer = strings.TrimSpace(input.Issuer)
+	input.ClientID = strings.TrimSpace(input.ClientID)
+	input.GroupsClaim = strings.TrimSpace(input.GroupsClaim)
+	input.V.CheckField(input.DisplayName != "", "display_name", "Display name is required.")
+	input.V.CheckField(len(input.DisplayName) <= maxSSODisplayNameLength, "display_name", "Display name must be 100 characters or fewer.")
+	input.V.CheckField(input.Issuer != "" && validator.IsURL(input.Issuer), "issuer", "Issuer must be a valid URL.")
+	input.V.CheckField(input.ClientID != "", "client_id", "Client ID is required.")
+	input.V.CheckField(len(input.GroupMappings) <= maxSSOGroupMappings, "group_mappings", "At most 200 group mappings are allowed.")
+	for _, scope := range input.Scopes {
+		input.V.CheckField(scope != "" && !strings.ContainsAny(scope, " \t\n"), "scopes", "Scopes must be single words.")
+	}
+
+	mappings := make([]database.SSOGroupMapping, 0, len(input.GroupMappings))
+	for _, mapping := range input.GroupMappings {
+		if strings.TrimSpace(mapping.Group) == "" {
+			input.V.AddFieldError("group_mappings", "Every group mapping needs a group.")
+			continue
+		}
+		team, found, err := app.db.GetTeam(r.Context(), org.ID, mapping.TeamSlug)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !found {
+			input.V.AddFieldError("group_mappings", "Group mappings must name teams in this organization.")
+			continue
+		}
+		mappings = append(mappings, database.SSOGroupMapping{GroupName: mapping.Group, TeamID: team.ID})
+	}
+
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	if _, err := app.oidcClient().Discover(r.Context(), input.Issuer); err != nil {
+		app.logWarn(r, "sso discovery failed", slog.String("issuer", input.Issuer), slog.String("error", err.Error()))
+		input.V.AddFieldError("issuer", "The issuer's OpenID Connect discovery document could not be loaded.")
+		app.failedValidation(w, r, input.V)
+		return
+	}
+	if input.SSORequired && contextGetAuthSession(r).SSOIssuer != input.Issuer {
+		input.V.AddFieldError("sso_required", "Sign in through this identity provider before requiring it.")
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	if input.Issuer != settings.Issuer || input.ClientID != settings.ClientID {
+		// A secret belongs to one client registration.
+		settings.ClientSecretEncrypted = ""
+	}
+	if input.ClientSecret != nil {
+		settings.ClientSecretEncrypted = ""
+		if *input.ClientSecret != "" {
+			settings.ClientSecretEncrypted, err = app.keyring.Encrypt(*input.ClientSecret)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+	}
+	settings.Issuer = input.Issuer
+	settings.ClientID = input.ClientID
+	settings.Scopes = input.Scopes
+	if len(settings.Scopes) == 0 {
+		settings.Scopes = defaultSSOScopes
+	}
+	slices.Sort(settings.Scopes)
+	settings.Scopes = slices.Compact(settings.Scopes)
+	settings.GroupsClaim = input.GroupsClaim
+	settings.TrustMFA = input.TrustMFA
+	data, err := json.Marshal(settings)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	config, err := app.db.UpsertOrgIDPConfig(r.Context(), org.ID, ssoProviderOIDC, input.DisplayName, string(data), input.SSORequired)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.db.ReplaceSSOGroupMappings(r.Context(), org.ID, mappings); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "organization sso updated", slog.Int64("org_id", org.ID), slog.String("issuer", settings.Issuer), slog.Bool("sso_required", input.SSORequired))
+	app.recordAudit(r, orgAuditEvent(r, "org.sso.update", "organization", org.ID, map[string]any{
+		"issuer":         settings.Issuer,
+		"client_id":      settings.ClientID,
+		"sso_required":   input.SSORequired,
+		"trust_mfa":      input.TrustMFA,
+		"group_mappings": len(mappings),
+		"secret_changed": input.ClientSecret != nil,
+	}))
+	payload, err := app.orgSSOResponse(r.Context(), config)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, payload)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteOrgSSO(w http.ResponseWriter, r *http.Request) {
+	org := contextGetOrg(r)
+	_, found, err := app.db.GetOrgIDPConfig(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+	if err := app.db.DeleteOrgIDPConfig(r.Context(), org.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "organization sso deleted", slog.Int64("org_id", org.ID))
+	app.recordAudit(r, orgAuditEvent(r, "org.sso.delete", "organization", org.ID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// orgSSOIssuer returns the issuer members of org must sign in through, or ""
+// when the organization does not require SSO.
+func (app *application) orgSSOIssuer(ctx context.Context, orgID int64) (string, error) {
+	config, found, err := app.db.GetOrgIDPConfig(ctx, orgID)
+	if err != nil || !found || !config.IsActive || !config.SSORequired {
+		return "", err
+	}
+	var settings orgOIDCSettings
+	if err := json.Unmarshal([]byte(config.Config), &settings); err != nil {
+		return "", err
+	}
+	return settings.Issuer, nil
+}
+
+// orgProviderVouchesMFA reports whether the request's auth session was signed
+// in through the organization's active provider and that provider is set to
+// trust_mfa. API tokens never qualify.
+func (app *application) orgProviderVouchesMFA(r *http.Request, orgID int64) (bool, error) {
+	if _, ok := contextGetAPIToken(r); ok {
+		return false, nil
+	}
+	ssoIssuer := contextGetAuthSession(r).SSOIssuer
+	if ssoIssuer == "" {
+		return false, nil
+	}
+	config, found, err := app.db.GetOrgIDPConfig(r.Context(), orgID)
+	if err != nil || !found || !config.IsActive {
+		return false, err
+	}
+	var settings orgOIDCSettings
+	if err := json.Unmarshal([]byte(config.Config), &settings); err != nil {
+		return false, err
+	}
+	return settings.TrustMFA && settings.Issuer == ssoIssuer, nil
+}
+
+// satisfiesOrgSSO reports whether the request may access an organization that
+// requires signing in through issuer. Service account tokens are exempt since
+// they cannot sign in interactively, and so are instance admins so they can
+// repair a broken provider. Everyone else needs an auth session established
+// through the issuer; personal API tokens do not qualify.
+func (app *application) satisfiesOrgSSO(r *http.Request, account database.Account, issuer string) (bool, error) {
+	if _, ok := contextGetAPIToken(r); ok {
+		return account.IsService(), nil
+	}
+	if contextGetAuthSession(r).SSOIssuer == issuer {
+		return true, nil
+	}
+	return app.db.IsInstanceAdmin(r.Context(), account.ID)
+}
+
+// listAccountSSOLinks returns the organization providers waiting for the
+// account holder to let them sign in as this account.
+func (app *application) listAccountSSOLinks(w http.ResponseWriter, r *http.Request) {
+	links, err := app.db.ListPendingSSOLinks(r.Context(), contextGetAccount(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{"items": links})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// confirmAccountSSOLink lets an organization provider's identity sign in as
+// the account.
+func (app *application) confirmAccountSSOLink(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	identity, found, err := app.db.ConfirmAccountIdentityLink(r.Context(), account.ID, chi.URLParam(r, "identity_id"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "sso link confirmed", slog.Int64("account_id", account.ID), slog.String("identity_id", identity.ID), slog.String("issuer", identity.Issuer))
+	app.recordAudit(r, audit.Event{
+		OrgID:        identity.OrgID,
+		Action:       "account.sso_link.confirm",
+		ResourceType: "account",
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+		Details:      map[string]any{"identity_id": identity.ID, "issuer": identity.Issuer, "subject": identity.Subject},
+	})
+	err = response.JSON(w, http.StatusOK, identity)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// declineAccountSSOLink drops an organization provider's pending request to
+// sign in as the account.
+func (app *application) declineAccountSSOLink(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	identityID := chi.URLParam(r, "identity_id")
+	declined, err := app.db.DeclineAccountIdentityLink(r.Context(), account.ID, identityID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !declined {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "sso link declined", slog.Int64("account_id", account.ID), slog.String("identity_id", identityID))
+	app.recordAudit(r, audit.Event{
+		Action:       "account.sso_link.decline",
+		ResourceType: "account",
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+		Details:      map[string]any{"identity_id": identityID},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,333 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/oidc/oidctest"
+)
+
+// configureOrgSSOForTest points the organization at issuer as the owner.
+func configureOrgSSOForTest(t *testing.T, app *application, orgSlug, ownerToken string, issuer *oidctest.Issuer, extra map[string]any) testResponse {
+	t.Helper()
+	body := map[string]any{
+		"display_name":  "Test IdP",
+		"issuer":        issuer.URL,
+		"client_id":     issuer.ClientID,
+		"client_secret": issuer.ClientSecret,
+	}
+	for key, value := range extra {
+		body[key] = value
+	}
+	return send(t, newAuthRequest(t, http.MethodPut, "/api/v1/orgs/"+orgSlug+"/sso", body, ownerToken), app.routes())
+}
+
+// ssoLogin runs the browser side of a sign-in: start, authenticate at the
+// issuer with claims, and follow the callback. It returns the callback
+// response.
+func ssoLogin(t *testing.T, app *application, issuer *oidctest.Issuer, startQuery url.Values, claims map[string]any) testResponse {
+	t.Helper()
+
+	startRes := send(t, newTestRequest(t, http.MethodGet, "/api/v1/auth/sso/start?"+startQuery.Encode(), nil), app.routes())
+	assert.Equal(t, startRes.StatusCode, http.StatusFound)
+
+	callback, err := issuer.Authorize(startRes.Header.Get("Location"), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackURL, err := url.Parse(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, callbackURL.Path, ssoCallbackPath)
+	return send(t, newTestRequest(t, http.MethodGet, callbackURL.RequestURI(), nil), app.routes())
+}
+
+// ssoAccessToken exchanges the refresh cookie set by a successful sign-in for
+// an access token.
+func ssoAccessToken(t *testing.T, app *application, callbackRes testResponse) string {
+	t.Helper()
+	req := newTestRequest(t, http.MethodPost, "/api/v1/auth/refresh", nil)
+	req.AddCookie(extractRefreshCookie(t, callbackRes))
+	res := send(t, req, app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	return extractAccessToken(t, res)
+}
+
+// linkSSOIdentityForTest signs an existing member in through the
+// organization's provider, which only proposes the link, and confirms it as
+// the account holder with accountToken.
+func linkSSOIdentityForTest(t *testing.T, app *application, issuer *oidctest.Issuer, orgSlug, accountToken string, claims map[string]any) {
+	t.Helper()
+	res := ssoLogin(t, app, issuer, url.Values{"org": {orgSlug}}, claims)
+	assertSSOFailure(t, res, ssoErrorLinkPending)
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account/sso-links", nil, accountToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	for _, item := range res.BodyFields["items"].([]any) {
+		link := item.(map[string]any)
+		if link["issuer"] != issuer.URL {
+			continue
+		}
+		res = send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/sso-links/"+link["id"].(string)+"/confirm", nil, accountToken), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		return
+	}
+	t.Fatalf("no pending sso link for %s", issuer.URL)
+}
+
+func assertSSOFailure(t *testing.T, res testResponse, reason string) {
+	t.Helper()
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+	assert.Equal(t, res.Header.Get("Location"), "/login?sso_error="+reason)
+}
+
+func TestOrgSSOProvisionsAccountAndSyncsTeams(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sso-owner"), "SSO Owner", "SSO Org")
+	team, err := app.db.InsertTeam(context.Background(), org.ID, "dba", "DBA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, map[string]any{
+		"group_mappings": []map[string]any{{"group": "db-admins", "team_slug": "dba"}},
+	})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["client_secret_set"].(bool), true)
+	assert.Equal(t, res.BodyFields["redirect_uri"].(string), "https://www.example.com"+ssoCallbackPath)
+
+	email := uniqueEmail(t, "sso-new")
+	claims := map[string]any{
+		"sub":            "user-1",
+		"email":          email,
+		"email_verified": true,
+		"name":           "New User",
+		"groups":         []string{"db-admins"},
+	}
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}, "return_to": {"/workbench"}}, claims)
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+	assert.Equal(t, res.Header.Get("Location"), "/workbench")
+	accessToken := ssoAccessToken(t, app, res)
+
+	account, found, err := app.db.GetAccountByEmail(context.Background(), email)
+	if err != nil || !found {
+		t.Fatalf("provisioned account: found=%v err=%v", found, err)
+	}
+	assert.Nil(t, account.Password)
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, isMember)
+	teams, err := app.db.GetAccountTeams(context.Background(), org.ID, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(teams), 1)
+	assert.Equal(t, teams[0].ID, team.ID)
+
+	meRes := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/me", nil, accessToken), app.routes())
+	assert.Equal(t, meRes.StatusCode, http.StatusOK)
+
+	// Signing in again without the group removes the mapped membership and
+	// reuses the account.
+	claims["groups"] = []string{"other"}
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims)
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+	assert.Equal(t, res.Header.Get("Location"), "/")
+	teams, err = app.db.GetAccountTeams(context.Background(), org.ID, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(teams), 0)
+}
+
+func TestOrgSSOLinksOnlyExistingMembers(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sso-link-owner"), "SSO Owner", "SSO Link Org")
+	res := configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, nil)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	outsider := seedAccount(t, app, uniqueEmail(t, "sso-outsider"), "Outsider")
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, map[string]any{
+		"sub": "outsider", "email": outsider.Email, "email_verified": true,
+	})
+	assertSSOFailure(t, res, ssoErrorAccountNotLinkable)
+
+	member, memberToken := seedAccountWithToken(t, app, uniqueEmail(t, "sso-member"), "Member")
+	addOrgMemberDirect(t, app, org.Slug, member.Email)
+	claims := map[string]any{"sub": "member", "email": member.Email, "email_verified": false}
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims)
+	assertSSOFailure(t, res, ssoErrorEmailNotVerified)
+
+	// A matching member is only proposed a link, and the identity cannot sign
+	// in until the account holder confirms it.
+	claims["email_verified"] = true
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims)
+	assertSSOFailure(t, res, ssoErrorLinkPending)
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims)
+	assertSSOFailure(t, res, ssoErrorLinkPending)
+	identity, found, err := app.db.GetAccountIdentity(context.Background(), issuer.URL, "member")
+	if err != nil || !found {
+		t.Fatalf("pending identity: found=%v err=%v", found, err)
+	}
+	assert.Equal(t, identity.AccountID, member.ID)
+	assert.False(t, identity.Confirmed())
+
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account/sso-links", nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	items := res.BodyFields["items"].([]any)
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].(map[string]any)["org_slug"].(string), org.Slug)
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/account/sso-links/"+identity.ID, nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	_, found, err = app.db.GetAccountIdentity(context.Background(), issuer.URL, "member")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	linkSSOIdentityForTest(t, app, issuer, org.Slug, memberToken, claims)
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims)
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+	assert.Equal(t, res.Header.Get("Location"), "/")
+
+	// A member the organization removed does not rejoin by signing in.
+	if err := app.db.RemoveOrgMember(context.Background(), org.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, claims)
+	assertSSOFailure(t, res, ssoErrorNotOrgMember)
+	isMember, err := app.db.IsOrgMember(context.Background(), org.ID, member.ID)
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+}
+
+func TestOrgSSOCannotTakeOverInstanceAdmin(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	admin, _ := seedInstanceAdminAccount(t, app, uniqueEmail(t, "sso-admin"), "Admin")
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sso-takeover-owner"), "SSO Owner", "SSO Takeover Org")
+	addOrgMemberDirect(t, app, org.Slug, admin.Email)
+	res := configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, nil)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, map[string]any{
+		"sub": "attacker", "email": admin.Email, "email_verified": true,
+	})
+	assertSSOFailure(t, res, ssoErrorLinkPending)
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, map[string]any{
+		"sub": "attacker", "email": admin.Email, "email_verified": true,
+	})
+	assertSSOFailure(t, res, ssoErrorLinkPending)
+}
+
+func TestSSOCallbackRejectsUnknownOrReusedState(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	_, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sso-state-owner"), "SSO Owner", "SSO State Org")
+	res := configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, nil)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = send(t, newTestRequest(t, http.MethodGet, ssoCallbackPath+"?state=forged&code=code-1", nil), app.routes())
+	assertSSOFailure(t, res, ssoErrorInvalidState)
+
+	startRes := send(t, newTestRequest(t, http.MethodGet, "/api/v1/auth/sso/start?org="+org.Slug, nil), app.routes())
+	assert.Equal(t, startRes.StatusCode, http.StatusFound)
+	callback, err := issuer.Authorize(startRes.Header.Get("Location"), map[string]any{"sub": "someone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackURL, _ := url.Parse(callback)
+	res = send(t, newTestRequest(t, http.MethodGet, callbackURL.RequestURI(), nil), app.routes())
+	assertSSOFailure(t, res, ssoErrorEmailNotVerified)
+	res = send(t, newTestRequest(t, http.MethodGet, callbackURL.RequestURI(), nil), app.routes())
+	assertSSOFailure(t, res, ssoErrorInvalidState)
+
+	res = send(t, newTestRequest(t, http.MethodGet, "/api/v1/auth/sso/start?org="+org.Slug+"&return_to=//evil.example.com", nil), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "return_to")
+}
+
+func TestOrgSSORequiredEnforcement(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	owner, ownerToken, org := seedOrgOwner(t, app, uniqueEmail(t, "sso-required-owner"), "SSO Owner", "SSO Required Org")
+
+	// Requiring SSO needs a session established through the issuer.
+	res := configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, map[string]any{"sso_required": true})
+	assertValidationField(t, res, "sso_required")
+
+	res = configureOrgSSOForTest(t, app, org.Slug, ownerToken, issuer, nil)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	ownerClaims := map[string]any{"sub": "owner", "email": owner.Email, "email_verified": true}
+	linkSSOIdentityForTest(t, app, issuer, org.Slug, ownerToken, ownerClaims)
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, ownerClaims)
+	ownerSSOToken := ssoAccessToken(t, app, res)
+	res = configureOrgSSOForTest(t, app, org.Slug, ownerSSOToken, issuer, map[string]any{"sso_required": true})
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["sso_required"].(bool), true)
+
+	member, memberToken := seedAccountWithToken(t, app, uniqueEmail(t, "sso-required-member"), "Member")
+	addOrgMemberDirect(t, app, org.Slug, member.Email)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug, nil, memberToken), app.routes())
+	assertAPIError(t, res, apiErrorSSORequired, "This organization requires signing in through its identity provider.")
+
+	memberClaims := map[string]any{"sub": "member", "email": member.Email, "email_verified": true}
+	linkSSOIdentityForTest(t, app, issuer, org.Slug, memberToken, memberClaims)
+	res = ssoLogin(t, app, issuer, url.Values{"org": {org.Slug}}, memberClaims)
+	memberSSOToken := ssoAccessToken(t, app, res)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug, nil, memberSSOToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	// Instance admins keep access with a password session.
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug, nil, ownerToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = send(t, newAuthRequest(t, http.MethodDelete, "/api/v1/orgs/"+org.Slug+"/sso", nil, ownerSSOToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	res = send(t, newAuthRequest(t, http.MethodGet, "/api/v1/orgs/"+org.Slug, nil, memberToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestInstanceSSOProvisionsAccountWithoutOrg(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	issuer := oidctest.NewIssuer(t, "sqlwarden", "client-secret")
+	seedInstanceAdminAccount(t, app, uniqueEmail(t, "sso-instance-admin"), "Admin")
+
+	res := send(t, newTestRequest(t, http.MethodGet, "/api/v1/auth/sso/start", nil), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+
+	app.config.SSO.OIDC = OIDCProvider{Issuer: issuer.URL, ClientID: issuer.ClientID, ClientSecret: issuer.ClientSecret}
+	email := uniqueEmail(t, "sso-instance-user")
+	res = ssoLogin(t, app, issuer, url.Values{}, map[string]any{
+		"sub": "instance-user", "email": email, "email_verified": "true",
+	})
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+	assert.Equal(t, res.Header.Get("Location"), "/")
+	ssoAccessToken(t, app, res)
+
+	account, found, err := app.db.GetAccountByEmail(context.Background(), email)
+	if err != nil || !found {
+		t.Fatalf("provisioned account: found=%v err=%v", found, err)
+	}
+	sessions, err := app.db.ListAuthSessionsPage(context.Background(), database.ListAuthSessionsParams{AccountID: account.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.Items) != 1 || sessions.Items[0].SSOIssuer != issuer.URL {
+		t.Fatalf("auth sessions = %+v; want one SSO session", sessions.Items)
+	}
+}
@@ -81,6 +81,7 @@ func (app *application) createTeam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "team created", slog.Int64("org_id", org.ID), slog.Int64("team_id", team.ID), slog.String("team_slug", team.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.team.create", "team", team.ID, map[string]any{"slug": team.Slug}))
 	err = response.JSON(w, http.StatusCreated, team)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -145,6 +146,7 @@ func (app *application) updateTeam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.logInfo(r, "team updated", slog.Int64("org_id", org.ID), slog.Int64("team_id", team.ID), slog.String("team_slug", team.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.team.update", "team", team.ID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -166,6 +168,7 @@ func (app *application) deleteTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	app.logInfo(r, "team deleted", slog.Int64("org_id", org.ID), slog.Int64("team_id", team.ID), slog.String("team_slug", team.Slug))
+	app.recordAudit(r, orgAuditEvent(r, "org.team.delete", "team", team.ID, map[string]any{"slug": team.Slug}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -270,6 +273,7 @@ func (app *application) addTeamMember(w http.ResponseWriter, r *http.Request) {
 
 	app.enforcer.InvalidatePrincipals(org.ID, input.AccountID)
 	app.logInfo(r, "team member added", slog.Int64("org_id", org.ID), slog.Int64("team_id", team.ID), slog.Int64("target_account_id", input.AccountID))
+	app.recordAudit(r, orgAuditEvent(r, "org.team.member.add", "team", team.ID, map[string]any{"account_id": input.AccountID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -309,5 +313,6 @@ func (app *application) removeTeamMember(w http.ResponseWriter, r *http.Request)
 
 	app.enforcer.InvalidatePrincipals(org.ID, accountID)
 	app.logInfo(r, "team member removed", slog.Int64("org_id", org.ID), slog.Int64("team_id", team.ID), slog.Int64("target_account_id", accountID), slog.Int("affected_workspaces", len(workspaceIDs)))
+	app.recordAudit(r, orgAuditEvent(r, "org.team.member.remove", "team", team.ID, map[string]any{"account_id": accountID}))
 	w.WriteHeader(http.StatusNoContent)
 }
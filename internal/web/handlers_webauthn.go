@@ -0,0 +1,496 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/request"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/validator"
+	"github.com/sqlwarden/internal/webauthn"
+)
+
+// relyingParty returns the WebAuthn relying party for the instance's base URL
+// and the user verification policy for registration and second-factor use.
+// Passwordless sign-in always requires user verification.
+func (app *application) relyingParty(ctx context.Context) (webauthn.RelyingParty, webauthn.UserVerification, error) {
+	settings, err := app.instanceSettings(ctx)
+	if err != nil {
+		return webauthn.RelyingParty{}, "", err
+	}
+	rp, err := webauthn.RelyingPartyFromURL(settings.InstanceName, settings.BaseURL)
+	if err != nil {
+		return webauthn.RelyingParty{}, "", err
+	}
+	uv := webauthn.UserVerification(settings.WebAuthnUserVerification)
+	if !uv.Valid() {
+		uv = webauthn.UserVerificationPreferred
+	}
+	return rp, uv, nil
+}
+
+// webAuthnUserHandle is the opaque user handle credentials are registered
+// with. Discoverable credentials return it when signing in.
+func webAuthnUserHandle(accountID int64) webauthn.Bytes {
+	return binary.BigEndian.AppendUint64(nil, uint64(accountID))
+}
+
+func webAuthnCeremonyID(challenge webauthn.Bytes) string {
+	return token.Hash(challenge.String())
+}
+
+// startWebAuthnCeremony stores a new ceremony and returns its challenge.
+func (app *application) startWebAuthnCeremony(ctx context.Context, ceremony database.WebAuthnCeremony) (webauthn.Bytes, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	ceremony.ID = webAuthnCeremonyID(challenge)
+	ceremony.ExpiresAt = time.Now().Add(webauthn.Timeout)
+	if err := app.db.InsertWebAuthnCeremony(ctx, ceremony); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnCeremony finds and uses up the ceremony the response's
+// challenge belongs to. The challenge itself is verified later, against the
+// client data the authenticator signed.
+func (app *application) consumeWebAuthnCeremony(ctx context.Context, clientChallenge func() (webauthn.Bytes, error), purpose string) (database.WebAuthnCeremony, webauthn.Bytes, bool, error) {
+	challenge, err := clientChallenge()
+	if err != nil {
+		return database.WebAuthnCeremony{}, nil, false, nil
+	}
+	ceremony, found, err := app.db.ConsumeWebAuthnCeremony(ctx, webAuthnCeremonyID(challenge))
+	if err != nil || !found || ceremony.Purpose != purpose {
+		return database.WebAuthnCeremony{}, nil, false, err
+	}
+	return ceremony, challenge, true, nil
+}
+
+// accountMFAMethods lists the second factors the account can sign in with.
+func (app *application) accountMFAMethods(ctx context.Context, accountID int64) ([]string, error) {
+	methods := []string{}
+	mfa, found, err := app.db.GetAccountMFA(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if found && mfa.Enabled() {
+		methods = append(methods, database.MFAMethodTOTP, database.MFAMethodRecoveryCode)
+	}
+	credentials, err := app.db.CountWebAuthnCredentials(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if credentials > 0 {
+		methods = append(methods, database.MFAMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+func (app *application) listWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	credentials, err := app.db.ListWebAuthnCredentials(r.Context(), contextGetAccount(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{"items": credentials})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// startWebAuthnRegistration returns the options for navigator.credentials.create().
+func (app *application) startWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	rp, uv, err := app.relyingParty(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	existing, err := app.db.ListWebAuthnCredentials(r.Context(), account.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		id, err := webauthn.DecodeBytes(credential.CredentialID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		exclude = append(exclude, webauthn.NewCredentialDescriptor(id, credential.Transports))
+	}
+
+	challenge, err := app.startWebAuthnCeremony(r.Context(), database.WebAuthnCeremony{
+		Purpose:          database.WebAuthnCeremonyRegistration,
+		AccountID:        &account.ID,
+		UserVerification: string(uv),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	user := webauthn.User{ID: webAuthnUserHandle(account.ID), Name: account.Email, DisplayName: account.Name}
+	err = response.JSON(w, http.StatusOK, map[string]any{"public_key": rp.CreationOptions(user, challenge, exclude, uv)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name       string                         `json:"name"`
+		Credential *webauthn.RegistrationResponse `json:"credential"`
+		V          validator.Validator            `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(validator.MaxRunes(input.Name, 100), "name", "Name must be 100 characters or fewer.")
+	input.V.CheckField(input.Credential != nil, "credential", "Credential is required.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	account := contextGetAccount(r)
+	ceremony, challenge, found, err := app.consumeWebAuthnCeremony(r.Context(), input.Credential.Challenge, database.WebAuthnCeremonyRegistration)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	input.V.CheckField(found && ceremony.AccountID != nil && *ceremony.AccountID == account.ID, "credential", "The registration has expired. Start again.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	rp, _, err := app.relyingParty(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	verified, err := rp.VerifyRegistration(*input.Credential, challenge, webauthn.UserVerification(ceremony.UserVerification))
+	if err != nil {
+		app.logWarn(r, "webauthn registration rejected", slog.Int64("account_id", account.ID), slog.String("error", err.Error()))
+		input.V.AddFieldError("credential", "The credential could not be verified.")
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	credential, err := app.db.InsertWebAuthnCredential(r.Context(), database.WebAuthnCredential{
+		AccountID:      account.ID,
+		CredentialID:   verified.ID.String(),
+		PublicKey:      verified.PublicKey.String(),
+		Algorithm:      verified.Algorithm,
+		SignCount:      int64(verified.SignCount),
+		AAGUID:         verified.AAGUID.String(),
+		Transports:     verified.Transports,
+		Name:           input.Name,
+		BackupEligible: verified.BackupEligible,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			app.errorMessage(w, r, http.StatusConflict, "This credential is already registered.", nil)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "webauthn credential registered", slog.Int64("account_id", account.ID), slog.String("credential_id", credential.ID))
+	app.recordAudit(r, audit.Event{
+		Action:       "account.webauthn.register",
+		ResourceType: "webauthn_credential",
+		ResourceID:   credential.ID,
+		Details:      map[string]any{"name": credential.Name, "backup_eligible": credential.BackupEligible},
+	})
+	err = response.JSON(w, http.StatusCreated, credential)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) renameWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string              `json:"name"`
+		V    validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.V.CheckField(input.Name != "", "name", "Name is required.")
+	input.V.CheckField(validator.MaxRunes(input.Name, 100), "name", "Name must be 100 characters or fewer.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	renamed, err := app.db.RenameWebAuthnCredential(r.Context(), contextGetAccount(r).ID, chi.URLParam(r, "credential_id"), input.Name)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !renamed {
+		app.notFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) deleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	account := contextGetAccount(r)
+	credentialID := chi.URLParam(r, "credential_id")
+	deleted, err := app.db.DeleteWebAuthnCredential(r.Context(), account.ID, credentialID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !deleted {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logInfo(r, "webauthn credential removed", slog.Int64("account_id", account.ID), slog.String("credential_id", credentialID))
+	app.recordAudit(r, audit.Event{Action: "account.webauthn.remove", ResourceType: "webauthn_credential", ResourceID: credentialID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startWebAuthnLogin returns options for a passwordless sign-in. The allow
+// list is empty, so the browser offers the user's discoverable credentials.
+func (app *application) startWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	rp, _, err := app.relyingParty(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	challenge, err := app.startWebAuthnCeremony(r.Context(), database.WebAuthnCeremony{
+		Purpose:          database.WebAuthnCeremonyLogin,
+		UserVerification: string(webauthn.UserVerificationRequired),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{"public_key": rp.RequestOptions(challenge, nil, webauthn.UserVerificationRequired)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// completeWebAuthnLogin signs in with a passkey alone. The authenticator must
+// verify the user, so the credential counts as a second factor too.
+func (app *application) completeWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Credential *webauthn.AssertionResponse `json:"credential"`
+		V          validator.Validator         `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.V.CheckField(input.Credential != nil, "credential", "Credential is required.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	ceremony, challenge, found, err := app.consumeWebAuthnCeremony(r.Context(), input.Credential.Challenge, database.WebAuthnCeremonyLogin)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	credential, ok, err := app.verifyWebAuthnAssertion(r, *input.Credential, ceremony, challenge, 0)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !ok {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	account, found, err := app.db.GetAccount(r.Context(), credential.AccountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || !account.IsActive || account.IsService() {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+
+	accessToken, authSessionID, err := app.issueAuthSession(w, r, account, database.AuthSessionSignIn{MFAMethod: database.MFAMethodWebAuthn})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logInfo(r, "account logged in", slog.Int64("account_id", account.ID), slog.String("auth_session_id", authSessionID), slog.String("method", "webauthn"))
+	app.recordAudit(r, audit.Event{
+		ActorAccountID: &account.ID,
+		Action:         "auth.login",
+		ResourceType:   "auth_session",
+		ResourceID:     authSessionID,
+		Details:        map[string]any{"method": database.MFAMethodWebAuthn, "credential_id": credential.ID},
+	})
+
+	err = response.JSON(w, http.StatusOK, map[string]string{"access_token": accessToken})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// startWebAuthnMFA returns options for answering a password sign-in's MFA
+// challenge with one of the account's registered credentials.
+func (app *application) startWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken string              `json:"mfa_token"`
+		V        validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.V.CheckField(input.MFAToken != "", "mfa_token", "MFA token is required.")
+	if input.V.HasErrors() {
+		app.failedValidation(w, r, input.V)
+		return
+	}
+
+	mfaChallenge, found, err := app.db.GetMFALoginChallenge(r.Context(), token.Hash(input.MFAToken))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+	credentials, err := app.db.ListWebAuthnCredentials(r.Context(), mfaChallenge.AccountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if len(credentials) == 0 {
+		app.errorMessage(w, r, http.StatusConflict, "No security keys or passkeys are registered for this account.", nil)
+		return
+	}
+	allow := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		id, err := webauthn.DecodeBytes(credential.CredentialID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		allow = append(allow, webauthn.NewCredentialDescriptor(id, credential.Transports))
+	}
+
+	rp, uv, err := app.relyingParty(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	challenge, err := app.startWebAuthnCeremony(r.Context(), database.WebAuthnCeremony{
+		Purpose:          database.WebAuthnCeremonyMFA,
+		AccountID:        &mfaChallenge.AccountID,
+		MFAChallengeID:   mfaChallenge.ID,
+		UserVerification: string(uv),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = response.JSON(w, http.StatusOK, map[string]any{"public_key": rp.RequestOptions(challenge, allow, uv)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// verifyWebAuthnSecondFactor checks an assertion answering the MFA login
+// challenge with id mfaChallengeID for accountID.
+func (app *application) verifyWebAuthnSecondFactor(r *http.Request, res webauthn.AssertionResponse, mfaChallengeID string, accountID int64) (bool, error) {
+	ceremony, challenge, found, err := app.consumeWebAuthnCeremony(r.Context(), res.Challenge, database.WebAuthnCeremonyMFA)
+	if err != nil || !found || ceremony.MFAChallengeID != mfaChallengeID {
+		return false, err
+	}
+	_, ok, err := app.verifyWebAuthnAssertion(r, res, ceremony, challenge, accountID)
+	return ok, err
+}
+
+// verifyWebAuthnAssertion verifies res against the stored credential it names
+// and records its new sign counter. A non-zero accountID requires the
+// credential to belong to that account.
+func (app *application) verifyWebAuthnAssertion(r *http.Request, res webauthn.AssertionResponse, ceremony database.WebAuthnCeremony, challenge webauthn.Bytes, accountID int64) (database.WebAuthnCredential, bool, error) {
+	rawID := res.RawID
+	if len(rawID) == 0 {
+		var err error
+		if rawID, err = webauthn.DecodeBytes(res.ID); err != nil {
+			return database.WebAuthnCredential{}, false, nil
+		}
+	}
+	credential, found, err := app.db.GetWebAuthnCredentialByCredentialID(r.Context(), rawID.String())
+	if err != nil || !found {
+		return database.WebAuthnCredential{}, false, err
+	}
+	if accountID != 0 && credential.AccountID != accountID {
+		return database.WebAuthnCredential{}, false, nil
+	}
+	if len(res.Response.UserHandle) > 0 && !bytes.Equal(res.Response.UserHandle, webAuthnUserHandle(credential.AccountID)) {
+		return database.WebAuthnCredential{}, false, nil
+	}
+	publicKey, err := webauthn.DecodeBytes(credential.PublicKey)
+	if err != nil {
+		return database.WebAuthnCredential{}, false, err
+	}
+
+	rp, _, err := app.relyingParty(r.Context())
+	if err != nil {
+		return database.WebAuthnCredential{}, false, err
+	}
+	assertion, err := rp.VerifyAssertion(res, challenge, publicKey, uint32(credential.SignCount), webauthn.UserVerification(ceremony.UserVerification))
+	if errors.Is(err, webauthn.ErrSignCountRegression) {
+		app.logWarn(r, "webauthn sign counter did not increase; the authenticator may be cloned", slog.Int64("account_id", credential.AccountID), slog.String("credential_id", credential.ID))
+		return database.WebAuthnCredential{}, false, nil
+	}
+	if err != nil {
+		app.logWarn(r, "webauthn assertion rejected", slog.Int64("account_id", credential.AccountID), slog.String("error", err.Error()))
+		return database.WebAuthnCredential{}, false, nil
+	}
+	used, err := app.db.UseWebAuthnCredential(r.Context(), credential.ID, credential.SignCount, int64(assertion.SignCount))
+	if err != nil || !used {
+		return database.WebAuthnCredential{}, false, err
+	}
+	return credential, true, nil
+}
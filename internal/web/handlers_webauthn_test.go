@@ -0,0 +1,163 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/token"
+	"github.com/sqlwarden/internal/webauthn"
+	"github.com/sqlwarden/internal/webauthn/webauthntest"
+)
+
+// webAuthnBody converts a ceremony response to a request body field.
+func webAuthnBody(t *testing.T, v any) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// publicKeyOption decodes a base64url field of the public_key options in res.
+func publicKeyOption(t *testing.T, res testResponse, path ...string) webauthn.Bytes {
+	t.Helper()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("options: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	value := res.BodyFields["public_key"]
+	for _, key := range path {
+		value = value.(map[string]any)[key]
+	}
+	decoded, err := webauthn.DecodeBytes(value.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+// registerWebAuthnForTest registers a software authenticator to the token's
+// account.
+func registerWebAuthnForTest(t *testing.T, app *application, accessToken string) *webauthntest.Authenticator {
+	t.Helper()
+
+	rp, _, err := app.relyingParty(context.Background())
+	assert.Nil(t, err)
+	authenticator := webauthntest.New(t, rp.Origin, rp.ID)
+
+	res := send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/webauthn/registration", nil, accessToken), app.routes())
+	challenge := publicKeyOption(t, res, "challenge")
+	userHandle := publicKeyOption(t, res, "user", "id")
+
+	body := map[string]any{"name": "Laptop", "credential": webAuthnBody(t, authenticator.Register(t, challenge, userHandle))}
+	res = send(t, newAuthRequest(t, http.MethodPost, "/api/v1/account/webauthn/credentials", body, accessToken), app.routes())
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("register credential: got status %d, body %s", res.StatusCode, res.BodyBytes)
+	}
+	return authenticator
+}
+
+func TestWebAuthnPasswordlessLogin(t *testing.T) {
+	app := newTestApp(t)
+	setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "passkey@example.com", "Passkey", "securepass99")
+
+	accessToken := extractAccessToken(t, loginTestUser(t, app, "passkey@example.com", "securepass99"))
+	authenticator := registerWebAuthnForTest(t, app, accessToken)
+
+	res := send(t, newAuthRequest(t, http.MethodGet, "/api/v1/account/webauthn/credentials", nil, accessToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, len(res.BodyFields["items"].([]any)), 1)
+
+	login := func() testResponse {
+		res := send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/webauthn/login/options", nil), app.routes())
+		challenge := publicKeyOption(t, res, "challenge")
+		assertion := authenticator.Assert(t, challenge)
+		return send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/webauthn/login", map[string]any{"credential": webAuthnBody(t, assertion)}), app.routes())
+	}
+
+	res = login()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	claims, err := token.Verify(extractAccessToken(t, res), app.config.JWT.SecretKey)
+	assert.Nil(t, err)
+	account, _, err := app.db.GetAccountByEmail(context.Background(), "passkey@example.com")
+	assert.Nil(t, err)
+	authSession, _, err := app.db.GetAuthSession(context.Background(), claims.AuthSessionID, account.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, authSession.MFAMethod, database.MFAMethodWebAuthn)
+
+	authenticator.UserVerified = false
+	res = login()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	authenticator.UserVerified = true
+	authenticator.SignCount = 0
+	res = login()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestWebAuthnLoginChallengeIsSingleUse(t *testing.T) {
+	app := newTestApp(t)
+	setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "replay@example.com", "Replay", "securepass99")
+
+	accessToken := extractAccessToken(t, loginTestUser(t, app, "replay@example.com", "securepass99"))
+	authenticator := registerWebAuthnForTest(t, app, accessToken)
+
+	res := send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/webauthn/login/options", nil), app.routes())
+	body := map[string]any{"credential": webAuthnBody(t, authenticator.Assert(t, publicKeyOption(t, res, "challenge")))}
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/webauthn/login", body), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/webauthn/login", body), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestWebAuthnAsSecondFactor(t *testing.T) {
+	app := newTestApp(t)
+	setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "key@example.com", "Key", "securepass99")
+
+	accessToken := extractAccessToken(t, loginTestUser(t, app, "key@example.com", "securepass99"))
+	authenticator := registerWebAuthnForTest(t, app, accessToken)
+
+	res := loginTestUser(t, app, "key@example.com", "securepass99")
+	assert.Equal(t, res.BodyFields["mfa_required"], true)
+	assert.True(t, slices.Contains(res.BodyFields["mfa_methods"].([]any), any(database.MFAMethodWebAuthn)))
+	mfaToken := res.BodyFields["mfa_token"].(string)
+
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa/webauthn", map[string]any{"mfa_token": mfaToken}), app.routes())
+	challenge := publicKeyOption(t, res, "challenge")
+	other := webauthntest.New(t, authenticator.Origin, authenticator.RPID)
+	other.CredentialID = authenticator.CredentialID
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "webauthn": webAuthnBody(t, other.Assert(t, challenge))}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa/webauthn", map[string]any{"mfa_token": mfaToken}), app.routes())
+	assertion := authenticator.Assert(t, publicKeyOption(t, res, "challenge"))
+	res = send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/login/mfa", map[string]any{"mfa_token": mfaToken, "webauthn": webAuthnBody(t, assertion)}), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	credentials, err := app.db.ListWebAuthnCredentials(context.Background(), authSessionAccountID(t, app, extractAccessToken(t, res)))
+	assert.Nil(t, err)
+	assert.Equal(t, credentials[0].SignCount, int64(authenticator.SignCount))
+	assert.NotNil(t, credentials[0].LastUsedAt)
+}
+
+func authSessionAccountID(t *testing.T, app *application, accessToken string) int64 {
+	t.Helper()
+	claims, err := token.Verify(accessToken, app.config.JWT.SecretKey)
+	assert.Nil(t, err)
+	account, found, err := app.db.GetAccountByEmail(context.Background(), claims.Email)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	return account.ID
+}
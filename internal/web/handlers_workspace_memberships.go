@@ -108,6 +108,7 @@ func (app *application) addWorkspaceMember(w http.ResponseWriter, r *http.Reques
 
 	app.enforcer.InvalidatePrincipals(org.ID, input.AccountID)
 	app.logInfo(r, "workspace member added", slog.Int64("workspace_id", ws.ID), slog.Int64("target_account_id", input.AccountID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.member.add", "workspace", ws.ID, map[string]any{"account_id": input.AccountID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -128,6 +129,7 @@ func (app *application) removeWorkspaceMember(w http.ResponseWriter, r *http.Req
 
 	app.enforcer.InvalidatePrincipals(org.ID, accountID)
 	app.logInfo(r, "workspace member removed", slog.Int64("workspace_id", ws.ID), slog.Int64("target_account_id", accountID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.member.remove", "workspace", ws.ID, map[string]any{"account_id": accountID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -205,6 +207,7 @@ func (app *application) addWorkspaceTeam(w http.ResponseWriter, r *http.Request)
 		app.enforcer.InvalidatePrincipals(org.ID, accountID)
 	}
 	app.logInfo(r, "workspace team added", slog.Int64("workspace_id", ws.ID), slog.Int64("team_id", input.TeamID), slog.Int("affected_accounts", len(accountIDs)))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.team.add", "workspace", ws.ID, map[string]any{"team_id": input.TeamID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -231,5 +234,6 @@ func (app *application) removeWorkspaceTeam(w http.ResponseWriter, r *http.Reque
 		app.enforcer.InvalidatePrincipals(org.ID, accountID)
 	}
 	app.logInfo(r, "workspace team removed", slog.Int64("workspace_id", ws.ID), slog.Int64("team_id", teamID), slog.Int("affected_accounts", len(accountIDs)))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.team.remove", "workspace", ws.ID, map[string]any{"team_id": teamID}))
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -128,6 +128,7 @@ func (app *application) createWorkspaceRole(w http.ResponseWriter, r *http.Reque
 	}
 
 	app.logInfo(r, "workspace role created", slog.Int64("workspace_id", ws.ID), slog.Int64("role_id", role.ID), slog.String("scope_type", role.ScopeType), slog.Int("permission_count", len(input.Permissions)))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.role.create", "role", role.ID, map[string]any{"name": role.Name, "permissions": input.Permissions}))
 	err = response.JSON(w, http.StatusCreated, role)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -235,6 +236,7 @@ func (app *application) updateWorkspaceRole(w http.ResponseWriter, r *http.Reque
 	}
 
 	app.logInfo(r, "workspace role updated", slog.Int64("workspace_id", ws.ID), slog.Int64("role_id", role.ID), slog.Int("permission_count", len(input.Permissions)))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.role.update", "role", role.ID, map[string]any{"permissions": input.Permissions}))
 	err = response.JSON(w, http.StatusOK, role)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -281,6 +283,7 @@ func (app *application) deleteWorkspaceRole(w http.ResponseWriter, r *http.Reque
 	}
 
 	app.logInfo(r, "workspace role deleted", slog.Int64("workspace_id", ws.ID), slog.Int64("role_id", roleID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.role.delete", "role", roleID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -474,6 +477,7 @@ func (app *application) grantWorkspacePolicy(w http.ResponseWriter, r *http.Requ
 	}
 
 	app.logInfo(r, "workspace policy granted", slog.Int64("workspace_id", ws.ID), slog.Int64("role_id", input.RoleID), slog.String("subject_type", input.SubjectType), slog.Int64("subject_id", input.SubjectID), slog.String("resource_type", input.ResourceType), slog.Int64("resource_id", resourceID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.policy.grant", "role", input.RoleID, map[string]any{"subject_type": input.SubjectType, "subject_id": input.SubjectID, "resource_type": input.ResourceType, "resource_id": resourceID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -526,6 +530,7 @@ func (app *application) revokeWorkspacePolicy(w http.ResponseWriter, r *http.Req
 	}
 
 	app.logInfo(r, "workspace policy revoked", slog.Int64("workspace_id", ws.ID), slog.Int64("binding_id", bindingID), slog.Int64("role_id", rb.RoleID), slog.String("subject_type", rb.SubjectType), slog.Int64("subject_id", rb.SubjectID), slog.String("resource_type", rb.ResourceType), slog.Int64("resource_id", rb.ResourceID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.policy.revoke", "role_binding", bindingID, map[string]any{"role_id": rb.RoleID, "subject_type": rb.SubjectType, "subject_id": rb.SubjectID, "resource_type": rb.ResourceType, "resource_id": rb.ResourceID}))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -132,6 +132,7 @@ func (app *application) createWorkspace(w http.ResponseWriter, r *http.Request)
 	ws = workspaces[0]
 
 	app.logInfo(r, "workspace created", slog.Int64("org_id", org.ID), slog.Int64("workspace_id", ws.ID), slog.Int64("owner_account_id", account.ID))
+	app.recordAudit(r, orgAuditEvent(r, "workspace.create", "workspace", ws.ID, map[string]any{"name": ws.Name}))
 	err = response.JSON(w, http.StatusCreated, ws)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -218,6 +219,7 @@ func (app *application) updateWorkspace(w http.ResponseWriter, r *http.Request)
 	}
 
 	app.logInfo(r, "workspace updated", slog.Int64("workspace_id", ws.ID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.update", "workspace", ws.ID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -231,5 +233,6 @@ func (app *application) deleteWorkspace(w http.ResponseWriter, r *http.Request)
 
 	app.enforcer.InvalidateAncestry("workspace", ws.ID)
 	app.logInfo(r, "workspace deleted", slog.Int64("workspace_id", ws.ID))
+	app.recordAudit(r, workspaceAuditEvent(r, "workspace.delete", "workspace", ws.ID, nil))
 	w.WriteHeader(http.StatusNoContent)
 }
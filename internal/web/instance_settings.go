@@ -24,6 +24,7 @@ type effectiveRuntimeSettings struct {
 	QueryMaxResultRows         int
 	QueryCursorPageSize        int
 	QueryMaxResultBytes        int64
+	QueryMaxExecutionTime      time.Duration
 	ExportsSyncMaxBytes        int64
 	ExportsBackgroundMaxBytes  int64
 	SchemaSnapshotFreshness    time.Duration
@@ -105,6 +106,9 @@ func validateInstanceSettings(settings database.InstanceSettings) error {
 	if settings.QueryMaxResultBytes <= 0 {
 		return fmt.Errorf("validate runtime settings: query_max_result_bytes must be greater than 0")
 	}
+	if settings.QueryMaxExecutionSeconds < 0 || settings.QueryMaxExecutionSeconds > maxRuntimeDurationSeconds {
+		return fmt.Errorf("validate runtime settings: query_max_execution_seconds is outside the supported range")
+	}
 	if settings.ExportsSyncMaxBytes <= 0 {
 		return fmt.Errorf("validate runtime settings: exports_sync_max_bytes must be greater than 0")
 	}
@@ -156,6 +160,12 @@ func validateInstanceSettings(settings database.InstanceSettings) error {
 	if settings.QueryHistoryRetentionCount > settings.QueryHistoryRetentionCountMax {
 		return fmt.Errorf("validate runtime settings: query_history_retention_count cannot exceed query_history_retention_count_max")
 	}
+	if settings.LoginLockoutThreshold < 0 || settings.LoginIPLockoutThreshold < 0 {
+		return fmt.Errorf("validate runtime settings: login lockout thresholds must be 0 or greater")
+	}
+	if settings.LoginLockoutSeconds <= 0 || settings.LoginLockoutSeconds > 86400 {
+		return fmt.Errorf("validate runtime settings: login_lockout_seconds is outside the supported range")
+	}
 	return nil
 }
 
@@ -193,6 +203,7 @@ func effectiveSettingsFromInstance(settings database.InstanceSettings) effective
 		QueryMaxResultRows:         settings.QueryMaxResultRows,
 		QueryCursorPageSize:        settings.QueryCursorPageSize,
 		QueryMaxResultBytes:        settings.QueryMaxResultBytes,
+		QueryMaxExecutionTime:      time.Duration(settings.QueryMaxExecutionSeconds) * time.Second,
 		ExportsSyncMaxBytes:        settings.ExportsSyncMaxBytes,
 		ExportsBackgroundMaxBytes:  settings.ExportsBackgroundMaxBytes,
 		SchemaSnapshotFreshness:    time.Duration(settings.SchemaSnapshotFreshnessSeconds) * time.Second,
@@ -231,6 +242,7 @@ func (s *runtimeSettingsService) effectiveForOrg(ctx context.Context, orgID *int
 			effective.QueryMaxResultBytes = *overrides.QueryMaxResultBytes
 		}
 	}
+	effective.QueryMaxExecutionTime = tightenQueryExecutionLimit(effective.QueryMaxExecutionTime, overrides.QueryMaxExecutionSeconds)
 	if overrides.ExportsSyncMaxBytes != nil {
 		if *overrides.ExportsSyncMaxBytes > 0 && *overrides.ExportsSyncMaxBytes < effective.ExportsSyncMaxBytes {
 			effective.ExportsSyncMaxBytes = *overrides.ExportsSyncMaxBytes
@@ -310,6 +322,7 @@ func (app *application) instanceSettingsResponse(settings database.InstanceSetti
 		"query_max_result_rows":             settings.QueryMaxResultRows,
 		"query_cursor_page_size":            settings.QueryCursorPageSize,
 		"query_max_result_bytes":            settings.QueryMaxResultBytes,
+		"query_max_execution_seconds":       settings.QueryMaxExecutionSeconds,
 		"exports_sync_max_bytes":            settings.ExportsSyncMaxBytes,
 		"exports_background_max_bytes":      settings.ExportsBackgroundMaxBytes,
 		"schema_snapshot_freshness_seconds": settings.SchemaSnapshotFreshnessSeconds,
@@ -333,6 +346,11 @@ func (app *application) instanceSettingsResponse(settings database.InstanceSetti
 		"query_history_retention_count":     settings.QueryHistoryRetentionCount,
 		"query_history_retention_count_max": settings.QueryHistoryRetentionCountMax,
 		"query_favorites_mode":              settings.QueryFavoritesMode,
+		"mfa_required":                      settings.MFARequired,
+		"webauthn_user_verification":        settings.WebAuthnUserVerification,
+		"login_lockout_threshold":           settings.LoginLockoutThreshold,
+		"login_ip_lockout_threshold":        settings.LoginIPLockoutThreshold,
+		"login_lockout_seconds":             settings.LoginLockoutSeconds,
 	}
 }
 
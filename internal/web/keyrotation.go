@@ -2,24 +2,32 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/sqlwarden/internal/audit"
 	"github.com/sqlwarden/internal/response"
 )
 
 // EncryptionRotationReport summarizes a key-rotation pass over all
 // application-encrypted data.
 type EncryptionRotationReport struct {
-	ConnectionsScanned   int `json:"connections_scanned"`
-	ConnectionsRotated   int `json:"connections_rotated"`
-	FileContentsScanned  int `json:"file_contents_scanned"`
-	FileContentsRotated  int `json:"file_contents_rotated"`
-	SMTPPasswordsScanned int `json:"smtp_passwords_scanned"`
-	SMTPPasswordsRotated int `json:"smtp_passwords_rotated"`
+	ConnectionsScanned     int `json:"connections_scanned"`
+	ConnectionsRotated     int `json:"connections_rotated"`
+	FileContentsScanned    int `json:"file_contents_scanned"`
+	FileContentsRotated    int `json:"file_contents_rotated"`
+	SMTPPasswordsScanned   int `json:"smtp_passwords_scanned"`
+	SMTPPasswordsRotated   int `json:"smtp_passwords_rotated"`
+	IDPSecretsScanned      int `json:"idp_secrets_scanned"`
+	IDPSecretsRotated      int `json:"idp_secrets_rotated"`
+	MFASecretsScanned      int `json:"mfa_secrets_scanned"`
+	MFASecretsRotated      int `json:"mfa_secrets_rotated"`
+	UserCredentialsScanned int `json:"user_credentials_scanned"`
+	UserCredentialsRotated int `json:"user_credentials_rotated"`
 }
 
 // rotateEncryptionKeysHandler re-encrypts all application-encrypted data with
@@ -31,6 +39,12 @@ func (app *application) rotateEncryptionKeysHandler(w http.ResponseWriter, r *ht
 		app.serverError(w, r, err)
 		return
 	}
+	app.recordAudit(r, audit.Event{
+		Action:       "instance.encryption.rotate",
+		ResourceType: "encryption_key",
+		ResourceID:   app.keyring.PrimaryKeyID(),
+		Details:      report,
+	})
 	if err := response.JSON(w, http.StatusOK, report); err != nil {
 		app.serverError(w, r, err)
 	}
@@ -57,6 +71,15 @@ func (app *application) RotateEncryptionKeys(ctx context.Context) (EncryptionRot
 	if err := app.rotateSMTPPassword(ctx, &report); err != nil {
 		return report, err
 	}
+	if err := app.rotateIDPClientSecrets(ctx, &report); err != nil {
+		return report, err
+	}
+	if err := app.rotateMFASecrets(ctx, &report); err != nil {
+		return report, err
+	}
+	if err := app.rotateConnectionUserCredentials(ctx, &report); err != nil {
+		return report, err
+	}
 	app.logger.InfoContext(ctx, "encryption key rotation complete",
 		slog.Int("connections_scanned", report.ConnectionsScanned),
 		slog.Int("connections_rotated", report.ConnectionsRotated),
@@ -95,7 +118,105 @@ func (app *application) rotateSMTPPassword(ctx context.Context, report *Encrypti
 	return nil
 }
 
-// rotateConnectionDSNs re-encrypts stored connection DSNs with the primary key.
+// rotateIDPClientSecrets re-encrypts the client secrets stored in
+// organization identity provider configs.
+func (app *application) rotateIDPClientSecrets(ctx context.Context, report *EncryptionRotationReport) error {
+	configs, err := app.db.ListOrgIDPConfigs(ctx)
+	if err != nil {
+		return fmt.Errorf("rotate idp secrets: list configs: %w", err)
+	}
+	for _, config := range configs {
+		var settings orgOIDCSettings
+		if err := json.Unmarshal([]byte(config.Config), &settings); err != nil {
+			return fmt.Errorf("rotate idp secrets: decode org %d: %w", config.OrgID, err)
+		}
+		if settings.ClientSecretEncrypted == "" {
+			continue
+		}
+		report.IDPSecretsScanned++
+		if !app.keyring.NeedsRotation(settings.ClientSecretEncrypted) {
+			continue
+		}
+		plaintext, err := app.keyring.Decrypt(settings.ClientSecretEncrypted)
+		if err != nil {
+			return fmt.Errorf("rotate idp secrets: decrypt org %d: %w", config.OrgID, err)
+		}
+		settings.ClientSecretEncrypted, err = app.keyring.Encrypt(plaintext)
+		if err != nil {
+			return fmt.Errorf("rotate idp secrets: encrypt org %d: %w", config.OrgID, err)
+		}
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return fmt.Errorf("rotate idp secrets: encode org %d: %w", config.OrgID, err)
+		}
+		if err := app.db.UpdateOrgIDPConfigData(ctx, config.OrgID, string(data)); err != nil {
+			return fmt.Errorf("rotate idp secrets: update org %d: %w", config.OrgID, err)
+		}
+		report.IDPSecretsRotated++
+	}
+	return nil
+}
+
+// rotateMFASecrets re-encrypts the TOTP secrets of MFA enrollments.
+func (app *application) rotateMFASecrets(ctx context.Context, report *EncryptionRotationReport) error {
+	enrollments, err := app.db.ListAccountMFA(ctx)
+	if err != nil {
+		return fmt.Errorf("rotate mfa secrets: list enrollments: %w", err)
+	}
+	for _, mfa := range enrollments {
+		report.MFASecretsScanned++
+		if !app.keyring.NeedsRotation(mfa.TOTPSecretEncrypted) {
+			continue
+		}
+		plaintext, err := app.keyring.Decrypt(mfa.TOTPSecretEncrypted)
+		if err != nil {
+			return fmt.Errorf("rotate mfa secrets: decrypt account %d: %w", mfa.AccountID, err)
+		}
+		secretEncrypted, err := app.keyring.Encrypt(plaintext)
+		if err != nil {
+			return fmt.Errorf("rotate mfa secrets: encrypt account %d: %w", mfa.AccountID, err)
+		}
+		if err := app.db.UpdateAccountMFASecret(ctx, mfa.AccountID, secretEncrypted); err != nil {
+			return fmt.Errorf("rotate mfa secrets: update account %d: %w", mfa.AccountID, err)
+		}
+		report.MFASecretsRotated++
+	}
+	return nil
+}
+
+// rotateConnectionUserCredentials re-encrypts the credentials accounts store
+// for per-user connections.
+func (app *application) rotateConnectionUserCredentials(ctx context.Context, report *EncryptionRotationReport) error {
+	creds, err := app.db.ListAllConnectionUserCredentials(ctx)
+	if err != nil {
+		return fmt.Errorf("rotate user credentials: list credentials: %w", err)
+	}
+	for _, cred := range creds {
+		if cred.CredentialEncrypted == "" {
+			continue
+		}
+		report.UserCredentialsScanned++
+		if !app.keyring.NeedsRotation(cred.CredentialEncrypted) {
+			continue
+		}
+		plaintext, err := app.keyring.Decrypt(cred.CredentialEncrypted)
+		if err != nil {
+			return fmt.Errorf("rotate user credentials: decrypt connection %d account %d: %w", cred.ConnectionID, cred.AccountID, err)
+		}
+		reencrypted, err := app.keyring.Encrypt(plaintext)
+		if err != nil {
+			return fmt.Errorf("rotate user credentials: encrypt connection %d account %d: %w", cred.ConnectionID, cred.AccountID, err)
+		}
+		if err := app.db.UpdateConnectionUserCredentialSecret(ctx, cred.ConnectionID, cred.AccountID, reencrypted); err != nil {
+			return fmt.Errorf("rotate user credentials: update connection %d account %d: %w", cred.ConnectionID, cred.AccountID, err)
+		}
+		report.UserCredentialsRotated++
+	}
+	return nil
+}
+
+// rotateConnectionDSNs re-encrypts stored connection DSNs, and any SSH tunnel
+// settings stored beside them, with the primary key.
 func (app *application) rotateConnectionDSNs(ctx context.Context, report *EncryptionRotationReport) error {
 	conns, err := app.db.ListAllConnections(ctx)
 	if err != nil {
@@ -103,6 +224,32 @@ func (app *application) rotateConnectionDSNs(ctx context.Context, report *Encryp
 	}
 	for _, conn := range conns {
 		report.ConnectionsScanned++
+		if conn.SSHTunnelEncrypted != "" && app.keyring.NeedsRotation(conn.SSHTunnelEncrypted) {
+			plaintext, err := app.keyring.Decrypt(conn.SSHTunnelEncrypted)
+			if err != nil {
+				return fmt.Errorf("rotate dsn: decrypt ssh tunnel for connection %d: %w", conn.ID, err)
+			}
+			reencrypted, err := app.keyring.Encrypt(plaintext)
+			if err != nil {
+				return fmt.Errorf("rotate dsn: encrypt ssh tunnel for connection %d: %w", conn.ID, err)
+			}
+			if err := app.db.UpdateConnectionSSHTunnel(ctx, conn.ID, reencrypted); err != nil {
+				return fmt.Errorf("rotate dsn: update ssh tunnel for connection %d: %w", conn.ID, err)
+			}
+		}
+		if conn.ConfigEncrypted != "" && app.keyring.NeedsRotation(conn.ConfigEncrypted) {
+			plaintext, err := app.keyring.Decrypt(conn.ConfigEncrypted)
+			if err != nil {
+				return fmt.Errorf("rotate dsn: decrypt config for connection %d: %w", conn.ID, err)
+			}
+			reencrypted, err := app.keyring.Encrypt(plaintext)
+			if err != nil {
+				return fmt.Errorf("rotate dsn: encrypt config for connection %d: %w", conn.ID, err)
+			}
+			if err := app.db.UpdateConnectionConfig(ctx, conn.ID, reencrypted); err != nil {
+				return fmt.Errorf("rotate dsn: update config for connection %d: %w", conn.ID, err)
+			}
+		}
 		if !app.keyring.NeedsRotation(conn.DSNEncrypted) {
 			continue
 		}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
@@ -230,6 +231,45 @@ func TestRotateEncryptionKeysIncludesSMTPPassword(t *testing.T) {
 	}
 }
 
+func TestRotateEncryptionKeysIncludesIDPClientSecrets(t *testing.T) {
+	app := newTestApplication(t)
+	oldKeyring, err := encrypt.NewKeyring("old-idp-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := oldKeyring.Encrypt("idp-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, err := app.db.InsertOrg(context.Background(), "idp-rotation", "IdP Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(orgOIDCSettings{Issuer: "https://idp.example.com", ClientID: "sqlwarden", ClientSecretEncrypted: ciphertext})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.db.UpsertOrgIDPConfig(context.Background(), org.ID, ssoProviderOIDC, "Example", string(data), false); err != nil {
+		t.Fatal(err)
+	}
+	app.keyring, err = encrypt.NewKeyring("new-idp-key", "old-idp-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := app.RotateEncryptionKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.IDPSecretsScanned != 1 || report.IDPSecretsRotated != 1 {
+		t.Fatalf("unexpected IdP rotation report: %+v", report)
+	}
+	provider, found, err := app.ssoProviderFor(context.Background(), &org.ID)
+	if err != nil || !found || provider.ClientSecret != "idp-secret" {
+		t.Fatalf("rotated provider = %+v, found=%v err=%v", provider, found, err)
+	}
+}
+
 func TestRotateEncryptionKeysEndpointRequiresAuth(t *testing.T) {
 	app := newTestApp(t)
 
@@ -0,0 +1,291 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/response"
+)
+
+const (
+	replicaHeartbeatInterval = 10 * time.Second
+	// replicaStaleAfter is how long a replica can go without a heartbeat
+	// before the others treat it, and the sessions it held, as gone.
+	replicaStaleAfter = 45 * time.Second
+	drainPollInterval = time.Second
+)
+
+const (
+	// headerReplica names the replica that answered, so a load balancer or
+	// client can keep sending a session's requests to it.
+	headerReplica = "X-Warden-Replica"
+	// headerForwardedBy marks a request another replica forwarded. It is
+	// never forwarded again.
+	headerForwardedBy = "X-Warden-Forwarded-By"
+)
+
+const (
+	apiErrorReplicaDraining       = "replica_draining"
+	apiErrorSessionOnOtherReplica = "session_on_other_replica"
+)
+
+// clusterEnabled reports whether live sessions are routed between replicas.
+func (app *application) clusterEnabled() bool {
+	return app.config.Cluster.AdvertiseURL != ""
+}
+
+// defaultReplicaID names a replica by its host and port, which tells apart
+// replicas sharing a host.
+func defaultReplicaID(cfg Config) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.HTTPPort))
+}
+
+// startReplicaHeartbeat registers this replica and keeps its registration
+// fresh, pruning replicas that stopped without unregistering. It unregisters
+// the replica, and so drops its routes, when the application closes.
+func (app *application) startReplicaHeartbeat() {
+	if !app.clusterEnabled() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	app.replicaHeartbeatCancel = cancel
+	// Register before serving so that the first session has a replica to
+	// point at.
+	app.replicaHeartbeat(ctx)
+	app.logger.Info("replica registered", "replica_id", app.replicaID, "advertise_url", app.config.Cluster.AdvertiseURL, "forward_mode", app.config.Cluster.ForwardMode)
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		ticker := time.NewTicker(replicaHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := app.db.DeleteServerReplica(context.Background(), app.replicaID); err != nil {
+					app.logger.Error("replica unregister failed", "replica_id", app.replicaID, "error", err)
+				}
+				return
+			case <-ticker.C:
+				app.replicaHeartbeat(ctx)
+			}
+		}
+	}()
+}
+
+func (app *application) replicaHeartbeat(ctx context.Context) {
+	if err := app.db.UpsertServerReplica(ctx, app.replicaID, app.config.Cluster.AdvertiseURL, app.draining.Load()); err != nil {
+		if ctx.Err() == nil {
+			app.logger.ErrorContext(ctx, "replica heartbeat failed", "replica_id", app.replicaID, "error", err)
+		}
+		return
+	}
+	removed, err := app.db.DeleteStaleServerReplicas(ctx, time.Now().Add(-replicaStaleAfter))
+	if err != nil {
+		if ctx.Err() == nil {
+			app.logger.ErrorContext(ctx, "stale replica cleanup failed", "error", err)
+		}
+		return
+	}
+	if removed > 0 {
+		app.logger.InfoContext(ctx, "stale replicas removed", "count", removed)
+	}
+}
+
+// configureLiveSessionRoutes drops the routes of sessions as they close.
+func (app *application) configureLiveSessionRoutes() {
+	if app.connManager == nil || !app.clusterEnabled() {
+		return
+	}
+	app.connManager.SetOnSessionsRemoved(func(sessionIDs []string) {
+		if err := app.db.DeleteSessionRoutes(context.Background(), sessionIDs); err != nil {
+			app.logger.Error("live session routes cleanup failed", "count", len(sessionIDs), "error", err)
+		}
+	})
+}
+
+// recordLiveSessionRoute tells the other replicas that this one holds the
+// session or query cursor id. A failure is logged rather than returned: the
+// session still works through this replica.
+func (app *application) recordLiveSessionRoute(r *http.Request, kind, id, sessionID string, conn database.Connection) {
+	if !app.clusterEnabled() {
+		return
+	}
+	err := app.db.InsertLiveSessionRoute(r.Context(), database.LiveSessionRoute{
+		ID:           id,
+		Kind:         kind,
+		SessionID:    sessionID,
+		ReplicaID:    app.replicaID,
+		AccountID:    contextGetAccount(r).ID,
+		ConnectionID: conn.ID,
+	})
+	if err != nil && !isUniqueViolation(err) {
+		app.logWarn(r, "live session route not recorded", slog.String("kind", kind), slog.String("id", id), slog.String("error", err.Error()))
+	}
+}
+
+// liveRouteID returns the live session or query cursor a request addresses,
+// if any. Query cursor routes carry the cursor in the path, which this reads
+// directly because the middleware runs before those routes are matched.
+func liveRouteID(r *http.Request) (id, kind string) {
+	if id := chi.URLParam(r, "session_id"); id != "" {
+		return id, database.LiveRouteSession
+	}
+	if id := strings.TrimSpace(r.Header.Get("X-Warden-Session")); id != "" {
+		return id, database.LiveRouteSession
+	}
+	if _, rest, ok := strings.Cut(r.URL.Path, "/query-cursors/"); ok {
+		id, _, _ := strings.Cut(rest, "/")
+		return id, database.LiveRouteQueryCursor
+	}
+	return "", ""
+}
+
+// routeLiveSession sends a request for a live session or query cursor held by
+// another replica to that replica, by proxying it or by answering with where
+// to go, depending on cluster.forward_mode. Requests this replica can serve,
+// or for sessions no live replica holds, reach next, which reports a missing
+// session as usual.
+func (app *application) routeLiveSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.clusterEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(headerReplica, app.replicaID)
+		id, kind := liveRouteID(r)
+		if id == "" || r.Header.Get(headerForwardedBy) != "" || app.holdsLiveRoute(id, kind) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route, found, err := app.db.GetLiveSessionRoute(r.Context(), id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !found || route.ReplicaID == app.replicaID || time.Since(route.ReplicaLastSeenAt) > replicaStaleAfter {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if app.config.Cluster.ForwardMode == ClusterForwardRedirect {
+			w.Header().Set(headerReplica, route.ReplicaID)
+			app.apiError(w, r, http.StatusMisdirectedRequest, apiErrorSessionOnOtherReplica,
+				"This session is held by another replica. Retry the request there.",
+				response.APIError{Details: map[string]any{
+					"replica_id": route.ReplicaID,
+					"location":   route.ReplicaURL + r.URL.RequestURI(),
+				}}, nil)
+			return
+		}
+		app.forwardToReplica(w, r, route)
+	})
+}
+
+func (app *application) holdsLiveRoute(id, kind string) bool {
+	if kind == database.LiveRouteQueryCursor {
+		_, ok := app.queryCursorManager().Get(id)
+		return ok
+	}
+	_, ok := app.connManager.Get(id)
+	return ok
+}
+
+// forwardToReplica proxies r to the replica holding its session. The owner
+// authenticates and authorizes the request again from its own headers.
+func (app *application) forwardToReplica(w http.ResponseWriter, r *http.Request, route database.LiveSessionRoute) {
+	target, err := url.Parse(route.ReplicaURL)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logDebug(r, "live session request forwarded", slog.String("session_id", route.SessionID), slog.String("replica_id", route.ReplicaID))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Header.Set(headerForwardedBy, app.replicaID)
+		},
+		// Exports stream, so pass each write on as it arrives.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			app.logWarn(r, "live session forward failed", slog.String("replica_id", route.ReplicaID), slog.String("error", err.Error()))
+			app.errorMessage(w, r, http.StatusBadGateway, "The replica holding this session could not be reached.", nil)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// refuseWhileDraining answers a request to open a live session while this
+// replica drains, so the client retries and lands on another replica. It
+// reports whether it answered.
+func (app *application) refuseWhileDraining(w http.ResponseWriter, r *http.Request) bool {
+	if !app.draining.Load() {
+		return false
+	}
+	headers := make(http.Header)
+	headers.Set("Retry-After", retryAfterSeconds(drainPollInterval))
+	app.apiError(w, r, http.StatusServiceUnavailable, apiErrorReplicaDraining,
+		"This server is shutting down and not opening new sessions. Retry to connect through another one.", response.APIError{}, headers)
+	return true
+}
+
+// drainLiveSessions stops this replica opening live sessions and waits for the
+// open ones to end, up to cluster.drain_timeout. Other replicas keep routing
+// requests for those sessions here meanwhile.
+func (app *application) drainLiveSessions(ctx context.Context) {
+	timeout := app.config.Cluster.DrainTimeout
+	if !app.clusterEnabled() || timeout <= 0 {
+		return
+	}
+	app.draining.Store(true)
+	app.replicaHeartbeat(ctx)
+
+	startedAt := time.Now()
+	app.logger.Info("replica draining started", "replica_id", app.replicaID, "live_sessions", app.connManager.Count(), "timeout_ms", timeout.Milliseconds())
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for app.connManager.Count() > 0 {
+		select {
+		case <-ctx.Done():
+			app.logger.Warn("replica draining timed out", "replica_id", app.replicaID, "live_sessions", app.connManager.Count(), "duration_ms", time.Since(startedAt).Milliseconds())
+			return
+		case <-ticker.C:
+		}
+	}
+	app.logger.Info("replica draining completed", "replica_id", app.replicaID, "duration_ms", time.Since(startedAt).Milliseconds())
+}
+
+// readiness tells a load balancer whether to send this replica new traffic.
+// A draining replica is not ready but still serves its open sessions.
+func (app *application) readiness(w http.ResponseWriter, r *http.Request) {
+	status, code := "ready", http.StatusOK
+	if app.draining.Load() {
+		status, code = "draining", http.StatusServiceUnavailable
+	}
+	payload := map[string]any{"status": status}
+	if app.clusterEnabled() {
+		payload["replica_id"] = app.replicaID
+	}
+	err := response.JSON(w, code, payload)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
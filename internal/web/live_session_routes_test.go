@@ -0,0 +1,103 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+)
+
+func TestLiveSessionRouting(t *testing.T) {
+	t.Parallel()
+	app, org, ws, tok := setupWorkspaceOwner(t)
+	app.config.Cluster.AdvertiseURL = "http://replica-a.internal:6020"
+	app.replicaID = "replica-a"
+	app.configureLiveSessionRoutes()
+	ctx := context.Background()
+	assert.Nil(t, app.db.UpsertServerReplica(ctx, app.replicaID, app.config.Cluster.AdvertiseURL, false))
+
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	res := send(t, newAuthRequest(t, http.MethodPost, orgEnvConnectionsURL(org.Slug, ws.ID, envID), map[string]any{
+		"name": "Local", "driver": "sqlite", "dsn": ":memory:",
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	connID := int64(res.BodyFields["id"].(float64))
+	connURL := orgConnectionURL(org.Slug, ws.ID, envID, strconv.FormatInt(connID, 10))
+
+	res = send(t, newAuthRequest(t, http.MethodPost, connURL+"/connect", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	localSessionID := res.BodyFields["session_id"].(string)
+	route, found, err := app.db.GetLiveSessionRoute(ctx, localSessionID)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, route.ReplicaID, "replica-a")
+
+	var forwardedBy, forwardedSession string
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBy = r.Header.Get(headerForwardedBy)
+		forwardedSession = r.Header.Get("X-Warden-Session")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer owner.Close()
+	assert.Nil(t, app.db.UpsertServerReplica(ctx, "replica-b", owner.URL, false))
+	assert.Nil(t, app.db.InsertLiveSessionRoute(ctx, database.LiveSessionRoute{
+		ID: "remote-session", Kind: database.LiveRouteSession, SessionID: "remote-session", ReplicaID: "replica-b", ConnectionID: connID,
+	}))
+
+	query := func() *http.Request {
+		req := newAuthRequest(t, http.MethodPost, connURL+"/query", map[string]any{"sql": "SELECT 1"}, tok)
+		req.Header.Set("X-Warden-Session", "remote-session")
+		return req
+	}
+
+	t.Run("proxy", func(t *testing.T) {
+		res := send(t, query(), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusTeapot)
+		assert.Equal(t, forwardedBy, "replica-a")
+		assert.Equal(t, forwardedSession, "remote-session")
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		app.config.Cluster.ForwardMode = ClusterForwardRedirect
+		defer func() { app.config.Cluster.ForwardMode = ClusterForwardProxy }()
+
+		res := send(t, query(), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusMisdirectedRequest)
+		assertAPIError(t, res, apiErrorSessionOnOtherReplica, "")
+		assert.Equal(t, res.Header.Get(headerReplica), "replica-b")
+		assert.Equal(t, apiErrorDetails(t, res)["location"], any(owner.URL+connURL+"/query"))
+	})
+
+	t.Run("forwarded requests stay local", func(t *testing.T) {
+		req := query()
+		req.Header.Set(headerForwardedBy, "replica-b")
+		res := send(t, req, app.routes())
+		assert.NotEqual(t, res.StatusCode, http.StatusTeapot)
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		res := send(t, httptest.NewRequest(http.MethodGet, "/api/ready", nil), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		assert.Equal(t, res.BodyFields["replica_id"], any("replica-a"))
+
+		app.draining.Store(true)
+		defer app.draining.Store(false)
+		res = send(t, httptest.NewRequest(http.MethodGet, "/api/ready", nil), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+		assert.Equal(t, res.BodyFields["status"], any("draining"))
+
+		app.connManager.Remove(localSessionID)
+		res = send(t, newAuthRequest(t, http.MethodPost, connURL+"/connect", nil, tok), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusServiceUnavailable)
+		assertAPIError(t, res, apiErrorReplicaDraining, "")
+		assert.Equal(t, res.Header.Get("Retry-After"), "1")
+	})
+
+	_, found, err = app.db.GetLiveSessionRoute(ctx, localSessionID)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
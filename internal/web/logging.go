@@ -12,7 +12,6 @@ import (
 	"github.com/lmittmann/tint"
 	"github.com/sqlwarden/internal/response"
 	"github.com/sqlwarden/internal/version"
-	"github.com/tomasen/realip"
 )
 
 const requestIDHeader = "X-Request-ID"
@@ -21,6 +20,7 @@ type requestLogContext struct {
 	RequestID     string
 	AccountID     int64
 	AuthSessionID string
+	APITokenID    string
 	OrgID         int64
 	OrgSlug       string
 	WorkspaceID   int64
@@ -103,13 +103,13 @@ func accessLogLevel(status int) slog.Level {
 	}
 }
 
-func requestAttrs(r *http.Request) []slog.Attr {
+func (app *application) requestAttrs(r *http.Request) []slog.Attr {
 	meta := contextGetRequestLogContext(r)
 	attrs := []slog.Attr{
 		slog.String("method", r.Method),
 		slog.String("path", requestPath(r)),
 		slog.String("proto", r.Proto),
-		slog.String("remote_ip", realip.FromRequest(r)),
+		slog.String("remote_ip", app.clientIP(r)),
 	}
 	if route := routePattern(r); route != "" {
 		attrs = append(attrs, slog.String("route", route))
@@ -136,6 +136,9 @@ func resourceAttrs(r *http.Request) []slog.Attr {
 	if meta.AuthSessionID != "" {
 		attrs = append(attrs, slog.String("auth_session_id", meta.AuthSessionID))
 	}
+	if meta.APITokenID != "" {
+		attrs = append(attrs, slog.String("api_token_id", meta.APITokenID))
+	}
 	if meta.OrgID != 0 {
 		attrs = append(attrs, slog.Int64("org_id", meta.OrgID))
 	}
@@ -154,9 +157,9 @@ func resourceAttrs(r *http.Request) []slog.Attr {
 	return attrs
 }
 
-func accessLogAttrs(r *http.Request, mw *response.MetricsResponseWriter, duration time.Duration) []slog.Attr {
+func (app *application) accessLogAttrs(r *http.Request, mw *response.MetricsResponseWriter, duration time.Duration) []slog.Attr {
 	return []slog.Attr{
-		slog.Group("request", attrsToAny(requestAttrs(r))...),
+		slog.Group("request", attrsToAny(app.requestAttrs(r))...),
 		slog.Group("response",
 			slog.Int("status", mw.StatusCode),
 			slog.Int("size", mw.BytesCount),
@@ -173,7 +176,7 @@ func (app *application) logInfo(r *http.Request, message string, attrs ...slog.A
 		return
 	}
 	base := []slog.Attr{
-		slog.Group("request", attrsToAny(requestAttrs(r))...),
+		slog.Group("request", attrsToAny(app.requestAttrs(r))...),
 		slog.Group("resource", attrsToAny(resourceAttrs(r))...),
 	}
 	base = append(base, attrs...)
@@ -188,7 +191,7 @@ func (app *application) logDebug(r *http.Request, message string, attrs ...slog.
 		return
 	}
 	base := []slog.Attr{
-		slog.Group("request", attrsToAny(requestAttrs(r))...),
+		slog.Group("request", attrsToAny(app.requestAttrs(r))...),
 		slog.Group("resource", attrsToAny(resourceAttrs(r))...),
 	}
 	base = append(base, attrs...)
@@ -202,7 +205,7 @@ func (app *application) logWarn(r *http.Request, message string, attrs ...slog.A
 		return
 	}
 	base := []slog.Attr{
-		slog.Group("request", attrsToAny(requestAttrs(r))...),
+		slog.Group("request", attrsToAny(app.requestAttrs(r))...),
 		slog.Group("resource", attrsToAny(resourceAttrs(r))...),
 	}
 	base = append(base, attrs...)
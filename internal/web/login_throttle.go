@@ -0,0 +1,204 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/ratelimit"
+	"github.com/sqlwarden/internal/smtp"
+)
+
+// Failed sign-ins for an account are answered immediately up to
+// loginDelayAfter failures. After that each attempt must wait twice as long
+// as the last, up to maxLoginDelay, until the lockout threshold is reached.
+const (
+	loginDelayAfter = 3
+	maxLoginDelay   = 30 * time.Second
+)
+
+// loginSubject is the account key for failed sign-in tracking. Emails are
+// tracked whether or not an account exists, so throttling does not reveal
+// which addresses are registered.
+func loginSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginDelay is how long after failure number failures the next attempt is
+// refused.
+func loginDelay(failures int) time.Duration {
+	if failures < loginDelayAfter {
+		return 0
+	}
+	shift := min(failures-loginDelayAfter, 16)
+	return min(time.Second<<shift, maxLoginDelay)
+}
+
+// clientRetryAfter returns how long the client IP is locked out of sign-in,
+// or zero.
+func (app *application) clientRetryAfter(r *http.Request) (time.Duration, error) {
+	failure, found, err := app.db.GetLoginFailure(r.Context(), database.LoginFailureScopeIP, app.clientIP(r))
+	if err != nil || !found || !failure.Locked(time.Now()) {
+		return 0, err
+	}
+	return time.Until(*failure.LockedUntil), nil
+}
+
+// loginRetryAfter returns how long the client must wait before it may try to
+// sign in as email. It is zero when the attempt may proceed. progressive
+// applies the delay between failed attempts as well as the lockout; second
+// factor attempts skip it, since each MFA challenge caps its own attempts.
+func (app *application) loginRetryAfter(r *http.Request, email string, progressive bool) (time.Duration, error) {
+	wait, err := app.clientRetryAfter(r)
+	if err != nil {
+		return 0, err
+	}
+	failure, found, err := app.db.GetLoginFailure(r.Context(), database.LoginFailureScopeAccount, loginSubject(email))
+	if err != nil || !found {
+		return wait, err
+	}
+	var until time.Time
+	if progressive {
+		until = failure.LastFailedAt.Add(loginDelay(failure.Failures))
+	}
+	if failure.Locked(time.Now()) {
+		until = *failure.LockedUntil
+	}
+	return max(wait, time.Until(until)), nil
+}
+
+// recordClientFailure counts a failed sign-in or refresh against the client
+// IP.
+func (app *application) recordClientFailure(r *http.Request) error {
+	settings, err := app.instanceSettings(r.Context())
+	if err != nil {
+		return err
+	}
+	clientIP := app.clientIP(r)
+	_, locked, err := app.db.RecordLoginFailure(r.Context(), database.LoginFailureScopeIP, clientIP, settings.LoginIPLockoutThreshold, time.Duration(settings.LoginLockoutSeconds)*time.Second)
+	if err != nil || !locked {
+		return err
+	}
+	app.logWarn(r, "client locked out of sign-in", slog.String("client_ip", clientIP))
+	app.recordAudit(r, audit.Event{Action: "auth.lockout", ResourceType: "client_ip", ResourceID: clientIP})
+	return nil
+}
+
+// recordLoginFailure counts a failed sign-in against email and the client IP.
+// account is nil when no account has that email. When the attempt locks an
+// account, the account holder is notified.
+func (app *application) recordLoginFailure(r *http.Request, email string, account *database.Account) error {
+	if err := app.recordClientFailure(r); err != nil {
+		return err
+	}
+	settings, err := app.instanceSettings(r.Context())
+	if err != nil {
+		return err
+	}
+	clientIP := app.clientIP(r)
+	failure, locked, err := app.db.RecordLoginFailure(r.Context(), database.LoginFailureScopeAccount, loginSubject(email), settings.LoginLockoutThreshold, time.Duration(settings.LoginLockoutSeconds)*time.Second)
+	if err != nil || !locked || account == nil {
+		return err
+	}
+	app.logWarn(r, "account locked out of sign-in", slog.Int64("account_id", account.ID), slog.Int("failures", failure.Failures))
+	app.recordAudit(r, audit.Event{
+		Action:       "auth.lockout",
+		ResourceType: "account",
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+		Details:      map[string]any{"failures": failure.Failures, "client_ip": clientIP},
+	})
+
+	data := newEmailData(settings.BaseURL)
+	data["IPAddress"] = clientIP
+	data["LockedUntil"] = *failure.LockedUntil
+	recipient := account.Email
+	app.backgroundTask(r, func() error {
+		err := app.sendEmail(false, recipient, data, "account-locked.tmpl")
+		if errors.Is(err, smtp.ErrDisabled) {
+			return nil
+		}
+		return err
+	})
+	return nil
+}
+
+// clearLoginFailures forgets an account's failed sign-ins once it signs in.
+func (app *application) clearLoginFailures(ctx context.Context, email string) error {
+	_, err := app.db.ClearLoginFailures(ctx, database.LoginFailureScopeAccount, loginSubject(email))
+	return err
+}
+
+func (app *application) loginThrottled(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	app.tooManyRequests(w, r, wait, "Too many failed sign-in attempts. Try again later.")
+}
+
+func (app *application) unlockInstanceAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+	account, found, err := app.db.GetAccount(r.Context(), accountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found {
+		app.notFound(w, r)
+		return
+	}
+	wasLocked, err := app.db.ClearLoginFailures(r.Context(), database.LoginFailureScopeAccount, loginSubject(account.Email))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logInfo(r, "account sign-in unlocked", slog.Int64("target_account_id", accountID), slog.Bool("was_locked", wasLocked))
+	app.recordAudit(r, instanceAuditEvent("instance.account.unlock", "account", accountID, map[string]any{"was_locked": wasLocked}))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rateLimit throttles each client IP in a route group as configured under
+// rate_limits.<group>. Limiters live on the application so every router built
+// from it shares one budget.
+func (app *application) rateLimit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter := app.rateLimiter(group)
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, wait := limiter.Allow(app.clientIP(r)); !ok {
+				app.tooManyRequests(w, r, wait, "Too many requests. Try again later.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) rateLimiter(group string) *ratelimit.Limiter {
+	limit, ok := app.config.RateLimits[group]
+	if !ok || limit.Requests <= 0 {
+		return nil
+	}
+	app.rateLimitersMu.Lock()
+	defer app.rateLimitersMu.Unlock()
+	if app.rateLimiters == nil {
+		app.rateLimiters = make(map[string]*ratelimit.Limiter)
+	}
+	limiter, ok := app.rateLimiters[group]
+	if !ok {
+		limiter = ratelimit.New(limit.Requests, limit.Window)
+		app.rateLimiters[group] = limiter
+	}
+	return limiter
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestLoginLockoutNotifiesAccountAndAdminCanUnlock(t *testing.T) {
+	app := newTestApp(t)
+	adminToken := setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "locked@example.com", "Locked", "securepass99")
+
+	settings, err := app.instanceSettings(context.Background())
+	assert.Nil(t, err)
+	settings.LoginLockoutThreshold = 3
+	_, err = app.db.UpsertInstanceSettings(context.Background(), settings)
+	assert.Nil(t, err)
+
+	for range 3 {
+		res := loginTestUser(t, app, "locked@example.com", "wrongpass99")
+		assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	}
+	res := loginTestUser(t, app, "locked@example.com", "securepass99")
+	assert.Equal(t, res.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, res.BodyFields["error"].(map[string]any)["code"], apiErrorRateLimited)
+	assert.True(t, res.Header.Get("Retry-After") != "")
+
+	app.wg.Wait()
+	assert.Equal(t, len(app.mailer.SentMessages), 1)
+	assert.True(t, strings.Contains(app.mailer.SentMessages[0], "To: <locked@example.com>"))
+
+	account, _, err := app.db.GetAccountByEmail(context.Background(), "locked@example.com")
+	assert.Nil(t, err)
+	res = send(t, newAuthRequest(t, http.MethodDelete, fmt.Sprintf("/api/v1/instance/accounts/%d/lockout", account.ID), nil, adminToken), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	res = loginTestUser(t, app, "locked@example.com", "securepass99")
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestLoginFailuresDelayUnknownAccountsAlike(t *testing.T) {
+	app := newTestApp(t)
+	setupInstance(t, app, "admin@example.com", "Admin", "securepass99")
+	registerTestUser(t, app, "known@example.com", "Known", "securepass99")
+
+	for _, email := range []string{"known@example.com", "nobody@example.com"} {
+		for range loginDelayAfter {
+			res := loginTestUser(t, app, email, "wrongpass99")
+			assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+		}
+		res := loginTestUser(t, app, email, "wrongpass99")
+		assert.Equal(t, res.StatusCode, http.StatusTooManyRequests)
+		assert.Equal(t, res.Header.Get("Retry-After"), "1")
+	}
+}
+
+func TestRateLimitPerRouteGroup(t *testing.T) {
+	app := newTestApp(t)
+	app.config.RateLimits = map[string]RateLimit{RateLimitGroupAuth: {Requests: 2, Window: time.Minute}}
+
+	for range 2 {
+		res := send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/logout", nil), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	}
+	res := send(t, newTestRequest(t, http.MethodPost, "/api/v1/auth/logout", nil), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, res.Header.Get("Retry-After"), "30")
+
+	// Other groups are not limited.
+	res = send(t, newTestRequest(t, http.MethodGet, "/api/v1/session", nil), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestRateLimitIgnoresForwardedHeadersFromUntrustedPeers(t *testing.T) {
+	app := newTestApp(t)
+	app.config.RateLimits = map[string]RateLimit{RateLimitGroupAuth: {Requests: 2, Window: time.Minute}}
+
+	logout := func(forwardedFor string) int {
+		req := newTestRequest(t, http.MethodPost, "/api/v1/auth/logout", nil)
+		req.RemoteAddr = "198.51.100.7:40000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set("X-Real-IP", forwardedFor)
+		return send(t, req, app.routes()).StatusCode
+	}
+	assert.Equal(t, logout("203.0.113.1"), http.StatusNoContent)
+	assert.Equal(t, logout("203.0.113.2"), http.StatusNoContent)
+	assert.Equal(t, logout("203.0.113.3"), http.StatusTooManyRequests)
+
+	app = newTestApp(t)
+	app.config.RateLimits = map[string]RateLimit{RateLimitGroupAuth: {Requests: 2, Window: time.Minute}}
+	app.config.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")}
+	assert.Equal(t, logout("203.0.113.1"), http.StatusNoContent)
+	assert.Equal(t, logout("203.0.113.2"), http.StatusNoContent)
+	assert.Equal(t, logout("203.0.113.3"), http.StatusNoContent)
+	// Only the hops appended by trusted proxies are believed.
+	assert.Equal(t, logout("203.0.113.9, 203.0.113.3"), http.StatusNoContent)
+	assert.Equal(t, logout("203.0.113.8, 203.0.113.3, 198.51.100.20"), http.StatusTooManyRequests)
+}
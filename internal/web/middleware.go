@@ -72,7 +72,7 @@ func (app *application) logAccess(next http.Handler) http.Handler {
 		next.ServeHTTP(mw, r)
 
 		duration := time.Since(startedAt)
-		app.logger.LogAttrs(r.Context(), accessLogLevel(mw.StatusCode), "http request", accessLogAttrs(r, mw, duration)...)
+		app.logger.LogAttrs(r.Context(), accessLogLevel(mw.StatusCode), "http request", app.accessLogAttrs(r, mw, duration)...)
 	})
 }
 
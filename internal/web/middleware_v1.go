@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/token"
 )
 
@@ -28,6 +30,11 @@ func (app *application) authenticateV1(next http.Handler) http.Handler {
 			return
 		}
 
+		if token.IsAPIToken(parts[1]) {
+			app.authenticateAPIToken(w, r, next, parts[1])
+			return
+		}
+
 		claims, err := token.Verify(parts[1], app.config.JWT.SecretKey)
 		if err != nil {
 			app.invalidAuthenticationToken(w, r)
@@ -90,6 +97,90 @@ func (app *application) authenticateV1(next http.Handler) http.Handler {
 	})
 }
 
+// authenticateAPIToken authenticates a personal access or service account
+// token. API tokens are not bound to an auth session, so session revocation
+// does not apply to them; revoking or expiring the token does.
+func (app *application) authenticateAPIToken(w http.ResponseWriter, r *http.Request, next http.Handler, plaintext string) {
+	apiToken, found, err := app.db.GetAPITokenByHash(r.Context(), token.Hash(plaintext))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || apiToken.RevokedAt != nil || apiToken.IsExpired(time.Now()) {
+		reason := "api_token_not_found"
+		if found && apiToken.RevokedAt != nil {
+			reason = "api_token_revoked"
+		} else if found {
+			reason = "api_token_expired"
+		}
+		app.logWarn(r, "api token rejected", slog.String("api_token_id", apiToken.ID), slog.String("reason", reason))
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+
+	account, found, err := app.db.GetAccount(r.Context(), apiToken.AccountID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !found || !account.IsActive {
+		app.invalidAuthenticationToken(w, r)
+		return
+	}
+
+	if account.IsService() {
+		allowed, err := app.serviceTokenAllowsPath(r.Context(), apiToken, r.URL.Path)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.notPermitted(w, r)
+			return
+		}
+	}
+
+	r = contextSetAPIToken(r, apiToken)
+	r = contextSetAccount(r, account)
+	if !apiTokenAllowsMethod(apiToken.Scopes, r.Method) {
+		app.insufficientTokenScope(w, r)
+		return
+	}
+
+	runtimeSettings, err := app.runtimeSettingsService().effectiveForOrg(r.Context(), nil)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	// Skip the write entirely while last_used_at is fresh, so automation
+	// clients do not pay for an update on every call.
+	if !apiToken.UsedRecently(time.Now()) {
+		if err = app.db.TouchAPIToken(r.Context(), apiToken.ID, r.RemoteAddr); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	r = contextSetRuntimeSettings(r, runtimeSettings)
+	next.ServeHTTP(w, r)
+}
+
+// serviceTokenAllowsPath reports whether a service account token may be used
+// for path. Service accounts only act within their organization, so every
+// route outside /api/v1/orgs/{slug} is off limits, including /me and personal
+// workspaces. orgCtx still checks the token's org once the route is resolved.
+func (app *application) serviceTokenAllowsPath(ctx context.Context, apiToken database.APIToken, path string) (bool, error) {
+	if apiToken.OrgID == nil {
+		return false, nil
+	}
+	org, found, err := app.db.GetOrg(ctx, *apiToken.OrgID)
+	if err != nil || !found {
+		return false, err
+	}
+	prefix := "/api/v1/orgs/" + org.Slug
+	return path == prefix || strings.HasPrefix(path, prefix+"/"), nil
+}
+
 // requireAccount rejects the request with 401 if no authenticated account is in context.
 func (app *application) requireAccount(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -102,6 +193,19 @@ func (app *application) requireAccount(next http.Handler) http.Handler {
 	})
 }
 
+// requireInteractiveSession rejects requests authenticated with an API token.
+// It guards credential management so a leaked token cannot mint further
+// tokens or change the password of the account it belongs to.
+func (app *application) requireInteractiveSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := contextGetAPIToken(r); ok {
+			app.interactiveSessionRequired(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // orgCtx resolves the org from the URL slug and sets it on context.
 // Requires requireAccount to have run first.
 func (app *application) orgCtx(next http.Handler) http.Handler {
@@ -127,6 +231,25 @@ func (app *application) orgCtx(next http.Handler) http.Handler {
 			app.notPermitted(w, r)
 			return
 		}
+		ssoIssuer, err := app.orgSSOIssuer(r.Context(), org.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if ssoIssuer != "" {
+			allowed, err := app.satisfiesOrgSSO(r, account, ssoIssuer)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			if !allowed {
+				app.ssoRequired(w, r)
+				return
+			}
+		}
+		if !app.enforceOrgMFA(w, r, org) {
+			return
+		}
 
 		runtimeSettings, ok := contextGetRuntimeSettings(r)
 		if !ok {
@@ -136,7 +259,12 @@ func (app *application) orgCtx(next http.Handler) http.Handler {
 				return
 			}
 		}
-		if runtimeSettings.SessionsRevocationEnabled {
+		if apiToken, ok := contextGetAPIToken(r); ok {
+			if apiToken.OrgID != nil && *apiToken.OrgID != org.ID {
+				app.notPermitted(w, r)
+				return
+			}
+		} else if runtimeSettings.SessionsRevocationEnabled {
 			authSession := contextGetAuthSession(r)
 			if authSession.ID == "" {
 				app.invalidAuthenticationToken(w, r)
@@ -0,0 +1,1434 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/audit"
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/validator"
+	"github.com/uptrace/bun"
+	"go.yaml.in/yaml/v3"
+)
+
+// policyDocumentVersion is the policy document format written by export and
+// accepted by import.
+const policyDocumentVersion = 1
+
+// errPolicyAmbiguousName is returned when a resource a policy document must
+// name shares its name with another resource, so it has no stable reference.
+var errPolicyAmbiguousName = errors.New("policy document cannot reference a resource whose name is not unique")
+
+// PolicyDocument is an organization's authorization policy addressed by
+// stable names rather than IDs, so it can be kept in version control.
+//
+// Accounts are referenced by email, or by slug for service accounts. Custom
+// roles and teams are the complete set for the organization. Workspaces are
+// referenced by name and must already exist; only the workspaces listed are
+// managed, so memberships, workspace roles and bindings inside other
+// workspaces are left as they are.
+type PolicyDocument struct {
+	Version    int               `json:"version"    yaml:"version"`
+	Roles      []PolicyRole      `json:"roles"      yaml:"roles"`
+	Teams      []PolicyTeam      `json:"teams"      yaml:"teams"`
+	Workspaces []PolicyWorkspace `json:"workspaces" yaml:"workspaces"`
+	Bindings   []PolicyBinding   `json:"bindings"   yaml:"bindings"`
+}
+
+// PolicyRole is a custom role. Workspace is set for a role local to one
+// workspace.
+type PolicyRole struct {
+	Name        string   `json:"name"                  yaml:"name"`
+	Workspace   string   `json:"workspace,omitempty"   yaml:"workspace,omitempty"`
+	Scope       string   `json:"scope"                 yaml:"scope"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Permissions []string `json:"permissions"           yaml:"permissions"`
+}
+
+type PolicyTeam struct {
+	Slug    string   `json:"slug"    yaml:"slug"`
+	Name    string   `json:"name"    yaml:"name"`
+	Members []string `json:"members" yaml:"members"`
+}
+
+// PolicyWorkspace lists a workspace's direct members and teams.
+type PolicyWorkspace struct {
+	Name    string   `json:"name"    yaml:"name"`
+	Members []string `json:"members" yaml:"members"`
+	Teams   []string `json:"teams"   yaml:"teams"`
+}
+
+// PolicyBinding grants a role to a subject on a resource.
+type PolicyBinding struct {
+	Role     PolicyRoleRef  `json:"role"     yaml:"role"`
+	Subject  PolicySubject  `json:"subject"  yaml:"subject"`
+	Resource PolicyResource `json:"resource" yaml:"resource"`
+}
+
+// PolicyRoleRef names a builtin or custom role. Workspace is set for roles
+// local to a workspace, including the workspace builtin roles.
+type PolicyRoleRef struct {
+	Name      string `json:"name"                yaml:"name"`
+	Workspace string `json:"workspace,omitempty" yaml:"workspace,omitempty"`
+}
+
+// PolicySubject names an account (email or service account slug), a team
+// (slug) or a workspace (workspace_members). Name is empty for org_members.
+type PolicySubject struct {
+	Type string `json:"type"           yaml:"type"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// PolicyResource names the org or a workspace, environment or connection.
+type PolicyResource struct {
+	Type        string `json:"type"                  yaml:"type"`
+	Workspace   string `json:"workspace,omitempty"   yaml:"workspace,omitempty"`
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Connection  string `json:"connection,omitempty"  yaml:"connection,omitempty"`
+}
+
+// Policy plan change kinds.
+const (
+	policyChangeRole            = "role"
+	policyChangeTeam            = "team"
+	policyChangeTeamMember      = "team_member"
+	policyChangeWorkspaceMember = "workspace_member"
+	policyChangeWorkspaceTeam   = "workspace_team"
+	policyChangeBinding         = "binding"
+)
+
+// PolicyChange is one step of a policy plan. Protected changes grant, revoke
+// or redefine organization deletion or ownership transfer permission.
+type PolicyChange struct {
+	Action    string `json:"action"              yaml:"action"`
+	Kind      string `json:"kind"                yaml:"kind"`
+	Target    string `json:"target"              yaml:"target"`
+	Protected bool   `json:"protected,omitempty" yaml:"protected,omitempty"`
+
+	protected  []string
+	principals []int64
+	apply      func(ctx context.Context, a *policyApplier) error
+}
+
+// PolicyPlan lists the changes that bring an organization's policy in line
+// with a document, in the order they are applied.
+type PolicyPlan struct {
+	Changes []PolicyChange `json:"changes" yaml:"changes"`
+}
+
+// protectedPermissions returns the permissions an actor must hold to apply
+// the plan's protected changes.
+func (p PolicyPlan) protectedPermissions() []string {
+	var permissions []string
+	for _, change := range p.Changes {
+		for _, permission := range change.protected {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+func (p PolicyPlan) changesTeams() bool {
+	for _, change := range p.Changes {
+		if change.Kind == policyChangeTeam || change.Kind == policyChangeTeamMember {
+			return true
+		}
+	}
+	return false
+}
+
+// policyApplier carries the transaction a plan is applied in. roles and
+// teams resolve names to IDs, including roles and teams created by earlier
+// changes.
+type policyApplier struct {
+	app     *application
+	exec    bun.IDB
+	actorID int64
+	roles   map[policyRoleKey]int64
+	teams   map[string]int64
+}
+
+// createdBy returns the actor recorded on new memberships, or nil when the
+// plan is applied at infrastructure trust level.
+func (a *policyApplier) createdBy() *int64 {
+	if a.actorID == 0 {
+		return nil
+	}
+	return &a.actorID
+}
+
+type policyRoleKey struct {
+	WorkspaceID int64
+	Name        string
+}
+
+type policyResourceKey struct {
+	WorkspaceID int64
+	Name        string
+}
+
+type policyBindingKey struct {
+	Role         policyRoleKey
+	SubjectType  string
+	SubjectRef   string
+	ResourceType string
+	ResourceID   int64
+}
+
+// policyIndex resolves between the IDs in an organization's policy state and
+// the names used by policy documents.
+type policyIndex struct {
+	orgID          int64
+	state          database.OrgPolicyState
+	accountRefs    map[int64]string
+	accountIDs     map[string]int64
+	roles          map[int64]database.Role
+	roleIDs        map[policyRoleKey]int64
+	teams          map[int64]database.Team
+	teamIDs        map[string]int64
+	teamMembers    map[int64][]int64
+	workspaces     map[int64]string
+	workspaceIDs   map[string][]int64
+	environments   map[int64]database.PolicyStateResource
+	environmentIDs map[policyResourceKey][]int64
+	connections    map[int64]database.PolicyStateResource
+	connectionIDs  map[policyResourceKey][]int64
+}
+
+func newPolicyIndex(orgID int64, state database.OrgPolicyState) *policyIndex {
+	idx := &policyIndex{
+		orgID:          orgID,
+		state:          state,
+		accountRefs:    make(map[int64]string),
+		accountIDs:     make(map[string]int64),
+		roles:          make(map[int64]database.Role),
+		roleIDs:        make(map[policyRoleKey]int64),
+		teams:          make(map[int64]database.Team),
+		teamIDs:        make(map[string]int64),
+		teamMembers:    make(map[int64][]int64),
+		workspaces:     make(map[int64]string),
+		workspaceIDs:   make(map[string][]int64),
+		environments:   make(map[int64]database.PolicyStateResource),
+		environmentIDs: make(map[policyResourceKey][]int64),
+		connections:    make(map[int64]database.PolicyStateResource),
+		connectionIDs:  make(map[policyResourceKey][]int64),
+	}
+	for _, account := range state.Accounts {
+		ref := strings.ToLower(account.Email)
+		if account.ServiceAccountSlug != "" {
+			ref = account.ServiceAccountSlug
+		}
+		idx.accountRefs[account.ID] = ref
+		idx.accountIDs[ref] = account.ID
+	}
+	for _, role := range state.Roles {
+		idx.roles[role.ID] = role
+		idx.roleIDs[roleKey(role)] = role.ID
+	}
+	for _, team := range state.Teams {
+		idx.teams[team.ID] = team
+		idx.teamIDs[team.Slug] = team.ID
+	}
+	for _, member := range state.TeamMembers {
+		idx.teamMembers[member.TeamID] = append(idx.teamMembers[member.TeamID], member.AccountID)
+	}
+	for _, ws := range state.Workspaces {
+		idx.workspaces[ws.ID] = ws.Name
+		idx.workspaceIDs[ws.Name] = append(idx.workspaceIDs[ws.Name], ws.ID)
+	}
+	for _, env := range state.Environments {
+		idx.environments[env.ID] = env
+		key := policyResourceKey{WorkspaceID: env.WorkspaceID, Name: env.Name}
+		idx.environmentIDs[key] = append(idx.environmentIDs[key], env.ID)
+	}
+	for _, conn := range state.Connections {
+		idx.connections[conn.ID] = conn
+		key := policyResourceKey{WorkspaceID: conn.WorkspaceID, Name: conn.Name}
+		idx.connectionIDs[key] = append(idx.connectionIDs[key], conn.ID)
+	}
+	return idx
+}
+
+func roleKey(role database.Role) policyRoleKey {
+	key := policyRoleKey{Name: role.Name}
+	if role.WorkspaceID != nil {
+		key.WorkspaceID = *role.WorkspaceID
+	}
+	return key
+}
+
+func (idx *policyIndex) workspaceName(id int64) (string, error) {
+	name := idx.workspaces[id]
+	if len(idx.workspaceIDs[name]) > 1 {
+		return "", fmt.Errorf("%w: workspace %q", errPolicyAmbiguousName, name)
+	}
+	return name, nil
+}
+
+func (idx *policyIndex) roleRef(roleID int64) (PolicyRoleRef, error) {
+	role := idx.roles[roleID]
+	ref := PolicyRoleRef{Name: role.Name}
+	if role.WorkspaceID != nil {
+		name, err := idx.workspaceName(*role.WorkspaceID)
+		if err != nil {
+			return PolicyRoleRef{}, err
+		}
+		ref.Workspace = name
+	}
+	return ref, nil
+}
+
+func (idx *policyIndex) subject(binding database.RoleBinding) (PolicySubject, error) {
+	subject := PolicySubject{Type: binding.SubjectType}
+	switch binding.SubjectType {
+	case access.SubjectTypeAccount:
+		subject.Name = idx.accountRefs[binding.SubjectID]
+		if subject.Name == "" {
+			subject.Name = strconv.FormatInt(binding.SubjectID, 10)
+		}
+	case access.SubjectTypeTeam:
+		subject.Name = idx.teams[binding.SubjectID].Slug
+	case access.SubjectTypeWorkspaceMembers:
+		name, err := idx.workspaceName(binding.SubjectID)
+		if err != nil {
+			return PolicySubject{}, err
+		}
+		subject.Name = name
+	}
+	return subject, nil
+}
+
+// resourceWorkspaceID returns the workspace a bound resource belongs to, or
+// zero for the org.
+func (idx *policyIndex) resourceWorkspaceID(resourceType string, resourceID int64) int64 {
+	switch resourceType {
+	case "workspace":
+		return resourceID
+	case "environment":
+		return idx.environments[resourceID].WorkspaceID
+	case "connection":
+		return idx.connections[resourceID].WorkspaceID
+	default:
+		return 0
+	}
+}
+
+func (idx *policyIndex) resource(resourceType string, resourceID int64) (PolicyResource, error) {
+	resource := PolicyResource{Type: resourceType}
+	if resourceType == "org" {
+		return resource, nil
+	}
+	workspaceID := idx.resourceWorkspaceID(resourceType, resourceID)
+	name, err := idx.workspaceName(workspaceID)
+	if err != nil {
+		return PolicyResource{}, err
+	}
+	resource.Workspace = name
+	switch resourceType {
+	case "environment":
+		resource.Environment = idx.environments[resourceID].Name
+	case "connection":
+		conn := idx.connections[resourceID]
+		if len(idx.connectionIDs[policyResourceKey{WorkspaceID: workspaceID, Name: conn.Name}]) > 1 {
+			return PolicyResource{}, fmt.Errorf("%w: connection %q in workspace %q", errPolicyAmbiguousName, conn.Name, name)
+		}
+		resource.Connection = conn.Name
+	}
+	return resource, nil
+}
+
+// ParsePolicyDocument reads a policy document written as YAML or JSON.
+func ParsePolicyDocument(r io.Reader) (PolicyDocument, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var doc PolicyDocument
+	if err := dec.Decode(&doc); err != nil {
+		return PolicyDocument{}, fmt.Errorf("parse policy document: %w", err)
+	}
+	return doc, nil
+}
+
+// ExportOrgPolicy returns the policy document for the organization with the
+// given slug.
+func (app *application) ExportOrgPolicy(ctx context.Context, orgSlug string) (PolicyDocument, error) {
+	org, found, err := app.db.GetOrgBySlug(ctx, orgSlug)
+	if err != nil {
+		return PolicyDocument{}, err
+	}
+	if !found {
+		return PolicyDocument{}, fmt.Errorf("organization %q not found", orgSlug)
+	}
+	return app.orgPolicyDocument(ctx, org.ID)
+}
+
+func (app *application) orgPolicyDocument(ctx context.Context, orgID int64) (PolicyDocument, error) {
+	state, err := app.db.LoadOrgPolicyState(ctx, orgID)
+	if err != nil {
+		return PolicyDocument{}, err
+	}
+	return newPolicyIndex(orgID, state).document()
+}
+
+// document renders the indexed state as a policy document in a stable
+// order, so exports of an unchanged policy are identical.
+func (idx *policyIndex) document() (PolicyDocument, error) {
+	doc := PolicyDocument{
+		Version:    policyDocumentVersion,
+		Roles:      []PolicyRole{},
+		Teams:      []PolicyTeam{},
+		Workspaces: []PolicyWorkspace{},
+		Bindings:   []PolicyBinding{},
+	}
+
+	for _, role := range idx.state.Roles {
+		if role.IsBuiltin {
+			continue
+		}
+		ref, err := idx.roleRef(role.ID)
+		if err != nil {
+			return PolicyDocument{}, err
+		}
+		permissions := slices.Clone(role.Permissions)
+		if permissions == nil {
+			permissions = []string{}
+		}
+		doc.Roles = append(doc.Roles, PolicyRole{
+			Name:        ref.Name,
+			Workspace:   ref.Workspace,
+			Scope:       role.ScopeType,
+			Description: role.Description,
+			Permissions: permissions,
+		})
+	}
+	sort.Slice(doc.Roles, func(i, j int) bool {
+		if doc.Roles[i].Workspace != doc.Roles[j].Workspace {
+			return doc.Roles[i].Workspace < doc.Roles[j].Workspace
+		}
+		return doc.Roles[i].Name < doc.Roles[j].Name
+	})
+
+	for _, team := range idx.state.Teams {
+		doc.Teams = append(doc.Teams, PolicyTeam{
+			Slug:    team.Slug,
+			Name:    team.Name,
+			Members: idx.accountRefList(idx.teamMembers[team.ID]),
+		})
+	}
+	sort.Slice(doc.Teams, func(i, j int) bool { return doc.Teams[i].Slug < doc.Teams[j].Slug })
+
+	members := make(map[int64][]int64)
+	for _, member := range idx.state.WorkspaceMembers {
+		members[member.WorkspaceID] = append(members[member.WorkspaceID], member.AccountID)
+	}
+	teams := make(map[int64][]string)
+	for _, team := range idx.state.WorkspaceTeams {
+		teams[team.WorkspaceID] = append(teams[team.WorkspaceID], idx.teams[team.TeamID].Slug)
+	}
+	for _, ws := range idx.state.Workspaces {
+		name, err := idx.workspaceName(ws.ID)
+		if err != nil {
+			return PolicyDocument{}, err
+		}
+		wsTeams := teams[ws.ID]
+		if wsTeams == nil {
+			wsTeams = []string{}
+		}
+		sort.Strings(wsTeams)
+		doc.Workspaces = append(doc.Workspaces, PolicyWorkspace{
+			Name:    name,
+			Members: idx.accountRefList(members[ws.ID]),
+			Teams:   wsTeams,
+		})
+	}
+	sort.Slice(doc.Workspaces, func(i, j int) bool { return doc.Workspaces[i].Name < doc.Workspaces[j].Name })
+
+	for _, binding := range idx.state.Bindings {
+		// Expiring bindings are temporary grants, not standing policy.
+		if binding.ExpiresAt != nil {
+			continue
+		}
+		role, err := idx.roleRef(binding.RoleID)
+		if err != nil {
+			return PolicyDocument{}, err
+		}
+		subject, err := idx.subject(binding)
+		if err != nil {
+			return PolicyDocument{}, err
+		}
+		resource, err := idx.resource(binding.ResourceType, binding.ResourceID)
+		if err != nil {
+			return PolicyDocument{}, err
+		}
+		doc.Bindings = append(doc.Bindings, PolicyBinding{Role: role, Subject: subject, Resource: resource})
+	}
+	sort.Slice(doc.Bindings, func(i, j int) bool {
+		return policyBindingSortKey(doc.Bindings[i]) < policyBindingSortKey(doc.Bindings[j])
+	})
+	return doc, nil
+}
+
+func (idx *policyIndex) accountRefList(accountIDs []int64) []string {
+	refs := make([]string, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		if ref, ok := idx.accountRefs[id]; ok {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func policyBindingSortKey(b PolicyBinding) string {
+	order := map[string]string{"org": "0", "workspace": "1", "environment": "2", "connection": "3"}
+	return strings.Join([]string{
+		b.Resource.Workspace, order[b.Resource.Type], b.Resource.Environment, b.Resource.Connection,
+		b.Role.Workspace, b.Role.Name, b.Subject.Type, b.Subject.Name,
+	}, "\x00")
+}
+
+func (r PolicyResource) String() string {
+	switch r.Type {
+	case "org":
+		return "org"
+	case "workspace":
+		return "workspace " + r.Workspace
+	case "environment":
+		return "environment " + r.Workspace + "/" + r.Environment
+	default:
+		return "connection " + r.Workspace + "/" + r.Connection
+	}
+}
+
+func (r PolicyRoleRef) String() string {
+	if r.Workspace != "" {
+		return r.Workspace + "/" + r.Name
+	}
+	return r.Name
+}
+
+func (s PolicySubject) String() string {
+	if s.Name == "" {
+		return s.Type
+	}
+	return s.Type + " " + s.Name
+}
+
+// PolicyDocumentError lists the problems that stop a policy document from
+// being planned.
+type PolicyDocumentError struct {
+	Validator validator.Validator
+}
+
+func (e PolicyDocumentError) Error() string {
+	problems := slices.Clone(e.Validator.Errors)
+	for _, field := range sortedKeys(e.Validator.FieldErrors) {
+		problems = append(problems, field+": "+e.Validator.FieldErrors[field])
+	}
+	return "invalid policy document: " + strings.Join(problems, "; ")
+}
+
+// Errors returned when the importing account may not apply a plan.
+var (
+	errPolicyProtectedChange = errors.New("plan changes protected organization policy")
+	errPolicyTeamChange      = errors.New("plan changes teams")
+)
+
+// policyImportActor is the account importing a policy document and the
+// guarded permissions it holds. The zero value is infrastructure trust, for
+// which every change is permitted.
+type policyImportActor struct {
+	accountID   int64
+	permissions map[string]bool
+}
+
+// newPolicyImportActor looks up the guarded permissions accountID holds.
+// They are read before the import transaction starts: on SQLite the
+// transaction holds the only connection.
+func (app *application) newPolicyImportActor(ctx context.Context, orgID, accountID int64) policyImportActor {
+	actor := policyImportActor{accountID: accountID, permissions: make(map[string]bool)}
+	for _, permission := range []string{access.PermOrgDelete, access.PermOrgTransferOwnership, access.PermOrgWrite} {
+		actor.permissions[permission] = app.enforcer.Can(ctx, accountID, orgID, "org", "org", orgID, permission)
+	}
+	return actor
+}
+
+// authorize applies the privileged-grant guard to a plan: protected changes
+// require the permissions they grant or revoke, as for individual grants,
+// and team changes require org:write, as for the team endpoints.
+func (a policyImportActor) authorize(plan PolicyPlan) error {
+	if a.accountID == 0 {
+		return nil
+	}
+	for _, permission := range plan.protectedPermissions() {
+		if !a.permissions[permission] {
+			return errPolicyProtectedChange
+		}
+	}
+	if plan.changesTeams() && !a.permissions[access.PermOrgWrite] {
+		return errPolicyTeamChange
+	}
+	return nil
+}
+
+// PlanOrgPolicy returns the changes importing doc would make to the
+// organization with the given slug, without making them.
+func (app *application) PlanOrgPolicy(ctx context.Context, orgSlug string, doc PolicyDocument) (PolicyPlan, error) {
+	org, found, err := app.db.GetOrgBySlug(ctx, orgSlug)
+	if err != nil {
+		return PolicyPlan{}, err
+	}
+	if !found {
+		return PolicyPlan{}, fmt.Errorf("organization %q not found", orgSlug)
+	}
+	state, err := app.db.LoadOrgPolicyState(ctx, org.ID)
+	if err != nil {
+		return PolicyPlan{}, err
+	}
+	return planOrgPolicy(org.ID, state, doc)
+}
+
+// ApplyOrgPolicy imports doc into the organization with the given slug at
+// infrastructure trust level, so the privileged-grant guard does not apply;
+// builtin roles and the last Owner binding remain protected.
+func (app *application) ApplyOrgPolicy(ctx context.Context, orgSlug string, doc PolicyDocument) (PolicyPlan, error) {
+	org, found, err := app.db.GetOrgBySlug(ctx, orgSlug)
+	if err != nil {
+		return PolicyPlan{}, err
+	}
+	if !found {
+		return PolicyPlan{}, fmt.Errorf("organization %q not found", orgSlug)
+	}
+	plan, err := app.applyOrgPolicy(ctx, org.ID, policyImportActor{}, doc)
+	if err != nil {
+		return PolicyPlan{}, err
+	}
+	if len(plan.Changes) > 0 {
+		event := audit.Event{
+			OrgID:        &org.ID,
+			Action:       "org.policy.import",
+			ResourceType: "org",
+			ResourceID:   strconv.FormatInt(org.ID, 10),
+			Details:      plan,
+		}
+		if _, err := app.auditStore().Append(ctx, event); err != nil {
+			app.logger.Error("audit record append failed", "audit.action", event.Action, "error", err)
+		}
+	}
+	return plan, nil
+}
+
+// applyOrgPolicy plans doc and applies the plan in one transaction. The plan
+// is computed from state read inside the transaction, so it is exactly the
+// set of changes made, and any failure leaves the policy untouched.
+func (app *application) applyOrgPolicy(ctx context.Context, orgID int64, actor policyImportActor, doc PolicyDocument) (PolicyPlan, error) {
+	var plan PolicyPlan
+	err := app.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		state, err := app.db.LoadOrgPolicyStateWithExecutor(ctx, tx, orgID)
+		if err != nil {
+			return err
+		}
+		plan, err = planOrgPolicy(orgID, state, doc)
+		if err != nil {
+			return err
+		}
+		if err = actor.authorize(plan); err != nil {
+			return err
+		}
+
+		applier := &policyApplier{
+			app:     app,
+			exec:    tx,
+			actorID: actor.accountID,
+			roles:   make(map[policyRoleKey]int64, len(state.Roles)),
+			teams:   make(map[string]int64, len(state.Teams)),
+		}
+		for _, role := range state.Roles {
+			applier.roles[roleKey(role)] = role.ID
+		}
+		for _, team := range state.Teams {
+			applier.teams[team.Slug] = team.ID
+		}
+		for _, change := range plan.Changes {
+			if err := change.apply(ctx, applier); err != nil {
+				return fmt.Errorf("%s %s %s: %w", change.Action, change.Kind, change.Target, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return PolicyPlan{}, err
+	}
+
+	if len(plan.Changes) > 0 {
+		app.enforcer.InvalidateOrgPolicy(orgID)
+	}
+	invalidated := make(map[int64]bool)
+	for _, change := range plan.Changes {
+		for _, accountID := range change.principals {
+			if !invalidated[accountID] {
+				app.enforcer.InvalidatePrincipals(orgID, accountID)
+				invalidated[accountID] = true
+			}
+		}
+	}
+	return plan, nil
+}
+
+// desiredPolicyRole is a custom role as declared by a document.
+type desiredPolicyRole struct {
+	key  policyRoleKey
+	ref  PolicyRoleRef
+	role PolicyRole
+}
+
+// desiredPolicyBinding is a binding as declared by a document, with every
+// name other than the role and team resolved.
+type desiredPolicyBinding struct {
+	key     policyBindingKey
+	binding PolicyBinding
+}
+
+// policyPlanner diffs a document against the indexed state. Validation
+// problems are collected in v, keyed by their position in the document.
+type policyPlanner struct {
+	idx *policyIndex
+	doc PolicyDocument
+	v   validator.Validator
+
+	managedWorkspaces map[int64]bool
+	roles             map[policyRoleKey]desiredPolicyRole
+	teams             map[string]PolicyTeam
+	teamMembers       map[string][]int64
+	workspaceMembers  map[int64][]int64
+	workspaceTeams    map[int64][]string
+	bindings          []desiredPolicyBinding
+}
+
+// planOrgPolicy computes the changes that make state match doc. It returns a
+// PolicyDocumentError when the document is invalid against state.
+func planOrgPolicy(orgID int64, state database.OrgPolicyState, doc PolicyDocument) (PolicyPlan, error) {
+	p := &policyPlanner{
+		idx:               newPolicyIndex(orgID, state),
+		doc:               doc,
+		managedWorkspaces: make(map[int64]bool),
+		roles:             make(map[policyRoleKey]desiredPolicyRole),
+		teams:             make(map[string]PolicyTeam),
+		teamMembers:       make(map[string][]int64),
+		workspaceMembers:  make(map[int64][]int64),
+		workspaceTeams:    make(map[int64][]string),
+	}
+
+	p.v.CheckField(doc.Version == policyDocumentVersion, "version", fmt.Sprintf("Version must be %d.", policyDocumentVersion))
+	p.resolveWorkspaces()
+	p.resolveRoles()
+	p.resolveTeams()
+	p.resolveWorkspaceMemberships()
+	p.resolveBindings()
+	if p.v.HasErrors() {
+		return PolicyPlan{}, PolicyDocumentError{Validator: p.v}
+	}
+
+	plan := p.plan()
+	if p.v.HasErrors() {
+		return PolicyPlan{}, PolicyDocumentError{Validator: p.v}
+	}
+	return plan, nil
+}
+
+func (p *policyPlanner) lookupWorkspace(field, name string) (int64, bool) {
+	ids := p.idx.workspaceIDs[name]
+	switch {
+	case name == "":
+		p.v.AddFieldError(field, "Workspace is required.")
+	case len(ids) == 0:
+		p.v.AddFieldError(field, fmt.Sprintf("Workspace %q does not exist.", name))
+	case len(ids) > 1:
+		p.v.AddFieldError(field, fmt.Sprintf("Workspace name %q is not unique.", name))
+	default:
+		return ids[0], true
+	}
+	return 0, false
+}
+
+func (p *policyPlanner) lookupManagedWorkspace(field, name string) (int64, bool) {
+	id, ok := p.lookupWorkspace(field, name)
+	if ok && !p.managedWorkspaces[id] {
+		p.v.AddFieldError(field, fmt.Sprintf("Workspace %q must be listed under workspaces.", name))
+		return 0, false
+	}
+	return id, ok
+}
+
+func (p *policyPlanner) lookupAccounts(field string, refs []string) []int64 {
+	ids := make([]int64, 0, len(refs))
+	for i, ref := range refs {
+		id, ok := p.idx.accountIDs[strings.ToLower(strings.TrimSpace(ref))]
+		if !ok {
+			p.v.AddFieldError(fmt.Sprintf("%s[%d]", field, i), fmt.Sprintf("%q is not a member of this organization.", ref))
+			continue
+		}
+		if slices.Contains(ids, id) {
+			p.v.AddFieldError(fmt.Sprintf("%s[%d]", field, i), fmt.Sprintf("%q is listed more than once.", ref))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (p *policyPlanner) resolveWorkspaces() {
+	for i, ws := range p.doc.Workspaces {
+		id, ok := p.lookupWorkspace(fmt.Sprintf("workspaces[%d].name", i), ws.Name)
+		if !ok {
+			continue
+		}
+		if p.managedWorkspaces[id] {
+			p.v.AddFieldError(fmt.Sprintf("workspaces[%d].name", i), fmt.Sprintf("Workspace %q is listed more than once.", ws.Name))
+			continue
+		}
+		p.managedWorkspaces[id] = true
+	}
+}
+
+func (p *policyPlanner) resolveRoles() {
+	validScopes := map[string]bool{"org": true, "workspace": true, "environment": true, "connection": true}
+	for i, role := range p.doc.Roles {
+		field := fmt.Sprintf("roles[%d]", i)
+		key := policyRoleKey{Name: role.Name}
+		if role.Workspace != "" {
+			id, ok := p.lookupManagedWorkspace(field+".workspace", role.Workspace)
+			if !ok {
+				continue
+			}
+			key.WorkspaceID = id
+		}
+		p.v.CheckField(role.Name != "", field+".name", "Name is required.")
+		if !validScopes[role.Scope] || (role.Workspace != "" && role.Scope == "org") {
+			p.v.AddFieldError(field+".scope", "Scope must be org, workspace, environment, or connection; workspace roles cannot have org scope.")
+			continue
+		}
+		for _, permission := range role.Permissions {
+			p.v.CheckField(access.ValidForScope(permission, role.Scope), field+".permissions", "Permission "+permission+" is not valid for "+role.Scope+" scope.")
+		}
+		if id, ok := p.idx.roleIDs[key]; ok {
+			existing := p.idx.roles[id]
+			if existing.IsBuiltin {
+				p.v.AddFieldError(field+".name", "Builtin roles cannot be changed.")
+				continue
+			}
+			p.v.CheckField(existing.ScopeType == role.Scope, field+".scope", "The scope of an existing role cannot be changed.")
+		}
+		if _, ok := p.roles[key]; ok {
+			p.v.AddFieldError(field+".name", fmt.Sprintf("Role %q is listed more than once.", role.Name))
+			continue
+		}
+		p.roles[key] = desiredPolicyRole{key: key, ref: PolicyRoleRef{Name: role.Name, Workspace: role.Workspace}, role: role}
+	}
+}
+
+func (p *policyPlanner) resolveTeams() {
+	for i, team := range p.doc.Teams {
+		field := fmt.Sprintf("teams[%d]", i)
+		p.v.CheckField(team.Name != "", field+".name", "Name is required.")
+		if !isValidSlug(team.Slug) {
+			p.v.AddFieldError(field+".slug", "Slug may only contain lowercase letters, numbers, and hyphens.")
+			continue
+		}
+		if _, ok := p.teams[team.Slug]; ok {
+			p.v.AddFieldError(field+".slug", fmt.Sprintf("Team %q is listed more than once.", team.Slug))
+			continue
+		}
+		p.teams[team.Slug] = team
+		p.teamMembers[team.Slug] = p.lookupAccounts(field+".members", team.Members)
+	}
+}
+
+func (p *policyPlanner) resolveWorkspaceMemberships() {
+	for i, ws := range p.doc.Workspaces {
+		field := fmt.Sprintf("workspaces[%d]", i)
+		ids := p.idx.workspaceIDs[ws.Name]
+		if len(ids) != 1 {
+			continue
+		}
+		p.workspaceMembers[ids[0]] = p.lookupAccounts(field+".members", ws.Members)
+		var teams []string
+		for j, slug := range ws.Teams {
+			teamField := fmt.Sprintf("%s.teams[%d]", field, j)
+			if _, ok := p.teams[slug]; !ok {
+				p.v.AddFieldError(teamField, fmt.Sprintf("Team %q is not listed under teams.", slug))
+				continue
+			}
+			if slices.Contains(teams, slug) {
+				p.v.AddFieldError(teamField, fmt.Sprintf("Team %q is listed more than once.", slug))
+				continue
+			}
+			teams = append(teams, slug)
+		}
+		p.workspaceTeams[ids[0]] = teams
+	}
+}
+
+// lookupRole resolves a role reference to a custom role declared by the
+// document or an existing builtin role.
+func (p *policyPlanner) lookupRole(field string, ref PolicyRoleRef) (policyRoleKey, string, bool) {
+	key := policyRoleKey{Name: ref.Name}
+	if ref.Workspace != "" {
+		id, ok := p.lookupManagedWorkspace(field+".workspace", ref.Workspace)
+		if !ok {
+			return policyRoleKey{}, "", false
+		}
+		key.WorkspaceID = id
+	}
+	if role, ok := p.roles[key]; ok {
+		return key, role.role.Scope, true
+	}
+	if id, ok := p.idx.roleIDs[key]; ok && p.idx.roles[id].IsBuiltin {
+		return key, p.idx.roles[id].ScopeType, true
+	}
+	p.v.AddFieldError(field+".name", fmt.Sprintf("Role %q is neither builtin nor listed under roles.", ref))
+	return policyRoleKey{}, "", false
+}
+
+func (p *policyPlanner) lookupResource(field string, resource PolicyResource) (int64, int64, bool) {
+	switch resource.Type {
+	case "org":
+		return p.idx.orgID, 0, true
+	case "workspace", "environment", "connection":
+	default:
+		p.v.AddFieldError(field+".type", "Resource type must be org, workspace, environment, or connection.")
+		return 0, 0, false
+	}
+	wsID, ok := p.lookupManagedWorkspace(field+".workspace", resource.Workspace)
+	if !ok {
+		return 0, 0, false
+	}
+	var ids []int64
+	var name string
+	switch resource.Type {
+	case "workspace":
+		return wsID, wsID, true
+	case "environment":
+		name = resource.Environment
+		ids = p.idx.environmentIDs[policyResourceKey{WorkspaceID: wsID, Name: name}]
+	case "connection":
+		name = resource.Connection
+		ids = p.idx.connectionIDs[policyResourceKey{WorkspaceID: wsID, Name: name}]
+	}
+	switch {
+	case len(ids) == 0:
+		p.v.AddFieldError(field+"."+resource.Type, fmt.Sprintf("%s %q does not exist in workspace %q.", capitalize(resource.Type), name, resource.Workspace))
+	case len(ids) > 1:
+		p.v.AddFieldError(field+"."+resource.Type, fmt.Sprintf("%s name %q is not unique in workspace %q.", capitalize(resource.Type), name, resource.Workspace))
+	default:
+		return ids[0], wsID, true
+	}
+	return 0, 0, false
+}
+
+func (p *policyPlanner) resolveBindings() {
+	seen := make(map[policyBindingKey]bool)
+	for i, binding := range p.doc.Bindings {
+		field := fmt.Sprintf("bindings[%d]", i)
+		roleKey, roleScope, roleOK := p.lookupRole(field+".role", binding.Role)
+		resourceID, resourceWorkspaceID, resourceOK := p.lookupResource(field+".resource", binding.Resource)
+		if !roleOK || !resourceOK {
+			continue
+		}
+		if roleScope != binding.Resource.Type || (binding.Resource.Type == "org" && roleKey.WorkspaceID != 0) {
+			p.v.AddFieldError(field+".role", "Role scope must match resource type.")
+			continue
+		}
+		if roleKey.WorkspaceID != 0 && roleKey.WorkspaceID != resourceWorkspaceID {
+			p.v.AddFieldError(field+".role", "Workspace roles can only be bound within their workspace.")
+			continue
+		}
+
+		key := policyBindingKey{Role: roleKey, SubjectType: binding.Subject.Type, ResourceType: binding.Resource.Type, ResourceID: resourceID}
+		subjectField := field + ".subject"
+		switch binding.Subject.Type {
+		case access.SubjectTypeAccount:
+			id, ok := p.idx.accountIDs[strings.ToLower(strings.TrimSpace(binding.Subject.Name))]
+			if !ok {
+				p.v.AddFieldError(subjectField+".name", fmt.Sprintf("%q is not a member of this organization.", binding.Subject.Name))
+				continue
+			}
+			key.SubjectRef = strconv.FormatInt(id, 10)
+		case access.SubjectTypeTeam:
+			if _, ok := p.teams[binding.Subject.Name]; !ok {
+				p.v.AddFieldError(subjectField+".name", fmt.Sprintf("Team %q is not listed under teams.", binding.Subject.Name))
+				continue
+			}
+			key.SubjectRef = binding.Subject.Name
+		case access.SubjectTypeOrgMembers:
+			key.SubjectRef = strconv.FormatInt(p.idx.orgID, 10)
+		case access.SubjectTypeWorkspaceMembers:
+			ids := p.idx.workspaceIDs[binding.Subject.Name]
+			if binding.Resource.Type == "org" || len(ids) != 1 || ids[0] != resourceWorkspaceID {
+				p.v.AddFieldError(subjectField+".name", "Workspace members can only be granted roles within their own workspace.")
+				continue
+			}
+			key.SubjectRef = strconv.FormatInt(resourceWorkspaceID, 10)
+		default:
+			p.v.AddFieldError(subjectField+".type", "Subject type must be account, team, org_members, or workspace_members.")
+			continue
+		}
+
+		if seen[key] {
+			p.v.AddFieldError(field, "Binding is listed more than once.")
+			continue
+		}
+		seen[key] = true
+		p.bindings = append(p.bindings, desiredPolicyBinding{key: key, binding: binding})
+	}
+
+	hasOwner := false
+	for _, b := range p.bindings {
+		if b.key.Role.WorkspaceID == 0 && b.key.Role.Name == access.BuiltinOrgOwnerRole && b.key.ResourceType == "org" {
+			hasOwner = true
+			break
+		}
+	}
+	p.v.Check(hasOwner, "The policy must keep at least one binding of the organization Owner role.")
+}
+
+// managed reports whether an existing binding is within the document's
+// scope: bound on the org or inside a listed workspace, and not a temporary
+// grant.
+func (p *policyPlanner) managed(binding database.RoleBinding) bool {
+	if binding.ExpiresAt != nil {
+		return false
+	}
+	if binding.ResourceType == "org" {
+		return true
+	}
+	return p.managedWorkspaces[p.idx.resourceWorkspaceID(binding.ResourceType, binding.ResourceID)]
+}
+
+func (p *policyPlanner) existingBindingKey(binding database.RoleBinding) policyBindingKey {
+	key := policyBindingKey{
+		Role:         roleKey(p.idx.roles[binding.RoleID]),
+		SubjectType:  binding.SubjectType,
+		SubjectRef:   strconv.FormatInt(binding.SubjectID, 10),
+		ResourceType: binding.ResourceType,
+		ResourceID:   binding.ResourceID,
+	}
+	if binding.SubjectType == access.SubjectTypeTeam {
+		key.SubjectRef = p.idx.teams[binding.SubjectID].Slug
+	}
+	return key
+}
+
+func (p *policyPlanner) plan() PolicyPlan {
+	var (
+		removeBindings, removeMemberships, removeRoles, removeTeams []PolicyChange
+		upsertRoles, upsertTeams, addMemberships, addBindings       []PolicyChange
+	)
+	orgID := p.idx.orgID
+
+	// Teams holding a protected org binding before or after the change:
+	// their membership grants the protected permissions.
+	protectedTeams := make(map[string][]string)
+	desiredKeys := make(map[policyBindingKey]bool, len(p.bindings))
+	for _, b := range p.bindings {
+		desiredKeys[b.key] = true
+		if b.key.SubjectType == access.SubjectTypeTeam {
+			protectedTeams[b.key.SubjectRef] = append(protectedTeams[b.key.SubjectRef], protectedOrgPolicyPermissions(p.desiredRole(b.key.Role))...)
+		}
+	}
+
+	existingKeys := make(map[policyBindingKey]bool)
+	retainedRoles := make(map[int64]bool)
+	retainedTeams := make(map[int64]bool)
+	for _, binding := range p.idx.state.Bindings {
+		key := p.existingBindingKey(binding)
+		existingKeys[key] = true
+		if binding.SubjectType == access.SubjectTypeTeam {
+			protectedTeams[key.SubjectRef] = append(protectedTeams[key.SubjectRef], protectedOrgPolicyPermissions(p.idx.roles[binding.RoleID])...)
+		}
+		if !p.managed(binding) {
+			retainedRoles[binding.RoleID] = true
+			if binding.SubjectType == access.SubjectTypeTeam {
+				retainedTeams[binding.SubjectID] = true
+			}
+			continue
+		}
+		if desiredKeys[key] {
+			continue
+		}
+		bindingID := binding.ID
+		role := p.idx.roles[binding.RoleID]
+		desc := p.describeBinding(binding)
+		removeBindings = append(removeBindings, PolicyChange{
+			Action:    "delete",
+			Kind:      policyChangeBinding,
+			Target:    desc,
+			protected: protectedOrgPolicyPermissions(role),
+			apply: func(ctx context.Context, a *policyApplier) error {
+				return a.app.enforcer.UnbindRoleWithExecutor(ctx, a.exec, bindingID, orgID)
+			},
+		})
+	}
+
+	for _, b := range p.bindings {
+		if existingKeys[b.key] {
+			continue
+		}
+		b := b
+		addBindings = append(addBindings, PolicyChange{
+			Action:    "create",
+			Kind:      policyChangeBinding,
+			Target:    fmt.Sprintf("%s to %s on %s", b.binding.Role, b.binding.Subject, b.binding.Resource),
+			protected: protectedOrgPolicyPermissions(p.desiredRole(b.key.Role)),
+			apply: func(ctx context.Context, a *policyApplier) error {
+				subjectID := a.teams[b.key.SubjectRef]
+				if b.key.SubjectType != access.SubjectTypeTeam {
+					subjectID, _ = strconv.ParseInt(b.key.SubjectRef, 10, 64)
+				}
+				return a.app.enforcer.BindRoleWithExecutor(ctx, a.exec, orgID, a.roles[b.key.Role], b.key.SubjectType, subjectID, b.key.ResourceType, b.key.ResourceID, a.actorID)
+			},
+		})
+	}
+
+	// Custom roles: every org role and the roles of listed workspaces.
+	for _, role := range p.idx.state.Roles {
+		key := roleKey(role)
+		if role.IsBuiltin || (key.WorkspaceID != 0 && !p.managedWorkspaces[key.WorkspaceID]) {
+			continue
+		}
+		if _, ok := p.roles[key]; ok {
+			continue
+		}
+		if retainedRoles[role.ID] {
+			p.v.AddError(fmt.Sprintf("Role %q is still bound in a workspace not listed under workspaces.", role.Name))
+			continue
+		}
+		roleID := role.ID
+		removeRoles = append(removeRoles, PolicyChange{
+			Action:    "delete",
+			Kind:      policyChangeRole,
+			Target:    p.describeRole(key),
+			protected: protectedOrgPolicyPermissions(role),
+			apply: func(ctx context.Context, a *policyApplier) error {
+				return a.app.enforcer.DeleteRoleWithExecutor(ctx, a.exec, roleID, orgID)
+			},
+		})
+	}
+	for _, desired := range p.sortedDesiredRoles() {
+		desired := desired
+		permissions := slices.Clone(desired.role.Permissions)
+		sort.Strings(permissions)
+		permissions = slices.Compact(permissions)
+		newRole := database.Role{Name: desired.role.Name, ScopeType: desired.role.Scope, Permissions: permissions}
+		id, exists := p.idx.roleIDs[desired.key]
+		if !exists {
+			var workspaceID *int64
+			if desired.key.WorkspaceID != 0 {
+				wsID := desired.key.WorkspaceID
+				workspaceID = &wsID
+			}
+			upsertRoles = append(upsertRoles, PolicyChange{
+				Action:    "create",
+				Kind:      policyChangeRole,
+				Target:    desired.ref.String(),
+				protected: protectedOrgPolicyPermissions(newRole),
+				apply: func(ctx context.Context, a *policyApplier) error {
+					roleID, err := a.app.enforcer.CreateRoleWithExecutor(ctx, a.exec, orgID, workspaceID, desired.role.Name, desired.role.Description, desired.role.Scope, permissions)
+					a.roles[desired.key] = roleID
+					return err
+				},
+			})
+			continue
+		}
+		existing := p.idx.roles[id]
+		current := slices.Clone(existing.Permissions)
+		sort.Strings(current)
+		if existing.Description == desired.role.Description && slices.Equal(current, permissions) {
+			continue
+		}
+		upsertRoles = append(upsertRoles, PolicyChange{
+			Action:    "update",
+			Kind:      policyChangeRole,
+			Target:    desired.ref.String(),
+			protected: mergePermissions(protectedOrgPolicyPermissions(existing), protectedOrgPolicyPermissions(newRole)),
+			apply: func(ctx context.Context, a *policyApplier) error {
+				return a.app.enforcer.UpdateRoleWithExecutor(ctx, a.exec, id, orgID, desired.role.Name, desired.role.Description, permissions)
+			},
+		})
+	}
+
+	// Teams and team members.
+	for _, team := range p.idx.state.Teams {
+		if _, ok := p.teams[team.Slug]; ok {
+			continue
+		}
+		if retainedTeams[team.ID] {
+			p.v.AddError(fmt.Sprintf("Team %q is still bound in a workspace not listed under workspaces.", team.Slug))
+			continue
+		}
+		teamID := team.ID
+		removeTeams = append(removeTeams, PolicyChange{
+			Action:     "delete",
+			Kind:       policyChangeTeam,
+			Target:     team.Slug,
+			protected:  protectedTeams[team.Slug],
+			principals: p.idx.teamMembers[team.ID],
+			apply: func(ctx context.Context, a *policyApplier) error {
+				return a.app.db.DeleteTeamWithExecutor(ctx, a.exec, teamID, orgID)
+			},
+		})
+	}
+	for _, slug := range sortedKeys(p.teams) {
+		team := p.teams[slug]
+		id, exists := p.idx.teamIDs[slug]
+		switch {
+		case !exists:
+			upsertTeams = append(upsertTeams, PolicyChange{
+				Action: "create",
+				Kind:   policyChangeTeam,
+				Target: slug,
+				apply: func(ctx context.Context, a *policyApplier) error {
+					created, err := a.app.db.InsertTeamWithExecutor(ctx, a.exec, orgID, team.Slug, team.Name)
+					a.teams[team.Slug] = created.ID
+					return err
+				},
+			})
+		case p.idx.teams[id].Name != team.Name:
+			upsertTeams = append(upsertTeams, PolicyChange{
+				Action: "update",
+				Kind:   policyChangeTeam,
+				Target: slug,
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.UpdateTeamWithExecutor(ctx, a.exec, id, orgID, team.Name)
+				},
+			})
+		}
+
+		current := p.idx.teamMembers[id]
+		for _, accountID := range p.teamMembers[slug] {
+			if slices.Contains(current, accountID) {
+				continue
+			}
+			accountID := accountID
+			addMemberships = append(addMemberships, PolicyChange{
+				Action:     "create",
+				Kind:       policyChangeTeamMember,
+				Target:     slug + ": " + p.idx.accountRefs[accountID],
+				protected:  protectedTeams[slug],
+				principals: []int64{accountID},
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.AddTeamMemberWithExecutor(ctx, a.exec, a.teams[slug], accountID)
+				},
+			})
+		}
+		if !exists {
+			continue
+		}
+		for _, accountID := range current {
+			if slices.Contains(p.teamMembers[slug], accountID) {
+				continue
+			}
+			accountID := accountID
+			removeMemberships = append(removeMemberships, PolicyChange{
+				Action:     "delete",
+				Kind:       policyChangeTeamMember,
+				Target:     slug + ": " + p.idx.accountRefs[accountID],
+				protected:  protectedTeams[slug],
+				principals: []int64{accountID},
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.RemoveTeamMemberWithExecutor(ctx, a.exec, id, accountID)
+				},
+			})
+		}
+	}
+
+	// Workspace members and teams of listed workspaces.
+	currentMembers := make(map[int64][]int64)
+	for _, member := range p.idx.state.WorkspaceMembers {
+		currentMembers[member.WorkspaceID] = append(currentMembers[member.WorkspaceID], member.AccountID)
+	}
+	currentTeams := make(map[int64][]string)
+	for _, team := range p.idx.state.WorkspaceTeams {
+		currentTeams[team.WorkspaceID] = append(currentTeams[team.WorkspaceID], p.idx.teams[team.TeamID].Slug)
+	}
+	for _, ws := range p.idx.state.Workspaces {
+		if !p.managedWorkspaces[ws.ID] {
+			continue
+		}
+		wsID := ws.ID
+		for _, accountID := range currentMembers[wsID] {
+			if slices.Contains(p.workspaceMembers[wsID], accountID) {
+				continue
+			}
+			accountID := accountID
+			removeMemberships = append(removeMemberships, PolicyChange{
+				Action:     "delete",
+				Kind:       policyChangeWorkspaceMember,
+				Target:     ws.Name + ": " + p.accountRef(accountID),
+				principals: []int64{accountID},
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.RemoveWorkspaceMemberWithExecutor(ctx, a.exec, wsID, accountID)
+				},
+			})
+		}
+		for _, accountID := range p.workspaceMembers[wsID] {
+			if slices.Contains(currentMembers[wsID], accountID) {
+				continue
+			}
+			accountID := accountID
+			addMemberships = append(addMemberships, PolicyChange{
+				Action:     "create",
+				Kind:       policyChangeWorkspaceMember,
+				Target:     ws.Name + ": " + p.idx.accountRefs[accountID],
+				principals: []int64{accountID},
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.AddWorkspaceMemberWithExecutor(ctx, a.exec, wsID, accountID, a.createdBy())
+				},
+			})
+		}
+		for _, slug := range currentTeams[wsID] {
+			if slices.Contains(p.workspaceTeams[wsID], slug) {
+				continue
+			}
+			teamID := p.idx.teamIDs[slug]
+			// Deleted teams lose their workspace memberships with them.
+			if _, kept := p.teams[slug]; !kept {
+				continue
+			}
+			removeMemberships = append(removeMemberships, PolicyChange{
+				Action:     "delete",
+				Kind:       policyChangeWorkspaceTeam,
+				Target:     ws.Name + ": " + slug,
+				principals: p.idx.teamMembers[teamID],
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.RemoveWorkspaceTeamWithExecutor(ctx, a.exec, wsID, teamID)
+				},
+			})
+		}
+		for _, slug := range p.workspaceTeams[wsID] {
+			if slices.Contains(currentTeams[wsID], slug) {
+				continue
+			}
+			slug := slug
+			addMemberships = append(addMemberships, PolicyChange{
+				Action:     "create",
+				Kind:       policyChangeWorkspaceTeam,
+				Target:     ws.Name + ": " + slug,
+				principals: mergeAccountIDs(p.idx.teamMembers[p.idx.teamIDs[slug]], p.teamMembers[slug]),
+				apply: func(ctx context.Context, a *policyApplier) error {
+					return a.app.db.AddWorkspaceTeamWithExecutor(ctx, a.exec, wsID, a.teams[slug], a.createdBy())
+				},
+			})
+		}
+	}
+
+	// Bindings go before the roles and teams they reference are deleted, and
+	// after the roles and teams they reference are created.
+	var changes []PolicyChange
+	for _, group := range [][]PolicyChange{removeBindings, removeMemberships, removeRoles, removeTeams, upsertRoles, upsertTeams, addMemberships, addBindings} {
+		changes = append(changes, group...)
+	}
+	for i := range changes {
+		changes[i].Protected = len(changes[i].protected) > 0
+	}
+	if changes == nil {
+		changes = []PolicyChange{}
+	}
+	return PolicyPlan{Changes: changes}
+}
+
+// desiredRole returns a custom or builtin role as it will be once the plan
+// is applied.
+func (p *policyPlanner) desiredRole(key policyRoleKey) database.Role {
+	if role, ok := p.roles[key]; ok {
+		return database.Role{Name: role.role.Name, ScopeType: role.role.Scope, Permissions: role.role.Permissions}
+	}
+	return p.idx.roles[p.idx.roleIDs[key]]
+}
+
+func (p *policyPlanner) sortedDesiredRoles() []desiredPolicyRole {
+	roles := make([]desiredPolicyRole, 0, len(p.roles))
+	for _, role := range p.roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].ref.String() < roles[j].ref.String() })
+	return roles
+}
+
+func (p *policyPlanner) accountRef(accountID int64) string {
+	if ref, ok := p.idx.accountRefs[accountID]; ok {
+		return ref
+	}
+	return "account " + strconv.FormatInt(accountID, 10)
+}
+
+func (p *policyPlanner) describeRole(key policyRoleKey) string {
+	if key.WorkspaceID != 0 {
+		return p.idx.workspaces[key.WorkspaceID] + "/" + key.Name
+	}
+	return key.Name
+}
+
+func (p *policyPlanner) describeBinding(binding database.RoleBinding) string {
+	role := p.describeRole(roleKey(p.idx.roles[binding.RoleID]))
+	subject := PolicySubject{Type: binding.SubjectType}
+	switch binding.SubjectType {
+	case access.SubjectTypeAccount:
+		subject.Name = p.accountRef(binding.SubjectID)
+	case access.SubjectTypeTeam:
+		subject.Name = p.idx.teams[binding.SubjectID].Slug
+	case access.SubjectTypeWorkspaceMembers:
+		subject.Name = p.idx.workspaces[binding.SubjectID]
+	}
+	resource := PolicyResource{Type: binding.ResourceType}
+	if binding.ResourceType != "org" {
+		resource.Workspace = p.idx.workspaces[p.idx.resourceWorkspaceID(binding.ResourceType, binding.ResourceID)]
+		resource.Environment = p.idx.environments[binding.ResourceID].Name
+		resource.Connection = p.idx.connections[binding.ResourceID].Name
+	}
+	return fmt.Sprintf("%s to %s on %s", role, subject, resource)
+}
+
+func mergePermissions(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, permission := range b {
+		if !slices.Contains(merged, permission) {
+			merged = append(merged, permission)
+		}
+	}
+	return merged
+}
+
+func mergeAccountIDs(a, b []int64) []int64 {
+	merged := slices.Clone(a)
+	for _, id := range b {
+		if !slices.Contains(merged, id) {
+			merged = append(merged, id)
+		}
+	}
+	return merged
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
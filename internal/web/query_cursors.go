@@ -12,6 +12,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/sqlwarden/internal/access"
 	"github.com/sqlwarden/internal/connection"
+	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/engine/classifier"
 	"github.com/sqlwarden/internal/engine/cursor"
 	"github.com/sqlwarden/internal/request"
@@ -23,9 +24,10 @@ import (
 const apiErrorQueryCursorUnavailable = "query_cursor_unavailable"
 
 type queryCursorRequest struct {
-	SQL      string              `json:"sql"`
-	PageSize *int                `json:"page_size"`
-	V        validator.Validator `json:"-"`
+	SQL                string              `json:"sql"`
+	PageSize           *int                `json:"page_size"`
+	ConfirmEnvironment string              `json:"confirm_environment"`
+	V                  validator.Validator `json:"-"`
 }
 
 type queryCursorFetchRequest struct {
@@ -43,6 +45,7 @@ type queryCursorPageResponse struct {
 	TruncationReason string          `json:"truncation_reason,omitempty"`
 	Exhausted        bool            `json:"exhausted"`
 	PageSize         int             `json:"page_size"`
+	Endpoint         string          `json:"endpoint,omitempty"`
 }
 
 func (app *application) queryCursorManager() *connection.QueryCursorManager {
@@ -73,14 +76,35 @@ func (app *application) startQueryCursor(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
+	runtimeSettings, err = app.connectionRuntimeSettings(r.Context(), contextGetConnection(r), runtimeSettings)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	pageSize := queryCursorPageSize(input.PageSize, runtimeSettings)
 	session, ok := app.resolveQueryRuntimeSession(w, r, input.SQL)
 	if !ok {
 		return
 	}
 
+	conn := contextGetConnection(r)
+	classification, err := app.classifyConnectionSQL(r, conn, input.SQL)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !app.enforceEnvironmentProtection(w, r, conn, input.SQL, classification.Kind, input.ConfirmEnvironment) {
+		return
+	}
+	startCursor := session.StartQueryCursor
+	if session.HasReplicas() && classification.Kind == classifier.KindDQL {
+		startCursor = session.StartReadCursor
+	}
+
+	r, cancelQuery := withQueryDeadline(r, runtimeSettings.QueryMaxExecutionTime)
+	defer cancelQuery()
 	start := time.Now()
-	cursor, err := session.StartQueryCursor(queryCursorLifetimeContext(r.Context()), input.SQL)
+	cursor, err := startCursor(queryCursorLifetimeContext(r.Context()), input.SQL)
 	if err != nil {
 		if errors.Is(err, connection.ErrQueryCursorsUnsupported) {
 			app.logWarn(r, "query cursor unsupported",
@@ -90,6 +114,15 @@ func (app *application) startQueryCursor(w http.ResponseWriter, r *http.Request)
 			app.errorMessage(w, r, http.StatusUnprocessableEntity, "Connection driver does not support query cursors.", nil)
 			return
 		}
+		if isQueryTimeout(r, err) {
+			app.logWarn(r, "query cursor start timed out",
+				slog.String("session_id", session.ID),
+				slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+				slog.Int64("limit_seconds", int64(runtimeSettings.QueryMaxExecutionTime.Seconds())),
+			)
+			app.queryTimedOut(w, r, runtimeSettings.QueryMaxExecutionTime)
+			return
+		}
 		if app.isQueryRequestCanceled(r, err) {
 			app.connManager.Remove(session.ID)
 			app.logDebug(r, "query cursor start cancelled",
@@ -116,6 +149,16 @@ func (app *application) startQueryCursor(w http.ResponseWriter, r *http.Request)
 	rs, state, err := cursor.Fetch(r.Context(), queryCursorScanOptions(pageSize, runtimeSettings))
 	if err != nil {
 		app.queryCursorManager().Remove(qc.ID)
+		if isQueryTimeout(r, err) {
+			app.logWarn(r, "query cursor initial fetch timed out",
+				queryCursorRecordAttrs(qc,
+					slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+					slog.Int64("limit_seconds", int64(runtimeSettings.QueryMaxExecutionTime.Seconds())),
+				)...,
+			)
+			app.queryTimedOut(w, r, runtimeSettings.QueryMaxExecutionTime)
+			return
+		}
 		if app.isQueryRequestCanceled(r, err) {
 			app.connManager.Remove(session.ID)
 			app.logDebug(r, "query cursor initial fetch cancelled",
@@ -139,6 +182,8 @@ func (app *application) startQueryCursor(w http.ResponseWriter, r *http.Request)
 	if state.Exhausted {
 		qc.MarkExhausted()
 		app.queryCursorManager().Remove(qc.ID)
+	} else {
+		app.recordLiveSessionRoute(r, database.LiveRouteQueryCursor, qc.ID, session.ID, contextGetConnection(r))
 	}
 
 	app.logDebug(r, "query cursor started",
@@ -173,6 +218,11 @@ func (app *application) fetchQueryCursor(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
+	runtimeSettings, err = app.connectionRuntimeSettings(r.Context(), contextGetConnection(r), runtimeSettings)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	pageSize := queryCursorPageSize(input.PageSize, runtimeSettings)
 	qc, ok := app.resolveQueryCursorRecord(w, r)
 	if !ok {
@@ -203,9 +253,22 @@ func (app *application) fetchQueryCursor(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	r, cancelQuery := withQueryDeadline(r, runtimeSettings.QueryMaxExecutionTime)
+	defer cancelQuery()
 	start := time.Now()
 	rs, state, err := qc.Cursor.Fetch(r.Context(), queryCursorScanOptions(pageSize, runtimeSettings))
 	if err != nil {
+		if isQueryTimeout(r, err) {
+			app.queryCursorManager().Remove(qc.ID)
+			app.logWarn(r, "query cursor fetch timed out",
+				queryCursorRecordAttrs(qc,
+					slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+					slog.Int64("limit_seconds", int64(runtimeSettings.QueryMaxExecutionTime.Seconds())),
+				)...,
+			)
+			app.queryTimedOut(w, r, runtimeSettings.QueryMaxExecutionTime)
+			return
+		}
 		if app.isQueryRequestCanceled(r, err) {
 			app.logDebug(r, "query cursor fetch cancelled",
 				queryCursorRecordAttrs(qc,
@@ -349,6 +412,7 @@ func (app *application) writeQueryCursorPage(w http.ResponseWriter, r *http.Requ
 		TruncationReason: rs.TruncationReason,
 		Exhausted:        exhausted,
 		PageSize:         pageSize,
+		Endpoint:         rs.Endpoint,
 	}
 	if err := response.JSON(w, http.StatusOK, payload); err != nil {
 		app.serverError(w, r, err)
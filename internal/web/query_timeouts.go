@@ -0,0 +1,118 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/response"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const apiErrorQueryTimeout = "query_timeout"
+
+// queryDeadlineGrace is how far past the statement time limit a query
+// request's own deadline falls. The engine's native timeout normally stops
+// the statement first and leaves the session usable; the deadline catches
+// statements the engine does not limit, such as writes on MySQL.
+const queryDeadlineGrace = 2 * time.Second
+
+var errQueryTimeLimit = errors.New("query time limit exceeded")
+
+// tightenQueryExecutionLimit applies an organization, environment, or
+// connection override in seconds to limit, where zero means unlimited. An
+// override only ever shortens the limit.
+func tightenQueryExecutionLimit(limit time.Duration, seconds *int64) time.Duration {
+	if seconds == nil || *seconds <= 0 {
+		return limit
+	}
+	override := time.Duration(*seconds) * time.Second
+	if limit == 0 || override < limit {
+		return override
+	}
+	return limit
+}
+
+// queryExecutionLimitAllowed reports whether seconds may override parent: it
+// must be positive and no longer than parent unless parent is unlimited.
+func queryExecutionLimitAllowed(seconds int64, parent time.Duration) bool {
+	if seconds <= 0 || seconds > maxRuntimeDurationSeconds {
+		return false
+	}
+	return parent == 0 || time.Duration(seconds)*time.Second <= parent
+}
+
+// connectionRuntimeSettings narrows a workspace's settings to conn, whose
+// environment and then conn itself may tighten the statement time limit.
+func (app *application) connectionRuntimeSettings(ctx context.Context, conn database.Connection, settings effectiveRuntimeSettings) (effectiveRuntimeSettings, error) {
+	env, found, err := app.db.GetEnvironment(ctx, conn.EnvironmentID)
+	if err != nil {
+		return effectiveRuntimeSettings{}, err
+	}
+	if found {
+		settings.QueryMaxExecutionTime = tightenQueryExecutionLimit(settings.QueryMaxExecutionTime, env.QueryMaxExecutionSeconds)
+	}
+	settings.QueryMaxExecutionTime = tightenQueryExecutionLimit(settings.QueryMaxExecutionTime, conn.QueryMaxExecutionSeconds)
+	return settings, nil
+}
+
+// environmentQueryExecutionLimit returns the limit a connection in envID
+// starts from, the workspace's as tightened by the environment. A nil envID
+// is the workspace's default environment.
+func (app *application) environmentQueryExecutionLimit(ctx context.Context, ws database.Workspace, envID *int64) (time.Duration, error) {
+	settings, err := app.effectiveRuntimeSettingsForWorkspace(ctx, ws)
+	if err != nil {
+		return 0, err
+	}
+	conn := database.Connection{WorkspaceID: ws.ID}
+	if envID != nil {
+		conn.EnvironmentID = *envID
+	} else if conn.EnvironmentID, err = app.db.DefaultEnvironmentID(ctx, ws.ID); err != nil {
+		return 0, err
+	}
+	settings, err = app.connectionRuntimeSettings(ctx, conn, settings)
+	return settings.QueryMaxExecutionTime, err
+}
+
+// validateQueryExecutionOverride checks an environment or connection override
+// against the limit it tightens.
+func validateQueryExecutionOverride(v *validator.Validator, seconds *int64, parent time.Duration) {
+	if seconds == nil {
+		return
+	}
+	message := "Query time limit must be greater than 0."
+	if parent > 0 {
+		message = fmt.Sprintf("Query time limit must be between 1 and %d seconds.", int64(parent.Seconds()))
+	}
+	v.CheckField(queryExecutionLimitAllowed(*seconds, parent), "query_max_execution_seconds", message)
+}
+
+// withQueryDeadline bounds r by limit plus queryDeadlineGrace. A zero limit
+// leaves r as it is.
+func withQueryDeadline(r *http.Request, limit time.Duration) (*http.Request, context.CancelFunc) {
+	if limit <= 0 {
+		return r, func() {}
+	}
+	ctx, cancel := context.WithTimeoutCause(r.Context(), limit+queryDeadlineGrace, errQueryTimeLimit)
+	return r.WithContext(ctx), cancel
+}
+
+// isQueryTimeout reports whether err is the statement time limit at work
+// rather than the client going away. r is the request withQueryDeadline
+// returned.
+func isQueryTimeout(r *http.Request, err error) bool {
+	return errors.Is(err, engine.ErrStatementTimeout) || errors.Is(context.Cause(r.Context()), errQueryTimeLimit)
+}
+
+func (app *application) queryTimedOut(w http.ResponseWriter, r *http.Request, limit time.Duration) {
+	seconds := int64(limit.Seconds())
+	app.apiError(w, r, http.StatusUnprocessableEntity, apiErrorQueryTimeout,
+		fmt.Sprintf("Query exceeded the time limit of %d seconds.", seconds),
+		response.APIError{Details: map[string]any{"limit_seconds": seconds}},
+		nil,
+	)
+}
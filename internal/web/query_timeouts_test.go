@@ -0,0 +1,74 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+	"github.com/sqlwarden/internal/database"
+)
+
+func TestQueryExecutionLimits(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, tok, org := seedOrgOwner(t, app, uniqueEmail(t, "timeout-owner"), "Timeout Owner", "Acme")
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Primary Workspace", "")
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	envURL := fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/environments/%d", org.Slug, ws.ID, envID)
+	connectionsURL := orgEnvConnectionsURL(org.Slug, ws.ID, envID)
+
+	updateInstanceSettingsForTest(t, app, func(settings *database.InstanceSettings) {
+		settings.QueryMaxExecutionSeconds = 60
+	})
+
+	res := send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+org.Slug+"/runtime-settings", map[string]any{"query_max_execution_seconds": 120}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "query_max_execution_seconds")
+	res = send(t, newAuthRequest(t, http.MethodPatch, "/api/v1/orgs/"+org.Slug+"/runtime-settings", map[string]any{"query_max_execution_seconds": 30}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.BodyFields["effective"].(map[string]any)["query_max_execution_seconds"], any(float64(30)))
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, envURL, map[string]any{"name": "Default", "query_max_execution_seconds": 45}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "query_max_execution_seconds")
+	res = send(t, newAuthRequest(t, http.MethodPatch, envURL, map[string]any{"name": "Default", "query_max_execution_seconds": 10}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	res = send(t, newAuthRequest(t, http.MethodPost, connectionsURL, map[string]any{
+		"name": "Scratch", "driver": "sqlite", "dsn": ":memory:", "query_max_execution_seconds": 20,
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "query_max_execution_seconds")
+	res = send(t, newAuthRequest(t, http.MethodPost, connectionsURL, map[string]any{
+		"name": "Scratch", "driver": "sqlite", "dsn": ":memory:", "query_max_execution_seconds": 1,
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	assert.Equal(t, res.BodyFields["query_max_execution_seconds"], any(float64(1)))
+	connID := int64(res.BodyFields["id"].(float64))
+	connURL := orgConnectionURL(org.Slug, ws.ID, envID, strconv.FormatInt(connID, 10))
+
+	res = send(t, newAuthRequest(t, http.MethodPost, connURL+"/connect", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	sessionID := res.BodyFields["session_id"].(string)
+
+	runQuery := func(sql string) testResponse {
+		req := newAuthRequest(t, http.MethodPost, connURL+"/query", map[string]any{"sql": sql}, tok)
+		req.Header.Set("X-Warden-Session", sessionID)
+		return send(t, req, app.routes())
+	}
+	res = runQuery("WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n) SELECT count(*) FROM n")
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertAPIError(t, res, apiErrorQueryTimeout, "")
+	assert.Equal(t, apiErrorDetails(t, res)["limit_seconds"], any(float64(1)))
+
+	// The engine stopped the statement itself, so the session stays usable.
+	res = runQuery("SELECT 1")
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"query_max_execution_seconds": nil}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	conn, _ := storedConnection(t, app, connID)
+	assert.Nil(t, conn.QueryMaxExecutionSeconds)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/sqlwarden/assets"
 	"github.com/sqlwarden/internal/access"
+	"github.com/sqlwarden/internal/agent"
 )
 
 func (app *application) routes() http.Handler {
@@ -22,25 +23,36 @@ func (app *application) routes() http.Handler {
 	mux.Use(app.recoverPanic)
 	mux.Use(middleware.Compress(5))
 
-	mux.With(app.noStoreCache).Post("/api/setup", app.setup)
+	mux.With(app.noStoreCache, app.rateLimit(RateLimitGroupAuth)).Post("/api/setup", app.setup)
 	mux.With(app.noStoreCache).Get("/api/setup/status", app.setupStatus)
+	mux.With(app.noStoreCache).Get("/api/ready", app.readiness)
 
 	mux.Route("/api/v1", func(r chi.Router) {
 		// API responses must never be HTTP-cached by the browser; see noStoreCache.
 		r.Use(app.noStoreCache)
+		r.Use(app.rateLimit(RateLimitGroupAPI))
 		r.Use(app.authenticateV1)
 
-		r.Post("/auth/register", app.registerAccount)
-		r.Post("/auth/login", app.loginAccount)
-		r.Post("/auth/refresh", app.refreshToken)
-		r.Post("/auth/logout", app.logoutAccount)
-		r.Get("/invitations/{token}", app.getOrganizationInvitation)
-		r.Post("/invitations/{token}/accept", app.acceptOrganizationInvitation)
+		r.Group(func(r chi.Router) {
+			r.Use(app.rateLimit(RateLimitGroupAuth))
+			r.Post("/auth/register", app.registerAccount)
+			r.Post("/auth/login", app.loginAccount)
+			r.Post("/auth/login/mfa", app.completeMFALogin)
+			r.Post("/auth/login/mfa/webauthn", app.startWebAuthnMFA)
+			r.Post("/auth/webauthn/login/options", app.startWebAuthnLogin)
+			r.Post("/auth/webauthn/login", app.completeWebAuthnLogin)
+			r.Post("/auth/refresh", app.refreshToken)
+			r.Post("/auth/logout", app.logoutAccount)
+			r.Get("/auth/sso/start", app.startSSOLogin)
+			r.Get("/auth/sso/callback", app.completeSSOLogin)
+			r.Get("/invitations/{token}", app.getOrganizationInvitation)
+			r.Post("/invitations/{token}/accept", app.acceptOrganizationInvitation)
+		})
 
-		r.With(app.requireAccount, app.requireInstanceAdmin).Post("/orgs", app.createOrg)
+		r.With(app.requireAccount, app.requireInstanceMFA, app.requireInstanceAdmin).Post("/orgs", app.createOrg)
 
 		r.Route("/instance", func(r chi.Router) {
-			r.Use(app.requireAccount, app.requireInstanceAdmin)
+			r.Use(app.requireAccount, app.requireInstanceMFA, app.requireInstanceAdmin)
 			r.Get("/admins", app.listInstanceAdmins)
 			r.Get("/accounts", app.listInstanceAccounts)
 			r.Get("/orgs", app.listOrganizations)
@@ -52,19 +64,44 @@ func (app *application) routes() http.Handler {
 			r.Get("/accounts/{account_id}/sessions", app.listInstanceAccountSessions)
 			r.Delete("/accounts/{account_id}/sessions", app.revokeInstanceAccountSessions)
 			r.Delete("/accounts/{account_id}/sessions/{session_id}", app.revokeInstanceAccountSession)
+			r.With(app.requireInteractiveSession).Delete("/accounts/{account_id}/mfa", app.resetInstanceAccountMFA)
+			r.Delete("/accounts/{account_id}/lockout", app.unlockInstanceAccount)
 			r.Delete("/admins/{account_id}", app.removeInstanceAdmin)
 			r.Post("/encryption/rotate", app.rotateEncryptionKeysHandler)
+			r.Get("/audit/verify", app.verifyAuditChainsHandler)
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(app.requireAccount)
 			r.Get("/account", app.getAccount)
 			r.Patch("/account", app.updateAccount)
-			r.Patch("/account/password", app.updateAccountPassword)
 			r.Get("/account/orgs", app.getAccountOrgs)
-			r.Get("/account/sessions", app.listAccountSessions)
-			r.Delete("/account/sessions", app.revokeAccountSessions)
-			r.Delete("/account/sessions/{session_id}", app.revokeAccountSession)
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireInteractiveSession)
+				r.Patch("/account/password", app.updateAccountPassword)
+				r.Get("/account/sessions", app.listAccountSessions)
+				r.Delete("/account/sessions", app.revokeAccountSessions)
+				r.Delete("/account/sessions/{session_id}", app.revokeAccountSession)
+				r.Get("/account/tokens", app.listAccountAPITokens)
+				r.Post("/account/tokens", app.createAccountAPIToken)
+				r.Delete("/account/tokens/{token_id}", app.revokeAccountAPIToken)
+				r.Get("/account/mfa", app.getAccountMFA)
+				r.Delete("/account/mfa", app.disableAccountMFA)
+				r.Post("/account/mfa/totp", app.startTOTPEnrollment)
+				r.Post("/account/mfa/totp/confirm", app.confirmTOTPEnrollment)
+				r.Post("/account/mfa/recovery-codes", app.regenerateMFARecoveryCodes)
+				r.Get("/account/webauthn/credentials", app.listWebAuthnCredentials)
+				r.Post("/account/webauthn/credentials", app.createWebAuthnCredential)
+				r.Post("/account/webauthn/registration", app.startWebAuthnRegistration)
+				r.Patch("/account/webauthn/credentials/{credential_id}", app.renameWebAuthnCredential)
+				r.Delete("/account/webauthn/credentials/{credential_id}", app.deleteWebAuthnCredential)
+				r.Get("/account/scim-links", app.listAccountSCIMLinks)
+				r.Post("/account/scim-links/{org_id}/confirm", app.confirmAccountSCIMLink)
+				r.Delete("/account/scim-links/{org_id}", app.declineAccountSCIMLink)
+				r.Get("/account/sso-links", app.listAccountSSOLinks)
+				r.Post("/account/sso-links/{identity_id}/confirm", app.confirmAccountSSOLink)
+				r.Delete("/account/sso-links/{identity_id}", app.declineAccountSSOLink)
+			})
 			r.Get("/session", app.getSession)
 
 			r.Get("/engines", app.listEngines)
@@ -73,7 +110,7 @@ func (app *application) routes() http.Handler {
 		})
 
 		r.Route("/me", func(r chi.Router) {
-			r.Use(app.requireAccount)
+			r.Use(app.requireAccount, app.requireInstanceMFA)
 
 			r.Get("/", app.getAccount)
 
@@ -87,7 +124,7 @@ func (app *application) routes() http.Handler {
 					r.Patch("/", app.updateWorkspace)
 					r.Delete("/", app.deleteWorkspace)
 					r.Get("/sessions", app.listActiveSessions)
-					r.Delete("/sessions/{session_id}", app.revokeWorkspaceDatabaseSession)
+					r.With(app.routeLiveSession).Delete("/sessions/{session_id}", app.revokeWorkspaceDatabaseSession)
 
 					r.Route("/files/private", func(r chi.Router) {
 						r.Get("/", app.listPrivateWorkspaceFiles)
@@ -120,6 +157,7 @@ func (app *application) routes() http.Handler {
 								r.Post("/", app.createMyConnection)
 								r.Route("/{conn_id}", func(r chi.Router) {
 									r.Use(app.spaceConnCtx)
+									r.Use(app.routeLiveSession)
 									r.Get("/", app.getConnection)
 									r.Patch("/", app.updateConnection)
 									r.Delete("/", app.deleteConnection)
@@ -166,6 +204,7 @@ func (app *application) routes() http.Handler {
 						r.Post("/", app.createMyConnection)
 						r.Route("/{conn_id}", func(r chi.Router) {
 							r.Use(app.spaceConnCtx)
+							r.Use(app.routeLiveSession)
 							r.Get("/", app.getConnection)
 							r.Patch("/", app.updateConnection)
 							r.Delete("/", app.deleteConnection)
@@ -215,10 +254,51 @@ func (app *application) routes() http.Handler {
 				r.With(app.requireOrgPermission("org:read")).Get("/{account_id}/teams", app.listOrgMemberTeams)
 				r.With(app.requireOrgPermission("org:write")).Get("/{account_id}/sessions", app.listOrgMemberAccessSessions)
 				r.With(app.requireOrgPermission("org:write")).Delete("/{account_id}/sessions/{session_id}", app.revokeOrgMemberAccessSession)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/{account_id}/mfa", app.resetOrgMemberMFA)
 				r.With(app.requireOrgRole(access.BuiltinOrgOwnerRole)).Patch("/{account_id}", app.updateOrgMemberRole)
 				r.With(app.requireOrgPermission("org:write")).Delete("/{account_id}", app.removeOrgMember)
 			})
 
+			r.Route("/sso", func(r chi.Router) {
+				r.With(app.requireOrgPermission("org:write")).Get("/", app.getOrgSSO)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Put("/", app.updateOrgSSO)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/", app.deleteOrgSSO)
+			})
+
+			r.Route("/egress-policy", func(r chi.Router) {
+				r.With(app.requireOrgPermission("org:read")).Get("/", app.getOrgEgressPolicy)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Put("/", app.updateOrgEgressPolicy)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/", app.deleteOrgEgressPolicy)
+			})
+
+			r.Route("/scim/tokens", func(r chi.Router) {
+				r.With(app.requireOrgPermission("org:write")).Get("/", app.listSCIMTokens)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Post("/", app.createSCIMToken)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/{token_id}", app.revokeSCIMToken)
+			})
+
+			r.Route("/agents", func(r chi.Router) {
+				r.With(app.requireOrgPermission("org:read")).Get("/", app.listConnectorAgents)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Post("/", app.createConnectorAgent)
+				r.Route("/{agent_id}", func(r chi.Router) {
+					r.With(app.requireOrgPermission("org:read")).Get("/", app.getConnectorAgent)
+					r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/", app.deleteConnectorAgent)
+					r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Post("/token", app.rotateConnectorAgentToken)
+				})
+			})
+
+			r.Route("/service-accounts", func(r chi.Router) {
+				r.With(app.requireOrgPermission("org:read")).Get("/", app.listServiceAccounts)
+				r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Post("/", app.createServiceAccount)
+				r.Route("/{service_account_id}", func(r chi.Router) {
+					r.With(app.requireOrgPermission("org:read")).Get("/", app.getServiceAccount)
+					r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/", app.deleteServiceAccount)
+					r.With(app.requireOrgPermission("org:write")).Get("/tokens", app.listServiceAccountTokens)
+					r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Post("/tokens", app.createServiceAccountToken)
+					r.With(app.requireInteractiveSession, app.requireOrgPermission("org:write")).Delete("/tokens/{token_id}", app.revokeServiceAccountToken)
+				})
+			})
+
 			r.Route("/invitations", func(r chi.Router) {
 				r.With(app.requireOrgPermission("org:invite")).Get("/", app.listOrganizationInvitations)
 				r.With(app.requireOrgPermission("org:invite")).Post("/", app.createOrganizationInvitation)
@@ -251,10 +331,14 @@ func (app *application) routes() http.Handler {
 				r.With(app.requireOrgPermission("policy:read")).Get("/", app.listOrgPolicies)
 				r.With(app.requireOrgPermission("policy:modify")).Post("/", app.grantOrgPolicy)
 				r.With(app.requireOrgPermission("policy:modify")).Delete("/{binding_id}", app.revokeOrgPolicy)
+				r.With(app.requireOrgPermission("policy:read")).Get("/export", app.exportOrgPolicy)
+				r.With(app.requireOrgPermission("policy:modify")).Post("/plan", app.planOrgPolicyImport)
+				r.With(app.requireOrgPermission("policy:modify")).Post("/apply", app.applyOrgPolicyImport)
 			})
 
 			r.Get("/permissions", app.listPermissions)
 			r.Get("/permissions/effective", app.getEffectivePermissions)
+			r.With(app.requireOrgPermission("policy:read")).Post("/permissions/explain", app.explainPermission)
 
 			r.Route("/workspaces", func(r chi.Router) {
 				r.Get("/", app.listWorkspaces)
@@ -265,7 +349,7 @@ func (app *application) routes() http.Handler {
 					r.With(app.requireWorkspacePermission("ws:write")).Patch("/", app.updateWorkspace)
 					r.With(app.requireWorkspacePermission("ws:delete")).Delete("/", app.deleteWorkspace)
 					r.Get("/sessions", app.listActiveSessions)
-					r.Delete("/sessions/{session_id}", app.revokeWorkspaceDatabaseSession)
+					r.With(app.routeLiveSession).Delete("/sessions/{session_id}", app.revokeWorkspaceDatabaseSession)
 					r.Route("/jobs", func(r chi.Router) {
 						r.Get("/", app.listWorkspaceJobs)
 						r.Get("/{job_id}", app.getWorkspaceJob)
@@ -275,6 +359,18 @@ func (app *application) routes() http.Handler {
 
 					r.Get("/permissions", app.listWorkspacePermissions)
 
+					r.Route("/deployments", func(r chi.Router) {
+						r.Post("/", app.createDeployment)
+						r.Get("/{deployment_id}", app.getDeployment)
+					})
+
+					r.Route("/migration-sets", func(r chi.Router) {
+						r.Get("/", app.listMigrationSets)
+						r.With(app.requireWorkspacePermission("ws:write")).Post("/", app.createMigrationSet)
+						r.Get("/{set_id}", app.getMigrationSet)
+						r.With(app.requireWorkspacePermission("ws:write")).Delete("/{set_id}", app.deleteMigrationSet)
+					})
+
 					r.Route("/files/private", func(r chi.Router) {
 						r.Get("/", app.listPrivateWorkspaceFiles)
 						r.Post("/", app.createPrivateWorkspaceFile)
@@ -341,6 +437,7 @@ func (app *application) routes() http.Handler {
 							r.Get("/", app.getEnvironment)
 							r.With(app.requireEnvironmentPermission("env:write")).Patch("/", app.updateEnvironment)
 							r.With(app.requireEnvironmentPermission("env:delete")).Delete("/", app.deleteEnvironment)
+							r.With(app.requireEnvironmentPermission("env:read")).Get("/deployments", app.listEnvironmentDeployments)
 
 							r.Route("/connections", func(r chi.Router) {
 								r.With(app.requireEnvironmentPermission("conn:create")).Post("/test", app.testConnection)
@@ -348,10 +445,18 @@ func (app *application) routes() http.Handler {
 								r.With(app.requireEnvironmentPermission("conn:create")).Post("/", app.createConnection)
 								r.Route("/{conn_id}", func(r chi.Router) {
 									r.Use(app.connCtx)
+									r.Use(app.routeLiveSession)
 									r.Get("/", app.getConnection)
 									r.With(app.requireConnectionPermission("conn:update")).Get("/dsn", app.getConnectionDSN)
 									r.With(app.requireConnectionPermission("conn:update")).Patch("/", app.updateConnection)
 									r.With(app.requireConnectionPermission("conn:delete")).Delete("/", app.deleteConnection)
+									r.With(app.requireConnectionPermission("conn:update")).Get("/credentials", app.listConnectionUserCredentials)
+									r.With(app.requireConnectionPermission("conn:update")).Delete("/credentials/{account_id}", app.deleteConnectionUserCredential)
+									r.Get("/credentials/me", app.getMyConnectionCredential)
+									r.Put("/credentials/me", app.setMyConnectionCredential)
+									r.Delete("/credentials/me", app.deleteMyConnectionCredential)
+									r.With(app.requireConnectionPermission("conn:read")).Get("/health", app.getConnectionHealth)
+									r.With(app.requireConnectionPermission("conn:read")).Get("/health/history", app.listConnectionHealthHistory)
 									r.Post("/connect", app.connectToDatabase)
 									r.Delete("/session", app.disconnectFromDatabase)
 									r.Post("/query-cursors", app.startQueryCursor)
@@ -375,6 +480,8 @@ func (app *application) routes() http.Handler {
 									r.Post("/schema/refresh", app.refreshConnectionSchema)
 									r.Post("/schema/mutations", app.applyConnectionDDL)
 									r.Post("/schema/statements", app.generateConnectionStatement)
+									r.With(app.requireConnectionPermission("conn:read")).Get("/migration-sets/{set_id}", app.getConnectionMigrationStatus)
+									r.With(app.requireConnectionPermission("conn:read")).Post("/migration-sets/{set_id}/apply", app.applyConnectionMigrations)
 								})
 							})
 						})
@@ -395,10 +502,18 @@ func (app *application) routes() http.Handler {
 						r.With(app.requireWorkspacePermission("conn:create")).Post("/", app.createConnection)
 						r.Route("/{conn_id}", func(r chi.Router) {
 							r.Use(app.connCtx)
+							r.Use(app.routeLiveSession)
 							r.Get("/", app.getConnection)
 							r.With(app.requireConnectionPermission("conn:update")).Get("/dsn", app.getConnectionDSN)
 							r.With(app.requireConnectionPermission("conn:update")).Patch("/", app.updateConnection)
 							r.With(app.requireConnectionPermission("conn:delete")).Delete("/", app.deleteConnection)
+							r.With(app.requireConnectionPermission("conn:update")).Get("/credentials", app.listConnectionUserCredentials)
+							r.With(app.requireConnectionPermission("conn:update")).Delete("/credentials/{account_id}", app.deleteConnectionUserCredential)
+							r.Get("/credentials/me", app.getMyConnectionCredential)
+							r.Put("/credentials/me", app.setMyConnectionCredential)
+							r.Delete("/credentials/me", app.deleteMyConnectionCredential)
+							r.With(app.requireConnectionPermission("conn:read")).Get("/health", app.getConnectionHealth)
+							r.With(app.requireConnectionPermission("conn:read")).Get("/health/history", app.listConnectionHealthHistory)
 							r.Post("/connect", app.connectToDatabase)
 							r.Delete("/session", app.disconnectFromDatabase)
 							r.Post("/query-cursors", app.startQueryCursor)
@@ -422,6 +537,8 @@ func (app *application) routes() http.Handler {
 							r.Post("/schema/refresh", app.refreshConnectionSchema)
 							r.Post("/schema/mutations", app.applyConnectionDDL)
 							r.Post("/schema/statements", app.generateConnectionStatement)
+							r.With(app.requireConnectionPermission("conn:read")).Get("/migration-sets/{set_id}", app.getConnectionMigrationStatus)
+							r.With(app.requireConnectionPermission("conn:read")).Post("/migration-sets/{set_id}/apply", app.applyConnectionMigrations)
 						})
 					})
 				})
@@ -429,6 +546,37 @@ func (app *application) routes() http.Handler {
 		})
 	})
 
+	// Connector agents authenticate with their registration token and keep
+	// this request open as their link.
+	mux.With(app.noStoreCache, app.rateLimit(RateLimitGroupAuth)).Get(agent.ConnectPath, app.connectAgentLink)
+
+	mux.Route("/scim/v2/orgs/{org_slug}", func(r chi.Router) {
+		r.Use(app.noStoreCache)
+		r.Use(app.rateLimit(RateLimitGroupSCIM))
+		r.Use(app.authenticateSCIM)
+
+		r.Get("/ServiceProviderConfig", app.scimServiceProviderConfig)
+		r.Get("/ResourceTypes", app.scimResourceTypes)
+
+		r.Route("/Users", func(r chi.Router) {
+			r.Get("/", app.listSCIMUsers)
+			r.Post("/", app.createSCIMUser)
+			r.Get("/{user_id}", app.getSCIMUser)
+			r.Put("/{user_id}", app.replaceSCIMUser)
+			r.Patch("/{user_id}", app.patchSCIMUser)
+			r.Delete("/{user_id}", app.deleteSCIMUser)
+		})
+
+		r.Route("/Groups", func(r chi.Router) {
+			r.Get("/", app.listSCIMGroups)
+			r.Post("/", app.createSCIMGroup)
+			r.Get("/{group_id}", app.getSCIMGroup)
+			r.Put("/{group_id}", app.replaceSCIMGroup)
+			r.Patch("/{group_id}", app.patchSCIMGroup)
+			r.Delete("/{group_id}", app.deleteSCIMGroup)
+		})
+	})
+
 	staticFS, err := fs.Sub(assets.EmbeddedFiles, "static")
 	if err != nil {
 		panic(err)
@@ -123,7 +123,27 @@ func (app *application) syncSchemaSnapshot(ctx context.Context, connectionID int
 	if err != nil {
 		return schemaSyncOutput{}, err
 	}
-	if err := driver.Connect(ctx, app.driverConnectionConfig(conn.Driver, plainDSN, settings, conn.DefaultScope)); err != nil {
+	tlsMaterial, err := app.connectionTLS(conn)
+	if err != nil {
+		return schemaSyncOutput{}, err
+	}
+	dsn, lease, err := app.connectionCredentials(ctx, conn, plainDSN)
+	if err != nil {
+		if errors.Is(err, errConnectionCredentials) {
+			return schemaSyncOutput{}, jobs.Retryable("schema_sync_credentials_failed", "Could not resolve the target database credentials.")
+		}
+		return schemaSyncOutput{}, err
+	}
+	defer lease.Close()
+	tunnel, err := app.openConnectionTunnel(ctx, conn)
+	if err != nil {
+		return schemaSyncOutput{}, jobs.Retryable("schema_sync_tunnel_failed", "Could not open the SSH tunnel to the target database.")
+	}
+	defer tunnel.Close()
+	cfg := app.driverConnectionConfig(conn.Driver, dsn, settings, conn.DefaultScope)
+	cfg.Dial = app.connectionDialer(conn, tunnel.Dialer())
+	cfg.TLS = tlsMaterial
+	if err := driver.Connect(ctx, cfg); err != nil {
 		return schemaSyncOutput{}, jobs.Retryable("schema_sync_connect_failed", "Could not connect to the target database.")
 	}
 	defer driver.Close()
@@ -30,6 +30,7 @@ func (app *application) ServeHTTP(ctx context.Context) error {
 
 	go func() {
 		<-ctx.Done()
+		app.drainLiveSessions(context.Background())
 
 		startedAt := time.Now()
 		app.logger.Info("server shutdown started", slog.Group("server", "addr", srv.Addr), "timeout_ms", defaultShutdownPeriod.Milliseconds())
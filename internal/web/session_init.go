@@ -0,0 +1,153 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sqlwarden/internal/database"
+	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/engine/classifier"
+	"github.com/sqlwarden/internal/validator"
+)
+
+const apiErrorSessionInitFailed = "session_init_failed"
+
+const (
+	maxSessionInitVariables       = 20
+	maxSessionInitStatements      = 20
+	maxSessionInitStatementLength = 4096
+)
+
+// sessionInitKeywords are the statements session initialization may run.
+// Together with the classifier rejecting DDL and DML, they keep init to
+// settings that shape the session rather than its data.
+var sessionInitKeywords = map[string]bool{
+	"SET":    true,
+	"RESET":  true,
+	"USE":    true,
+	"PRAGMA": true,
+	"SELECT": true,
+}
+
+// validateSessionInit checks init for a connection using driverName. An
+// environment's init, which serves connections of any driver, is checked with
+// an empty driverName: its statements go through the heuristic classifier and
+// its variables only need valid names. Statements are trimmed in place.
+func validateSessionInit(ctx context.Context, v *validator.Validator, driverName string, init *database.SessionInit) {
+	if init == nil {
+		return
+	}
+	if len(init.Variables) > maxSessionInitVariables {
+		v.AddFieldError("session_init", fmt.Sprintf("Session initialization can set at most %d variables.", maxSessionInitVariables))
+		return
+	}
+	if len(init.Statements) > maxSessionInitStatements {
+		v.AddFieldError("session_init", fmt.Sprintf("Session initialization can run at most %d statements.", maxSessionInitStatements))
+		return
+	}
+
+	var setter engine.SessionVariableSetter
+	if driverName != "" {
+		if d, err := engine.New(driverName); err == nil {
+			setter, _ = d.(engine.SessionVariableSetter)
+		}
+		if setter == nil && len(init.Variables) > 0 {
+			v.AddFieldError("session_init", "This driver does not support session variables.")
+			return
+		}
+	}
+	for i := range init.Variables {
+		variable := &init.Variables[i]
+		variable.Name = strings.TrimSpace(variable.Name)
+		err := engine.CheckSessionVariable(variable.Name, variable.Value)
+		if err == nil && setter != nil {
+			_, err = setter.SessionVariableSQL(variable.Name, variable.Value)
+		}
+		if err != nil {
+			v.AddFieldError("session_init", fmt.Sprintf("Session variable %q is not valid.", variable.Name))
+			return
+		}
+	}
+
+	c := connectionClassifier(driverName)
+	for i := range init.Statements {
+		statement := strings.TrimSuffix(strings.TrimSpace(init.Statements[i]), ";")
+		init.Statements[i] = statement
+		if problem := sessionInitStatementProblem(ctx, c, statement); problem != "" {
+			v.AddFieldError("session_init", fmt.Sprintf("Statement %d %s", i+1, problem))
+			return
+		}
+	}
+}
+
+// sessionInitStatementProblem describes why statement may not run as session
+// initialization, or returns "" when it may.
+func sessionInitStatementProblem(ctx context.Context, c classifier.Classifier, statement string) string {
+	if statement == "" {
+		return "must not be empty."
+	}
+	if len(statement) > maxSessionInitStatementLength {
+		return fmt.Sprintf("must be at most %d characters.", maxSessionInitStatementLength)
+	}
+	classification, err := c.Classify(ctx, classifier.Request{SQL: statement})
+	if err != nil {
+		return "could not be classified."
+	}
+	if classification.StatementCount > 1 || (classification.StatementCount == 0 && strings.Contains(statement, ";")) {
+		return "must be a single statement."
+	}
+	if classification.Kind == classifier.KindDDL || classification.Kind == classifier.KindDML {
+		return "must not change schema or data."
+	}
+	words := strings.Fields(strings.ToUpper(statement))
+	if !sessionInitKeywords[words[0]] || (words[0] == "SELECT" && classification.Kind != classifier.KindDQL) {
+		return "must be a SET, RESET, USE, PRAGMA, or read-only SELECT statement."
+	}
+	if words[0] == "SET" && len(words) > 1 && (words[1] == "GLOBAL" || strings.HasPrefix(words[1], "PERSIST") || strings.HasPrefix(words[1], "@@GLOBAL.") || strings.HasPrefix(words[1], "@@PERSIST")) {
+		return "must only change the session, not the server."
+	}
+	return ""
+}
+
+// effectiveSessionInit is the init conn runs: its own, or else its
+// environment's.
+func effectiveSessionInit(conn database.Connection, env database.Environment) *database.SessionInit {
+	if conn.SessionInit != nil {
+		return conn.SessionInit
+	}
+	return env.SessionInit
+}
+
+// connectionSessionInit renders the statements a new session on conn runs
+// after connecting, with the driver's statements for its session variables
+// ahead of the configured statements.
+func (app *application) connectionSessionInit(ctx context.Context, conn database.Connection) ([]string, error) {
+	env, _, err := app.db.GetEnvironment(ctx, conn.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	init := effectiveSessionInit(conn, env)
+	if init == nil {
+		return nil, nil
+	}
+	statements := make([]string, 0, len(init.Variables)+len(init.Statements))
+	if len(init.Variables) > 0 {
+		d, err := engine.New(conn.Driver)
+		if err != nil {
+			return nil, err
+		}
+		setter, ok := d.(engine.SessionVariableSetter)
+		if !ok {
+			return nil, fmt.Errorf("driver %q does not support session variables", conn.Driver)
+		}
+		for _, variable := range init.Variables {
+			statement, err := setter.SessionVariableSQL(variable.Name, variable.Value)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement)
+		}
+	}
+	return append(statements, init.Statements...), nil
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/sqlwarden/internal/assert"
+)
+
+func TestSessionInitAppliesOnConnect(t *testing.T) {
+	t.Parallel()
+	app := newTestApp(t)
+	owner, tok, org := seedOrgOwner(t, app, uniqueEmail(t, "session-init-owner"), "Session Init Owner", "Acme")
+	ws := seedWorkspaceForAccount(t, app, org, owner, "Primary Workspace", "")
+	envID := defaultEnvironmentID(t, app, ws.ID)
+	envURL := fmt.Sprintf("/api/v1/orgs/%s/workspaces/%d/environments/%d", org.Slug, ws.ID, envID)
+	connectionsURL := orgEnvConnectionsURL(org.Slug, ws.ID, envID)
+
+	for _, statement := range []string{
+		"CREATE TABLE audit (id INTEGER)",
+		"DELETE FROM audit",
+		"VACUUM",
+		"SELECT 1; SELECT 2",
+		"SET GLOBAL max_connections = 10",
+	} {
+		res := send(t, newAuthRequest(t, http.MethodPatch, envURL, map[string]any{
+			"name": "Default", "session_init": map[string]any{"statements": []string{statement}},
+		}, tok), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+		assertValidationField(t, res, "session_init")
+	}
+	res := send(t, newAuthRequest(t, http.MethodPatch, envURL, map[string]any{
+		"name": "Default", "session_init": map[string]any{"variables": []map[string]string{{"name": "cache_size; DROP TABLE t", "value": "1"}}},
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertValidationField(t, res, "session_init")
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, envURL, map[string]any{
+		"name": "Default", "session_init": map[string]any{"variables": []map[string]string{{"name": "cache_size", "value": "-3000"}}},
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+
+	res = send(t, newAuthRequest(t, http.MethodPost, connectionsURL, map[string]any{
+		"name": "Scratch", "driver": "sqlite", "dsn": ":memory:",
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+	connID := int64(res.BodyFields["id"].(float64))
+	connURL := orgConnectionURL(org.Slug, ws.ID, envID, strconv.FormatInt(connID, 10))
+
+	pragma := func(name string) any {
+		t.Helper()
+		res := send(t, newAuthRequest(t, http.MethodPost, connURL+"/connect", nil, tok), app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		req := newAuthRequest(t, http.MethodPost, connURL+"/query", map[string]any{"sql": "SELECT * FROM pragma_" + name + "()"}, tok)
+		req.Header.Set("X-Warden-Session", res.BodyFields["session_id"].(string))
+		res = send(t, req, app.routes())
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		return res.BodyFields["rows"].([]any)[0].([]any)[0].(map[string]any)["integer"]
+	}
+	// The connection has no init of its own, so it inherits the environment's.
+	assert.Equal(t, pragma("cache_size"), any(float64(-3000)))
+
+	// A connection's own init replaces the environment's, and changing it
+	// drops live sessions only when forced.
+	patch := map[string]any{"session_init": map[string]any{
+		"variables":  []map[string]string{{"name": "cache_size", "value": "-5000"}},
+		"statements": []string{"PRAGMA foreign_keys = ON;"},
+	}}
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, patch, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusConflict)
+	patch["force"] = true
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, patch, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	conn, _ := storedConnection(t, app, connID)
+	assert.Equal(t, conn.SessionInit.Statements[0], "PRAGMA foreign_keys = ON")
+	assert.Equal(t, pragma("cache_size"), any(float64(-5000)))
+	assert.Equal(t, pragma("foreign_keys"), any(float64(1)))
+
+	// A statement the database rejects fails the connect and names the
+	// statement.
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{
+		"session_init": map[string]any{"statements": []string{"SELECT missing_function()"}}, "force": true,
+	}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	res = send(t, newAuthRequest(t, http.MethodPost, connURL+"/connect", nil, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assertAPIError(t, res, apiErrorSessionInitFailed, "")
+	assert.Equal(t, apiErrorDetails(t, res)["statement"], any(float64(1)))
+
+	res = send(t, newAuthRequest(t, http.MethodPatch, connURL, map[string]any{"session_init": nil}, tok), app.routes())
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	conn, _ = storedConnection(t, app, connID)
+	assert.Nil(t, conn.SessionInit)
+}
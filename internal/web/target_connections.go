@@ -1,10 +1,15 @@
 package web
 
 import (
+	"context"
 	"errors"
 	"strings"
 
+	"github.com/sqlwarden/internal/connection"
+	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/engine"
+	"github.com/sqlwarden/internal/jobs"
+	"github.com/sqlwarden/internal/secrets"
 )
 
 var errSQLiteTargetDisabled = errors.New("sqlite file target connections are disabled for this instance")
@@ -58,3 +63,74 @@ func isInMemorySQLiteDSN(dsn string) bool {
 		return false
 	}
 }
+
+// targetResources are what a target connection holds open besides its driver:
+// the SSH tunnel and the lease on its credentials. Either may be nil.
+type targetResources struct {
+	tunnel *connection.Tunnel
+	lease  *secrets.Keeper
+}
+
+// Close releases the resources. Callers close the driver first.
+func (r targetResources) Close() error {
+	return errors.Join(r.lease.Close(), r.tunnel.Close())
+}
+
+// openTargetConnection opens a short-lived connection to a stored target for
+// background work, through its SSH tunnel when one is configured and with its
+// credentials resolved from their secret reference when it has one. Callers
+// close the driver and then the returned resources. Failures the caller
+// cannot retry are jobs.CodedError.
+func (app *application) openTargetConnection(ctx context.Context, conn database.Connection) (engine.Driver, targetResources, error) {
+	ws, found, err := app.db.GetWorkspace(ctx, conn.WorkspaceID)
+	if err != nil {
+		return nil, targetResources{}, err
+	}
+	if !found {
+		return nil, targetResources{}, jobs.Permanent("workspace_not_found", "Workspace was not found.")
+	}
+	plainDSN, err := app.keyring.Decrypt(conn.DSNEncrypted)
+	if err != nil {
+		return nil, targetResources{}, err
+	}
+	if err := app.validateTargetConnection(conn.Driver, plainDSN); err != nil {
+		return nil, targetResources{}, jobs.Permanent("target_blocked", targetConnectionFieldError(err))
+	}
+	driver, err := engine.New(conn.Driver)
+	if err != nil {
+		return nil, targetResources{}, jobs.Permanent("target_driver_unavailable", "The target driver is unavailable.")
+	}
+	settings, err := app.effectiveRuntimeSettingsForWorkspace(ctx, ws)
+	if err != nil {
+		return nil, targetResources{}, err
+	}
+	tlsMaterial, err := app.connectionTLS(conn)
+	if err != nil {
+		return nil, targetResources{}, err
+	}
+	dsn, lease, err := app.connectionCredentials(ctx, conn, plainDSN)
+	if err != nil {
+		if errors.Is(err, errConnectionCredentials) {
+			return nil, targetResources{}, jobs.Retryable("target_credentials_failed", "Could not resolve the target database credentials.")
+		}
+		return nil, targetResources{}, err
+	}
+	tunnel, err := app.openConnectionTunnel(ctx, conn)
+	if err != nil {
+		_ = lease.Close()
+		var tunnelErr *connection.TunnelError
+		if errors.As(err, &tunnelErr) {
+			return nil, targetResources{}, jobs.Permanent("target_tunnel_failed", "Could not open the SSH tunnel to the target database.")
+		}
+		return nil, targetResources{}, err
+	}
+	resources := targetResources{tunnel: tunnel, lease: lease}
+	cfg := app.driverConnectionConfig(conn.Driver, dsn, settings, conn.DefaultScope)
+	cfg.Dial = app.connectionDialer(conn, tunnel.Dialer())
+	cfg.TLS = tlsMaterial
+	if err := driver.Connect(ctx, cfg); err != nil {
+		_ = resources.Close()
+		return nil, targetResources{}, jobs.Permanent("target_connect_failed", "Could not connect to the target database.")
+	}
+	return driver, resources, nil
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/sqlwarden/internal/connection"
 	"github.com/sqlwarden/internal/database"
 	"github.com/sqlwarden/internal/encrypt"
+	"github.com/sqlwarden/internal/secrets"
 	"github.com/sqlwarden/internal/smtp"
 	"github.com/sqlwarden/internal/token"
 
@@ -83,6 +84,7 @@ func newTestApplication(t *testing.T) *application {
 		t.Fatal(err)
 	}
 	app.keyring = keyring
+	app.secrets = secrets.NewResolver(app.logger)
 	app.fileStores, err = newFileStoreRegistry(app.config)
 	if err != nil {
 		t.Fatal(err)
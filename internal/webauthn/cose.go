@@ -0,0 +1,116 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for credentials, in order of preference.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// COSE key parameters (RFC 9053).
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseRSAN      = -1
+	coseRSAE      = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+
+	minRSABits = 2048
+)
+
+var errUnsupportedKey = errors.New("webauthn: unsupported public key")
+
+// publicKey is a parsed COSE credential public key.
+type publicKey struct {
+	algorithm int64
+	key       crypto.PublicKey
+}
+
+// parsePublicKey parses a COSE_Key encoded credential public key.
+func parsePublicKey(data []byte) (publicKey, error) {
+	v, rest, err := decodeCBOR(data)
+	if err != nil {
+		return publicKey{}, err
+	}
+	if len(rest) != 0 {
+		return publicKey{}, errCBOR
+	}
+	return publicKeyFromMap(v)
+}
+
+func publicKeyFromMap(v any) (publicKey, error) {
+	m, ok := v.(map[any]any)
+	if !ok {
+		return publicKey{}, errUnsupportedKey
+	}
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, errUnsupportedKey
+		}
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return publicKey{}, fmt.Errorf("%w: %v", errUnsupportedKey, err)
+		}
+		return publicKey{algorithm: alg, key: key}, nil
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errUnsupportedKey
+		}
+		return publicKey{algorithm: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		modulus := new(big.Int).SetBytes(n)
+		exponent := new(big.Int).SetBytes(e)
+		if modulus.BitLen() < minRSABits || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return publicKey{}, errUnsupportedKey
+		}
+		return publicKey{algorithm: alg, key: &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}}, nil
+	}
+	return publicKey{}, errUnsupportedKey
+}
+
+// verify checks sig over data with the key's algorithm.
+func (k publicKey) verify(data, sig []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
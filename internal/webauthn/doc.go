@@ -0,0 +1,8 @@
+// Package webauthn is a minimal WebAuthn relying party. It builds the options
+// for the registration and authentication ceremonies and verifies the
+// authenticator's responses: client data, authenticator data flags, the
+// sign counter, and assertion signatures for ES256, EdDSA and RS256 keys.
+//
+// Attestation is not requested and attestation statements are not verified;
+// a credential is trusted because a signed-in account registered it.
+package webauthn
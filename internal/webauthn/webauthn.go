@@ -0,0 +1,457 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Timeout is how long a ceremony may take. Browsers treat it as a hint.
+	Timeout = 5 * time.Minute
+
+	challengeBytes = 32
+	// Authenticator data starts with the SHA-256 hash of the relying party
+	// ID, a flags byte and a four-byte sign counter.
+	rpIDHashLength  = 32
+	authDataMinimum = rpIDHashLength + 1 + 4
+	aaguidLength    = 16
+	maxCredentialID = 1023
+
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+	credentialType       = "public-key"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+var (
+	ErrInvalidResponse     = errors.New("webauthn: invalid response")
+	ErrChallengeMismatch   = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch      = errors.New("webauthn: origin does not match")
+	ErrUserNotVerified     = errors.New("webauthn: user verification required")
+	ErrInvalidSignature    = errors.New("webauthn: invalid signature")
+	ErrSignCountRegression = errors.New("webauthn: sign counter did not increase")
+)
+
+// UserVerification is the relying party's requirement for the authenticator
+// to verify the user, with a PIN or biometric, in addition to their presence.
+type UserVerification string
+
+const (
+	UserVerificationRequired    UserVerification = "required"
+	UserVerificationPreferred   UserVerification = "preferred"
+	UserVerificationDiscouraged UserVerification = "discouraged"
+)
+
+// Valid reports whether uv is a known requirement.
+func (uv UserVerification) Valid() bool {
+	switch uv {
+	case UserVerificationRequired, UserVerificationPreferred, UserVerificationDiscouraged:
+		return true
+	}
+	return false
+}
+
+// Bytes is binary data that is base64url-encoded in JSON, matching the
+// browser's PublicKeyCredential toJSON() serialization.
+type Bytes []byte
+
+func (b Bytes) String() string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := DecodeBytes(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// DecodeBytes decodes base64url, with or without padding.
+func DecodeBytes(s string) (Bytes, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// NewChallenge returns a random ceremony challenge.
+func NewChallenge() (Bytes, error) {
+	b := make([]byte, challengeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RelyingParty identifies this server to authenticators. Credentials are
+// scoped to ID, a registrable domain, and responses must come from Origin.
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// RelyingPartyFromURL derives the relying party from the URL users reach the
+// application at.
+func RelyingPartyFromURL(name, baseURL string) (RelyingParty, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return RelyingParty{}, fmt.Errorf("webauthn: invalid base url %q", baseURL)
+	}
+	return RelyingParty{ID: u.Hostname(), Name: name, Origin: u.Scheme + "://" + u.Host}, nil
+}
+
+// User is the account a credential is registered for. ID is an opaque user
+// handle, returned by discoverable credentials when signing in.
+type User struct {
+	ID          Bytes
+	Name        string
+	DisplayName string
+}
+
+// CredentialDescriptor names a credential in allow and exclude lists.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// NewCredentialDescriptor describes the credential with id.
+func NewCredentialDescriptor(id Bytes, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: credentialType, ID: id, Transports: transports}
+}
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string           `json:"residentKey"`
+	UserVerification UserVerification `json:"userVerification"`
+}
+
+// CreationOptions are the PublicKeyCredentialCreationOptions for a
+// registration ceremony, in the JSON form navigator.credentials.create()
+// accepts after PublicKeyCredential.parseCreationOptionsFromJSON().
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge"`
+	RP                     relyingPartyEntity     `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// CreationOptions returns registration options for user. Discoverable
+// credentials are preferred so the credential can sign in without a password.
+func (rp RelyingParty) CreationOptions(user User, challenge Bytes, exclude []CredentialDescriptor, uv UserVerification) CreationOptions {
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        relyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:      userEntity{ID: user.ID, Name: user.Name, DisplayName: user.DisplayName},
+		PubKeyCredParams: []credentialParameter{
+			{Type: credentialType, Alg: AlgES256},
+			{Type: credentialType, Alg: AlgEdDSA},
+			{Type: credentialType, Alg: AlgRS256},
+		},
+		Timeout:                Timeout.Milliseconds(),
+		ExcludeCredentials:     exclude,
+		AuthenticatorSelection: authenticatorSelection{ResidentKey: "preferred", UserVerification: uv},
+		Attestation:            "none",
+	}
+}
+
+// RequestOptions are the PublicKeyCredentialRequestOptions for an
+// authentication ceremony.
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification UserVerification       `json:"userVerification"`
+}
+
+// RequestOptions returns authentication options. An empty allow list lets the
+// authenticator offer any discoverable credential for the relying party.
+func (rp RelyingParty) RequestOptions(challenge Bytes, allow []CredentialDescriptor, uv UserVerification) RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: uv,
+	}
+}
+
+// RegistrationResponse is the browser's serialized PublicKeyCredential from
+// navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes    `json:"clientDataJSON"`
+		AttestationObject Bytes    `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// Challenge returns the challenge the client signed, so the server can find
+// the ceremony it belongs to. It is not verified.
+func (r RegistrationResponse) Challenge() (Bytes, error) {
+	return clientChallenge(r.Response.ClientDataJSON)
+}
+
+// AssertionResponse is the browser's serialized PublicKeyCredential from
+// navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Challenge returns the challenge the client signed. It is not verified.
+func (r AssertionResponse) Challenge() (Bytes, error) {
+	return clientChallenge(r.Response.ClientDataJSON)
+}
+
+// Credential is a verified new credential to store for the account.
+type Credential struct {
+	ID             Bytes
+	PublicKey      Bytes
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         Bytes
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool
+}
+
+// Assertion is the outcome of a verified authentication ceremony.
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// VerifyRegistration checks a registration response against the ceremony's
+// challenge and returns the new credential.
+func (rp RelyingParty) VerifyRegistration(res RegistrationResponse, challenge Bytes, uv UserVerification) (Credential, error) {
+	if res.Type != credentialType {
+		return Credential{}, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, res.Type)
+	}
+	if err := rp.verifyClientData(res.Response.ClientDataJSON, clientDataTypeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+
+	attestation, rest, err := decodeCBOR(res.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return Credential{}, fmt.Errorf("%w: attestation object", ErrInvalidResponse)
+	}
+	fields, ok := attestation.(map[any]any)
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: attestation object", ErrInvalidResponse)
+	}
+	authData, ok := fields["authData"].([]byte)
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: missing authenticator data", ErrInvalidResponse)
+	}
+
+	data, err := rp.parseAuthenticatorData(authData, uv)
+	if err != nil {
+		return Credential{}, err
+	}
+	if data.flags&flagAttestedData == 0 {
+		return Credential{}, fmt.Errorf("%w: missing attested credential data", ErrInvalidResponse)
+	}
+	attested := data.rest
+	if len(attested) < aaguidLength+2 {
+		return Credential{}, fmt.Errorf("%w: attested credential data", ErrInvalidResponse)
+	}
+	aaguid := attested[:aaguidLength]
+	idLength := int(binary.BigEndian.Uint16(attested[aaguidLength:]))
+	attested = attested[aaguidLength+2:]
+	if idLength == 0 || idLength > maxCredentialID || len(attested) < idLength {
+		return Credential{}, fmt.Errorf("%w: credential id", ErrInvalidResponse)
+	}
+	credentialID := attested[:idLength]
+	if len(res.RawID) > 0 && !bytes.Equal(res.RawID, credentialID) {
+		return Credential{}, fmt.Errorf("%w: credential id does not match", ErrInvalidResponse)
+	}
+
+	keyValue, extensions, err := decodeCBOR(attested[idLength:])
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: credential public key", ErrInvalidResponse)
+	}
+	if data.flags&flagExtensionData == 0 && len(extensions) != 0 {
+		return Credential{}, fmt.Errorf("%w: trailing authenticator data", ErrInvalidResponse)
+	}
+	key, err := publicKeyFromMap(keyValue)
+	if err != nil {
+		return Credential{}, err
+	}
+	keyBytes := attested[idLength : len(attested)-len(extensions)]
+
+	return Credential{
+		ID:             append(Bytes(nil), credentialID...),
+		PublicKey:      append(Bytes(nil), keyBytes...),
+		Algorithm:      key.algorithm,
+		SignCount:      data.signCount,
+		AAGUID:         append(Bytes(nil), aaguid...),
+		Transports:     res.Response.Transports,
+		UserVerified:   data.flags&flagUserVerified != 0,
+		BackupEligible: data.flags&flagBackupEligible != 0,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication response against the ceremony's
+// challenge and the stored credential's public key and sign counter.
+//
+// A counter that fails to increase suggests a cloned authenticator and is
+// rejected. Authenticators that do not keep a counter always report zero.
+func (rp RelyingParty) VerifyAssertion(res AssertionResponse, challenge Bytes, storedPublicKey Bytes, storedSignCount uint32, uv UserVerification) (Assertion, error) {
+	if res.Type != credentialType {
+		return Assertion{}, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, res.Type)
+	}
+	if err := rp.verifyClientData(res.Response.ClientDataJSON, clientDataTypeGet, challenge); err != nil {
+		return Assertion{}, err
+	}
+	data, err := rp.parseAuthenticatorData(res.Response.AuthenticatorData, uv)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	key, err := parsePublicKey(storedPublicKey)
+	if err != nil {
+		return Assertion{}, err
+	}
+	clientDataHash := sha256.Sum256(res.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), res.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, res.Response.Signature) {
+		return Assertion{}, ErrInvalidSignature
+	}
+
+	if (data.signCount != 0 || storedSignCount != 0) && data.signCount <= storedSignCount {
+		return Assertion{}, ErrSignCountRegression
+	}
+	return Assertion{SignCount: data.signCount, UserVerified: data.flags&flagUserVerified != 0}, nil
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func clientChallenge(raw Bytes) (Bytes, error) {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: client data", ErrInvalidResponse)
+	}
+	challenge, err := DecodeBytes(data.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, fmt.Errorf("%w: client data challenge", ErrInvalidResponse)
+	}
+	return challenge, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw Bytes, wantType string, challenge Bytes) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: client data", ErrInvalidResponse)
+	}
+	if data.Type != wantType {
+		return fmt.Errorf("%w: client data type %q", ErrInvalidResponse, data.Type)
+	}
+	got, err := DecodeBytes(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+	if data.Origin != rp.Origin || data.CrossOrigin {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	flags     byte
+	signCount uint32
+	rest      []byte
+}
+
+func (rp RelyingParty) parseAuthenticatorData(raw []byte, uv UserVerification) (authenticatorData, error) {
+	if len(raw) < authDataMinimum {
+		return authenticatorData{}, fmt.Errorf("%w: authenticator data", ErrInvalidResponse)
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(raw[:rpIDHashLength], rpIDHash[:]) != 1 {
+		return authenticatorData{}, fmt.Errorf("%w: relying party id does not match", ErrInvalidResponse)
+	}
+	data := authenticatorData{
+		flags:     raw[rpIDHashLength],
+		signCount: binary.BigEndian.Uint32(raw[rpIDHashLength+1:]),
+		rest:      raw[authDataMinimum:],
+	}
+	if data.flags&flagUserPresent == 0 {
+		return authenticatorData{}, fmt.Errorf("%w: user not present", ErrInvalidResponse)
+	}
+	if uv == UserVerificationRequired && data.flags&flagUserVerified == 0 {
+		return authenticatorData{}, ErrUserNotVerified
+	}
+	if data.flags&flagBackedUp != 0 && data.flags&flagBackupEligible == 0 {
+		return authenticatorData{}, fmt.Errorf("%w: backup flags", ErrInvalidResponse)
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sqlwarden/internal/webauthn"
+	"github.com/sqlwarden/internal/webauthn/webauthntest"
+)
+
+func testRelyingParty(t *testing.T) webauthn.RelyingParty {
+	t.Helper()
+	rp, err := webauthn.RelyingPartyFromURL("SQLWarden", "https://sqlwarden.example.com:8443/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.ID != "sqlwarden.example.com" || rp.Origin != "https://sqlwarden.example.com:8443" {
+		t.Fatalf("unexpected relying party %+v", rp)
+	}
+	return rp
+}
+
+func register(t *testing.T, rp webauthn.RelyingParty, authenticator *webauthntest.Authenticator) webauthn.Credential {
+	t.Helper()
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.VerifyRegistration(authenticator.Register(t, challenge, []byte("user-1")), challenge, webauthn.UserVerificationPreferred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credential
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := testRelyingParty(t)
+	authenticator := webauthntest.New(t, rp.Origin, rp.ID)
+
+	credential := register(t, rp, authenticator)
+	if string(credential.ID) != string(authenticator.CredentialID) || credential.Algorithm != webauthn.AlgES256 || !credential.UserVerified {
+		t.Fatalf("unexpected credential %+v", credential)
+	}
+
+	challenge, _ := webauthn.NewChallenge()
+	res := authenticator.Assert(t, challenge)
+	got, err := res.Challenge()
+	if err != nil || string(got) != string(challenge) {
+		t.Fatalf("challenge: got %x, err %v", got, err)
+	}
+	assertion, err := rp.VerifyAssertion(res, challenge, credential.PublicKey, credential.SignCount, webauthn.UserVerificationRequired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assertion.SignCount != 1 || !assertion.UserVerified {
+		t.Fatalf("unexpected assertion %+v", assertion)
+	}
+
+	// The browser's JSON form round-trips.
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded webauthn.AssertionResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(decoded, challenge, credential.PublicKey, 0, webauthn.UserVerificationRequired); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyAssertionRejectsInvalidResponses(t *testing.T) {
+	rp := testRelyingParty(t)
+	authenticator := webauthntest.New(t, rp.Origin, rp.ID)
+	credential := register(t, rp, authenticator)
+	challenge, _ := webauthn.NewChallenge()
+
+	other, _ := webauthn.NewChallenge()
+	if _, err := rp.VerifyAssertion(authenticator.Assert(t, challenge), other, credential.PublicKey, 0, webauthn.UserVerificationPreferred); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+		t.Fatalf("wrong challenge: got %v", err)
+	}
+
+	phishing := webauthntest.New(t, "https://sqlwarden.example.net", rp.ID)
+	phishing.CredentialID = authenticator.CredentialID
+	if _, err := rp.VerifyAssertion(phishing.Assert(t, challenge), challenge, credential.PublicKey, 0, webauthn.UserVerificationPreferred); !errors.Is(err, webauthn.ErrOriginMismatch) {
+		t.Fatalf("wrong origin: got %v", err)
+	}
+
+	forged := phishing.Assert(t, challenge)
+	forged.Response.ClientDataJSON = authenticator.Assert(t, challenge).Response.ClientDataJSON
+	if _, err := rp.VerifyAssertion(forged, challenge, credential.PublicKey, 0, webauthn.UserVerificationPreferred); !errors.Is(err, webauthn.ErrInvalidSignature) {
+		t.Fatalf("wrong key: got %v", err)
+	}
+
+	authenticator.UserVerified = false
+	if _, err := rp.VerifyAssertion(authenticator.Assert(t, challenge), challenge, credential.PublicKey, 0, webauthn.UserVerificationRequired); !errors.Is(err, webauthn.ErrUserNotVerified) {
+		t.Fatalf("user verification: got %v", err)
+	}
+
+	authenticator.UserVerified = true
+	res := authenticator.Assert(t, challenge)
+	if _, err := rp.VerifyAssertion(res, challenge, credential.PublicKey, authenticator.SignCount, webauthn.UserVerificationPreferred); !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("sign count: got %v", err)
+	}
+}
+
+func TestVerifyRegistrationRejectsOtherRelyingParty(t *testing.T) {
+	rp := testRelyingParty(t)
+	authenticator := webauthntest.New(t, rp.Origin, "example.com")
+	challenge, _ := webauthn.NewChallenge()
+
+	_, err := rp.VerifyRegistration(authenticator.Register(t, challenge, []byte("user-1")), challenge, webauthn.UserVerificationPreferred)
+	if !errors.Is(err, webauthn.ErrInvalidResponse) {
+		t.Fatalf("got %v", err)
+	}
+
+	res := webauthntest.New(t, rp.Origin, rp.ID).Register(t, challenge, []byte("user-1"))
+	res.Response.AttestationObject = res.Response.AttestationObject[:len(res.Response.AttestationObject)-3]
+	if _, err := rp.VerifyRegistration(res, challenge, webauthn.UserVerificationPreferred); !errors.Is(err, webauthn.ErrInvalidResponse) {
+		t.Fatalf("truncated attestation: got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package webauthntest is a software WebAuthn authenticator for tests. It
+// holds one ES256 credential and produces the responses a browser would send
+// for registration and authentication ceremonies.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/sqlwarden/internal/webauthn"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// Authenticator is a single software credential. SignCount is incremented by
+// each assertion; set it to simulate a cloned authenticator.
+type Authenticator struct {
+	Origin       string
+	RPID         string
+	CredentialID []byte
+	UserHandle   []byte
+	SignCount    uint32
+	// UserVerified controls whether responses claim the user was verified.
+	UserVerified bool
+
+	key *ecdsa.PrivateKey
+}
+
+// New returns an authenticator for the relying party at origin with rpID.
+func New(t testing.TB, origin, rpID string) *Authenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &Authenticator{Origin: origin, RPID: rpID, CredentialID: id, UserVerified: true, key: key}
+}
+
+// Register answers a registration ceremony with challenge for userHandle.
+func (a *Authenticator) Register(t testing.TB, challenge, userHandle []byte) webauthn.RegistrationResponse {
+	t.Helper()
+
+	a.UserHandle = userHandle
+	point, err := a.key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coseKey := encode(map[int]any{1: 2, 3: int(webauthn.AlgES256), -1: 1, -2: point[1:33], -3: point[33:]})
+
+	authData := a.authenticatorData(flagAttestedData)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, coseKey...)
+
+	var res webauthn.RegistrationResponse
+	res.ID = webauthn.Bytes(a.CredentialID).String()
+	res.RawID = a.CredentialID
+	res.Type = "public-key"
+	res.Response.ClientDataJSON = a.clientData(t, "webauthn.create", challenge)
+	res.Response.AttestationObject = encode(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": authData})
+	res.Response.Transports = []string{"internal"}
+	return res
+}
+
+// Assert answers an authentication ceremony with challenge.
+func (a *Authenticator) Assert(t testing.TB, challenge []byte) webauthn.AssertionResponse {
+	t.Helper()
+
+	a.SignCount++
+	authData := a.authenticatorData(0)
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res webauthn.AssertionResponse
+	res.ID = webauthn.Bytes(a.CredentialID).String()
+	res.RawID = a.CredentialID
+	res.Type = "public-key"
+	res.Response.ClientDataJSON = clientData
+	res.Response.AuthenticatorData = authData
+	res.Response.Signature = sig
+	res.Response.UserHandle = a.UserHandle
+	return res
+}
+
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) clientData(t testing.TB, typ string, challenge []byte) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"type":      typ,
+		"challenge": webauthn.Bytes(challenge).String(),
+		"origin":    a.Origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// encode is a CBOR encoder for the value shapes the authenticator emits.
+// Map keys are sorted, as CTAP2 canonical encoding requires.
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case map[int]any:
+		keys := make([]int, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return cborIntLess(keys[i], keys[j]) })
+		out := header(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		out := header(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported cbor value")
+}
+
+// cborIntLess orders integer keys by their encoding: unsigned before
+// negative, then by magnitude.
+func cborIntLess(a, b int) bool {
+	if (a < 0) != (b < 0) {
+		return a >= 0
+	}
+	if a < 0 {
+		return a > b
+	}
+	return a < b
+}
+
+func header(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
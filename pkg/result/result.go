@@ -18,6 +18,9 @@ type ResultSet struct {
 	QueryCursorID    string   `json:"query_cursor_id,omitempty"`
 	Exhausted        *bool    `json:"exhausted,omitempty"`
 	PageSize         int      `json:"page_size,omitempty"`
+	// Endpoint names the server that answered: "primary" or a read replica.
+	// It is only set for connections with read replicas.
+	Endpoint string `json:"endpoint,omitempty"`
 }
 
 // NewExecutionResult returns a columnless result for an executed statement.